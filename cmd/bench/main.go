@@ -0,0 +1,195 @@
+// Command bench drives synthetic CloudWatch alarms through the adapter and dispatcher at a fixed
+// rate, with Slack replaced by an in-process stub, and reports throughput, allocations and
+// latency percentiles. It shows how many alerts a replica keeps up with during an alert storm.
+//
+//	bench --rate 500 --duration 30s --workers 16
+//
+// Routes, drop rules and rollups from --config are applied, but their targets and notifiers are
+// removed so nothing leaves the process.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"alert-dispatcher/internal/adapter"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/dispatch"
+	"alert-dispatcher/internal/state"
+)
+
+func main() {
+	rate := flag.Int("rate", 200, "alerts generated per second")
+	duration := flag.Duration("duration", 30*time.Second, "how long to generate load")
+	workers := flag.Int("workers", 16, "alerts dispatched concurrently")
+	alarms := flag.Int("alarms", 100, "distinct alarm names to cycle through")
+	slackLatency := flag.Duration("slack-latency", 0, "delay the Slack stub adds to each API call")
+	configPath := flag.String("config", "", "alarm-channels.yaml to route with (optional)")
+	flag.Parse()
+
+	if *rate <= 0 || *duration <= 0 || *workers <= 0 || *alarms <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := benchConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var slackCalls atomic.Int64
+	slackStub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := slackCalls.Add(1)
+		if *slackLatency > 0 {
+			time.Sleep(*slackLatency)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": "C0BENCH", "ts": fmt.Sprintf("%d.000100", n)})
+	}))
+	defer slackStub.Close()
+	cfg.SlackAPIURL = slackStub.URL
+
+	dispatcher := dispatch.NewDispatcher(cfg, state.NewMemoryStore())
+
+	// The dispatcher logs every alert, which would dominate the measurement
+	log.SetOutput(io.Discard)
+
+	type job struct {
+		body      string
+		scheduled time.Time
+	}
+	jobs := make(chan job, *workers)
+	latencies := make([]time.Duration, 0, *rate*int(duration.Seconds()+1))
+	var latenciesMu sync.Mutex
+	var failures atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				alertMsg, err := adapter.AdaptSQSMessageWithRouting(j.body, cfg.SlackChannels, cfg.AlarmChannels, cfg)
+				if err == nil {
+					err = dispatcher.Dispatch(context.Background(), alertMsg, "")
+				}
+				if err != nil {
+					failures.Add(1)
+				}
+
+				latency := time.Since(j.scheduled)
+				latenciesMu.Lock()
+				latencies = append(latencies, latency)
+				latenciesMu.Unlock()
+			}
+		}()
+	}
+
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	// Alerts are scheduled at fixed intervals, so time spent queued behind slow dispatches counts
+	// towards their latency as it would in a backed-up SQS queue
+	interval := time.Second / time.Duration(*rate)
+	start := time.Now()
+	var sent int
+	for scheduled := start; scheduled.Sub(start) < *duration; scheduled = scheduled.Add(interval) {
+		if wait := time.Until(scheduled); wait > 0 {
+			time.Sleep(wait)
+		}
+		jobs <- job{body: syntheticAlarm(sent, *alarms), scheduled: scheduled}
+		sent++
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	fmt.Fprintf(os.Stderr, "alerts:      %d in %s (%d failed, %d Slack calls)\n", sent, elapsed.Round(time.Millisecond), failures.Load(), slackCalls.Load())
+	fmt.Fprintf(os.Stderr, "throughput:  %.1f alerts/s (target %d/s)\n", float64(sent)/elapsed.Seconds(), *rate)
+	fmt.Fprintf(os.Stderr, "allocations: %d allocs/alert, %s/alert\n",
+		(after.Mallocs-before.Mallocs)/uint64(sent), byteSize((after.TotalAlloc-before.TotalAlloc)/uint64(sent)))
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Fprintf(os.Stderr, "latency:     p50 %s  p90 %s  p99 %s  max %s\n",
+		percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99), latencies[len(latencies)-1].Round(time.Microsecond))
+}
+
+// benchConfig routes with the given alarm channel config, or priority channels alone, and strips
+// every destination other than Slack
+func benchConfig(path string) (*config.Config, error) {
+	cfg := &config.Config{
+		SlackBotToken: "xoxb-bench",
+		SlackChannels: config.PriorityChannels(),
+		SlackTimeout:  10 * time.Second,
+	}
+	if path == "" {
+		return cfg, nil
+	}
+
+	alarmConfig, err := config.LoadAlarmChannelConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for channel, route := range alarmConfig.Routes {
+		route.Targets = nil
+		route.GoogleChatWebhook = ""
+		route.MattermostChannel = ""
+		alarmConfig.Routes[channel] = route
+	}
+	cfg.AlarmChannels = alarmConfig.AlarmMappings
+	cfg.Routes = alarmConfig.Routes
+	cfg.DropRules = alarmConfig.DropRules
+	return cfg, nil
+}
+
+// Name stems spread synthetic alarms across priorities the way the adapter assigns them
+var alarmStems = []string{"prod-api-cpu", "redis-cache-evictions", "staging-worker-errors", "orders-queue-depth"}
+
+// syntheticAlarm returns an SNS-wrapped CloudWatch alarm; every fifth notification is an OK
+func syntheticAlarm(n, alarms int) string {
+	state := "ALARM"
+	if n%5 == 4 {
+		state = "OK"
+	}
+	alarm := adapter.CloudWatchAlarm{
+		AlarmName:       fmt.Sprintf("%s-%d", alarmStems[n%len(alarmStems)], n%alarms),
+		NewStateValue:   state,
+		OldStateValue:   "OK",
+		NewStateReason:  "Threshold Crossed: 1 datapoint [92.5] was greater than the threshold (80.0).",
+		StateChangeTime: time.Now().UTC().Format("2006-01-02T15:04:05.000+0000"),
+		Region:          "US East (N. Virginia)",
+	}
+	alarm.Trigger.MetricName = "CPUUtilization"
+	alarm.Trigger.Namespace = "AWS/EC2"
+
+	message, _ := json.Marshal(alarm)
+	envelope, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": string(message)})
+	return string(envelope)
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	return sorted[(len(sorted)-1)*p/100].Round(time.Microsecond)
+}
+
+func byteSize(b uint64) string {
+	if b < 1024 {
+		return fmt.Sprintf("%d B", b)
+	}
+	return fmt.Sprintf("%.1f KiB", float64(b)/1024)
+}
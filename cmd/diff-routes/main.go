@@ -0,0 +1,212 @@
+// Command diff-routes replays a corpus of alert payloads through two versions of
+// alarm-channels.yaml and prints every alert whose routing would change.
+//
+//	diff-routes --old old.yaml --new new.yaml --corpus payloads/
+//
+// Priority channels come from the same SLACK_CHANNEL_* env vars as the dispatcher. Nothing is
+// sent. The exit status is 1 when any alert would be routed differently, like diff.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"alert-dispatcher/internal/adapter"
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/dispatch"
+	"alert-dispatcher/internal/state"
+)
+
+func main() {
+	oldPath := flag.String("old", "", "current alarm-channels.yaml")
+	newPath := flag.String("new", "", "proposed alarm-channels.yaml")
+	corpus := flag.String("corpus", "", "directory of alert payloads (SQS/SNS messages or built-in webhook payloads)")
+	flag.Parse()
+
+	if *oldPath == "" || *newPath == "" || *corpus == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldRouting, err := newRouting(*oldPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	newRouting, err := newRouting(*newPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	files, err := corpusFiles(*corpus)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The dispatcher logs every drop and reroute, which would bury the diff
+	log.SetOutput(io.Discard)
+
+	var changed, skipped int
+	for _, file := range files {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", file, err)
+			skipped++
+			continue
+		}
+
+		before, err := oldRouting.plan(string(body))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", file, err)
+			skipped++
+			continue
+		}
+		after, err := newRouting.plan(string(body))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s: %v\n", file, err)
+			skipped++
+			continue
+		}
+
+		if before.summary != after.summary {
+			changed++
+			fmt.Printf("%s (%s)\n  - %s\n  + %s\n", before.name, file, before.summary, after.summary)
+		}
+	}
+
+	fmt.Printf("\n%d of %d alerts would be routed differently", changed, len(files)-skipped)
+	if skipped > 0 {
+		fmt.Printf(", %d payloads skipped", skipped)
+	}
+	fmt.Println()
+
+	if changed > 0 {
+		os.Exit(1)
+	}
+}
+
+// routing adapts and plans alerts with one version of the alarm channel config
+type routing struct {
+	config     *config.Config
+	dispatcher *dispatch.Dispatcher
+}
+
+func newRouting(path string) (*routing, error) {
+	alarmConfig, err := config.LoadAlarmChannelConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	cfg := &config.Config{
+		SlackChannels:   config.PriorityChannels(),
+		AlarmChannels:   alarmConfig.AlarmMappings,
+		PriorityTargets: alarmConfig.PriorityTargets,
+		AlarmTargets:    alarmConfig.AlarmTargets,
+		Routes:          alarmConfig.Routes,
+		DropRules:       alarmConfig.DropRules,
+		Notifiers:       alarmConfig.Notifiers,
+	}
+	return &routing{config: cfg, dispatcher: dispatch.NewDispatcher(cfg, state.NewMemoryStore())}, nil
+}
+
+type plannedAlert struct {
+	name    string
+	summary string
+}
+
+// plan adapts the payload as the SQS poller would, falling back to the formats of the webhooks
+// configured webhooks can use, in the order of adapter.Inputs
+func (r *routing) plan(body string) (plannedAlert, error) {
+	alertMsg, err := adapter.AdaptSQSMessageWithRouting(body, r.config.SlackChannels, r.config.AlarmChannels, r.config)
+	if err != nil {
+		alertMsg, err = r.adaptWebhook(body, err)
+		if err != nil {
+			return plannedAlert{}, err
+		}
+	}
+
+	plan, err := r.dispatcher.PlanRoute(context.Background(), alertMsg)
+	if err != nil {
+		return plannedAlert{}, err
+	}
+	return plannedAlert{name: alertMsg.Name, summary: summarize(plan)}, nil
+}
+
+// adaptWebhook tries each configurable webhook adapter in turn, reporting sqsErr and every
+// adapter's error when none accepts the payload
+func (r *routing) adaptWebhook(body string, sqsErr error) (*alert.Alert, error) {
+	failures := []string{fmt.Sprintf("not an SQS message (%v)", sqsErr)}
+	for _, name := range adapter.ConfigurableWebhooks() {
+		input, _ := adapter.LookupInput(name)
+		alertMsg, err := input.Webhook(body, r.config.SlackChannels, r.config.AlarmChannels, nil, r.config)
+		if err == nil {
+			return alertMsg, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s webhook (%v)", name, err))
+	}
+	return nil, fmt.Errorf("%s", strings.Join(failures, ", or "))
+}
+
+// summarize describes a plan on one line, listing targets in a stable order
+func summarize(plan dispatch.Plan) string {
+	if plan.Dropped {
+		if plan.DropRule != "" {
+			return fmt.Sprintf("dropped by rule %s", plan.DropRule)
+		}
+		return fmt.Sprintf("held back in %s: %s", plan.Channel, plan.Reason)
+	}
+
+	summary := fmt.Sprintf("%s %s", plan.Severity, plan.Channel)
+	var targets []string
+	for _, target := range plan.Targets {
+		destination := target.Channel
+		if destination == "" {
+			destination = target.URL
+		}
+		if destination == "" && len(target.To) > 0 {
+			destination = strings.Join(target.To, ",")
+		}
+		if destination != "" {
+			targets = append(targets, target.Type+":"+destination)
+		} else {
+			targets = append(targets, target.Type)
+		}
+	}
+	if len(targets) > 0 {
+		sort.Strings(targets)
+		summary += " + " + strings.Join(targets, ", ")
+	}
+	return summary
+}
+
+// corpusFiles lists the payload files under dir, skipping hidden files
+func corpusFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != dir {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list corpus %s: %v", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
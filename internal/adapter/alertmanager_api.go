@@ -0,0 +1,53 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// AdaptPostedAlerts maps the alerts posted to the Alertmanager API, as Prometheus and amtool
+// send them, to routed alerts. Each alert is routed on its own, like a group of one, and is
+// resolved once its endsAt has passed.
+func AdaptPostedAlerts(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) ([]*alert.Alert, error) {
+	var posted []alertmanagerAlert
+	if err := json.Unmarshal([]byte(body), &posted); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceAlertmanager, Err: err}
+	}
+
+	now := time.Now()
+	adapted := make([]*alert.Alert, 0, len(posted))
+	for i, postedAlert := range posted {
+		alertname := postedAlert.Labels["alertname"]
+		if alertname == "" {
+			return nil, &errs.ParseError{Source: alert.SourceAlertmanager, Err: fmt.Errorf("alert %d has no alertname label", i)}
+		}
+
+		status := "firing"
+		if endsAt := parseTime(time.RFC3339, postedAlert.EndsAt); endsAt != nil && !endsAt.After(now) {
+			status = "resolved"
+		}
+		webhook := alertmanagerWebhook{
+			CommonLabels: postedAlert.Labels,
+			Status:       status,
+			First:        postedAlert,
+			AlertCount:   1,
+			noDataAlert:  isNoDataText(alertname) || isNoDataText(postedAlert.Annotations["description"]),
+		}
+
+		alertMsg, err := adaptAlertmanagerWebhook(webhook, channels, alarmChannels, fields)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(postedAlert)
+		if err != nil {
+			return nil, &errs.ParseError{Source: alert.SourceAlertmanager, Err: err}
+		}
+		alertMsg.Raw = string(raw)
+		adapted = append(adapted, alertMsg)
+	}
+	return adapted, nil
+}
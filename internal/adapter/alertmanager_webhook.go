@@ -0,0 +1,151 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// alertmanagerWebhook is an Alertmanager-format webhook, as sent by Grafana unified alerting.
+// Messages are rendered from the first alert of the group, so only that one is kept.
+type alertmanagerWebhook struct {
+	CommonLabels map[string]string
+	Status       string
+	Title        string
+	Message      string
+	ExternalURL  string // Alertmanager's own URL, or Grafana's
+	First        alertmanagerAlert
+	AlertCount   int
+
+	noDataAlert bool // some alert of the group has NoData in its name or description
+}
+
+type alertmanagerAlert struct {
+	Labels       map[string]string   `json:"labels"`
+	Annotations  map[string]string   `json:"annotations"`
+	StartsAt     string              `json:"startsAt"`
+	EndsAt       string              `json:"endsAt"`
+	GeneratorURL string              `json:"generatorURL"`
+	SilenceURL   string              `json:"silenceURL"`
+	DashboardURL string              `json:"dashboardURL"`
+	PanelURL     string              `json:"panelURL"`
+	ImageURL     string              `json:"imageURL"`
+	ValueString  string              `json:"valueString"`
+	Values       map[string]*float64 `json:"values"`
+}
+
+// alertmanagerAlertCheck holds the fields of the rest of the group that are checked for NoData.
+// It has no maps, so decoding into it allocates nothing per alert.
+type alertmanagerAlertCheck struct {
+	Labels struct {
+		Alertname string `json:"alertname"`
+	} `json:"labels"`
+	Annotations struct {
+		Description string `json:"description"`
+	} `json:"annotations"`
+}
+
+// decodeAlertmanagerWebhook decodes a webhook in a single pass over body. Groups can carry
+// hundreds of alerts during a storm; after the first, each is decoded into a reused struct and
+// discarded rather than unmarshaled into generic maps.
+func decodeAlertmanagerWebhook(body string) (alertmanagerWebhook, error) {
+	var webhook alertmanagerWebhook
+	dec := json.NewDecoder(strings.NewReader(body))
+	if err := expectDelim(dec, '{'); err != nil {
+		return webhook, err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return webhook, err
+		}
+
+		switch key, _ := token.(string); key {
+		case "alerts":
+			err = webhook.decodeAlerts(dec)
+		case "commonLabels":
+			err = dec.Decode(&webhook.CommonLabels)
+		case "status":
+			err = dec.Decode(&webhook.Status)
+		case "title":
+			err = dec.Decode(&webhook.Title)
+		case "message":
+			err = dec.Decode(&webhook.Message)
+		case "externalURL":
+			err = dec.Decode(&webhook.ExternalURL)
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return webhook, fmt.Errorf("failed to decode %v: %v", token, err)
+		}
+	}
+	return webhook, nil
+}
+
+func (w *alertmanagerWebhook) decodeAlerts(dec *json.Decoder) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected an array, got %v", token)
+	}
+
+	var check alertmanagerAlertCheck
+	for dec.More() {
+		if w.AlertCount == 0 {
+			if err := dec.Decode(&w.First); err != nil {
+				return err
+			}
+			w.noDataAlert = isNoDataText(w.First.Labels["alertname"]) || isNoDataText(w.First.Annotations["description"])
+		} else {
+			check = alertmanagerAlertCheck{}
+			if err := dec.Decode(&check); err != nil {
+				return err
+			}
+			if !w.noDataAlert {
+				w.noDataAlert = isNoDataText(check.Labels.Alertname) || isNoDataText(check.Annotations.Description)
+			}
+		}
+		w.AlertCount++
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %v, got %v", want, token)
+	}
+	return nil
+}
+
+// skipValue discards the next value, walking its tokens so nothing is kept
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// ErrArgoCDEventIgnored is wrapped by the parse errors of applications that are neither in
+// trouble nor synced and healthy, such as ones progressing through a sync, so the webhook can
+// accept them
+var ErrArgoCDEventIgnored = errors.New("only degraded, missing, out-of-sync, failed and recovered applications are mapped")
+
+// ArgoCDNotification is the body the ArgoCD notifications webhook template in the README sends
+type ArgoCDNotification struct {
+	App            string `json:"app"`
+	Project        string `json:"project"`
+	SyncStatus     string `json:"sync_status"`     // Synced, OutOfSync, Unknown
+	HealthStatus   string `json:"health_status"`   // Healthy, Progressing, Degraded, Suspended, Missing, Unknown
+	OperationPhase string `json:"operation_phase"` // Running, Succeeded, Failed, Error, Terminating; empty without an operation
+	Message        string `json:"message"`         // the last operation's message
+	Revision       string `json:"revision"`
+	RepoURL        string `json:"repo_url"`
+	Namespace      string `json:"namespace"` // the destination namespace
+	Cluster        string `json:"cluster"`   // the destination server or name
+	URL            string `json:"url"`       // the application in the ArgoCD UI
+}
+
+// argocdStatus maps an application to a status and state: failed syncs, and degraded, missing
+// and out-of-sync applications fire, and synced healthy ones resolve them. It reports false
+// for anything else.
+func argocdStatus(n ArgoCDNotification) (string, string, bool) {
+	switch {
+	case n.OperationPhase == "Failed" || n.OperationPhase == "Error":
+		return alert.StatusFiring, "SyncFailed", true
+	case n.HealthStatus == "Degraded" || n.HealthStatus == "Missing":
+		return alert.StatusFiring, n.HealthStatus, true
+	case n.SyncStatus == "OutOfSync" && n.OperationPhase != "Running":
+		return alert.StatusFiring, n.SyncStatus, true
+	case n.SyncStatus == "Synced" && n.HealthStatus == "Healthy" && n.OperationPhase != "Running":
+		return alert.StatusResolved, "Healthy", true
+	}
+	return "", n.HealthStatus, false
+}
+
+// AdaptArgoCDNotification maps an ArgoCD application that failed to sync, is degraded or
+// missing, or has drifted out of sync, to an alert named after the application, which the
+// application being synced and healthy again resolves. Drift is P2, the rest P1. Alerts go to
+// their alarm mapping, then their application's channel, then the channel of their priority.
+func AdaptArgoCDNotification(body string, channels, alarmChannels, appChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var n ArgoCDNotification
+	if err := json.Unmarshal([]byte(body), &n); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceArgoCD, Err: err}
+	}
+	if n.App == "" {
+		return nil, &errs.ParseError{Source: alert.SourceArgoCD, Err: fmt.Errorf("notification has no app")}
+	}
+	status, state, ok := argocdStatus(n)
+	if !ok {
+		return nil, &errs.ParseError{Source: alert.SourceArgoCD, Err: fmt.Errorf("%s is %s and %s: %w", n.App, n.SyncStatus, n.HealthStatus, ErrArgoCDEventIgnored)}
+	}
+
+	priority := "P1"
+	if state == "OutOfSync" {
+		priority = "P2"
+	}
+
+	channel := alarmChannels[n.App]
+	if channel == "" {
+		channel = appChannels[n.App]
+	}
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	labels := map[string]string{"app": n.App}
+	if n.Project != "" {
+		labels["project"] = n.Project
+	}
+	if n.Namespace != "" {
+		labels["namespace"] = n.Namespace
+	}
+	annotations := make(map[string]string)
+	if n.Message != "" {
+		annotations["description"] = n.Message
+	}
+	urls := make(map[string]string)
+	if n.URL != "" {
+		urls[alert.URLSource] = n.URL
+	}
+
+	now := time.Now()
+	adapted := &alert.Alert{
+		Source:      alert.SourceArgoCD,
+		Name:        n.App,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  now,
+		Raw:         body,
+		Extensions: map[string]interface{}{
+			"sync_status":   n.SyncStatus,
+			"health_status": n.HealthStatus,
+			"revision":      n.Revision,
+		},
+		Message: formatArgoCDSlackMessage(n, status, state, fieldShower(fields, channel)),
+		Summary: formatCompactArgoCDMessage(n, status, state),
+	}
+	if status == alert.StatusResolved {
+		adapted.EndsAt = &now
+	} else {
+		adapted.StartsAt = &now
+	}
+	return adapted, nil
+}
+
+// argocdOutcome describes the application's state, e.g. "is degraded"
+func argocdOutcome(status, state string) string {
+	switch {
+	case status == alert.StatusResolved:
+		return "is synced and healthy"
+	case state == "SyncFailed":
+		return "failed to sync"
+	case state == "OutOfSync":
+		return "is out of sync"
+	case state == "Missing":
+		return "is missing resources"
+	}
+	return "is degraded"
+}
+
+func formatArgoCDSlackMessage(n ArgoCDNotification, status, state string, show func(string) bool) string {
+	emoji := "🚨"
+	switch {
+	case status == alert.StatusResolved:
+		emoji = "✅"
+	case state == "OutOfSync":
+		emoji = "⚠️"
+	}
+	message := fmt.Sprintf("%s *ArgoCD app %s %s*\n• *Sync:* `%s`\n• *Health:* `%s`", emoji, n.App, argocdOutcome(status, state), n.SyncStatus, n.HealthStatus)
+	if n.Revision != "" {
+		message += fmt.Sprintf("\n• *Revision:* `%s`", shortSHA(n.Revision))
+	}
+	if n.Message != "" {
+		message += fmt.Sprintf("\n• *Message:* %s", n.Message)
+	}
+	if n.Namespace != "" {
+		destination := n.Namespace
+		if n.Cluster != "" && show("cluster") {
+			destination = n.Cluster + " / " + n.Namespace
+		}
+		message += fmt.Sprintf("\n• *Destination:* `%s`", destination)
+	}
+	if n.Project != "" && show("project") {
+		message += fmt.Sprintf("\n• *Project:* `%s`", n.Project)
+	}
+	if n.RepoURL != "" && show("repo_url") {
+		message += fmt.Sprintf("\n• *Repository:* %s", n.RepoURL)
+	}
+	if n.URL != "" {
+		message += fmt.Sprintf("\n• *Details:* <%s|View in ArgoCD>", n.URL)
+	}
+	return message
+}
+
+// formatCompactArgoCDMessage renders an application's state as a single line
+func formatCompactArgoCDMessage(n ArgoCDNotification, status, state string) string {
+	emoji := "🚨"
+	if status == alert.StatusResolved {
+		emoji = "✅"
+	}
+	line := fmt.Sprintf("%s *%s* %s", emoji, n.App, argocdOutcome(status, state))
+	if n.URL != "" {
+		line += fmt.Sprintf(" <%s|View>", n.URL)
+	}
+	return line
+}
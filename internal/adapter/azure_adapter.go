@@ -0,0 +1,316 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// azureCommonAlertSchemaID identifies Azure Monitor alerts using the common alert schema
+const azureCommonAlertSchemaID = "azureMonitorCommonAlertSchema"
+
+// AzureAlert is an Azure Monitor action group webhook using the common alert schema
+type AzureAlert struct {
+	SchemaID string `json:"schemaId"`
+	Data     struct {
+		Essentials       AzureEssentials   `json:"essentials"`
+		AlertContext     AzureAlertContext `json:"alertContext"`
+		CustomProperties map[string]string `json:"customProperties"`
+	} `json:"data"`
+}
+
+// AzureEssentials are the fields every Azure Monitor alert has, whatever its signal
+type AzureEssentials struct {
+	AlertID             string   `json:"alertId"`
+	AlertRule           string   `json:"alertRule"`
+	Severity            string   `json:"severity"`         // Sev0 to Sev4
+	SignalType          string   `json:"signalType"`       // Metric, Log or Activity Log
+	MonitorCondition    string   `json:"monitorCondition"` // Fired or Resolved
+	MonitoringService   string   `json:"monitoringService"`
+	AlertTargetIDs      []string `json:"alertTargetIDs"`
+	ConfigurationItems  []string `json:"configurationItems"`
+	TargetResourceGroup string   `json:"targetResourceGroup"`
+	TargetResourceType  string   `json:"targetResourceType"`
+	FiredDateTime       string   `json:"firedDateTime"`
+	ResolvedDateTime    string   `json:"resolvedDateTime"`
+	Description         string   `json:"description"`
+	InvestigationLink   string   `json:"investigationLink"`
+}
+
+// AzureAlertContext holds the condition of metric and log search alerts; other signals have
+// their own shapes, of which only the operation of activity log alerts is used
+type AzureAlertContext struct {
+	Condition struct {
+		WindowSize string           `json:"windowSize"` // ISO 8601 duration, e.g. PT5M
+		AllOf      []AzureCondition `json:"allOf"`
+	} `json:"condition"`
+	OperationName string `json:"operationName"`
+	Caller        string `json:"caller"`
+}
+
+// AzureCondition is one criterion of a metric or log search alert rule
+type AzureCondition struct {
+	MetricName            string           `json:"metricName"`
+	MetricNamespace       string           `json:"metricNamespace"`
+	SearchQuery           string           `json:"searchQuery"`
+	Operator              string           `json:"operator"`
+	Threshold             json.RawMessage  `json:"threshold"` // a string or a number
+	TimeAggregation       string           `json:"timeAggregation"`
+	MetricValue           json.RawMessage  `json:"metricValue"`
+	Dimensions            []AzureDimension `json:"dimensions"`
+	LinkToSearchResultsUI string           `json:"linkToSearchResultsUI"`
+}
+
+type AzureDimension struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// isAzureCommonAlert reports whether body is an Azure Monitor common alert schema payload
+func isAzureCommonAlert(body string) bool {
+	var probe struct {
+		SchemaID string `json:"schemaId"`
+	}
+	return json.Unmarshal([]byte(body), &probe) == nil && probe.SchemaID == azureCommonAlertSchemaID
+}
+
+// AdaptAzureAlert maps an Azure Monitor common alert schema payload to a routed alert
+func AdaptAzureAlert(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var azAlert AzureAlert
+	if err := json.Unmarshal([]byte(body), &azAlert); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceAzure, Err: err}
+	}
+	essentials := azAlert.Data.Essentials
+	if essentials.AlertRule == "" {
+		return nil, &errs.ParseError{Source: alert.SourceAzure, Err: fmt.Errorf("no alertRule in essentials")}
+	}
+
+	labels := azureLabels(azAlert)
+	priority := determineAzurePriority(essentials.Severity, labels)
+
+	// First check if there's a specific mapping for this alert rule
+	channel := alarmChannels[essentials.AlertRule]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := strings.ToUpper(essentials.MonitorCondition)
+	status := alert.StatusFiring
+	if state == "RESOLVED" {
+		status = alert.StatusResolved
+	}
+
+	annotations := make(map[string]string)
+	if essentials.Description != "" {
+		annotations["description"] = essentials.Description
+	}
+	if conditions := azAlert.Data.AlertContext.Condition.AllOf; len(conditions) > 0 {
+		if value := rawValue(conditions[0].MetricValue); value != "" && value != "null" {
+			annotations[alert.AnnotationValue] = value
+		}
+	}
+	urls := make(map[string]string)
+	if link := azureAlertLink(essentials); link != "" {
+		urls[alert.URLSource] = link
+	}
+	for _, condition := range azAlert.Data.AlertContext.Condition.AllOf {
+		if condition.LinkToSearchResultsUI != "" {
+			urls[alert.URLDashboard] = condition.LinkToSearchResultsUI
+			break
+		}
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceAzure,
+		Name:        essentials.AlertRule,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"alert_id":           essentials.AlertID,
+			"azure_severity":     essentials.Severity,
+			"alert_target_ids":   essentials.AlertTargetIDs,
+			"monitoring_service": essentials.MonitoringService,
+		},
+		Message: formatAzureSlackMessage(azAlert, state, fieldShower(fields, channel)),
+		Summary: formatCompactAzureMessage(azAlert, state),
+		Raw:     body,
+	}
+	if essentials.FiredDateTime != "" {
+		adapted.StartsAt = parseTime(time.RFC3339Nano, essentials.FiredDateTime)
+	}
+	if essentials.ResolvedDateTime != "" {
+		adapted.EndsAt = parseTime(time.RFC3339Nano, essentials.ResolvedDateTime)
+	}
+	return adapted, nil
+}
+
+// azureLabels holds the target resources, signal, the dimensions of the conditions and the
+// rule's custom properties
+func azureLabels(azAlert AzureAlert) map[string]string {
+	essentials := azAlert.Data.Essentials
+	labels := make(map[string]string)
+	for _, condition := range azAlert.Data.AlertContext.Condition.AllOf {
+		for _, dim := range condition.Dimensions {
+			labels[dim.Name] = dim.Value
+		}
+	}
+	for k, v := range azAlert.Data.CustomProperties {
+		labels[k] = v
+	}
+	for key, value := range map[string]string{
+		"resource":           strings.Join(essentials.ConfigurationItems, ", "),
+		"resource_group":     essentials.TargetResourceGroup,
+		"resource_type":      essentials.TargetResourceType,
+		"signal_type":        essentials.SignalType,
+		"monitoring_service": essentials.MonitoringService,
+	} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// determineAzurePriority maps Azure severities onto ours: Sev0 is P0, Sev1 P1 and Sev2 to Sev4
+// P2. A channel custom property of P0-P2 wins.
+func determineAzurePriority(severity string, labels map[string]string) string {
+	switch strings.ToUpper(labels["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(labels["channel"])
+	}
+
+	switch strings.ToLower(severity) {
+	case "sev0":
+		return "P0"
+	case "sev1":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+// azureAlertLink opens the alert in the portal, preferring its investigation page
+func azureAlertLink(essentials AzureEssentials) string {
+	if essentials.InvestigationLink != "" {
+		return essentials.InvestigationLink
+	}
+	if essentials.AlertID != "" {
+		return "https://portal.azure.com/#blade/Microsoft_Azure_Monitoring/AlertDetailsTemplateBlade/alertId/" +
+			strings.ReplaceAll(essentials.AlertID, "/", "%2F")
+	}
+	return ""
+}
+
+// rawValue renders a JSON string or number without quotes
+func rawValue(raw json.RawMessage) string {
+	return strings.Trim(string(raw), `"`)
+}
+
+// formatAzureCondition renders one criterion, e.g. "`Percentage CPU` Average GreaterThan 80, now 93.2"
+func formatAzureCondition(condition AzureCondition) string {
+	subject := condition.MetricName
+	if subject == "" && condition.SearchQuery != "" {
+		subject = strings.Join(strings.Fields(condition.SearchQuery), " ")
+		if len(subject) > 120 {
+			subject = subject[:117] + "..."
+		}
+	}
+	line := fmt.Sprintf("`%s`", subject)
+	if condition.TimeAggregation != "" {
+		line += " " + condition.TimeAggregation
+	}
+	if condition.Operator != "" {
+		line += fmt.Sprintf(" %s %s", condition.Operator, rawValue(condition.Threshold))
+	}
+	if value := rawValue(condition.MetricValue); value != "" && value != "null" {
+		line += fmt.Sprintf(", now *%s*", value)
+	}
+	return line
+}
+
+func formatAzureSlackMessage(azAlert AzureAlert, state string, show func(string) bool) string {
+	essentials := azAlert.Data.Essentials
+	alertContext := azAlert.Data.AlertContext
+	message := fmt.Sprintf("%s *Azure Monitor Alert: %s*\n• *State:* `%s`", stateEmoji(state), essentials.AlertRule, state)
+
+	if essentials.Severity != "" {
+		message += fmt.Sprintf("\n• *Severity:* %s", essentials.Severity)
+	}
+	if len(essentials.ConfigurationItems) > 0 {
+		message += fmt.Sprintf("\n• *Resource:* `%s`", strings.Join(essentials.ConfigurationItems, "`, `"))
+	}
+	if essentials.TargetResourceGroup != "" {
+		message += fmt.Sprintf("\n• *Resource group:* `%s`", essentials.TargetResourceGroup)
+	}
+
+	conditions := alertContext.Condition.AllOf
+	if len(conditions) == 1 {
+		message += "\n• *Condition:* " + formatAzureCondition(conditions[0])
+	} else if len(conditions) > 1 {
+		message += "\n• *Conditions:*"
+		for _, condition := range conditions {
+			message += "\n   → " + formatAzureCondition(condition)
+		}
+	}
+	if alertContext.Condition.WindowSize != "" {
+		message += fmt.Sprintf("\n• *Window:* `%s`", alertContext.Condition.WindowSize)
+	}
+	if alertContext.OperationName != "" {
+		message += fmt.Sprintf("\n• *Operation:* `%s`", alertContext.OperationName)
+		if alertContext.Caller != "" {
+			message += fmt.Sprintf(" by %s", alertContext.Caller)
+		}
+	}
+	if essentials.Description != "" {
+		message += fmt.Sprintf("\n• *Description:* %s", essentials.Description)
+	}
+
+	var dimensions []string
+	for _, condition := range conditions {
+		for _, dim := range condition.Dimensions {
+			if show(dim.Name) {
+				dimensions = append(dimensions, fmt.Sprintf("\n   → `%s`: %s", dim.Name, dim.Value))
+			}
+		}
+	}
+	sort.Strings(dimensions)
+	if len(dimensions) > 0 {
+		message += "\n• *Dimensions:*" + strings.Join(dimensions, "")
+	}
+
+	if link := azureAlertLink(essentials); link != "" {
+		message += fmt.Sprintf("\n• *Alert:* <%s|View in Azure Portal>", link)
+	}
+	return message
+}
+
+// formatCompactAzureMessage renders an Azure Monitor alert as a single line
+func formatCompactAzureMessage(azAlert AzureAlert, state string) string {
+	essentials := azAlert.Data.Essentials
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), essentials.AlertRule, state)
+	if conditions := azAlert.Data.AlertContext.Condition.AllOf; len(conditions) > 0 && conditions[0].MetricName != "" {
+		if value := rawValue(conditions[0].MetricValue); value != "" && value != "null" {
+			line += fmt.Sprintf(" %s = *%s*", conditions[0].MetricName, value)
+		}
+	}
+	if link := azureAlertLink(essentials); link != "" {
+		line += fmt.Sprintf(" <%s|View>", link)
+	}
+	return line
+}
@@ -0,0 +1,200 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// backupJobStateChange is the detail-type of the AWS Backup events the adapter maps
+const backupJobStateChange = "Backup Job State Change"
+
+// BackupRoutes sends AWS Backup job alerts to one channel, such as the infra channel.
+// *config.Config implements it from its backup_channel; without one, or when the filter passed
+// to the adapter doesn't implement it, they are routed by priority.
+type BackupRoutes interface {
+	AWSBackupChannel() string
+}
+
+// BackupEvent is an event EventBridge delivers from AWS Backup
+type BackupEvent struct {
+	DetailType string    `json:"detail-type"`
+	Source     string    `json:"source"`
+	Account    string    `json:"account"`
+	Region     string    `json:"region"`
+	Detail     BackupJob `json:"detail"`
+}
+
+// BackupJob is the detail of a "Backup Job State Change" event
+type BackupJob struct {
+	BackupJobID    string `json:"backupJobId"`
+	BackupVaultArn string `json:"backupVaultArn"`
+	ResourceArn    string `json:"resourceArn"`
+	ResourceType   string `json:"resourceType"` // EC2, EBS, RDS, DynamoDB, EFS, S3, ...
+	State          string `json:"state"`        // CREATED, RUNNING, COMPLETED, FAILED, EXPIRED, ABORTED, PARTIAL, ...
+	StatusMessage  string `json:"statusMessage"`
+	CreationDate   string `json:"creationDate"`
+	CompletionDate string `json:"completionDate"`
+	IAMRoleArn     string `json:"iamRoleArn"`
+	CreatedBy      struct {
+		BackupPlanID string `json:"backupPlanId"`
+		BackupRuleID string `json:"backupRuleId"`
+	} `json:"createdBy"`
+}
+
+// parseBackupEvent reads an EventBridge AWS Backup event from an SQS body, directly or wrapped
+// in SNS, reporting false for anything else
+func parseBackupEvent(body string) (BackupEvent, bool) {
+	var event BackupEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return BackupEvent{}, false
+	}
+	if event.Source == "aws.backup" {
+		return event, true
+	}
+
+	var envelope struct {
+		Message string `json:"Message"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || !strings.HasPrefix(strings.TrimSpace(envelope.Message), "{") {
+		return BackupEvent{}, false
+	}
+	return parseBackupEvent(envelope.Message)
+}
+
+// backupJobStatus maps a finished job's state to a status: jobs that didn't back the resource
+// up fire, and a completed job resolves them. Jobs still in progress aren't mapped.
+func backupJobStatus(state string) (string, bool) {
+	switch state {
+	case "FAILED", "EXPIRED", "ABORTED", "PARTIAL":
+		return alert.StatusFiring, true
+	case "COMPLETED":
+		return alert.StatusResolved, true
+	}
+	return "", false
+}
+
+// backupResourceName is the name at the end of a resource ARN, e.g. vol-0abc for
+// arn:aws:ec2:eu-west-1:123456789012:volume/vol-0abc and orders for
+// arn:aws:rds:eu-west-1:123456789012:db:orders
+func backupResourceName(arn string) string {
+	if i := strings.LastIndex(arn, "/"); i >= 0 {
+		return arn[i+1:]
+	}
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+// adaptBackupEvent maps a backup job state change to an alert named after the resource backed
+// up, so its next completed backup resolves a failed one. Alerts go to the backup channel, else
+// by alarm mapping, then the P1 channel.
+func adaptBackupEvent(event BackupEvent, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	if event.DetailType != backupJobStateChange {
+		return nil, &errs.ParseError{Source: alert.SourceAWSBackup, Err: fmt.Errorf("unsupported AWS Backup event %q", event.DetailType)}
+	}
+	job := event.Detail
+	status, ok := backupJobStatus(job.State)
+	if !ok {
+		return nil, &errs.ParseError{Source: alert.SourceAWSBackup, Err: fmt.Errorf("backup job %s is %s, only finished jobs are mapped", job.BackupJobID, job.State)}
+	}
+
+	name := backupResourceName(job.ResourceArn)
+	vault := backupResourceName(job.BackupVaultArn)
+	priority := "P1"
+	channel := alarmChannels[name]
+	if routes, ok := fields.(BackupRoutes); ok && channel == "" {
+		channel = routes.AWSBackupChannel()
+	}
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	labels := map[string]string{"vault": vault, "resource_type": job.ResourceType}
+	if event.Account != "" {
+		labels["account"] = event.Account
+	}
+	if event.Region != "" {
+		labels["region"] = event.Region
+	}
+	annotations := make(map[string]string)
+	if job.StatusMessage != "" {
+		annotations["description"] = job.StatusMessage
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceAWSBackup,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       job.State,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: backupJobURL(event.Region, job.BackupJobID)},
+		ReceivedAt:  time.Now(),
+		Raw:         body,
+		Extensions: map[string]interface{}{
+			"backup_job_id":  job.BackupJobID,
+			"resource_arn":   job.ResourceArn,
+			"backup_plan_id": job.CreatedBy.BackupPlanID,
+		},
+		Message: formatBackupSlackMessage(event, name, vault, status, fieldShower(fields, channel)),
+		Summary: formatCompactBackupMessage(job, name, vault, status),
+	}
+	adapted.StartsAt = parseTime(time.RFC3339, job.CreationDate)
+	if status == alert.StatusResolved {
+		adapted.EndsAt = parseTime(time.RFC3339, job.CompletionDate)
+	}
+	return adapted, nil
+}
+
+func backupJobURL(region, jobID string) string {
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/backup/home?region=%s#/jobs/backup/details/%s", region, region, jobID)
+}
+
+func formatBackupSlackMessage(event BackupEvent, name, vault, status string, show func(string) bool) string {
+	job := event.Detail
+	emoji, heading := "🚨", "AWS Backup job "+strings.ToLower(job.State)
+	if status == alert.StatusResolved {
+		emoji = "✅"
+	}
+	message := fmt.Sprintf("%s *%s: %s*\n• *Vault:* `%s`\n• *Resource:* `%s`", emoji, heading, name, vault, job.ResourceArn)
+	if job.ResourceType != "" {
+		message += fmt.Sprintf(" (%s)", job.ResourceType)
+	}
+	if job.StatusMessage != "" {
+		message += fmt.Sprintf("\n• *Message:* %s", job.StatusMessage)
+	}
+	if job.CreatedBy.BackupPlanID != "" && show("backup_plan") {
+		message += fmt.Sprintf("\n• *Backup plan:* `%s`", job.CreatedBy.BackupPlanID)
+	}
+	if event.Account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", event.Account)
+	}
+	if event.Region != "" && show("region") {
+		message += fmt.Sprintf("\n• *Region:* `%s`", event.Region)
+	}
+	if at := parseTime(time.RFC3339, job.CreationDate); at != nil {
+		message += fmt.Sprintf("\n• *Started:* %s", at.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	return message + fmt.Sprintf("\n• *Job:* <%s|%s>", backupJobURL(event.Region, job.BackupJobID), job.BackupJobID)
+}
+
+// formatCompactBackupMessage renders a backup job state change as a single line
+func formatCompactBackupMessage(job BackupJob, name, vault, status string) string {
+	emoji := "🚨"
+	if status == alert.StatusResolved {
+		emoji = "✅"
+	}
+	line := fmt.Sprintf("%s *%s* backup `%s` in %s", emoji, name, job.State, vault)
+	if job.StatusMessage != "" && status == alert.StatusFiring {
+		line += ": " + job.StatusMessage
+	}
+	return line
+}
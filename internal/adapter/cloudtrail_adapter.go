@@ -0,0 +1,323 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// States of CloudTrail alerts: whether the call or sign-in the event records succeeded
+const (
+	cloudTrailStateSuccess = "SUCCESS"
+	cloudTrailStateFailure = "FAILURE"
+)
+
+// CloudTrailRules assigns CloudTrail events their priority and, optionally, channel.
+// *config.Config implements it from its cloudtrail_rules; events no rule matches, or all events
+// when the filter passed to the adapter doesn't implement it, get the built-in priorities.
+type CloudTrailRules interface {
+	CloudTrailRoute(eventName, identityType string) (priority, channel string, ok bool)
+}
+
+// defaultCloudTrailRules are the built-in priorities, after any configured rule: anything the
+// root user does pages, IAM policy and security group changes need a look soon, and the rest
+// are informational
+var defaultCloudTrailRules = []struct {
+	eventName string
+	priority  string
+}{
+	{"Put*Policy", "P1"},
+	{"Attach*Policy", "P1"},
+	{"Detach*Policy", "P1"},
+	{"Delete*Policy", "P1"},
+	{"CreatePolicy*", "P1"},
+	{"SetDefaultPolicyVersion", "P1"},
+	{"*SecurityGroup*", "P1"},
+}
+
+// CloudTrailEvent is a CloudTrail record as an EventBridge rule delivers it, with detail-type
+// "AWS API Call via CloudTrail" or "AWS Console Sign In via CloudTrail"
+type CloudTrailEvent struct {
+	DetailType string `json:"detail-type"`
+	Source     string `json:"source"`
+	Account    string `json:"account"`
+	Region     string `json:"region"`
+	Detail     struct {
+		EventID             string                 `json:"eventID"`
+		EventName           string                 `json:"eventName"`
+		EventSource         string                 `json:"eventSource"`
+		EventTime           string                 `json:"eventTime"`
+		AWSRegion           string                 `json:"awsRegion"`
+		SourceIPAddress     string                 `json:"sourceIPAddress"`
+		UserAgent           string                 `json:"userAgent"`
+		ErrorCode           string                 `json:"errorCode"`
+		ErrorMessage        string                 `json:"errorMessage"`
+		UserIdentity        CloudTrailIdentity     `json:"userIdentity"`
+		RequestParameters   map[string]interface{} `json:"requestParameters"`
+		ResponseElements    map[string]interface{} `json:"responseElements"`
+		AdditionalEventData map[string]interface{} `json:"additionalEventData"`
+	} `json:"detail"`
+}
+
+// CloudTrailIdentity is who made the call
+type CloudTrailIdentity struct {
+	Type           string `json:"type"` // Root, IAMUser, AssumedRole, FederatedUser, AWSService, ...
+	PrincipalID    string `json:"principalId"`
+	ARN            string `json:"arn"`
+	AccountID      string `json:"accountId"`
+	AccessKeyID    string `json:"accessKeyId"`
+	UserName       string `json:"userName"`
+	InvokedBy      string `json:"invokedBy"`
+	SessionContext struct {
+		SessionIssuer struct {
+			Type     string `json:"type"`
+			UserName string `json:"userName"`
+			ARN      string `json:"arn"`
+		} `json:"sessionIssuer"`
+		Attributes struct {
+			MFAAuthenticated string `json:"mfaAuthenticated"`
+		} `json:"attributes"`
+	} `json:"sessionContext"`
+}
+
+// actor names who made the call: the user, the role and session assumed, or the service
+func (i CloudTrailIdentity) actor() string {
+	switch {
+	case i.Type == "Root":
+		return "root"
+	case i.UserName != "":
+		return i.UserName
+	case i.SessionContext.SessionIssuer.UserName != "":
+		// An assumed role's principal ID ends in the session name
+		name := i.SessionContext.SessionIssuer.UserName
+		if _, session, ok := strings.Cut(i.PrincipalID, ":"); ok {
+			name += "/" + session
+		}
+		return name
+	case i.InvokedBy != "":
+		return i.InvokedBy
+	default:
+		return i.PrincipalID
+	}
+}
+
+// parseCloudTrailEvent reads an EventBridge CloudTrail event from an SQS body, directly or
+// wrapped in SNS, reporting false for anything else
+func parseCloudTrailEvent(body string) (CloudTrailEvent, bool) {
+	var event CloudTrailEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return CloudTrailEvent{}, false
+	}
+	if strings.HasSuffix(event.DetailType, "via CloudTrail") && event.Detail.EventName != "" {
+		return event, true
+	}
+
+	var envelope struct {
+		Message string `json:"Message"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || !strings.HasPrefix(strings.TrimSpace(envelope.Message), "{") {
+		return CloudTrailEvent{}, false
+	}
+	return parseCloudTrailEvent(envelope.Message)
+}
+
+// cloudTrailFailed reports whether the call was refused or the sign-in failed
+func cloudTrailFailed(event CloudTrailEvent) bool {
+	if event.Detail.ErrorCode != "" {
+		return true
+	}
+	result, _ := event.Detail.ResponseElements["ConsoleLogin"].(string)
+	return result == "Failure"
+}
+
+// cloudTrailPriority returns the priority and channel of the first configured rule matching
+// the event, or its built-in priority
+func cloudTrailPriority(event CloudTrailEvent, rules CloudTrailRules) (string, string) {
+	name, identityType := event.Detail.EventName, event.Detail.UserIdentity.Type
+	if rules != nil {
+		if priority, channel, ok := rules.CloudTrailRoute(name, identityType); ok {
+			return priority, channel
+		}
+	}
+	if identityType == "Root" {
+		return "P0", ""
+	}
+	for _, rule := range defaultCloudTrailRules {
+		if matched, _ := path.Match(rule.eventName, name); matched {
+			return rule.priority, ""
+		}
+	}
+	return "P2", ""
+}
+
+// adaptCloudTrailEvent maps a CloudTrail event to a routed alert: by its alarm mapping, then the
+// channel of the rule it matched, then its priority
+func adaptCloudTrailEvent(event CloudTrailEvent, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) *alert.Alert {
+	rules, _ := fields.(CloudTrailRules)
+	priority, channel := cloudTrailPriority(event, rules)
+	detail := event.Detail
+	if mapped := alarmChannels[detail.EventName]; mapped != "" {
+		channel = mapped
+	}
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	state := cloudTrailStateSuccess
+	if cloudTrailFailed(event) {
+		state = cloudTrailStateFailure
+	}
+	identity := detail.UserIdentity
+	account := identity.AccountID
+	if account == "" {
+		account = event.Account
+	}
+	region := detail.AWSRegion
+	if region == "" {
+		region = event.Region
+	}
+
+	// The event's ID is a label, so each call is an alert of its own
+	labels := map[string]string{
+		"event_id":      detail.EventID,
+		"event_source":  detail.EventSource,
+		"identity_type": identity.Type,
+		"actor":         identity.actor(),
+	}
+	if account != "" {
+		labels["account"] = account
+	}
+	if region != "" {
+		labels["region"] = region
+	}
+	annotations := make(map[string]string)
+	if detail.ErrorCode != "" {
+		annotations["description"] = detail.ErrorCode
+		if detail.ErrorMessage != "" {
+			annotations["description"] += ": " + detail.ErrorMessage
+		}
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceCloudTrail,
+		Name:        detail.EventName,
+		Severity:    priority,
+		Status:      alert.StatusFiring,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: cloudTrailConsoleURL(event, region)},
+		ReceivedAt:  time.Now(),
+		Raw:         body,
+		Extensions: map[string]interface{}{
+			"actor_arn":         identity.ARN,
+			"source_ip_address": detail.SourceIPAddress,
+			"user_agent":        detail.UserAgent,
+			"access_key_id":     identity.AccessKeyID,
+		},
+		Message: formatCloudTrailSlackMessage(event, state, account, region, fieldShower(fields, channel)),
+		Summary: formatCompactCloudTrailMessage(event, state),
+	}
+	adapted.StartsAt = parseTime(time.RFC3339, detail.EventTime)
+	return adapted
+}
+
+// cloudTrailConsoleURL links the event in the CloudTrail event history
+func cloudTrailConsoleURL(event CloudTrailEvent, region string) string {
+	if region == "" {
+		region = "us-east-1"
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/cloudtrail/home?region=%s#/events/%s", region, region, event.Detail.EventID)
+}
+
+// cloudTrailMFA reports whether the actor signed in with MFA, or "" when the event doesn't say
+func cloudTrailMFA(event CloudTrailEvent) string {
+	if used, _ := event.Detail.AdditionalEventData["MFAUsed"].(string); used != "" {
+		return used
+	}
+	switch event.Detail.UserIdentity.SessionContext.Attributes.MFAAuthenticated {
+	case "true":
+		return "Yes"
+	case "false":
+		return "No"
+	}
+	return ""
+}
+
+func formatCloudTrailSlackMessage(event CloudTrailEvent, state, account, region string, show func(string) bool) string {
+	detail := event.Detail
+	identity := detail.UserIdentity
+	heading := "AWS API call"
+	if detail.EventName == "ConsoleLogin" {
+		heading = "AWS console sign-in"
+	}
+	message := fmt.Sprintf("🔐 *%s: %s*\n• *Service:* `%s`", heading, detail.EventName, detail.EventSource)
+	if state == cloudTrailStateFailure {
+		outcome := "Failed"
+		if detail.ErrorCode != "" {
+			outcome += fmt.Sprintf(" (`%s`)", detail.ErrorCode)
+		}
+		message += fmt.Sprintf("\n• *Outcome:* *%s*", outcome)
+		if detail.ErrorMessage != "" {
+			message += fmt.Sprintf("\n• *Error:* %s", detail.ErrorMessage)
+		}
+	}
+
+	message += fmt.Sprintf("\n• *Actor:* *%s* (%s)", identity.actor(), identity.Type)
+	if identity.ARN != "" {
+		message += fmt.Sprintf("\n   → ARN: `%s`", identity.ARN)
+	}
+	if identity.AccessKeyID != "" && show("access_key_id") {
+		message += fmt.Sprintf("\n   → Access key: `%s`", identity.AccessKeyID)
+	}
+	if mfa := cloudTrailMFA(event); mfa != "" {
+		message += fmt.Sprintf("\n   → MFA: %s", mfa)
+	}
+	if detail.SourceIPAddress != "" {
+		message += fmt.Sprintf("\n   → From: `%s`", detail.SourceIPAddress)
+	}
+	if detail.UserAgent != "" && show("user_agent") {
+		message += fmt.Sprintf("\n   → User agent: `%s`", detail.UserAgent)
+	}
+
+	if len(detail.RequestParameters) > 0 && show("request_parameters") {
+		if params, err := json.Marshal(detail.RequestParameters); err == nil {
+			text := string(params)
+			if len(text) > 500 {
+				text = text[:500] + "…"
+			}
+			message += fmt.Sprintf("\n• *Request:* `%s`", text)
+		}
+	}
+	if account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", account)
+	}
+	if region != "" && show("region") {
+		message += fmt.Sprintf("\n• *Region:* `%s`", region)
+	}
+	if at := parseTime(time.RFC3339, detail.EventTime); at != nil {
+		message += fmt.Sprintf("\n• *Time:* %s", at.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	return message + fmt.Sprintf("\n• *Event:* <%s|View in CloudTrail>", cloudTrailConsoleURL(event, region))
+}
+
+// formatCompactCloudTrailMessage renders a CloudTrail event as a single line
+func formatCompactCloudTrailMessage(event CloudTrailEvent, state string) string {
+	detail := event.Detail
+	line := fmt.Sprintf("🔐 *%s* by %s", detail.EventName, detail.UserIdentity.actor())
+	if detail.SourceIPAddress != "" {
+		line += " from " + detail.SourceIPAddress
+	}
+	if state == cloudTrailStateFailure {
+		line += " `" + state + "`"
+	}
+	return line
+}
@@ -0,0 +1,323 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// Detail types of the CodePipeline and CodeBuild events the adapter maps
+const (
+	pipelineExecutionChange = "CodePipeline Pipeline Execution State Change"
+	stageExecutionChange    = "CodePipeline Stage Execution State Change"
+	actionExecutionChange   = "CodePipeline Action Execution State Change"
+	buildStateChange        = "CodeBuild Build State Change"
+)
+
+// PipelineRoutes sends the alerts of a pipeline or CodeBuild project to the team owning it.
+// *config.Config implements it from its pipeline_channels; pipelines without a channel, or all
+// of them when the filter passed to the adapter doesn't implement it, are routed by priority.
+type PipelineRoutes interface {
+	PipelineChannel(name string) string
+}
+
+// CodeStarNotification is a CodePipeline or CodeBuild event, as CodeStar Notifications delivers
+// it to SNS or EventBridge delivers it directly
+type CodeStarNotification struct {
+	Account              string             `json:"account"`
+	DetailType           string             `json:"detailType"`  // CodeStar Notifications
+	BridgedDetailType    string             `json:"detail-type"` // EventBridge
+	Region               string             `json:"region"`
+	Source               string             `json:"source"`
+	Time                 string             `json:"time"`
+	Detail               codeStarDetail     `json:"detail"`
+	AdditionalAttributes codeStarAttributes `json:"additionalAttributes"`
+}
+
+// codeStarDetail holds the detail fields of both CodePipeline and CodeBuild events
+type codeStarDetail struct {
+	Pipeline    string `json:"pipeline"`
+	ExecutionID string `json:"execution-id"`
+	Stage       string `json:"stage"`
+	Action      string `json:"action"`
+	State       string `json:"state"` // STARTED, SUCCEEDED, FAILED, CANCELED, SUPERSEDED, ...
+
+	ProjectName           string `json:"project-name"`
+	BuildID               string `json:"build-id"`     // the build's ARN
+	BuildStatus           string `json:"build-status"` // IN_PROGRESS, SUCCEEDED, FAILED, FAULT, STOPPED, TIMED_OUT
+	AdditionalInformation struct {
+		Initiator string `json:"initiator"` // e.g. codepipeline/orders-deploy
+		Logs      struct {
+			DeepLink string `json:"deep-link"`
+		} `json:"logs"`
+	} `json:"additional-information"`
+}
+
+// codeStarAttributes is the extra context CodeStar Notifications adds to pipeline failures
+type codeStarAttributes struct {
+	FailedStage   string `json:"failedStage"`
+	FailedActions []struct {
+		Action                string `json:"action"`
+		AdditionalInformation string `json:"additionalInformation"`
+	} `json:"failedActions"`
+}
+
+func (n CodeStarNotification) detailType() string {
+	if n.DetailType != "" {
+		return n.DetailType
+	}
+	return n.BridgedDetailType
+}
+
+// isBuild reports whether the notification is about a CodeBuild build rather than a pipeline
+func (n CodeStarNotification) isBuild() bool {
+	return n.Source == "aws.codebuild"
+}
+
+// name is the pipeline or CodeBuild project the notification is about
+func (n CodeStarNotification) name() string {
+	if n.isBuild() {
+		return n.Detail.ProjectName
+	}
+	return n.Detail.Pipeline
+}
+
+// parseCodeStarNotification reads a CodePipeline or CodeBuild event from an SQS body, directly
+// or wrapped in SNS, reporting false for anything else
+func parseCodeStarNotification(body string) (CodeStarNotification, bool) {
+	var notification CodeStarNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return CodeStarNotification{}, false
+	}
+	if (notification.Source == "aws.codepipeline" || notification.Source == "aws.codebuild") && notification.detailType() != "" {
+		return notification, true
+	}
+
+	var envelope struct {
+		Message string `json:"Message"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || !strings.HasPrefix(strings.TrimSpace(envelope.Message), "{") {
+		return CodeStarNotification{}, false
+	}
+	return parseCodeStarNotification(envelope.Message)
+}
+
+// codeStarStatus maps a finished execution or build to a status: failures fire, and success
+// resolves them. Executions in progress, stopped or superseded aren't mapped.
+func codeStarStatus(n CodeStarNotification) (string, string, bool) {
+	switch n.detailType() {
+	case pipelineExecutionChange, stageExecutionChange, actionExecutionChange:
+		switch n.Detail.State {
+		case "FAILED":
+			return alert.StatusFiring, n.Detail.State, true
+		case "SUCCEEDED":
+			return alert.StatusResolved, n.Detail.State, true
+		}
+		return "", n.Detail.State, false
+	case buildStateChange:
+		switch n.Detail.BuildStatus {
+		case "FAILED", "FAULT", "TIMED_OUT":
+			return alert.StatusFiring, n.Detail.BuildStatus, true
+		case "SUCCEEDED":
+			return alert.StatusResolved, n.Detail.BuildStatus, true
+		}
+		return "", n.Detail.BuildStatus, false
+	}
+	return "", "", false
+}
+
+// adaptCodeStarNotification maps a failed pipeline execution, stage or action, or a failed build,
+// to a P1 alert named after the pipeline or project, so its next success resolves it. Alerts go
+// to the alarm mapping, then the pipeline's channel, then the P1 channel.
+func adaptCodeStarNotification(n CodeStarNotification, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	status, state, ok := codeStarStatus(n)
+	if !ok {
+		if state == "" {
+			return nil, &errs.ParseError{Source: alert.SourceCodeStar, Err: fmt.Errorf("unsupported %s event %q", n.Source, n.detailType())}
+		}
+		return nil, &errs.ParseError{Source: alert.SourceCodeStar, Err: fmt.Errorf("%s is %s, only failures and successes are mapped", n.name(), state)}
+	}
+
+	name := n.name()
+	priority := "P1"
+	channel := alarmChannels[name]
+	if routes, ok := fields.(PipelineRoutes); ok && channel == "" {
+		channel = routes.PipelineChannel(name)
+	}
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	kind := "pipeline"
+	if n.isBuild() {
+		kind = "build"
+	}
+	labels := map[string]string{"kind": kind}
+	if n.Account != "" {
+		labels["account"] = n.Account
+	}
+	if n.Region != "" {
+		labels["region"] = n.Region
+	}
+	annotations := make(map[string]string)
+	if reason := codeStarFailure(n); reason != "" {
+		annotations["description"] = reason
+	}
+	extensions := map[string]interface{}{"execution_id": codeStarExecutionID(n)}
+	if stage := codeStarStage(n); stage != "" {
+		extensions["stage"] = stage
+	}
+	if n.Detail.Action != "" {
+		extensions["action"] = n.Detail.Action
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceCodeStar,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: codeStarConsoleURL(n)},
+		ReceivedAt:  time.Now(),
+		Raw:         body,
+		Extensions:  extensions,
+		Message:     formatCodeStarSlackMessage(n, status, state, fieldShower(fields, channel)),
+		Summary:     formatCompactCodeStarMessage(n, status, state),
+	}
+	if status == alert.StatusResolved {
+		adapted.EndsAt = parseTime(time.RFC3339, n.Time)
+	} else {
+		adapted.StartsAt = parseTime(time.RFC3339, n.Time)
+	}
+	return adapted, nil
+}
+
+// codeStarStage is the stage the event is about or, for a failed execution, the stage it
+// failed in
+func codeStarStage(n CodeStarNotification) string {
+	if n.Detail.Stage != "" {
+		return n.Detail.Stage
+	}
+	return n.AdditionalAttributes.FailedStage
+}
+
+// codeStarFailure joins the failed actions' messages CodeStar Notifications adds, such as a
+// deployment's error
+func codeStarFailure(n CodeStarNotification) string {
+	var reasons []string
+	for _, failed := range n.AdditionalAttributes.FailedActions {
+		if failed.AdditionalInformation != "" {
+			reasons = append(reasons, failed.Action+": "+failed.AdditionalInformation)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// codeStarExecutionID is the pipeline execution ID or the build ID, e.g. orders-api:1b2c... for
+// arn:aws:codebuild:eu-west-1:123456789012:build/orders-api:1b2c...
+func codeStarExecutionID(n CodeStarNotification) string {
+	if n.isBuild() {
+		if i := strings.Index(n.Detail.BuildID, ":build/"); i >= 0 {
+			return n.Detail.BuildID[i+len(":build/"):]
+		}
+		return n.Detail.BuildID
+	}
+	return n.Detail.ExecutionID
+}
+
+// codeStarConsoleURL links the pipeline execution or build in the console
+func codeStarConsoleURL(n CodeStarNotification) string {
+	region := n.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	console := fmt.Sprintf("https://%s.console.aws.amazon.com/codesuite", region)
+	if n.isBuild() {
+		return fmt.Sprintf("%s/codebuild/projects/%s/build/%s/?region=%s", console, url.PathEscape(n.Detail.ProjectName), url.PathEscape(codeStarExecutionID(n)), region)
+	}
+	if n.Detail.ExecutionID == "" {
+		return fmt.Sprintf("%s/codepipeline/pipelines/%s/view?region=%s", console, url.PathEscape(n.Detail.Pipeline), region)
+	}
+	return fmt.Sprintf("%s/codepipeline/pipelines/%s/executions/%s/timeline?region=%s", console, url.PathEscape(n.Detail.Pipeline), n.Detail.ExecutionID, region)
+}
+
+// codeStarHeading is e.g. "CodePipeline stage failed"
+func codeStarHeading(n CodeStarNotification, state string) string {
+	subject := "CodePipeline"
+	switch {
+	case n.isBuild():
+		subject = "CodeBuild build"
+	case n.detailType() == stageExecutionChange:
+		subject = "CodePipeline stage"
+	case n.detailType() == actionExecutionChange:
+		subject = "CodePipeline action"
+	}
+	return subject + " " + strings.ToLower(strings.ReplaceAll(state, "_", " "))
+}
+
+func formatCodeStarSlackMessage(n CodeStarNotification, status, state string, show func(string) bool) string {
+	emoji := "🚨"
+	if status == alert.StatusResolved {
+		emoji = "✅"
+	}
+	noun := "Pipeline"
+	if n.isBuild() {
+		noun = "Project"
+	}
+	message := fmt.Sprintf("%s *%s: %s*\n• *%s:* *`%s`*", emoji, codeStarHeading(n, state), n.name(), noun, n.name())
+	if stage := codeStarStage(n); stage != "" {
+		message += fmt.Sprintf("\n• *Stage:* `%s`", stage)
+	}
+	if n.Detail.Action != "" {
+		message += fmt.Sprintf("\n• *Action:* `%s`", n.Detail.Action)
+	}
+	if reason := codeStarFailure(n); reason != "" {
+		message += fmt.Sprintf("\n• *Error:* %s", reason)
+	}
+	if initiator := n.Detail.AdditionalInformation.Initiator; initiator != "" && show("initiator") {
+		message += fmt.Sprintf("\n• *Started by:* `%s`", initiator)
+	}
+	if n.Account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", n.Account)
+	}
+	if n.Region != "" && show("region") {
+		message += fmt.Sprintf("\n• *Region:* `%s`", n.Region)
+	}
+	if at := parseTime(time.RFC3339, n.Time); at != nil {
+		message += fmt.Sprintf("\n• *Time:* %s", at.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	if logs := n.Detail.AdditionalInformation.Logs.DeepLink; logs != "" {
+		message += fmt.Sprintf("\n• *Logs:* <%s|View in CloudWatch Logs>", logs)
+	}
+	if id := codeStarExecutionID(n); id != "" {
+		return message + fmt.Sprintf("\n• *Execution:* <%s|%s>", codeStarConsoleURL(n), id)
+	}
+	return message + fmt.Sprintf("\n• *Console:* <%s|View in CodePipeline>", codeStarConsoleURL(n))
+}
+
+// formatCompactCodeStarMessage renders a pipeline or build notification as a single line
+func formatCompactCodeStarMessage(n CodeStarNotification, status, state string) string {
+	emoji := "🚨"
+	if status == alert.StatusResolved {
+		emoji = "✅"
+	}
+	line := fmt.Sprintf("%s *%s* `%s`", emoji, n.name(), state)
+	if stage := codeStarStage(n); stage != "" {
+		line += " in " + stage
+	}
+	if reason := codeStarFailure(n); reason != "" && status == alert.StatusFiring {
+		line += ": " + reason
+	}
+	return line
+}
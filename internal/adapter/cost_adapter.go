@@ -0,0 +1,386 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// States of cost alerts: which amount a budget notification is about, or an anomaly
+const (
+	costStateActual     = "ACTUAL"
+	costStateForecasted = "FORECASTED"
+	costStateAnomaly    = "ANOMALY"
+)
+
+// budgetsConsoleURL lists the budgets, for notifications that don't link their own
+const budgetsConsoleURL = "https://console.aws.amazon.com/billing/home#/budgets"
+
+// BudgetNotification is an AWS Budgets alert, which SNS delivers as plain text:
+//
+//	You requested that we alert you when the ACTUAL Cost associated with your monthly budget
+//	is greater than 80.00% of your budgeted amount ($1,000.00) ...
+//
+//	Budget Name: monthly
+//	Budget Type: Cost
+//	Budgeted Amount: $1,000.00
+//	Alert Type: ACTUAL
+//	Alert Threshold: > $800.00
+//	ACTUAL Amount: $850.23
+type BudgetNotification struct {
+	Account          string
+	BudgetName       string
+	BudgetType       string // Cost, Usage, RI utilization, ...
+	BudgetedAmount   string
+	AlertType        string // ACTUAL or FORECASTED
+	AlertThreshold   string
+	Amount           string  // the actual or forecasted amount
+	ThresholdPercent float64 // of the budgeted amount, or 0 for absolute thresholds
+	Link             string
+}
+
+var (
+	budgetAccountPattern   = regexp.MustCompile(`AWS Account (\d+)`)
+	budgetPercentPattern   = regexp.MustCompile(`([0-9.]+)% of your budgeted amount`)
+	budgetLinkPattern      = regexp.MustCompile(`\[1\]\s*(https://\S+)`)
+	budgetFieldLinePattern = regexp.MustCompile(`(?m)^\s*([A-Za-z ]+):\s*(.+?)\s*$`)
+)
+
+// parseBudgetNotification reads the fields of a Budgets notification, reporting false when the
+// text isn't one
+func parseBudgetNotification(text string) (BudgetNotification, bool) {
+	fields := make(map[string]string)
+	for _, match := range budgetFieldLinePattern.FindAllStringSubmatch(text, -1) {
+		fields[match[1]] = match[2]
+	}
+	if fields["Budget Name"] == "" {
+		return BudgetNotification{}, false
+	}
+
+	budget := BudgetNotification{
+		BudgetName:     fields["Budget Name"],
+		BudgetType:     fields["Budget Type"],
+		BudgetedAmount: fields["Budgeted Amount"],
+		AlertType:      strings.ToUpper(fields["Alert Type"]),
+		AlertThreshold: fields["Alert Threshold"],
+		Link:           budgetsConsoleURL,
+	}
+	if budget.AlertType == "" {
+		budget.AlertType = costStateActual
+	}
+	budget.Amount = fields[budget.AlertType+" Amount"]
+	if match := budgetAccountPattern.FindStringSubmatch(text); match != nil {
+		budget.Account = match[1]
+	}
+	if match := budgetPercentPattern.FindStringSubmatch(text); match != nil {
+		budget.ThresholdPercent, _ = strconv.ParseFloat(match[1], 64)
+	}
+	if match := budgetLinkPattern.FindStringSubmatch(text); match != nil {
+		budget.Link = match[1]
+	}
+	return budget, true
+}
+
+// CostAnomaly is an AWS Cost Anomaly Detection alert, as its SNS subscriptions publish it and as
+// the detail of an EventBridge "Anomaly Detected" event
+type CostAnomaly struct {
+	AccountID          string `json:"accountId"`
+	AnomalyID          string `json:"anomalyId"`
+	AnomalyDetailsLink string `json:"anomalyDetailsLink"`
+	AnomalyStartDate   string `json:"anomalyStartDate"`
+	AnomalyEndDate     string `json:"anomalyEndDate"`
+	MonitorName        string `json:"monitorName"`
+	MonitorArn         string `json:"monitorArn"`
+	Impact             struct {
+		MaxImpact             float64 `json:"maxImpact"`
+		TotalActualSpend      float64 `json:"totalActualSpend"`
+		TotalExpectedSpend    float64 `json:"totalExpectedSpend"`
+		TotalImpact           float64 `json:"totalImpact"`
+		TotalImpactPercentage float64 `json:"totalImpactPercentage"`
+	} `json:"impact"`
+	RootCauses []CostRootCause `json:"rootCauses"`
+}
+
+// CostRootCause is a service, region and usage type an anomaly's spend comes from
+type CostRootCause struct {
+	Service           string `json:"service"`
+	Region            string `json:"region"`
+	UsageType         string `json:"usageType"`
+	LinkedAccount     string `json:"linkedAccount"`
+	LinkedAccountName string `json:"linkedAccountName"`
+}
+
+// costNotification is a Budgets or Cost Anomaly Detection notification read from an SQS body
+type costNotification struct {
+	budget  *BudgetNotification
+	anomaly *CostAnomaly
+}
+
+// parseCostNotification reads a Budgets or Cost Anomaly Detection notification from an SQS body:
+// an SNS envelope around either, or an EventBridge anomaly event directly or wrapped in SNS. It
+// reports false for anything else, such as CloudWatch alarms.
+func parseCostNotification(body string) (costNotification, bool) {
+	var envelope struct {
+		Message    string          `json:"Message"`
+		Source     string          `json:"source"`
+		DetailType string          `json:"detail-type"`
+		Detail     json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return costNotification{}, false
+	}
+	if envelope.Source == "aws.ce" && envelope.DetailType == "Anomaly Detected" {
+		var anomaly CostAnomaly
+		if err := json.Unmarshal(envelope.Detail, &anomaly); err != nil || anomaly.AnomalyID == "" {
+			return costNotification{}, false
+		}
+		return costNotification{anomaly: &anomaly}, true
+	}
+	if envelope.Message == "" {
+		return costNotification{}, false
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(envelope.Message), "{") {
+		var anomaly CostAnomaly
+		if err := json.Unmarshal([]byte(envelope.Message), &anomaly); err == nil && anomaly.AnomalyID != "" {
+			return costNotification{anomaly: &anomaly}, true
+		}
+		return parseCostNotification(envelope.Message)
+	}
+	if budget, ok := parseBudgetNotification(envelope.Message); ok {
+		return costNotification{budget: &budget}, true
+	}
+	return costNotification{}, false
+}
+
+// adaptCostNotification maps a Budgets or Cost Anomaly Detection notification to a routed alert
+func adaptCostNotification(notification costNotification, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) *alert.Alert {
+	var adapted *alert.Alert
+	if notification.budget != nil {
+		adapted = adaptBudgetNotification(*notification.budget, channels, alarmChannels, fields)
+	} else {
+		adapted = adaptCostAnomaly(*notification.anomaly, channels, alarmChannels, fields)
+	}
+	adapted.Raw = body
+	return adapted
+}
+
+// costChannel routes a cost alert by its alarm mapping, then the cost channel, then priority
+func costChannel(name, priority string, channels map[string]string, alarmChannels map[string]string) string {
+	if channel := alarmChannels[name]; channel != "" {
+		return channel
+	}
+	if channel := channels["cost"]; channel != "" {
+		return channel
+	}
+	if channel := channels[priority]; channel != "" {
+		return channel
+	}
+	return channels["default"]
+}
+
+func adaptBudgetNotification(budget BudgetNotification, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) *alert.Alert {
+	// Spending past the whole budget needs someone to look now; earlier thresholds and
+	// forecasts are warnings
+	priority := "P2"
+	if budget.AlertType == costStateActual && budget.ThresholdPercent >= 100 {
+		priority = "P1"
+	}
+	channel := costChannel(budget.BudgetName, priority, channels, alarmChannels)
+
+	labels := map[string]string{"alert_type": strings.ToLower(budget.AlertType)}
+	if budget.BudgetType != "" {
+		labels["budget_type"] = strings.ToLower(budget.BudgetType)
+	}
+	if budget.Account != "" {
+		labels["account"] = budget.Account
+	}
+	annotations := make(map[string]string)
+	if budget.Amount != "" {
+		annotations[alert.AnnotationValue] = budget.Amount
+	}
+	if budget.AlertThreshold != "" {
+		annotations["threshold"] = budget.AlertThreshold
+	}
+
+	return &alert.Alert{
+		Source:      alert.SourceAWSCost,
+		Name:        budget.BudgetName,
+		Severity:    priority,
+		Status:      alert.StatusFiring,
+		State:       budget.AlertType,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: budget.Link},
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"budgeted_amount":   budget.BudgetedAmount,
+			"threshold_percent": budget.ThresholdPercent,
+		},
+		Message: formatBudgetSlackMessage(budget, fieldShower(fields, channel)),
+		Summary: formatCompactBudgetMessage(budget),
+	}
+}
+
+func adaptCostAnomaly(anomaly CostAnomaly, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) *alert.Alert {
+	// Spend at least double what was expected needs someone to look now
+	priority := "P2"
+	if anomaly.Impact.TotalImpactPercentage >= 100 {
+		priority = "P1"
+	}
+	name := anomaly.MonitorName
+	if name == "" {
+		name = "Cost anomaly"
+	}
+	channel := costChannel(name, priority, channels, alarmChannels)
+
+	// The anomaly's ID is a label, so each anomaly is an alert of its own
+	labels := map[string]string{"anomaly_id": anomaly.AnomalyID}
+	if anomaly.AccountID != "" {
+		labels["account"] = anomaly.AccountID
+	}
+	if len(anomaly.RootCauses) > 0 {
+		if cause := anomaly.RootCauses[0]; cause.Service != "" {
+			labels["service"] = cause.Service
+		}
+		if cause := anomaly.RootCauses[0]; cause.Region != "" {
+			labels["region"] = cause.Region
+		}
+	}
+	annotations := map[string]string{
+		alert.AnnotationValue: formatDollars(anomaly.Impact.TotalImpact),
+	}
+	if service := labels["service"]; service != "" {
+		annotations["description"] = fmt.Sprintf("Unexpected spend on %s", service)
+	}
+	urls := make(map[string]string)
+	if anomaly.AnomalyDetailsLink != "" {
+		urls[alert.URLSource] = anomaly.AnomalyDetailsLink
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceAWSCost,
+		Name:        name,
+		Severity:    priority,
+		Status:      alert.StatusFiring,
+		State:       costStateAnomaly,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"monitor_arn":      anomaly.MonitorArn,
+			"impact_percent":   anomaly.Impact.TotalImpactPercentage,
+			"actual_spend":     anomaly.Impact.TotalActualSpend,
+			"expected_spend":   anomaly.Impact.TotalExpectedSpend,
+			"root_cause_count": len(anomaly.RootCauses),
+		},
+		Message: formatCostAnomalySlackMessage(anomaly, name, fieldShower(fields, channel)),
+		Summary: formatCompactCostAnomalyMessage(anomaly, name),
+	}
+	adapted.StartsAt = parseTime(time.RFC3339, anomaly.AnomalyStartDate)
+	return adapted
+}
+
+// formatDollars renders a Cost Explorer amount, which is in USD
+func formatDollars(amount float64) string {
+	return fmt.Sprintf("$%.2f", amount)
+}
+
+func formatBudgetSlackMessage(budget BudgetNotification, show func(string) bool) string {
+	message := fmt.Sprintf("%s *AWS Budget: %s*\n• *Alert:* `%s`", stateEmoji(budget.AlertType), budget.BudgetName, budget.AlertType)
+	if budget.ThresholdPercent > 0 {
+		message += fmt.Sprintf(" over *%s%%* of the budget", strconv.FormatFloat(budget.ThresholdPercent, 'f', -1, 64))
+	}
+	if budget.Amount != "" {
+		kind := strings.ToLower(budget.AlertType)
+		message += fmt.Sprintf("\n• *%s amount:* *%s*", strings.ToUpper(kind[:1])+kind[1:], budget.Amount)
+	}
+	if budget.BudgetedAmount != "" {
+		message += fmt.Sprintf("\n• *Budgeted:* %s", budget.BudgetedAmount)
+	}
+	if budget.AlertThreshold != "" {
+		message += fmt.Sprintf("\n• *Threshold:* `%s`", budget.AlertThreshold)
+	}
+	if budget.BudgetType != "" && show("budget_type") {
+		message += fmt.Sprintf("\n• *Type:* `%s`", budget.BudgetType)
+	}
+	if budget.Account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", budget.Account)
+	}
+	return message + fmt.Sprintf("\n• *Budget:* <%s|View in AWS Budgets>", budget.Link)
+}
+
+// formatCompactBudgetMessage renders a Budgets notification as a single line
+func formatCompactBudgetMessage(budget BudgetNotification) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(budget.AlertType), budget.BudgetName, budget.AlertType)
+	if budget.Amount != "" {
+		line += " " + budget.Amount
+		if budget.BudgetedAmount != "" {
+			line += " of " + budget.BudgetedAmount
+		}
+	}
+	return line + fmt.Sprintf(" <%s|View>", budget.Link)
+}
+
+func formatCostAnomalySlackMessage(anomaly CostAnomaly, name string, show func(string) bool) string {
+	impact := anomaly.Impact
+	message := fmt.Sprintf("%s *AWS Cost Anomaly: %s*\n• *Impact:* *+%s*", stateEmoji(costStateAnomaly), name, formatDollars(impact.TotalImpact))
+	if impact.TotalExpectedSpend > 0 {
+		message += fmt.Sprintf(" (%.1f%% over the expected %s)", impact.TotalImpactPercentage, formatDollars(impact.TotalExpectedSpend))
+	}
+	if impact.TotalActualSpend > 0 {
+		message += fmt.Sprintf("\n• *Actual spend:* %s", formatDollars(impact.TotalActualSpend))
+	}
+
+	if len(anomaly.RootCauses) > 0 {
+		message += "\n• *Root causes:*"
+		for _, cause := range anomaly.RootCauses {
+			line := fmt.Sprintf("\n   → `%s`", cause.Service)
+			if cause.Region != "" {
+				line += fmt.Sprintf(" in `%s`", cause.Region)
+			}
+			if cause.UsageType != "" {
+				line += fmt.Sprintf(" (%s)", cause.UsageType)
+			}
+			if account := cause.LinkedAccountName; account != "" && show("account") {
+				line += fmt.Sprintf(", account %s", account)
+			}
+			message += line
+		}
+	}
+
+	if anomaly.AccountID != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", anomaly.AccountID)
+	}
+	if start := parseTime(time.RFC3339, anomaly.AnomalyStartDate); start != nil {
+		period := start.Format("2006-01-02")
+		if end := parseTime(time.RFC3339, anomaly.AnomalyEndDate); end != nil && !end.Equal(*start) {
+			period += " – " + end.Format("2006-01-02")
+		}
+		message += fmt.Sprintf("\n• *Period:* %s", period)
+	}
+	if anomaly.AnomalyDetailsLink != "" {
+		message += fmt.Sprintf("\n• *Anomaly:* <%s|View in Cost Explorer>", anomaly.AnomalyDetailsLink)
+	}
+	return message
+}
+
+// formatCompactCostAnomalyMessage renders a cost anomaly as a single line
+func formatCompactCostAnomalyMessage(anomaly CostAnomaly, name string) string {
+	line := fmt.Sprintf("%s *%s* `%s` +%s", stateEmoji(costStateAnomaly), name, costStateAnomaly, formatDollars(anomaly.Impact.TotalImpact))
+	if len(anomaly.RootCauses) > 0 && anomaly.RootCauses[0].Service != "" {
+		line += " on " + anomaly.RootCauses[0].Service
+	}
+	if anomaly.AnomalyDetailsLink != "" {
+		line += fmt.Sprintf(" <%s|View>", anomaly.AnomalyDetailsLink)
+	}
+	return line
+}
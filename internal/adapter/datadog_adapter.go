@@ -0,0 +1,236 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// DatadogAlert is the body of a Datadog webhook integration using the payload template in the
+// README. Datadog fills in every field as a string.
+type DatadogAlert struct {
+	ID              string `json:"id"`
+	AlertID         string `json:"alert_id"` // monitor ID
+	Title           string `json:"title"`    // event title, e.g. "[Triggered on {host:web-1}] High CPU"
+	Body            string `json:"body"`     // the monitor message
+	AlertTitle      string `json:"alert_title"`
+	AlertTransition string `json:"alert_transition"` // Triggered, Re-Triggered, Recovered, Warn, No Data, Renotify
+	AlertType       string `json:"alert_type"`       // error, warning, success or info
+	Priority        string `json:"priority"`         // monitor priority, P1 to P5, or empty
+	AlertQuery      string `json:"alert_query"`
+	AlertMetric     string `json:"alert_metric"`
+	AlertScope      string `json:"alert_scope"`
+	Hostname        string `json:"hostname"`
+	Tags            string `json:"tags"` // comma-separated key:value tags
+	Link            string `json:"link"`
+	Snapshot        string `json:"snapshot"`
+	Date            string `json:"date"` // milliseconds since the epoch
+	Org             struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"org"`
+}
+
+// datadogTitlePrefix matches the "[Triggered on {host:web-1}]" Datadog puts before monitor names
+var datadogTitlePrefix = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+
+// AdaptDatadogWebhook maps a Datadog monitor notification to a routed alert
+func AdaptDatadogWebhook(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var ddAlert DatadogAlert
+	if err := json.Unmarshal([]byte(body), &ddAlert); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceDatadog, Err: err}
+	}
+
+	name := datadogMonitorName(ddAlert)
+	if name == "" {
+		return nil, &errs.ParseError{Source: alert.SourceDatadog, Err: fmt.Errorf("no alert_title or title")}
+	}
+	tags := parseDatadogTags(ddAlert.Tags)
+	priority := determineDatadogPriority(ddAlert, tags)
+
+	// First check if there's a specific mapping for this monitor
+	channel := alarmChannels[name]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := datadogState(ddAlert.AlertTransition)
+	status := alert.StatusFiring
+	switch state {
+	case "RECOVERED":
+		status = alert.StatusResolved
+	case "NO_DATA":
+		status = alert.StatusNoData
+	}
+
+	annotations := make(map[string]string)
+	if ddAlert.Body != "" {
+		annotations["description"] = ddAlert.Body
+	}
+	if ddAlert.AlertQuery != "" {
+		annotations["query"] = ddAlert.AlertQuery
+	}
+	urls := make(map[string]string)
+	if ddAlert.Link != "" {
+		urls[alert.URLSource] = ddAlert.Link
+	}
+	if ddAlert.Snapshot != "" {
+		urls[alert.URLImage] = ddAlert.Snapshot
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceDatadog,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      tags,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"monitor_id": ddAlert.AlertID,
+			"event_id":   ddAlert.ID,
+			"priority":   ddAlert.Priority,
+			"scope":      ddAlert.AlertScope,
+			"org":        ddAlert.Org.Name,
+		},
+		Message: formatDatadogSlackMessage(ddAlert, name, state, tags, fieldShower(fields, channel)),
+		Summary: formatCompactDatadogMessage(ddAlert, name, state),
+		Raw:     body,
+	}
+	if ms, err := strconv.ParseInt(ddAlert.Date, 10, 64); err == nil {
+		startsAt := time.UnixMilli(ms).UTC()
+		adapted.StartsAt = &startsAt
+	}
+	return adapted, nil
+}
+
+// datadogMonitorName strips the transition prefix from the title, so every notification of a
+// monitor has the same name for alarm mappings, drop rules and fingerprints
+func datadogMonitorName(ddAlert DatadogAlert) string {
+	title := ddAlert.AlertTitle
+	if title == "" {
+		title = ddAlert.Title
+	}
+	return strings.TrimSpace(datadogTitlePrefix.ReplaceAllString(title, ""))
+}
+
+// datadogState normalizes a transition like "Re-Triggered" or "No Data" to TRIGGERED or NO_DATA
+func datadogState(transition string) string {
+	state := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(transition), " ", "_"))
+	switch state {
+	case "", "RE-TRIGGERED", "RENOTIFY":
+		return "TRIGGERED"
+	case "WARN", "RE-WARN":
+		return "WARN"
+	}
+	return state
+}
+
+// parseDatadogTags splits "env:prod,service:api,canary" into labels; tags without a value keep
+// an empty one
+func parseDatadogTags(tags string) map[string]string {
+	labels := make(map[string]string)
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(tag, ":")
+		labels[key] = value
+	}
+	return labels
+}
+
+// determineDatadogPriority maps Datadog's P1-P5 monitor priorities onto ours; a channel:P0-P2
+// tag wins, and monitors without a priority go by whether they are errors or warnings
+func determineDatadogPriority(ddAlert DatadogAlert, tags map[string]string) string {
+	switch strings.ToUpper(tags["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(tags["channel"])
+	}
+
+	switch strings.ToUpper(ddAlert.Priority) {
+	case "P1":
+		return "P0"
+	case "P2":
+		return "P1"
+	case "P3", "P4", "P5":
+		return "P2"
+	}
+
+	if strings.EqualFold(ddAlert.AlertType, "error") && datadogState(ddAlert.AlertTransition) != "WARN" {
+		return "P1"
+	}
+	return "P2"
+}
+
+func formatDatadogSlackMessage(ddAlert DatadogAlert, name, state string, tags map[string]string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Datadog Alert: %s*\n• *State:* `%s`", stateEmoji(state), name, state)
+
+	if ddAlert.Priority != "" {
+		message += fmt.Sprintf("\n• *Monitor priority:* %s", strings.ToUpper(ddAlert.Priority))
+	}
+	if ddAlert.Hostname != "" {
+		message += fmt.Sprintf("\n• *Host:* `%s`", ddAlert.Hostname)
+	}
+	if ddAlert.AlertScope != "" && ddAlert.AlertScope != "*" {
+		message += fmt.Sprintf("\n• *Scope:* `%s`", ddAlert.AlertScope)
+	}
+	if ddAlert.AlertQuery != "" {
+		message += fmt.Sprintf("\n• *Query:* `%s`", ddAlert.AlertQuery)
+	}
+	if ddAlert.Body != "" {
+		message += fmt.Sprintf("\n• *Description:* %s", ddAlert.Body)
+	}
+
+	// Skip the channel tag as it's used for routing
+	var keys []string
+	for k := range tags {
+		if k != "channel" && show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Tags:*"
+		for _, k := range keys {
+			if tags[k] == "" {
+				message += fmt.Sprintf("\n   → `%s`", k)
+			} else {
+				message += fmt.Sprintf("\n   → `%s`: %s", k, tags[k])
+			}
+		}
+	}
+
+	if ddAlert.Link != "" {
+		message += fmt.Sprintf("\n• *Monitor:* <%s|View in Datadog>", ddAlert.Link)
+	}
+	return message
+}
+
+// formatCompactDatadogMessage renders a Datadog alert as a single line
+func formatCompactDatadogMessage(ddAlert DatadogAlert, name, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), name, state)
+	if ddAlert.Hostname != "" {
+		line += fmt.Sprintf(" on `%s`", ddAlert.Hostname)
+	}
+	if ddAlert.Link != "" {
+		line += fmt.Sprintf(" <%s|View>", ddAlert.Link)
+	}
+	return line
+}
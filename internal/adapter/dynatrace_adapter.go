@@ -0,0 +1,285 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// DynatraceProblem is the body of a Dynatrace custom integration problem notification using the
+// payload in the README
+type DynatraceProblem struct {
+	ProblemID          string            `json:"ProblemID"` // display ID, e.g. P-2405123
+	PID                string            `json:"PID"`
+	ProblemTitle       string            `json:"ProblemTitle"`
+	State              string            `json:"State"`           // OPEN, RESOLVED or MERGED
+	ProblemSeverity    string            `json:"ProblemSeverity"` // AVAILABILITY, ERROR, PERFORMANCE, RESOURCE_CONTENTION, CUSTOM_ALERT, ...
+	ProblemImpact      string            `json:"ProblemImpact"`   // APPLICATION, SERVICE or INFRASTRUCTURE
+	ImpactedEntities   []DynatraceEntity `json:"ImpactedEntities"`
+	ImpactedEntity     string            `json:"ImpactedEntity"`
+	ProblemURL         string            `json:"ProblemURL"`
+	ProblemDetailsText string            `json:"ProblemDetailsText"`
+	Tags               string            `json:"Tags"`            // comma separated, e.g. "env:prod, team:payments"
+	ManagementZones    string            `json:"ManagementZones"` // comma separated, for payloads without ProblemDetailsJSON
+	ProblemDetailsJSON json.RawMessage   `json:"ProblemDetailsJSON"`
+}
+
+// DynatraceEntity is one entity affected by a problem
+type DynatraceEntity struct {
+	Type   string `json:"type"` // HOST, SERVICE, APPLICATION, ...
+	Name   string `json:"name"`
+	Entity string `json:"entity"` // entity ID, e.g. HOST-4F3C2A91D8E0B7C6
+}
+
+// dynatraceDetails is the part of {ProblemDetailsJSON} the adapter uses
+type dynatraceDetails struct {
+	StartTime       int64 `json:"startTime"` // unix milliseconds
+	ManagementZones []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"managementZones"`
+}
+
+// AdaptDynatraceProblem maps a Dynatrace problem notification to a routed alert. Problems go to
+// their alarm mapping, then the channel of their first mapped management zone, then the channel
+// of their priority.
+func AdaptDynatraceProblem(body string, channels, alarmChannels, zoneChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var problem DynatraceProblem
+	if err := json.Unmarshal([]byte(body), &problem); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceDynatrace, Err: err}
+	}
+	if problem.ProblemTitle == "" {
+		return nil, &errs.ParseError{Source: alert.SourceDynatrace, Err: fmt.Errorf("no ProblemTitle")}
+	}
+
+	var details dynatraceDetails
+	if len(problem.ProblemDetailsJSON) > 0 {
+		// Details are optional; a payload that mangles them still routes by title and severity
+		json.Unmarshal(problem.ProblemDetailsJSON, &details)
+	}
+	zones := dynatraceZones(problem, details)
+	tags := dynatraceTags(problem.Tags)
+	priority := determineDynatracePriority(problem.ProblemSeverity, tags)
+
+	// First check if there's a specific mapping for this problem, then for its management zones
+	channel := alarmChannels[problem.ProblemTitle]
+	for _, zone := range zones {
+		if channel != "" {
+			break
+		}
+		channel = zoneChannels[zone]
+	}
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := strings.ToUpper(problem.State)
+	if state == "" {
+		state = "OPEN"
+	}
+	status := alert.StatusFiring
+	if state == "RESOLVED" || state == "MERGED" {
+		status = alert.StatusResolved
+	}
+
+	labels := make(map[string]string, len(tags)+3)
+	for k, v := range tags {
+		labels[k] = v
+	}
+	if len(zones) > 0 {
+		labels["management_zone"] = strings.Join(zones, ", ")
+	}
+	if entities := dynatraceEntityNames(problem); len(entities) > 0 {
+		labels["entity"] = strings.Join(entities, ", ")
+	}
+	if problem.ProblemImpact != "" {
+		labels["impact"] = strings.ToLower(problem.ProblemImpact)
+	}
+
+	annotations := make(map[string]string)
+	if problem.ProblemDetailsText != "" {
+		annotations["description"] = problem.ProblemDetailsText
+	}
+	urls := make(map[string]string)
+	if problem.ProblemURL != "" {
+		urls[alert.URLSource] = problem.ProblemURL
+	}
+
+	entityIDs := make([]string, 0, len(problem.ImpactedEntities))
+	for _, entity := range problem.ImpactedEntities {
+		entityIDs = append(entityIDs, entity.Entity)
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceDynatrace,
+		Name:        problem.ProblemTitle,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"problem_id":         problem.ProblemID,
+			"pid":                problem.PID,
+			"dynatrace_severity": problem.ProblemSeverity,
+			"entity_ids":         entityIDs,
+		},
+		Message: formatDynatraceSlackMessage(problem, state, zones, tags, fieldShower(fields, channel)),
+		Summary: formatCompactDynatraceMessage(problem, state),
+		Raw:     body,
+	}
+	if details.StartTime > 0 {
+		startsAt := time.UnixMilli(details.StartTime).UTC()
+		adapted.StartsAt = &startsAt
+	}
+	return adapted, nil
+}
+
+// dynatraceZones lists the problem's management zones from its details, or from the
+// ManagementZones field of payloads that don't send them
+func dynatraceZones(problem DynatraceProblem, details dynatraceDetails) []string {
+	var zones []string
+	for _, zone := range details.ManagementZones {
+		if zone.Name != "" {
+			zones = append(zones, zone.Name)
+		}
+	}
+	if len(zones) > 0 {
+		return zones
+	}
+	for _, zone := range strings.Split(problem.ManagementZones, ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// dynatraceTags turns {Tags}, e.g. "env:prod, [Kubernetes]app:checkout, canary", into labels.
+// Tags without a value get an empty one.
+func dynatraceTags(raw string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(raw, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(tag, ":")
+		tags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return tags
+}
+
+// dynatraceEntityNames lists the impacted entities, or the one impacted entity of payloads
+// without the list
+func dynatraceEntityNames(problem DynatraceProblem) []string {
+	var names []string
+	for _, entity := range problem.ImpactedEntities {
+		if entity.Name != "" {
+			names = append(names, entity.Name)
+		}
+	}
+	if len(names) == 0 && problem.ImpactedEntity != "" {
+		names = append(names, problem.ImpactedEntity)
+	}
+	return names
+}
+
+// determineDynatracePriority maps problem severities onto priorities: AVAILABILITY is P0, ERROR
+// P1 and PERFORMANCE, RESOURCE_CONTENTION, CUSTOM_ALERT and the rest P2. A channel:P0-P2 tag wins.
+func determineDynatracePriority(severity string, tags map[string]string) string {
+	switch strings.ToUpper(tags["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(tags["channel"])
+	}
+
+	switch strings.ToUpper(severity) {
+	case "AVAILABILITY":
+		return "P0"
+	case "ERROR":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+func formatDynatraceSlackMessage(problem DynatraceProblem, state string, zones []string, tags map[string]string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Dynatrace Problem: %s*\n• *State:* `%s`", stateEmoji(state), problem.ProblemTitle, state)
+
+	if problem.ProblemID != "" {
+		message += fmt.Sprintf("\n• *ID:* `%s`", problem.ProblemID)
+	}
+	if problem.ProblemSeverity != "" {
+		message += fmt.Sprintf("\n• *Severity:* %s", problem.ProblemSeverity)
+	}
+	if problem.ProblemImpact != "" {
+		message += fmt.Sprintf("\n• *Impact:* %s", problem.ProblemImpact)
+	}
+	if len(problem.ImpactedEntities) > 0 {
+		message += "\n• *Impacted entities:*"
+		for _, entity := range problem.ImpactedEntities {
+			message += fmt.Sprintf("\n   → `%s` %s", entity.Name, strings.ToLower(entity.Type))
+		}
+	} else if problem.ImpactedEntity != "" {
+		message += fmt.Sprintf("\n• *Impacted entity:* `%s`", problem.ImpactedEntity)
+	}
+	if len(zones) > 0 {
+		message += fmt.Sprintf("\n• *Management zones:* `%s`", strings.Join(zones, "`, `"))
+	}
+	if problem.ProblemDetailsText != "" {
+		details := problem.ProblemDetailsText
+		if len(details) > 500 {
+			details = details[:497] + "..."
+		}
+		message += fmt.Sprintf("\n• *Details:* %s", details)
+	}
+
+	// Skip the channel tag as it's used for routing
+	var keys []string
+	for k := range tags {
+		if k != "channel" && show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Tags:*"
+		for _, k := range keys {
+			if tags[k] == "" {
+				message += fmt.Sprintf("\n   → `%s`", k)
+			} else {
+				message += fmt.Sprintf("\n   → `%s`: %s", k, tags[k])
+			}
+		}
+	}
+
+	if problem.ProblemURL != "" {
+		message += fmt.Sprintf("\n• *Problem:* <%s|View in Dynatrace>", problem.ProblemURL)
+	}
+	return message
+}
+
+// formatCompactDynatraceMessage renders a Dynatrace problem as a single line
+func formatCompactDynatraceMessage(problem DynatraceProblem, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), problem.ProblemTitle, state)
+	if entities := dynatraceEntityNames(problem); len(entities) > 0 {
+		line += fmt.Sprintf(" on `%s`", entities[0])
+	}
+	if problem.ProblemURL != "" {
+		line += fmt.Sprintf(" <%s|View>", problem.ProblemURL)
+	}
+	return line
+}
@@ -0,0 +1,393 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// ECS event detail-types the adapter maps
+const (
+	ecsTaskStateChange = "ECS Task State Change"
+	ecsServiceAction   = "ECS Service Action"
+)
+
+// ECSEvent is an event EventBridge delivers from ECS: a task state change or a service action
+type ECSEvent struct {
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Account    string          `json:"account"`
+	Region     string          `json:"region"`
+	Resources  []string        `json:"resources"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+// ECSTask is the detail of an "ECS Task State Change" event
+type ECSTask struct {
+	ClusterArn        string         `json:"clusterArn"`
+	TaskArn           string         `json:"taskArn"`
+	TaskDefinitionArn string         `json:"taskDefinitionArn"`
+	Group             string         `json:"group"` // service:<name> or family:<name>
+	LastStatus        string         `json:"lastStatus"`
+	DesiredStatus     string         `json:"desiredStatus"`
+	StopCode          string         `json:"stopCode"` // TaskFailedToStart, EssentialContainerExited, UserInitiated, ...
+	StoppedReason     string         `json:"stoppedReason"`
+	LaunchType        string         `json:"launchType"`
+	AvailabilityZone  string         `json:"availabilityZone"`
+	StartedAt         string         `json:"startedAt"`
+	StoppedAt         string         `json:"stoppedAt"`
+	Containers        []ECSContainer `json:"containers"`
+}
+
+// ECSContainer is a container of a task, with how it exited once it has
+type ECSContainer struct {
+	Name       string `json:"name"`
+	Image      string `json:"image"`
+	LastStatus string `json:"lastStatus"`
+	ExitCode   *int   `json:"exitCode"`
+	Reason     string `json:"reason"` // e.g. OutOfMemoryError: Container killed due to memory usage
+}
+
+// ECSServiceAction is the detail of an "ECS Service Action" event
+type ECSServiceAction struct {
+	EventType  string `json:"eventType"` // INFO, WARN or ERROR
+	EventName  string `json:"eventName"` // e.g. SERVICE_TASK_PLACEMENT_FAILURE, SERVICE_STEADY_STATE
+	ClusterArn string `json:"clusterArn"`
+	CreatedAt  string `json:"createdAt"`
+	Reason     string `json:"reason"`
+}
+
+// parseECSEvent reads an EventBridge ECS event from an SQS body, directly or wrapped in SNS,
+// reporting false for anything else
+func parseECSEvent(body string) (ECSEvent, bool) {
+	var event ECSEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return ECSEvent{}, false
+	}
+	if event.Source == "aws.ecs" {
+		return event, true
+	}
+
+	var envelope struct {
+		Message string `json:"Message"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || !strings.HasPrefix(strings.TrimSpace(envelope.Message), "{") {
+		return ECSEvent{}, false
+	}
+	return parseECSEvent(envelope.Message)
+}
+
+// adaptECSEvent maps an ECS task state change or service action to a routed alert. Both are
+// named after the service, or the task family for tasks outside a service, and labeled with
+// its cluster, so a service settling resolves its crashed tasks' alert.
+func adaptECSEvent(event ECSEvent, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var adapted *alert.Alert
+	switch event.DetailType {
+	case ecsTaskStateChange:
+		var task ECSTask
+		if err := json.Unmarshal(event.Detail, &task); err != nil {
+			return nil, &errs.ParseError{Source: alert.SourceECS, Err: err}
+		}
+		adapted = adaptECSTask(event, task, channels, alarmChannels, fields)
+	case ecsServiceAction:
+		var action ECSServiceAction
+		if err := json.Unmarshal(event.Detail, &action); err != nil {
+			return nil, &errs.ParseError{Source: alert.SourceECS, Err: err}
+		}
+		adapted = adaptECSServiceAction(event, action, channels, alarmChannels, fields)
+	default:
+		return nil, &errs.ParseError{Source: alert.SourceECS, Err: fmt.Errorf("unsupported ECS event %q", event.DetailType)}
+	}
+	adapted.Raw = body
+	return adapted, nil
+}
+
+// ecsChannel routes an ECS alert by its alarm mapping, then priority
+func ecsChannel(name, priority string, channels map[string]string, alarmChannels map[string]string) string {
+	if channel := alarmChannels[name]; channel != "" {
+		return channel
+	}
+	if channel := channels[priority]; channel != "" {
+		return channel
+	}
+	return channels["default"]
+}
+
+// arnName is the last part of an ARN's resource, e.g. the cluster of
+// arn:aws:ecs:us-east-1:123456789012:cluster/prod
+func arnName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// ecsTaskName names a task after its service or, outside a service, its family
+func ecsTaskName(task ECSTask) (name string, inService bool) {
+	if service, ok := strings.CutPrefix(task.Group, "service:"); ok {
+		return service, true
+	}
+	if family, ok := strings.CutPrefix(task.Group, "family:"); ok {
+		return family, false
+	}
+	family, _, _ := strings.Cut(arnName(task.TaskDefinitionArn), ":")
+	return family, false
+}
+
+// ecsTaskCrashed reports whether a stopped task failed to start or a container of it failed,
+// rather than being stopped by a deployment, scaling or a user
+func ecsTaskCrashed(task ECSTask) bool {
+	if task.LastStatus != "STOPPED" {
+		return false
+	}
+	if task.StopCode == "TaskFailedToStart" {
+		return true
+	}
+	for _, container := range task.Containers {
+		if (container.ExitCode != nil && *container.ExitCode != 0) || container.Reason != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func ecsLabels(event ECSEvent, cluster, serviceLabel, name string) map[string]string {
+	labels := map[string]string{"cluster": cluster, serviceLabel: name}
+	if event.Account != "" {
+		labels["account"] = event.Account
+	}
+	if event.Region != "" {
+		labels["region"] = event.Region
+	}
+	return labels
+}
+
+func adaptECSTask(event ECSEvent, task ECSTask, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) *alert.Alert {
+	name, inService := ecsTaskName(task)
+	cluster := arnName(task.ClusterArn)
+
+	// A service's crashing tasks take capacity away now; a standalone or scheduled task can
+	// be rerun. Tasks that didn't crash resolve the alert in the same channel.
+	status, priority := alert.StatusResolved, "P2"
+	if ecsTaskCrashed(task) {
+		status = alert.StatusFiring
+	}
+	if inService {
+		priority = "P1"
+	}
+	channel := ecsChannel(name, priority, channels, alarmChannels)
+
+	serviceLabel := "service"
+	if !inService {
+		serviceLabel = "task_family"
+	}
+	labels := ecsLabels(event, cluster, serviceLabel, name)
+	annotations := make(map[string]string)
+	if task.StoppedReason != "" {
+		annotations["description"] = task.StoppedReason
+	}
+	if failed := ecsFailedContainer(task); failed != nil && failed.ExitCode != nil {
+		annotations[alert.AnnotationValue] = fmt.Sprint(*failed.ExitCode)
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceECS,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       task.LastStatus,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: ecsTaskURL(event.Region, cluster, arnName(task.TaskArn))},
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"task_arn":        task.TaskArn,
+			"task_definition": arnName(task.TaskDefinitionArn),
+			"stop_code":       task.StopCode,
+			"launch_type":     task.LaunchType,
+		},
+		Message: formatECSTaskSlackMessage(event, task, name, status, fieldShower(fields, channel)),
+		Summary: formatCompactECSTaskMessage(task, name, status),
+	}
+	adapted.StartsAt = parseTime(time.RFC3339, task.StartedAt)
+	adapted.EndsAt = parseTime(time.RFC3339, task.StoppedAt)
+	if status == alert.StatusFiring {
+		adapted.EndsAt = nil
+	}
+	return adapted
+}
+
+func adaptECSServiceAction(event ECSEvent, action ECSServiceAction, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) *alert.Alert {
+	name := ""
+	if len(event.Resources) > 0 {
+		name = arnName(event.Resources[0])
+	}
+	cluster := arnName(action.ClusterArn)
+
+	// A steady state resolves the service's alert, in the channel its crashing tasks go to
+	status, priority := alert.StatusFiring, "P2"
+	switch action.EventType {
+	case "ERROR":
+		priority = "P1"
+	case "INFO":
+		status, priority = alert.StatusResolved, "P1"
+	}
+	channel := ecsChannel(name, priority, channels, alarmChannels)
+
+	annotations := map[string]string{"description": action.EventName}
+	if action.Reason != "" {
+		annotations["description"] += ": " + action.Reason
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceECS,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       action.EventType,
+		Channel:     channel,
+		Labels:      ecsLabels(event, cluster, "service", name),
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: ecsServiceURL(event.Region, cluster, name)},
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"event_name": action.EventName,
+		},
+		Message: formatECSServiceActionSlackMessage(event, action, name, cluster, status, fieldShower(fields, channel)),
+		Summary: formatCompactECSServiceActionMessage(action, name, status),
+	}
+	adapted.StartsAt = parseTime(time.RFC3339, action.CreatedAt)
+	return adapted
+}
+
+// ecsFailedContainer is the first container that exited non-zero or was stopped with a reason
+func ecsFailedContainer(task ECSTask) *ECSContainer {
+	for i, container := range task.Containers {
+		if (container.ExitCode != nil && *container.ExitCode != 0) || container.Reason != "" {
+			return &task.Containers[i]
+		}
+	}
+	return nil
+}
+
+func ecsTaskURL(region, cluster, taskID string) string {
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/ecs/v2/clusters/%s/tasks/%s?region=%s", region, cluster, taskID, region)
+}
+
+func ecsServiceURL(region, cluster, service string) string {
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/ecs/v2/clusters/%s/services/%s?region=%s", region, cluster, service, region)
+}
+
+// ecsEmoji marks crashes and service errors red, warnings yellow and the rest as resolved
+func ecsEmoji(status, eventType string) string {
+	switch {
+	case status == alert.StatusResolved:
+		return "✅"
+	case eventType == "WARN":
+		return "⚠️"
+	default:
+		return "🚨"
+	}
+}
+
+func formatECSTaskSlackMessage(event ECSEvent, task ECSTask, name, status string, show func(string) bool) string {
+	heading := "ECS task stopped"
+	switch {
+	case status == alert.StatusFiring && task.StopCode == "TaskFailedToStart":
+		heading = "ECS task failed to start"
+	case status == alert.StatusFiring:
+		heading = "ECS task crashed"
+	case task.LastStatus != "STOPPED":
+		heading = "ECS task " + strings.ToLower(task.LastStatus)
+	}
+	message := fmt.Sprintf("%s *%s: %s*\n• *Cluster:* `%s`", ecsEmoji(status, ""), heading, name, arnName(task.ClusterArn))
+	if task.StopCode != "" {
+		message += fmt.Sprintf("\n• *Stop code:* `%s`", task.StopCode)
+	}
+	if task.StoppedReason != "" {
+		message += fmt.Sprintf("\n• *Reason:* %s", task.StoppedReason)
+	}
+
+	if len(task.Containers) > 0 {
+		message += "\n• *Containers:*"
+		for _, container := range task.Containers {
+			line := fmt.Sprintf("\n   → `%s`", container.Name)
+			if container.ExitCode != nil {
+				line += fmt.Sprintf(" exited with *%d*", *container.ExitCode)
+			} else if container.LastStatus != "" {
+				line += " " + strings.ToLower(container.LastStatus)
+			}
+			if container.Reason != "" {
+				line += " — " + container.Reason
+			}
+			message += line
+		}
+	}
+
+	if definition := arnName(task.TaskDefinitionArn); definition != "" {
+		message += fmt.Sprintf("\n• *Task definition:* `%s`", definition)
+	}
+	placement := []string{}
+	if task.LaunchType != "" {
+		placement = append(placement, task.LaunchType)
+	}
+	if task.AvailabilityZone != "" && show("availability_zone") {
+		placement = append(placement, task.AvailabilityZone)
+	}
+	cluster, taskID := arnName(task.ClusterArn), arnName(task.TaskArn)
+	message += fmt.Sprintf("\n• *Task:* <%s|%s>", ecsTaskURL(event.Region, cluster, taskID), taskID)
+	if len(placement) > 0 {
+		message += fmt.Sprintf(" (%s)", strings.Join(placement, ", "))
+	}
+	started, stopped := parseTime(time.RFC3339, task.StartedAt), parseTime(time.RFC3339, task.StoppedAt)
+	if started != nil && stopped != nil {
+		message += fmt.Sprintf("\n• *Ran for:* %s", stopped.Sub(*started).Round(time.Second))
+	}
+	if event.Account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", event.Account)
+	}
+	return message
+}
+
+// formatCompactECSTaskMessage renders a task state change as a single line
+func formatCompactECSTaskMessage(task ECSTask, name, status string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", ecsEmoji(status, ""), name, task.LastStatus)
+	if failed := ecsFailedContainer(task); failed != nil {
+		line += " " + failed.Name
+		if failed.ExitCode != nil {
+			line += fmt.Sprintf(" exited %d", *failed.ExitCode)
+		}
+		if failed.Reason != "" {
+			line += ": " + failed.Reason
+		}
+	} else if task.StoppedReason != "" {
+		line += " " + task.StoppedReason
+	}
+	return line
+}
+
+func formatECSServiceActionSlackMessage(event ECSEvent, action ECSServiceAction, name, cluster, status string, show func(string) bool) string {
+	heading := map[string]string{"ERROR": "error", "WARN": "warning"}[action.EventType]
+	if heading == "" {
+		heading = "event"
+	}
+	message := fmt.Sprintf("%s *ECS service %s: %s*\n• *Cluster:* `%s`\n• *Event:* `%s`", ecsEmoji(status, action.EventType), heading, name, cluster, action.EventName)
+	if action.Reason != "" {
+		message += fmt.Sprintf("\n• *Reason:* %s", action.Reason)
+	}
+	if event.Account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", event.Account)
+	}
+	return message + fmt.Sprintf("\n• *Service:* <%s|View in ECS>", ecsServiceURL(event.Region, cluster, name))
+}
+
+// formatCompactECSServiceActionMessage renders a service action as a single line
+func formatCompactECSServiceActionMessage(action ECSServiceAction, name, status string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", ecsEmoji(status, action.EventType), name, action.EventName)
+	if action.Reason != "" {
+		line += " " + action.Reason
+	}
+	return line
+}
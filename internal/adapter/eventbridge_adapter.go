@@ -0,0 +1,163 @@
+package adapter
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// EventBridgeAlarmEvent is a "CloudWatch Alarm State Change" event as delivered by an EventBridge rule
+type EventBridgeAlarmEvent struct {
+	DetailType string   `json:"detail-type"`
+	Source     string   `json:"source"`
+	Account    string   `json:"account"`
+	Region     string   `json:"region"`
+	Resources  []string `json:"resources"`
+	Detail     struct {
+		AlarmName     string `json:"alarmName"`
+		Configuration struct {
+			Description string              `json:"description"`
+			AlarmRule   string              `json:"alarmRule"`
+			Metrics     []eventBridgeMetric `json:"metrics"`
+		} `json:"configuration"`
+		State         eventBridgeState `json:"state"`
+		PreviousState eventBridgeState `json:"previousState"`
+	} `json:"detail"`
+}
+
+type eventBridgeState struct {
+	Value      string `json:"value"`
+	Reason     string `json:"reason"`
+	ReasonData string `json:"reasonData"` // JSON document encoded as a string
+	Timestamp  string `json:"timestamp"`
+}
+
+type eventBridgeMetric struct {
+	Id         string `json:"id"`
+	Expression string `json:"expression"`
+	Label      string `json:"label"`
+	ReturnData bool   `json:"returnData"`
+	MetricStat *struct {
+		Metric struct {
+			Namespace  string            `json:"namespace"`
+			Name       string            `json:"name"`
+			Dimensions map[string]string `json:"dimensions"`
+		} `json:"metric"`
+		Period int    `json:"period"`
+		Stat   string `json:"stat"`
+	} `json:"metricStat"`
+}
+
+// eventBridgeReasonData is the subset of state.reasonData we render
+type eventBridgeReasonData struct {
+	Threshold           *float64          `json:"threshold"`
+	EvaluatedDatapoints []json.RawMessage `json:"evaluatedDatapoints"`
+	TriggeringAlarms    []struct {
+		Arn   string `json:"arn"`
+		State struct {
+			Value     string `json:"value"`
+			Timestamp string `json:"timestamp"`
+		} `json:"state"`
+	} `json:"triggeringAlarms"`
+}
+
+// isEventBridgeAlarmEvent reports whether a message is an EventBridge alarm state change event
+func isEventBridgeAlarmEvent(message string) bool {
+	var probe struct {
+		DetailType string `json:"detail-type"`
+		Source     string `json:"source"`
+	}
+	if err := json.Unmarshal([]byte(message), &probe); err != nil {
+		return false
+	}
+	return probe.Source == "aws.cloudwatch" && probe.DetailType == "CloudWatch Alarm State Change"
+}
+
+// parseEventBridgeAlarm converts an EventBridge alarm event into the SNS alarm shape we format and route
+func parseEventBridgeAlarm(message string) (CloudWatchAlarm, error) {
+	var event EventBridgeAlarmEvent
+	if err := json.Unmarshal([]byte(message), &event); err != nil {
+		return CloudWatchAlarm{}, err
+	}
+
+	detail := event.Detail
+	alarm := CloudWatchAlarm{
+		AlarmName:       detail.AlarmName,
+		AWSAccountId:    event.Account,
+		NewStateValue:   detail.State.Value,
+		OldStateValue:   detail.PreviousState.Value,
+		NewStateReason:  detail.State.Reason,
+		StateChangeTime: detail.State.Timestamp,
+		Region:          event.Region,
+		AlarmRule:       detail.Configuration.AlarmRule,
+	}
+	if detail.Configuration.Description != "" {
+		alarm.AlarmDescription = &detail.Configuration.Description
+	}
+	if len(event.Resources) > 0 {
+		alarm.AlarmArn = event.Resources[0]
+	}
+
+	metrics := detail.Configuration.Metrics
+	if len(metrics) == 1 && metrics[0].MetricStat != nil {
+		// A plain single-metric alarm maps onto the flat Trigger fields
+		stat := metrics[0].MetricStat
+		alarm.Trigger.Namespace = stat.Metric.Namespace
+		alarm.Trigger.MetricName = stat.Metric.Name
+		alarm.Trigger.Statistic = stat.Stat
+		alarm.Trigger.Period = stat.Period
+		alarm.Trigger.Dimensions = eventBridgeDimensions(stat.Metric.Dimensions)
+	} else {
+		for _, m := range metrics {
+			tm := TriggerMetric{
+				Id:         m.Id,
+				Expression: m.Expression,
+				Label:      m.Label,
+				ReturnData: m.ReturnData,
+			}
+			if m.MetricStat != nil {
+				tm.MetricStat = &MetricStat{Period: m.MetricStat.Period, Stat: m.MetricStat.Stat}
+				tm.MetricStat.Metric.Namespace = m.MetricStat.Metric.Namespace
+				tm.MetricStat.Metric.MetricName = m.MetricStat.Metric.Name
+				tm.MetricStat.Metric.Dimensions = eventBridgeDimensions(m.MetricStat.Metric.Dimensions)
+			}
+			alarm.Trigger.Metrics = append(alarm.Trigger.Metrics, tm)
+
+			// Anomaly detection alarms evaluate against the band expression
+			if anomalyBandRe.MatchString(m.Expression) {
+				alarm.Trigger.ThresholdMetricId = m.Id
+			}
+		}
+	}
+
+	// Threshold, evaluation count and composite children only appear inside reasonData
+	var reasonData eventBridgeReasonData
+	if detail.State.ReasonData != "" && json.Unmarshal([]byte(detail.State.ReasonData), &reasonData) == nil {
+		if reasonData.Threshold != nil {
+			alarm.Trigger.Threshold = *reasonData.Threshold
+		}
+		alarm.Trigger.EvaluationPeriods = len(reasonData.EvaluatedDatapoints)
+		for _, child := range reasonData.TriggeringAlarms {
+			ref := CompositeChildRef{Arn: child.Arn}
+			ref.State.Value = child.State.Value
+			ref.State.Timestamp = child.State.Timestamp
+			alarm.TriggeringChildren = append(alarm.TriggeringChildren, ref)
+		}
+	}
+
+	return alarm, nil
+}
+
+// eventBridgeDimensions converts EventBridge's dimension map to the SNS list form, sorted for stable output
+func eventBridgeDimensions(dimensions map[string]string) []Dimension {
+	names := make([]string, 0, len(dimensions))
+	for name := range dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]Dimension, 0, len(names))
+	for _, name := range names {
+		result = append(result, Dimension{Name: name, Value: dimensions[name]})
+	}
+	return result
+}
@@ -0,0 +1,21 @@
+package adapter
+
+// FieldFilter decides which labels, tags, dimensions and annotations the Slack messages of a
+// channel show. *config.Config implements it from each route's field rules.
+type FieldFilter interface {
+	ShowField(channel, key string) bool
+}
+
+// fieldShower returns whether a field is shown in channel's messages; a nil filter shows all
+func fieldShower(fields FieldFilter, channel string) func(key string) bool {
+	if fields == nil {
+		return showAllFields
+	}
+	return func(key string) bool {
+		return fields.ShowField(channel, key)
+	}
+}
+
+func showAllFields(string) bool {
+	return true
+}
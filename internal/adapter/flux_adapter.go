@@ -0,0 +1,225 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// ErrFluxEventIgnored is wrapped by the parse errors of informational events that don't report
+// a successful reconciliation, such as progress and dependency waits, so the webhook can accept
+// them
+var ErrFluxEventIgnored = errors.New("only error events and successful reconciliations are mapped")
+
+// FluxEvent is the event the Flux notification-controller posts to generic and generic-hmac
+// providers
+type FluxEvent struct {
+	InvolvedObject      FluxObject        `json:"involvedObject"`
+	Severity            string            `json:"severity"` // info or error
+	Timestamp           string            `json:"timestamp"`
+	Message             string            `json:"message"`
+	Reason              string            `json:"reason"`   // e.g. ReconciliationFailed, HealthCheckFailed, ReconciliationSucceeded
+	Metadata            map[string]string `json:"metadata"` // revision, summary and the Alert's eventMetadata
+	ReportingController string            `json:"reportingController"`
+}
+
+type FluxObject struct {
+	Kind      string `json:"kind"` // Kustomization, HelmRelease, GitRepository, ...
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// fluxSuccessReasons are the reasons of informational events that report an object reconciled
+// successfully, which resolve its failure
+var fluxSuccessReasons = map[string]bool{
+	"ReconciliationSucceeded": true,
+	"Succeeded":               true,
+	"NewArtifact":             true,
+	"InstallSucceeded":        true,
+	"UpgradeSucceeded":        true,
+	"TestSucceeded":           true,
+}
+
+// fluxSourceKinds are the source-controller kinds, whose failures leave the last fetched
+// artifact deployed
+var fluxSourceKinds = map[string]bool{
+	"GitRepository":  true,
+	"OCIRepository":  true,
+	"HelmRepository": true,
+	"HelmChart":      true,
+	"Bucket":         true,
+}
+
+// Metadata keys shown in their own fields, or not at all, rather than as labels
+var fluxMetadataFields = map[string]bool{"revision": true, "summary": true, "token": true}
+
+// fluxMetadataLabels are the metadata keys that become labels, sorted
+func fluxMetadataLabels(metadata map[string]string) []string {
+	var keys []string
+	for key, value := range metadata {
+		if !fluxMetadataFields[key] && value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fluxStatus maps an event to a status: errors fire and successful reconciliations resolve.
+// It reports false for anything else.
+func fluxStatus(event FluxEvent) (string, bool) {
+	switch {
+	case event.Severity == "error":
+		return alert.StatusFiring, true
+	case event.Severity == "info" && fluxSuccessReasons[event.Reason]:
+		return alert.StatusResolved, true
+	}
+	return "", false
+}
+
+// fluxRevision shortens the commit in revisions such as main@sha1:<sha> and sha256:<digest>,
+// leaving chart versions as they are
+func fluxRevision(revision string) string {
+	ref, digest, ok := strings.Cut(revision, ":")
+	if !ok {
+		return revision
+	}
+	return ref + ":" + shortSHA(digest)
+}
+
+// AdaptFluxEvent maps an error event of a Flux object to an alert named <kind>/<name>, which
+// the object's next successful reconciliation resolves. Sources failing to fetch are P2, since
+// what they last fetched stays deployed, and the rest P1. Alerts go to their alarm mapping,
+// then the channel of their <namespace>/<name>, then of their namespace, then the channel of
+// their priority.
+func AdaptFluxEvent(body string, channels, alarmChannels, objectChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var event FluxEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceFlux, Err: err}
+	}
+	object := event.InvolvedObject
+	if object.Kind == "" || object.Name == "" {
+		return nil, &errs.ParseError{Source: alert.SourceFlux, Err: fmt.Errorf("event has no involved object")}
+	}
+	name := object.Kind + "/" + object.Name
+	status, ok := fluxStatus(event)
+	if !ok {
+		return nil, &errs.ParseError{Source: alert.SourceFlux, Err: fmt.Errorf("%s %s event %s: %w", name, event.Severity, event.Reason, ErrFluxEventIgnored)}
+	}
+
+	priority := "P1"
+	if fluxSourceKinds[object.Kind] {
+		priority = "P2"
+	}
+
+	channel := alarmChannels[name]
+	if channel == "" {
+		channel = objectChannels[object.Namespace+"/"+object.Name]
+	}
+	if channel == "" {
+		channel = objectChannels[object.Namespace]
+	}
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	// The reason and revision change between a failure and its recovery, so they aren't labels
+	labels := map[string]string{"kind": object.Kind, "name": object.Name}
+	if object.Namespace != "" {
+		labels["namespace"] = object.Namespace
+	}
+	for _, key := range fluxMetadataLabels(event.Metadata) {
+		labels[key] = event.Metadata[key]
+	}
+	annotations := make(map[string]string)
+	if event.Message != "" {
+		annotations["description"] = event.Message
+	}
+	if summary := event.Metadata["summary"]; summary != "" {
+		annotations["summary"] = summary
+	}
+
+	now := time.Now()
+	at := now
+	if parsed, err := time.Parse(time.RFC3339, event.Timestamp); err == nil {
+		at = parsed
+	}
+	adapted := &alert.Alert{
+		Source:      alert.SourceFlux,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       event.Reason,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        make(map[string]string),
+		ReceivedAt:  now,
+		Raw:         body,
+		Extensions: map[string]interface{}{
+			"revision":   event.Metadata["revision"],
+			"controller": event.ReportingController,
+		},
+		Message: formatFluxSlackMessage(event, status, fieldShower(fields, channel)),
+		Summary: formatCompactFluxMessage(event, status),
+	}
+	if status == alert.StatusResolved {
+		adapted.EndsAt = &at
+	} else {
+		adapted.StartsAt = &at
+	}
+	return adapted, nil
+}
+
+func formatFluxSlackMessage(event FluxEvent, status string, show func(string) bool) string {
+	object := event.InvolvedObject
+	emoji, outcome := "🚨", "failed to reconcile"
+	if status == alert.StatusResolved {
+		emoji, outcome = "✅", "reconciled"
+	}
+	message := fmt.Sprintf("%s *Flux %s %s %s*\n• *Reason:* `%s`", emoji, object.Kind, object.Name, outcome, event.Reason)
+	if summary := event.Metadata["summary"]; summary != "" {
+		message += fmt.Sprintf("\n• *Summary:* %s", summary)
+	}
+	if revision := event.Metadata["revision"]; revision != "" {
+		message += fmt.Sprintf("\n• *Revision:* `%s`", fluxRevision(revision))
+	}
+	if object.Namespace != "" {
+		message += fmt.Sprintf("\n• *Namespace:* `%s`", object.Namespace)
+	}
+	for _, key := range fluxMetadataLabels(event.Metadata) {
+		if show(key) {
+			message += fmt.Sprintf("\n• *%s:* `%s`", key, event.Metadata[key])
+		}
+	}
+	if event.ReportingController != "" && show("controller") {
+		message += fmt.Sprintf("\n• *Controller:* `%s`", event.ReportingController)
+	}
+	// Build and apply errors span many lines
+	if text := strings.TrimSpace(event.Message); text != "" {
+		if strings.Contains(text, "\n") {
+			message += fmt.Sprintf("\n• *Message:* ```%s```", text)
+		} else {
+			message += fmt.Sprintf("\n• *Message:* %s", text)
+		}
+	}
+	return message
+}
+
+// formatCompactFluxMessage renders an event as a single line
+func formatCompactFluxMessage(event FluxEvent, status string) string {
+	object := event.InvolvedObject
+	if status == alert.StatusResolved {
+		return fmt.Sprintf("✅ *%s/%s* reconciled", object.Kind, object.Name)
+	}
+	return fmt.Sprintf("🚨 *%s/%s* failed to reconcile: %s", object.Kind, object.Name, event.Reason)
+}
@@ -0,0 +1,266 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// GCPNotification is the body of a Google Cloud Monitoring webhook notification channel
+type GCPNotification struct {
+	Version  string       `json:"version"`
+	Incident *GCPIncident `json:"incident"`
+}
+
+// GCPIncident is the incident an alerting policy opened or closed
+type GCPIncident struct {
+	IncidentID          string `json:"incident_id"`
+	ScopingProjectID    string `json:"scoping_project_id"`
+	URL                 string `json:"url"`
+	StartedAt           int64  `json:"started_at"` // Unix seconds
+	EndedAt             int64  `json:"ended_at"`
+	State               string `json:"state"` // open or closed
+	Summary             string `json:"summary"`
+	ObservedValue       string `json:"observed_value"`
+	ThresholdValue      string `json:"threshold_value"`
+	ResourceDisplayName string `json:"resource_display_name"`
+	ResourceTypeDisplay string `json:"resource_type_display_name"`
+	Resource            struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+	Metric struct {
+		Type        string            `json:"type"`
+		DisplayName string            `json:"displayName"`
+		Labels      map[string]string `json:"labels"`
+	} `json:"metric"`
+	Metadata struct {
+		UserLabels map[string]string `json:"user_labels"`
+	} `json:"metadata"`
+	PolicyName       string            `json:"policy_name"`
+	PolicyUserLabels map[string]string `json:"policy_user_labels"`
+	ConditionName    string            `json:"condition_name"`
+	Condition        struct {
+		DisplayName string `json:"displayName"`
+	} `json:"condition"`
+	Documentation struct {
+		Content string `json:"content"`
+		Subject string `json:"subject"`
+	} `json:"documentation"`
+	Severity string `json:"severity"` // Critical, Error, Warning or No severity
+}
+
+// isGCPNotification reports whether body is a Cloud Monitoring incident notification
+func isGCPNotification(body string) bool {
+	var probe struct {
+		Incident *struct {
+			PolicyName string `json:"policy_name"`
+		} `json:"incident"`
+	}
+	return json.Unmarshal([]byte(body), &probe) == nil && probe.Incident != nil && probe.Incident.PolicyName != ""
+}
+
+// AdaptGCPNotification maps a Cloud Monitoring incident notification to a routed alert
+func AdaptGCPNotification(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var notification GCPNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceGCP, Err: err}
+	}
+	incident := notification.Incident
+	if incident == nil || incident.PolicyName == "" {
+		return nil, &errs.ParseError{Source: alert.SourceGCP, Err: fmt.Errorf("no incident policy_name")}
+	}
+
+	labels := gcpLabels(incident)
+	priority := determineGCPPriority(incident)
+
+	// First check if there's a specific mapping for this policy
+	channel := alarmChannels[incident.PolicyName]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := strings.ToUpper(incident.State)
+	if state == "" {
+		state = "OPEN"
+	}
+	status := alert.StatusFiring
+	if state == "CLOSED" {
+		status = alert.StatusResolved
+	}
+
+	annotations := make(map[string]string)
+	if incident.Summary != "" {
+		annotations["summary"] = incident.Summary
+	}
+	if incident.Documentation.Content != "" {
+		annotations["description"] = incident.Documentation.Content
+	}
+	if incident.ObservedValue != "" {
+		annotations[alert.AnnotationValue] = incident.ObservedValue
+	}
+	urls := make(map[string]string)
+	if incident.URL != "" {
+		urls[alert.URLSource] = incident.URL
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceGCP,
+		Name:        incident.PolicyName,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"incident_id":  incident.IncidentID,
+			"gcp_severity": incident.Severity,
+			"condition":    gcpConditionName(incident),
+		},
+		Message: formatGCPSlackMessage(incident, state, fieldShower(fields, channel)),
+		Summary: formatCompactGCPMessage(incident, state),
+		Raw:     body,
+	}
+	if incident.StartedAt > 0 {
+		startsAt := time.Unix(incident.StartedAt, 0).UTC()
+		adapted.StartsAt = &startsAt
+	}
+	if incident.EndedAt > 0 {
+		endsAt := time.Unix(incident.EndedAt, 0).UTC()
+		adapted.EndsAt = &endsAt
+	}
+	return adapted, nil
+}
+
+func gcpConditionName(incident *GCPIncident) string {
+	if incident.Condition.DisplayName != "" {
+		return incident.Condition.DisplayName
+	}
+	return incident.ConditionName
+}
+
+// gcpLabels holds the project, resource and metric type, the resource and metric labels, and
+// the user labels of the resource and the policy, which win
+func gcpLabels(incident *GCPIncident) map[string]string {
+	labels := make(map[string]string)
+	for _, set := range []map[string]string{incident.Resource.Labels, incident.Metric.Labels, incident.Metadata.UserLabels, incident.PolicyUserLabels} {
+		for k, v := range set {
+			labels[k] = v
+		}
+	}
+	for key, value := range map[string]string{
+		"project":       incident.ScopingProjectID,
+		"resource_type": incident.Resource.Type,
+		"metric":        incident.Metric.Type,
+		"condition":     gcpConditionName(incident),
+	} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// determineGCPPriority reads the priority from the policy's user labels: a channel label of
+// P0-P2 wins, then a severity label of critical (P0), error (P1) or anything else (P2). Policies
+// without one fall back to the policy's own severity level.
+func determineGCPPriority(incident *GCPIncident) string {
+	userLabels := incident.PolicyUserLabels
+	switch strings.ToUpper(userLabels["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(userLabels["channel"])
+	}
+
+	severity := userLabels["severity"]
+	if severity == "" {
+		severity = incident.Severity
+	}
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "P0"
+	case "error":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+func formatGCPSlackMessage(incident *GCPIncident, state string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Cloud Monitoring Alert: %s*\n• *State:* `%s`", stateEmoji(state), incident.PolicyName, state)
+
+	if incident.Severity != "" && incident.Severity != "No severity" {
+		message += fmt.Sprintf("\n• *Severity:* %s", incident.Severity)
+	}
+	if condition := gcpConditionName(incident); condition != "" {
+		message += fmt.Sprintf("\n• *Condition:* %s", condition)
+	}
+	if incident.ResourceDisplayName != "" {
+		message += fmt.Sprintf("\n• *Resource:* `%s`", incident.ResourceDisplayName)
+		if incident.ResourceTypeDisplay != "" {
+			message += fmt.Sprintf(" (%s)", incident.ResourceTypeDisplay)
+		}
+	}
+	if incident.ObservedValue != "" {
+		metric := incident.Metric.DisplayName
+		if metric == "" {
+			metric = incident.Metric.Type
+		}
+		message += fmt.Sprintf("\n• *Value:* `%s` = *%s*", metric, incident.ObservedValue)
+		if incident.ThresholdValue != "" {
+			message += fmt.Sprintf(" (threshold %s)", incident.ThresholdValue)
+		}
+	}
+	if incident.Summary != "" {
+		message += fmt.Sprintf("\n• *Summary:* %s", incident.Summary)
+	}
+	if incident.Documentation.Content != "" {
+		message += fmt.Sprintf("\n• *Documentation:* %s", incident.Documentation.Content)
+	}
+
+	// Skip the channel user label as it's used for routing
+	var labels []string
+	for _, set := range []map[string]string{incident.Resource.Labels, incident.Metric.Labels, incident.Metadata.UserLabels, incident.PolicyUserLabels} {
+		for k, v := range set {
+			if k != "channel" && show(k) {
+				labels = append(labels, fmt.Sprintf("\n   → `%s`: %s", k, v))
+			}
+		}
+	}
+	sort.Strings(labels)
+	if len(labels) > 0 {
+		message += "\n• *Labels:*" + strings.Join(labels, "")
+	}
+
+	if incident.URL != "" {
+		message += fmt.Sprintf("\n• *Incident:* <%s|View in Cloud Console>", incident.URL)
+	}
+	return message
+}
+
+// formatCompactGCPMessage renders a Cloud Monitoring alert as a single line
+func formatCompactGCPMessage(incident *GCPIncident, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), incident.PolicyName, state)
+	if incident.ResourceDisplayName != "" {
+		line += fmt.Sprintf(" on `%s`", incident.ResourceDisplayName)
+	}
+	if incident.ObservedValue != "" {
+		line += fmt.Sprintf(" = *%s*", incident.ObservedValue)
+	}
+	if incident.URL != "" {
+		line += fmt.Sprintf(" <%s|View>", incident.URL)
+	}
+	return line
+}
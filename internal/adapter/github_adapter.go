@@ -0,0 +1,284 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// ErrGitHubEventIgnored is wrapped by the parse errors of GitHub events that are neither a
+// failure nor a success, such as runs starting or cancelled, so the webhook can accept them
+var ErrGitHubEventIgnored = errors.New("only failed and successful workflow runs and deployments are mapped")
+
+// GitHubWebhook is the body of a workflow_run or deployment_status webhook
+type GitHubWebhook struct {
+	Action           string                  `json:"action"`
+	WorkflowRun      *GitHubWorkflowRun      `json:"workflow_run"`
+	Deployment       *GitHubDeployment       `json:"deployment"`
+	DeploymentStatus *GitHubDeploymentStatus `json:"deployment_status"`
+	Repository       struct {
+		Name          string `json:"name"`
+		FullName      string `json:"full_name"` // owner/name
+		DefaultBranch string `json:"default_branch"`
+		HTMLURL       string `json:"html_url"`
+	} `json:"repository"`
+	Sender githubUser `json:"sender"`
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// GitHubWorkflowRun is a GitHub Actions workflow run
+type GitHubWorkflowRun struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"` // the workflow's name
+	DisplayTitle string     `json:"display_title"`
+	HeadBranch   string     `json:"head_branch"`
+	HeadSHA      string     `json:"head_sha"`
+	Event        string     `json:"event"` // push, pull_request, schedule, ...
+	Status       string     `json:"status"`
+	Conclusion   string     `json:"conclusion"` // success, failure, timed_out, startup_failure, cancelled, ...
+	RunNumber    int        `json:"run_number"`
+	RunAttempt   int        `json:"run_attempt"`
+	HTMLURL      string     `json:"html_url"`
+	RunStartedAt string     `json:"run_started_at"`
+	UpdatedAt    string     `json:"updated_at"`
+	Actor        githubUser `json:"actor"`
+}
+
+// GitHubDeployment is the deployment a deployment_status webhook is about
+type GitHubDeployment struct {
+	ID                    int64      `json:"id"`
+	SHA                   string     `json:"sha"`
+	Ref                   string     `json:"ref"`
+	Environment           string     `json:"environment"`
+	ProductionEnvironment bool       `json:"production_environment"`
+	Creator               githubUser `json:"creator"`
+}
+
+// GitHubDeploymentStatus is a deployment's new state
+type GitHubDeploymentStatus struct {
+	State       string `json:"state"` // failure, error, success, in_progress, ...
+	Description string `json:"description"`
+	Environment string `json:"environment"`
+	TargetURL   string `json:"target_url"`
+	LogURL      string `json:"log_url"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// githubStatus maps a finished run or deployment to a status: failures fire, and success
+// resolves them. It reports false for anything else.
+func githubStatus(webhook GitHubWebhook) (string, string, bool) {
+	switch {
+	case webhook.WorkflowRun != nil:
+		if webhook.Action != "completed" {
+			return "", webhook.WorkflowRun.Status, false
+		}
+		switch conclusion := webhook.WorkflowRun.Conclusion; conclusion {
+		case "failure", "timed_out", "startup_failure":
+			return alert.StatusFiring, conclusion, true
+		case "success":
+			return alert.StatusResolved, conclusion, true
+		default:
+			return "", conclusion, false
+		}
+	case webhook.DeploymentStatus != nil && webhook.Deployment != nil:
+		switch state := webhook.DeploymentStatus.State; state {
+		case "failure", "error":
+			return alert.StatusFiring, state, true
+		case "success":
+			return alert.StatusResolved, state, true
+		default:
+			return "", state, false
+		}
+	}
+	return "", "", false
+}
+
+// githubEnvironment is the environment a deployment went to
+func githubEnvironment(webhook GitHubWebhook) string {
+	if env := webhook.DeploymentStatus.Environment; env != "" {
+		return env
+	}
+	return webhook.Deployment.Environment
+}
+
+// AdaptGitHubWebhook maps a failed GitHub Actions workflow run or deployment to an alert, which
+// the next successful run of the workflow on the branch, or deployment to the environment,
+// resolves. Runs on the default branch and production deployments are P1, the rest P2. Alerts
+// go to their alarm mapping, then their repository's channel, then the channel of their priority.
+func AdaptGitHubWebhook(body string, channels, alarmChannels, repoChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var webhook GitHubWebhook
+	if err := json.Unmarshal([]byte(body), &webhook); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceGitHub, Err: err}
+	}
+	if webhook.WorkflowRun == nil && webhook.DeploymentStatus == nil {
+		return nil, &errs.ParseError{Source: alert.SourceGitHub, Err: fmt.Errorf("not a workflow_run or deployment_status event")}
+	}
+	status, state, ok := githubStatus(webhook)
+	if !ok {
+		return nil, &errs.ParseError{Source: alert.SourceGitHub, Err: fmt.Errorf("%s is %s: %w", webhook.Repository.FullName, state, ErrGitHubEventIgnored)}
+	}
+
+	repo := webhook.Repository.FullName
+	labels := map[string]string{"repository": repo}
+	extensions := make(map[string]interface{})
+	urls := make(map[string]string)
+	var name, priority string
+	var at *time.Time
+	if run := webhook.WorkflowRun; run != nil {
+		name = fmt.Sprintf("%s: %s", webhook.Repository.Name, run.Name)
+		labels["workflow"] = run.Name
+		labels["branch"] = run.HeadBranch
+		priority = "P2"
+		if run.HeadBranch == webhook.Repository.DefaultBranch {
+			priority = "P1"
+		}
+		extensions["run_id"] = run.ID
+		extensions["run_number"] = run.RunNumber
+		extensions["sha"] = run.HeadSHA
+		urls[alert.URLSource] = run.HTMLURL
+		at = parseTime(time.RFC3339, run.UpdatedAt)
+	} else {
+		env := githubEnvironment(webhook)
+		name = fmt.Sprintf("%s: deploy to %s", webhook.Repository.Name, env)
+		labels["environment"] = env
+		priority = "P2"
+		if webhook.Deployment.ProductionEnvironment {
+			priority = "P1"
+		}
+		extensions["deployment_id"] = webhook.Deployment.ID
+		extensions["sha"] = webhook.Deployment.SHA
+		extensions["ref"] = webhook.Deployment.Ref
+		if target := githubDeploymentURL(webhook); target != "" {
+			urls[alert.URLSource] = target
+		}
+		at = parseTime(time.RFC3339, webhook.DeploymentStatus.CreatedAt)
+	}
+
+	channel := alarmChannels[name]
+	if channel == "" {
+		channel = repoChannels[repo]
+	}
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	annotations := make(map[string]string)
+	if webhook.DeploymentStatus != nil && webhook.DeploymentStatus.Description != "" {
+		annotations["description"] = webhook.DeploymentStatus.Description
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceGitHub,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Raw:         body,
+		Extensions:  extensions,
+		Message:     formatGitHubSlackMessage(webhook, name, status, state, fieldShower(fields, channel)),
+		Summary:     formatCompactGitHubMessage(webhook, name, status, state),
+	}
+	if status == alert.StatusResolved {
+		adapted.EndsAt = at
+	} else {
+		adapted.StartsAt = at
+	}
+	return adapted, nil
+}
+
+// githubDeploymentURL links the deployment's logs, or else its status target
+func githubDeploymentURL(webhook GitHubWebhook) string {
+	if webhook.DeploymentStatus.LogURL != "" {
+		return webhook.DeploymentStatus.LogURL
+	}
+	return webhook.DeploymentStatus.TargetURL
+}
+
+// githubOutcome is e.g. "failed", "timed out" or "succeeded"
+func githubOutcome(status, state string) string {
+	switch {
+	case status == alert.StatusResolved:
+		return "succeeded"
+	case state == "failure", state == "error":
+		return "failed"
+	}
+	return strings.ReplaceAll(state, "_", " ")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func formatGitHubSlackMessage(webhook GitHubWebhook, name, status, state string, show func(string) bool) string {
+	emoji := "🚨"
+	if status == alert.StatusResolved {
+		emoji = "✅"
+	}
+
+	if run := webhook.WorkflowRun; run != nil {
+		message := fmt.Sprintf("%s *Workflow %s: %s*\n• *Repository:* *`%s`*\n• *Workflow:* `%s` #%d", emoji, githubOutcome(status, state), name, webhook.Repository.FullName, run.Name, run.RunNumber)
+		if run.RunAttempt > 1 {
+			message += fmt.Sprintf(" (attempt %d)", run.RunAttempt)
+		}
+		message += fmt.Sprintf("\n• *Branch:* `%s`", run.HeadBranch)
+		if run.DisplayTitle != "" {
+			message += fmt.Sprintf("\n• *Commit:* `%s` %s", shortSHA(run.HeadSHA), run.DisplayTitle)
+		}
+		if run.Event != "" && show("event") {
+			message += fmt.Sprintf("\n• *Trigger:* `%s`", run.Event)
+		}
+		if run.Actor.Login != "" && show("actor") {
+			message += fmt.Sprintf("\n• *Actor:* `%s`", run.Actor.Login)
+		}
+		return message + fmt.Sprintf("\n• *Run:* <%s|View in GitHub Actions>", run.HTMLURL)
+	}
+
+	deployment := webhook.Deployment
+	message := fmt.Sprintf("%s *Deployment %s: %s*\n• *Repository:* *`%s`*\n• *Environment:* `%s`", emoji, githubOutcome(status, state), name, webhook.Repository.FullName, githubEnvironment(webhook))
+	message += fmt.Sprintf("\n• *Ref:* `%s` (`%s`)", deployment.Ref, shortSHA(deployment.SHA))
+	if description := webhook.DeploymentStatus.Description; description != "" {
+		message += fmt.Sprintf("\n• *Description:* %s", description)
+	}
+	if deployment.Creator.Login != "" && show("actor") {
+		message += fmt.Sprintf("\n• *Deployed by:* `%s`", deployment.Creator.Login)
+	}
+	if target := githubDeploymentURL(webhook); target != "" {
+		message += fmt.Sprintf("\n• *Logs:* <%s|View deployment>", target)
+	}
+	return message
+}
+
+// formatCompactGitHubMessage renders a workflow run or deployment as a single line
+func formatCompactGitHubMessage(webhook GitHubWebhook, name, status, state string) string {
+	emoji := "🚨"
+	if status == alert.StatusResolved {
+		emoji = "✅"
+	}
+	line := fmt.Sprintf("%s *%s* %s", emoji, name, githubOutcome(status, state))
+	if run := webhook.WorkflowRun; run != nil {
+		return line + fmt.Sprintf(" on `%s` <%s|View>", run.HeadBranch, run.HTMLURL)
+	}
+	if target := githubDeploymentURL(webhook); target != "" {
+		line += fmt.Sprintf(" <%s|View>", target)
+	}
+	return line
+}
@@ -0,0 +1,202 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// ErrGitLabEventIgnored is wrapped by the parse errors of pipelines that neither failed nor
+// succeeded, such as running or canceled ones, so the webhook can accept them
+var ErrGitLabEventIgnored = errors.New("only failed and successful pipelines are mapped")
+
+// gitlabTimeLayout is how GitLab formats times in pipeline events
+const gitlabTimeLayout = "2006-01-02 15:04:05 MST"
+
+// GitLabPipelineEvent is the body of a GitLab pipeline webhook
+type GitLabPipelineEvent struct {
+	ObjectKind       string `json:"object_kind"`
+	ObjectAttributes struct {
+		ID         int64  `json:"id"`
+		IID        int64  `json:"iid"`
+		Name       string `json:"name"` // set by workflow:name, usually empty
+		Ref        string `json:"ref"`
+		Tag        bool   `json:"tag"`
+		SHA        string `json:"sha"`
+		Source     string `json:"source"` // push, merge_request_event, schedule, ...
+		Status     string `json:"status"` // failed, success, canceled, running, ...
+		Duration   int    `json:"duration"`
+		CreatedAt  string `json:"created_at"`
+		FinishedAt string `json:"finished_at"`
+		URL        string `json:"url"`
+	} `json:"object_attributes"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project struct {
+		Name              string `json:"name"`
+		PathWithNamespace string `json:"path_with_namespace"` // group/project
+		DefaultBranch     string `json:"default_branch"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+	Commit struct {
+		Title string `json:"title"`
+	} `json:"commit"`
+	Builds []struct {
+		ID            int64  `json:"id"`
+		Stage         string `json:"stage"`
+		Name          string `json:"name"`
+		Status        string `json:"status"`
+		AllowFailure  bool   `json:"allow_failure"`
+		FailureReason string `json:"failure_reason"`
+	} `json:"builds"`
+}
+
+// gitlabFailedJobs lists the failed jobs that fail the pipeline, e.g. "test: rspec (script_failure)"
+func gitlabFailedJobs(event GitLabPipelineEvent) []string {
+	var jobs []string
+	for _, build := range event.Builds {
+		if build.Status != "failed" || build.AllowFailure {
+			continue
+		}
+		job := build.Stage + ": " + build.Name
+		if build.FailureReason != "" {
+			job += " (" + build.FailureReason + ")"
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// AdaptGitLabPipeline maps a failed GitLab pipeline to an alert named after the project, which
+// the next successful pipeline for the ref resolves. Pipelines for the default branch are P1,
+// the rest P2. Alerts go to their alarm mapping, then their project's channel, then the
+// channel of their priority.
+func AdaptGitLabPipeline(body string, channels, alarmChannels, projectChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var event GitLabPipelineEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceGitLab, Err: err}
+	}
+	if event.ObjectKind != "pipeline" {
+		return nil, &errs.ParseError{Source: alert.SourceGitLab, Err: fmt.Errorf("not a pipeline event: %q", event.ObjectKind)}
+	}
+
+	pipeline := event.ObjectAttributes
+	project := event.Project.PathWithNamespace
+	var status string
+	switch pipeline.Status {
+	case "failed":
+		status = alert.StatusFiring
+	case "success":
+		status = alert.StatusResolved
+	default:
+		return nil, &errs.ParseError{Source: alert.SourceGitLab, Err: fmt.Errorf("pipeline %d of %s is %s: %w", pipeline.ID, project, pipeline.Status, ErrGitLabEventIgnored)}
+	}
+
+	name := event.Project.Name + " pipeline"
+	if pipeline.Name != "" {
+		name = fmt.Sprintf("%s: %s", event.Project.Name, pipeline.Name)
+	}
+	priority := "P2"
+	if !pipeline.Tag && pipeline.Ref == event.Project.DefaultBranch {
+		priority = "P1"
+	}
+
+	channel := alarmChannels[name]
+	if channel == "" {
+		channel = projectChannels[project]
+	}
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	labels := map[string]string{"project": project, "ref": pipeline.Ref}
+	annotations := make(map[string]string)
+	if jobs := gitlabFailedJobs(event); len(jobs) > 0 {
+		annotations["description"] = "Failed jobs: " + strings.Join(jobs, ", ")
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceGitLab,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       pipeline.Status,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: gitlabPipelineURL(event)},
+		ReceivedAt:  time.Now(),
+		Raw:         body,
+		Extensions: map[string]interface{}{
+			"pipeline_id": pipeline.ID,
+			"sha":         pipeline.SHA,
+		},
+		Message: formatGitLabSlackMessage(event, name, status, fieldShower(fields, channel)),
+		Summary: formatCompactGitLabMessage(event, name, status),
+	}
+	if status == alert.StatusResolved {
+		adapted.EndsAt = parseTime(gitlabTimeLayout, pipeline.FinishedAt)
+	} else {
+		adapted.StartsAt = parseTime(gitlabTimeLayout, pipeline.FinishedAt)
+	}
+	return adapted, nil
+}
+
+// gitlabPipelineURL links the pipeline, built from the project's URL for events without one
+func gitlabPipelineURL(event GitLabPipelineEvent) string {
+	if event.ObjectAttributes.URL != "" {
+		return event.ObjectAttributes.URL
+	}
+	return fmt.Sprintf("%s/-/pipelines/%d", event.Project.WebURL, event.ObjectAttributes.ID)
+}
+
+func formatGitLabSlackMessage(event GitLabPipelineEvent, name, status string, show func(string) bool) string {
+	pipeline := event.ObjectAttributes
+	emoji, outcome := "🚨", "failed"
+	if status == alert.StatusResolved {
+		emoji, outcome = "✅", "succeeded"
+	}
+	message := fmt.Sprintf("%s *Pipeline %s: %s*\n• *Project:* *`%s`*\n• *Pipeline:* #%d", emoji, outcome, name, event.Project.PathWithNamespace, pipeline.IID)
+	if pipeline.Duration > 0 {
+		message += fmt.Sprintf(" after %s", time.Duration(pipeline.Duration)*time.Second)
+	}
+	ref := "Branch"
+	if pipeline.Tag {
+		ref = "Tag"
+	}
+	message += fmt.Sprintf("\n• *%s:* `%s`", ref, pipeline.Ref)
+	if event.Commit.Title != "" {
+		message += fmt.Sprintf("\n• *Commit:* `%s` %s", shortSHA(pipeline.SHA), event.Commit.Title)
+	}
+	if jobs := gitlabFailedJobs(event); len(jobs) > 0 {
+		message += "\n• *Failed jobs:*"
+		for _, job := range jobs {
+			message += fmt.Sprintf("\n   → `%s`", job)
+		}
+	}
+	if pipeline.Source != "" && show("source") {
+		message += fmt.Sprintf("\n• *Trigger:* `%s`", pipeline.Source)
+	}
+	if event.User.Username != "" && show("user") {
+		message += fmt.Sprintf("\n• *User:* `%s`", event.User.Username)
+	}
+	return message + fmt.Sprintf("\n• *Details:* <%s|View in GitLab>", gitlabPipelineURL(event))
+}
+
+// formatCompactGitLabMessage renders a pipeline as a single line
+func formatCompactGitLabMessage(event GitLabPipelineEvent, name, status string) string {
+	emoji, outcome := "🚨", "failed"
+	if status == alert.StatusResolved {
+		emoji, outcome = "✅", "succeeded"
+	}
+	return fmt.Sprintf("%s *%s* %s on `%s` <%s|View>", emoji, name, outcome, event.ObjectAttributes.Ref, gitlabPipelineURL(event))
+}
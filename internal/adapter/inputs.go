@@ -0,0 +1,97 @@
+package adapter
+
+import "alert-dispatcher/internal/alert"
+
+// Webhook parses a request body into a routed alert. objectChannels route the input's own
+// objects, such as Sentry projects or ArgoCD applications, for inputs that have them.
+type Webhook func(body string, channels, alarmChannels, objectChannels map[string]string, fields FieldFilter) (*alert.Alert, error)
+
+// Input is somewhere alerts arrive: the SQS queue, a built-in webhook or the Alertmanager API
+type Input struct {
+	Name    string   // the heartbeat input, and for webhooks the path /<name>/webhook
+	Sources []string // sources of the alerts it produces
+	Webhook Webhook  // parses its webhook's requests; nil for inputs that aren't webhooks
+	// Configurable webhooks' adapters can also be used by configured webhooks; the rest rely on
+	// request headers or only post recoveries from failures
+	Configurable bool
+}
+
+// Inputs are every input the dispatcher has. Lint, the server and configured webhooks all go
+// by this list, so a new input or source only needs adding here.
+var Inputs = []Input{
+	{Name: "sqs", Sources: []string{
+		alert.SourceCloudWatch, alert.SourceAWSCost, alert.SourceCloudTrail, alert.SourceECS,
+		alert.SourceRDS, alert.SourceAWSBackup, alert.SourceLambda, alert.SourceCodeStar,
+	}},
+	{Name: "grafana", Sources: []string{
+		alert.SourceGrafana, alert.SourceAlertmanager, alert.SourceNewRelic, alert.SourceAzure, alert.SourceGCP,
+	}, Webhook: withoutObjects(AdaptWebhook), Configurable: true},
+	{Name: "datadog", Sources: []string{alert.SourceDatadog}, Webhook: withoutObjects(AdaptDatadogWebhook), Configurable: true},
+	{Name: "sentry", Sources: []string{alert.SourceSentry}, Webhook: AdaptSentryWebhook, Configurable: true},
+	{Name: "zabbix", Sources: []string{alert.SourceZabbix}, Webhook: withoutObjects(AdaptZabbixWebhook), Configurable: true},
+	{Name: "nagios", Sources: []string{alert.SourceNagios}, Webhook: withoutObjects(AdaptNagiosNotification), Configurable: true},
+	{Name: "dynatrace", Sources: []string{alert.SourceDynatrace}, Webhook: AdaptDynatraceProblem, Configurable: true},
+	{Name: "splunk", Sources: []string{alert.SourceSplunk}, Webhook: withoutObjects(AdaptSplunkAlert), Configurable: true},
+	{Name: "kibana", Sources: []string{alert.SourceKibana}, Webhook: withoutObjects(AdaptKibanaAlert), Configurable: true},
+	{Name: "uptimekuma", Sources: []string{alert.SourceUptimeKuma}, Webhook: withoutObjects(AdaptUptimeKumaNotification), Configurable: true},
+	{Name: "pingdom", Sources: []string{alert.SourcePingdom}, Webhook: withoutObjects(AdaptPingdomAlert), Configurable: true},
+	{Name: "statuscake", Sources: []string{alert.SourceStatusCake}, Webhook: withoutObjects(AdaptStatusCakeAlert), Configurable: true},
+	{Name: "github", Sources: []string{alert.SourceGitHub}, Webhook: AdaptGitHubWebhook},
+	{Name: "gitlab", Sources: []string{alert.SourceGitLab}, Webhook: AdaptGitLabPipeline},
+	{Name: "argocd", Sources: []string{alert.SourceArgoCD}, Webhook: AdaptArgoCDNotification},
+	{Name: "flux", Sources: []string{alert.SourceFlux}, Webhook: AdaptFluxEvent},
+	{Name: "alertmanager_api", Sources: []string{alert.SourceAlertmanager}},
+}
+
+// withoutObjects adapts a webhook adapter that routes by priorities and alarm mappings alone
+func withoutObjects(adapt func(body string, channels, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error)) Webhook {
+	return func(body string, channels, alarmChannels, _ map[string]string, fields FieldFilter) (*alert.Alert, error) {
+		return adapt(body, channels, alarmChannels, fields)
+	}
+}
+
+// InputNames are the names of every input, which heartbeats can watch
+func InputNames() []string {
+	names := make([]string, 0, len(Inputs))
+	for _, input := range Inputs {
+		names = append(names, input.Name)
+	}
+	return names
+}
+
+// ConfigurableWebhooks are the names of the adapters configured webhooks can use
+func ConfigurableWebhooks() []string {
+	var names []string
+	for _, input := range Inputs {
+		if input.Configurable {
+			names = append(names, input.Name)
+		}
+	}
+	return names
+}
+
+// Sources are the sources of every input's alerts, and of the alerts the dispatcher raises
+// about itself
+func Sources() []string {
+	seen := make(map[string]bool)
+	var sources []string
+	for _, input := range Inputs {
+		for _, source := range input.Sources {
+			if !seen[source] {
+				seen[source] = true
+				sources = append(sources, source)
+			}
+		}
+	}
+	return append(sources, alert.SourceDispatcher)
+}
+
+// LookupInput returns the input with this name
+func LookupInput(name string) (Input, bool) {
+	for _, input := range Inputs {
+		if input.Name == name {
+			return input, true
+		}
+	}
+	return Input{}, false
+}
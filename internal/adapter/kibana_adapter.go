@@ -0,0 +1,255 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// KibanaAlert is the body of a Kibana alerting webhook connector action using the payload in
+// the README
+type KibanaAlert struct {
+	Rule struct {
+		ID      string   `json:"id"`
+		Name    string   `json:"name"`
+		Type    string   `json:"type"` // e.g. .es-query, metrics.alert.threshold, logs.alert.document.count
+		Tags    []string `json:"tags"`
+		SpaceID string   `json:"spaceId"`
+		URL     string   `json:"url"`
+	} `json:"rule"`
+	Alert struct {
+		ID              string `json:"id"` // the alert instance, e.g. the host of a per-host rule
+		UUID            string `json:"uuid"`
+		ActionGroup     string `json:"actionGroup"` // e.g. query matched, metrics.threshold.fired, recovered
+		ActionGroupName string `json:"actionGroupName"`
+		Flapping        bool   `json:"flapping"`
+	} `json:"alert"`
+	Context       map[string]json.RawMessage `json:"context"` // rule type specific; reason is common to all
+	Date          string                     `json:"date"`
+	KibanaBaseURL string                     `json:"kibanaBaseUrl"`
+}
+
+// contextString returns a context variable as text, without the quotes of strings
+func (k KibanaAlert) contextString(key string) string {
+	value := rawValue(k.Context[key])
+	if value == "null" {
+		return ""
+	}
+	return value
+}
+
+// AdaptKibanaAlert maps a Kibana alerting rule action to a routed alert
+func AdaptKibanaAlert(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var kbAlert KibanaAlert
+	if err := json.Unmarshal([]byte(body), &kbAlert); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceKibana, Err: err}
+	}
+	if kbAlert.Rule.Name == "" {
+		return nil, &errs.ParseError{Source: alert.SourceKibana, Err: fmt.Errorf("no rule name")}
+	}
+
+	tags := kibanaTags(kbAlert.Rule.Tags)
+	priority := determineKibanaPriority(kbAlert.Rule.Tags, tags)
+
+	// First check if there's a specific mapping for this rule
+	channel := alarmChannels[kbAlert.Rule.Name]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := kibanaState(kbAlert.Alert.ActionGroup)
+	status := alert.StatusFiring
+	if state == "RECOVERED" {
+		status = alert.StatusResolved
+	}
+
+	labels := make(map[string]string, len(tags)+3)
+	for k, v := range tags {
+		labels[k] = v
+	}
+	if kbAlert.Alert.ID != "" {
+		labels["alert_id"] = kbAlert.Alert.ID
+	}
+	if kbAlert.Rule.Type != "" {
+		labels["rule_type"] = kbAlert.Rule.Type
+	}
+	if kbAlert.Rule.SpaceID != "" && kbAlert.Rule.SpaceID != "default" {
+		labels["space"] = kbAlert.Rule.SpaceID
+	}
+
+	annotations := make(map[string]string)
+	if reason := kbAlert.contextString("reason"); reason != "" {
+		annotations["description"] = reason
+	}
+	if value := kbAlert.contextString("value"); value != "" {
+		annotations[alert.AnnotationValue] = value
+	}
+	urls := make(map[string]string)
+	if link := kibanaAlertLink(kbAlert); link != "" {
+		urls[alert.URLSource] = link
+	}
+	if link := kbAlert.contextString("viewInAppUrl"); link != "" {
+		urls[alert.URLDashboard] = link
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceKibana,
+		Name:        kbAlert.Rule.Name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"rule_id":      kbAlert.Rule.ID,
+			"alert_uuid":   kbAlert.Alert.UUID,
+			"action_group": kbAlert.Alert.ActionGroup,
+			"flapping":     kbAlert.Alert.Flapping,
+		},
+		Message: formatKibanaSlackMessage(kbAlert, state, tags, fieldShower(fields, channel)),
+		Summary: formatCompactKibanaMessage(kbAlert, state),
+		Raw:     body,
+	}
+	return adapted, nil
+}
+
+// kibanaState normalizes action groups: recovered to RECOVERED, no data groups to NO_DATA,
+// warning groups to WARN and the rest to FIRING
+func kibanaState(actionGroup string) string {
+	group := strings.ToLower(actionGroup)
+	switch {
+	case group == "recovered":
+		return "RECOVERED"
+	case strings.Contains(group, "nodata") || strings.Contains(group, "no data"):
+		return "NO_DATA"
+	case strings.Contains(group, "warning"):
+		return "WARN"
+	default:
+		return "FIRING"
+	}
+}
+
+// kibanaTags turns "key:value" rule tags into labels; other tags get an empty value. Priority
+// tags are left out, as they are only used for routing.
+func kibanaTags(ruleTags []string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range ruleTags {
+		switch strings.ToUpper(tag) {
+		case "P0", "P1", "P2":
+			continue
+		}
+		key, value, _ := strings.Cut(tag, ":")
+		if key = strings.TrimSpace(key); key != "" {
+			tags[key] = strings.TrimSpace(value)
+		}
+	}
+	return tags
+}
+
+// determineKibanaPriority reads the priority from the rule's tags: a P0, P1 or P2 tag, or a
+// channel:P0-P2 one. Kibana rules have no severity, so everything else is P2.
+func determineKibanaPriority(ruleTags []string, tags map[string]string) string {
+	switch strings.ToUpper(tags["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(tags["channel"])
+	}
+	for _, tag := range ruleTags {
+		switch strings.ToUpper(tag) {
+		case "P0", "P1", "P2":
+			return strings.ToUpper(tag)
+		}
+	}
+	return "P2"
+}
+
+// kibanaAlertLink opens the alert's details, or else the rule, in Kibana
+func kibanaAlertLink(kbAlert KibanaAlert) string {
+	if link := kbAlert.contextString("alertDetailsUrl"); link != "" {
+		return link
+	}
+	if kbAlert.Rule.URL != "" {
+		return kbAlert.Rule.URL
+	}
+	if kbAlert.KibanaBaseURL != "" && kbAlert.Rule.ID != "" {
+		return fmt.Sprintf("%s/app/management/insightsAndAlerting/triggersActions/rule/%s", strings.TrimRight(kbAlert.KibanaBaseURL, "/"), kbAlert.Rule.ID)
+	}
+	return ""
+}
+
+func formatKibanaSlackMessage(kbAlert KibanaAlert, state string, tags map[string]string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Kibana Alert: %s*\n• *State:* `%s`", stateEmoji(state), kbAlert.Rule.Name, state)
+
+	if group := kbAlert.Alert.ActionGroupName; group != "" && !strings.EqualFold(group, state) {
+		message += fmt.Sprintf("\n• *Action group:* %s", group)
+	}
+	if kbAlert.Alert.ID != "" {
+		message += fmt.Sprintf("\n• *Alert:* `%s`", kbAlert.Alert.ID)
+	}
+	if kbAlert.Rule.Type != "" {
+		message += fmt.Sprintf("\n• *Rule type:* `%s`", kbAlert.Rule.Type)
+	}
+	if reason := kbAlert.contextString("reason"); reason != "" {
+		message += fmt.Sprintf("\n• *Reason:* %s", reason)
+	}
+	if value := kbAlert.contextString("value"); value != "" {
+		message += fmt.Sprintf("\n• *Value:* *%s*", value)
+	}
+	if conditions := kbAlert.contextString("conditions"); conditions != "" {
+		message += fmt.Sprintf("\n• *Conditions:* `%s`", conditions)
+	}
+	if kbAlert.Alert.Flapping {
+		message += "\n• *Flapping:* yes"
+	}
+
+	// Skip the channel tag as it's used for routing
+	var keys []string
+	for k := range tags {
+		if k != "channel" && show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Tags:*"
+		for _, k := range keys {
+			if tags[k] == "" {
+				message += fmt.Sprintf("\n   → `%s`", k)
+			} else {
+				message += fmt.Sprintf("\n   → `%s`: %s", k, tags[k])
+			}
+		}
+	}
+
+	if link := kibanaAlertLink(kbAlert); link != "" {
+		message += fmt.Sprintf("\n• *Details:* <%s|View in Kibana>", link)
+	}
+	if link := kbAlert.contextString("viewInAppUrl"); link != "" {
+		message += fmt.Sprintf("\n• *Data:* <%s|View in app>", link)
+	}
+	return message
+}
+
+// formatCompactKibanaMessage renders a Kibana alert as a single line
+func formatCompactKibanaMessage(kbAlert KibanaAlert, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), kbAlert.Rule.Name, state)
+	if kbAlert.Alert.ID != "" && kbAlert.Alert.ID != kbAlert.Rule.Name {
+		line += fmt.Sprintf(" for `%s`", kbAlert.Alert.ID)
+	}
+	if link := kibanaAlertLink(kbAlert); link != "" {
+		line += fmt.Sprintf(" <%s|View>", link)
+	}
+	return line
+}
@@ -0,0 +1,297 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// lambdaFailureDetailType is the detail-type of the EventBridge events of an on-failure destination
+const lambdaFailureDetailType = "Lambda Function Invocation Result - Failure"
+
+// lambdaDeadLettered is the condition of DLQ messages, which don't say why they failed for good
+const lambdaDeadLettered = "DeadLettered"
+
+// lambdaPayloadLimit caps the request payload shown in messages
+const lambdaPayloadLimit = 500
+
+// LambdaFailure is a failed asynchronous Lambda invocation, from an on-failure destination
+// record or a message sent to the function's dead-letter queue
+type LambdaFailure struct {
+	FunctionName string
+	FunctionARN  string
+	Qualifier    string // the version or alias invoked, e.g. $LATEST
+	RequestID    string
+	Condition    string // RetriesExhausted, EventAgeExceeded or DeadLettered
+	Attempts     int
+	ErrorType    string // e.g. Runtime.ExitError or the exception class
+	ErrorMessage string
+	ErrorCode    string // DLQ messages only: 200 for function errors, 429 for throttling
+	Payload      string // the event the function was invoked with
+	Time         *time.Time
+	Account      string
+	Region       string
+	DLQ          string // the DLQ topic the message came through, if any
+}
+
+// lambdaInvocationRecord is the record an asynchronous invocation sends to its destinations
+type lambdaInvocationRecord struct {
+	Timestamp      string `json:"timestamp"`
+	RequestContext struct {
+		RequestID              string `json:"requestId"`
+		FunctionArn            string `json:"functionArn"`
+		Condition              string `json:"condition"`
+		ApproximateInvokeCount int    `json:"approximateInvokeCount"`
+	} `json:"requestContext"`
+	RequestPayload  json.RawMessage `json:"requestPayload"`
+	ResponseContext struct {
+		StatusCode      int    `json:"statusCode"`
+		ExecutedVersion string `json:"executedVersion"`
+		FunctionError   string `json:"functionError"`
+	} `json:"responseContext"`
+	ResponsePayload json.RawMessage `json:"responsePayload"`
+}
+
+// lambdaSNSMessage is an SNS notification, with the attributes Lambda sets on the messages it
+// sends to an SNS dead-letter queue
+type lambdaSNSMessage struct {
+	TopicArn          string `json:"TopicArn"`
+	Message           string `json:"Message"`
+	Timestamp         string `json:"Timestamp"`
+	MessageAttributes map[string]struct {
+		Value string `json:"Value"`
+	} `json:"MessageAttributes"`
+}
+
+// parseLambdaFailure reads a failed invocation from an SQS body: an on-failure destination
+// record, directly, from EventBridge or wrapped in SNS, or a message Lambda sent to an SNS
+// dead-letter queue. It reports false for anything else.
+func parseLambdaFailure(body string) (LambdaFailure, bool) {
+	var record lambdaInvocationRecord
+	if err := json.Unmarshal([]byte(body), &record); err != nil {
+		return LambdaFailure{}, false
+	}
+	if record.RequestContext.FunctionArn != "" && record.RequestContext.Condition != "" {
+		return lambdaDestinationFailure(record)
+	}
+
+	var bridged struct {
+		DetailType string                 `json:"detail-type"`
+		Source     string                 `json:"source"`
+		Detail     lambdaInvocationRecord `json:"detail"`
+	}
+	if err := json.Unmarshal([]byte(body), &bridged); err == nil && bridged.Source == "lambda" && bridged.DetailType == lambdaFailureDetailType {
+		return lambdaDestinationFailure(bridged.Detail)
+	}
+
+	var envelope lambdaSNSMessage
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || envelope.TopicArn == "" {
+		return LambdaFailure{}, false
+	}
+	if requestID, ok := envelope.MessageAttributes["RequestID"]; ok {
+		if errorCode, ok := envelope.MessageAttributes["ErrorCode"]; ok {
+			topic := envelope.TopicArn[strings.LastIndex(envelope.TopicArn, ":")+1:]
+			failure := LambdaFailure{
+				FunctionName: lambdaDLQFunctionName(topic),
+				RequestID:    requestID.Value,
+				Condition:    lambdaDeadLettered,
+				ErrorMessage: envelope.MessageAttributes["ErrorMessage"].Value,
+				ErrorCode:    errorCode.Value,
+				Payload:      envelope.Message,
+				Time:         parseTime(time.RFC3339, envelope.Timestamp),
+				DLQ:          topic,
+			}
+			// arn:aws:sns:eu-west-1:123456789012:orders-processor-dlq
+			if parts := strings.Split(envelope.TopicArn, ":"); len(parts) == 6 {
+				failure.Region, failure.Account = parts[3], parts[4]
+			}
+			return failure, true
+		}
+	}
+	if !strings.HasPrefix(strings.TrimSpace(envelope.Message), "{") {
+		return LambdaFailure{}, false
+	}
+	return parseLambdaFailure(envelope.Message)
+}
+
+// lambdaDestinationFailure reads a destination record, which is a success record when sent to
+// an on-success destination
+func lambdaDestinationFailure(record lambdaInvocationRecord) (LambdaFailure, bool) {
+	// arn:aws:lambda:eu-west-1:123456789012:function:orders-processor:$LATEST
+	parts := strings.SplitN(record.RequestContext.FunctionArn, ":", 8)
+	if len(parts) < 7 {
+		return LambdaFailure{}, false
+	}
+	failure := LambdaFailure{
+		FunctionName: parts[6],
+		FunctionARN:  record.RequestContext.FunctionArn,
+		RequestID:    record.RequestContext.RequestID,
+		Condition:    record.RequestContext.Condition,
+		Attempts:     record.RequestContext.ApproximateInvokeCount,
+		ErrorType:    record.ResponseContext.FunctionError,
+		Payload:      string(record.RequestPayload),
+		Time:         parseTime(time.RFC3339, record.Timestamp),
+		Account:      parts[4],
+		Region:       parts[3],
+	}
+	if len(parts) == 8 {
+		failure.Qualifier = parts[7]
+	}
+
+	var response struct {
+		ErrorType    string `json:"errorType"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := json.Unmarshal(record.ResponsePayload, &response); err == nil {
+		if response.ErrorType != "" {
+			failure.ErrorType = response.ErrorType
+		}
+		failure.ErrorMessage = response.ErrorMessage
+	}
+	return failure, true
+}
+
+// lambdaDLQFunctionName is the function a DLQ topic belongs to, by the convention of naming it
+// after the function with a -dlq suffix; other topics name the alert themselves
+func lambdaDLQFunctionName(topic string) string {
+	for _, suffix := range []string{"-dlq", "_dlq", "-DLQ", "_DLQ"} {
+		if name, ok := strings.CutSuffix(topic, suffix); ok && name != "" {
+			return name
+		}
+	}
+	return topic
+}
+
+// lambdaFailureReason describes why an invocation failed for good
+func lambdaFailureReason(failure LambdaFailure) string {
+	switch failure.Condition {
+	case "RetriesExhausted":
+		if failure.Attempts > 0 {
+			return fmt.Sprintf("failed after %d attempts", failure.Attempts)
+		}
+		return "failed after its retries"
+	case "EventAgeExceeded":
+		return "expired before it could run"
+	case lambdaDeadLettered:
+		if failure.ErrorCode == "429" {
+			return "throttled and sent to its DLQ"
+		}
+		return "failed and sent to its DLQ"
+	}
+	return strings.ToLower(failure.Condition)
+}
+
+// adaptLambdaFailure maps a failed invocation to a P1 alert named after the function, routed by
+// its alarm mapping, then the P1 channel. Every failure fires on its own; nothing resolves them.
+func adaptLambdaFailure(failure LambdaFailure, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	if failure.Condition == "Success" {
+		return nil, &errs.ParseError{Source: alert.SourceLambda, Err: fmt.Errorf("invocation %s of %s succeeded, only failures are mapped", failure.RequestID, failure.FunctionName)}
+	}
+
+	priority := "P1"
+	channel := alarmChannels[failure.FunctionName]
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	labels := map[string]string{"condition": failure.Condition}
+	if failure.ErrorType != "" {
+		labels["error_type"] = failure.ErrorType
+	}
+	if failure.Account != "" {
+		labels["account"] = failure.Account
+	}
+	if failure.Region != "" {
+		labels["region"] = failure.Region
+	}
+	annotations := make(map[string]string)
+	if failure.ErrorMessage != "" {
+		annotations["description"] = failure.ErrorMessage
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceLambda,
+		Name:        failure.FunctionName,
+		Severity:    priority,
+		Status:      alert.StatusFiring,
+		State:       failure.Condition,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        map[string]string{alert.URLSource: lambdaConsoleURL(failure)},
+		ReceivedAt:  time.Now(),
+		Raw:         body,
+		Extensions: map[string]interface{}{
+			"request_id":   failure.RequestID,
+			"function_arn": failure.FunctionARN,
+		},
+		Message: formatLambdaSlackMessage(failure, fieldShower(fields, channel)),
+		Summary: formatCompactLambdaMessage(failure),
+	}
+	adapted.StartsAt = failure.Time
+	return adapted, nil
+}
+
+// lambdaConsoleURL links the function's monitoring tab in the Lambda console
+func lambdaConsoleURL(failure LambdaFailure) string {
+	region := failure.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/lambda/home?region=%s#/functions/%s?tab=monitoring", region, region, failure.FunctionName)
+}
+
+func formatLambdaSlackMessage(failure LambdaFailure, show func(string) bool) string {
+	message := fmt.Sprintf("🚨 *Lambda invocation %s: %s*\n• *Function:* *`%s`*", lambdaFailureReason(failure), failure.FunctionName, failure.FunctionName)
+	if failure.Qualifier != "" {
+		message += fmt.Sprintf(" (`%s`)", failure.Qualifier)
+	}
+	if failure.ErrorType != "" {
+		message += fmt.Sprintf("\n• *Error:* `%s`", failure.ErrorType)
+	}
+	if failure.ErrorMessage != "" {
+		message += fmt.Sprintf("\n• *Message:* %s", failure.ErrorMessage)
+	}
+	if failure.ErrorCode != "" {
+		message += fmt.Sprintf("\n• *Error code:* `%s`", failure.ErrorCode)
+	}
+	message += fmt.Sprintf("\n• *Request ID:* `%s`", failure.RequestID)
+	if failure.DLQ != "" && show("dlq") {
+		message += fmt.Sprintf("\n• *DLQ:* `%s`", failure.DLQ)
+	}
+	if failure.Account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", failure.Account)
+	}
+	if failure.Region != "" && show("region") {
+		message += fmt.Sprintf("\n• *Region:* `%s`", failure.Region)
+	}
+	if failure.Time != nil {
+		message += fmt.Sprintf("\n• *Time:* %s", failure.Time.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	if payload := strings.TrimSpace(failure.Payload); payload != "" && payload != "null" && show("payload") {
+		if len(payload) > lambdaPayloadLimit {
+			payload = payload[:lambdaPayloadLimit] + "…"
+		}
+		message += fmt.Sprintf("\n• *Payload:* ```%s```", payload)
+	}
+	return message + fmt.Sprintf("\n• *Console:* <%s|View in Lambda>", lambdaConsoleURL(failure))
+}
+
+// formatCompactLambdaMessage renders a failed invocation as a single line
+func formatCompactLambdaMessage(failure LambdaFailure) string {
+	line := fmt.Sprintf("🚨 *%s* invocation %s", failure.FunctionName, lambdaFailureReason(failure))
+	if failure.ErrorType != "" {
+		line += fmt.Sprintf(" `%s`", failure.ErrorType)
+	}
+	if failure.ErrorMessage != "" {
+		line += ": " + failure.ErrorMessage
+	}
+	return line
+}
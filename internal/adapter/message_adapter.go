@@ -3,8 +3,14 @@ package adapter
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
 )
 
 type CloudWatchAlarm struct {
@@ -17,21 +23,57 @@ type CloudWatchAlarm struct {
 	StateChangeTime  string  `json:"StateChangeTime"`
 	Region           string  `json:"Region"`
 	AlarmArn         string  `json:"AlarmArn"`
-	Trigger          struct {
-		MetricName         string  `json:"MetricName"`
-		Namespace          string  `json:"Namespace"`
-		Statistic          string  `json:"Statistic"`
-		ComparisonOperator string  `json:"ComparisonOperator"`
-		Threshold          float64 `json:"Threshold"`
-		Period             int     `json:"Period"`
-		EvaluationPeriods  int     `json:"EvaluationPeriods"`
-		Dimensions         []struct {
-			Name  string `json:"name"`
-			Value string `json:"value"`
-		} `json:"Dimensions"`
+	// Composite alarms carry a rule over child alarms instead of a metric Trigger
+	AlarmRule          string              `json:"AlarmRule"`
+	TriggeringChildren []CompositeChildRef `json:"TriggeringChildren"`
+	Trigger            struct {
+		MetricName         string      `json:"MetricName"`
+		Namespace          string      `json:"Namespace"`
+		Statistic          string      `json:"Statistic"`
+		ComparisonOperator string      `json:"ComparisonOperator"`
+		Threshold          float64     `json:"Threshold"`
+		Period             int         `json:"Period"`
+		EvaluationPeriods  int         `json:"EvaluationPeriods"`
+		Dimensions         []Dimension `json:"Dimensions"`
+		// Anomaly detection alarms compare against a band metric instead of a numeric Threshold
+		ThresholdMetricId string          `json:"ThresholdMetricId"`
+		Metrics           []TriggerMetric `json:"Metrics"`
 	} `json:"Trigger"`
 }
 
+type Dimension struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// TriggerMetric is one entry of an alarm's Metrics array: a metric query or an expression
+type TriggerMetric struct {
+	Id         string      `json:"Id"`
+	Expression string      `json:"Expression"`
+	Label      string      `json:"Label"`
+	ReturnData bool        `json:"ReturnData"`
+	MetricStat *MetricStat `json:"MetricStat"`
+}
+
+type MetricStat struct {
+	Metric struct {
+		Namespace  string      `json:"Namespace"`
+		MetricName string      `json:"MetricName"`
+		Dimensions []Dimension `json:"Dimensions"`
+	} `json:"Metric"`
+	Period int    `json:"Period"`
+	Stat   string `json:"Stat"`
+}
+
+// CompositeChildRef is a child alarm whose state change triggered a composite alarm
+type CompositeChildRef struct {
+	Arn   string `json:"Arn"`
+	State struct {
+		Value     string `json:"Value"`
+		Timestamp string `json:"Timestamp"`
+	} `json:"State"`
+}
+
 type GrafanaAlert struct {
 	Title       string            `json:"title"`
 	RuleID      int64             `json:"ruleId"`
@@ -67,12 +109,6 @@ type GrafanaWebhook struct {
 	Title       string            `json:"title"`
 }
 
-type AlertMessage struct {
-	Message  string
-	Priority string
-	Channel  string
-}
-
 func AdaptSQSMessage(body string) (string, error) {
 	var envelope struct {
 		Message string `json:"Message"`
@@ -87,21 +123,35 @@ func AdaptSQSMessage(body string) (string, error) {
 		return "", err
 	}
 
-	return formatSlackMessage(alarm), nil
+	return formatSlackMessage(alarm, showAllFields), nil
 }
 
-func AdaptSQSMessageWithRouting(body string, channels map[string]string, alarmChannels map[string]string) (*AlertMessage, error) {
-	var envelope struct {
-		Message string `json:"Message"`
-		Subject string `json:"Subject"`
+func AdaptSQSMessageWithRouting(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	if notification, ok := parseCostNotification(body); ok {
+		return adaptCostNotification(notification, body, channels, alarmChannels, fields), nil
 	}
-	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
-		return nil, err
+	if event, ok := parseCloudTrailEvent(body); ok {
+		return adaptCloudTrailEvent(event, body, channels, alarmChannels, fields), nil
+	}
+	if event, ok := parseECSEvent(body); ok {
+		return adaptECSEvent(event, body, channels, alarmChannels, fields)
+	}
+	if event, ok := parseRDSEvent(body); ok {
+		return adaptRDSEvent(event, body, channels, alarmChannels, fields), nil
+	}
+	if event, ok := parseBackupEvent(body); ok {
+		return adaptBackupEvent(event, body, channels, alarmChannels, fields)
+	}
+	if failure, ok := parseLambdaFailure(body); ok {
+		return adaptLambdaFailure(failure, body, channels, alarmChannels, fields)
+	}
+	if notification, ok := parseCodeStarNotification(body); ok {
+		return adaptCodeStarNotification(notification, body, channels, alarmChannels, fields)
 	}
 
-	var alarm CloudWatchAlarm
-	if err := json.Unmarshal([]byte(envelope.Message), &alarm); err != nil {
-		return nil, err
+	alarm, err := parseCloudWatchAlarm(body)
+	if err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceCloudWatch, Err: err}
 	}
 
 	// First check if there's a specific mapping for this alarm
@@ -118,13 +168,112 @@ func AdaptSQSMessageWithRouting(body string, channels map[string]string, alarmCh
 
 	priority := determinePriority(alarm)
 
-	return &AlertMessage{
-		Message:  formatSlackMessage(alarm),
-		Priority: priority,
-		Channel:  channel,
+	return &alert.Alert{
+		Source:      alert.SourceCloudWatch,
+		Name:        alarm.AlarmName,
+		Severity:    priority,
+		Status:      cloudWatchStatus(alarm.NewStateValue),
+		State:       alarm.NewStateValue,
+		Channel:     channel,
+		Labels:      cloudWatchLabels(alarm),
+		Annotations: cloudWatchAnnotations(alarm),
+		URLs:        cloudWatchURLs(alarm),
+		StartsAt:    parseCloudWatchTime(alarm.StateChangeTime),
+		ReceivedAt:  time.Now(),
+		Extensions:  cloudWatchExtensions(alarm),
+		Message:     formatSlackMessage(alarm, fieldShower(fields, channel)),
+		Summary:     formatCompactSlackMessage(alarm),
+		Raw:         body,
 	}, nil
 }
 
+func cloudWatchStatus(state string) string {
+	switch state {
+	case "OK":
+		return alert.StatusResolved
+	case "INSUFFICIENT_DATA":
+		return alert.StatusNoData
+	default:
+		return alert.StatusFiring
+	}
+}
+
+func cloudWatchAnnotations(alarm CloudWatchAlarm) map[string]string {
+	annotations := map[string]string{
+		"reason": alarm.NewStateReason,
+	}
+	if alarm.AlarmDescription != nil && *alarm.AlarmDescription != "" {
+		annotations["description"] = *alarm.AlarmDescription
+	}
+	if value := extractDatapoint(alarm.NewStateReason); value != "" {
+		annotations[alert.AnnotationValue] = value
+	}
+	return annotations
+}
+
+func cloudWatchURLs(alarm CloudWatchAlarm) map[string]string {
+	urls := make(map[string]string)
+	if link := cloudWatchConsoleURL(alarm); link != "" {
+		urls[alert.URLSource] = link
+	}
+	return urls
+}
+
+func cloudWatchExtensions(alarm CloudWatchAlarm) map[string]interface{} {
+	extensions := map[string]interface{}{
+		"alarm_arn":      alarm.AlarmArn,
+		"old_state":      alarm.OldStateValue,
+		"aws_account_id": alarm.AWSAccountId,
+	}
+	if alarm.AlarmRule != "" {
+		extensions["alarm_rule"] = alarm.AlarmRule
+	}
+	if isMetricMath(alarm) {
+		extensions["expression"] = metricMathExpression(alarm).Expression
+	}
+	return extensions
+}
+
+// parseCloudWatchTime parses "2025-07-23T13:32:26.882+0000", returning nil on failure
+func parseCloudWatchTime(timeStr string) *time.Time {
+	return parseTime("2006-01-02T15:04:05.000-0700", timeStr)
+}
+
+// parseTime returns nil for unparseable or zero times so they are omitted from the normalized alert
+func parseTime(layout, value string) *time.Time {
+	t, err := time.Parse(layout, value)
+	if err != nil || t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// parseCloudWatchAlarm reads an alarm from an SQS body, which is either an SNS envelope around
+// an SNS alarm notification or an EventBridge state change event (directly or wrapped in SNS)
+func parseCloudWatchAlarm(body string) (CloudWatchAlarm, error) {
+	if isEventBridgeAlarmEvent(body) {
+		return parseEventBridgeAlarm(body)
+	}
+
+	var envelope struct {
+		Message string `json:"Message"`
+		Subject string `json:"Subject"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return CloudWatchAlarm{}, err
+	}
+
+	if isEventBridgeAlarmEvent(envelope.Message) {
+		return parseEventBridgeAlarm(envelope.Message)
+	}
+
+	var alarm CloudWatchAlarm
+	if err := json.Unmarshal([]byte(envelope.Message), &alarm); err != nil {
+		return CloudWatchAlarm{}, err
+	}
+	return alarm, nil
+}
+
 // This will be rarely used as this is just a fallback if mapping is not done via configmap
 func determinePriority(alarm CloudWatchAlarm) string {
 	// Priority logic - customize based on your needs
@@ -162,7 +311,7 @@ func determinePriority(alarm CloudWatchAlarm) string {
 	return "P2"
 }
 
-func formatSlackMessage(alarm CloudWatchAlarm) string {
+func formatSlackMessage(alarm CloudWatchAlarm, show func(string) bool) string {
 	// Get emoji and color based on state
 	var emoji, stateColor string
 	switch alarm.NewStateValue {
@@ -180,35 +329,28 @@ func formatSlackMessage(alarm CloudWatchAlarm) string {
 		stateColor = fmt.Sprintf("`%s`", alarm.NewStateValue)
 	}
 
-	var oldStateColor string
-	switch alarm.OldStateValue {
-	case "ALARM":
-		oldStateColor = "`🔴 ALARM`"
-	case "OK":
-		oldStateColor = "`🟢 OK`"
-	case "INSUFFICIENT_DATA":
-		oldStateColor = "`🟡 INSUFFICIENT_DATA`"
-	default:
-		oldStateColor = fmt.Sprintf("`%s`", alarm.OldStateValue)
+	oldStateColor := cloudWatchStateBadge(alarm.OldStateValue)
+
+	if alarm.AlarmRule != "" {
+		return formatCompositeSlackMessage(alarm, emoji, oldStateColor, stateColor)
 	}
 
 	// Build the message with color coding
 	message := fmt.Sprintf(`%s *CloudWatch Alarm: %s*
 • *From:* %s → *To:* %s
-• *Metric:* `+"`%s/%s`"+`
-• *Threshold:* `+"`%s %.1f`"+`
+%s
+• *Threshold:* %s
 • *Period:* `+"`%ds over %d evaluations`"+`
-• *Dimensions:*
 %s
 • *Region:* `+"`%s`"+`
 • *Reason:* %s
 • *Time:* `+"`%s`",
 		emoji, alarm.AlarmName,
 		oldStateColor, stateColor,
-		alarm.Trigger.Namespace, alarm.Trigger.MetricName,
-		alarm.Trigger.ComparisonOperator, alarm.Trigger.Threshold,
-		alarm.Trigger.Period, alarm.Trigger.EvaluationPeriods,
-		formatDimensionsIndented(alarm.Trigger.Dimensions),
+		formatMetricLine(alarm),
+		formatThreshold(alarm),
+		alarmPeriod(alarm), alarm.Trigger.EvaluationPeriods,
+		formatMetricDetails(alarm, show),
 		alarm.Region,
 		alarm.NewStateReason,
 		formatTimestamp(alarm.StateChangeTime))
@@ -216,17 +358,297 @@ func formatSlackMessage(alarm CloudWatchAlarm) string {
 	return message
 }
 
-func formatDimensionsIndented(dimensions []struct {
-	Name  string `json:"name"`
-	Value string `json:"value"`
-}) string {
-	if len(dimensions) == 0 {
-		return "   → None"
+// isMetricMath reports whether the alarm evaluates a metric math expression over a Metrics array
+func isMetricMath(alarm CloudWatchAlarm) bool {
+	return alarm.Trigger.Namespace == "" && alarm.Trigger.ThresholdMetricId == "" && metricMathExpression(alarm) != nil
+}
+
+// metricMathExpression returns the expression whose result the alarm evaluates
+func metricMathExpression(alarm CloudWatchAlarm) *TriggerMetric {
+	for i, m := range alarm.Trigger.Metrics {
+		if m.Expression != "" && m.ReturnData {
+			return &alarm.Trigger.Metrics[i]
+		}
+	}
+	return nil
+}
+
+// formatMetricLine renders the watched metric, or the expression for metric math alarms
+func formatMetricLine(alarm CloudWatchAlarm) string {
+	if isMetricMath(alarm) {
+		expr := metricMathExpression(alarm)
+		line := fmt.Sprintf("• *Expression:* `%s = %s`", expr.Id, expr.Expression)
+		if expr.Label != "" {
+			line += fmt.Sprintf(" (%s)", expr.Label)
+		}
+		return line
+	}
+
+	namespace, metricName, _ := alarmMetric(alarm)
+	return fmt.Sprintf("• *Metric:* `%s/%s`", namespace, metricName)
+}
+
+// formatMetricDetails renders the shown dimensions, or each constituent metric for metric math alarms
+func formatMetricDetails(alarm CloudWatchAlarm, show func(string) bool) string {
+	if !isMetricMath(alarm) {
+		_, _, dimensions := alarmMetric(alarm)
+		return "• *Dimensions:*\n" + formatDimensionsIndented(dimensions, show)
+	}
+
+	var parts []string
+	for _, m := range alarm.Trigger.Metrics {
+		switch {
+		case m.MetricStat != nil:
+			part := fmt.Sprintf("   → `%s`: `%s/%s` (%s, %ds)", m.Id,
+				m.MetricStat.Metric.Namespace, m.MetricStat.Metric.MetricName, m.MetricStat.Stat, m.MetricStat.Period)
+			var dims []string
+			for _, dim := range m.MetricStat.Metric.Dimensions {
+				if show(dim.Name) {
+					dims = append(dims, fmt.Sprintf("%s=%s", dim.Name, dim.Value))
+				}
+			}
+			if len(dims) > 0 {
+				part += " " + strings.Join(dims, ", ")
+			}
+			parts = append(parts, part)
+		case m.Expression != "" && !m.ReturnData:
+			// Intermediate expressions feeding the evaluated one
+			parts = append(parts, fmt.Sprintf("   → `%s`: `%s`", m.Id, m.Expression))
+		}
+	}
+	if len(parts) == 0 {
+		return "• *Metrics:*\n   → None"
+	}
+	return "• *Metrics:*\n" + strings.Join(parts, "\n")
+}
+
+// alarmPeriod returns the evaluation period; metric math alarms only set it per metric
+func alarmPeriod(alarm CloudWatchAlarm) int {
+	if alarm.Trigger.Period == 0 {
+		for _, m := range alarm.Trigger.Metrics {
+			if m.MetricStat != nil {
+				return m.MetricStat.Period
+			}
+		}
+	}
+	return alarm.Trigger.Period
+}
+
+var (
+	anomalyBandRe = regexp.MustCompile(`ANOMALY_DETECTION_BAND\(\s*(\w+)\s*(?:,\s*([0-9.]+))?\s*\)`)
+	bandEdgeRe    = regexp.MustCompile(`thresholds? \[([-0-9.eE+]+)`)
+)
+
+// formatThreshold renders the numeric threshold, or the anomaly band for ThresholdMetricId alarms
+func formatThreshold(alarm CloudWatchAlarm) string {
+	if alarm.Trigger.ThresholdMetricId == "" {
+		// EventBridge events don't carry the comparison operator
+		return fmt.Sprintf("`%s`", strings.TrimSpace(fmt.Sprintf("%s %.1f", alarm.Trigger.ComparisonOperator, alarm.Trigger.Threshold)))
+	}
+
+	band := fmt.Sprintf("`%s` anomaly band `%s`", alarm.Trigger.ComparisonOperator, alarm.Trigger.ThresholdMetricId)
+	if expr := anomalyBandExpression(alarm); expr != "" {
+		matches := anomalyBandRe.FindStringSubmatch(expr)
+		width := "2"
+		if len(matches) > 2 && matches[2] != "" {
+			width = matches[2]
+		}
+		band = fmt.Sprintf("`%s` anomaly band of `%s` (%s std devs)", alarm.Trigger.ComparisonOperator, matches[1], width)
+	}
+
+	// The reason carries the band edge that was crossed, e.g. "... the upper thresholds [80.2]"
+	if edge := bandEdgeRe.FindStringSubmatch(alarm.NewStateReason); len(edge) > 1 {
+		band += fmt.Sprintf(", band edge `%s`", edge[1])
+	}
+	return band
+}
+
+// alarmMetric returns the metric an alarm watches. Anomaly detection alarms leave the top-level
+// metric empty, so the banded metric is described instead; metric math alarms use their first metric.
+func alarmMetric(alarm CloudWatchAlarm) (string, string, []Dimension) {
+	if alarm.Trigger.Namespace == "" {
+		if base := anomalyBandBaseMetric(alarm); base != nil && base.MetricStat != nil {
+			return base.MetricStat.Metric.Namespace, base.MetricStat.Metric.MetricName, base.MetricStat.Metric.Dimensions
+		}
+		for _, m := range alarm.Trigger.Metrics {
+			if m.MetricStat != nil {
+				return m.MetricStat.Metric.Namespace, m.MetricStat.Metric.MetricName, m.MetricStat.Metric.Dimensions
+			}
+		}
+	}
+	return alarm.Trigger.Namespace, alarm.Trigger.MetricName, alarm.Trigger.Dimensions
+}
+
+// anomalyBandExpression returns the ANOMALY_DETECTION_BAND expression referenced by ThresholdMetricId
+func anomalyBandExpression(alarm CloudWatchAlarm) string {
+	for _, m := range alarm.Trigger.Metrics {
+		if m.Id == alarm.Trigger.ThresholdMetricId && anomalyBandRe.MatchString(m.Expression) {
+			return m.Expression
+		}
+	}
+	return ""
+}
+
+// anomalyBandBaseMetric returns the metric the anomaly band is computed over
+func anomalyBandBaseMetric(alarm CloudWatchAlarm) *TriggerMetric {
+	matches := anomalyBandRe.FindStringSubmatch(anomalyBandExpression(alarm))
+	if len(matches) < 2 {
+		return nil
+	}
+	for i := range alarm.Trigger.Metrics {
+		if alarm.Trigger.Metrics[i].Id == matches[1] {
+			return &alarm.Trigger.Metrics[i]
+		}
+	}
+	return nil
+}
+
+// formatCompositeSlackMessage renders a composite alarm's rule and the child alarms that triggered it
+func formatCompositeSlackMessage(alarm CloudWatchAlarm, emoji, oldStateColor, stateColor string) string {
+	message := fmt.Sprintf(`%s *CloudWatch Alarm: %s*
+• *From:* %s → *To:* %s
+• *Composite Rule:* `+"`%s`",
+		emoji, alarm.AlarmName,
+		oldStateColor, stateColor,
+		alarm.AlarmRule)
+
+	if len(alarm.TriggeringChildren) > 0 {
+		message += "\n• *Triggered By:*"
+		for _, child := range alarm.TriggeringChildren {
+			message += fmt.Sprintf("\n   → %s %s", compositeChildName(child.Arn), cloudWatchStateBadge(child.State.Value))
+		}
+	}
+
+	message += fmt.Sprintf(`
+• *Region:* `+"`%s`"+`
+• *Reason:* %s
+• *Time:* `+"`%s`",
+		alarm.Region,
+		alarm.NewStateReason,
+		formatTimestamp(alarm.StateChangeTime))
+
+	return message
+}
+
+func cloudWatchStateBadge(state string) string {
+	switch state {
+	case "ALARM":
+		return "`🔴 ALARM`"
+	case "OK":
+		return "`🟢 OK`"
+	case "INSUFFICIENT_DATA":
+		return "`🟡 INSUFFICIENT_DATA`"
+	default:
+		return fmt.Sprintf("`%s`", state)
+	}
+}
+
+// compositeChildName extracts the alarm name from arn:aws:cloudwatch:<region>:<account>:alarm:<name>
+func compositeChildName(arn string) string {
+	if idx := strings.Index(arn, ":alarm:"); idx >= 0 {
+		return arn[idx+len(":alarm:"):]
 	}
+	return arn
+}
+
+// cloudWatchLabels flattens the alarm's metric identity and dimensions into labels
+func cloudWatchLabels(alarm CloudWatchAlarm) map[string]string {
+	namespace, metricName, dimensions := alarmMetric(alarm)
+	labels := map[string]string{
+		"namespace":   namespace,
+		"metric_name": metricName,
+		"account_id":  alarm.AWSAccountId,
+		"region":      alarm.Region,
+	}
+	if alarm.AlarmRule != "" {
+		labels["alarm_type"] = "composite"
+	}
+	for _, dim := range dimensions {
+		labels[dim.Name] = dim.Value
+	}
+	return labels
+}
+
+// formatCompactSlackMessage renders a CloudWatch alarm as a single line: emoji, name, value, link
+func formatCompactSlackMessage(alarm CloudWatchAlarm) string {
+	emoji := stateEmoji(alarm.NewStateValue)
+	line := fmt.Sprintf("%s *%s* `%s`", emoji, alarm.AlarmName, alarm.NewStateValue)
+
+	if value := extractDatapoint(alarm.NewStateReason); value != "" {
+		metric := ""
+		if isMetricMath(alarm) {
+			expr := metricMathExpression(alarm)
+			metric = expr.Label
+			if metric == "" {
+				metric = expr.Expression
+			}
+		} else {
+			namespace, metricName, _ := alarmMetric(alarm)
+			metric = namespace + "/" + metricName
+		}
+		line += fmt.Sprintf(" %s = *%s*", metric, value)
+	}
+
+	if link := cloudWatchConsoleURL(alarm); link != "" {
+		line += fmt.Sprintf(" <%s|View>", link)
+	}
+	return line
+}
+
+func stateEmoji(state string) string {
+	switch strings.ToUpper(state) {
+	case "ALARM", "ALERTING", "FIRING", "FIRED", "TRIGGERED", "CREATED", "ACTIVATED", "OPEN", "PROBLEM", "CRITICAL", "DOWN", "UNREACHABLE":
+		return "🚨"
+	case "OK", "RESOLVED", "RECOVERED", "CLOSED", "UP", "MERGED":
+		return "✅"
+	case "ACKNOWLEDGED":
+		return "👀"
+	case "INSUFFICIENT_DATA", "NO_DATA", "WARN", "WARNING", "UNKNOWN":
+		return "⚠️"
+	case "PENDING":
+		return "⏳"
+	case "MAINTENANCE":
+		return "🔧"
+	case "ACTUAL", "FORECASTED", "ANOMALY":
+		return "💸"
+	default:
+		return "📊"
+	}
+}
 
+var datapointRe = regexp.MustCompile(`\[([-0-9.eE+]+) \(`)
+
+// extractDatapoint pulls the first datapoint out of a reason like
+// "Threshold Crossed: 1 datapoint [85.3 (23/07/25 13:27:00)] was greater than..."
+func extractDatapoint(reason string) string {
+	matches := datapointRe.FindStringSubmatch(reason)
+	if len(matches) > 1 {
+		return matches[1]
+	}
+	return ""
+}
+
+// cloudWatchConsoleURL builds a console link from the alarm ARN, which carries the region code
+func cloudWatchConsoleURL(alarm CloudWatchAlarm) string {
+	// arn:aws:cloudwatch:us-east-1:123456789012:alarm:name
+	parts := strings.SplitN(alarm.AlarmArn, ":", 7)
+	if len(parts) < 7 || alarm.AlarmName == "" {
+		return ""
+	}
+	region := parts[3]
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/cloudwatch/home?region=%s#alarmsV2:alarm/%s",
+		region, region, url.PathEscape(alarm.AlarmName))
+}
+
+func formatDimensionsIndented(dimensions []Dimension, show func(string) bool) string {
 	var parts []string
 	for _, dim := range dimensions {
-		parts = append(parts, fmt.Sprintf("   → %s: %s", dim.Name, dim.Value))
+		if show(dim.Name) {
+			parts = append(parts, fmt.Sprintf("   → %s: %s", dim.Name, dim.Value))
+		}
+	}
+	if len(parts) == 0 {
+		return "   → None"
 	}
 	return strings.Join(parts, "\n")
 }
@@ -242,24 +664,31 @@ func formatTimestamp(timeStr string) string {
 	return t.Format("2006-01-02 15:04:05 UTC")
 }
 
-func AdaptGrafanaWebhook(body string, channels map[string]string, alarmChannels map[string]string) (*AlertMessage, error) {
+func AdaptGrafanaWebhook(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
 	// First try modern Alertmanager format
-	var alertmanagerWebhook struct {
-		Alerts       []map[string]interface{} `json:"alerts"`
-		CommonLabels map[string]string        `json:"commonLabels"`
-		Status       string                   `json:"status"`
-		Title        string                   `json:"title"`
-		Message      string                   `json:"message"`
+	if webhook, err := decodeAlertmanagerWebhook(body); err == nil && webhook.AlertCount > 0 {
+		return adaptAlertmanagerBody(webhook, body, channels, alarmChannels, fields)
 	}
 
-	if err := json.Unmarshal([]byte(body), &alertmanagerWebhook); err == nil && len(alertmanagerWebhook.Alerts) > 0 {
-		return adaptAlertmanagerWebhook(alertmanagerWebhook, channels, alarmChannels)
+	// Fallback to legacy format
+	return adaptLegacyGrafanaWebhook(body, channels, alarmChannels, fields)
+}
+
+// adaptAlertmanagerBody adapts a decoded Alertmanager-format webhook, keeping its body as Raw
+func adaptAlertmanagerBody(webhook alertmanagerWebhook, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	alertMsg, err := adaptAlertmanagerWebhook(webhook, channels, alarmChannels, fields)
+	if err != nil {
+		return nil, err
 	}
+	alertMsg.Raw = body
+	return alertMsg, nil
+}
 
-	// Fallback to legacy format
+// adaptLegacyGrafanaWebhook adapts a legacy Grafana alerting webhook
+func adaptLegacyGrafanaWebhook(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
 	var grafanaAlert GrafanaWebhook
 	if err := json.Unmarshal([]byte(body), &grafanaAlert); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal Grafana webhook: %v", err)
+		return nil, &errs.ParseError{Source: alert.SourceGrafana, Err: err}
 	}
 
 	// First check if there's a specific mapping for this rule
@@ -276,21 +705,56 @@ func AdaptGrafanaWebhook(body string, channels map[string]string, alarmChannels
 
 	priority := determineGrafanaPriority(grafanaAlert)
 
-	return &AlertMessage{
-		Message:  formatGrafanaSlackMessage(grafanaAlert),
-		Priority: priority,
-		Channel:  channel,
+	status := alert.StatusFiring
+	switch strings.ToUpper(grafanaAlert.State) {
+	case "OK":
+		status = alert.StatusResolved
+	case "NO_DATA":
+		status = alert.StatusNoData
+	}
+
+	annotations := make(map[string]string)
+	if grafanaAlert.Message != "" {
+		annotations["description"] = grafanaAlert.Message
+	}
+	if len(grafanaAlert.EvalMatches) > 0 {
+		annotations[alert.AnnotationValue] = formatValue(grafanaAlert.EvalMatches[0].Value)
+	}
+	urls := make(map[string]string)
+	if grafanaAlert.RuleURL != "" {
+		urls[alert.URLSource] = grafanaAlert.RuleURL
+	}
+	if grafanaAlert.ImageURL != "" {
+		urls[alert.URLImage] = grafanaAlert.ImageURL
+	}
+	// Legacy alert rules live on a panel, and their rule URL opens it
+	if grafanaAlert.PanelID != 0 && strings.Contains(grafanaAlert.RuleURL, "/d/") {
+		urls[alert.URLPanel] = grafanaAlert.RuleURL
+	}
+
+	return &alert.Alert{
+		Source:      alert.SourceGrafana,
+		Name:        grafanaAlert.RuleName,
+		Severity:    priority,
+		Status:      status,
+		State:       strings.ToUpper(grafanaAlert.State),
+		Channel:     channel,
+		Labels:      grafanaAlert.Tags,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"rule_id":      grafanaAlert.RuleID,
+			"dashboard_id": grafanaAlert.DashboardID,
+			"panel_id":     grafanaAlert.PanelID,
+		},
+		Message: formatGrafanaSlackMessage(grafanaAlert, fieldShower(fields, channel)),
+		Summary: formatCompactGrafanaMessage(grafanaAlert),
+		Raw:     body,
 	}, nil
 }
 
-func adaptAlertmanagerWebhook(webhook struct {
-	Alerts       []map[string]interface{} `json:"alerts"`
-	CommonLabels map[string]string        `json:"commonLabels"`
-	Status       string                   `json:"status"`
-	Title        string                   `json:"title"`
-	Message      string                   `json:"message"`
-}, channels map[string]string, alarmChannels map[string]string) (*AlertMessage, error) {
-
+func adaptAlertmanagerWebhook(webhook alertmanagerWebhook, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
 	// Get channel from commonLabels first
 	var channelTag string
 	if webhook.CommonLabels != nil {
@@ -298,14 +762,8 @@ func adaptAlertmanagerWebhook(webhook struct {
 	}
 
 	// If no channel in commonLabels, check first alert
-	if channelTag == "" && len(webhook.Alerts) > 0 {
-		if labels, ok := webhook.Alerts[0]["labels"].(map[string]interface{}); ok {
-			if ch, exists := labels["channel"]; exists {
-				if chStr, ok := ch.(string); ok {
-					channelTag = chStr
-				}
-			}
-		}
+	if channelTag == "" {
+		channelTag = webhook.First.Labels["channel"]
 	}
 
 	// Debug log to see what channel tag was extracted
@@ -314,58 +772,23 @@ func adaptAlertmanagerWebhook(webhook struct {
 	// Determine priority from channel tag or fallback logic
 	var priority string
 
-	// Check for NoData state and route to P1
-	if strings.ToUpper(webhook.Status) == "FIRING" && len(webhook.Alerts) > 0 {
-		// Check if any alert is in NoData state
-		for _, alert := range webhook.Alerts {
-			if labels, ok := alert["labels"].(map[string]interface{}); ok {
-				if alertname, exists := labels["alertname"]; exists {
-					if alertnameStr, ok := alertname.(string); ok {
-						// Check for NoData indicators in alert name or labels
-						if strings.Contains(strings.ToLower(alertnameStr), "nodata") ||
-							strings.Contains(strings.ToLower(alertnameStr), "no data") ||
-							strings.Contains(strings.ToLower(alertnameStr), "data source") {
-							priority = "P1"
-							fmt.Printf("DEBUG: NoData alert detected, setting priority to P1: %s\n", alertnameStr)
-							break
-						}
-					}
-				}
-			}
-
-			// Also check annotations for NoData indicators
-			if annotations, ok := alert["annotations"].(map[string]interface{}); ok {
-				if description, exists := annotations["description"]; exists {
-					if descStr, ok := description.(string); ok {
-						if strings.Contains(strings.ToLower(descStr), "no data") ||
-							strings.Contains(strings.ToLower(descStr), "nodata") ||
-							strings.Contains(strings.ToLower(descStr), "data source") {
-							priority = "P1"
-							fmt.Printf("DEBUG: NoData alert detected in description, setting priority to P1\n")
-							break
-						}
-					}
-				}
-			}
-		}
-	}
+	// Check for NoData/DatasourceError state; how it is handled is the destination route's nodata_policy
+	noData := strings.ToUpper(webhook.Status) == "FIRING" && webhook.noDataAlert
 
-	// If priority not set by NoData logic, use channel tag or fallback
-	if priority == "" {
-		if channelTag != "" {
-			switch strings.ToUpper(channelTag) {
-			case "P0":
-				priority = "P0"
-			case "P1":
-				priority = "P1"
-			case "P2":
-				priority = "P2"
-			default:
-				priority = "P2"
-			}
-		} else {
-			priority = "P2" // default
+	// Use channel tag or fallback
+	if channelTag != "" {
+		switch strings.ToUpper(channelTag) {
+		case "P0":
+			priority = "P0"
+		case "P1":
+			priority = "P1"
+		case "P2":
+			priority = "P2"
+		default:
+			priority = "P2"
 		}
+	} else {
+		priority = "P2" // default
 	}
 
 	// Debug log to see final priority
@@ -388,11 +811,77 @@ func adaptAlertmanagerWebhook(webhook struct {
 		}
 	}
 
-	return &AlertMessage{
-		Message:  formatAlertmanagerSlackMessage(webhook),
-		Priority: priority,
-		Channel:  channel,
-	}, nil
+	status := alert.StatusFiring
+	if strings.ToUpper(webhook.Status) == "RESOLVED" {
+		status = alert.StatusResolved
+	} else if noData {
+		status = alert.StatusNoData
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceAlertmanager,
+		Name:        alertmanagerAlertname(webhook),
+		Severity:    priority,
+		Status:      status,
+		State:       strings.ToUpper(webhook.Status),
+		Channel:     channel,
+		Labels:      alertmanagerLabels(webhook),
+		Annotations: make(map[string]string),
+		URLs:        make(map[string]string),
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"alert_count": webhook.AlertCount,
+		},
+		Message: formatAlertmanagerSlackMessage(webhook, fieldShower(fields, channel)),
+		Summary: formatCompactAlertmanagerMessage(webhook),
+	}
+
+	// Annotations, links and timestamps come from the first alert of the group
+	first := webhook.First
+	for k, v := range first.Annotations {
+		adapted.Annotations[k] = v
+	}
+	if _, ok := adapted.Annotations[alert.AnnotationValue]; !ok {
+		if _, value := alertmanagerFirstValue(first); value != nil {
+			adapted.Annotations[alert.AnnotationValue] = formatValue(*value)
+		}
+	}
+	for key, link := range map[string]string{
+		alert.URLSource:    first.GeneratorURL,
+		alert.URLSilence:   first.SilenceURL,
+		alert.URLDashboard: first.DashboardURL,
+		alert.URLImage:     first.ImageURL,
+		alert.URLPanel:     first.PanelURL,
+	} {
+		if link != "" {
+			adapted.URLs[key] = link
+		}
+	}
+	// Alertmanager sends no silence link, so link its new silence page for the alert's labels
+	if first.SilenceURL == "" && webhook.ExternalURL != "" && len(first.Labels) > 0 {
+		adapted.URLs[alert.URLSilence] = alertmanagerSilenceURL(webhook.ExternalURL, first.Labels)
+	}
+	// SLO alerts link their SLO dashboard rather than the panel of the rule
+	if slo, ok := sloOf(first, webhook.CommonLabels); ok && slo.dashboard != "" {
+		adapted.URLs[alert.URLDashboard] = slo.dashboard
+	}
+	if first.StartsAt != "" {
+		adapted.StartsAt = parseTime(time.RFC3339, first.StartsAt)
+	}
+	if first.EndsAt != "" {
+		adapted.EndsAt = parseTime(time.RFC3339, first.EndsAt)
+	}
+
+	return adapted, nil
+}
+
+// isNoDataText reports whether Grafana alert text indicates a NoData or DatasourceError state
+func isNoDataText(text string) bool {
+	text = strings.ToLower(text)
+	return strings.Contains(text, "nodata") ||
+		strings.Contains(text, "no data") ||
+		strings.Contains(text, "data source") ||
+		strings.Contains(text, "datasourceerror")
 }
 
 func determineGrafanaPriority(alert GrafanaWebhook) string {
@@ -443,7 +932,7 @@ func determineGrafanaPriority(alert GrafanaWebhook) string {
 	return "P2"
 }
 
-func formatGrafanaSlackMessage(alert GrafanaWebhook) string {
+func formatGrafanaSlackMessage(alert GrafanaWebhook, show func(string) bool) string {
 	// Get emoji and color based on state
 	var emoji, stateColor string
 	switch strings.ToUpper(alert.State) {
@@ -495,8 +984,8 @@ func formatGrafanaSlackMessage(alert GrafanaWebhook) string {
 			if len(match.Tags) > 0 {
 				var importantTags []string
 				for k, v := range match.Tags {
-					// Only show important tags, skip noise
-					if k != "__name__" && k != "job" && k != "instance" {
+					// Only show the tags the route wants, skipping noise such as job and instance
+					if show(k) {
 						importantTags = append(importantTags, fmt.Sprintf("`%s=%s`", k, v))
 					}
 				}
@@ -512,7 +1001,7 @@ func formatGrafanaSlackMessage(alert GrafanaWebhook) string {
 		var importantTags []string
 		for k, v := range alert.Tags {
 			// Skip channel tag as it's used for routing
-			if k != "channel" && v != "" {
+			if k != "channel" && v != "" && show(k) {
 				importantTags = append(importantTags, fmt.Sprintf("   → `%s`: %s", k, v))
 			}
 		}
@@ -529,13 +1018,102 @@ func formatGrafanaSlackMessage(alert GrafanaWebhook) string {
 	return message
 }
 
-func formatAlertmanagerSlackMessage(webhook struct {
-	Alerts       []map[string]interface{} `json:"alerts"`
-	CommonLabels map[string]string        `json:"commonLabels"`
-	Status       string                   `json:"status"`
-	Title        string                   `json:"title"`
-	Message      string                   `json:"message"`
-}) string {
+// formatCompactGrafanaMessage renders a legacy Grafana alert as a single line
+func formatCompactGrafanaMessage(alert GrafanaWebhook) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(alert.State), alert.Title, strings.ToUpper(alert.State))
+
+	if len(alert.EvalMatches) > 0 {
+		match := alert.EvalMatches[0]
+		line += fmt.Sprintf(" %s = *%.2f*", match.Metric, match.Value)
+	}
+
+	if alert.RuleURL != "" {
+		line += fmt.Sprintf(" <%s|View>", alert.RuleURL)
+	}
+	return line
+}
+
+// alertmanagerAlertname prefers the common alertname and falls back to the first alert's label
+func alertmanagerAlertname(webhook alertmanagerWebhook) string {
+	alertname := webhook.CommonLabels["alertname"]
+	if alertname == "" {
+		alertname = webhook.First.Labels["alertname"]
+	}
+	return alertname
+}
+
+// alertmanagerLabels merges the first alert's labels with the common labels, common labels winning
+func alertmanagerLabels(webhook alertmanagerWebhook) map[string]string {
+	labels := make(map[string]string, len(webhook.First.Labels)+len(webhook.CommonLabels))
+	for k, v := range webhook.First.Labels {
+		labels[k] = v
+	}
+	for k, v := range webhook.CommonLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// alertmanagerSilenceURL links Alertmanager's page for a new silence matching the labels
+func alertmanagerSilenceURL(externalURL string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matchers := make([]string, len(names))
+	for i, name := range names {
+		matchers[i] = fmt.Sprintf("%s=%q", name, labels[name])
+	}
+	filter := "{" + strings.Join(matchers, ",") + "}"
+	return strings.TrimSuffix(externalURL, "/") + "/#/silences/new?filter=" + url.QueryEscape(filter)
+}
+
+// alertmanagerFirstValue returns the alert's first value; Grafana keys values by query ref
+// (A, B, ...), so the first is picked deterministically
+func alertmanagerFirstValue(a alertmanagerAlert) (string, *float64) {
+	if len(a.Values) == 0 {
+		return "", nil
+	}
+	keys := make([]string, 0, len(a.Values))
+	for key := range a.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys[0], a.Values[keys[0]]
+}
+
+// formatValue renders a metric value with two decimals, or none when it is whole
+func formatValue(value float64) string {
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+// formatCompactAlertmanagerMessage renders an Alertmanager-style webhook as a single line
+func formatCompactAlertmanagerMessage(webhook alertmanagerWebhook) string {
+	if slo, ok := sloOf(webhook.First, webhook.CommonLabels); ok {
+		return formatCompactSLOMessage(webhook, slo)
+	}
+
+	alertname := alertmanagerAlertname(webhook)
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(webhook.Status), alertname, strings.ToUpper(webhook.Status))
+	if webhook.AlertCount > 1 {
+		line += fmt.Sprintf(" (%d alerts)", webhook.AlertCount)
+	}
+
+	first := webhook.First
+	if ref, v := alertmanagerFirstValue(first); v != nil {
+		line += fmt.Sprintf(" %s = *%.2f*", ref, *v)
+	}
+	if first.GeneratorURL != "" {
+		line += fmt.Sprintf(" <%s|View>", first.GeneratorURL)
+	}
+	return line
+}
+
+func formatAlertmanagerSlackMessage(webhook alertmanagerWebhook, show func(string) bool) string {
 	// Wrap everything in a defer to catch any panics and return basic message
 	defer func() {
 		if r := recover(); r != nil {
@@ -543,8 +1121,13 @@ func formatAlertmanagerSlackMessage(webhook struct {
 		}
 	}()
 
+	// SLO burn-rate alerts are about the error budget rather than the metric
+	if slo, ok := sloOf(webhook.First, webhook.CommonLabels); ok {
+		return formatSLOMessage(webhook, slo)
+	}
+
 	// Try enhanced formatting first
-	if enhancedMessage := formatEnhancedAlertMessage(webhook); enhancedMessage != "" {
+	if enhancedMessage := formatEnhancedAlertMessage(webhook, show); enhancedMessage != "" {
 		return enhancedMessage
 	}
 
@@ -552,13 +1135,7 @@ func formatAlertmanagerSlackMessage(webhook struct {
 	return formatBasicAlertMessage(webhook)
 }
 
-func formatEnhancedAlertMessage(webhook struct {
-	Alerts       []map[string]interface{} `json:"alerts"`
-	CommonLabels map[string]string        `json:"commonLabels"`
-	Status       string                   `json:"status"`
-	Title        string                   `json:"title"`
-	Message      string                   `json:"message"`
-}) string {
+func formatEnhancedAlertMessage(webhook alertmanagerWebhook, show func(string) bool) string {
 	// Get emoji and color based on status
 	var emoji, stateColor string
 	switch strings.ToUpper(webhook.Status) {
@@ -574,97 +1151,57 @@ func formatEnhancedAlertMessage(webhook struct {
 	}
 
 	// Build the message
-	alertname := webhook.CommonLabels["alertname"]
-	if alertname == "" && len(webhook.Alerts) > 0 {
-		if labels, ok := webhook.Alerts[0]["labels"].(map[string]interface{}); ok {
-			if name, exists := labels["alertname"]; exists {
-				if nameStr, ok := name.(string); ok {
-					alertname = nameStr
-				}
-			}
-		}
-	}
+	alertname := alertmanagerAlertname(webhook)
 
 	message := fmt.Sprintf(`%s *Grafana Alert: %s*
 • *State:* %s`,
 		emoji, alertname, stateColor)
 
 	// Add annotations from the alert
-	if len(webhook.Alerts) > 0 {
-		if annotations, ok := webhook.Alerts[0]["annotations"].(map[string]interface{}); ok {
-			// Add description/summary first
-			if desc, exists := annotations["description"]; exists {
-				if descStr, ok := desc.(string); ok && descStr != "" {
-					message += fmt.Sprintf("\n• *Description:* %s", descStr)
-				}
-			} else if summary, exists := annotations["summary"]; exists {
-				if summaryStr, ok := summary.(string); ok && summaryStr != "" {
-					message += fmt.Sprintf("\n• *Description:* %s", summaryStr)
-				}
-			}
-
-			// Add all other annotations dynamically
-			for key, value := range annotations {
-				// Skip already processed annotations
-				if key == "description" || key == "summary" {
-					continue
-				}
+	first := webhook.First
+	message += alertmanagerDescription(first)
+
+	// Add all other annotations dynamically
+	for key, value := range first.Annotations {
+		// Skip already processed annotations and those the route hides
+		if key == "description" || key == "summary" || !show(key) {
+			continue
+		}
 
-				if valueStr, ok := value.(string); ok && valueStr != "" {
-					// Format the key nicely (capitalize first letter)
-					formattedKey := strings.ReplaceAll(key, "_", " ")
-					if len(formattedKey) > 0 {
-						formattedKey = strings.ToUpper(formattedKey[:1]) + formattedKey[1:]
-					}
-					message += fmt.Sprintf("\n• *%s:* %s", formattedKey, valueStr)
-				}
+		if value != "" {
+			// Format the key nicely (capitalize first letter)
+			formattedKey := strings.ReplaceAll(key, "_", " ")
+			if len(formattedKey) > 0 {
+				formattedKey = strings.ToUpper(formattedKey[:1]) + formattedKey[1:]
 			}
+			message += fmt.Sprintf("\n• *%s:* %s", formattedKey, value)
 		}
 	}
 
 	// Add valueString as raw data if available
-	if len(webhook.Alerts) > 0 {
-		alert := webhook.Alerts[0]
-		if valueString, ok := alert["valueString"].(string); ok && valueString != "" {
-			message += fmt.Sprintf("\n• *ValueString:* %s", valueString)
-		}
+	if first.ValueString != "" {
+		message += fmt.Sprintf("\n• *ValueString:* %s", first.ValueString)
 	}
 
 	// Add silence URL if available
-	if len(webhook.Alerts) > 0 {
-		if silenceURL, ok := webhook.Alerts[0]["silenceURL"].(string); ok && silenceURL != "" {
-			message += fmt.Sprintf("\n• *Silence:* <%s|Silence Alert>", silenceURL)
-		}
+	if first.SilenceURL != "" {
+		message += fmt.Sprintf("\n• *Silence:* <%s|Silence Alert>", first.SilenceURL)
 	}
 
 	// Add generator URL if available
-	if len(webhook.Alerts) > 0 {
-		if generatorURL, ok := webhook.Alerts[0]["generatorURL"].(string); ok && generatorURL != "" {
-			message += fmt.Sprintf("\n• *Dashboard:* <%s|View Alert Rule>", generatorURL)
-		}
+	if first.GeneratorURL != "" {
+		message += fmt.Sprintf("\n• *Dashboard:* <%s|View Alert Rule>", first.GeneratorURL)
 	}
 
 	// Add dashboard URL if different from generator URL
-	if len(webhook.Alerts) > 0 {
-		if dashboardURL, ok := webhook.Alerts[0]["dashboardURL"].(string); ok && dashboardURL != "" {
-			// Only add if it's different from generator URL
-			generatorURL, _ := webhook.Alerts[0]["generatorURL"].(string)
-			if dashboardURL != generatorURL {
-				message += fmt.Sprintf("\n• *Dashboard:* <%s|View Dashboard>", dashboardURL)
-			}
-		}
+	if first.DashboardURL != "" && first.DashboardURL != first.GeneratorURL {
+		message += fmt.Sprintf("\n• *Dashboard:* <%s|View Dashboard>", first.DashboardURL)
 	}
 
 	return message
 }
 
-func formatBasicAlertMessage(webhook struct {
-	Alerts       []map[string]interface{} `json:"alerts"`
-	CommonLabels map[string]string        `json:"commonLabels"`
-	Status       string                   `json:"status"`
-	Title        string                   `json:"title"`
-	Message      string                   `json:"message"`
-}) string {
+func formatBasicAlertMessage(webhook alertmanagerWebhook) string {
 	// Get emoji and color based on status
 	var emoji, stateColor string
 	switch strings.ToUpper(webhook.Status) {
@@ -680,46 +1217,36 @@ func formatBasicAlertMessage(webhook struct {
 	}
 
 	// Build the basic message
-	alertname := webhook.CommonLabels["alertname"]
-	if alertname == "" && len(webhook.Alerts) > 0 {
-		if labels, ok := webhook.Alerts[0]["labels"].(map[string]interface{}); ok {
-			if name, exists := labels["alertname"]; exists {
-				if nameStr, ok := name.(string); ok {
-					alertname = nameStr
-				}
-			}
-		}
-	}
+	alertname := alertmanagerAlertname(webhook)
 
 	message := fmt.Sprintf(`%s *Grafana Alert: %s*
 • *State:* %s`,
 		emoji, alertname, stateColor)
 
 	// Add description/summary from annotations
-	if len(webhook.Alerts) > 0 {
-		if annotations, ok := webhook.Alerts[0]["annotations"].(map[string]interface{}); ok {
-			if desc, exists := annotations["description"]; exists {
-				if descStr, ok := desc.(string); ok && descStr != "" {
-					message += fmt.Sprintf("\n• *Description:* %s", descStr)
-				}
-			} else if summary, exists := annotations["summary"]; exists {
-				if summaryStr, ok := summary.(string); ok && summaryStr != "" {
-					message += fmt.Sprintf("\n• *Description:* %s", summaryStr)
-				}
-			}
-		}
-	}
+	message += alertmanagerDescription(webhook.First)
 
 	// Add generator URL if available
-	if len(webhook.Alerts) > 0 {
-		if generatorURL, ok := webhook.Alerts[0]["generatorURL"].(string); ok && generatorURL != "" {
-			message += fmt.Sprintf("\n• *Dashboard:* <%s|View Alert Rule>", generatorURL)
-		}
+	if webhook.First.GeneratorURL != "" {
+		message += fmt.Sprintf("\n• *Dashboard:* <%s|View Alert Rule>", webhook.First.GeneratorURL)
 	}
 
 	return message
 }
 
+// alertmanagerDescription is the Description line from the alert's description annotation, or
+// its summary when it has no description
+func alertmanagerDescription(a alertmanagerAlert) string {
+	text, exists := a.Annotations["description"]
+	if !exists {
+		text = a.Annotations["summary"]
+	}
+	if text == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n• *Description:* %s", text)
+}
+
 func formatValueString(valueString string) string {
 	defer func() {
 		if r := recover(); r != nil {
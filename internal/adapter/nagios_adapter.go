@@ -0,0 +1,299 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// NagiosNotification is the body the notification commands in the README post for host and
+// service notifications from Nagios or Icinga. Host notifications have no service fields.
+type NagiosNotification struct {
+	Monitor            string            `json:"monitor"`           // nagios or icinga, for display
+	NotificationType   string            `json:"notification_type"` // PROBLEM, RECOVERY, ACKNOWLEDGEMENT, FLAPPINGSTART, ...
+	HostName           string            `json:"host_name"`
+	HostAddress        string            `json:"host_address"`
+	HostState          string            `json:"host_state"` // UP, DOWN or UNREACHABLE
+	LastHostState      string            `json:"last_host_state"`
+	ServiceDescription string            `json:"service_description"`
+	ServiceState       string            `json:"service_state"` // OK, WARNING, CRITICAL or UNKNOWN
+	LastServiceState   string            `json:"last_service_state"`
+	Output             string            `json:"output"`
+	LongOutput         string            `json:"long_output"`
+	Perfdata           string            `json:"perfdata"`
+	Author             string            `json:"author"` // of an acknowledgement or downtime
+	Comment            string            `json:"comment"`
+	Timestamp          json.RawMessage   `json:"timestamp"` // unix seconds, a number or a string holding one
+	URL                string            `json:"url"`       // the host or service in the web interface
+	Vars               map[string]string `json:"vars"`      // custom variables, e.g. channel
+}
+
+// isService reports whether the notification is about a service check rather than a host check
+func (n NagiosNotification) isService() bool {
+	return n.ServiceDescription != ""
+}
+
+// checkState is the state of the notified check, e.g. CRITICAL or DOWN
+func (n NagiosNotification) checkState() string {
+	if n.isService() {
+		return strings.ToUpper(n.ServiceState)
+	}
+	return strings.ToUpper(n.HostState)
+}
+
+// lastCheckState is the state of the check before this notification, if the command sent it
+func (n NagiosNotification) lastCheckState() string {
+	if n.isService() {
+		return strings.ToUpper(n.LastServiceState)
+	}
+	return strings.ToUpper(n.LastHostState)
+}
+
+// monitorName is "Icinga" for notifications from Icinga and "Nagios" otherwise
+func (n NagiosNotification) monitorName() string {
+	if strings.EqualFold(n.Monitor, "icinga") {
+		return "Icinga"
+	}
+	return "Nagios"
+}
+
+// AdaptNagiosNotification maps a Nagios or Icinga host or service notification to a routed alert
+func AdaptNagiosNotification(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var notification NagiosNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceNagios, Err: err}
+	}
+	if notification.HostName == "" {
+		return nil, &errs.ParseError{Source: alert.SourceNagios, Err: fmt.Errorf("no host_name")}
+	}
+
+	// Services are named by their description, so one mapping covers the check on every host
+	name := notification.HostName
+	if notification.isService() {
+		name = notification.ServiceDescription
+	}
+	priority := determineNagiosPriority(notification)
+
+	// First check if there's a specific mapping for this check
+	channel := alarmChannels[name]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := nagiosState(notification)
+	status := alert.StatusFiring
+	if state == "OK" || state == "UP" {
+		status = alert.StatusResolved
+	}
+
+	labels := make(map[string]string, len(notification.Vars)+3)
+	for k, v := range notification.Vars {
+		labels[k] = v
+	}
+	labels["host"] = notification.HostName
+	if notification.HostAddress != "" && notification.HostAddress != notification.HostName {
+		labels["host_address"] = notification.HostAddress
+	}
+	if notification.isService() {
+		labels["service"] = notification.ServiceDescription
+	}
+
+	annotations := make(map[string]string)
+	if notification.Output != "" {
+		annotations["description"] = notification.Output
+	}
+	if value := nagiosPerfValue(notification.Perfdata); value != "" {
+		annotations[alert.AnnotationValue] = value
+	}
+	urls := make(map[string]string)
+	if notification.URL != "" {
+		urls[alert.URLSource] = notification.URL
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceNagios,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"monitor":           strings.ToLower(notification.monitorName()),
+			"notification_type": strings.ToUpper(notification.NotificationType),
+			"check_state":       notification.checkState(),
+		},
+		Message: formatNagiosSlackMessage(notification, name, state, fieldShower(fields, channel)),
+		Summary: formatCompactNagiosMessage(notification, name, state),
+		Raw:     body,
+	}
+	if seconds, err := strconv.ParseInt(rawValue(notification.Timestamp), 10, 64); err == nil && seconds > 0 {
+		startsAt := time.Unix(seconds, 0).UTC()
+		adapted.StartsAt = &startsAt
+	}
+	return adapted, nil
+}
+
+// nagiosState is the state of the check, or ACKNOWLEDGED for acknowledgements so they aren't
+// taken for another firing. Recoveries always resolve, whatever state the command sent.
+func nagiosState(notification NagiosNotification) string {
+	switch strings.ToUpper(notification.NotificationType) {
+	case "ACKNOWLEDGEMENT":
+		return "ACKNOWLEDGED"
+	case "RECOVERY":
+		if notification.isService() {
+			return "OK"
+		}
+		return "UP"
+	}
+	if state := notification.checkState(); state != "" {
+		return state
+	}
+	return "PROBLEM"
+}
+
+// determineNagiosPriority maps check states onto priorities: a host DOWN is P0, a service
+// CRITICAL or a host UNREACHABLE P1 and everything else P2. Recoveries keep the priority of the
+// state they recover from, so they reach the channel the problem went to. A channel variable of
+// P0-P2 wins.
+func determineNagiosPriority(notification NagiosNotification) string {
+	switch strings.ToUpper(notification.Vars["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(notification.Vars["channel"])
+	}
+
+	state := notification.checkState()
+	if last := notification.lastCheckState(); (state == "OK" || state == "UP") && last != "" {
+		state = last
+	}
+	switch state {
+	case "DOWN":
+		return "P0"
+	case "CRITICAL", "UNREACHABLE":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+var nagiosPerfValuePattern = regexp.MustCompile(`^(?:'[^']*'|[^=\s]+)=(-?[0-9.]+)([a-zA-Z%]*)`)
+
+// nagiosPerfValue is the first value of the plugin's performance data with its unit, e.g. "93%"
+// from "load=93%;80;90;0;100"
+func nagiosPerfValue(perfdata string) string {
+	m := nagiosPerfValuePattern.FindStringSubmatch(strings.TrimSpace(perfdata))
+	if m == nil {
+		return ""
+	}
+	return m[1] + m[2]
+}
+
+// nagiosStateBadge renders a check state like CloudWatch's state badges
+func nagiosStateBadge(state string) string {
+	switch state {
+	case "OK", "UP":
+		return fmt.Sprintf("`🟢 %s`", state)
+	case "WARNING":
+		return "`🟡 WARNING`"
+	case "CRITICAL", "DOWN":
+		return fmt.Sprintf("`🔴 %s`", state)
+	case "UNKNOWN", "UNREACHABLE":
+		return fmt.Sprintf("`🟣 %s`", state)
+	default:
+		return fmt.Sprintf("`%s`", state)
+	}
+}
+
+func formatNagiosSlackMessage(notification NagiosNotification, name, state string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *%s Alert: %s*", stateEmoji(state), notification.monitorName(), name)
+
+	current := notification.checkState()
+	if state == "OK" || state == "UP" {
+		current = state
+	}
+	if last := notification.lastCheckState(); last != "" && last != current {
+		message += fmt.Sprintf("\n• *From:* %s → *To:* %s", nagiosStateBadge(last), nagiosStateBadge(current))
+	} else {
+		message += fmt.Sprintf("\n• *State:* %s", nagiosStateBadge(current))
+	}
+	if notificationType := strings.ToUpper(notification.NotificationType); notificationType != "" && notificationType != "PROBLEM" && notificationType != "RECOVERY" {
+		message += fmt.Sprintf("\n• *Notification:* `%s`", notificationType)
+	}
+
+	if notification.isService() {
+		message += fmt.Sprintf("\n• *Host:* `%s`", notification.HostName)
+		if notification.HostAddress != "" && notification.HostAddress != notification.HostName {
+			message += fmt.Sprintf(" (%s)", notification.HostAddress)
+		}
+	} else if notification.HostAddress != "" && notification.HostAddress != notification.HostName {
+		message += fmt.Sprintf("\n• *Address:* `%s`", notification.HostAddress)
+	}
+	if notification.Output != "" {
+		message += fmt.Sprintf("\n• *Output:* %s", notification.Output)
+	}
+	if notification.LongOutput != "" {
+		message += fmt.Sprintf("\n• *Details:* %s", strings.ReplaceAll(notification.LongOutput, `\n`, "\n"))
+	}
+	if notification.Perfdata != "" {
+		message += fmt.Sprintf("\n• *Perfdata:* `%s`", notification.Perfdata)
+	}
+	if notification.Author != "" {
+		message += fmt.Sprintf("\n• *By:* %s", notification.Author)
+		if notification.Comment != "" {
+			message += fmt.Sprintf(": %s", notification.Comment)
+		}
+	}
+
+	// Skip the channel variable as it's used for routing
+	var keys []string
+	for k := range notification.Vars {
+		if k != "channel" && show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Variables:*"
+		for _, k := range keys {
+			message += fmt.Sprintf("\n   → `%s`: %s", k, notification.Vars[k])
+		}
+	}
+
+	if seconds, err := strconv.ParseInt(rawValue(notification.Timestamp), 10, 64); err == nil && seconds > 0 {
+		message += fmt.Sprintf("\n• *Time:* `%s`", time.Unix(seconds, 0).UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	if notification.URL != "" {
+		message += fmt.Sprintf("\n• *Check:* <%s|View in %s>", notification.URL, notification.monitorName())
+	}
+	return message
+}
+
+// formatCompactNagiosMessage renders a Nagios or Icinga notification as a single line
+func formatCompactNagiosMessage(notification NagiosNotification, name, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), name, state)
+	if notification.isService() {
+		line += fmt.Sprintf(" on `%s`", notification.HostName)
+	}
+	if notification.Output != "" {
+		line += " " + notification.Output
+	}
+	if notification.URL != "" {
+		line += fmt.Sprintf(" <%s|View>", notification.URL)
+	}
+	return line
+}
@@ -0,0 +1,341 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// NewRelicAlert is the body of a New Relic webhook: either a workflow issue notification using
+// the default payload template, or a legacy alert policy incident notification
+type NewRelicAlert struct {
+	// Workflow issue fields
+	IssueID             string   `json:"id"`
+	IssueURL            string   `json:"issueUrl"`
+	Title               string   `json:"title"`
+	Priority            string   `json:"priority"` // CRITICAL, HIGH, MEDIUM or LOW
+	ImpactedEntities    []string `json:"impactedEntities"`
+	TotalIncidents      int      `json:"totalIncidents"`
+	State               string   `json:"state"` // CREATED, ACTIVATED, ACKNOWLEDGED or CLOSED
+	CreatedAt           int64    `json:"createdAt"`
+	Sources             []string `json:"sources"`
+	AlertPolicyNames    []string `json:"alertPolicyNames"`
+	AlertConditionNames []string `json:"alertConditionNames"`
+	WorkflowName        string   `json:"workflowName"`
+
+	// Legacy incident fields
+	IncidentID        int64            `json:"incident_id"`
+	IncidentURL       string           `json:"incident_url"`
+	ConditionName     string           `json:"condition_name"`
+	PolicyName        string           `json:"policy_name"`
+	Severity          string           `json:"severity"`      // CRITICAL, WARNING or INFO
+	CurrentState      string           `json:"current_state"` // open, acknowledged or closed
+	Details           string           `json:"details"`
+	RunbookURL        string           `json:"runbook_url"`
+	ViolationChartURL string           `json:"violation_chart_url"`
+	Timestamp         int64            `json:"timestamp"`
+	Targets           []NewRelicTarget `json:"targets"`
+}
+
+// NewRelicTarget is an entity a legacy incident was opened on
+type NewRelicTarget struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Link   string            `json:"link"`
+	Labels map[string]string `json:"labels"`
+}
+
+// isIssue reports whether the alert came from a workflow rather than a legacy alert policy
+func (nr NewRelicAlert) isIssue() bool {
+	return nr.IssueURL != "" || len(nr.AlertConditionNames) > 0
+}
+
+// AdaptWebhook adapts a body posted to the webhook endpoint, which accepts New Relic, Azure
+// Monitor and Google Cloud Monitoring notifications as well as Grafana's legacy and
+// Alertmanager formats
+func AdaptWebhook(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	match := SniffWebhook(body)
+	payloadFormats.Inc(match.Format, match.Confidence)
+	if match.Ambiguous != "" {
+		ambiguousPayloads.Inc(match.Ambiguous)
+		log.Printf("Webhook payload is ambiguous, falling back to %s: %s", match.Format, match.Reason)
+	} else {
+		log.Printf("Webhook payload matched %s with %s confidence: %s", match.Format, match.Confidence, match.Reason)
+	}
+
+	switch match.Format {
+	case FormatAzure:
+		return AdaptAzureAlert(body, channels, alarmChannels, fields)
+	case FormatGCP:
+		return AdaptGCPNotification(body, channels, alarmChannels, fields)
+	case FormatNewRelic:
+		var nrAlert NewRelicAlert
+		if err := json.Unmarshal([]byte(body), &nrAlert); err != nil {
+			return nil, &errs.ParseError{Source: alert.SourceNewRelic, Err: err}
+		}
+		return adaptNewRelicAlert(nrAlert, body, channels, alarmChannels, fields)
+	case FormatAlertmanager:
+		return adaptAlertmanagerBody(match.alertmanager, body, channels, alarmChannels, fields)
+	default:
+		return adaptLegacyGrafanaWebhook(body, channels, alarmChannels, fields)
+	}
+}
+
+// adaptNewRelicAlert maps a New Relic issue or incident notification to a routed alert
+func adaptNewRelicAlert(nrAlert NewRelicAlert, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	name := newRelicConditionName(nrAlert)
+	if name == "" {
+		return nil, &errs.ParseError{Source: alert.SourceNewRelic, Err: fmt.Errorf("no condition name or title")}
+	}
+	labels := newRelicLabels(nrAlert)
+	priority := determineNewRelicPriority(nrAlert, labels)
+
+	// First check if there's a specific mapping for this condition
+	channel := alarmChannels[name]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := newRelicState(nrAlert)
+	status := alert.StatusFiring
+	if state == "CLOSED" {
+		status = alert.StatusResolved
+	}
+
+	annotations := make(map[string]string)
+	if description := newRelicDescription(nrAlert, name); description != "" {
+		annotations["description"] = description
+	}
+	if nrAlert.RunbookURL != "" {
+		annotations["runbook_url"] = nrAlert.RunbookURL
+	}
+	urls := make(map[string]string)
+	if link := newRelicLink(nrAlert); link != "" {
+		urls[alert.URLSource] = link
+	}
+	if nrAlert.ViolationChartURL != "" {
+		urls[alert.URLImage] = nrAlert.ViolationChartURL
+	}
+
+	extensions := map[string]interface{}{
+		"severity": newRelicSeverity(nrAlert),
+	}
+	if nrAlert.isIssue() {
+		extensions["issue_id"] = nrAlert.IssueID
+		extensions["total_incidents"] = nrAlert.TotalIncidents
+		extensions["workflow"] = nrAlert.WorkflowName
+		extensions["sources"] = nrAlert.Sources
+	} else {
+		extensions["incident_id"] = nrAlert.IncidentID
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceNewRelic,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions:  extensions,
+		Message:     formatNewRelicSlackMessage(nrAlert, name, state, labels, fieldShower(fields, channel)),
+		Summary:     formatCompactNewRelicMessage(nrAlert, name, state),
+		Raw:         body,
+	}
+	if ms := newRelicTimestamp(nrAlert); ms > 0 {
+		startsAt := time.UnixMilli(ms).UTC()
+		adapted.StartsAt = &startsAt
+	}
+	return adapted, nil
+}
+
+// newRelicConditionName names the alert after its condition, so alarm mappings and drop rules
+// match every issue it opens; issues correlating several conditions use the first
+func newRelicConditionName(nrAlert NewRelicAlert) string {
+	if len(nrAlert.AlertConditionNames) > 0 && nrAlert.AlertConditionNames[0] != "" {
+		return nrAlert.AlertConditionNames[0]
+	}
+	if nrAlert.ConditionName != "" {
+		return nrAlert.ConditionName
+	}
+	return nrAlert.Title
+}
+
+func newRelicPolicy(nrAlert NewRelicAlert) string {
+	if len(nrAlert.AlertPolicyNames) > 0 {
+		return strings.Join(nrAlert.AlertPolicyNames, ", ")
+	}
+	return nrAlert.PolicyName
+}
+
+// newRelicEntities lists the impacted entities of an issue, or the targets of an incident
+func newRelicEntities(nrAlert NewRelicAlert) []string {
+	if len(nrAlert.ImpactedEntities) > 0 {
+		return nrAlert.ImpactedEntities
+	}
+	var entities []string
+	for _, target := range nrAlert.Targets {
+		if target.Name != "" {
+			entities = append(entities, target.Name)
+		}
+	}
+	return entities
+}
+
+// newRelicLabels holds the policy, condition and entity, plus the labels of incident targets
+func newRelicLabels(nrAlert NewRelicAlert) map[string]string {
+	labels := make(map[string]string)
+	for _, target := range nrAlert.Targets {
+		for k, v := range target.Labels {
+			labels[k] = v
+		}
+	}
+	if policy := newRelicPolicy(nrAlert); policy != "" {
+		labels["policy"] = policy
+	}
+	if condition := newRelicConditionName(nrAlert); condition != "" {
+		labels["condition"] = condition
+	}
+	if entities := newRelicEntities(nrAlert); len(entities) > 0 {
+		labels["entity"] = strings.Join(entities, ", ")
+	}
+	if nrAlert.WorkflowName != "" {
+		labels["workflow"] = nrAlert.WorkflowName
+	}
+	return labels
+}
+
+func newRelicSeverity(nrAlert NewRelicAlert) string {
+	if nrAlert.Priority != "" {
+		return strings.ToUpper(nrAlert.Priority)
+	}
+	return strings.ToUpper(nrAlert.Severity)
+}
+
+// newRelicState normalizes issue and incident states to CREATED, ACTIVATED, ACKNOWLEDGED,
+// CLOSED or OPEN
+func newRelicState(nrAlert NewRelicAlert) string {
+	if nrAlert.State != "" {
+		return strings.ToUpper(nrAlert.State)
+	}
+	if nrAlert.CurrentState != "" {
+		return strings.ToUpper(nrAlert.CurrentState)
+	}
+	return "ACTIVATED"
+}
+
+func newRelicDescription(nrAlert NewRelicAlert, name string) string {
+	if nrAlert.Details != "" {
+		return nrAlert.Details
+	}
+	// Issue titles are usually more specific than the condition, e.g. naming the host
+	if nrAlert.Title != name {
+		return nrAlert.Title
+	}
+	return ""
+}
+
+func newRelicLink(nrAlert NewRelicAlert) string {
+	if nrAlert.IssueURL != "" {
+		return nrAlert.IssueURL
+	}
+	return nrAlert.IncidentURL
+}
+
+func newRelicTimestamp(nrAlert NewRelicAlert) int64 {
+	if nrAlert.CreatedAt > 0 {
+		return nrAlert.CreatedAt
+	}
+	return nrAlert.Timestamp
+}
+
+// determineNewRelicPriority maps New Relic priorities and severities onto ours: CRITICAL is P0,
+// HIGH is P1 and the rest are P2. A channel:P0-P2 target label wins.
+func determineNewRelicPriority(nrAlert NewRelicAlert, labels map[string]string) string {
+	switch strings.ToUpper(labels["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(labels["channel"])
+	}
+
+	switch newRelicSeverity(nrAlert) {
+	case "CRITICAL":
+		return "P0"
+	case "HIGH":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+func formatNewRelicSlackMessage(nrAlert NewRelicAlert, name, state string, labels map[string]string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *New Relic Alert: %s*\n• *State:* `%s`", stateEmoji(state), name, state)
+
+	if severity := newRelicSeverity(nrAlert); severity != "" {
+		message += fmt.Sprintf("\n• *Severity:* %s", severity)
+	}
+	if policy := newRelicPolicy(nrAlert); policy != "" {
+		message += fmt.Sprintf("\n• *Policy:* `%s`", policy)
+	}
+	if entities := newRelicEntities(nrAlert); len(entities) > 0 {
+		message += fmt.Sprintf("\n• *Entities:* `%s`", strings.Join(entities, "`, `"))
+	}
+	if description := newRelicDescription(nrAlert, name); description != "" {
+		message += fmt.Sprintf("\n• *Description:* %s", description)
+	}
+	if nrAlert.TotalIncidents > 1 {
+		message += fmt.Sprintf("\n• *Incidents:* %d", nrAlert.TotalIncidents)
+	}
+
+	// Policy, condition, entity and workflow are shown above, and channel is used for routing
+	var keys []string
+	for k := range labels {
+		switch k {
+		case "policy", "condition", "entity", "workflow", "channel":
+			continue
+		}
+		if show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Labels:*"
+		for _, k := range keys {
+			message += fmt.Sprintf("\n   → `%s`: %s", k, labels[k])
+		}
+	}
+
+	if nrAlert.RunbookURL != "" {
+		message += fmt.Sprintf("\n• *Runbook:* <%s|Open Runbook>", nrAlert.RunbookURL)
+	}
+	if link := newRelicLink(nrAlert); link != "" {
+		message += fmt.Sprintf("\n• *Issue:* <%s|View in New Relic>", link)
+	}
+	return message
+}
+
+// formatCompactNewRelicMessage renders a New Relic alert as a single line
+func formatCompactNewRelicMessage(nrAlert NewRelicAlert, name, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), name, state)
+	if entities := newRelicEntities(nrAlert); len(entities) > 0 {
+		line += fmt.Sprintf(" on `%s`", entities[0])
+	}
+	if link := newRelicLink(nrAlert); link != "" {
+		line += fmt.Sprintf(" <%s|View>", link)
+	}
+	return line
+}
@@ -0,0 +1,213 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// PingdomAlert is the body of a Pingdom webhook integration, sent when a check changes state
+type PingdomAlert struct {
+	CheckID     int64  `json:"check_id"`
+	CheckName   string `json:"check_name"`
+	CheckType   string `json:"check_type"` // HTTP, TCP, PING, DNS, TRANSACTION, ...
+	CheckParams struct {
+		FullURL  string `json:"full_url"`
+		Hostname string `json:"hostname"`
+	} `json:"check_params"`
+	Tags                  []string `json:"tags"`
+	PreviousState         string   `json:"previous_state"` // UP or DOWN; SUCCESSFUL or FAILING for transaction checks
+	CurrentState          string   `json:"current_state"`
+	ImportanceLevel       string   `json:"importance_level"` // HIGH or LOW
+	StateChangedTimestamp int64    `json:"state_changed_timestamp"`
+	Description           string   `json:"description"`
+	LongDescription       string   `json:"long_description"`
+	CustomMessage         string   `json:"custom_message"`
+	FirstProbe            struct {
+		Location string `json:"location"`
+	} `json:"first_probe"`
+}
+
+// target is what the check probes: its full URL for HTTP checks, otherwise its hostname
+func (p PingdomAlert) target() string {
+	if p.CheckParams.FullURL != "" {
+		return p.CheckParams.FullURL
+	}
+	return p.CheckParams.Hostname
+}
+
+// AdaptPingdomAlert maps a Pingdom check state change to a routed alert
+func AdaptPingdomAlert(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var pingdomAlert PingdomAlert
+	if err := json.Unmarshal([]byte(body), &pingdomAlert); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourcePingdom, Err: err}
+	}
+	if pingdomAlert.CheckName == "" {
+		return nil, &errs.ParseError{Source: alert.SourcePingdom, Err: fmt.Errorf("no check_name")}
+	}
+
+	priority := determinePingdomPriority(pingdomAlert)
+
+	// First check if there's a specific mapping for this check
+	channel := alarmChannels[pingdomAlert.CheckName]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := pingdomState(pingdomAlert.CurrentState)
+	status := alert.StatusFiring
+	if state == "UP" {
+		status = alert.StatusResolved
+	}
+
+	// Probes and descriptions change from one notification to the next, so only the check's
+	// settings are labels and a recovery resolves the alert its failure raised
+	labels := make(map[string]string, len(pingdomAlert.Tags)+2)
+	for _, tag := range pingdomAlert.Tags {
+		if !isPriorityTag(tag) {
+			labels[tag] = ""
+		}
+	}
+	if pingdomAlert.CheckType != "" {
+		labels["check_type"] = strings.ToLower(pingdomAlert.CheckType)
+	}
+	if target := pingdomAlert.target(); target != "" {
+		labels["target"] = target
+	}
+
+	annotations := make(map[string]string)
+	if description := pingdomDescription(pingdomAlert); description != "" {
+		annotations["description"] = description
+	}
+	urls := map[string]string{
+		alert.URLSource: fmt.Sprintf("https://my.pingdom.com/app/reports/uptime#check=%d", pingdomAlert.CheckID),
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourcePingdom,
+		Name:        pingdomAlert.CheckName,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"check_id":         pingdomAlert.CheckID,
+			"importance_level": pingdomAlert.ImportanceLevel,
+		},
+		Message: formatPingdomSlackMessage(pingdomAlert, state, urls[alert.URLSource], fieldShower(fields, channel)),
+		Summary: formatCompactPingdomMessage(pingdomAlert, state, urls[alert.URLSource]),
+		Raw:     body,
+	}
+	if pingdomAlert.StateChangedTimestamp > 0 {
+		startsAt := time.Unix(pingdomAlert.StateChangedTimestamp, 0).UTC()
+		adapted.StartsAt = &startsAt
+	}
+	return adapted, nil
+}
+
+// pingdomState normalizes transaction check states to UP and DOWN
+func pingdomState(state string) string {
+	switch strings.ToUpper(state) {
+	case "UP", "SUCCESSFUL", "SUCCESS":
+		return "UP"
+	case "DOWN", "FAILING", "FAILED":
+		return "DOWN"
+	default:
+		return strings.ToUpper(state)
+	}
+}
+
+// determinePingdomPriority reads the priority from a P0, P1 or P2 tag, then the check's
+// importance level: HIGH is P1 and LOW P2. Pingdom sends the same level when the check
+// recovers, so the recovery goes wherever the failure went.
+func determinePingdomPriority(pingdomAlert PingdomAlert) string {
+	for _, tag := range pingdomAlert.Tags {
+		if isPriorityTag(tag) {
+			return strings.ToUpper(tag)
+		}
+	}
+	if strings.EqualFold(pingdomAlert.ImportanceLevel, "LOW") {
+		return "P2"
+	}
+	return "P1"
+}
+
+// isPriorityTag reports whether tag is P0, P1 or P2
+func isPriorityTag(tag string) bool {
+	switch strings.ToUpper(tag) {
+	case "P0", "P1", "P2":
+		return true
+	}
+	return false
+}
+
+// pingdomDescription is the check's error, e.g. "Timeout (> 30s)"
+func pingdomDescription(pingdomAlert PingdomAlert) string {
+	if pingdomAlert.LongDescription != "" {
+		return pingdomAlert.LongDescription
+	}
+	return pingdomAlert.Description
+}
+
+func formatPingdomSlackMessage(pingdomAlert PingdomAlert, state, link string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Pingdom Check: %s*\n• *State:* `%s`", stateEmoji(state), pingdomAlert.CheckName, state)
+
+	if previous := pingdomState(pingdomAlert.PreviousState); previous != "" && previous != state {
+		message += fmt.Sprintf(" (was `%s`)", previous)
+	}
+	if target := pingdomAlert.target(); target != "" {
+		message += fmt.Sprintf("\n• *Target:* `%s`", target)
+	}
+	if pingdomAlert.CheckType != "" {
+		message += fmt.Sprintf("\n• *Type:* `%s`", pingdomAlert.CheckType)
+	}
+	if description := pingdomDescription(pingdomAlert); description != "" {
+		message += fmt.Sprintf("\n• *Error:* %s", description)
+	}
+	if location := pingdomAlert.FirstProbe.Location; location != "" && state != "UP" {
+		message += fmt.Sprintf("\n• *Probe:* %s", location)
+	}
+	if pingdomAlert.CustomMessage != "" {
+		message += fmt.Sprintf("\n• *Note:* %s", pingdomAlert.CustomMessage)
+	}
+
+	var tags []string
+	for _, tag := range pingdomAlert.Tags {
+		if !isPriorityTag(tag) && show(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	if len(tags) > 0 {
+		message += "\n• *Tags:*"
+		for _, tag := range tags {
+			message += fmt.Sprintf("\n   → `%s`", tag)
+		}
+	}
+
+	message += fmt.Sprintf("\n• *Report:* <%s|View in Pingdom>", link)
+	return message
+}
+
+// formatCompactPingdomMessage renders a Pingdom check state change as a single line
+func formatCompactPingdomMessage(pingdomAlert PingdomAlert, state, link string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), pingdomAlert.CheckName, state)
+	if pingdomAlert.Description != "" && state != "UP" {
+		line += " " + pingdomAlert.Description
+	}
+	return line + fmt.Sprintf(" <%s|View>", link)
+}
@@ -0,0 +1,327 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// RDSEventPriorities assigns RDS event categories their priority. *config.Config implements it
+// from its rds_event_priorities; categories it has no priority for, or all of them when the
+// filter passed to the adapter doesn't implement it, get the built-in ones.
+type RDSEventPriorities interface {
+	RDSEventPriority(category string) (string, bool)
+}
+
+// defaultRDSEventPriorities are the built-in priorities by category: a failed or failing
+// instance pages, a failover or storage running out needs a look soon, and the rest, such as
+// backups, snapshots and maintenance, are informational
+var defaultRDSEventPriorities = map[string]string{
+	"failure":         "P0",
+	"failover":        "P1",
+	"global-failover": "P1",
+	"low storage":     "P1",
+	"availability":    "P1",
+	"recovery":        "P1",
+}
+
+// rdsEventCategories are the categories of common events, for SNS notifications, which
+// don't carry theirs
+var rdsEventCategories = map[string]string{
+	"RDS-EVENT-0001": "backup",
+	"RDS-EVENT-0002": "backup",
+	"RDS-EVENT-0004": "availability",
+	"RDS-EVENT-0006": "availability",
+	"RDS-EVENT-0007": "low storage",
+	"RDS-EVENT-0013": "failover",
+	"RDS-EVENT-0015": "failover",
+	"RDS-EVENT-0031": "failure",
+	"RDS-EVENT-0034": "failover",
+	"RDS-EVENT-0036": "failure",
+	"RDS-EVENT-0049": "failover",
+	"RDS-EVENT-0089": "low storage",
+}
+
+// RDSEvent is an RDS event, from either an event subscription's SNS notification or EventBridge
+type RDSEvent struct {
+	SourceType string // db-instance, db-cluster, db-snapshot, db-cluster-snapshot, ...
+	SourceID   string // the DB instance, cluster or snapshot identifier
+	SourceARN  string
+	EventID    string   // e.g. RDS-EVENT-0049
+	Categories []string // e.g. failover
+	Message    string
+	Time       *time.Time
+	Account    string
+	Region     string
+	Link       string // the console page of the source, when the notification has one
+}
+
+// rdsNotification is the message of an RDS event subscription's SNS notification
+type rdsNotification struct {
+	EventSource    string `json:"Event Source"`
+	EventTime      string `json:"Event Time"` // e.g. 2024-05-01 10:15:42.137
+	IdentifierLink string `json:"Identifier Link"`
+	SourceID       string `json:"Source ID"`
+	SourceARN      string `json:"Source ARN"`
+	EventID        string `json:"Event ID"` // a documentation link ending in #RDS-EVENT-0049
+	EventMessage   string `json:"Event Message"`
+}
+
+// rdsEventBridgeEvent is an RDS event as EventBridge delivers it, e.g. with detail-type
+// "RDS DB Instance Event"
+type rdsEventBridgeEvent struct {
+	Source  string `json:"source"`
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	Detail  struct {
+		EventCategories  []string `json:"EventCategories"`
+		SourceType       string   `json:"SourceType"` // DB_INSTANCE, CLUSTER, SNAPSHOT, ...
+		SourceArn        string   `json:"SourceArn"`
+		SourceIdentifier string   `json:"SourceIdentifier"`
+		Date             string   `json:"Date"`
+		Message          string   `json:"Message"`
+		EventID          string   `json:"EventID"`
+	} `json:"detail"`
+}
+
+// parseRDSEvent reads an RDS event from an SQS body, whether an EventBridge event or an event
+// subscription's notification, directly or wrapped in SNS, reporting false for anything else
+func parseRDSEvent(body string) (RDSEvent, bool) {
+	var bridged rdsEventBridgeEvent
+	if err := json.Unmarshal([]byte(body), &bridged); err != nil {
+		return RDSEvent{}, false
+	}
+	if bridged.Source == "aws.rds" && bridged.Detail.SourceIdentifier != "" {
+		detail := bridged.Detail
+		return RDSEvent{
+			SourceType: rdsSourceType(detail.SourceType),
+			SourceID:   detail.SourceIdentifier,
+			SourceARN:  detail.SourceArn,
+			EventID:    detail.EventID,
+			Categories: detail.EventCategories,
+			Message:    detail.Message,
+			Time:       parseTime(time.RFC3339, detail.Date),
+			Account:    bridged.Account,
+			Region:     bridged.Region,
+		}, true
+	}
+
+	var notification rdsNotification
+	if err := json.Unmarshal([]byte(body), &notification); err == nil && notification.SourceID != "" && notification.EventID != "" {
+		eventID := notification.EventID[strings.LastIndex(notification.EventID, "#")+1:]
+		event := RDSEvent{
+			SourceType: notification.EventSource,
+			SourceID:   notification.SourceID,
+			SourceARN:  notification.SourceARN,
+			EventID:    eventID,
+			Message:    notification.EventMessage,
+			Time:       parseTime("2006-01-02 15:04:05.000", notification.EventTime),
+			Link:       notification.IdentifierLink,
+		}
+		if category, ok := rdsEventCategories[eventID]; ok {
+			event.Categories = []string{category}
+		}
+		event.Region, event.Account = rdsARNLocation(notification.SourceARN)
+		return event, true
+	}
+
+	var envelope struct {
+		Message string `json:"Message"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || !strings.HasPrefix(strings.TrimSpace(envelope.Message), "{") {
+		return RDSEvent{}, false
+	}
+	return parseRDSEvent(envelope.Message)
+}
+
+// rdsSourceType is the event subscription name of an EventBridge source type, e.g. db-instance
+// for DB_INSTANCE
+func rdsSourceType(sourceType string) string {
+	switch sourceType {
+	case "DB_INSTANCE":
+		return "db-instance"
+	case "CLUSTER":
+		return "db-cluster"
+	case "SNAPSHOT":
+		return "db-snapshot"
+	case "CLUSTER_SNAPSHOT":
+		return "db-cluster-snapshot"
+	case "DB_PARAM":
+		return "db-parameter-group"
+	case "SECURITY_GROUP":
+		return "db-security-group"
+	case "DB_PROXY":
+		return "db-proxy"
+	}
+	return strings.ToLower(strings.ReplaceAll(sourceType, "_", "-"))
+}
+
+// rdsARNLocation is the region and account of an RDS ARN, e.g.
+// arn:aws:rds:eu-west-1:123456789012:db:orders
+func rdsARNLocation(arn string) (region, account string) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return "", ""
+	}
+	return parts[3], parts[4]
+}
+
+// rdsPriority is the most urgent priority of the event's categories, configured or built in
+func rdsPriority(event RDSEvent, priorities RDSEventPriorities) string {
+	priority := "P2"
+	for _, category := range event.Categories {
+		category = strings.ToLower(category)
+		p, ok := "", false
+		if priorities != nil {
+			p, ok = priorities.RDSEventPriority(category)
+		}
+		if !ok {
+			p, ok = defaultRDSEventPriorities[category]
+		}
+		if ok && p < priority {
+			priority = p
+		}
+	}
+	return priority
+}
+
+// rdsCategory is the event's category, or notification when the adapter doesn't know it
+func rdsCategory(event RDSEvent) string {
+	if len(event.Categories) == 0 {
+		return "notification"
+	}
+	return strings.ToLower(strings.Join(event.Categories, ", "))
+}
+
+// adaptRDSEvent maps an RDS event to an alert named after its DB instance, cluster or snapshot,
+// routed by its alarm mapping, then its priority. Each kind of event on a source is an alert of
+// its own, firing every time it happens.
+func adaptRDSEvent(event RDSEvent, body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) *alert.Alert {
+	priorities, _ := fields.(RDSEventPriorities)
+	priority := rdsPriority(event, priorities)
+	channel := alarmChannels[event.SourceID]
+	if channel == "" {
+		channel = channels[priority]
+	}
+	if channel == "" {
+		channel = channels["default"]
+	}
+
+	category := rdsCategory(event)
+	labels := map[string]string{
+		"source_type": event.SourceType,
+		"category":    category,
+		"event_id":    event.EventID,
+	}
+	if event.Account != "" {
+		labels["account"] = event.Account
+	}
+	if event.Region != "" {
+		labels["region"] = event.Region
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceRDS,
+		Name:        event.SourceID,
+		Severity:    priority,
+		Status:      alert.StatusFiring,
+		State:       strings.ToUpper(category),
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: map[string]string{"description": event.Message},
+		URLs:        map[string]string{alert.URLSource: rdsConsoleURL(event)},
+		ReceivedAt:  time.Now(),
+		Raw:         body,
+		Extensions: map[string]interface{}{
+			"source_arn": event.SourceARN,
+		},
+		Message: formatRDSSlackMessage(event, category, priority, fieldShower(fields, channel)),
+		Summary: formatCompactRDSMessage(event, category, priority),
+	}
+	adapted.StartsAt = event.Time
+	return adapted
+}
+
+// rdsConsoleURL links the event's source in the RDS console
+func rdsConsoleURL(event RDSEvent) string {
+	if event.Link != "" {
+		return event.Link
+	}
+	region := event.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	page := "database:id=" + event.SourceID + ";is-cluster=false"
+	switch event.SourceType {
+	case "db-cluster":
+		page = "database:id=" + event.SourceID + ";is-cluster=true"
+	case "db-snapshot":
+		page = "db-snapshot:id=" + event.SourceID
+	case "db-cluster-snapshot":
+		page = "db-cluster-snapshot:id=" + event.SourceID
+	case "db-parameter-group", "db-security-group", "db-proxy":
+		page = "events:"
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/rds/home?region=%s#%s", region, region, page)
+}
+
+// rdsSourceNoun names the kind of source, e.g. "DB instance"
+func rdsSourceNoun(sourceType string) string {
+	switch sourceType {
+	case "db-instance":
+		return "DB instance"
+	case "db-cluster":
+		return "DB cluster"
+	case "db-snapshot":
+		return "DB snapshot"
+	case "db-cluster-snapshot":
+		return "DB cluster snapshot"
+	case "":
+		return "Source"
+	}
+	return sourceType
+}
+
+// rdsEmoji marks P0 events red, P1 amber and the rest as informational
+func rdsEmoji(priority string) string {
+	switch priority {
+	case "P0":
+		return "🚨"
+	case "P1":
+		return "⚠️"
+	default:
+		return "🛢️"
+	}
+}
+
+func formatRDSSlackMessage(event RDSEvent, category, priority string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *RDS %s: %s*\n• *%s:* *`%s`*", rdsEmoji(priority), category, event.SourceID, rdsSourceNoun(event.SourceType), event.SourceID)
+	if event.Message != "" {
+		message += fmt.Sprintf("\n• *Event:* %s", event.Message)
+	}
+	if event.EventID != "" {
+		message += fmt.Sprintf(" (`%s`)", event.EventID)
+	}
+	if event.Account != "" && show("account") {
+		message += fmt.Sprintf("\n• *Account:* `%s`", event.Account)
+	}
+	if event.Region != "" && show("region") {
+		message += fmt.Sprintf("\n• *Region:* `%s`", event.Region)
+	}
+	if event.Time != nil {
+		message += fmt.Sprintf("\n• *Time:* %s", event.Time.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	return message + fmt.Sprintf("\n• *Console:* <%s|View in RDS>", rdsConsoleURL(event))
+}
+
+// formatCompactRDSMessage renders an RDS event as a single line
+func formatCompactRDSMessage(event RDSEvent, category, priority string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", rdsEmoji(priority), event.SourceID, strings.ToUpper(category))
+	if event.Message != "" {
+		line += " " + event.Message
+	}
+	return line
+}
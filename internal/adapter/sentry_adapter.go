@@ -0,0 +1,311 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// SentryWebhook is the body of a Sentry issue alert: either an internal integration's
+// event_alert or issue webhook, or a notification from the legacy WebHooks plugin
+type SentryWebhook struct {
+	Action string `json:"action"` // triggered for alerts, resolved, assigned, ... for issues
+	Data   struct {
+		Event         *SentryEvent `json:"event"`
+		Issue         *SentryIssue `json:"issue"`
+		TriggeredRule string       `json:"triggered_rule"`
+	} `json:"data"`
+
+	// Legacy WebHooks plugin fields
+	ProjectSlug string       `json:"project_slug"`
+	ProjectName string       `json:"project_name"`
+	Level       string       `json:"level"`
+	Culprit     string       `json:"culprit"`
+	Message     string       `json:"message"`
+	URL         string       `json:"url"`
+	Event       *SentryEvent `json:"event"`
+}
+
+// SentryEvent is the event that triggered an issue alert
+type SentryEvent struct {
+	EventID     string          `json:"event_id"`
+	IssueID     string          `json:"issue_id"`
+	Project     json.RawMessage `json:"project"` // numeric ID
+	Title       string          `json:"title"`
+	Culprit     string          `json:"culprit"`
+	Level       string          `json:"level"`
+	Release     string          `json:"release"`
+	Environment string          `json:"environment"`
+	Platform    string          `json:"platform"`
+	WebURL      string          `json:"web_url"`
+	Datetime    string          `json:"datetime"`
+	Tags        [][]string      `json:"tags"` // key/value pairs
+}
+
+// SentryIssue is the issue of an issue webhook, sent when it is resolved, ignored or assigned
+type SentryIssue struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Culprit   string `json:"culprit"`
+	Level     string `json:"level"`
+	Permalink string `json:"permalink"`
+	Project   struct {
+		ID   string `json:"id"`
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	} `json:"project"`
+}
+
+// sentryAlert is a Sentry webhook of any kind reduced to the fields the dispatcher uses
+type sentryAlert struct {
+	title       string
+	culprit     string
+	level       string
+	release     string
+	environment string
+	project     string // slug when known, otherwise ID
+	projectID   string
+	rule        string
+	url         string
+	eventID     string
+	issueID     string
+	state       string
+	startsAt    *time.Time
+	tags        map[string]string
+}
+
+// AdaptSentryWebhook maps a Sentry issue alert to a routed alert. Alerts go to their alarm
+// mapping, then their project's channel, then the channel of their priority.
+func AdaptSentryWebhook(body string, channels, alarmChannels, projectChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var webhook SentryWebhook
+	if err := json.Unmarshal([]byte(body), &webhook); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceSentry, Err: err}
+	}
+
+	sa := normalizeSentryWebhook(webhook)
+	if sa.title == "" {
+		return nil, &errs.ParseError{Source: alert.SourceSentry, Err: fmt.Errorf("no event or issue title")}
+	}
+	priority := determineSentryPriority(sa)
+
+	// First check if there's a specific mapping for this issue, then for its project
+	channel := alarmChannels[sa.title]
+	if channel == "" {
+		channel = projectChannels[sa.project]
+	}
+	if channel == "" && sa.projectID != "" {
+		channel = projectChannels[sa.projectID]
+	}
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	status := alert.StatusFiring
+	if sa.state == "RESOLVED" {
+		status = alert.StatusResolved
+	}
+
+	labels := make(map[string]string, len(sa.tags)+2)
+	for k, v := range sa.tags {
+		labels[k] = v
+	}
+	if sa.project != "" {
+		labels["project"] = sa.project
+	}
+	if sa.environment != "" {
+		labels["environment"] = sa.environment
+	}
+
+	annotations := make(map[string]string)
+	if sa.culprit != "" {
+		annotations["culprit"] = sa.culprit
+	}
+	if sa.release != "" {
+		annotations["release"] = sa.release
+	}
+	urls := make(map[string]string)
+	if sa.url != "" {
+		urls[alert.URLSource] = sa.url
+	}
+
+	return &alert.Alert{
+		Source:      alert.SourceSentry,
+		Name:        sa.title,
+		Severity:    priority,
+		Status:      status,
+		State:       sa.state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		StartsAt:    sa.startsAt,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"event_id": sa.eventID,
+			"issue_id": sa.issueID,
+			"level":    sa.level,
+			"rule":     sa.rule,
+		},
+		Message: formatSentrySlackMessage(sa, fieldShower(fields, channel)),
+		Summary: formatCompactSentryMessage(sa),
+		Raw:     body,
+	}, nil
+}
+
+func normalizeSentryWebhook(webhook SentryWebhook) sentryAlert {
+	sa := sentryAlert{
+		state:   "TRIGGERED",
+		rule:    webhook.Data.TriggeredRule,
+		project: webhook.ProjectSlug,
+		title:   webhook.Message,
+		culprit: webhook.Culprit,
+		level:   webhook.Level,
+		url:     webhook.URL,
+	}
+	if action := strings.ToUpper(webhook.Action); action != "" && action != "TRIGGERED" {
+		sa.state = action
+	}
+
+	event := webhook.Data.Event
+	if event == nil {
+		event = webhook.Event
+	}
+	if event != nil {
+		sa.eventID = event.EventID
+		sa.issueID = event.IssueID
+		sa.projectID = strings.Trim(string(event.Project), `"`)
+		sa.release = event.Release
+		sa.environment = event.Environment
+		if event.Title != "" {
+			sa.title = event.Title
+		}
+		if event.Culprit != "" {
+			sa.culprit = event.Culprit
+		}
+		if event.Level != "" {
+			sa.level = event.Level
+		}
+		if event.WebURL != "" {
+			sa.url = event.WebURL
+		}
+		if event.Datetime != "" {
+			sa.startsAt = parseTime(time.RFC3339Nano, event.Datetime)
+		}
+		sa.tags = sentryTags(event.Tags)
+	}
+
+	if issue := webhook.Data.Issue; issue != nil {
+		sa.issueID = issue.ID
+		sa.title = issue.Title
+		sa.culprit = issue.Culprit
+		sa.level = issue.Level
+		sa.url = issue.Permalink
+		sa.project = issue.Project.Slug
+		sa.projectID = issue.Project.ID
+	}
+
+	if sa.project == "" {
+		sa.project = sa.projectID
+	}
+	return sa
+}
+
+// sentryTags turns Sentry's [key, value] pairs into labels, leaving out the ones the message
+// shows on their own lines
+func sentryTags(pairs [][]string) map[string]string {
+	tags := make(map[string]string)
+	for _, pair := range pairs {
+		if len(pair) != 2 {
+			continue
+		}
+		switch pair[0] {
+		case "level", "release", "environment":
+			continue
+		}
+		tags[pair[0]] = pair[1]
+	}
+	return tags
+}
+
+// determineSentryPriority maps event levels onto priorities: fatal is P0, error P1 and
+// everything else P2. A channel:P0-P2 tag wins.
+func determineSentryPriority(sa sentryAlert) string {
+	switch strings.ToUpper(sa.tags["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(sa.tags["channel"])
+	}
+
+	switch strings.ToLower(sa.level) {
+	case "fatal":
+		return "P0"
+	case "error":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+func formatSentrySlackMessage(sa sentryAlert, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Sentry Alert: %s*\n• *State:* `%s`", stateEmoji(sa.state), sa.title, sa.state)
+
+	if sa.project != "" {
+		message += fmt.Sprintf("\n• *Project:* `%s`", sa.project)
+	}
+	if sa.culprit != "" {
+		message += fmt.Sprintf("\n• *Culprit:* `%s`", sa.culprit)
+	}
+	if sa.level != "" {
+		message += fmt.Sprintf("\n• *Level:* `%s`", sa.level)
+	}
+	if sa.release != "" {
+		message += fmt.Sprintf("\n• *Release:* `%s`", sa.release)
+	}
+	if sa.environment != "" {
+		message += fmt.Sprintf("\n• *Environment:* `%s`", sa.environment)
+	}
+	if sa.rule != "" {
+		message += fmt.Sprintf("\n• *Rule:* %s", sa.rule)
+	}
+
+	// Skip the channel tag as it's used for routing
+	var keys []string
+	for k := range sa.tags {
+		if k != "channel" && show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Tags:*"
+		for _, k := range keys {
+			message += fmt.Sprintf("\n   → `%s`: %s", k, sa.tags[k])
+		}
+	}
+
+	if sa.url != "" {
+		message += fmt.Sprintf("\n• *Event:* <%s|View in Sentry>", sa.url)
+	}
+	return message
+}
+
+// formatCompactSentryMessage renders a Sentry alert as a single line
+func formatCompactSentryMessage(sa sentryAlert) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(sa.state), sa.title, sa.state)
+	if sa.culprit != "" {
+		line += fmt.Sprintf(" in `%s`", sa.culprit)
+	}
+	if sa.url != "" {
+		line += fmt.Sprintf(" <%s|View>", sa.url)
+	}
+	return line
+}
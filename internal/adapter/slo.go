@@ -0,0 +1,169 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sloAlert is a multiwindow burn-rate alert on a service level objective, read from the label
+// and annotation conventions of Sloth, Pyrra and hand-written SLO rules
+type sloAlert struct {
+	name            string
+	service         string
+	objective       string
+	burnRate        string
+	longWindow      string
+	shortWindow     string
+	budgetRemaining string
+	exhaustion      string
+	dashboard       string
+}
+
+// firstOf returns the first non-empty value of keys in labels, then annotations
+func firstOf(labels, annotations map[string]string, keys ...string) string {
+	for _, set := range []map[string]string{labels, annotations} {
+		for _, key := range keys {
+			if value := set[key]; value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// sloOf reports whether the alert is an SLO alert, by an slo or sloth_slo label, and reads its
+// burn-rate details
+func sloOf(a alertmanagerAlert, commonLabels map[string]string) (sloAlert, bool) {
+	labels := make(map[string]string, len(a.Labels)+len(commonLabels))
+	for k, v := range a.Labels {
+		labels[k] = v
+	}
+	for k, v := range commonLabels {
+		labels[k] = v
+	}
+
+	name := firstOf(labels, nil, "slo", "sloth_slo")
+	if name == "" {
+		return sloAlert{}, false
+	}
+	slo := sloAlert{
+		name:            name,
+		service:         firstOf(labels, nil, "service", "sloth_service"),
+		objective:       firstOf(labels, a.Annotations, "objective", "sloth_objective"),
+		burnRate:        firstOf(labels, a.Annotations, "burn_rate"),
+		longWindow:      firstOf(labels, a.Annotations, "long", "long_window", "window"),
+		shortWindow:     firstOf(labels, a.Annotations, "short", "short_window"),
+		budgetRemaining: firstOf(nil, a.Annotations, "error_budget_remaining", "budget_remaining"),
+		exhaustion:      firstOf(labels, a.Annotations, "exhaustion"),
+		dashboard:       firstOf(nil, a.Annotations, "slo_dashboard", "slo_url"),
+	}
+	if slo.dashboard == "" {
+		slo.dashboard = a.DashboardURL
+	}
+	return slo, true
+}
+
+// formatBurnRate adds the "x" to a bare burn rate factor, e.g. "14.4" becomes "14.4x"
+func formatBurnRate(rate string) string {
+	if _, err := strconv.ParseFloat(rate, 64); err == nil {
+		return rate + "x"
+	}
+	return rate
+}
+
+// formatObjective renders an objective given as a ratio as a percentage, e.g. "0.999" becomes
+// "99.9%"
+func formatObjective(objective string) string {
+	if ratio, err := strconv.ParseFloat(objective, 64); err == nil && ratio > 0 && ratio < 1 {
+		return strconv.FormatFloat(ratio*100, 'f', -1, 64) + "%"
+	}
+	return objective
+}
+
+// formatSLOWindows is e.g. " over `1h` and `5m`"
+func formatSLOWindows(slo sloAlert) string {
+	switch {
+	case slo.longWindow != "" && slo.shortWindow != "":
+		return fmt.Sprintf(" over `%s` and `%s`", slo.longWindow, slo.shortWindow)
+	case slo.longWindow != "":
+		return fmt.Sprintf(" over `%s`", slo.longWindow)
+	default:
+		return ""
+	}
+}
+
+// formatSLOMessage renders an SLO alert around its error budget rather than the metric: burn
+// rate and windows, budget remaining and a link to the SLO dashboard
+func formatSLOMessage(webhook alertmanagerWebhook, slo sloAlert) string {
+	var emoji, stateColor string
+	switch strings.ToUpper(webhook.Status) {
+	case "FIRING":
+		emoji = "🔥"
+		stateColor = "`🔴 FIRING`"
+	case "RESOLVED":
+		emoji = "✅"
+		stateColor = "`🟢 RESOLVED`"
+	default:
+		emoji = "📊"
+		stateColor = fmt.Sprintf("`%s`", webhook.Status)
+	}
+
+	message := fmt.Sprintf("%s *SLO Alert: %s*\n• *State:* %s", emoji, slo.name, stateColor)
+	if alertname := alertmanagerAlertname(webhook); alertname != "" && alertname != slo.name {
+		message += fmt.Sprintf("\n• *Alert:* `%s`", alertname)
+	}
+	if slo.service != "" {
+		message += fmt.Sprintf("\n• *Service:* `%s`", slo.service)
+	}
+	if slo.objective != "" {
+		message += fmt.Sprintf("\n• *Objective:* %s", formatObjective(slo.objective))
+	}
+	if slo.burnRate != "" {
+		message += fmt.Sprintf("\n• *Burn rate:* *%s*%s", formatBurnRate(slo.burnRate), formatSLOWindows(slo))
+	} else if windows := formatSLOWindows(slo); windows != "" {
+		message += "\n• *Windows:*" + strings.TrimPrefix(windows, " over")
+	}
+	if slo.budgetRemaining != "" {
+		message += fmt.Sprintf("\n• *Error budget remaining:* %s", slo.budgetRemaining)
+	}
+	if slo.exhaustion != "" {
+		message += fmt.Sprintf("\n• *Budget exhausted in:* %s", slo.exhaustion)
+	}
+
+	first := webhook.First
+	message += alertmanagerDescription(first)
+	if first.SilenceURL != "" {
+		message += fmt.Sprintf("\n• *Silence:* <%s|Silence Alert>", first.SilenceURL)
+	}
+	if slo.dashboard != "" {
+		message += fmt.Sprintf("\n• *SLO:* <%s|Open SLO Dashboard>", slo.dashboard)
+	}
+	if first.GeneratorURL != "" {
+		message += fmt.Sprintf("\n• *Rule:* <%s|View Alert Rule>", first.GeneratorURL)
+	}
+	return message
+}
+
+// formatCompactSLOMessage renders an SLO alert as a single line, e.g.
+// "🔥 *api-availability* `FIRING` burning 14.4x, 62% budget left <...|SLO>"
+func formatCompactSLOMessage(webhook alertmanagerWebhook, slo sloAlert) string {
+	emoji := stateEmoji(webhook.Status)
+	if strings.ToUpper(webhook.Status) == "FIRING" {
+		emoji = "🔥"
+	}
+	line := fmt.Sprintf("%s *%s* `%s`", emoji, slo.name, strings.ToUpper(webhook.Status))
+	if slo.burnRate != "" {
+		line += " burning " + formatBurnRate(slo.burnRate)
+		if slo.budgetRemaining != "" {
+			line += ","
+		}
+	}
+	if slo.budgetRemaining != "" {
+		line += fmt.Sprintf(" %s budget left", slo.budgetRemaining)
+	}
+	if slo.dashboard != "" {
+		line += fmt.Sprintf(" <%s|SLO>", slo.dashboard)
+	}
+	return line
+}
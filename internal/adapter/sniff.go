@@ -0,0 +1,116 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+var (
+	payloadFormats = metrics.NewCounter("alert_dispatcher_payload_formats_total",
+		"Webhook payloads by the format they were recognized as and how confidently.", "format", "confidence")
+	ambiguousPayloads = metrics.NewCounter("alert_dispatcher_ambiguous_payloads_total",
+		"Webhook payloads no format recognized, handed to the legacy Grafana adapter as a fallback.", "reason")
+)
+
+// Formats the webhook endpoint accepts
+const (
+	FormatAzure         = "azure"
+	FormatGCP           = "gcp"
+	FormatNewRelic      = "newrelic"
+	FormatAlertmanager  = "alertmanager"
+	FormatGrafanaLegacy = "grafana_legacy"
+)
+
+// How confidently a payload was recognized
+const (
+	ConfidenceHigh   = "high"   // a field only that format sends, e.g. Azure's schemaId
+	ConfidenceMedium = "medium" // the fields the adapter reads are there, but nothing unique to it
+	ConfidenceLow    = "low"    // nothing matched and the legacy Grafana adapter is the fallback
+)
+
+// Reasons a payload is ambiguous
+const (
+	ambiguousInvalidJSON        = "invalid_json"
+	ambiguousMalformedAlerts    = "malformed_alertmanager"
+	ambiguousEmptyAlerts        = "empty_alertmanager"
+	ambiguousUnrecognized       = "unrecognized"
+	ambiguousLegacyWithoutState = "legacy_without_state"
+)
+
+// PayloadMatch is the format a webhook payload was recognized as
+type PayloadMatch struct {
+	Format     string
+	Confidence string
+	Reason     string // what decided it
+	Ambiguous  string // why nothing matched, when the format is a fallback
+
+	alertmanager alertmanagerWebhook // the decoded body, when the format is FormatAlertmanager
+}
+
+// webhookProbe holds the fields that tell the webhook formats apart, so a body is unmarshaled
+// once to recognize it
+type webhookProbe struct {
+	SchemaID string `json:"schemaId"`
+	Incident *struct {
+		PolicyName string `json:"policy_name"`
+	} `json:"incident"`
+	IssueURL            string     `json:"issueUrl"`
+	AlertConditionNames []string   `json:"alertConditionNames"`
+	ConditionName       string     `json:"condition_name"`
+	Alerts              []struct{} `json:"alerts"`
+	RuleName            string     `json:"ruleName"`
+	State               string     `json:"state"`
+}
+
+// SniffWebhook recognizes the format of a body posted to the webhook endpoint. The formats are
+// tried in the order AdaptWebhook has always tried them, so the same body goes to the same
+// adapter; what's new is saying why, and when nothing but the legacy Grafana fallback is left.
+func SniffWebhook(body string) PayloadMatch {
+	var probe webhookProbe
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal([]byte(body), &probe); err != nil && !errors.As(err, &typeErr) {
+		// A body that isn't an object, or whose alerts aren't an array, still gets a
+		// chance as Alertmanager before the fallback reports the error
+		if webhook, amErr := decodeAlertmanagerWebhook(body); amErr == nil && webhook.AlertCount > 0 {
+			return PayloadMatch{Format: FormatAlertmanager, Confidence: ConfidenceHigh, Reason: "alerts array", alertmanager: webhook}
+		}
+		return fallbackMatch(ambiguousInvalidJSON, fmt.Sprintf("not a recognizable JSON object: %v", err))
+	}
+
+	switch {
+	case probe.SchemaID == azureCommonAlertSchemaID:
+		return PayloadMatch{Format: FormatAzure, Confidence: ConfidenceHigh, Reason: "schemaId " + probe.SchemaID}
+	case probe.Incident != nil && probe.Incident.PolicyName != "":
+		return PayloadMatch{Format: FormatGCP, Confidence: ConfidenceHigh, Reason: "incident.policy_name"}
+	case probe.IssueURL != "" || len(probe.AlertConditionNames) > 0:
+		return PayloadMatch{Format: FormatNewRelic, Confidence: ConfidenceHigh, Reason: "workflow issue fields"}
+	case probe.ConditionName != "":
+		return PayloadMatch{Format: FormatNewRelic, Confidence: ConfidenceMedium, Reason: "condition_name"}
+	}
+
+	if probe.Alerts != nil {
+		webhook, err := decodeAlertmanagerWebhook(body)
+		switch {
+		case err != nil:
+			return fallbackMatch(ambiguousMalformedAlerts, fmt.Sprintf("has alerts but isn't Alertmanager format: %v", err))
+		case webhook.AlertCount == 0:
+			return fallbackMatch(ambiguousEmptyAlerts, "Alertmanager format with no alerts")
+		}
+		return PayloadMatch{Format: FormatAlertmanager, Confidence: ConfidenceHigh, Reason: "alerts array", alertmanager: webhook}
+	}
+
+	switch {
+	case probe.RuleName != "" && probe.State != "":
+		return PayloadMatch{Format: FormatGrafanaLegacy, Confidence: ConfidenceMedium, Reason: "ruleName and state"}
+	case probe.RuleName != "":
+		return fallbackMatch(ambiguousLegacyWithoutState, "ruleName without a state")
+	}
+	return fallbackMatch(ambiguousUnrecognized, "no fields of a known format")
+}
+
+func fallbackMatch(ambiguous, reason string) PayloadMatch {
+	return PayloadMatch{Format: FormatGrafanaLegacy, Confidence: ConfidenceLow, Reason: reason, Ambiguous: ambiguous}
+}
@@ -0,0 +1,184 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// SplunkAlert is the body of a Splunk saved search's webhook alert action. Splunk sends the
+// first result of the search; result_count is only present when a custom alert action adds it.
+type SplunkAlert struct {
+	SearchName  string                     `json:"search_name"`
+	SID         string                     `json:"sid"`
+	ResultsLink string                     `json:"results_link"`
+	Owner       string                     `json:"owner"`
+	App         string                     `json:"app"`
+	Result      map[string]json.RawMessage `json:"result"` // field values are strings, or lists for multivalue fields
+	ResultCount json.RawMessage            `json:"result_count"`
+}
+
+// AdaptSplunkAlert maps a Splunk saved search alert to a routed alert. Splunk doesn't notify
+// when a search stops matching, so its alerts are always firing.
+func AdaptSplunkAlert(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var splunkAlert SplunkAlert
+	if err := json.Unmarshal([]byte(body), &splunkAlert); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceSplunk, Err: err}
+	}
+	if splunkAlert.SearchName == "" {
+		return nil, &errs.ParseError{Source: alert.SourceSplunk, Err: fmt.Errorf("no search_name")}
+	}
+
+	result := splunkResult(splunkAlert.Result)
+	priority := determineSplunkPriority(result)
+
+	// First check if there's a specific mapping for this search
+	channel := alarmChannels[splunkAlert.SearchName]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	// Result fields change from one firing to the next, so only the search's app and owner
+	// are labels and the fingerprint stays stable
+	labels := make(map[string]string)
+	if splunkAlert.App != "" {
+		labels["app"] = splunkAlert.App
+	}
+	if splunkAlert.Owner != "" {
+		labels["owner"] = splunkAlert.Owner
+	}
+
+	annotations := make(map[string]string)
+	if count := rawValue(splunkAlert.ResultCount); count != "" && count != "null" {
+		annotations[alert.AnnotationValue] = count
+	}
+	urls := make(map[string]string)
+	if splunkAlert.ResultsLink != "" {
+		urls[alert.URLSource] = splunkAlert.ResultsLink
+	}
+
+	const state = "TRIGGERED"
+	adapted := &alert.Alert{
+		Source:      alert.SourceSplunk,
+		Name:        splunkAlert.SearchName,
+		Severity:    priority,
+		Status:      alert.StatusFiring,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"sid":    splunkAlert.SID,
+			"result": result,
+		},
+		Message: formatSplunkSlackMessage(splunkAlert, state, result, fieldShower(fields, channel)),
+		Summary: formatCompactSplunkMessage(splunkAlert, state),
+		Raw:     body,
+	}
+	if seconds, err := strconv.ParseFloat(result["_time"], 64); err == nil && seconds > 0 {
+		startsAt := time.UnixMilli(int64(seconds * 1000)).UTC()
+		adapted.StartsAt = &startsAt
+	}
+	return adapted, nil
+}
+
+// splunkResult flattens the first result's fields to strings, joining multivalue fields
+func splunkResult(raw map[string]json.RawMessage) map[string]string {
+	result := make(map[string]string, len(raw))
+	for field, value := range raw {
+		var values []string
+		if json.Unmarshal(value, &values) == nil {
+			result[field] = strings.Join(values, ", ")
+			continue
+		}
+		result[field] = rawValue(value)
+	}
+	return result
+}
+
+// determineSplunkPriority reads the priority from the result: a channel field of P0-P2 wins,
+// then a severity or urgency field, where critical is P0, high P1 and anything else P2
+func determineSplunkPriority(result map[string]string) string {
+	switch strings.ToUpper(result["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(result["channel"])
+	}
+
+	severity := result["severity"]
+	if severity == "" {
+		severity = result["urgency"]
+	}
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "P0"
+	case "high":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+func formatSplunkSlackMessage(splunkAlert SplunkAlert, state string, result map[string]string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Splunk Alert: %s*\n• *State:* `%s`", stateEmoji(state), splunkAlert.SearchName, state)
+
+	if count := rawValue(splunkAlert.ResultCount); count != "" && count != "null" {
+		message += fmt.Sprintf("\n• *Result count:* *%s*", count)
+	}
+	if splunkAlert.App != "" {
+		message += fmt.Sprintf("\n• *App:* `%s`", splunkAlert.App)
+	}
+	if splunkAlert.Owner != "" {
+		message += fmt.Sprintf("\n• *Owner:* %s", splunkAlert.Owner)
+	}
+
+	// Skip Splunk's internal fields and the channel field, which is used for routing
+	var keys []string
+	for k := range result {
+		if !strings.HasPrefix(k, "_") && k != "channel" && show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *First result:*"
+		for _, k := range keys {
+			message += fmt.Sprintf("\n   → `%s`: %s", k, result[k])
+		}
+	}
+	if raw := result["_raw"]; raw != "" {
+		if len(raw) > 500 {
+			raw = raw[:497] + "..."
+		}
+		message += fmt.Sprintf("\n• *Event:* ```%s```", raw)
+	}
+
+	if splunkAlert.ResultsLink != "" {
+		message += fmt.Sprintf("\n• *Search:* <%s|View results in Splunk>", splunkAlert.ResultsLink)
+	}
+	return message
+}
+
+// formatCompactSplunkMessage renders a Splunk alert as a single line
+func formatCompactSplunkMessage(splunkAlert SplunkAlert, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), splunkAlert.SearchName, state)
+	if count := rawValue(splunkAlert.ResultCount); count != "" && count != "null" {
+		line += fmt.Sprintf(" %s results", count)
+	}
+	if splunkAlert.ResultsLink != "" {
+		line += fmt.Sprintf(" <%s|View>", splunkAlert.ResultsLink)
+	}
+	return line
+}
@@ -0,0 +1,191 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// StatusCakeAlert is a StatusCake uptime test webhook. StatusCake posts it as a form; a JSON
+// object with the same fields is accepted too.
+type StatusCakeAlert struct {
+	TestID       string `json:"TestID"`
+	Name         string `json:"Name"`
+	Status       string `json:"Status"`     // Up or Down
+	StatusCode   string `json:"StatusCode"` // HTTP status, or 0 when the request failed
+	URL          string `json:"URL"`
+	IP           string `json:"IP"`
+	Tags         string `json:"Tags"` // comma-separated
+	CheckRate    string `json:"CheckRate"`
+	ResponseTime string `json:"ResponseTime"` // ms, when sent
+}
+
+// parseStatusCakeAlert reads a form or JSON webhook body
+func parseStatusCakeAlert(body string) (StatusCakeAlert, error) {
+	var scAlert StatusCakeAlert
+	if strings.HasPrefix(strings.TrimSpace(body), "{") {
+		err := json.Unmarshal([]byte(body), &scAlert)
+		return scAlert, err
+	}
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return scAlert, err
+	}
+	scAlert = StatusCakeAlert{
+		TestID:       form.Get("TestID"),
+		Name:         form.Get("Name"),
+		Status:       form.Get("Status"),
+		StatusCode:   form.Get("StatusCode"),
+		URL:          form.Get("URL"),
+		IP:           form.Get("IP"),
+		Tags:         form.Get("Tags"),
+		CheckRate:    form.Get("CheckRate"),
+		ResponseTime: form.Get("ResponseTime"),
+	}
+	return scAlert, nil
+}
+
+// AdaptStatusCakeAlert maps a StatusCake uptime test going down or up to a routed alert
+func AdaptStatusCakeAlert(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	scAlert, err := parseStatusCakeAlert(body)
+	if err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceStatusCake, Err: err}
+	}
+	if scAlert.Name == "" {
+		return nil, &errs.ParseError{Source: alert.SourceStatusCake, Err: fmt.Errorf("no Name")}
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(scAlert.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	priority := determineStatusCakePriority(tags)
+
+	// First check if there's a specific mapping for this test
+	channel := alarmChannels[scAlert.Name]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := strings.ToUpper(scAlert.Status)
+	status := alert.StatusFiring
+	if state == "UP" {
+		status = alert.StatusResolved
+	}
+
+	// The IP and status code change from one notification to the next, so only the test's URL
+	// and tags are labels and a recovery resolves the alert its failure raised
+	labels := make(map[string]string, len(tags)+1)
+	for _, tag := range tags {
+		if !isPriorityTag(tag) {
+			labels[tag] = ""
+		}
+	}
+	if scAlert.URL != "" {
+		labels["target"] = scAlert.URL
+	}
+
+	annotations := make(map[string]string)
+	if scAlert.ResponseTime != "" {
+		annotations[alert.AnnotationValue] = scAlert.ResponseTime
+	}
+	urls := make(map[string]string)
+	if scAlert.TestID != "" {
+		urls[alert.URLSource] = fmt.Sprintf("https://app.statuscake.com/UptimeStatus.php?tid=%s", url.QueryEscape(scAlert.TestID))
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceStatusCake,
+		Name:        scAlert.Name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"test_id":     scAlert.TestID,
+			"status_code": scAlert.StatusCode,
+			"ip":          scAlert.IP,
+		},
+		Message: formatStatusCakeSlackMessage(scAlert, state, tags, urls[alert.URLSource], fieldShower(fields, channel)),
+		Summary: formatCompactStatusCakeMessage(scAlert, state, urls[alert.URLSource]),
+		Raw:     body,
+	}
+	return adapted, nil
+}
+
+// determineStatusCakePriority reads the priority from a P0, P1 or P2 tag. Tests only notify
+// when they go down and come back up, so everything else is P1, and a recovery goes wherever
+// its failure went.
+func determineStatusCakePriority(tags []string) string {
+	for _, tag := range tags {
+		if isPriorityTag(tag) {
+			return strings.ToUpper(tag)
+		}
+	}
+	return "P1"
+}
+
+func formatStatusCakeSlackMessage(scAlert StatusCakeAlert, state string, tags []string, link string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *StatusCake Test: %s*\n• *State:* `%s`", stateEmoji(state), scAlert.Name, state)
+
+	if scAlert.URL != "" {
+		message += fmt.Sprintf("\n• *Target:* `%s`", scAlert.URL)
+	}
+	if scAlert.StatusCode != "" {
+		message += fmt.Sprintf("\n• *Status code:* `%s`", scAlert.StatusCode)
+	}
+	if scAlert.ResponseTime != "" {
+		message += fmt.Sprintf("\n• *Response time:* *%s ms*", scAlert.ResponseTime)
+	}
+	if scAlert.IP != "" {
+		message += fmt.Sprintf("\n• *IP:* `%s`", scAlert.IP)
+	}
+
+	var shown []string
+	for _, tag := range tags {
+		if !isPriorityTag(tag) && show(tag) {
+			shown = append(shown, tag)
+		}
+	}
+	sort.Strings(shown)
+	if len(shown) > 0 {
+		message += "\n• *Tags:*"
+		for _, tag := range shown {
+			message += fmt.Sprintf("\n   → `%s`", tag)
+		}
+	}
+
+	if link != "" {
+		message += fmt.Sprintf("\n• *Test:* <%s|View in StatusCake>", link)
+	}
+	return message
+}
+
+// formatCompactStatusCakeMessage renders a StatusCake test state change as a single line
+func formatCompactStatusCakeMessage(scAlert StatusCakeAlert, state, link string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), scAlert.Name, state)
+	if scAlert.StatusCode != "" && state != "UP" {
+		line += fmt.Sprintf(" status %s", scAlert.StatusCode)
+	}
+	if link != "" {
+		line += fmt.Sprintf(" <%s|View>", link)
+	}
+	return line
+}
@@ -0,0 +1,214 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// Uptime Kuma heartbeat statuses
+const (
+	uptimeKumaDown        = 0
+	uptimeKumaUp          = 1
+	uptimeKumaPending     = 2
+	uptimeKumaMaintenance = 3
+)
+
+// UptimeKumaNotification is the body of an Uptime Kuma webhook notification. Test
+// notifications only have msg.
+type UptimeKumaNotification struct {
+	Heartbeat *struct {
+		MonitorID int      `json:"monitorID"`
+		Status    int      `json:"status"` // 0 down, 1 up, 2 pending, 3 maintenance
+		Time      string   `json:"time"`   // UTC, e.g. 2024-05-01 12:00:00.000
+		Msg       string   `json:"msg"`
+		Ping      *float64 `json:"ping"` // response time in ms
+	} `json:"heartbeat"`
+	Monitor *struct {
+		ID       int    `json:"id"`
+		Name     string `json:"name"`
+		Type     string `json:"type"` // http, keyword, port, ping, dns, ...
+		URL      string `json:"url"`
+		Hostname string `json:"hostname"`
+		Tags     []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tags"`
+	} `json:"monitor"`
+	Msg string `json:"msg"` // e.g. "[api] [🔴 Down] timeout of 48000ms exceeded"
+}
+
+// target is what the monitor checks: its URL for HTTP monitors, otherwise its hostname
+func (n UptimeKumaNotification) target() string {
+	if n.Monitor.URL != "" && n.Monitor.URL != "https://" {
+		return n.Monitor.URL
+	}
+	return n.Monitor.Hostname
+}
+
+// AdaptUptimeKumaNotification maps an Uptime Kuma monitor notification to a routed alert
+func AdaptUptimeKumaNotification(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var notification UptimeKumaNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceUptimeKuma, Err: err}
+	}
+	if notification.Monitor == nil || notification.Monitor.Name == "" || notification.Heartbeat == nil {
+		return nil, &errs.ParseError{Source: alert.SourceUptimeKuma, Err: fmt.Errorf("no monitor or heartbeat")}
+	}
+
+	tags := make(map[string]string, len(notification.Monitor.Tags))
+	for _, tag := range notification.Monitor.Tags {
+		tags[tag.Name] = tag.Value
+	}
+	priority := determineUptimeKumaPriority(tags)
+
+	// First check if there's a specific mapping for this monitor
+	channel := alarmChannels[notification.Monitor.Name]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := uptimeKumaState(notification.Heartbeat.Status)
+	status := alert.StatusFiring
+	if state == "UP" || state == "MAINTENANCE" {
+		status = alert.StatusResolved
+	}
+
+	labels := make(map[string]string, len(tags)+2)
+	for k, v := range tags {
+		switch k {
+		case "P0", "P1", "P2":
+			continue
+		}
+		labels[k] = v
+	}
+	if notification.Monitor.Type != "" {
+		labels["monitor_type"] = notification.Monitor.Type
+	}
+	if target := notification.target(); target != "" {
+		labels["target"] = target
+	}
+
+	annotations := make(map[string]string)
+	if notification.Heartbeat.Msg != "" {
+		annotations["description"] = notification.Heartbeat.Msg
+	}
+	if ping := notification.Heartbeat.Ping; ping != nil {
+		annotations[alert.AnnotationValue] = fmt.Sprintf("%g", *ping)
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceUptimeKuma,
+		Name:        notification.Monitor.Name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        make(map[string]string),
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"monitor_id": notification.Monitor.ID,
+		},
+		Message: formatUptimeKumaSlackMessage(notification, state, tags, fieldShower(fields, channel)),
+		Summary: formatCompactUptimeKumaMessage(notification, state),
+		Raw:     body,
+	}
+	if startsAt, err := time.Parse("2006-01-02 15:04:05.000", notification.Heartbeat.Time); err == nil {
+		adapted.StartsAt = &startsAt
+	}
+	return adapted, nil
+}
+
+// uptimeKumaState names a heartbeat status
+func uptimeKumaState(status int) string {
+	switch status {
+	case uptimeKumaDown:
+		return "DOWN"
+	case uptimeKumaUp:
+		return "UP"
+	case uptimeKumaPending:
+		return "PENDING"
+	case uptimeKumaMaintenance:
+		return "MAINTENANCE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// determineUptimeKumaPriority reads the priority from a channel tag of P0-P2 or a tag named
+// P0, P1 or P2. Monitors only notify when they go down and come back up, so everything else is
+// P1, and a recovery goes wherever its down notification went.
+func determineUptimeKumaPriority(tags map[string]string) string {
+	switch strings.ToUpper(tags["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(tags["channel"])
+	}
+	for _, priority := range []string{"P0", "P1", "P2"} {
+		if _, ok := tags[priority]; ok {
+			return priority
+		}
+	}
+	return "P1"
+}
+
+func formatUptimeKumaSlackMessage(notification UptimeKumaNotification, state string, tags map[string]string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Uptime Kuma: %s*\n• *Status:* `%s`", stateEmoji(state), notification.Monitor.Name, state)
+
+	if target := notification.target(); target != "" {
+		message += fmt.Sprintf("\n• *Target:* `%s`", target)
+	}
+	if notification.Monitor.Type != "" {
+		message += fmt.Sprintf("\n• *Type:* `%s`", notification.Monitor.Type)
+	}
+	if notification.Heartbeat.Msg != "" {
+		message += fmt.Sprintf("\n• *Message:* %s", notification.Heartbeat.Msg)
+	}
+	if ping := notification.Heartbeat.Ping; ping != nil {
+		message += fmt.Sprintf("\n• *Response time:* *%g ms*", *ping)
+	}
+
+	// Skip the channel and priority tags as they're used for routing
+	var keys []string
+	for k := range tags {
+		switch k {
+		case "channel", "P0", "P1", "P2":
+			continue
+		}
+		if show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Tags:*"
+		for _, k := range keys {
+			if tags[k] == "" {
+				message += fmt.Sprintf("\n   → `%s`", k)
+			} else {
+				message += fmt.Sprintf("\n   → `%s`: %s", k, tags[k])
+			}
+		}
+	}
+	return message
+}
+
+// formatCompactUptimeKumaMessage renders an Uptime Kuma notification as a single line
+func formatCompactUptimeKumaMessage(notification UptimeKumaNotification, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), notification.Monitor.Name, state)
+	if notification.Heartbeat.Msg != "" {
+		line += " " + notification.Heartbeat.Msg
+	}
+	return line
+}
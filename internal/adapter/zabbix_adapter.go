@@ -0,0 +1,244 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// ZabbixAlert is the body the webhook media type in the README posts for a trigger's problem
+// and recovery events. Zabbix fills in every field as a string.
+type ZabbixAlert struct {
+	EventID            string          `json:"event_id"`
+	EventName          string          `json:"event_name"`
+	EventStatus        string          `json:"event_status"` // PROBLEM or RESOLVED
+	EventSeverity      string          `json:"event_severity"`
+	EventDate          string          `json:"event_date"` // 2024.01.31
+	EventTime          string          `json:"event_time"` // 13:04:05
+	EventOpdata        string          `json:"event_opdata"`
+	EventTags          json.RawMessage `json:"event_tags"` // {EVENT.TAGSJSON}, an array or a string holding one
+	TriggerID          string          `json:"trigger_id"`
+	TriggerName        string          `json:"trigger_name"`
+	TriggerDescription string          `json:"trigger_description"`
+	Host               string          `json:"host"`
+	HostIP             string          `json:"host_ip"`
+	ItemValue          string          `json:"item_value"`
+	ZabbixURL          string          `json:"zabbix_url"`
+}
+
+// zabbixTag is one entry of {EVENT.TAGSJSON}
+type zabbixTag struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// AdaptZabbixWebhook maps a Zabbix trigger event to a routed alert
+func AdaptZabbixWebhook(body string, channels map[string]string, alarmChannels map[string]string, fields FieldFilter) (*alert.Alert, error) {
+	var zbxAlert ZabbixAlert
+	if err := json.Unmarshal([]byte(body), &zbxAlert); err != nil {
+		return nil, &errs.ParseError{Source: alert.SourceZabbix, Err: err}
+	}
+
+	name := zbxAlert.TriggerName
+	if name == "" {
+		name = zbxAlert.EventName
+	}
+	if name == "" {
+		return nil, &errs.ParseError{Source: alert.SourceZabbix, Err: fmt.Errorf("no trigger_name or event_name")}
+	}
+	tags := zabbixTags(zbxAlert.EventTags)
+	priority := determineZabbixPriority(zbxAlert.EventSeverity, tags)
+
+	// First check if there's a specific mapping for this trigger
+	channel := alarmChannels[name]
+
+	// If no specific mapping, use priority-based routing
+	if channel == "" {
+		channel = channels[priority]
+		if channel == "" {
+			channel = channels["default"]
+		}
+	}
+
+	state := strings.ToUpper(zbxAlert.EventStatus)
+	switch state {
+	case "":
+		state = "PROBLEM"
+	case "OK":
+		state = "RESOLVED"
+	}
+	status := alert.StatusFiring
+	if state == "RESOLVED" {
+		status = alert.StatusResolved
+	}
+
+	labels := make(map[string]string, len(tags)+2)
+	for k, v := range tags {
+		labels[k] = v
+	}
+	if zbxAlert.Host != "" {
+		labels["host"] = zbxAlert.Host
+	}
+	if zbxAlert.HostIP != "" {
+		labels["host_ip"] = zbxAlert.HostIP
+	}
+
+	annotations := make(map[string]string)
+	if zbxAlert.TriggerDescription != "" {
+		annotations["description"] = zbxAlert.TriggerDescription
+	}
+	if value := zabbixValue(zbxAlert); value != "" {
+		annotations[alert.AnnotationValue] = value
+	}
+	urls := make(map[string]string)
+	if link := zabbixEventLink(zbxAlert); link != "" {
+		urls[alert.URLSource] = link
+	}
+
+	adapted := &alert.Alert{
+		Source:      alert.SourceZabbix,
+		Name:        name,
+		Severity:    priority,
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      labels,
+		Annotations: annotations,
+		URLs:        urls,
+		ReceivedAt:  time.Now(),
+		Extensions: map[string]interface{}{
+			"event_id":        zbxAlert.EventID,
+			"trigger_id":      zbxAlert.TriggerID,
+			"zabbix_severity": zbxAlert.EventSeverity,
+		},
+		Message: formatZabbixSlackMessage(zbxAlert, name, state, tags, fieldShower(fields, channel)),
+		Summary: formatCompactZabbixMessage(zbxAlert, name, state),
+		Raw:     body,
+	}
+	if zbxAlert.EventDate != "" && zbxAlert.EventTime != "" {
+		adapted.StartsAt = parseTime("2006.01.02 15:04:05", zbxAlert.EventDate+" "+zbxAlert.EventTime)
+	}
+	return adapted, nil
+}
+
+// zabbixTags turns {EVENT.TAGSJSON} into labels. The media type may pass it on as the array
+// itself or as the string Zabbix expands the macro to.
+func zabbixTags(raw json.RawMessage) map[string]string {
+	labels := make(map[string]string)
+	if len(raw) == 0 {
+		return labels
+	}
+
+	var encoded string
+	if json.Unmarshal(raw, &encoded) == nil {
+		raw = json.RawMessage(encoded)
+	}
+	var tags []zabbixTag
+	if err := json.Unmarshal(raw, &tags); err != nil {
+		return labels
+	}
+	for _, tag := range tags {
+		if tag.Tag != "" {
+			labels[tag.Tag] = tag.Value
+		}
+	}
+	return labels
+}
+
+// zabbixValue is the operational data of the trigger, or the last value of its item
+func zabbixValue(zbxAlert ZabbixAlert) string {
+	if zbxAlert.EventOpdata != "" && !strings.HasPrefix(zbxAlert.EventOpdata, "*") {
+		return zbxAlert.EventOpdata
+	}
+	if zbxAlert.ItemValue != "" && !strings.HasPrefix(zbxAlert.ItemValue, "*") {
+		return zbxAlert.ItemValue
+	}
+	return ""
+}
+
+// zabbixEventLink opens the event in the Zabbix frontend
+func zabbixEventLink(zbxAlert ZabbixAlert) string {
+	if zbxAlert.ZabbixURL == "" || zbxAlert.TriggerID == "" || zbxAlert.EventID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/tr_events.php?triggerid=%s&eventid=%s", strings.TrimRight(zbxAlert.ZabbixURL, "/"), zbxAlert.TriggerID, zbxAlert.EventID)
+}
+
+// determineZabbixPriority maps Zabbix severities onto ours: Disaster is P0, High P1 and Average,
+// Warning, Information and Not classified P2. A channel:P0-P2 tag wins.
+func determineZabbixPriority(severity string, tags map[string]string) string {
+	switch strings.ToUpper(tags["channel"]) {
+	case "P0", "P1", "P2":
+		return strings.ToUpper(tags["channel"])
+	}
+
+	switch strings.ToLower(severity) {
+	case "disaster":
+		return "P0"
+	case "high":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+func formatZabbixSlackMessage(zbxAlert ZabbixAlert, name, state string, tags map[string]string, show func(string) bool) string {
+	message := fmt.Sprintf("%s *Zabbix Alert: %s*\n• *State:* `%s`", stateEmoji(state), name, state)
+
+	if zbxAlert.EventSeverity != "" {
+		message += fmt.Sprintf("\n• *Severity:* %s", zbxAlert.EventSeverity)
+	}
+	if zbxAlert.Host != "" {
+		message += fmt.Sprintf("\n• *Host:* `%s`", zbxAlert.Host)
+		if zbxAlert.HostIP != "" {
+			message += fmt.Sprintf(" (%s)", zbxAlert.HostIP)
+		}
+	}
+	if value := zabbixValue(zbxAlert); value != "" {
+		message += fmt.Sprintf("\n• *Value:* *%s*", value)
+	}
+	if zbxAlert.TriggerDescription != "" {
+		message += fmt.Sprintf("\n• *Description:* %s", zbxAlert.TriggerDescription)
+	}
+
+	// Skip the channel tag as it's used for routing
+	var keys []string
+	for k := range tags {
+		if k != "channel" && show(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		message += "\n• *Tags:*"
+		for _, k := range keys {
+			if tags[k] == "" {
+				message += fmt.Sprintf("\n   → `%s`", k)
+			} else {
+				message += fmt.Sprintf("\n   → `%s`: %s", k, tags[k])
+			}
+		}
+	}
+
+	if link := zabbixEventLink(zbxAlert); link != "" {
+		message += fmt.Sprintf("\n• *Event:* <%s|View in Zabbix>", link)
+	}
+	return message
+}
+
+// formatCompactZabbixMessage renders a Zabbix alert as a single line
+func formatCompactZabbixMessage(zbxAlert ZabbixAlert, name, state string) string {
+	line := fmt.Sprintf("%s *%s* `%s`", stateEmoji(state), name, state)
+	if zbxAlert.Host != "" {
+		line += fmt.Sprintf(" on `%s`", zbxAlert.Host)
+	}
+	if link := zabbixEventLink(zbxAlert); link != "" {
+		line += fmt.Sprintf(" <%s|View>", link)
+	}
+	return line
+}
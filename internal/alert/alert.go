@@ -0,0 +1,138 @@
+package alert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Alert sources
+const (
+	SourceCloudWatch   = "cloudwatch"
+	SourceGrafana      = "grafana"
+	SourceAlertmanager = "alertmanager"
+	SourceDatadog      = "datadog"
+	SourceNewRelic     = "newrelic"
+	SourceSentry       = "sentry"
+	SourceAzure        = "azure"
+	SourceGCP          = "gcp"
+	SourceZabbix       = "zabbix"
+	SourceNagios       = "nagios" // Nagios and Icinga
+	SourceDynatrace    = "dynatrace"
+	SourceSplunk       = "splunk"
+	SourceKibana       = "kibana"
+	SourceUptimeKuma   = "uptimekuma"
+	SourcePingdom      = "pingdom"
+	SourceStatusCake   = "statuscake"
+	SourceAWSCost      = "awscost"    // AWS Budgets and Cost Anomaly Detection
+	SourceCloudTrail   = "cloudtrail" // CloudTrail events delivered by EventBridge
+	SourceECS          = "ecs"        // ECS task state changes and service actions
+	SourceRDS          = "rds"        // RDS event subscriptions
+	SourceAWSBackup    = "awsbackup"  // AWS Backup job state changes
+	SourceLambda       = "lambda"     // failed asynchronous Lambda invocations
+	SourceCodeStar     = "codestar"   // CodePipeline and CodeBuild notifications
+	SourceGitHub       = "github"     // GitHub Actions workflow runs and deployments
+	SourceGitLab       = "gitlab"     // GitLab pipelines
+	SourceArgoCD       = "argocd"     // ArgoCD application notifications
+	SourceFlux         = "flux"       // Flux notification-controller events
+	SourceDispatcher   = "dispatcher" // alerts about the dispatcher itself
+)
+
+// Normalized alert statuses; the source-native value is kept in State
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusNoData   = "nodata" // INSUFFICIENT_DATA, NoData or DatasourceError
+)
+
+// Well-known URL keys
+const (
+	URLSource    = "source"    // the alarm/rule in the source system
+	URLDashboard = "dashboard" // a dashboard or panel showing the metric
+	URLSilence   = "silence"   // where to silence the alert upstream
+	URLImage     = "image"     // a rendered graph of the metric
+	URLPanel     = "panel"     // the Grafana panel the graph was rendered from
+)
+
+// AnnotationValue is the annotation holding the metric value that triggered the alert, for
+// sources that report one
+const AnnotationValue = "value"
+
+// Alert is the canonical alert every adapter produces and every renderer and sink consumes.
+// Its JSON form is the normalized alert posted by raw routes.
+type Alert struct {
+	Source      string            `json:"source"`
+	Name        string            `json:"name"`
+	Severity    string            `json:"severity"` // P0, P1 or P2
+	Status      string            `json:"status"`
+	State       string            `json:"state"` // source-native state, e.g. ALARM or alerting
+	Channel     string            `json:"channel"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	URLs        map[string]string `json:"urls,omitempty"`
+	StartsAt    *time.Time        `json:"starts_at,omitempty"`
+	EndsAt      *time.Time        `json:"ends_at,omitempty"`
+	ReceivedAt  time.Time         `json:"received_at"`
+
+	// Extensions holds source-specific fields that have no canonical equivalent
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+
+	// Message and Summary are the adapter's full and one-line Slack mrkdwn renderings
+	Message string `json:"-"`
+	Summary string `json:"-"`
+	// Raw is the original source payload, kept for the Raw payload button
+	Raw string `json:"-"`
+}
+
+func (a *Alert) IsResolved() bool {
+	return a.Status == StatusResolved
+}
+
+func (a *Alert) IsNoData() bool {
+	return a.Status == StatusNoData
+}
+
+// Lookup resolves a field expression such as "name", "severity", "labels.service" or
+// "annotations.summary" against the alert
+func (a *Alert) Lookup(expr string) string {
+	if label, ok := strings.CutPrefix(expr, "labels."); ok {
+		return a.Labels[label]
+	}
+	if annotation, ok := strings.CutPrefix(expr, "annotations."); ok {
+		return a.Annotations[annotation]
+	}
+	switch expr {
+	case "source":
+		return a.Source
+	case "name":
+		return a.Name
+	case "status":
+		return a.Status
+	case "state":
+		return a.State
+	case "severity", "priority":
+		return a.Severity
+	case "channel":
+		return a.Channel
+	}
+	return ""
+}
+
+// Fingerprint identifies the alert across firings: it hashes the source, name and labels, which
+// stay the same while status, annotations and timestamps change
+func (a *Alert) Fingerprint() string {
+	names := make([]string, 0, len(a.Labels))
+	for name := range a.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	h.Write([]byte(a.Source + "\x00" + a.Name))
+	for _, name := range names {
+		h.Write([]byte("\x00" + name + "=" + a.Labels[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
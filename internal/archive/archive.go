@@ -0,0 +1,129 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/state"
+)
+
+// Record is what we keep about a dispatched alert so buttons can expand it later
+type Record struct {
+	Version     int          `json:"version"` // RecordVersion when written
+	Alert       *alert.Alert `json:"alert,omitempty"`
+	Fingerprint string       `json:"fingerprint,omitempty"` // the alert's, as computed when stored
+	Message     string       `json:"message"`
+	Payload     string       `json:"payload,omitempty"` // original source payload as received
+	StoredAt    time.Time    `json:"stored_at"`
+}
+
+// Archive is a TTL-bounded store of dispatched alerts keyed by alert ID. Records live in the
+// shared state store, so any replica can expand them; each replica also remembers the IDs it
+// stored so the archiver can move them to long-term storage.
+type Archive struct {
+	store state.Store
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	local map[string]time.Time // alert ID -> stored at, for records put by this replica
+}
+
+func NewArchive(store state.Store, ttl time.Duration) *Archive {
+	return &Archive{
+		store: store,
+		ttl:   ttl,
+		local: make(map[string]time.Time),
+	}
+}
+
+func recordKey(alertID string) string {
+	return "archive:" + alertID
+}
+
+func (a *Archive) Put(ctx context.Context, alertID string, record Record) error {
+	now := time.Now()
+	record.Version = RecordVersion
+	record.StoredAt = now
+	if record.Alert != nil && record.Fingerprint == "" {
+		record.Fingerprint = record.Alert.Fingerprint()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %v", err)
+	}
+	if err := a.store.Set(ctx, recordKey(alertID), string(data), a.ttl); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.local[alertID] = now
+	// Forget expired records while we hold the lock
+	for id, storedAt := range a.local {
+		if now.Sub(storedAt) > a.ttl {
+			delete(a.local, id)
+		}
+	}
+	return nil
+}
+
+func (a *Archive) Get(ctx context.Context, alertID string) (Record, bool, error) {
+	data, ok, err := a.store.Get(ctx, recordKey(alertID))
+	if err != nil || !ok {
+		return Record{}, false, err
+	}
+
+	record, err := decodeRecord([]byte(data))
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to unmarshal record: %v", err)
+	}
+	return record, true, nil
+}
+
+// OlderThan returns the records this replica stored more than age ago
+func (a *Archive) OlderThan(ctx context.Context, age time.Duration) (map[string]Record, error) {
+	a.mu.Lock()
+	var ids []string
+	for id, storedAt := range a.local {
+		if time.Since(storedAt) > age {
+			ids = append(ids, id)
+		}
+	}
+	a.mu.Unlock()
+
+	older := make(map[string]Record)
+	for _, id := range ids {
+		record, ok, err := a.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			older[id] = record
+		}
+	}
+	return older, nil
+}
+
+func (a *Archive) Delete(ctx context.Context, alertIDs ...string) error {
+	keys := make([]string, len(alertIDs))
+	for i, id := range alertIDs {
+		keys[i] = recordKey(id)
+	}
+	if err := a.store.Delete(ctx, keys...); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, id := range alertIDs {
+		delete(a.local, id)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// Uploader stores an object outside the process, e.g. in S3
+type Uploader interface {
+	Upload(ctx context.Context, key string, data []byte) error
+}
+
+// Archiver periodically moves records older than a cutoff from the archive to long-term storage
+// as gzipped JSON lines, partitioned by day ("<prefix>/dt=YYYY-MM-DD/...") so Athena can query them
+type Archiver struct {
+	archive  *Archive
+	uploader Uploader
+	prefix   string
+	after    time.Duration
+	interval time.Duration
+}
+
+func NewArchiver(archive *Archive, uploader Uploader, prefix string, after, interval time.Duration) *Archiver {
+	return &Archiver{
+		archive:  archive,
+		uploader: uploader,
+		prefix:   prefix,
+		after:    after,
+		interval: interval,
+	}
+}
+
+// archivedRecord is one line of an archived object
+type archivedRecord struct {
+	Version     int          `json:"version"`
+	AlertID     string       `json:"alert_id"`
+	StoredAt    time.Time    `json:"stored_at"`
+	Fingerprint string       `json:"fingerprint,omitempty"`
+	Alert       *alert.Alert `json:"alert,omitempty"`
+	Payload     string       `json:"payload,omitempty"`
+}
+
+// Run archives on every interval until ctx is cancelled
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.ArchiveOnce(ctx); err != nil {
+				log.Printf("Failed to archive alert history: %v", err)
+			}
+		}
+	}
+}
+
+// ArchiveOnce uploads records older than the cutoff, one object per day, and removes each day's
+// records from the archive once its object is stored
+func (a *Archiver) ArchiveOnce(ctx context.Context) error {
+	older, err := a.archive.OlderThan(ctx, a.after)
+	if err != nil {
+		return fmt.Errorf("failed to read archived alerts: %v", err)
+	}
+
+	days := make(map[string][]archivedRecord)
+	for id, record := range older {
+		day := record.StoredAt.UTC().Format("2006-01-02")
+		days[day] = append(days[day], archivedRecord{
+			Version:     record.Version,
+			AlertID:     id,
+			StoredAt:    record.StoredAt,
+			Fingerprint: record.Fingerprint,
+			Alert:       record.Alert,
+			Payload:     record.Payload,
+		})
+	}
+
+	host, _ := os.Hostname()
+	for day, records := range days {
+		sort.Slice(records, func(i, j int) bool { return records[i].StoredAt.Before(records[j].StoredAt) })
+
+		data, err := gzipJSONLines(records)
+		if err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("%s/dt=%s/%s-%d.jsonl.gz", a.prefix, day, host, time.Now().UnixNano())
+		if err := a.uploader.Upload(ctx, key, data); err != nil {
+			return fmt.Errorf("failed to upload %s: %v", key, err)
+		}
+
+		ids := make([]string, len(records))
+		for i, r := range records {
+			ids[i] = r.AlertID
+		}
+		if err := a.archive.Delete(ctx, ids...); err != nil {
+			return fmt.Errorf("failed to prune archived alerts: %v", err)
+		}
+		log.Printf("Archived %d alerts to %s", len(records), key)
+	}
+	return nil
+}
+
+func gzipJSONLines(records []archivedRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, fmt.Errorf("failed to encode archived record: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archived records: %v", err)
+	}
+	return buf.Bytes(), nil
+}
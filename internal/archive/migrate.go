@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// RecordVersion is the schema version of records written by this build. Bump it, and add a
+// migration from the previous version, whenever a change to Record or alert.Alert would misread
+// records already in the state store.
+//
+//	1: unversioned records, as written before versioning
+//	2: adds version and the alert fingerprint
+const RecordVersion = 2
+
+// migrations[v] upgrades a record from version v to v+1. They work on the record's JSON object,
+// so fields the current structs no longer have can still be read and carried over.
+var migrations = map[int]func(fields map[string]json.RawMessage) error{
+	1: addFingerprint,
+}
+
+// decodeRecord reads a stored record of any version. Older records are migrated forward;
+// records written by a newer build, e.g. during a rolling upgrade, are read as far as this one
+// understands them, since fields are only ever added or migrated.
+func decodeRecord(data []byte) (Record, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return Record{}, err
+	}
+
+	var record Record
+	if header.Version >= RecordVersion {
+		err := json.Unmarshal(data, &record)
+		return record, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Record{}, err
+	}
+	version := header.Version
+	if version == 0 {
+		version = 1
+	}
+	for ; version < RecordVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return Record{}, fmt.Errorf("no migration from record version %d", version)
+		}
+		if err := migrate(fields); err != nil {
+			return Record{}, fmt.Errorf("failed to migrate record from version %d: %v", version, err)
+		}
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(version))
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return Record{}, err
+	}
+	err = json.Unmarshal(migrated, &record)
+	return record, err
+}
+
+// addFingerprint stores the fingerprint of the record's alert, so history stays grouped by alert
+// even if the way fingerprints are computed changes later
+func addFingerprint(fields map[string]json.RawMessage) error {
+	raw, ok := fields["alert"]
+	if !ok {
+		return nil
+	}
+
+	var a alert.Alert
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return err
+	}
+	fingerprint, err := json.Marshal(a.Fingerprint())
+	if err != nil {
+		return err
+	}
+	fields["fingerprint"] = fingerprint
+	return nil
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+// Where an action was taken
+const (
+	ViaButton     = "button"
+	ViaDigest     = "digest"
+	ViaCommand    = "command"
+	ViaTelegram   = "telegram"
+	ViaMattermost = "mattermost"
+)
+
+var alertActions = metrics.NewCounter("alert_dispatcher_alert_actions_total",
+	"Actions people took on alerts, by action and where they were made.", "action", "via")
+
+// Entry records one action a person took on one alert
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"` // acknowledge, dismiss, snooze, change_priority or quick_action
+	AlertID   string    `json:"alert_id"`
+	AlertName string    `json:"alert_name,omitempty"`
+	User      string    `json:"user"`
+	Via       string    `json:"via"`
+	Detail    string    `json:"detail,omitempty"` // e.g. "P2 -> P0", a snooze's duration or what a quick action did
+}
+
+// How long writing an entry to the Writer may take
+const writeTimeout = 5 * time.Second
+
+// Writer keeps audit entries outside the logs; *postgres.Store implements it
+type Writer interface {
+	WriteAudit(ctx context.Context, entry Entry) error
+}
+
+var (
+	writerMu sync.RWMutex
+	writer   Writer
+)
+
+// Use makes Record also hand every entry to w
+func Use(w Writer) {
+	writerMu.Lock()
+	defer writerMu.Unlock()
+	writer = w
+}
+
+// Record writes the entry to the log as a JSON line prefixed with "audit:" and counts it. With
+// a Writer in use, the entry is also written there in the background, so the action it records
+// isn't held up.
+func Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Failed to marshal audit entry for %s: %v", entry.AlertID, err)
+		return
+	}
+	log.Printf("audit: %s", line)
+	alertActions.Inc(entry.Action, entry.Via)
+
+	writerMu.RLock()
+	w := writer
+	writerMu.RUnlock()
+	if w == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		defer cancel()
+		if err := w.WriteAudit(ctx, entry); err != nil {
+			log.Printf("Failed to write audit entry for %s: %v", entry.AlertID, err)
+		}
+	}()
+}
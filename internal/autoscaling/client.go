@@ -0,0 +1,76 @@
+package autoscaling
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"alert-dispatcher/internal/awsquery"
+)
+
+// apiVersion is the version of the Auto Scaling query API the client speaks
+const apiVersion = "2011-01-01"
+
+// Client scales Auto Scaling groups through the Auto Scaling query API
+type Client struct {
+	query *awsquery.Client
+}
+
+func NewClient(timeout time.Duration) (*Client, error) {
+	query, err := awsquery.NewClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{query: query}, nil
+}
+
+type describeGroupsResponse struct {
+	Groups []struct {
+		Name            string `xml:"AutoScalingGroupName"`
+		DesiredCapacity int    `xml:"DesiredCapacity"`
+		MaxSize         int    `xml:"MaxSize"`
+	} `xml:"DescribeAutoScalingGroupsResult>AutoScalingGroups>member"`
+}
+
+// ScaleGroup adds step instances to the group's desired capacity, up to max or the group's max
+// size, whichever is lower, and returns the capacity before and after. A group already at its
+// limit is an error. An empty region is the default chain's.
+func (c *Client) ScaleGroup(ctx context.Context, region, group string, step, max int) (int, int, error) {
+	var described describeGroupsResponse
+	err := c.query.Call(ctx, "autoscaling", apiVersion, region, url.Values{
+		"Action":                         {"DescribeAutoScalingGroups"},
+		"AutoScalingGroupNames.member.1": {group},
+	}, &described)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(described.Groups) == 0 {
+		return 0, 0, fmt.Errorf("no Auto Scaling group %s", group)
+	}
+
+	from := described.Groups[0].DesiredCapacity
+	limit := described.Groups[0].MaxSize
+	if max > 0 && max < limit {
+		limit = max
+	}
+	to := from + step
+	if to > limit {
+		to = limit
+	}
+	if to <= from {
+		return from, from, fmt.Errorf("%s is already at its limit of %d instances", group, limit)
+	}
+
+	err = c.query.Call(ctx, "autoscaling", apiVersion, region, url.Values{
+		"Action":               {"SetDesiredCapacity"},
+		"AutoScalingGroupName": {group},
+		"DesiredCapacity":      {strconv.Itoa(to)},
+		"HonorCooldown":        {"false"},
+	}, nil)
+	if err != nil {
+		return from, from, err
+	}
+	return from, to, nil
+}
@@ -0,0 +1,152 @@
+package awsquery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+
+	"alert-dispatcher/internal/httpclient"
+)
+
+// Client calls AWS query APIs, such as CloudWatch's and Auto Scaling's, signing requests with
+// the default AWS credential chain
+type Client struct {
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+	region      string // the default chain's region, for calls that don't name one
+}
+
+func NewClient(timeout time.Duration) (*Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      httpclient.New(timeout),
+		region:      cfg.Region,
+	}, nil
+}
+
+// errorResponse is the body of a failed query API call
+type errorResponse struct {
+	Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+}
+
+// Call POSTs params to the service's endpoint in region, or the default region when empty, and
+// decodes the XML response into out unless it is nil
+func (c *Client) Call(ctx context.Context, service, version, region string, params url.Values, out interface{}) error {
+	params.Set("Version", version)
+	action := params.Get("Action")
+	resp, err := c.send(ctx, service, region, action, params.Encode(), map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded; charset=utf-8",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var failure errorResponse
+		if xml.Unmarshal(data, &failure) == nil && failure.Error.Code != "" {
+			return fmt.Errorf("%s failed: %s: %s", action, failure.Error.Code, failure.Error.Message)
+		}
+		return fmt.Errorf("%s failed: %s", action, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", action, err)
+	}
+	return nil
+}
+
+// jsonErrorResponse is the body of a failed JSON protocol call
+type jsonErrorResponse struct {
+	Type    string `json:"__type"` // e.g. com.amazonaws.logs#ResourceNotFoundException
+	Message string `json:"message"`
+}
+
+// CallJSON calls an operation of an AWS JSON protocol API, such as CloudWatch Logs', whose
+// target is e.g. Logs_20140328.StartQuery, and decodes the response into out unless it is nil
+func (c *Client) CallJSON(ctx context.Context, service, target, region string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	_, operation, _ := strings.Cut(target, ".")
+	resp, err := c.send(ctx, service, region, operation, string(body), map[string]string{
+		"Content-Type": "application/x-amz-json-1.1",
+		"X-Amz-Target": target,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var failure jsonErrorResponse
+		if json.Unmarshal(data, &failure) == nil && failure.Type != "" {
+			_, code, _ := strings.Cut(failure.Type, "#")
+			if code == "" {
+				code = failure.Type
+			}
+			return fmt.Errorf("%s failed: %s: %s", operation, code, failure.Message)
+		}
+		return fmt.Errorf("%s failed: %s", operation, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", operation, err)
+	}
+	return nil
+}
+
+// send POSTs the signed body to the service's endpoint in region, or the default region when empty
+func (c *Client) send(ctx context.Context, service, region, operation, body string, headers map[string]string) (*http.Response, error) {
+	if region == "" {
+		region = c.region
+	}
+	if region == "" {
+		return nil, fmt.Errorf("%s failed: no AWS region", operation)
+	}
+	endpoint := fmt.Sprintf("https://%s.%s.amazonaws.com/", service, region)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	credentials, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %v", err)
+	}
+	hash := sha256.Sum256([]byte(body))
+	if err := c.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), service, region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %v", err)
+	}
+	return c.client.Do(req)
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+// How often writes also sweep out expired entries that are never read again
+const sweepInterval = time.Minute
+
+var (
+	cacheRequests = metrics.NewCounter("alert_dispatcher_cache_requests_total",
+		"Cache lookups by cache and result (hit or miss).", "cache", "result")
+	cacheEvictions = metrics.NewCounter("alert_dispatcher_cache_evictions_total",
+		"Entries evicted to stay within the cache size.", "cache")
+	cacheEntries = metrics.NewGauge("alert_dispatcher_cache_entries",
+		"Entries currently held, including expired ones not yet removed.", "cache")
+)
+
+// Cache is an in-process, size-bounded map whose entries expire after a TTL. When full, the
+// least recently used entry is evicted. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	name string
+	size int // 0 means unbounded
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	entries   map[K]*list.Element
+	order     *list.List // most recently used first
+	lastSweep time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// New creates a cache reported as name in metrics, holding at most size entries (0 for no limit)
+// that expire ttl after they are set
+func New[K comparable, V any](name string, size int, ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		name:    name,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[K]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the value for key, or false when it is missing or expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key, time.Now())
+	if !ok {
+		cacheRequests.Inc(c.name, "miss")
+		var zero V
+		return zero, false
+	}
+	cacheRequests.Inc(c.name, "hit")
+	return e.value, true
+}
+
+// Set stores the value with the cache's TTL
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL stores the value with its own TTL
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, ttl, time.Now())
+}
+
+// Add stores the value only when key is missing or expired, reporting whether it did
+func (c *Cache[K, V]) Add(key K, value V, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.lookup(key, now); ok {
+		return false
+	}
+	c.set(key, value, ttl, now)
+	return true
+}
+
+func (c *Cache[K, V]) Delete(keys ...K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+	}
+	cacheEntries.Set(float64(len(c.entries)), c.name)
+}
+
+// Entries returns the live entries, without marking them recently used
+func (c *Cache[K, V]) Entries() map[K]V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make(map[K]V, len(c.entries))
+	for key, elem := range c.entries {
+		if e := elem.Value.(*entry[K, V]); !now.After(e.expiresAt) {
+			entries[key] = e.value
+		}
+	}
+	return entries
+}
+
+// Len returns the number of entries, including expired ones not yet removed
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// lookup returns the live entry for key, marking it recently used and removing it if expired
+func (c *Cache[K, V]) lookup(key K, now time.Time) (*entry[K, V], bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry[K, V])
+	if now.After(e.expiresAt) {
+		c.remove(elem)
+		cacheEntries.Set(float64(len(c.entries)), c.name)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return e, true
+}
+
+func (c *Cache[K, V]) set(key K, value V, ttl time.Duration, now time.Time) {
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = now.Add(ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: now.Add(ttl)})
+	if now.Sub(c.lastSweep) > sweepInterval {
+		c.sweep(now)
+	}
+	if c.size > 0 && len(c.entries) > c.size {
+		c.sweep(now)
+		c.evict()
+	}
+	cacheEntries.Set(float64(len(c.entries)), c.name)
+}
+
+// sweep drops expired entries
+func (c *Cache[K, V]) sweep(now time.Time) {
+	c.lastSweep = now
+	for elem := c.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.After(elem.Value.(*entry[K, V]).expiresAt) {
+			c.remove(elem)
+		}
+		elem = prev
+	}
+}
+
+// evict drops the least recently used entries until the cache is within its size
+func (c *Cache[K, V]) evict() {
+	for len(c.entries) > c.size {
+		c.remove(c.order.Back())
+		cacheEvictions.Inc(c.name)
+	}
+}
+
+func (c *Cache[K, V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,7 @@
+package config
+
+// AWSBackupChannel returns the backup_channel AWS Backup job alerts go to, or "" to route them
+// by priority
+func (c *Config) AWSBackupChannel() string {
+	return c.BackupChannel
+}
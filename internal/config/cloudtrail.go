@@ -0,0 +1,34 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// CloudTrailRule sets the priority, and optionally the channel, of the CloudTrail events it
+// matches. Rules apply in order, the first match winning, before the built-in ones.
+type CloudTrailRule struct {
+	EventName    string `yaml:"event_name"`    // with shell wildcards, e.g. "Put*Policy"
+	IdentityType string `yaml:"identity_type"` // Root, IAMUser, AssumedRole, ...; empty matches any
+	Priority     string `yaml:"priority"`
+	Channel      string `yaml:"channel"`
+}
+
+func (r CloudTrailRule) matches(eventName, identityType string) bool {
+	if r.IdentityType != "" && !strings.EqualFold(r.IdentityType, identityType) {
+		return false
+	}
+	matched, _ := path.Match(r.EventName, eventName)
+	return matched
+}
+
+// CloudTrailRoute returns the priority and channel of the first cloudtrail_rules entry matching
+// the event, reporting false when none does
+func (c *Config) CloudTrailRoute(eventName, identityType string) (priority, channel string, ok bool) {
+	for _, rule := range c.CloudTrailRules {
+		if rule.matches(eventName, identityType) {
+			return rule.Priority, rule.Channel, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,7 @@
+package config
+
+// PipelineChannel returns the channel of a CodePipeline pipeline's or CodeBuild project's alerts
+// from pipeline_channels, or "" to route them by priority
+func (c *Config) PipelineChannel(name string) string {
+	return c.PipelineChannels[name]
+}
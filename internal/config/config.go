@@ -1,28 +1,594 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/errs"
+	"alert-dispatcher/internal/render"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
 	SQSQueueURL        string
+	SQSDeadLetterURL   string // queue that messages failing for good are moved to, instead of being retried
 	SlackWebhookURL    string
 	SlackBotToken      string
 	SlackSigningSecret string
+	SlackAPIURL        string // Slack Web API base URL, empty for https://slack.com/api/
+
+	// Standby workspace alerts are posted to while the primary keeps returning auth or rate
+	// limit errors; empty SlackStandbyBotToken disables failover
+	SlackStandbyBotToken      string
+	SlackStandbySigningSecret string
+	SlackStandbyAPIURL        string
+	SlackFailoverAfter        time.Duration // how long the primary must keep failing before switching
+
 	ServerPort         string
 	PollIntervalSec    int
+	SQSMaxReceivers    int           // receive loops run at once while the queue is backed up
+	SQSMaxIdleWait     time.Duration // longest pause between empty receives while the queue is idle
+	SlackTimeout       time.Duration // bound on each Slack API call
+	WebhookTimeout     time.Duration // bound on each outbound webhook call, e.g. Slack response_url
+	MessageTimeout     time.Duration // bound on processing each SQS message, after which it is redelivered
+	QuickActionTimeout time.Duration // bound on each AWS or Kubernetes call of a quick action
+	LogQueryTimeout    time.Duration // bound on running a Fetch logs query, including waiting for Logs Insights
+	LokiToken          string        // bearer token for Loki log queries, empty for none
+	GrafanaURL         string        // Grafana whose panels the Refresh graph button renders; empty disables it
+	GrafanaRenderToken string        // Grafana service account token for rendering panels, empty for anonymous access
+	GraphRenderTimeout time.Duration // bound on rendering a panel for the Refresh graph button
+	ExportAlertMetrics bool          // expose an ALERTS-style gauge of active alerts on /metrics
+	PriorityEditors    []string      // Slack user IDs allowed to change alert priorities; empty allows no one
+	PublicURL          string        // externally reachable base URL, used for alert permalinks
+	SNSTopicARN        string        // topic every delivered alert is republished to as JSON
+	GrafanaSecret      string        // required as a bearer token, X-Webhook-Secret header or token query parameter of Grafana webhooks; unset accepts any
+	DatadogSecret      string        // required in the X-Webhook-Secret header of Datadog webhooks
+	SentrySecret       string        // client secret Sentry signs webhooks with
+	GitHubSecret       string        // secret GitHub signs webhooks with
+	GitLabToken        string        // required in the X-Gitlab-Token header of GitLab webhooks
+	ArgoCDSecret       string        // required in the X-Webhook-Secret header of ArgoCD notifications
+	FluxSecret         string        // key Flux signs events with in X-Signature
+	OpsChannel         string        // Slack channel for config lint findings and alerts about the dispatcher itself
+	ZabbixSecret       string        // required in the X-Webhook-Secret header of Zabbix webhooks
+	NagiosSecret       string        // required in the X-Webhook-Secret header of Nagios and Icinga notifications
+	DynatraceSecret    string        // required in the X-Webhook-Secret header of Dynatrace problem notifications
+	KibanaSecret       string        // required in the X-Webhook-Secret header of Kibana alerts
+	UptimeKumaSecret   string        // required in the X-Webhook-Secret header of Uptime Kuma notifications
+	PingdomToken       string        // required in the token query parameter or X-Webhook-Secret header of Pingdom webhooks
+	StatusCakeToken    string        // required in the token query parameter or X-Webhook-Secret header of StatusCake webhooks
+	SplunkToken        string        // required in the token query parameter or X-Webhook-Secret header of Splunk alerts
+	AlertmanagerToken  string        // bearer token the Alertmanager API requires; the API isn't served without it
+	ReceiptsToken      string        // bearer token receipts of alerts that didn't come through a configured webhook require
+	PermalinkToken     string        // bearer token alert permalinks require; they aren't served without it
+	Kafka              KafkaConfig
+	OpenSearch         OpenSearchConfig
+	ClickHouse         ClickHouseConfig
+	Opsgenie           OpsgenieConfig
+	PagerDuty          PagerDutyConfig
+	Pushover           PushoverConfig
+	Ntfy               NtfyConfig
+	Mattermost         MattermostConfig
+	S3Archive          S3ArchiveConfig
+	SQSBacklog         SQSBacklogConfig
+	Email              EmailConfig
+	State              StateConfig
 	SlackChannels      map[string]string
 	AlarmChannels      map[string]string
+	PriorityTargets    map[string][]TargetConfig
+	AlarmTargets       map[string][]TargetConfig
+	Routes             map[string]RouteConfig
+	DropRules          []DropRule
+	Notifiers          NotifiersConfig
+	Teams              map[string]TeamConfig
+	Templates          map[string]string
+	EmojiSets          map[string]EmojiSet
+	SentryProjects     map[string]string // Sentry project slug or ID to Slack channel
+	GitHubRepos        map[string]string // GitHub repository (owner/name) to Slack channel
+	GitLabProjects     map[string]string // GitLab project path (group/project) to Slack channel
+	ArgoCDApps         map[string]string // ArgoCD application name to Slack channel
+	FluxObjects        map[string]string // Flux object namespace/name, or namespace, to Slack channel
+	DynatraceZones     map[string]string // Dynatrace management zone to Slack channel
+	Webhooks           map[string]WebhookConfig
+	CloudTrailRules    []CloudTrailRule
+	RDSEventPriorities map[string]string       // RDS event category to priority
+	BackupChannel      string                  // channel of AWS Backup job alerts
+	PipelineChannels   map[string]string       // CodePipeline pipeline or CodeBuild project to Slack channel
+	SilenceSync        *SilenceSyncConfig      // nil unless silence_sync is configured
+	CloudWatchSnooze   *CloudWatchSnoozeConfig // nil unless cloudwatch_snooze is configured
+	AWSConsole         AWSConsoleConfig
+	Heartbeats         map[string]time.Duration // input to how long it may go without alerts
+	SourceQuotas       map[string]SourceQuota   // by source, or "*" for every other source
+	Kubernetes         KubernetesConfig
+
+	overlay      overlayState  // routes, templates and silences from custom resources
+	loadFindings []LintFinding // problems reading alarm-channels.yaml
+}
+
+// OpenSearchConfig enables indexing alert events into OpenSearch or Elasticsearch when URL is set
+type OpenSearchConfig struct {
+	URL         string
+	IndexPrefix string
+	Username    string
+	Password    string
+	Timeout     time.Duration
+}
+
+// ClickHouseConfig enables inserting alert events into ClickHouse when URL is set
+type ClickHouseConfig struct {
+	URL      string // HTTP interface, e.g. http://clickhouse:8123
+	Table    string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// OpsgenieConfig enables paging through Opsgenie when APIKey is set
+type OpsgenieConfig struct {
+	APIKey     string
+	APIURL     string   // https://api.eu.opsgenie.com for EU accounts
+	Priorities []string // alert priorities that page, e.g. P0
+	Timeout    time.Duration
+}
+
+// PagerDutyConfig holds the Events API v2 integration used by pagerduty targets
+type PagerDutyConfig struct {
+	RoutingKey string
+	EventsURL  string
+	Timeout    time.Duration
+}
+
+// PushoverConfig holds the Pushover application used by pushover targets
+type PushoverConfig struct {
+	AppToken string
+	UserKey  string // user or delivery group key targets without their own
+	Timeout  time.Duration
+}
+
+// NtfyConfig holds the ntfy server used by ntfy targets
+type NtfyConfig struct {
+	URL     string
+	Token   string // access token for protected topics
+	Timeout time.Duration
+}
+
+// KafkaConfig enables writing every delivered alert to a Kafka topic when Brokers is set
+type KafkaConfig struct {
+	Brokers       []string
+	Topic         string
+	TLS           bool
+	SASLMechanism string // plain, scram-sha-256 or scram-sha-512; empty disables SASL
+	Username      string
+	Password      string
+	Timeout       time.Duration
+}
+
+// MattermostConfig enables posting to the Mattermost channels of routes when URL is set
+type MattermostConfig struct {
+	URL          string // server URL, e.g. https://mattermost.example.com
+	BotToken     string
+	ActionURL    string // public URL of /mattermost/actions; buttons are omitted when empty
+	ActionSecret string // echoed back by button presses to authenticate them; no buttons without it
+	Timeout      time.Duration
+}
+
+// State backends
+const (
+	StateMemory   = "memory"
+	StateRedis    = "redis"
+	StateDynamoDB = "dynamodb"
+	StatePostgres = "postgres"
+)
+
+// StateConfig selects where threads and archived alerts are kept. Multi-replica deployments
+// need a shared backend so buttons and threads work whichever replica handles them.
+type StateConfig struct {
+	Backend        string // "memory" (default), "redis", "dynamodb" or "postgres"
+	RedisAddr      string
+	RedisPassword  string
+	RedisDB        int
+	RedisKeyPrefix string
+	DynamoDBTable  string
+	PostgresDSN    string
+}
+
+// Email backends
+const (
+	EmailSMTP = "smtp"
+	EmailSES  = "ses"
+)
+
+// EmailConfig enables emailing alerts of the configured priorities when Backend is set
+type EmailConfig struct {
+	Backend      string // "smtp" or "ses"
+	From         string
+	To           []string // default recipients; routes can override them with email_to
+	Priorities   []string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	Timeout      time.Duration
+}
+
+// S3ArchiveConfig enables archiving alert history to S3 when Bucket is set
+type S3ArchiveConfig struct {
+	Bucket   string
+	Prefix   string
+	After    time.Duration // age at which alerts move from memory to S3; must be under a day
+	Interval time.Duration
+}
+
+// SQSBacklogConfig sets how often the queue's depth is checked and the backlog at which the
+// dispatcher alerts that it is falling behind. A zero limit is never exceeded.
+type SQSBacklogConfig struct {
+	Interval    time.Duration
+	MaxMessages int
+	MaxAge      time.Duration // of the oldest message
 }
 
 type AlarmChannelConfig struct {
-	AlarmMappings   map[string]string `yaml:"alarm_mappings"`
-	DefaultChannels map[string]string `yaml:"default_channels"`
+	AlarmMappings   map[string]string      `yaml:"alarm_mappings"`
+	DefaultChannels map[string]string      `yaml:"default_channels"`
+	Routes          map[string]RouteConfig `yaml:"routes"`
+	// Destinations every alert of a priority, or of an alarm, is delivered to besides its channel
+	PriorityTargets map[string][]TargetConfig `yaml:"priority_targets"`
+	AlarmTargets    map[string][]TargetConfig `yaml:"alarm_targets"`
+	DropRules       []DropRule                `yaml:"drop_rules"`
+	Notifiers       NotifiersConfig           `yaml:"notifiers"`
+	Teams           map[string]TeamConfig     `yaml:"teams"`
+	// Named Go text/templates and emoji sets that routes render their alerts with
+	Templates map[string]string   `yaml:"templates"`
+	EmojiSets map[string]EmojiSet `yaml:"emoji_sets"`
+	// Channels Sentry issue alerts are routed to, by project slug or ID
+	SentryProjects map[string]string `yaml:"sentry_projects"`
+	// Channels GitHub workflow run and deployment alerts are routed to, by owner/name
+	GitHubRepos map[string]string `yaml:"github_repos"`
+	// Channels GitLab pipeline alerts are routed to, by project path
+	GitLabProjects map[string]string `yaml:"gitlab_projects"`
+	// Channels ArgoCD application alerts are routed to, by application name
+	ArgoCDApps map[string]string `yaml:"argocd_apps"`
+	// Channels Flux alerts are routed to, by the object's namespace/name or namespace
+	FluxObjects map[string]string `yaml:"flux_objects"`
+	// Channels Dynatrace problems are routed to, by management zone
+	DynatraceZones map[string]string `yaml:"dynatrace_zones"`
+	// Endpoints served at /webhook/<name>, by name
+	Webhooks map[string]WebhookConfig `yaml:"webhooks"`
+	// Priorities and channels of CloudTrail events, by event name
+	CloudTrailRules []CloudTrailRule `yaml:"cloudtrail_rules"`
+	// Priorities of RDS events, by event category, over the built-in ones
+	RDSEventPriorities map[string]string `yaml:"rds_event_priorities"`
+	// Channel of AWS Backup job alerts, in place of the priority channels
+	BackupChannel string `yaml:"backup_channel"`
+	// Channels of CodePipeline and CodeBuild alerts, by pipeline or CodeBuild project name
+	PipelineChannels map[string]string `yaml:"pipeline_channels"`
+	// Silences created in Grafana and Alertmanager when their alerts are acknowledged or
+	// silenced in Slack
+	SilenceSync *SilenceSyncConfig `yaml:"silence_sync"`
+	// What snoozing a CloudWatch alert from Slack does to its alarm
+	CloudWatchSnooze *CloudWatchSnoozeConfig `yaml:"cloudwatch_snooze"`
+	// How AWS console links on alerts sign in to the alarm's account
+	AWSConsole AWSConsoleConfig `yaml:"aws_console"`
+	// How long each input that normally receives alerts may go without one before the
+	// dispatcher alerts that it has gone silent; see adapter.Inputs
+	Heartbeats map[string]time.Duration `yaml:"heartbeats"`
+	// How many alerts each source may dispatch, so a runaway one can't starve the rest; "*"
+	// gives every source without its own quota one of its own
+	SourceQuotas map[string]SourceQuota `yaml:"source_quotas"`
+}
+
+// WebhookConfig is an endpoint at /webhook/<name> that parses bodies with one of the built-in
+// adapters, so another Grafana instance or Datadog org needs only config
+type WebhookConfig struct {
+	// Adapter parses request bodies: grafana, datadog, sentry, zabbix, nagios, dynatrace,
+	// splunk, kibana, uptimekuma, pingdom or statuscake
+	Adapter string `yaml:"adapter"`
+	// SecretEnv names the environment variable holding the secret requests must carry in the
+	// X-Webhook-Secret header. Without it every request is rejected.
+	SecretEnv string `yaml:"secret_env"`
+	// Channel receives the alerts no alarm mapping routes, in place of the priority channels
+	Channel string `yaml:"channel"`
+	// Priorities routes priorities to channels, overriding Channel and SLACK_CHANNEL_P*
+	Priorities map[string]string `yaml:"priorities"`
+	// Labels are added to every alert, e.g. region: eu, for drop rules, templates and threads
+	Labels map[string]string `yaml:"labels"`
+	// RateLimit caps the requests accepted per window; the rest are rejected with 429
+	RateLimit *RateLimit `yaml:"rate_limit"`
+	// CallbackURL is sent a POST, signed with the secret, when one of the webhook's alerts is
+	// acknowledged, dismissed or resolved
+	CallbackURL string `yaml:"callback_url"`
+
+	Secret string `yaml:"-"` // read from SecretEnv
+}
+
+// EmojiSet is the emoji a route's template shows for statuses and priorities, and a legend
+// explaining them that is posted under each of the route's alerts
+type EmojiSet struct {
+	Firing     string            `yaml:"firing"`
+	Resolved   string            `yaml:"resolved"`
+	NoData     string            `yaml:"nodata"`
+	Priorities map[string]string `yaml:"priorities"` // e.g. P0 -> 🔴
+	Legend     string            `yaml:"legend"`
+}
+
+// TeamConfig groups the channels a team is on call for. A handoff summary is posted to
+// HandoffChannel when the weekly rotation changes over.
+type TeamConfig struct {
+	Channels       []string `yaml:"channels"`
+	HandoffChannel string   `yaml:"handoff_channel"`
+	HandoffDay     string   `yaml:"handoff_day"`  // e.g. monday
+	HandoffTime    string   `yaml:"handoff_time"` // 24-hour HH:MM, defaults to 09:00
+	Timezone       string   `yaml:"timezone"`     // IANA name, defaults to UTC
+	// RouteDefaults are the route settings of the team's channels, which their routes override
+	RouteDefaults RouteConfig `yaml:"route_defaults"`
+}
+
+// NotifiersConfig configures notifiers that deliver alongside Slack
+type NotifiersConfig struct {
+	Twilio   *TwilioConfig   `yaml:"twilio"`
+	Telegram *TelegramConfig `yaml:"telegram"`
+	WhatsApp *WhatsAppConfig `yaml:"whatsapp"`
+}
+
+// TwilioConfig texts, and optionally calls, a phone list for alerts of the given priorities.
+// Credentials come from TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN rather than the config file.
+type TwilioConfig struct {
+	Priorities []string `yaml:"priorities"` // defaults to P0
+	From       string   `yaml:"from"`
+	To         []string `yaml:"to"`
+	Voice      bool     `yaml:"voice"` // also call each number and read the alert out
+
+	AccountSID string        `yaml:"-"`
+	AuthToken  string        `yaml:"-"`
+	Timeout    time.Duration `yaml:"-"`
+}
+
+// WhatsAppConfig messages a group of numbers for alerts of the given priorities through the
+// WhatsApp Business Cloud API, using an approved template. The access token comes from
+// WHATSAPP_ACCESS_TOKEN rather than the config file.
+type WhatsAppConfig struct {
+	Priorities    []string `yaml:"priorities"`      // defaults to P0
+	PhoneNumberID string   `yaml:"phone_number_id"` // the business number messages are sent from
+	Template      string   `yaml:"template"`        // body parameters: priority, name, status, description
+	Language      string   `yaml:"language"`        // template language code, defaults to en
+	To            []string `yaml:"to"`
+
+	AccessToken string        `yaml:"-"`
+	APIURL      string        `yaml:"-"`
+	Timeout     time.Duration `yaml:"-"`
+}
+
+// TelegramConfig posts alerts to a Telegram chat per priority, with acknowledge and dismiss
+// buttons. The bot token comes from TELEGRAM_BOT_TOKEN rather than the config file.
+type TelegramConfig struct {
+	Chats map[string]string `yaml:"chats"` // priority -> chat ID
+
+	BotToken      string        `yaml:"-"`
+	WebhookSecret string        `yaml:"-"` // checked against X-Telegram-Bot-Api-Secret-Token
+	Timeout       time.Duration `yaml:"-"`
+}
+
+// SilenceSyncConfig silences Grafana and Alertmanager alerts upstream, through the silence
+// links in their payloads, when they are acknowledged or silenced in Slack. The API tokens come
+// from GRAFANA_SILENCE_TOKEN and ALERTMANAGER_SILENCE_TOKEN rather than the config file.
+type SilenceSyncConfig struct {
+	// Acknowledge silences an acknowledged alert upstream for this long; unset, acknowledging
+	// doesn't
+	Acknowledge time.Duration `yaml:"acknowledge"`
+	// The Grafana and Alertmanager silences are created in. Links in payloads to anywhere else
+	// are refused, so a forged alert can't have the tokens sent to another host.
+	GrafanaURL      string `yaml:"grafana_url"`
+	AlertmanagerURL string `yaml:"alertmanager_url"`
+
+	GrafanaToken      string        `yaml:"-"`
+	AlertmanagerToken string        `yaml:"-"`
+	Timeout           time.Duration `yaml:"-"`
+}
+
+// CloudWatchSnooze actions
+const (
+	CloudWatchDisableActions = "disable_actions"
+	CloudWatchSetState       = "set_state"
+)
+
+// CloudWatchSnoozeConfig changes the CloudWatch alarm of an alert snoozed from Slack, through
+// the CloudWatch API with the default AWS credential chain, so it stops notifying while someone
+// works on it
+type CloudWatchSnoozeConfig struct {
+	// Action is disable_actions (the default), which disables the alarm's actions until the
+	// snooze ends, or set_state, which sets the alarm to OK until its next evaluation
+	Action string `yaml:"action"`
+
+	Timeout time.Duration `yaml:"-"`
+}
+
+// AWSConsoleConfig makes the AWS console links on alerts, such as the EC2 console and Session
+// Manager buttons, sign in to the alarm's account through IAM Identity Center. Without it they
+// open the console signed in to whichever account the user last used.
+type AWSConsoleConfig struct {
+	// SSOStartURL is the AWS access portal URL, e.g. https://my-org.awsapps.com/start
+	SSOStartURL string `yaml:"sso_start_url"`
+	// SSORole is the permission set to sign in with, and AccountRoles overrides it by account ID
+	SSORole      string            `yaml:"sso_role"`
+	AccountRoles map[string]string `yaml:"account_roles"`
+}
+
+// SSORoleFor returns the permission set to sign in to the account with
+func (c AWSConsoleConfig) SSORoleFor(account string) string {
+	if role := c.AccountRoles[account]; role != "" {
+		return role
+	}
+	return c.SSORole
+}
+
+// DropRule discards matching alerts before they are delivered; every set field must match
+type DropRule struct {
+	Name      string            `yaml:"name"`
+	Source    string            `yaml:"source"`     // cloudwatch, grafana, alertmanager, datadog, newrelic, sentry, azure, gcp, zabbix, nagios, dynatrace, splunk, kibana, uptimekuma, pingdom, statuscake, awscost, cloudtrail, ecs, rds, awsbackup, lambda, codestar, github, gitlab, argocd, flux, dispatcher
+	NameRegex string            `yaml:"name_regex"` // matched against the alarm/alert name
+	State     string            `yaml:"state"`      // e.g. INSUFFICIENT_DATA, case-insensitive
+	Labels    map[string]string `yaml:"labels"`     // exact label values
+
+	nameRe *regexp.Regexp // NameRegex, compiled when the config is loaded
+}
+
+// MatchesName reports whether the alert name matches the rule's name_regex, which every name
+// does when it has none. Rules that weren't loaded from a config file never match by name.
+func (r DropRule) MatchesName(name string) bool {
+	if r.NameRegex == "" {
+		return true
+	}
+	return r.nameRe != nil && r.nameRe.MatchString(name)
+}
+
+// compileDropRules compiles the name_regex of each rule once, so alerts aren't matched against
+// patterns compiled on the spot
+func compileDropRules(rules []DropRule) error {
+	for i, rule := range rules {
+		if rule.NameRegex == "" {
+			continue
+		}
+		re, err := regexp.Compile(rule.NameRegex)
+		if err != nil {
+			name := rule.Name
+			if name == "" {
+				name = fmt.Sprintf("rule_%d", i)
+			}
+			return &errs.ConfigError{Setting: "drop rule " + name, Err: fmt.Errorf("invalid name_regex: %w", err)}
+		}
+		rules[i].nameRe = re
+	}
+	return nil
+}
+
+// Supported message layouts for a route
+const (
+	LayoutBlocks      = "blocks"
+	LayoutAttachments = "attachments"
+)
+
+// Supported message formats for a route
+const (
+	FormatFull    = "full"
+	FormatCompact = "compact"
+	FormatRaw     = "raw"
+)
+
+// NoData policies for INSUFFICIENT_DATA / NoData / DatasourceError alerts
+const (
+	NoDataDeliver   = "deliver"
+	NoDataDrop      = "drop"
+	NoDataDowngrade = "downgrade"
+	NoDataReroute   = "reroute"
+)
+
+// How repeated firings of an alert that hasn't resolved are posted
+const (
+	RepeatsThread = "thread" // a one-line update in the first firing's thread
+	RepeatsFull   = "full"   // a new message like the first
+)
+
+// SourceQuota is a token bucket a source's alerts are dispatched from: Max may arrive at once,
+// and Max more are allowed per Per, an hour when unset. With Key, a field expression such as
+// "labels.grafana_folder", each of its values has a bucket of its own.
+type SourceQuota struct {
+	Max int           `yaml:"max"`
+	Per time.Duration `yaml:"per"`
+	Key string        `yaml:"key"`
+}
+
+// RateLimit allows Max alerts per window of Per, an hour when unset
+type RateLimit struct {
+	Max int           `yaml:"max"`
+	Per time.Duration `yaml:"per"`
+}
+
+// SamplingConfig samples a rule whose alerts reach the channel more than Threshold times in a
+// minute: one in Every is posted, and each Window a summary of all of them, counting the
+// distinct values of the CountBy label, or distinct alerts without it
+type SamplingConfig struct {
+	Threshold int           `yaml:"threshold"`
+	Every     int           `yaml:"every"`    // 10 when unset
+	Window    time.Duration `yaml:"window"`   // 10m when unset
+	CountBy   string        `yaml:"count_by"` // e.g. pod
+}
+
+// RouteConfig holds per-channel rendering options, keyed by channel in alarm-channels.yaml
+type RouteConfig struct {
+	// Layout is either "blocks" (default) or "attachments" for a severity color bar
+	Layout string `yaml:"layout"`
+	// Format is "full" (default), "compact" for one line with a Details button,
+	// or "raw" for the normalized alert JSON consumed by bots
+	Format string `yaml:"format"`
+	// ThreadKey is a field expression (e.g. "labels.service") whose value groups alerts
+	// into one parent thread per day
+	ThreadKey string `yaml:"thread_key"`
+	// DailyRollup posts P2 alerts as replies under one dated parent message per day
+	DailyRollup bool `yaml:"daily_rollup"`
+	// NoDataPolicy is "deliver" (default), "drop", "downgrade" (one priority lower)
+	// or "reroute" to NoDataChannel
+	NoDataPolicy  string `yaml:"nodata_policy"`
+	NoDataChannel string `yaml:"nodata_channel"`
+	// Renderer is "slack" (default) for Slack mrkdwn, or "markdown", "text", "html" or "json"
+	// for targets that don't understand Slack formatting
+	Renderer string `yaml:"renderer"`
+	// Repeats is "thread" (default) to post repeated firings as updates in the first firing's
+	// thread, or "full" to post them like the first
+	Repeats string `yaml:"repeats"`
+	// Template names a template under templates that full-format Slack messages are rendered
+	// with instead of the source's layout
+	Template string `yaml:"template"`
+	// EmojiSet names a set under emoji_sets used by the template and for the legend
+	EmojiSet string `yaml:"emoji_set"`
+	// Fields chooses which labels and annotations messages show; see ShowField
+	Fields FieldRules `yaml:"fields"`
+	// RateLimits caps the alerts of a priority posted to the channel per window; the rest are
+	// summarized in one message when the window ends
+	RateLimits map[string]RateLimit `yaml:"rate_limits"`
+	// Sampling posts a sample of a rule's alerts while it fires faster than a threshold, and
+	// a summary of all of them each window
+	Sampling *SamplingConfig `yaml:"sampling"`
+	// EmailTo also emails alerts routed to this channel to these addresses, in place of the
+	// default email recipients
+	EmailTo []string `yaml:"email_to"`
+	// GoogleChatWebhook is shorthand for a googlechat target
+	GoogleChatWebhook string `yaml:"google_chat_webhook"`
+	// MattermostChannel is shorthand for a mattermost target
+	MattermostChannel string `yaml:"mattermost_channel"`
+	// Targets are further destinations alerts routed to this channel are fanned out to
+	Targets []TargetConfig `yaml:"targets"`
+	// Actions are quick action buttons, such as scaling out, on alerts about an Auto Scaling
+	// group or Kubernetes deployment
+	Actions []QuickAction `yaml:"actions"`
+	// Logs adds a Fetch logs button running this query and posting the results in-thread
+	Logs *LogQuery `yaml:"logs"`
+}
+
+// TargetConfig is a destination of a route besides its Slack channel. Which fields apply
+// depends on Type.
+type TargetConfig struct {
+	Type    string   `yaml:"type"`    // slack, webhook, email, googlechat, mattermost, telegram, opsgenie, pagerduty, twilio, whatsapp, pushover, ntfy or sns
+	Channel string   `yaml:"channel"` // Slack channel, Mattermost channel ID, Telegram chat ID, Pushover user key, ntfy topic or SNS topic ARN
+	URL     string   `yaml:"url"`     // webhook or Google Chat webhook URL, or an ntfy server overriding NTFY_URL
+	To      []string `yaml:"to"`      // email addresses or phone numbers, overriding the defaults
+	// Deadline for each delivery to this target, e.g. 3s, overriding its type's default such as
+	// WEBHOOK_TIMEOUT_SEC
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// TimeoutOr is the target's timeout, or fallback when it has none
+func (t TargetConfig) TimeoutOr(fallback time.Duration) time.Duration {
+	if t.Timeout > 0 {
+		return t.Timeout
+	}
+	return fallback
 }
 
 func LoadConfig() *Config {
@@ -52,56 +618,418 @@ func LoadConfig() *Config {
 			pollInterval = val
 		}
 	}
+	sqsMaxReceivers := 4
+	if val, err := strconv.Atoi(os.Getenv("SQS_MAX_RECEIVERS")); err == nil && val > 0 {
+		sqsMaxReceivers = val
+	}
 
-	// Configure channels for different priorities
-	channels := map[string]string{
-		"P0":      getEnvOrDefault("SLACK_CHANNEL_P0", "#p0-channel"),
-		"P1":      getEnvOrDefault("SLACK_CHANNEL_P1", "#p1-channel"),
-		"P2":      getEnvOrDefault("SLACK_CHANNEL_P2", "#p2-channel"),
-		"default": getEnvOrDefault("SLACK_CHANNEL_DEFAULT", "#alerts"),
+	slackTimeout := getEnvSecondsOrDefault("SLACK_TIMEOUT_SEC", 10)
+	webhookTimeout := getEnvSecondsOrDefault("WEBHOOK_TIMEOUT_SEC", 5)
+
+	exportAlertMetrics, _ := strconv.ParseBool(os.Getenv("EXPORT_ALERT_METRICS"))
+
+	channels := PriorityChannels()
+	if cost := os.Getenv("SLACK_CHANNEL_COST"); cost != "" {
+		channels["cost"] = cost
 	}
 
-	// Load alarm-to-channel mappings
-	alarmChannels := loadAlarmChannelMappings()
+	// Load alarm-to-channel mappings and per-channel routes
+	alarmConfig, loadFindings := loadAlarmChannelConfig()
+	if twilio := alarmConfig.Notifiers.Twilio; twilio != nil {
+		twilio.AccountSID = os.Getenv("TWILIO_ACCOUNT_SID")
+		twilio.AuthToken = os.Getenv("TWILIO_AUTH_TOKEN")
+		twilio.Timeout = getEnvSecondsOrDefault("TWILIO_TIMEOUT_SEC", 10)
+		if len(twilio.Priorities) == 0 {
+			twilio.Priorities = []string{"P0"}
+		}
+	}
+	if whatsApp := alarmConfig.Notifiers.WhatsApp; whatsApp != nil {
+		whatsApp.AccessToken = os.Getenv("WHATSAPP_ACCESS_TOKEN")
+		whatsApp.APIURL = os.Getenv("WHATSAPP_API_URL")
+		whatsApp.Timeout = getEnvSecondsOrDefault("WHATSAPP_TIMEOUT_SEC", 10)
+		if len(whatsApp.Priorities) == 0 {
+			whatsApp.Priorities = []string{"P0"}
+		}
+		if whatsApp.Language == "" {
+			whatsApp.Language = "en"
+		}
+	}
+	for name, webhook := range alarmConfig.Webhooks {
+		if webhook.SecretEnv != "" {
+			webhook.Secret = os.Getenv(webhook.SecretEnv)
+			alarmConfig.Webhooks[name] = webhook
+		}
+	}
+	if sync := alarmConfig.SilenceSync; sync != nil {
+		sync.GrafanaToken = os.Getenv("GRAFANA_SILENCE_TOKEN")
+		sync.AlertmanagerToken = os.Getenv("ALERTMANAGER_SILENCE_TOKEN")
+		sync.Timeout = getEnvSecondsOrDefault("SILENCE_SYNC_TIMEOUT_SEC", 10)
+	}
+	if snooze := alarmConfig.CloudWatchSnooze; snooze != nil {
+		if snooze.Action == "" {
+			snooze.Action = CloudWatchDisableActions
+		}
+		snooze.Timeout = getEnvSecondsOrDefault("CLOUDWATCH_TIMEOUT_SEC", 10)
+	}
+	if telegram := alarmConfig.Notifiers.Telegram; telegram != nil {
+		telegram.BotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+		telegram.WebhookSecret = os.Getenv("TELEGRAM_WEBHOOK_SECRET")
+		telegram.Timeout = getEnvSecondsOrDefault("TELEGRAM_TIMEOUT_SEC", 10)
+	}
 
 	return &Config{
 		SQSQueueURL:        sqsURL,
+		SQSDeadLetterURL:   os.Getenv("SQS_DLQ_URL"),
 		SlackWebhookURL:    slackURL,
 		SlackBotToken:      slackBotToken,
 		SlackSigningSecret: slackSigningSecret,
+		SlackAPIURL:        os.Getenv("SLACK_API_URL"),
+
+		SlackStandbyBotToken:      os.Getenv("SLACK_STANDBY_BOT_TOKEN"),
+		SlackStandbySigningSecret: os.Getenv("SLACK_STANDBY_SIGNING_SECRET"),
+		SlackStandbyAPIURL:        os.Getenv("SLACK_STANDBY_API_URL"),
+		SlackFailoverAfter:        getEnvSecondsOrDefault("SLACK_FAILOVER_AFTER_SEC", 300),
+
 		ServerPort:         serverPort,
 		PollIntervalSec:    pollInterval,
+		SQSMaxReceivers:    sqsMaxReceivers,
+		SQSMaxIdleWait:     getEnvSecondsOrDefault("SQS_MAX_IDLE_WAIT_SEC", 30),
+		SlackTimeout:       slackTimeout,
+		WebhookTimeout:     webhookTimeout,
+		MessageTimeout:     getEnvSecondsOrDefault("MESSAGE_TIMEOUT_SEC", 25),
+		QuickActionTimeout: getEnvSecondsOrDefault("QUICK_ACTION_TIMEOUT_SEC", 15),
+		LogQueryTimeout:    getEnvSecondsOrDefault("LOG_QUERY_TIMEOUT_SEC", 30),
+		LokiToken:          os.Getenv("LOKI_TOKEN"),
+		GrafanaURL:         os.Getenv("GRAFANA_URL"),
+		GrafanaRenderToken: os.Getenv("GRAFANA_RENDER_TOKEN"),
+		GraphRenderTimeout: getEnvSecondsOrDefault("GRAPH_RENDER_TIMEOUT_SEC", 30),
+		ExportAlertMetrics: exportAlertMetrics,
+		PriorityEditors:    getEnvListOrDefault("PRIORITY_EDITORS", ""),
+		PublicURL:          strings.TrimRight(os.Getenv("PUBLIC_URL"), "/"),
+		SNSTopicARN:        os.Getenv("SNS_TOPIC_ARN"),
+		GrafanaSecret:      os.Getenv("GRAFANA_WEBHOOK_SECRET"),
+		DatadogSecret:      os.Getenv("DATADOG_WEBHOOK_SECRET"),
+		SentrySecret:       os.Getenv("SENTRY_CLIENT_SECRET"),
+		GitHubSecret:       os.Getenv("GITHUB_WEBHOOK_SECRET"),
+		GitLabToken:        os.Getenv("GITLAB_WEBHOOK_TOKEN"),
+		ArgoCDSecret:       os.Getenv("ARGOCD_WEBHOOK_SECRET"),
+		FluxSecret:         os.Getenv("FLUX_WEBHOOK_SECRET"),
+		OpsChannel:         os.Getenv("OPS_CHANNEL"),
+		ZabbixSecret:       os.Getenv("ZABBIX_WEBHOOK_SECRET"),
+		NagiosSecret:       os.Getenv("NAGIOS_WEBHOOK_SECRET"),
+		DynatraceSecret:    os.Getenv("DYNATRACE_WEBHOOK_SECRET"),
+		SplunkToken:        os.Getenv("SPLUNK_WEBHOOK_TOKEN"),
+		KibanaSecret:       os.Getenv("KIBANA_WEBHOOK_SECRET"),
+		UptimeKumaSecret:   os.Getenv("UPTIME_KUMA_WEBHOOK_SECRET"),
+		PingdomToken:       os.Getenv("PINGDOM_WEBHOOK_TOKEN"),
+		StatusCakeToken:    os.Getenv("STATUSCAKE_WEBHOOK_TOKEN"),
+		AlertmanagerToken:  os.Getenv("ALERTMANAGER_API_TOKEN"),
+		ReceiptsToken:      os.Getenv("RECEIPTS_API_TOKEN"),
+		PermalinkToken:     os.Getenv("PERMALINK_TOKEN"),
+		Kafka:              loadKafkaConfig(),
+		OpenSearch:         loadOpenSearchConfig(),
+		ClickHouse:         loadClickHouseConfig(),
+		Opsgenie:           loadOpsgenieConfig(),
+		PagerDuty:          loadPagerDutyConfig(),
+		Pushover:           loadPushoverConfig(),
+		Ntfy:               loadNtfyConfig(),
+		Mattermost:         loadMattermostConfig(),
+		S3Archive:          loadS3ArchiveConfig(),
+		SQSBacklog:         loadSQSBacklogConfig(),
+		Email:              loadEmailConfig(),
+		State:              loadStateConfig(),
+		Kubernetes:         loadKubernetesConfig(),
 		SlackChannels:      channels,
-		AlarmChannels:      alarmChannels,
+		AlarmChannels:      alarmConfig.AlarmMappings,
+		PriorityTargets:    alarmConfig.PriorityTargets,
+		AlarmTargets:       alarmConfig.AlarmTargets,
+		Routes:             alarmConfig.Routes,
+		DropRules:          alarmConfig.DropRules,
+		Notifiers:          alarmConfig.Notifiers,
+		Teams:              alarmConfig.Teams,
+		Templates:          alarmConfig.Templates,
+		EmojiSets:          alarmConfig.EmojiSets,
+		SentryProjects:     alarmConfig.SentryProjects,
+		GitHubRepos:        alarmConfig.GitHubRepos,
+		GitLabProjects:     alarmConfig.GitLabProjects,
+		ArgoCDApps:         alarmConfig.ArgoCDApps,
+		FluxObjects:        alarmConfig.FluxObjects,
+		DynatraceZones:     alarmConfig.DynatraceZones,
+		Webhooks:           alarmConfig.Webhooks,
+		CloudTrailRules:    alarmConfig.CloudTrailRules,
+		RDSEventPriorities: alarmConfig.RDSEventPriorities,
+		BackupChannel:      alarmConfig.BackupChannel,
+		PipelineChannels:   alarmConfig.PipelineChannels,
+		SilenceSync:        alarmConfig.SilenceSync,
+		CloudWatchSnooze:   alarmConfig.CloudWatchSnooze,
+		AWSConsole:         alarmConfig.AWSConsole,
+		Heartbeats:         alarmConfig.Heartbeats,
+		SourceQuotas:       alarmConfig.SourceQuotas,
+		loadFindings:       loadFindings,
+	}
+}
+
+// PriorityChannels returns the Slack channel for each priority, and the default channel
+func PriorityChannels() map[string]string {
+	return map[string]string{
+		"P0":      getEnvOrDefault("SLACK_CHANNEL_P0", "#p0-channel"),
+		"P1":      getEnvOrDefault("SLACK_CHANNEL_P1", "#p1-channel"),
+		"P2":      getEnvOrDefault("SLACK_CHANNEL_P2", "#p2-channel"),
+		"default": getEnvOrDefault("SLACK_CHANNEL_DEFAULT", "#alerts"),
+	}
+}
+
+// RouteFor returns the route options for a channel, filling in defaults for unset options
+func (c *Config) RouteFor(channel string) RouteConfig {
+	route := c.route(channel)
+	if route.Layout == "" {
+		route.Layout = LayoutBlocks
+	}
+	if route.Format == "" {
+		route.Format = FormatFull
+	}
+	if route.NoDataPolicy == "" {
+		route.NoDataPolicy = NoDataDeliver
+	}
+	if route.Renderer == "" {
+		route.Renderer = render.NameSlack
+	}
+	if route.Repeats == "" {
+		route.Repeats = RepeatsThread
+	}
+
+	// Copy so shorthand targets aren't appended to the shared config
+	targets := append([]TargetConfig(nil), route.Targets...)
+	if route.GoogleChatWebhook != "" {
+		targets = append(targets, TargetConfig{Type: "googlechat", URL: route.GoogleChatWebhook})
+	}
+	if route.MattermostChannel != "" {
+		targets = append(targets, TargetConfig{Type: "mattermost", Channel: route.MattermostChannel})
+	}
+	route.Targets = targets
+	return route
+}
+
+// TargetsFor returns every destination besides its Slack channel for an alert routed to route:
+// the route's targets, then its priority's and then its alarm's. Repeated targets and Slack
+// targets for the alert's own channel are left out.
+func (c *Config) TargetsFor(alarmName, priority, channel string, route RouteConfig) []TargetConfig {
+	var targets []TargetConfig
+	seen := make(map[string]bool)
+	for _, list := range [][]TargetConfig{route.Targets, c.PriorityTargets[priority], c.AlarmTargets[alarmName]} {
+		for _, target := range list {
+			if target.Type == "slack" && target.Channel == channel {
+				continue
+			}
+			key := target.Type + "|" + target.Channel + "|" + target.URL + "|" + strings.Join(target.To, ",")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+func loadOpenSearchConfig() OpenSearchConfig {
+	return OpenSearchConfig{
+		URL:         os.Getenv("OPENSEARCH_URL"),
+		IndexPrefix: getEnvOrDefault("OPENSEARCH_INDEX_PREFIX", "alert-dispatcher"),
+		Username:    os.Getenv("OPENSEARCH_USERNAME"),
+		Password:    os.Getenv("OPENSEARCH_PASSWORD"),
+		Timeout:     getEnvSecondsOrDefault("OPENSEARCH_TIMEOUT_SEC", 10),
+	}
+}
+
+func loadClickHouseConfig() ClickHouseConfig {
+	return ClickHouseConfig{
+		URL:      os.Getenv("CLICKHOUSE_URL"),
+		Table:    getEnvOrDefault("CLICKHOUSE_TABLE", "alert_events"),
+		Username: os.Getenv("CLICKHOUSE_USERNAME"),
+		Password: os.Getenv("CLICKHOUSE_PASSWORD"),
+		Timeout:  getEnvSecondsOrDefault("CLICKHOUSE_TIMEOUT_SEC", 10),
+	}
+}
+
+func loadOpsgenieConfig() OpsgenieConfig {
+	return OpsgenieConfig{
+		APIKey:     os.Getenv("OPSGENIE_API_KEY"),
+		APIURL:     getEnvOrDefault("OPSGENIE_API_URL", "https://api.opsgenie.com"),
+		Priorities: getEnvListOrDefault("OPSGENIE_PRIORITIES", "P0"),
+		Timeout:    getEnvSecondsOrDefault("OPSGENIE_TIMEOUT_SEC", 10),
+	}
+}
+
+func loadPagerDutyConfig() PagerDutyConfig {
+	return PagerDutyConfig{
+		RoutingKey: os.Getenv("PAGERDUTY_ROUTING_KEY"),
+		EventsURL:  getEnvOrDefault("PAGERDUTY_EVENTS_URL", "https://events.pagerduty.com/v2/enqueue"),
+		Timeout:    getEnvSecondsOrDefault("PAGERDUTY_TIMEOUT_SEC", 10),
+	}
+}
+
+func loadPushoverConfig() PushoverConfig {
+	return PushoverConfig{
+		AppToken: os.Getenv("PUSHOVER_APP_TOKEN"),
+		UserKey:  os.Getenv("PUSHOVER_USER_KEY"),
+		Timeout:  getEnvSecondsOrDefault("PUSHOVER_TIMEOUT_SEC", 10),
+	}
+}
+
+func loadNtfyConfig() NtfyConfig {
+	return NtfyConfig{
+		URL:     getEnvOrDefault("NTFY_URL", "https://ntfy.sh"),
+		Token:   os.Getenv("NTFY_TOKEN"),
+		Timeout: getEnvSecondsOrDefault("NTFY_TIMEOUT_SEC", 10),
+	}
+}
+
+func loadKafkaConfig() KafkaConfig {
+	useTLS, _ := strconv.ParseBool(os.Getenv("KAFKA_TLS"))
+	return KafkaConfig{
+		Brokers:       getEnvListOrDefault("KAFKA_BROKERS", ""),
+		Topic:         getEnvOrDefault("KAFKA_TOPIC", "alert-dispatcher.alerts"),
+		TLS:           useTLS,
+		SASLMechanism: os.Getenv("KAFKA_SASL_MECHANISM"),
+		Username:      os.Getenv("KAFKA_USERNAME"),
+		Password:      os.Getenv("KAFKA_PASSWORD"),
+		Timeout:       getEnvSecondsOrDefault("KAFKA_TIMEOUT_SEC", 10),
+	}
+}
+
+func loadMattermostConfig() MattermostConfig {
+	return MattermostConfig{
+		URL:          os.Getenv("MATTERMOST_URL"),
+		BotToken:     os.Getenv("MATTERMOST_BOT_TOKEN"),
+		ActionURL:    os.Getenv("MATTERMOST_ACTION_URL"),
+		ActionSecret: os.Getenv("MATTERMOST_ACTION_SECRET"),
+		Timeout:      getEnvSecondsOrDefault("MATTERMOST_TIMEOUT_SEC", 10),
 	}
 }
 
-func loadAlarmChannelMappings() map[string]string {
+func loadS3ArchiveConfig() S3ArchiveConfig {
+	return S3ArchiveConfig{
+		Bucket:   os.Getenv("ARCHIVE_S3_BUCKET"),
+		Prefix:   getEnvOrDefault("ARCHIVE_S3_PREFIX", "alert-history"),
+		After:    getEnvSecondsOrDefault("ARCHIVE_AFTER_SEC", 6*60*60),
+		Interval: getEnvSecondsOrDefault("ARCHIVE_INTERVAL_SEC", 60*60),
+	}
+}
+
+func loadSQSBacklogConfig() SQSBacklogConfig {
+	maxMessages, _ := strconv.Atoi(os.Getenv("SQS_BACKLOG_MAX_MESSAGES"))
+	var maxAge time.Duration
+	if os.Getenv("SQS_BACKLOG_MAX_AGE_SEC") != "" {
+		maxAge = getEnvSecondsOrDefault("SQS_BACKLOG_MAX_AGE_SEC", 0)
+	}
+	return SQSBacklogConfig{
+		Interval:    getEnvSecondsOrDefault("SQS_DEPTH_INTERVAL_SEC", 60),
+		MaxMessages: maxMessages,
+		MaxAge:      maxAge,
+	}
+}
+
+func loadEmailConfig() EmailConfig {
+	return EmailConfig{
+		Backend:      os.Getenv("EMAIL_BACKEND"),
+		From:         os.Getenv("EMAIL_FROM"),
+		To:           getEnvListOrDefault("EMAIL_TO", ""),
+		Priorities:   getEnvListOrDefault("EMAIL_PRIORITIES", "P0,P1"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvOrDefault("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		Timeout:      getEnvSecondsOrDefault("EMAIL_TIMEOUT_SEC", 15),
+	}
+}
+
+func loadStateConfig() StateConfig {
+	redisDB, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
+	return StateConfig{
+		Backend:        getEnvOrDefault("STATE_BACKEND", StateMemory),
+		RedisAddr:      getEnvOrDefault("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:  os.Getenv("REDIS_PASSWORD"),
+		RedisDB:        redisDB,
+		RedisKeyPrefix: getEnvOrDefault("REDIS_KEY_PREFIX", "alert-dispatcher:"),
+		DynamoDBTable:  getEnvOrDefault("DYNAMODB_TABLE", "alert-dispatcher-state"),
+		PostgresDSN:    os.Getenv("POSTGRES_DSN"),
+	}
+}
+
+func loadKubernetesConfig() KubernetesConfig {
+	crds, _ := strconv.ParseBool(os.Getenv("KUBERNETES_CRDS"))
+	return KubernetesConfig{
+		CRDs:      crds,
+		Namespace: os.Getenv("KUBERNETES_CRD_NAMESPACE"),
+	}
+}
+
+// loadAlarmChannelConfig reads alarm-channels.yaml and its fragments. A missing or invalid
+// config is replaced by an empty one, so alerts are still routed by priority, and reported in
+// the returned findings.
+func loadAlarmChannelConfig() (AlarmChannelConfig, []LintFinding) {
 	configPath := getEnvOrDefault("CONFIG_PATH", "/etc/config")
 	alarmConfigFile := filepath.Join(configPath, "alarm-channels.yaml")
+	fragmentsDir := getEnvOrDefault("CONFIG_FRAGMENTS_DIR", filepath.Join(configPath, fragmentsDirName))
 
-	// Check if file exists
-	if _, err := os.Stat(alarmConfigFile); os.IsNotExist(err) {
+	config, conflicts, err := LoadAlarmChannelConfigFragments(alarmConfigFile, fragmentsDir)
+	if errors.Is(err, os.ErrNotExist) {
 		log.Printf("Alarm channel config file not found at %s, using defaults", alarmConfigFile)
-		return make(map[string]string)
+		return emptyAlarmChannelConfig(), []LintFinding{{
+			Severity: LintWarning,
+			Message:  fmt.Sprintf("no alarm channel config at %s; alerts are routed by priority only", alarmConfigFile),
+		}}
+	}
+	if err != nil {
+		log.Print(err)
+		return emptyAlarmChannelConfig(), []LintFinding{{
+			Severity: LintError,
+			Message:  fmt.Sprintf("%v; using no alarm mappings, routes or drop rules, so alerts are routed by priority only", err),
+		}}
 	}
+	logConflicts(conflicts)
 
-	// Read the YAML file
-	data, err := os.ReadFile(alarmConfigFile)
+	var findings []LintFinding
+	for _, conflict := range conflicts {
+		findings = append(findings, LintFinding{Severity: LintWarning, Message: "config conflict: " + conflict.String()})
+	}
+	log.Printf("Loaded %d alarm-to-channel mappings and %d routes", len(config.AlarmMappings), len(config.Routes))
+	return config, findings
+}
+
+// LoadAlarmChannelConfigFile reads alarm mappings, routes and notifiers from a YAML file
+func LoadAlarmChannelConfigFile(path string) (AlarmChannelConfig, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("Failed to read alarm channel config: %v", err)
-		return make(map[string]string)
+		return AlarmChannelConfig{}, &errs.ConfigError{Setting: "alarm channel", Err: err}
 	}
+	return parseAlarmChannelConfig(data)
+}
 
-	// Parse YAML
+func parseAlarmChannelConfig(data []byte) (AlarmChannelConfig, error) {
 	var config AlarmChannelConfig
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		log.Printf("Failed to parse alarm channel config: %v", err)
-		return make(map[string]string)
+		return AlarmChannelConfig{}, &errs.ConfigError{Setting: "alarm channel", Err: err}
+	}
+	if config.AlarmMappings == nil {
+		config.AlarmMappings = make(map[string]string)
+	}
+	if err := compileDropRules(config.DropRules); err != nil {
+		return AlarmChannelConfig{}, err
 	}
+	var err error
+	if config.Routes, err = resolveRoutes(data, config.Teams); err != nil {
+		return AlarmChannelConfig{}, &errs.ConfigError{Setting: "alarm channel", Err: err}
+	}
+	return config, nil
+}
 
-	log.Printf("Loaded %d alarm-to-channel mappings", len(config.AlarmMappings))
-	return config.AlarmMappings
+func emptyAlarmChannelConfig() AlarmChannelConfig {
+	return AlarmChannelConfig{
+		AlarmMappings: make(map[string]string),
+		Routes:        make(map[string]RouteConfig),
+	}
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -110,3 +1038,25 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvListOrDefault reads a comma-separated list from the environment
+func getEnvListOrDefault(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnvOrDefault(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvSecondsOrDefault reads a whole number of seconds from the environment
+func getEnvSecondsOrDefault(key string, defaultSec int) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if sec, err := strconv.Atoi(value); err == nil && sec > 0 {
+			return time.Duration(sec) * time.Second
+		}
+		log.Printf("Invalid %s=%q, using %ds", key, value, defaultSec)
+	}
+	return time.Duration(defaultSec) * time.Second
+}
@@ -0,0 +1,35 @@
+package config
+
+import "path"
+
+// FieldRules are allow and deny lists of label and annotation names, with shell wildcards
+// such as "kubernetes_*". Without either, DefaultHiddenFields are hidden.
+type FieldRules struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// DefaultHiddenFields are Prometheus labels hidden from routes that set no field rules
+var DefaultHiddenFields = []string{"__name__", "job", "instance"}
+
+// ShowField reports whether messages for channel show the label or annotation key: it must
+// match the route's allow list, when it has one, and must not match its deny list
+func (c *Config) ShowField(channel, key string) bool {
+	rules := c.route(channel).Fields
+	if len(rules.Allow) == 0 && len(rules.Deny) == 0 {
+		return !matchesAny(DefaultHiddenFields, key)
+	}
+	if len(rules.Allow) > 0 && !matchesAny(rules.Allow, key) {
+		return false
+	}
+	return !matchesAny(rules.Deny, key)
+}
+
+func matchesAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"alert-dispatcher/internal/errs"
+)
+
+// fragmentsDirName is the directory next to alarm-channels.yaml whose files are merged into it
+const fragmentsDirName = "routes.d"
+
+// ConfigConflict is a setting two files give different values. The file merged later wins.
+type ConfigConflict struct {
+	Key    string // dotted path, e.g. routes.#payments.format
+	Winner string
+	Loser  string
+}
+
+func (c ConfigConflict) String() string {
+	return fmt.Sprintf("%s is set by %s and %s; using %s", c.Key, c.Loser, c.Winner, c.Winner)
+}
+
+// LoadAlarmChannelConfigFragments reads alarm-channels.yaml at path, if it exists, deep-merged
+// with the fragments in dir, and reports the settings the files disagree on
+func LoadAlarmChannelConfigFragments(path, dir string) (AlarmChannelConfig, []ConfigConflict, error) {
+	var files []string
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	fragments, err := fragmentFiles(dir)
+	if err != nil {
+		return AlarmChannelConfig{}, nil, &errs.ConfigError{Setting: "alarm channel", Err: err}
+	}
+	files = append(files, fragments...)
+	if len(files) == 0 {
+		return AlarmChannelConfig{}, nil, &errs.ConfigError{Setting: "alarm channel", Err: os.ErrNotExist}
+	}
+
+	data, conflicts, err := mergeConfigFiles(files)
+	if err != nil {
+		return AlarmChannelConfig{}, nil, &errs.ConfigError{Setting: "alarm channel", Err: err}
+	}
+	config, err := parseAlarmChannelConfig(data)
+	if err != nil {
+		return AlarmChannelConfig{}, nil, err
+	}
+	return config, conflicts, nil
+}
+
+// fragmentFiles lists the YAML files in dir and in its subdirectories one level down, so
+// several ConfigMaps can be mounted side by side, in order of their path relative to dir.
+// Hidden entries, like the ..data links of ConfigMap volumes, are skipped. A missing dir has
+// no fragments.
+func fragmentFiles(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path) // follows ConfigMap symlinks
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if isYAMLFile(entry.Name()) {
+				files = append(files, path)
+			}
+			continue
+		}
+		nested, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nested {
+			if !strings.HasPrefix(n.Name(), ".") && isYAMLFile(n.Name()) {
+				files = append(files, filepath.Join(path, n.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func isYAMLFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// mergeConfigFiles deep-merges the files in order: mappings merge key by key, lists are
+// appended, so drop rules of earlier files are tried first, and a scalar set differently by two
+// files takes the later file's value and is reported as a conflict
+func mergeConfigFiles(files []string) ([]byte, []ConfigConflict, error) {
+	merged := make(map[interface{}]interface{})
+	owners := make(map[string]string) // file that set each scalar, by key
+	var conflicts []ConfigConflict
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		var tree map[interface{}]interface{}
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", file, err)
+		}
+		mergeConfigTree(merged, tree, "", file, owners, &conflicts)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, conflicts, nil
+}
+
+func mergeConfigTree(dst, src map[interface{}]interface{}, prefix, file string, owners map[string]string, conflicts *[]ConfigConflict) {
+	for k, value := range src {
+		key := fmt.Sprint(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		existing, ok := dst[k]
+		if !ok {
+			dst[k] = value
+			setOwners(owners, key, value, file)
+			continue
+		}
+
+		srcMap, srcIsMap := value.(map[interface{}]interface{})
+		dstMap, dstIsMap := existing.(map[interface{}]interface{})
+		srcList, srcIsList := value.([]interface{})
+		dstList, dstIsList := existing.([]interface{})
+		switch {
+		case srcIsMap && dstIsMap:
+			mergeConfigTree(dstMap, srcMap, key, file, owners, conflicts)
+		case srcIsList && dstIsList:
+			dst[k] = append(dstList, srcList...)
+		default:
+			if !reflect.DeepEqual(existing, value) {
+				*conflicts = append(*conflicts, ConfigConflict{Key: key, Winner: file, Loser: owners[key]})
+			}
+			dst[k] = value
+			setOwners(owners, key, value, file)
+		}
+	}
+}
+
+// setOwners records file as the source of value and, when it is a mapping, of everything in it
+func setOwners(owners map[string]string, key string, value interface{}, file string) {
+	owners[key] = file
+	if m, ok := value.(map[interface{}]interface{}); ok {
+		for k, v := range m {
+			setOwners(owners, key+"."+fmt.Sprint(k), v, file)
+		}
+	}
+}
+
+// logConflicts reports the settings the config files disagree on
+func logConflicts(conflicts []ConfigConflict) {
+	for _, conflict := range conflicts {
+		log.Printf("Config conflict: %s", conflict)
+	}
+}
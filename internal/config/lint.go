@@ -0,0 +1,491 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/adapter"
+	"alert-dispatcher/internal/render"
+)
+
+// Lint severities: errors are settings the dispatcher ignores, warnings settings that are valid
+// but have no effect
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintFinding is one problem Lint found
+type LintFinding struct {
+	Severity string
+	Message  string
+}
+
+func (f LintFinding) String() string {
+	return f.Severity + ": " + f.Message
+}
+
+// slackChannelPattern matches "#name" channels and channel, group and DM IDs
+var slackChannelPattern = regexp.MustCompile(`^(#[a-z0-9][a-z0-9._-]*|[CGD][A-Z0-9]{8,})$`)
+
+// Lint checks the whole configuration, including routes from custom resources, for settings
+// that fall back to defaults or never take effect. Findings are sorted, errors first.
+func (c *Config) Lint() []LintFinding {
+	findings := append([]LintFinding(nil), c.loadFindings...)
+	add := func(severity, format string, args ...interface{}) {
+		findings = append(findings, LintFinding{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.GrafanaSecret == "" {
+		add(LintWarning, "GRAFANA_WEBHOOK_SECRET is not set, so /grafana/webhook accepts alerts from anyone")
+	}
+	for _, editor := range c.PriorityEditors {
+		if !slackUserID.MatchString(editor) {
+			add(LintWarning, "PRIORITY_EDITORS has %q, which isn't a Slack user ID, so they may not change priorities", editor)
+		}
+	}
+
+	// Channels alerts are routed to, so routes for any other channel never apply
+	receiving := make(map[string]bool)
+	for priority, channel := range c.SlackChannels {
+		receiving[channel] = true
+		c.lintChannel(add, channel, "SLACK_CHANNEL_"+strings.ToUpper(priority))
+	}
+	for alarm, channel := range c.AlarmMappings() {
+		receiving[channel] = true
+		if channel == "" {
+			add(LintError, "alarm mapping %q has no channel; its alerts are routed by priority", alarm)
+			continue
+		}
+		c.lintChannel(add, channel, fmt.Sprintf("alarm mapping %q", alarm))
+	}
+	for team, teamConfig := range c.Teams {
+		for _, channel := range teamConfig.Channels {
+			receiving[channel] = true
+			c.lintChannel(add, channel, fmt.Sprintf("team %s", team))
+		}
+	}
+	for project, channel := range c.SentryProjects {
+		receiving[channel] = true
+		c.lintChannel(add, channel, fmt.Sprintf("Sentry project %s", project))
+	}
+	for repo, channel := range c.GitHubRepos {
+		receiving[channel] = true
+		c.lintChannel(add, channel, fmt.Sprintf("GitHub repository %s", repo))
+	}
+	for project, channel := range c.GitLabProjects {
+		receiving[channel] = true
+		c.lintChannel(add, channel, fmt.Sprintf("GitLab project %s", project))
+	}
+	for app, channel := range c.ArgoCDApps {
+		receiving[channel] = true
+		c.lintChannel(add, channel, fmt.Sprintf("ArgoCD application %s", app))
+	}
+	for object, channel := range c.FluxObjects {
+		receiving[channel] = true
+		c.lintChannel(add, channel, fmt.Sprintf("Flux object %s", object))
+	}
+	for zone, channel := range c.DynatraceZones {
+		receiving[channel] = true
+		c.lintChannel(add, channel, fmt.Sprintf("Dynatrace zone %s", zone))
+	}
+	if c.BackupChannel != "" {
+		receiving[c.BackupChannel] = true
+		c.lintChannel(add, c.BackupChannel, "backup_channel")
+	}
+	for pipeline, channel := range c.PipelineChannels {
+		receiving[channel] = true
+		c.lintChannel(add, channel, fmt.Sprintf("pipeline %s", pipeline))
+	}
+	for name, webhook := range c.Webhooks {
+		c.lintWebhook(add, name, webhook)
+		if webhook.Channel != "" {
+			receiving[webhook.Channel] = true
+		}
+		for _, channel := range webhook.Priorities {
+			receiving[channel] = true
+		}
+	}
+
+	for category, priority := range c.RDSEventPriorities {
+		c.lintRDSEventPriority(add, category, priority)
+	}
+	if sync := c.SilenceSync; sync != nil {
+		if sync.GrafanaURL == "" && sync.AlertmanagerURL == "" {
+			add(LintError, "silence_sync has neither grafana_url nor alertmanager_url, so no silence is synced")
+		}
+		for _, base := range []struct{ setting, url string }{{"grafana_url", sync.GrafanaURL}, {"alertmanager_url", sync.AlertmanagerURL}} {
+			if u, err := url.Parse(base.url); base.url != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+				add(LintError, "silence_sync %s %q isn't an absolute URL, so no silence is synced there", base.setting, base.url)
+			}
+		}
+		if sync.GrafanaToken == "" && sync.AlertmanagerToken == "" {
+			add(LintWarning, "silence_sync is configured without GRAFANA_SILENCE_TOKEN or ALERTMANAGER_SILENCE_TOKEN, so it only reaches APIs that don't need authentication")
+		}
+	}
+	if snooze := c.CloudWatchSnooze; snooze != nil && snooze.Action != CloudWatchDisableActions && snooze.Action != CloudWatchSetState {
+		add(LintError, "cloudwatch_snooze has unknown action %q, so snoozing leaves CloudWatch alarms alone; expected %s or %s", snooze.Action, CloudWatchDisableActions, CloudWatchSetState)
+	}
+	if console := c.AWSConsole; console.SSOStartURL != "" && console.SSORole == "" && len(console.AccountRoles) == 0 {
+		add(LintError, "aws_console has sso_start_url without sso_role or account_roles, so console links can't say which permission set to sign in with")
+	} else if console.SSOStartURL == "" && (console.SSORole != "" || len(console.AccountRoles) > 0) {
+		add(LintWarning, "aws_console has a role but no sso_start_url, so console links open in whichever account is signed in")
+	}
+	for i, rule := range c.CloudTrailRules {
+		c.lintCloudTrailRule(add, i, rule)
+		if rule.Channel != "" {
+			receiving[rule.Channel] = true
+		}
+	}
+
+	channels := c.RouteChannels()
+	for _, channel := range channels {
+		if route := c.route(channel); route.NoDataPolicy == NoDataReroute && route.NoDataChannel != "" {
+			receiving[route.NoDataChannel] = true
+		}
+	}
+	for _, channel := range channels {
+		c.lintChannel(add, channel, "a route")
+		c.lintRoute(add, channel, c.route(channel))
+		if !receiving[channel] {
+			hint := ""
+			if similar := similarChannel(channel, receiving); similar != "" {
+				hint = fmt.Sprintf("; did you mean %s?", similar)
+			}
+			add(LintWarning, "route %s never applies: no alarm mapping, priority, team, Sentry project, GitHub repository, GitLab project, ArgoCD application, Flux object, Dynatrace zone, webhook or CloudTrail rule sends alerts there%s", channel, hint)
+		}
+	}
+
+	c.lintDropRules(add)
+	c.lintHeartbeats(add)
+	c.lintSourceQuotas(add)
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity == LintError
+		}
+		return findings[i].Message < findings[j].Message
+	})
+	return findings
+}
+
+// lintChannel flags channel names Slack can't post to
+func (c *Config) lintChannel(add func(severity, format string, args ...interface{}), channel, usedBy string) {
+	if !slackChannelPattern.MatchString(channel) {
+		add(LintWarning, "%s uses unknown channel %q; Slack channels are #lowercase-names or channel IDs", usedBy, channel)
+	}
+}
+
+// lintWebhook flags webhooks that reject every request or aren't verified as configured
+func (c *Config) lintWebhook(add func(severity, format string, args ...interface{}), name string, webhook WebhookConfig) {
+	if adapters := adapter.ConfigurableWebhooks(); !slices.Contains(adapters, webhook.Adapter) {
+		add(LintError, "webhook %s has unknown adapter %q and rejects every request; expected one of %s", name, webhook.Adapter, strings.Join(adapters, ", "))
+	}
+	if webhook.SecretEnv == "" {
+		add(LintError, "webhook %s has no secret_env and rejects every request", name)
+	} else if webhook.Secret == "" {
+		add(LintError, "webhook %s: %s is empty, so the webhook rejects every request", name, webhook.SecretEnv)
+	}
+	if webhook.Channel != "" {
+		c.lintChannel(add, webhook.Channel, fmt.Sprintf("webhook %s", name))
+	}
+	for priority, channel := range webhook.Priorities {
+		switch priority {
+		case "P0", "P1", "P2", "default":
+		default:
+			add(LintWarning, "webhook %s has a channel for %q, which isn't a priority, so it never applies", name, priority)
+		}
+		c.lintChannel(add, channel, fmt.Sprintf("webhook %s", name))
+	}
+	if webhook.RateLimit != nil && webhook.RateLimit.Max <= 0 {
+		add(LintWarning, "webhook %s has a rate limit of %d and rejects every request", name, webhook.RateLimit.Max)
+	}
+	if webhook.CallbackURL != "" {
+		if u, err := url.Parse(webhook.CallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(LintError, "webhook %s has callback_url %q, which isn't an http or https URL, so its callbacks fail", name, webhook.CallbackURL)
+		}
+	}
+}
+
+// lintCloudTrailRule flags CloudTrail rules that never match or fall back to the built-in priority
+func (c *Config) lintCloudTrailRule(add func(severity, format string, args ...interface{}), i int, rule CloudTrailRule) {
+	if rule.EventName == "" {
+		add(LintError, "cloudtrail rule %d has no event_name and never matches", i+1)
+	} else if _, err := path.Match(rule.EventName, ""); err != nil {
+		add(LintError, "cloudtrail rule %d has invalid event_name %q: %v", i+1, rule.EventName, err)
+	}
+	switch rule.Priority {
+	case "P0", "P1", "P2":
+	default:
+		add(LintWarning, "cloudtrail rule %d has priority %q, which isn't P0, P1 or P2, so its events are routed to the default channel", i+1, rule.Priority)
+	}
+	if rule.Channel != "" {
+		c.lintChannel(add, rule.Channel, fmt.Sprintf("cloudtrail rule %d", i+1))
+	}
+}
+
+// lintRDSEventPriority flags RDS event priorities for categories RDS doesn't use, or that
+// aren't priorities
+func (c *Config) lintRDSEventPriority(add func(severity, format string, args ...interface{}), category, priority string) {
+	if !slices.Contains(RDSEventCategories, strings.ToLower(category)) {
+		add(LintWarning, "rds_event_priorities has unknown category %q, which never matches; expected one of %s", category, strings.Join(RDSEventCategories, ", "))
+	}
+	switch priority {
+	case "P0", "P1", "P2":
+	default:
+		add(LintWarning, "rds_event_priorities gives %s events priority %q, which isn't P0, P1 or P2, so they are routed to the default channel", category, priority)
+	}
+}
+
+// lintHeartbeats flags heartbeats for inputs that don't exist, which would always alert, and
+// windows that can't be met
+func (c *Config) lintHeartbeats(add func(severity, format string, args ...interface{})) {
+	for input, window := range c.Heartbeats {
+		name, isWebhook := strings.CutPrefix(input, "webhook/")
+		if _, ok := c.Webhooks[name]; isWebhook && !ok {
+			add(LintError, "heartbeat for %s watches a webhook that isn't configured, so it always alerts", input)
+		} else if inputs := adapter.InputNames(); !isWebhook && !slices.Contains(inputs, input) {
+			add(LintError, "heartbeat for unknown input %q always alerts; expected webhook/<name> or one of %s", input, strings.Join(inputs, ", "))
+		}
+		if window < 2*time.Minute {
+			add(LintWarning, "heartbeat for %s allows only %s of silence; heartbeats are recorded and checked once a minute, so it alerts while the input is active", input, window)
+		}
+	}
+}
+
+// lintSourceQuotas flags quotas for sources that don't exist and quotas that hold back every alert
+func (c *Config) lintSourceQuotas(add func(severity, format string, args ...interface{})) {
+	for source, quota := range c.SourceQuotas {
+		if source != "*" && !containsFold(adapter.Sources(), source) {
+			add(LintWarning, "source quota for unknown source %q never applies", source)
+		}
+		if quota.Max <= 0 {
+			add(LintError, "source quota for %s has a max of %d and holds back every alert", source, quota.Max)
+		}
+	}
+}
+
+// lintRoute flags route settings that fall back to their defaults
+func (c *Config) lintRoute(add func(severity, format string, args ...interface{}), channel string, route RouteConfig) {
+	oneOf := func(setting, value string, valid ...string) {
+		if value == "" {
+			return
+		}
+		for _, v := range valid {
+			if value == v {
+				return
+			}
+		}
+		add(LintError, "route %s has unknown %s %q; expected one of %s", channel, setting, value, strings.Join(valid, ", "))
+	}
+	oneOf("layout", route.Layout, LayoutBlocks, LayoutAttachments)
+	oneOf("format", route.Format, FormatFull, FormatCompact, FormatRaw)
+	oneOf("nodata_policy", route.NoDataPolicy, NoDataDeliver, NoDataDrop, NoDataDowngrade, NoDataReroute)
+	oneOf("renderer", route.Renderer, render.NameSlack, render.NameMarkdown, render.NamePlainText, render.NameHTML, render.NameJSON)
+	oneOf("repeats", route.Repeats, RepeatsThread, RepeatsFull)
+
+	if route.NoDataPolicy == NoDataReroute && route.NoDataChannel == "" {
+		add(LintError, "route %s reroutes NoData alerts but has no nodata_channel", channel)
+	}
+	if route.Template != "" {
+		if source, ok := c.Template(route.Template); !ok {
+			add(LintError, "route %s uses unknown template %s", channel, route.Template)
+		} else if _, err := render.NewTemplateRenderer(route.Template, source, render.Emoji{}); err != nil {
+			add(LintError, "route %s: %v", channel, err)
+		}
+	}
+	if route.EmojiSet != "" {
+		if _, ok := c.EmojiSets[route.EmojiSet]; !ok {
+			add(LintError, "route %s uses unknown emoji set %s", channel, route.EmojiSet)
+		}
+	}
+	for priority := range route.RateLimits {
+		switch priority {
+		case "P0", "P1", "P2":
+		default:
+			add(LintWarning, "route %s has a rate limit for %q, which isn't a priority, so it never applies", channel, priority)
+		}
+	}
+	if route.Sampling != nil && route.Sampling.Threshold <= 0 {
+		add(LintWarning, "route %s samples rules without a threshold, so it never samples", channel)
+	}
+	c.lintQuickActions(add, channel, route.Actions)
+	if route.Logs != nil {
+		c.lintLogQuery(add, channel, *route.Logs)
+	}
+}
+
+// lintLogQuery flags Fetch logs queries that can't run
+func (c *Config) lintLogQuery(add func(severity, format string, args ...interface{}), channel string, logs LogQuery) {
+	switch logs.Type {
+	case LogQueryCloudWatch:
+		if len(logs.LogGroups) == 0 {
+			add(LintError, "log query of route %s has no log_groups to search", channel)
+		}
+	case LogQueryLoki:
+		if logs.URL == "" {
+			add(LintError, "log query of route %s has no Loki url", channel)
+		}
+	default:
+		add(LintError, "log query of route %s has unknown type %q; expected %s or %s", channel, logs.Type, LogQueryCloudWatch, LogQueryLoki)
+	}
+	if logs.Query == "" {
+		add(LintError, "log query of route %s has no query", channel)
+	}
+	for i, source := range append([]string{logs.Query}, logs.LogGroups...) {
+		if _, err := render.NewLogQueryRenderer("logs", source); err != nil {
+			what := "query"
+			if i > 0 {
+				what = "log group " + source
+			}
+			add(LintError, "log query of route %s has an invalid %s template: %v", channel, what, err)
+		}
+	}
+	for _, group := range logs.LogGroups {
+		if strings.Contains(group, "{{") && len(logs.AllowedLogGroups) == 0 {
+			add(LintError, "log query of route %s has templated log group %s but no allowed_log_groups, so it never searches it", channel, group)
+		}
+	}
+	for _, pattern := range logs.AllowedLogGroups {
+		if _, err := path.Match(pattern, ""); err != nil {
+			add(LintError, "log query of route %s has invalid allowed log group %q", channel, pattern)
+		}
+	}
+	for _, pattern := range logs.Alerts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			add(LintError, "log query of route %s has invalid alert pattern %q", channel, pattern)
+		}
+	}
+}
+
+// slackUserID matches Slack user IDs, such as U024BE7LH, which quick action users and priority
+// editors must be
+var slackUserID = regexp.MustCompile(`^[UW][A-Z0-9]+$`)
+
+// lintQuickActions flags quick actions that can't run or that nobody may run
+func (c *Config) lintQuickActions(add func(severity, format string, args ...interface{}), channel string, actions []QuickAction) {
+	names := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		switch {
+		case action.Name == "":
+			add(LintError, "route %s has a quick action without a name", channel)
+			continue
+		case names[action.Name]:
+			add(LintError, "route %s has more than one quick action named %s", channel, action.Name)
+		}
+		names[action.Name] = true
+
+		switch action.Type {
+		case QuickActionScaleGroup:
+			if len(action.Groups) == 0 {
+				add(LintWarning, "quick action %s of route %s has no groups, so it never changes an Auto Scaling group", action.Name, channel)
+			}
+		case QuickActionScaleDeployment, QuickActionRestartDeployment:
+			if action.Type == QuickActionScaleDeployment && action.Max <= 0 {
+				add(LintWarning, "quick action %s of route %s scales deployments without a max", action.Name, channel)
+			}
+			if len(action.Namespaces) == 0 {
+				add(LintWarning, "quick action %s of route %s has no namespaces, so it never changes a deployment", action.Name, channel)
+			}
+		default:
+			add(LintError, "quick action %s of route %s has unknown type %q; expected one of %s, %s, %s", action.Name, channel, action.Type, QuickActionScaleGroup, QuickActionScaleDeployment, QuickActionRestartDeployment)
+		}
+		if len(action.Users) == 0 {
+			add(LintWarning, "quick action %s of route %s has no users, so nobody may run it", action.Name, channel)
+		}
+		for _, user := range action.Users {
+			if !slackUserID.MatchString(user) {
+				add(LintWarning, "quick action %s of route %s has user %q, which isn't a Slack user ID, so they may not run it", action.Name, channel, user)
+			}
+		}
+		for _, pattern := range action.Alerts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				add(LintError, "quick action %s of route %s has invalid alert pattern %q", action.Name, channel, pattern)
+			}
+		}
+		for _, pattern := range append(append(append([]string(nil), action.Groups...), action.Namespaces...), action.Deployments...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				add(LintError, "quick action %s of route %s has invalid group, namespace or deployment pattern %q", action.Name, channel, pattern)
+			}
+		}
+	}
+}
+
+// lintDropRules flags drop rules that never match, match every alert, or only match alerts
+// an earlier rule already drops
+func (c *Config) lintDropRules(add func(severity, format string, args ...interface{})) {
+	for i, rule := range c.DropRules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule_%d", i)
+		}
+
+		if rule.Source != "" && !containsFold(adapter.Sources(), rule.Source) {
+			add(LintWarning, "drop rule %s never matches: unknown source %q", name, rule.Source)
+		}
+		if rule.Source == "" && rule.State == "" && rule.NameRegex == "" && len(rule.Labels) == 0 {
+			add(LintWarning, "drop rule %s has no conditions and drops every alert", name)
+		}
+		for j, earlier := range c.DropRules[:i] {
+			if dropRuleCovers(earlier, rule) {
+				earlierName := earlier.Name
+				if earlierName == "" {
+					earlierName = fmt.Sprintf("rule_%d", j)
+				}
+				add(LintWarning, "drop rule %s never matches: drop rule %s before it drops every alert it would", name, earlierName)
+				break
+			}
+		}
+	}
+}
+
+// dropRuleCovers reports whether every alert rule matches is also matched by earlier
+func dropRuleCovers(earlier, rule DropRule) bool {
+	if earlier.Source != "" && !strings.EqualFold(earlier.Source, rule.Source) {
+		return false
+	}
+	if earlier.State != "" && !strings.EqualFold(earlier.State, rule.State) {
+		return false
+	}
+	if earlier.NameRegex != "" && earlier.NameRegex != rule.NameRegex {
+		return false
+	}
+	for k, v := range earlier.Labels {
+		if value, ok := rule.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// similarChannel finds a receiving channel that differs from channel only in case or a
+// leading "#"
+func similarChannel(channel string, receiving map[string]bool) string {
+	normalized := strings.ToLower(strings.TrimPrefix(channel, "#"))
+	var matches []string
+	for candidate := range receiving {
+		if candidate != channel && strings.ToLower(strings.TrimPrefix(candidate, "#")) == normalized {
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[0]
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
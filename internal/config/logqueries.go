@@ -0,0 +1,92 @@
+package config
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Log query types
+const (
+	LogQueryCloudWatch = "cloudwatch" // a CloudWatch Logs Insights query
+	LogQueryLoki       = "loki"       // a LogQL query against Loki
+)
+
+// LogQuery is the query behind the Fetch logs button on a route's alerts, which posts the
+// results in the alert's thread. The query and log groups are Go templates seeing the alert
+// as dot, like message templates, e.g. filter instance = "{{ .Labels.InstanceId | insights }}".
+type LogQuery struct {
+	Type             string        `yaml:"type"`               // cloudwatch or loki
+	Query            string        `yaml:"query"`              // Logs Insights or LogQL query
+	LogGroups        []string      `yaml:"log_groups"`         // cloudwatch: log groups to search
+	AllowedLogGroups []string      `yaml:"allowed_log_groups"` // cloudwatch: shell wildcards templated log groups must match
+	Region           string        `yaml:"region"`             // cloudwatch: the alarm's region, or the default one, when unset
+	URL              string        `yaml:"url"`                // loki: base URL, e.g. http://loki.monitoring:3100
+	Tenant           string        `yaml:"tenant"`             // loki: X-Scope-OrgID, for multi-tenant Loki
+	Lookback         time.Duration `yaml:"lookback"`           // searched before the alert started, 15m when unset
+	Limit            int           `yaml:"limit"`              // results posted, 20 when unset
+	Alerts           []string      `yaml:"alerts"`             // alert names with shell wildcards; empty matches any
+}
+
+// logGroupName matches the characters CloudWatch allows in log group names
+var logGroupName = regexp.MustCompile(`^[A-Za-z0-9_./#-]{1,512}$`)
+
+// LogGroupAllowed reports whether a log group rendered from one of the log group templates may
+// be searched. Groups written out in full always may; templated ones, which take their values
+// from the alert, must match one of the allowed log groups.
+func (q LogQuery) LogGroupAllowed(source, group string) bool {
+	if !logGroupName.MatchString(group) {
+		return false
+	}
+	if !strings.Contains(source, "{{") {
+		return true
+	}
+	for _, pattern := range q.AllowedLogGroups {
+		if matched, _ := path.Match(pattern, group); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// LookbackWindow is how long before the alert started the query searches
+func (q LogQuery) LookbackWindow() time.Duration {
+	if q.Lookback <= 0 {
+		return 15 * time.Minute
+	}
+	return q.Lookback
+}
+
+// ResultLimit is how many results are posted
+func (q LogQuery) ResultLimit() int {
+	if q.Limit <= 0 {
+		return 20
+	}
+	return q.Limit
+}
+
+// Matches reports whether the query applies to alerts of this name, case-insensitively
+func (q LogQuery) Matches(alertName string) bool {
+	if len(q.Alerts) == 0 {
+		return true
+	}
+	for _, pattern := range q.Alerts {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(alertName)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// LogQueryTypes reports the log query types used by routes, so only the clients they need
+// are created
+func (c *Config) LogQueryTypes() map[string]bool {
+	types := make(map[string]bool)
+	for _, channel := range c.RouteChannels() {
+		if logs := c.route(channel).Logs; logs != nil {
+			types[logs.Type] = true
+		}
+	}
+	return types
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// KubernetesConfig enables operator mode, where routes, templates and silences are also read
+// from AlertRoute and AlertSilence custom resources
+type KubernetesConfig struct {
+	CRDs      bool
+	Namespace string // watch only this namespace, empty for every namespace
+}
+
+// Silence holds back alerts matching every set field between StartsAt and EndsAt
+type Silence struct {
+	Name      string // namespace/name of the AlertSilence it came from
+	Source    string
+	NameRegex string
+	Channels  []string // empty for any channel
+	Labels    map[string]string
+	StartsAt  time.Time // zero for already started
+	EndsAt    time.Time // zero for until deleted
+	Comment   string
+	CreatedBy string
+}
+
+// Active reports whether the silence applies at now
+func (s Silence) Active(now time.Time) bool {
+	return (s.StartsAt.IsZero() || !now.Before(s.StartsAt)) && (s.EndsAt.IsZero() || now.Before(s.EndsAt))
+}
+
+// DecodeRoute reads route options written as in alarm-channels.yaml, or as the equivalent JSON
+func DecodeRoute(data []byte) (RouteConfig, error) {
+	var route RouteConfig
+	if err := yaml.Unmarshal(data, &route); err != nil {
+		return RouteConfig{}, err
+	}
+	return route, nil
+}
+
+// Overlay is configuration read from custom resources. It sits under alarm-channels.yaml: an
+// alarm mapping, route or template the file defines keeps the file's definition.
+type Overlay struct {
+	AlarmMappings map[string]string
+	Routes        map[string]RouteConfig
+	Templates     map[string]string
+	Silences      []Silence
+}
+
+// overlayState is the current overlay merged with the file, always read and replaced under its
+// lock. Its maps are replaced, never modified, so readers can keep using the ones they got;
+// they are nil until an overlay is applied.
+type overlayState struct {
+	mu        sync.RWMutex
+	alarms    map[string]string
+	routes    map[string]RouteConfig
+	templates map[string]string
+	silences  []Silence
+}
+
+// ApplyOverlay replaces the configuration read from custom resources
+func (c *Config) ApplyOverlay(o Overlay) {
+	alarms := make(map[string]string, len(c.AlarmChannels)+len(o.AlarmMappings))
+	for name, channel := range o.AlarmMappings {
+		alarms[name] = channel
+	}
+	for name, channel := range c.AlarmChannels {
+		alarms[name] = channel
+	}
+	templates := make(map[string]string, len(c.Templates)+len(o.Templates))
+	for name, source := range o.Templates {
+		templates[name] = source
+	}
+	for name, source := range c.Templates {
+		templates[name] = source
+	}
+
+	c.overlay.mu.Lock()
+	defer c.overlay.mu.Unlock()
+	c.overlay.alarms = alarms
+	c.overlay.routes = o.Routes
+	c.overlay.templates = templates
+	c.overlay.silences = o.Silences
+}
+
+// AlarmMappings returns the alarm to channel mappings of the file and custom resources
+func (c *Config) AlarmMappings() map[string]string {
+	c.overlay.mu.RLock()
+	defer c.overlay.mu.RUnlock()
+	if c.overlay.alarms == nil {
+		return c.AlarmChannels
+	}
+	return c.overlay.alarms
+}
+
+// Template returns the source of the named template from the file or custom resources
+func (c *Config) Template(name string) (string, bool) {
+	c.overlay.mu.RLock()
+	defer c.overlay.mu.RUnlock()
+	if c.overlay.templates != nil {
+		source, ok := c.overlay.templates[name]
+		return source, ok
+	}
+	source, ok := c.Templates[name]
+	return source, ok
+}
+
+// Silences returns the silences read from custom resources
+func (c *Config) Silences() []Silence {
+	c.overlay.mu.RLock()
+	defer c.overlay.mu.RUnlock()
+	return c.overlay.silences
+}
+
+// RouteChannels lists the channels with a route in the file or custom resources
+func (c *Config) RouteChannels() []string {
+	seen := make(map[string]bool, len(c.Routes))
+	for channel := range c.Routes {
+		seen[channel] = true
+	}
+	c.overlay.mu.RLock()
+	for channel := range c.overlay.routes {
+		seen[channel] = true
+	}
+	c.overlay.mu.RUnlock()
+
+	channels := make([]string, 0, len(seen))
+	for channel := range seen {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// route returns the channel's route as configured, from the file or else custom resources
+func (c *Config) route(channel string) RouteConfig {
+	if route, ok := c.Routes[channel]; ok {
+		return route
+	}
+	c.overlay.mu.RLock()
+	defer c.overlay.mu.RUnlock()
+	return c.overlay.routes[channel]
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Quick action types
+const (
+	QuickActionScaleGroup        = "scale_group"        // adds instances to an Auto Scaling group
+	QuickActionScaleDeployment   = "scale_deployment"   // adds replicas to a Kubernetes deployment
+	QuickActionRestartDeployment = "restart_deployment" // restarts a Kubernetes deployment's pods
+)
+
+// QuickAction is a button on a route's alerts that remediates the Auto Scaling group or
+// Kubernetes deployment the alert is about, after the user confirms it. Alerts about neither
+// don't get the button.
+type QuickAction struct {
+	Name   string   `yaml:"name"`   // unique in the route, e.g. scale-out
+	Label  string   `yaml:"label"`  // button text, e.g. "Scale +2"; derived from the type when unset
+	Type   string   `yaml:"type"`   // scale_group, scale_deployment or restart_deployment
+	Step   int      `yaml:"step"`   // instances or replicas a scale action adds, 1 when unset
+	Max    int      `yaml:"max"`    // a scale action's ceiling; groups are also held to their max size
+	Alerts []string `yaml:"alerts"` // alert names with shell wildcards, e.g. "*CPU*"; empty matches any
+	Users  []string `yaml:"users"`  // Slack user IDs allowed to run it; empty allows nobody
+	// scale_group actions only change Auto Scaling groups with these names, with shell
+	// wildcards, since alert labels name them. Empty allows none.
+	Groups []string `yaml:"groups"`
+	// Deployment actions only change deployments in these namespaces, with shell wildcards,
+	// since alert labels name them and anyone who can send an alert sets its labels. Empty
+	// allows none.
+	Namespaces []string `yaml:"namespaces"`
+	// Deployment names with shell wildcards, e.g. "api-*", further limiting the deployments of
+	// those namespaces a deployment action changes; empty allows any
+	Deployments []string `yaml:"deployments"`
+}
+
+// ButtonLabel is the action's label, or one derived from its type
+func (a QuickAction) ButtonLabel() string {
+	switch {
+	case a.Label != "":
+		return a.Label
+	case a.Type == QuickActionRestartDeployment:
+		return "Restart deployment"
+	default:
+		return "Scale +" + strconv.Itoa(a.ScaleStep())
+	}
+}
+
+// ScaleStep is the instances or replicas a scale action adds
+func (a QuickAction) ScaleStep() int {
+	if a.Step <= 0 {
+		return 1
+	}
+	return a.Step
+}
+
+// Matches reports whether the action applies to alerts of this name, case-insensitively
+func (a QuickAction) Matches(alertName string) bool {
+	if len(a.Alerts) == 0 {
+		return true
+	}
+	for _, pattern := range a.Alerts {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(alertName)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the Slack user may run the action. Only IDs count, since users can
+// change their names.
+func (a QuickAction) Allows(userID string) bool {
+	for _, user := range a.Users {
+		if user == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsGroup reports whether a scale_group action may change this Auto Scaling group
+func (a QuickAction) AllowsGroup(name string) bool {
+	return matchesAny(a.Groups, name)
+}
+
+// AllowsDeployment reports whether a deployment action may change this deployment
+func (a QuickAction) AllowsDeployment(namespace, name string) bool {
+	if !matchesAny(a.Namespaces, namespace) {
+		return false
+	}
+	return len(a.Deployments) == 0 || matchesAny(a.Deployments, name)
+}
+
+// QuickActionTypes reports the quick action types used by routes, so only the clients they
+// need are created
+func (c *Config) QuickActionTypes() map[string]bool {
+	types := make(map[string]bool)
+	for _, channel := range c.RouteChannels() {
+		for _, action := range c.route(channel).Actions {
+			types[action.Type] = true
+		}
+	}
+	return types
+}
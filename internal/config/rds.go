@@ -0,0 +1,18 @@
+package config
+
+import "strings"
+
+// RDSEventCategories are the categories RDS assigns its events, which rds_event_priorities
+// are keyed by
+var RDSEventCategories = []string{
+	"availability", "backtrack", "backup", "configuration change", "creation", "deletion",
+	"failover", "failure", "global-failover", "low storage", "maintenance", "notification",
+	"read replica", "recovery", "restoration", "security", "security patching",
+}
+
+// RDSEventPriority returns the rds_event_priorities priority of an RDS event category,
+// reporting false when it has none
+func (c *Config) RDSEventPriority(category string) (string, bool) {
+	priority, ok := c.RDSEventPriorities[strings.ToLower(category)]
+	return priority, ok
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// routeNode is a route as written in alarm-channels.yaml: its own settings, and the routes
+// nested under it as "routes"
+type routeNode = map[interface{}]interface{}
+
+// resolveRoutes flattens nested routes into one route per channel. A route inherits every
+// setting it doesn't set itself from the route it is nested under or, at the top level, from the
+// route_defaults of the team on call for its channel. Team channels without a route of their
+// own get the team's defaults.
+func resolveRoutes(data []byte, teams map[string]TeamConfig) (map[string]RouteConfig, error) {
+	var file struct {
+		Routes map[string]routeNode `yaml:"routes"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	// A channel shared by several teams takes the defaults of the first by name
+	names := make([]string, 0, len(teams))
+	for name := range teams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	teamDefaults := make(map[string]RouteConfig)
+	for _, name := range names {
+		for _, channel := range teams[name].Channels {
+			if _, ok := teamDefaults[channel]; !ok {
+				teamDefaults[channel] = teams[name].RouteDefaults
+			}
+		}
+	}
+
+	routes := make(map[string]RouteConfig)
+	for channel, node := range file.Routes {
+		if err := resolveRoute(routes, channel, node, teamDefaults[channel]); err != nil {
+			return nil, err
+		}
+	}
+	for channel, defaults := range teamDefaults {
+		if _, ok := routes[channel]; !ok {
+			routes[channel] = defaults
+		}
+	}
+	return routes, nil
+}
+
+// resolveRoute decodes the node's settings over its parent's and adds it and its children to routes
+func resolveRoute(routes map[string]RouteConfig, channel string, node routeNode, parent RouteConfig) error {
+	if _, ok := routes[channel]; ok {
+		return fmt.Errorf("route %s is defined more than once", channel)
+	}
+
+	own := make(routeNode, len(node))
+	for key, value := range node {
+		if key != "routes" {
+			own[key] = value
+		}
+	}
+	data, err := yaml.Marshal(own)
+	if err != nil {
+		return fmt.Errorf("route %s: %v", channel, err)
+	}
+	// Unmarshaling over a copy of the parent keeps every setting the node doesn't mention,
+	// including ones it sets back to false or empty
+	route := parent.clone()
+	if err := yaml.Unmarshal(data, &route); err != nil {
+		return fmt.Errorf("route %s: %v", channel, err)
+	}
+	routes[channel] = route
+
+	children, ok := node["routes"].(routeNode)
+	if !ok && node["routes"] != nil {
+		return fmt.Errorf("route %s: routes must map channels to routes", channel)
+	}
+	for key, value := range children {
+		child, ok := value.(routeNode)
+		if !ok && value != nil {
+			return fmt.Errorf("route %v under %s must be a mapping", key, channel)
+		}
+		if err := resolveRoute(routes, fmt.Sprint(key), child, route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clone copies a route along with the maps and pointed-to settings that decoding into it would
+// otherwise write through to the route it was copied from. Slices are replaced, not written
+// into, when decoded.
+func (r RouteConfig) clone() RouteConfig {
+	r.RateLimits = maps.Clone(r.RateLimits)
+	if r.Sampling != nil {
+		sampling := *r.Sampling
+		r.Sampling = &sampling
+	}
+	if r.Logs != nil {
+		logs := *r.Logs
+		r.Logs = &logs
+	}
+	return r
+}
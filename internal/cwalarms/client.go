@@ -0,0 +1,51 @@
+package cwalarms
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"alert-dispatcher/internal/awsquery"
+)
+
+// apiVersion is the version of the CloudWatch query API the client speaks
+const apiVersion = "2010-08-01"
+
+// Client disables and re-enables the actions of CloudWatch alarms and sets their state through
+// the CloudWatch query API. Alarms are changed in the region of their ARN.
+type Client struct {
+	query *awsquery.Client
+}
+
+func NewClient(timeout time.Duration) (*Client, error) {
+	query, err := awsquery.NewClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{query: query}, nil
+}
+
+// DisableAlarmActions stops the alarm from running its actions, such as SNS notifications,
+// until they are enabled again
+func (c *Client) DisableAlarmActions(ctx context.Context, region, alarmName string) error {
+	return c.call(ctx, region, url.Values{"Action": {"DisableAlarmActions"}, "AlarmNames.member.1": {alarmName}})
+}
+
+// EnableAlarmActions lets the alarm run its actions again
+func (c *Client) EnableAlarmActions(ctx context.Context, region, alarmName string) error {
+	return c.call(ctx, region, url.Values{"Action": {"EnableAlarmActions"}, "AlarmNames.member.1": {alarmName}})
+}
+
+// SetAlarmState sets the alarm's state, e.g. to OK, until its next evaluation
+func (c *Client) SetAlarmState(ctx context.Context, region, alarmName, stateValue, reason string) error {
+	return c.call(ctx, region, url.Values{
+		"Action":      {"SetAlarmState"},
+		"AlarmName":   {alarmName},
+		"StateValue":  {stateValue},
+		"StateReason": {reason},
+	})
+}
+
+func (c *Client) call(ctx context.Context, region string, params url.Values) error {
+	return c.query.Call(ctx, "monitoring", apiVersion, region, params, nil)
+}
@@ -0,0 +1,118 @@
+package cwlogs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/awsquery"
+)
+
+// targetPrefix names the version of the CloudWatch Logs JSON API the client speaks
+const targetPrefix = "Logs_20140328."
+
+// pollInterval is how often a running query's results are checked
+const pollInterval = time.Second
+
+// Client runs CloudWatch Logs Insights queries through the CloudWatch Logs API
+type Client struct {
+	query *awsquery.Client
+}
+
+func NewClient(timeout time.Duration) (*Client, error) {
+	query, err := awsquery.NewClient(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{query: query}, nil
+}
+
+type startQueryRequest struct {
+	LogGroupNames []string `json:"logGroupNames"`
+	QueryString   string   `json:"queryString"`
+	StartTime     int64    `json:"startTime"`
+	EndTime       int64    `json:"endTime"`
+	Limit         int      `json:"limit"`
+}
+
+type queryID struct {
+	QueryID string `json:"queryId"`
+}
+
+type queryResults struct {
+	Status  string `json:"status"` // Scheduled, Running, Complete, Failed, Cancelled, Timeout
+	Results [][]struct {
+		Field string `json:"field"`
+		Value string `json:"value"`
+	} `json:"results"`
+}
+
+// Query runs the Logs Insights query over the log groups between start and end, waiting for it
+// to complete, and returns up to limit rows in the query's order. Rows with a @message are
+// "<@timestamp> <@message>", others their fields as name=value. An empty region is the
+// default chain's.
+func (c *Client) Query(ctx context.Context, region string, logGroups []string, query string, start, end time.Time, limit int) ([]string, error) {
+	var started queryID
+	err := c.call(ctx, region, "StartQuery", startQueryRequest{
+		LogGroupNames: logGroups,
+		QueryString:   query,
+		StartTime:     start.Unix(),
+		EndTime:       end.Unix(),
+		Limit:         limit,
+	}, &started)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Stop the query so it doesn't keep scanning, and billing, after we've given up
+			stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			c.call(stopCtx, region, "StopQuery", started, nil)
+			cancel()
+			return nil, fmt.Errorf("logs insights query %s didn't complete: %v", started.QueryID, ctx.Err())
+		case <-ticker.C:
+		}
+
+		var results queryResults
+		if err := c.call(ctx, region, "GetQueryResults", started, &results); err != nil {
+			return nil, err
+		}
+		switch results.Status {
+		case "Scheduled", "Running":
+			continue
+		case "Complete":
+		default:
+			return nil, fmt.Errorf("logs insights query %s ended %s", started.QueryID, results.Status)
+		}
+
+		var rows []string
+		for _, result := range results.Results {
+			fields := make(map[string]string, len(result))
+			var pairs []string
+			for _, field := range result {
+				fields[field.Field] = field.Value
+				if field.Field != "@ptr" {
+					pairs = append(pairs, field.Field+"="+field.Value)
+				}
+			}
+			if message, ok := fields["@message"]; ok {
+				rows = append(rows, strings.TrimSpace(fields["@timestamp"]+" "+strings.TrimRight(message, "\n")))
+			} else {
+				rows = append(rows, strings.Join(pairs, " "))
+			}
+			if len(rows) == limit {
+				break
+			}
+		}
+		return rows, nil
+	}
+}
+
+func (c *Client) call(ctx context.Context, region, operation string, in, out interface{}) error {
+	return c.query.CallJSON(ctx, "logs", targetPrefix+operation, region, in, out)
+}
@@ -0,0 +1,233 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/state"
+)
+
+// Firing alerts that haven't been heard of for a day are no longer listed as active
+const activeAlertTTL = 24 * time.Hour
+
+// ResolveTimeout is how long an alert posted to the Alertmanager API without an endsAt, or
+// past it, stays firing without being posted again, as in Alertmanager
+const ResolveTimeout = 5 * time.Minute
+
+// ActiveAlert is a firing alert, as the Alertmanager API lists it
+type ActiveAlert struct {
+	Alert      *alert.Alert      `json:"alert"`
+	AlertID    string            `json:"alert_id"` // of its first firing
+	Labels     map[string]string `json:"labels"`
+	StartsAt   time.Time         `json:"starts_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	EndsAt     time.Time         `json:"ends_at"` // zero until the source says when it stops firing
+	SilencedBy []string          `json:"-"`       // AlertSilence names and API silence IDs
+}
+
+// activeIndex tracks the firing alerts by fingerprint until they resolve, silenced ones
+// included, in the state store, so every replica lists the alerts any of them received.
+// Replicas update alerts without locking, so of concurrent firings the last one's labels and
+// times are kept.
+type activeIndex struct {
+	store state.Store
+}
+
+func newActiveIndex(store state.Store) *activeIndex {
+	return &activeIndex{store: store}
+}
+
+// activePrefix starts the keys of active alerts, "active:<fingerprint>"
+const activePrefix = "active:"
+
+func activeKey(alertMsg *alert.Alert) string {
+	return activePrefix + alertMsg.Fingerprint()
+}
+
+// track records a firing or NoData alert, keeping when it first fired, and forgets it once it
+// resolves. State errors are logged, leaving the alert unlisted or listed until its TTL.
+func (a *activeIndex) track(ctx context.Context, alertMsg *alert.Alert, alertID string) {
+	if err := a.update(ctx, alertMsg, alertID, true); err != nil {
+		log.Printf("Failed to track active alert %s: %v", alertMsg.Name, err)
+	}
+}
+
+// refresh updates an alert already tracked, or forgets it once it resolves
+func (a *activeIndex) refresh(ctx context.Context, alertMsg *alert.Alert) {
+	if err := a.update(ctx, alertMsg, "", false); err != nil {
+		log.Printf("Failed to refresh active alert %s: %v", alertMsg.Name, err)
+	}
+}
+
+func (a *activeIndex) update(ctx context.Context, alertMsg *alert.Alert, alertID string, add bool) error {
+	key := activeKey(alertMsg)
+	if alertMsg.IsResolved() {
+		return a.store.Delete(ctx, key)
+	}
+
+	now := time.Now()
+	active := ActiveAlert{AlertID: alertID, StartsAt: now}
+	if alertMsg.StartsAt != nil {
+		active.StartsAt = *alertMsg.StartsAt
+	}
+	active.set(alertMsg, now)
+	if add {
+		data, err := json.Marshal(active)
+		if err != nil {
+			return err
+		}
+		first, err := a.store.SetNX(ctx, key, string(data), activeAlertTTL)
+		if err != nil || first {
+			return err
+		}
+	}
+
+	// Tracked already: keep its first firing's ID and start
+	value, ok, err := a.store.Get(ctx, key)
+	if err != nil || !ok {
+		return err
+	}
+	if err := json.Unmarshal([]byte(value), &active); err != nil {
+		return err
+	}
+	active.set(alertMsg, now)
+	data, err := json.Marshal(active)
+	if err != nil {
+		return err
+	}
+	return a.store.Set(ctx, key, string(data), activeAlertTTL)
+}
+
+// set takes the alert's latest firing
+func (a *ActiveAlert) set(alertMsg *alert.Alert, now time.Time) {
+	a.Alert = alertMsg
+	a.Labels = AlertLabels(alertMsg)
+	a.UpdatedAt = now
+	a.EndsAt = time.Time{}
+	if alertMsg.EndsAt != nil {
+		a.EndsAt = *alertMsg.EndsAt
+	}
+}
+
+// list returns the alerts still firing, sorted by name. Alerts past their endsAt are left for
+// their TTL to remove, since a later firing may still extend them.
+func (a *activeIndex) list(ctx context.Context, now time.Time) ([]ActiveAlert, error) {
+	values, err := a.store.Scan(ctx, activePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []ActiveAlert
+	for key, value := range values {
+		var entry ActiveAlert
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			log.Printf("Failed to decode active alert %s: %v", key, err)
+			continue
+		}
+		if !entry.EndsAt.IsZero() && now.After(entry.EndsAt) {
+			continue
+		}
+		active = append(active, entry)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Alert.Name < active[j].Alert.Name })
+	return active, nil
+}
+
+// ActiveAlerts returns the firing alerts any replica received, with the silences holding each
+// back
+func (d *Dispatcher) ActiveAlerts(ctx context.Context) ([]ActiveAlert, error) {
+	active, err := d.active.list(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range active {
+		active[i].SilencedBy = d.silencedBy(ctx, active[i].Alert, false)
+	}
+	return active, nil
+}
+
+// postedKey is "posted:<fingerprint>", set while an alert posted to the Alertmanager API fires
+func postedKey(alertMsg *alert.Alert) string {
+	return "posted:" + alertMsg.Fingerprint()
+}
+
+// DispatchPosted dispatches an alert posted to the Alertmanager API. Prometheus posts every
+// firing alert again each minute, and its resolution for a while after, so only the first
+// firing and first resolution are dispatched; the rest keep a delivered alert listed as
+// active. Which alerts are firing is kept in the state store, so the replicas share it.
+func (d *Dispatcher) DispatchPosted(ctx context.Context, alertMsg *alert.Alert, alertID string) error {
+	key := postedKey(alertMsg)
+	if alertMsg.IsResolved() {
+		_, firing, err := d.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if !firing {
+			d.active.refresh(ctx, alertMsg)
+			return nil
+		}
+		if err := d.store.Delete(ctx, key); err != nil {
+			return err
+		}
+		return d.Dispatch(ctx, alertMsg, alertID)
+	}
+
+	ttl := ResolveTimeout
+	if alertMsg.EndsAt != nil {
+		ttl += time.Until(*alertMsg.EndsAt)
+	}
+	first, err := d.store.SetNX(ctx, key, alertID, ttl)
+	if err != nil {
+		return err
+	}
+	if !first {
+		d.active.refresh(ctx, alertMsg)
+		return d.store.Set(ctx, key, alertID, ttl)
+	}
+	if err := d.Dispatch(ctx, alertMsg, alertID); err != nil {
+		// Dispatched again when Prometheus next posts it
+		d.store.Delete(ctx, key)
+		return err
+	}
+	return nil
+}
+
+// A failure is resolved by the next success within this long; later successes aren't posted
+const failingTTL = 7 * 24 * time.Hour
+
+// failingKey is "failing:<fingerprint>", set while an alert from DispatchFailures is failing
+func failingKey(alertMsg *alert.Alert) string {
+	return "failing:" + alertMsg.Fingerprint()
+}
+
+// DispatchFailures dispatches an alert from a source that reports every success as well as
+// every failure, such as CI runs: failures are dispatched, and a success only when it resolves
+// an earlier failure, so routine successes aren't posted. It reports whether the alert was
+// dispatched. Which alerts are failing is kept in the state store, so the replicas share it.
+func (d *Dispatcher) DispatchFailures(ctx context.Context, alertMsg *alert.Alert, alertID string) (bool, error) {
+	key := failingKey(alertMsg)
+	if alertMsg.IsResolved() {
+		failedID, failing, err := d.store.Get(ctx, key)
+		if err != nil || !failing {
+			return false, err
+		}
+		if err := d.store.Delete(ctx, key); err != nil {
+			return false, err
+		}
+		// The success goes where the failure went, which a failure of another priority may
+		// have sent elsewhere
+		if record, ok, err := d.archive.Get(ctx, failedID); err == nil && ok && record.Alert != nil {
+			alertMsg.Channel, alertMsg.Severity = record.Alert.Channel, record.Alert.Severity
+		}
+		return true, d.Dispatch(ctx, alertMsg, alertID)
+	}
+
+	if err := d.store.Set(ctx, key, alertID, failingTTL); err != nil {
+		return false, err
+	}
+	return true, d.Dispatch(ctx, alertMsg, alertID)
+}
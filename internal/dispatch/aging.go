@@ -0,0 +1,61 @@
+package dispatch
+
+import (
+	"fmt"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// agingStep is how reminders about an alert look once it has been firing for at least after
+type agingStep struct {
+	after time.Duration
+	emoji string
+	color string
+}
+
+// agingSteps ramp from yellow to purple as an unhandled alert goes stale
+var agingSteps = []agingStep{
+	{after: 0, emoji: "🟡", color: "#ECB22E"},
+	{after: 30 * time.Minute, emoji: "🟠", color: "#E8912D"},
+	{after: 2 * time.Hour, emoji: "🔴", color: "#E01E5A"},
+	{after: 6 * time.Hour, emoji: "🟣", color: "#7D3C98"},
+}
+
+// agingOf returns the step an alert firing for age has reached
+func agingOf(age time.Duration) agingStep {
+	step := agingSteps[0]
+	for _, s := range agingSteps[1:] {
+		if age >= s.after {
+			step = s
+		}
+	}
+	return step
+}
+
+// firingFor is how long the alert has been firing: since the source says it started when it
+// says so, otherwise since its first firing was posted. Zero means unknown.
+func firingFor(alertMsg *alert.Alert, f firing) time.Duration {
+	since := f.FiredAt
+	if alertMsg.StartsAt != nil && !alertMsg.StartsAt.IsZero() && (since.IsZero() || alertMsg.StartsAt.Before(since)) {
+		since = *alertMsg.StartsAt
+	}
+	if since.IsZero() {
+		return 0
+	}
+	return time.Since(since)
+}
+
+// formatAge renders an age to the minute, or to the day past a day, e.g. "2h 15m" or "3d 4h"
+func formatAge(age time.Duration) string {
+	age = age.Round(time.Minute)
+	days, hours, minutes := int(age/(24*time.Hour)), int(age/time.Hour)%24, int(age/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,33 @@
+package dispatch
+
+import (
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/metrics"
+)
+
+// Alerts as metrics, so dashboards can chart alert frequency and overlap next to service metrics.
+// ALERTS mirrors the Prometheus series of the same name: 1 while an alert is active, gone once resolved.
+var (
+	activeAlerts = metrics.NewGauge("ALERTS",
+		"Active alerts seen by the dispatcher.", "alertname", "alertstate", "severity", "source")
+	alertTransitions = metrics.NewCounter("alert_dispatcher_alert_transitions_total",
+		"Alert state transitions received, by source, severity and status.", "source", "severity", "status")
+)
+
+// recordAlertMetrics updates the alert metrics for a transition when they are enabled
+func (d *Dispatcher) recordAlertMetrics(alertMsg *alert.Alert) {
+	if !d.config.ExportAlertMetrics {
+		return
+	}
+
+	alertTransitions.Inc(alertMsg.Source, alertMsg.Severity, alertMsg.Status)
+
+	// A resolution, or a firing at another severity, replaces every series of the alert, since
+	// resolutions don't always carry the severity the alert fired at
+	activeAlerts.DeleteWhere(func(labels []string) bool {
+		return labels[0] == alertMsg.Name && labels[3] == alertMsg.Source
+	})
+	if alertMsg.Status == alert.StatusFiring || alertMsg.Status == alert.StatusNoData {
+		activeAlerts.Set(1, alertMsg.Name, alertMsg.Status, alertMsg.Severity, alertMsg.Source)
+	}
+}
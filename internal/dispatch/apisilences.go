@@ -0,0 +1,419 @@
+package dispatch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/state"
+)
+
+const (
+	// apiSilencePrefix starts the keys of silences created through the Alertmanager API,
+	// "silence:<id>"
+	apiSilencePrefix = "silence:"
+	// legacySilencesKey held every API silence as one document, before each had its own key
+	legacySilencesKey = "silences:api"
+	// Expired silences stay listed this long, as in Alertmanager
+	silenceRetention = 5 * 24 * time.Hour
+	// How long a replica applies the silences it last read before reading them again
+	silenceRefresh = 10 * time.Second
+)
+
+// Silence states, as Alertmanager reports them
+const (
+	SilencePending = "pending"
+	SilenceActive  = "active"
+	SilenceExpired = "expired"
+)
+
+// ErrSilenceNotFound is an API silence ID that doesn't exist, or whose retention has passed
+var ErrSilenceNotFound = errors.New("silence not found")
+
+// InvalidSilenceError is an API silence that can't be created or changed as asked
+type InvalidSilenceError struct {
+	Reason string
+}
+
+func (e *InvalidSilenceError) Error() string {
+	return "invalid silence: " + e.Reason
+}
+
+// Matcher matches an alert label, as in Alertmanager silences
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual *bool  `json:"isEqual"` // false negates the match; older clients leave it out, meaning true
+
+	re *regexp.Regexp
+}
+
+// compile checks the matcher, defaulting IsEqual and anchoring its regex as Alertmanager does
+func (m *Matcher) compile() error {
+	if m.Name == "" {
+		return fmt.Errorf("matcher without a label name")
+	}
+	if m.IsEqual == nil {
+		isEqual := true
+		m.IsEqual = &isEqual
+	}
+	if m.IsRegex {
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex for %s: %v", m.Name, err)
+		}
+		m.re = re
+	}
+	return nil
+}
+
+// matcherPattern is a matcher as amtool and Alertmanager's filter parameters write it
+var matcherPattern = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// ParseMatcher reads a matcher written name="value", name!="value", name=~"regex" or
+// name!~"regex", the quotes optional
+func ParseMatcher(text string) (Matcher, error) {
+	parts := matcherPattern.FindStringSubmatch(text)
+	if parts == nil {
+		return Matcher{}, fmt.Errorf("invalid matcher %q", text)
+	}
+	value := parts[3]
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	isEqual := parts[2] == "=" || parts[2] == "=~"
+	m := Matcher{Name: parts[1], Value: value, IsRegex: strings.HasSuffix(parts[2], "~"), IsEqual: &isEqual}
+	if err := m.compile(); err != nil {
+		return Matcher{}, err
+	}
+	return m, nil
+}
+
+// Matches reports whether the label set satisfies the matcher; a missing label is empty. A
+// regex matcher that wasn't compiled matches nothing, negated or not.
+func (m Matcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	matched := value == m.Value
+	if m.IsRegex {
+		if m.re == nil {
+			return false
+		}
+		matched = m.re.MatchString(value)
+	}
+	return matched == (m.IsEqual == nil || *m.IsEqual)
+}
+
+// String writes the matcher as amtool does, e.g. alertname=~"High.*"
+func (m Matcher) String() string {
+	negated := m.IsEqual != nil && !*m.IsEqual
+	op := "="
+	switch {
+	case m.IsRegex && negated:
+		op = "!~"
+	case m.IsRegex:
+		op = "=~"
+	case negated:
+		op = "!="
+	}
+	return m.Name + op + strconv.Quote(m.Value)
+}
+
+// APISilence is a silence created through the Alertmanager API. Unlike AlertSilences, which
+// match the alert's name and channel, it matches labels, including the alertname, severity,
+// source and channel labels AlertLabels adds.
+type APISilence struct {
+	ID        string    `json:"id"`
+	Matchers  []Matcher `json:"matchers"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// State is pending before the silence starts, active until it ends and expired after
+func (s APISilence) State(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return SilencePending
+	case now.Before(s.EndsAt):
+		return SilenceActive
+	default:
+		return SilenceExpired
+	}
+}
+
+func (s APISilence) matches(labels map[string]string, now time.Time) bool {
+	if s.State(now) != SilenceActive {
+		return false
+	}
+	for _, m := range s.Matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// coversChannels reports whether the silence can hold back alerts in any of the channels: its
+// channel matchers, if any, accept one of them
+func (s APISilence) coversChannels(channels map[string]bool) bool {
+	for channel := range channels {
+		labels := map[string]string{"channel": channel}
+		covered := true
+		for _, m := range s.Matchers {
+			if m.Name == "channel" && !m.Matches(labels) {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// compile checks every matcher of the silence
+func (s *APISilence) compile() error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("no matchers")
+	}
+	for i := range s.Matchers {
+		if err := s.Matchers[i].compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AlertLabels are the labels API silences match and the Alertmanager API lists: the alert's
+// own, plus alertname, severity, source and channel unless it already has them
+func AlertLabels(alertMsg *alert.Alert) map[string]string {
+	labels := make(map[string]string, len(alertMsg.Labels)+4)
+	for k, v := range alertMsg.Labels {
+		labels[k] = v
+	}
+	for k, v := range map[string]string{
+		"alertname": alertMsg.Name,
+		"severity":  alertMsg.Severity,
+		"source":    alertMsg.Source,
+		"channel":   alertMsg.Channel,
+	} {
+		if _, ok := labels[k]; !ok && v != "" {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// apiSilences keeps the API silences in the state store, each under its own key, so every
+// replica applies them, and the last read in memory for silenceRefresh. Replicas change a
+// silence without locking, so of two changes to the same silence at the same moment one wins.
+type apiSilences struct {
+	store state.Store
+
+	mu       sync.Mutex // guards the cache
+	cached   []APISilence
+	cachedAt time.Time
+}
+
+func newAPISilences(store state.Store) *apiSilences {
+	return &apiSilences{store: store}
+}
+
+func apiSilenceKey(id string) string {
+	return apiSilencePrefix + id
+}
+
+// load reads the silences from the store, moving any left in the legacy document to their own
+// keys first
+func (a *apiSilences) load(ctx context.Context) ([]APISilence, error) {
+	if err := a.migrate(ctx); err != nil {
+		return nil, err
+	}
+	values, err := a.store.Scan(ctx, apiSilencePrefix)
+	if err != nil {
+		return nil, err
+	}
+	silences := make([]APISilence, 0, len(values))
+	for key, value := range values {
+		var silence APISilence
+		if err := json.Unmarshal([]byte(value), &silence); err != nil {
+			log.Printf("Skipping silence %s: failed to decode: %v", strings.TrimPrefix(key, apiSilencePrefix), err)
+			continue
+		}
+		if err := silence.compile(); err != nil {
+			log.Printf("Skipping silence %s: %v", silence.ID, err)
+			continue
+		}
+		silences = append(silences, silence)
+	}
+	return silences, nil
+}
+
+// migrate moves the silences of the legacy document to their own keys
+func (a *apiSilences) migrate(ctx context.Context) error {
+	value, ok, err := a.store.Get(ctx, legacySilencesKey)
+	if err != nil || !ok {
+		return err
+	}
+	var silences []APISilence
+	if err := json.Unmarshal([]byte(value), &silences); err != nil {
+		return fmt.Errorf("failed to decode silences: %v", err)
+	}
+	now := time.Now()
+	for _, silence := range silences {
+		if err := a.save(ctx, silence, now); err != nil {
+			return err
+		}
+	}
+	return a.store.Delete(ctx, legacySilencesKey)
+}
+
+// current returns the silences read at most silenceRefresh ago. When the store can't be read,
+// the silences read last keep applying.
+func (a *apiSilences) current(ctx context.Context) []APISilence {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if time.Since(a.cachedAt) < silenceRefresh {
+		return a.cached
+	}
+	silences, err := a.load(ctx)
+	if err != nil {
+		log.Printf("Failed to read API silences, applying the ones read earlier: %v", err)
+		return a.cached
+	}
+	a.cached, a.cachedAt = silences, time.Now()
+	return silences
+}
+
+// get reads one silence, which is gone once silenceRetention has passed since it ended
+func (a *apiSilences) get(ctx context.Context, id string) (APISilence, bool, error) {
+	value, ok, err := a.store.Get(ctx, apiSilenceKey(id))
+	if err != nil || !ok {
+		return APISilence{}, false, err
+	}
+	var silence APISilence
+	if err := json.Unmarshal([]byte(value), &silence); err != nil {
+		return APISilence{}, false, fmt.Errorf("failed to decode silence %s: %v", id, err)
+	}
+	return silence, true, nil
+}
+
+// save writes a silence, which expires silenceRetention after it ends, and makes this replica
+// read the silences again
+func (a *apiSilences) save(ctx context.Context, silence APISilence, now time.Time) error {
+	ttl := silence.EndsAt.Sub(now) + silenceRetention
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(silence)
+	if err != nil {
+		return fmt.Errorf("failed to encode silence %s: %v", silence.ID, err)
+	}
+	if err := a.store.Set(ctx, apiSilenceKey(silence.ID), string(data), ttl); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.cachedAt = time.Time{}
+	a.mu.Unlock()
+	return nil
+}
+
+// Silences returns the API silences, including those expired within silenceRetention, sorted
+// by when they end
+func (d *Dispatcher) Silences(ctx context.Context) ([]APISilence, error) {
+	silences, err := d.apiSilences.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(silences, func(i, j int) bool { return silences[i].EndsAt.Before(silences[j].EndsAt) })
+	return silences, nil
+}
+
+// PutSilence creates an API silence, or replaces the one with its ID, returning its ID. It
+// starts now when StartsAt is unset.
+func (d *Dispatcher) PutSilence(ctx context.Context, silence APISilence) (string, error) {
+	if err := silence.compile(); err != nil {
+		return "", &InvalidSilenceError{Reason: err.Error()}
+	}
+
+	now := time.Now()
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	switch {
+	case !silence.EndsAt.After(silence.StartsAt):
+		return "", &InvalidSilenceError{Reason: "endsAt is not after startsAt"}
+	case !silence.EndsAt.After(now):
+		return "", &InvalidSilenceError{Reason: "endsAt is in the past"}
+	}
+	silence.UpdatedAt = now
+
+	if silence.ID == "" {
+		silence.ID = newSilenceID()
+	} else {
+		existing, ok, err := d.apiSilences.get(ctx, silence.ID)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrSilenceNotFound
+		}
+		if existing.State(now) == SilenceExpired {
+			return "", &InvalidSilenceError{Reason: "silence " + silence.ID + " has expired"}
+		}
+	}
+	if err := d.apiSilences.save(ctx, silence, now); err != nil {
+		return "", err
+	}
+	log.Printf("Silence %s by %s saved until %s", silence.ID, silence.CreatedBy, silence.EndsAt.Format(time.RFC3339))
+	return silence.ID, nil
+}
+
+// ExpireSilence ends an API silence now
+func (d *Dispatcher) ExpireSilence(ctx context.Context, id string) error {
+	silence, ok, err := d.apiSilences.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSilenceNotFound
+	}
+	now := time.Now()
+	if silence.State(now) == SilenceExpired {
+		return &InvalidSilenceError{Reason: "silence " + id + " has already expired"}
+	}
+	if silence.StartsAt.After(now) {
+		silence.StartsAt = now
+	}
+	silence.EndsAt = now
+	silence.UpdatedAt = now
+	if err := d.apiSilences.save(ctx, silence, now); err != nil {
+		return err
+	}
+	log.Printf("Silence %s expired", id)
+	return nil
+}
+
+// newSilenceID returns a random UUID, as Alertmanager's silence IDs are
+func newSilenceID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,43 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"alert-dispatcher/internal/state"
+)
+
+func TestAPISilencesSkipInvalidNegatedRegex(t *testing.T) {
+	ctx := context.Background()
+	store := state.NewMemoryStore()
+	isEqual := false
+	now := time.Now()
+	silence := APISilence{
+		ID:       "invalid",
+		Matchers: []Matcher{{Name: "alertname", Value: "(unclosed", IsRegex: true, IsEqual: &isEqual}},
+		StartsAt: now.Add(-time.Minute),
+		EndsAt:   now.Add(time.Hour),
+	}
+	data, err := json.Marshal(silence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(ctx, apiSilenceKey(silence.ID), string(data), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	silences, err := newAPISilences(store).load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(silences) != 0 {
+		t.Fatalf("loaded %d silences, want the invalid one skipped", len(silences))
+	}
+
+	// Even if one slipped through, an uncompiled regex matcher must not match
+	if silence.matches(map[string]string{"alertname": "HighCPU"}, now) {
+		t.Fatal("uncompiled negated regex silence matched an alert")
+	}
+}
@@ -0,0 +1,80 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// backlogAlertName names the alert raised when the dispatcher falls behind its queue
+const backlogAlertName = "SQS backlog"
+
+// CheckBacklog raises an alert when the queue holds more messages, or older ones, than the
+// SQS backlog limits allow, and resolves it once both are back under them. Only changes are
+// dispatched, to the ops channel or else the P1 channel.
+func (d *Dispatcher) CheckBacklog(ctx context.Context, queue string, visible int, oldest time.Duration) {
+	limits := d.config.SQSBacklog
+	tooMany := limits.MaxMessages > 0 && visible > limits.MaxMessages
+	tooOld := limits.MaxAge > 0 && oldest > limits.MaxAge
+	firing := tooMany || tooOld
+
+	d.backlogMu.Lock()
+	changed := firing != d.backlogFiring
+	d.backlogFiring = firing
+	d.backlogMu.Unlock()
+	if !changed {
+		return
+	}
+
+	channel := d.config.OpsChannel
+	if channel == "" {
+		channel = d.config.SlackChannels["P1"]
+	}
+	state, status := "ALARM", alert.StatusFiring
+	if !firing {
+		state, status = "OK", alert.StatusResolved
+	}
+
+	message := fmt.Sprintf("%s *Dispatcher Alert: %s*\n• *State:* `%s`\n• *Queue:* `%s`", backlogEmoji(firing), backlogAlertName, state, queue)
+	message += fmt.Sprintf("\n• *Waiting messages:* *%d*%s", visible, backlogLimit(tooMany, limits.MaxMessages > 0, fmt.Sprint(limits.MaxMessages)))
+	message += fmt.Sprintf("\n• *Oldest message:* *%s*%s", oldest.Round(time.Second), backlogLimit(tooOld, limits.MaxAge > 0, limits.MaxAge.String()))
+
+	backlogAlert := &alert.Alert{
+		Source:      alert.SourceDispatcher,
+		Name:        backlogAlertName,
+		Severity:    "P1",
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      map[string]string{"queue": queue},
+		Annotations: map[string]string{alert.AnnotationValue: fmt.Sprint(visible)},
+		ReceivedAt:  time.Now(),
+		Message:     message,
+		Summary:     fmt.Sprintf("%s *%s* `%s` %d messages waiting, oldest %s", backlogEmoji(firing), backlogAlertName, state, visible, oldest.Round(time.Second)),
+	}
+	if err := d.Dispatch(ctx, backlogAlert, ""); err != nil {
+		log.Printf("Failed to send SQS backlog alert: %v", err)
+	}
+}
+
+func backlogEmoji(firing bool) string {
+	if firing {
+		return "🚨"
+	}
+	return "✅"
+}
+
+// backlogLimit describes a limit next to the value it applies to
+func backlogLimit(exceeded, set bool, limit string) string {
+	switch {
+	case exceeded:
+		return fmt.Sprintf(" (over the limit of %s)", limit)
+	case set:
+		return fmt.Sprintf(" (limit %s)", limit)
+	default:
+		return ""
+	}
+}
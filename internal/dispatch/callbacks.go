@@ -0,0 +1,105 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+	"alert-dispatcher/internal/metrics"
+)
+
+var callbackErrors = metrics.NewCounter("alert_dispatcher_callback_errors_total",
+	"Failed callbacks to configured webhooks' callback URLs, by webhook.", "webhook")
+
+// callbackTimeout bounds each callback, which runs after the action it reports has been answered
+const callbackTimeout = 10 * time.Second
+
+var callbackClient = httpclient.New(callbackTimeout)
+
+// Callback events
+const (
+	CallbackAcknowledged = "acknowledged"
+	CallbackDismissed    = "dismissed"
+	CallbackResolved     = "resolved"
+)
+
+// Callback is the body POSTed to a webhook's callback_url when one of its alerts is
+// acknowledged, dismissed or resolved
+type Callback struct {
+	Event   string            `json:"event"`
+	AlertID string            `json:"alert_id"` // for resolutions, the ID of the resolved alert's own request
+	Alert   string            `json:"alert"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Webhook string            `json:"webhook"`
+	User    string            `json:"user,omitempty"` // who acknowledged or dismissed it
+	Via     string            `json:"via,omitempty"`  // button, digest, command, telegram or mattermost
+	Time    time.Time         `json:"time"`
+}
+
+// alertWebhook is the configured webhook an alert was sent to, if any
+func alertWebhook(alertMsg *alert.Alert) string {
+	webhook, _ := alertMsg.Extensions["webhook"].(string)
+	return webhook
+}
+
+// callbackAction is the callback event of an acknowledge or dismiss action
+func callbackAction(action string) string {
+	if action == "dismiss" {
+		return CallbackDismissed
+	}
+	return CallbackAcknowledged
+}
+
+// callBack tells the webhook's callback URL, if it has one, about an alert of its, in the
+// background so the action being reported isn't held up. The body is signed with the webhook's
+// secret in the X-Webhook-Signature header, "sha256=" and the HMAC in hex.
+func (d *Dispatcher) callBack(callback Callback) {
+	webhook, ok := d.config.Webhooks[callback.Webhook]
+	if callback.Webhook == "" || !ok || webhook.CallbackURL == "" {
+		return
+	}
+	callback.Time = time.Now().UTC()
+
+	go func() {
+		if err := postCallback(webhook.CallbackURL, webhook.Secret, callback); err != nil {
+			log.Printf("Failed to call back webhook %s about %s being %s: %v", callback.Webhook, callback.Alert, callback.Event, err)
+			callbackErrors.Inc(callback.Webhook)
+		}
+	}()
+}
+
+func postCallback(url, secret string, callback Callback) error {
+	body, err := json.Marshal(callback)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := callbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback URL returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package dispatch
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/notifier"
+)
+
+// Labels naming the EC2 instance an alert is about: the CloudWatch dimension, then the usual
+// Prometheus EC2 service discovery one
+var instanceLabels = []string{"InstanceId", "instance_id"}
+
+// alertAccount is the AWS account of a CloudWatch alert's alarm, or else its account_id label
+func alertAccount(alertMsg *alert.Alert) string {
+	arn, _ := alertMsg.Extensions["alarm_arn"].(string)
+	if parts := strings.SplitN(arn, ":", 7); len(parts) == 7 {
+		return parts[4]
+	}
+	return alertMsg.Labels["account_id"]
+}
+
+// consoleLinks returns buttons opening the EC2 instance a firing alert is about in the EC2
+// console and in a Session Manager session, in the alarm's region, signed in to its account
+// when aws_console is configured. Alerts about no instance, or in no known region, get none.
+func (d *Dispatcher) consoleLinks(alertMsg *alert.Alert) []notifier.LinkButton {
+	if alertMsg.IsResolved() {
+		return nil
+	}
+	var instance string
+	for _, label := range instanceLabels {
+		if id := alertMsg.Labels[label]; strings.HasPrefix(id, "i-") {
+			instance = id
+			break
+		}
+	}
+	region := alertRegion(alertMsg)
+	if instance == "" || region == "" {
+		return nil
+	}
+
+	host := fmt.Sprintf("https://%s.console.aws.amazon.com", region)
+	ec2 := fmt.Sprintf("%s/ec2/home?region=%s#InstanceDetails:instanceId=%s", host, region, instance)
+	ssm := fmt.Sprintf("%s/systems-manager/session-manager/%s?region=%s", host, url.PathEscape(instance), region)
+	account := alertAccount(alertMsg)
+	return []notifier.LinkButton{
+		{Name: "ec2_console", Label: "🖥️ EC2 console", URL: d.consoleURL(account, ec2)},
+		{Name: "ssm_session", Label: "💻 SSM session", URL: d.consoleURL(account, ssm)},
+	}
+}
+
+// consoleURL signs in to the account through the IAM Identity Center access portal before
+// opening the console page, when aws_console configures a role for the account
+func (d *Dispatcher) consoleURL(account, destination string) string {
+	console := d.config.AWSConsole
+	role := console.SSORoleFor(account)
+	if console.SSOStartURL == "" || role == "" || account == "" {
+		return destination
+	}
+	query := url.Values{"account_id": {account}, "role_name": {role}, "destination": {destination}}
+	return strings.TrimRight(console.SSOStartURL, "/") + "/#/console?" + query.Encode()
+}
@@ -0,0 +1,406 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/archive"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/errs"
+	"alert-dispatcher/internal/render"
+	"alert-dispatcher/internal/sink"
+	"alert-dispatcher/internal/state"
+	"alert-dispatcher/notifier"
+)
+
+// How long dispatched alerts stay expandable from their buttons
+const archiveTTL = 24 * time.Hour
+
+// Thread keys are namespaced so route thread key values can't collide with the daily rollup
+const (
+	rollupThreadKey   = "rollup"
+	routeThreadPrefix = "key:"
+)
+
+// Dispatcher delivers adapted alerts to Slack using the options of their destination route
+type Dispatcher struct {
+	config  *config.Config
+	store   state.Store
+	archive *archive.Archive
+	threads *threadTracker
+	repeats *repeatTracker
+	open    *openAlerts
+	active  *activeIndex
+
+	apiSilences *apiSilences
+	silenceSync *silenceSync
+
+	alarmActions AlarmActions // nil unless cloudwatch_snooze is configured
+
+	scalingGroups ScalingGroups // nil unless a route has scale_group quick actions
+	deployments   Deployments   // nil unless a route has deployment quick actions
+	logsInsights  LogsInsights  // nil unless a route has a cloudwatch log query
+	loki          LokiQuerier   // nil unless a route has a loki log query
+	graphs        GraphRenderer // nil unless GRAFANA_URL is set
+
+	dropRules []dropRule
+	feedback  *feedbackTally
+	rotations []*rotation
+	sinks     []*sink.Batcher
+	pager     *pager
+	mailer    *mailer
+	escalator *escalator
+	telegram  *telegram
+	whatsApp  *whatsApp
+	streams   []stream
+	notifiers *notifierCache
+	throttler *throttler
+	sampler   *sampler
+
+	reloadMu sync.RWMutex          // guards what Reload replaces
+	styles   map[string]routeStyle // custom templates and legends, by channel
+	silences []silence
+
+	lintMu   sync.Mutex // serializes lint summaries
+	lastLint string     // the last lint summary, to post only changes
+
+	backlogMu     sync.Mutex
+	backlogFiring bool // whether the SQS backlog alert is firing
+
+	heartbeats *heartbeats   // when the watched inputs last received alerts
+	quotas     *sourceQuotas // the token buckets of the source quotas
+
+	rollupMu sync.Mutex // serializes creation of daily rollup parents
+}
+
+// NewDispatcher creates a dispatcher keeping its threads and archived alerts in store
+func NewDispatcher(cfg *config.Config, store state.Store) *Dispatcher {
+	d := &Dispatcher{
+		config:  cfg,
+		store:   store,
+		archive: archive.NewArchive(store, archiveTTL),
+		threads: newThreadTracker(store),
+		repeats: newRepeatTracker(store),
+		open:    newOpenAlerts(store),
+		active:  newActiveIndex(store),
+
+		apiSilences: newAPISilences(store),
+		silenceSync: newSilenceSync(cfg.SilenceSync),
+
+		dropRules: namedDropRules(cfg.DropRules),
+		feedback:  newFeedbackTally(),
+		rotations: newRotations(cfg.Teams),
+		sinks:     newSinks(cfg),
+		pager:     newPager(cfg.Opsgenie),
+		escalator: newEscalator(cfg.Notifiers.Twilio),
+		telegram:  newTelegram(cfg.Notifiers.Telegram),
+		whatsApp:  newWhatsApp(cfg.Notifiers.WhatsApp),
+		notifiers: newNotifierCache(),
+
+		heartbeats: newHeartbeats(),
+		quotas:     newSourceQuotas(),
+	}
+	d.throttler = newThrottler(d.postThrottleSummary)
+	d.sampler = newSampler(d.postSampleSummary)
+	d.Reload()
+	return d
+}
+
+// Reload picks up the templates, routes and silences changed since the dispatcher was created,
+// such as those read from custom resources, and lints the config they make up
+func (d *Dispatcher) Reload() {
+	styles := newRouteStyles(d.config)
+	silences := compileSilences(d.config.Silences())
+
+	d.reloadMu.Lock()
+	d.styles = styles
+	d.silences = silences
+	d.reloadMu.Unlock()
+	// Targets may point somewhere else now
+	d.notifiers.reset()
+
+	d.lintConfig()
+}
+
+// Dispatch renders the alert with its route's layout and format and posts it to the alert channel
+func (d *Dispatcher) Dispatch(ctx context.Context, alertMsg *alert.Alert, alertID string) error {
+	if alertID == "" {
+		alertID = fmt.Sprintf("alert_%d", time.Now().UnixNano())
+	}
+
+	receipt := newReceipt(alertMsg, alertID)
+	delivered, err := d.deliver(ctx, alertMsg, alertID, receipt)
+
+	outcome := sink.OutcomeDelivered
+	if err != nil {
+		outcome = sink.OutcomeFailed
+	} else if !delivered {
+		outcome = sink.OutcomeDropped
+	}
+	d.recordOutcome(alertMsg, alertID, outcome, err)
+	d.saveReceipt(ctx, alertMsg, receipt, outcome, err)
+	if delivered && alertMsg.IsResolved() {
+		d.callBack(Callback{Event: CallbackResolved, AlertID: alertID, Alert: alertMsg.Name, Labels: alertMsg.Labels, Webhook: receipt.Webhook})
+	}
+	return err
+}
+
+// deliver applies drop rules and the route's NoData policy, sampling and rate limits and posts
+// the alert, returning false when it was dropped or held back instead. Where it was posted, or
+// why it wasn't, goes on its receipt.
+func (d *Dispatcher) deliver(ctx context.Context, alertMsg *alert.Alert, alertID string, receipt *Receipt) (delivered bool, err error) {
+	errs.EnterStage(ctx, errs.StageEnrich)
+	v, err := d.decide(ctx, alertMsg, alertID, false)
+	if err != nil || v.held {
+		receipt.Reason = v.reason
+		return false, err
+	}
+	route := v.route
+
+	var repeat *firing
+	if alertMsg.IsResolved() {
+		d.repeats.Clear(ctx, alertMsg)
+	} else {
+		var claimed bool
+		if repeat, claimed = d.repeatOf(ctx, alertMsg, route); claimed {
+			// A first firing that fails to post must not turn its retry into a repeat
+			defer func() {
+				if err != nil {
+					d.repeats.Clear(ctx, alertMsg)
+				}
+			}()
+		}
+	}
+
+	errs.EnterStage(ctx, errs.StageDeliver)
+	d.pager.page(ctx, alertMsg, alertID)
+	d.mailer.send(ctx, alertMsg, route)
+	d.escalator.escalate(ctx, alertMsg, repeat)
+	d.whatsApp.send(ctx, alertMsg)
+	d.telegram.send(ctx, alertMsg, alertID)
+	d.fanOut(ctx, alertMsg, alertID, d.config.TargetsFor(alertMsg.Name, alertMsg.Severity, alertMsg.Channel, route))
+	d.publish(ctx, alertMsg, alertID)
+
+	if repeat != nil {
+		// The alert has been paged and sent everywhere else, so a retry would do that again
+		if err := d.postRepeat(ctx, alertMsg, *repeat); err != nil {
+			log.Printf("Failed to post repeated firing of %s: %v", alertMsg.Name, err)
+			receipt.Reason = "repeat update failed to post"
+			return true, nil
+		}
+		receipt.posted("", repeat.ThreadTS)
+		return true, nil
+	}
+
+	channelNotifier := d.slackNotifier(alertMsg.Channel).
+		WithQuickActions(d.quickActions(alertMsg, route)).
+		WithLinks(d.consoleLinks(alertMsg)).
+		WithFetchLogs(d.fetchLogsEnabled(alertMsg, route)).
+		WithRefreshGraph(d.refreshGraphEnabled(alertMsg))
+
+	// Keep the full message and source payload so buttons can expand them in-thread
+	record := archive.Record{Alert: alertMsg, Message: alertMsg.Message, Payload: alertMsg.Raw}
+	if err := d.archive.Put(ctx, alertID, record); err != nil {
+		log.Printf("Failed to archive alert %s, its buttons won't expand: %v", alertID, err)
+	}
+
+	// Low-priority noise goes under the channel's rollup for the day
+	if route.DailyRollup && alertMsg.Severity == "P2" {
+		parentTS, err := d.rollupParent(ctx, alertMsg.Channel)
+		if err != nil {
+			return false, err
+		}
+		channelNotifier.InThread(parentTS)
+		if err := d.render(ctx, channelNotifier, alertMsg, alertID, route); err != nil {
+			return false, err
+		}
+		d.delivered(ctx, alertMsg, alertID, parentTS)
+		receipt.posted(channelNotifier.PostedTimestamp(), parentTS)
+		d.firstFiring(ctx, alertMsg, route, parentTS)
+		return true, nil
+	}
+
+	// Alerts sharing a thread key value are replies under the first one posted today
+	var threadKey string
+	if route.ThreadKey != "" {
+		if value := alertMsg.Lookup(route.ThreadKey); value != "" {
+			threadKey = routeThreadPrefix + value
+		}
+	}
+	var threadTS string
+	if threadKey != "" {
+		if parentTS, ok := d.threads.Get(ctx, alertMsg.Channel, threadKey); ok {
+			channelNotifier.InThread(parentTS)
+			threadTS = parentTS
+		}
+	}
+
+	if err := d.render(ctx, channelNotifier, alertMsg, alertID, route); err != nil {
+		return false, err
+	}
+	d.delivered(ctx, alertMsg, alertID, "")
+	receipt.posted(channelNotifier.PostedTimestamp(), threadTS)
+	if threadTS == "" {
+		threadTS = channelNotifier.PostedTimestamp()
+		// The alert starts the key's thread, unless another replica's alert started it meanwhile
+		if threadKey != "" {
+			d.threads.Claim(ctx, alertMsg.Channel, threadKey, threadTS)
+		}
+	}
+	d.firstFiring(ctx, alertMsg, route, threadTS)
+	return true, nil
+}
+
+// delivered records an alert posted to Slack, under the digest at threadTS if any, for bulk
+// acknowledgement, the noise report and on-call handoffs
+func (d *Dispatcher) delivered(ctx context.Context, alertMsg *alert.Alert, alertID, threadTS string) {
+	d.open.track(ctx, alertMsg, alertID, threadTS)
+	d.feedback.fired(alertMsg)
+	d.recordShift(ctx, alertMsg, alertID)
+}
+
+// Archive returns the store of dispatched alerts, for archiving them to long-term storage
+func (d *Dispatcher) Archive() *archive.Archive {
+	return d.archive
+}
+
+// rollupParent returns today's rollup message for the channel, posting it on first use
+func (d *Dispatcher) rollupParent(ctx context.Context, channel string) (string, error) {
+	d.rollupMu.Lock()
+	defer d.rollupMu.Unlock()
+
+	if parentTS, ok := d.threads.Get(ctx, channel, rollupThreadKey); ok {
+		return parentTS, nil
+	}
+
+	parentNotifier := d.slackNotifier(channel)
+	today := time.Now().UTC().Format("Monday, 2 Jan 2006")
+	if err := parentNotifier.NotifyDigest(ctx, fmt.Sprintf("📅 *P2 alerts for %s*\n_Low-priority alerts are posted in this thread._", today)); err != nil {
+		return "", fmt.Errorf("failed to post daily rollup for %s: %v", channel, err)
+	}
+
+	// Another replica may have posted today's rollup at the same moment; its replies go under
+	// whichever was recorded first
+	return d.threads.Claim(ctx, channel, rollupThreadKey, parentNotifier.PostedTimestamp()), nil
+}
+
+// render posts the alert through the notifier in the route's format and layout
+func (d *Dispatcher) render(ctx context.Context, channelNotifier *notifier.SlackNotifier, alertMsg *alert.Alert, alertID string, route config.RouteConfig) error {
+	var color string
+	if route.Layout == config.LayoutAttachments {
+		color = notifier.SeverityColor(alertMsg.Severity, alertMsg.IsResolved())
+	}
+
+	if route.Format == config.FormatRaw {
+		return d.notifyRendered(ctx, channelNotifier, alertMsg, render.NameJSON)
+	}
+
+	if route.Renderer != render.NameSlack {
+		return d.notifyRendered(ctx, channelNotifier, alertMsg, route.Renderer)
+	}
+
+	if route.Format == config.FormatCompact {
+		return channelNotifier.NotifyCompact(ctx, alertMsg.Summary, alertID, color)
+	}
+
+	message := d.styledMessage(alertMsg)
+	if color != "" {
+		return channelNotifier.NotifyAttachment(ctx, message, alertID, color)
+	}
+	return channelNotifier.NotifyWithButtons(ctx, message, alertID)
+}
+
+// styledMessage renders the alert with its route's template, if it has one, and appends the
+// route's emoji legend. Alerts whose template fails to execute keep the adapter's layout.
+func (d *Dispatcher) styledMessage(alertMsg *alert.Alert) string {
+	d.reloadMu.RLock()
+	style, ok := d.styles[alertMsg.Channel]
+	d.reloadMu.RUnlock()
+	if !ok {
+		return alertMsg.Message
+	}
+
+	message := alertMsg.Message
+	if style.renderer != nil {
+		text, err := style.renderer.Render(alertMsg)
+		if err != nil {
+			log.Printf("Failed to render %s for %s, using the default layout: %v", alertMsg.Name, alertMsg.Channel, err)
+		} else {
+			message = text
+		}
+	}
+	if style.legend != "" {
+		message += "\n\n_" + style.legend + "_"
+	}
+	return message
+}
+
+// notifyRendered posts the alert as text produced by the named renderer. Output Slack can't
+// format itself, such as JSON or HTML, is posted in a code block.
+func (d *Dispatcher) notifyRendered(ctx context.Context, channelNotifier *notifier.SlackNotifier, alertMsg *alert.Alert, name string) error {
+	renderer, err := render.ForName(name)
+	if err != nil {
+		return err
+	}
+
+	text, err := renderer.Render(alertMsg)
+	if err != nil {
+		return fmt.Errorf("failed to render alert with %s: %v", name, err)
+	}
+
+	switch renderer.ContentType() {
+	case render.ContentTypeJSON, render.ContentTypeHTML:
+		text = fmt.Sprintf("```\n%s\n```", text)
+	}
+	return channelNotifier.NotifyText(ctx, text)
+}
+
+// PostDetails replies in-thread with the full message of a compact alert
+func (d *Dispatcher) PostDetails(ctx context.Context, channelID, threadTS, alertID string) error {
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return fmt.Errorf("failed to look up alert %s: %v", alertID, err)
+	}
+	if !ok {
+		return fmt.Errorf("no details found for alert %s", alertID)
+	}
+
+	channelNotifier := d.slackNotifier(channelID)
+	return channelNotifier.NotifyThreadReply(ctx, record.Message, alertID, threadTS)
+}
+
+// PostRawPayload uploads the archived source payload of an alert as a snippet in its thread
+func (d *Dispatcher) PostRawPayload(ctx context.Context, channelID, threadTS, alertID string) error {
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return fmt.Errorf("failed to look up alert %s: %v", alertID, err)
+	}
+	if !ok || record.Payload == "" {
+		return fmt.Errorf("no raw payload found for alert %s", alertID)
+	}
+
+	payload := record.Payload
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(payload), "", "  "); err == nil {
+		payload = indented.String()
+	}
+
+	channelNotifier := d.slackNotifier(channelID)
+	return channelNotifier.NotifyThreadSnippet(ctx, payload, alertID+".json", threadTS)
+}
+
+// slackNotifier returns a notifier for the channel bounded by the configured Slack timeout,
+// sharing the channel's cached client
+func (d *Dispatcher) slackNotifier(channel string) *notifier.SlackNotifier {
+	n, _ := d.notifiers.get("bot|"+channel, func() (notifier.AlertNotifier, error) {
+		return notifier.NewSlackNotifier(d.config.SlackBotToken, channel, d.config.SlackTimeout).WithAPIURL(d.config.SlackAPIURL).
+			WithStandby(d.config.SlackStandbyBotToken, d.config.SlackStandbyAPIURL, d.config.SlackFailoverAfter), nil
+	})
+	return n.(*notifier.SlackNotifier)
+}
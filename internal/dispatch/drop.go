@@ -0,0 +1,59 @@
+package dispatch
+
+import (
+	"fmt"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+)
+
+var droppedAlerts = metrics.NewCounter("alert_dispatcher_dropped_alerts_total",
+	"Alerts discarded by drop rules.", "rule")
+
+// dropRule is a config.DropRule named for its counter; its name regex was compiled when the
+// config was loaded
+type dropRule struct {
+	config.DropRule
+}
+
+func namedDropRules(rules []config.DropRule) []dropRule {
+	named := make([]dropRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule_%d", i)
+		}
+		named = append(named, dropRule{DropRule: rule})
+	}
+	return named
+}
+
+func (r dropRule) matches(alertMsg *alert.Alert) bool {
+	if r.Source != "" && !strings.EqualFold(r.Source, alertMsg.Source) {
+		return false
+	}
+	if r.State != "" && !strings.EqualFold(r.State, alertMsg.State) {
+		return false
+	}
+	if !r.MatchesName(alertMsg.Name) {
+		return false
+	}
+	for k, v := range r.Labels {
+		if alertMsg.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// shouldDrop returns the first drop rule matching the alert; drops are counted by whoever
+// carries them out, so plans don't
+func (d *Dispatcher) shouldDrop(alertMsg *alert.Alert) (string, bool) {
+	for _, rule := range d.dropRules {
+		if rule.matches(alertMsg) {
+			return rule.Name, true
+		}
+	}
+	return "", false
+}
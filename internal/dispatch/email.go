@@ -0,0 +1,53 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// mailer emails alerts of the configured priorities in addition to posting them to Slack
+type mailer struct {
+	notifier   *notifier.EmailNotifier
+	priorities map[string]bool
+	config     config.EmailConfig
+}
+
+// UseEmailSender enables email delivery through sender, configured by the email config.
+// Backends are created by the caller since SES needs AWS credentials.
+func (d *Dispatcher) UseEmailSender(sender notifier.EmailSender) {
+	cfg := d.config.Email
+	log.Printf("Emailing %v alerts through %s", cfg.Priorities, cfg.Backend)
+
+	m := &mailer{
+		notifier:   notifier.NewEmailNotifier(sender, cfg.From, cfg.To),
+		priorities: make(map[string]bool),
+		config:     cfg,
+	}
+	for _, priority := range cfg.Priorities {
+		m.priorities[priority] = true
+	}
+	d.mailer = m
+}
+
+// send emails the alert to the route's recipients or the default ones. Like paging, failures
+// are logged and counted rather than failing the Slack delivery.
+func (m *mailer) send(ctx context.Context, alertMsg *alert.Alert, route config.RouteConfig) {
+	if m == nil || !m.priorities[alertMsg.Severity] {
+		return
+	}
+	if len(route.EmailTo) == 0 && len(m.config.To) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+
+	if err := m.notifier.NotifyAlertTo(ctx, alertMsg, route.EmailTo); err != nil {
+		log.Printf("Failed to email %s: %v", alertMsg.Name, err)
+		notifierErrors.Inc("email")
+	}
+}
@@ -0,0 +1,60 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// escalator texts and calls a phone list for critical alerts, so a Slack outage or a missed
+// message doesn't mean a missed page
+type escalator struct {
+	twilio     *notifier.TwilioNotifier
+	priorities map[string]bool
+}
+
+func newEscalator(cfg *config.TwilioConfig) *escalator {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.AccountSID == "" || cfg.AuthToken == "" {
+		log.Printf("Twilio notifier configured without TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN, disabling it")
+		return nil
+	}
+
+	log.Printf("Escalating %v alerts to %d phone numbers through Twilio", cfg.Priorities, len(cfg.To))
+	e := &escalator{
+		twilio:     notifier.NewTwilioNotifier(cfg.AccountSID, cfg.AuthToken, cfg.From, cfg.To, cfg.Voice, cfg.Timeout),
+		priorities: make(map[string]bool),
+	}
+	for _, priority := range cfg.Priorities {
+		e.priorities[priority] = true
+	}
+	return e
+}
+
+// escalate texts (and calls) about firing alerts; resolutions are left to Slack. Repeats say
+// how long the alert has gone unhandled and how many times it has been escalated.
+func (e *escalator) escalate(ctx context.Context, alertMsg *alert.Alert, repeat *firing) {
+	if e == nil || !e.priorities[alertMsg.Severity] || alertMsg.Status != alert.StatusFiring {
+		return
+	}
+
+	var err error
+	if repeat != nil {
+		age := ""
+		if since := firingFor(alertMsg, *repeat); since > 0 {
+			age = formatAge(since)
+		}
+		err = e.twilio.NotifyReminder(ctx, alertMsg, age, repeat.Count)
+	} else {
+		err = e.twilio.NotifyAlert(ctx, alertMsg, "")
+	}
+	if err != nil {
+		log.Printf("Failed to escalate %s through Twilio: %v", alertMsg.Name, err)
+		notifierErrors.Inc("twilio")
+	}
+}
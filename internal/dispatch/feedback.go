@@ -0,0 +1,119 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/audit"
+	"alert-dispatcher/internal/metrics"
+)
+
+var alertFeedback = metrics.NewCounter("alert_dispatcher_alert_feedback_total",
+	"Answers to \"was this alert actionable?\" by alert rule.", "source", "alertname", "actionable")
+
+// NoiseScore summarizes the feedback on one alert rule. Score is the share of votes saying the
+// alert wasn't actionable, so rules near 1 are candidates for tuning.
+type NoiseScore struct {
+	Source        string  `json:"source"`
+	Name          string  `json:"name"`
+	Fired         int     `json:"fired"`
+	Actionable    int     `json:"actionable"`
+	NotActionable int     `json:"not_actionable"`
+	Score         float64 `json:"score"`
+}
+
+// feedbackTally counts firings and votes per alert rule since the replica started; the feedback
+// counter on /metrics keeps the long-term history
+type feedbackTally struct {
+	mu    sync.Mutex
+	rules map[string]*NoiseScore // by source and name
+}
+
+func newFeedbackTally() *feedbackTally {
+	return &feedbackTally{rules: make(map[string]*NoiseScore)}
+}
+
+func (f *feedbackTally) rule(source, name string) *NoiseScore {
+	key := source + "|" + name
+	score, ok := f.rules[key]
+	if !ok {
+		score = &NoiseScore{Source: source, Name: name}
+		f.rules[key] = score
+	}
+	return score
+}
+
+func (f *feedbackTally) fired(alertMsg *alert.Alert) {
+	if alertMsg.Status != alert.StatusFiring {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rule(alertMsg.Source, alertMsg.Name).Fired++
+}
+
+func (f *feedbackTally) vote(source, name string, actionable bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	score := f.rule(source, name)
+	if actionable {
+		score.Actionable++
+	} else {
+		score.NotActionable++
+	}
+	score.Score = float64(score.NotActionable) / float64(score.Actionable+score.NotActionable)
+}
+
+// RecordFeedback counts a person's answer to whether an alert was actionable, once per person
+// and alert. It reports false when they had already answered.
+func (d *Dispatcher) RecordFeedback(ctx context.Context, alertID, userID, user string, actionable bool) (bool, error) {
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up alert %s: %v", alertID, err)
+	}
+	if !ok || record.Alert == nil {
+		return false, fmt.Errorf("alert %s is no longer available", alertID)
+	}
+
+	first, err := d.store.SetNX(ctx, fmt.Sprintf("feedback:%s|%s", alertID, userID), fmt.Sprint(actionable), archiveTTL)
+	if err != nil {
+		return false, fmt.Errorf("failed to record feedback on %s: %v", alertID, err)
+	}
+	if !first {
+		return false, nil
+	}
+
+	source, name := record.Alert.Source, record.Alert.Name
+	d.feedback.vote(source, name, actionable)
+	alertFeedback.Inc(source, name, fmt.Sprint(actionable))
+
+	action := "not_actionable"
+	if actionable {
+		action = "actionable"
+	}
+	audit.Record(audit.Entry{Action: action, AlertID: alertID, AlertName: name, User: user, Via: audit.ViaButton})
+	return true, nil
+}
+
+// NoiseReport returns the feedback per alert rule, noisiest first
+func (d *Dispatcher) NoiseReport() []NoiseScore {
+	d.feedback.mu.Lock()
+	defer d.feedback.mu.Unlock()
+
+	report := make([]NoiseScore, 0, len(d.feedback.rules))
+	for _, score := range d.feedback.rules {
+		report = append(report, *score)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Score != report[j].Score {
+			return report[i].Score > report[j].Score
+		}
+		return report[i].Fired > report[j].Fired
+	})
+	return report
+}
@@ -0,0 +1,84 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// GraphRenderer renders the Grafana panels of alerts as images; *grafana.Renderer implements it
+type GraphRenderer interface {
+	CanRender(panelURL string) bool
+	RenderPanel(ctx context.Context, panelURL string, from, to time.Time) ([]byte, error)
+}
+
+// UseGraphRenderer enables the Refresh graph button on alerts with a graph of a panel it can
+// render
+func (d *Dispatcher) UseGraphRenderer(renderer GraphRenderer) {
+	d.graphs = renderer
+}
+
+// Bounds on a refreshed graph's window, which starts shortly before the alert
+const (
+	graphLeadIn    = 15 * time.Minute
+	minGraphWindow = time.Hour
+	maxGraphWindow = 24 * time.Hour
+)
+
+// refreshGraphEnabled reports whether the alert came with a graph of a panel the renderer can
+// render again, so it gets a Refresh graph button
+func (d *Dispatcher) refreshGraphEnabled(alertMsg *alert.Alert) bool {
+	if d.graphs == nil || alertMsg.URLs[alert.URLImage] == "" {
+		return false
+	}
+	panel := alertMsg.URLs[alert.URLPanel]
+	return panel != "" && d.graphs.CanRender(panel)
+}
+
+// graphWindow is the window a refreshed graph shows: from shortly before the alert started
+// until now, at least an hour and at most a day
+func graphWindow(alertMsg *alert.Alert, now time.Time) (time.Time, time.Time) {
+	started := alertMsg.ReceivedAt
+	if alertMsg.StartsAt != nil {
+		started = *alertMsg.StartsAt
+	}
+	from := started.Add(-graphLeadIn)
+	if now.Sub(from) < minGraphWindow {
+		from = now.Add(-minGraphWindow)
+	}
+	if now.Sub(from) > maxGraphWindow {
+		from = now.Add(-maxGraphWindow)
+	}
+	return from, now
+}
+
+// RefreshGraph renders the alert's panel up to now and uploads it in the thread, so responders
+// can watch the metric recover without opening Grafana
+func (d *Dispatcher) RefreshGraph(ctx context.Context, channelID, threadTS, alertID, userName string) error {
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return fmt.Errorf("failed to look up alert %s: %v", alertID, err)
+	}
+	if !ok || record.Alert == nil {
+		return fmt.Errorf("alert %s is no longer archived", alertID)
+	}
+	alertMsg := record.Alert
+	if !d.refreshGraphEnabled(alertMsg) {
+		return fmt.Errorf("the graph of %s can't be rendered", alertMsg.Name)
+	}
+
+	from, to := graphWindow(alertMsg, time.Now())
+	renderCtx, cancel := context.WithTimeout(ctx, d.config.GraphRenderTimeout)
+	defer cancel()
+	image, err := d.graphs.RenderPanel(renderCtx, alertMsg.URLs[alert.URLPanel], from, to)
+	if err != nil {
+		return err
+	}
+	log.Printf("Refreshed graph of %s for %s", alertMsg.Name, userName)
+
+	comment := fmt.Sprintf("📈 *%s* as of %s UTC, refreshed by %s", alertMsg.Name, to.UTC().Format("15:04"), userName)
+	return d.slackNotifier(channelID).NotifyThreadImage(ctx, image, alertID+"-graph.png", comment, threadTS)
+}
@@ -0,0 +1,341 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+)
+
+// Rules listed in a handoff summary; the rest are counted
+const handoffTopRules = 10
+
+// Firings and handoff claims outlive the weekly shift they belong to by a day
+const shiftTTL = 8 * 24 * time.Hour
+
+// rotation is a team's weekly on-call rotation
+type rotation struct {
+	team     string
+	channels map[string]bool
+	handoff  string // channel for the summary
+	weekday  time.Weekday
+	hour     int
+	minute   int
+	location *time.Location
+
+	shiftStart time.Time // the last boundary this replica handed off at, or started after
+}
+
+// shiftFiring is an alert that fired during a shift, kept in the state store under
+// "shift:<team>|<shift start>|<alert ID>" so every replica's firings are counted
+type shiftFiring struct {
+	Source   string `json:"source"`
+	Name     string `json:"name"`
+	Severity string `json:"severity"`
+}
+
+type firedRule struct {
+	name     string
+	severity string // the most urgent it fired at
+	count    int
+}
+
+func newRotations(teams map[string]config.TeamConfig) []*rotation {
+	var rotations []*rotation
+	for team, cfg := range teams {
+		if cfg.HandoffChannel == "" {
+			continue // the team only sets route defaults
+		}
+		r, err := newRotation(team, cfg)
+		if err != nil {
+			log.Printf("Skipping on-call handoffs for %s: %v", team, err)
+			continue
+		}
+		rotations = append(rotations, r)
+	}
+	return rotations
+}
+
+func newRotation(team string, cfg config.TeamConfig) (*rotation, error) {
+	if cfg.HandoffChannel == "" {
+		return nil, fmt.Errorf("no handoff_channel")
+	}
+
+	weekday := -1
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(cfg.HandoffDay, day.String()) {
+			weekday = int(day)
+		}
+	}
+	if weekday < 0 {
+		return nil, fmt.Errorf("invalid handoff_day %q", cfg.HandoffDay)
+	}
+
+	handoffTime := cfg.HandoffTime
+	if handoffTime == "" {
+		handoffTime = "09:00"
+	}
+	at, err := time.Parse("15:04", handoffTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid handoff_time %q", cfg.HandoffTime)
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+	}
+
+	r := &rotation{
+		team:     team,
+		channels: make(map[string]bool),
+		handoff:  cfg.HandoffChannel,
+		weekday:  time.Weekday(weekday),
+		hour:     at.Hour(),
+		minute:   at.Minute(),
+		location: location,
+	}
+	for _, channel := range cfg.Channels {
+		r.channels[channel] = true
+	}
+	r.shiftStart = r.lastHandoff(time.Now())
+	return r, nil
+}
+
+// lastHandoff returns the most recent rotation boundary at or before now
+func (r *rotation) lastHandoff(now time.Time) time.Time {
+	local := now.In(r.location)
+	boundary := time.Date(local.Year(), local.Month(), local.Day(), r.hour, r.minute, 0, 0, r.location)
+	boundary = boundary.AddDate(0, 0, -int((local.Weekday()-r.weekday+7)%7))
+	if boundary.After(local) {
+		boundary = boundary.AddDate(0, 0, -7)
+	}
+	return boundary
+}
+
+// shiftPrefix starts the keys of the firings of the team's shift starting at start
+func shiftPrefix(team string, start time.Time) string {
+	return fmt.Sprintf("shift:%s|%s|", team, start.UTC().Format(time.RFC3339))
+}
+
+// recordShift counts a delivered alert towards the current shift of the teams on call for its
+// channel. State errors are logged, leaving the alert out of the summaries.
+func (d *Dispatcher) recordShift(ctx context.Context, alertMsg *alert.Alert, alertID string) {
+	if alertMsg.Status != alert.StatusFiring {
+		return
+	}
+	data, err := json.Marshal(shiftFiring{Source: alertMsg.Source, Name: alertMsg.Name, Severity: alertMsg.Severity})
+	if err != nil {
+		log.Printf("Failed to encode shift firing of %s: %v", alertMsg.Name, err)
+		return
+	}
+	now := time.Now()
+	for _, r := range d.rotations {
+		if !r.channels[alertMsg.Channel] {
+			continue
+		}
+		key := shiftPrefix(r.team, r.lastHandoff(now)) + alertID
+		if err := d.store.Set(ctx, key, string(data), shiftTTL); err != nil {
+			log.Printf("Failed to count %s towards the %s shift: %v", alertMsg.Name, r.team, err)
+		}
+	}
+}
+
+// shiftFired returns what fired during the team's shift starting at start, most frequent first
+func (d *Dispatcher) shiftFired(ctx context.Context, r *rotation, start time.Time) ([]firedRule, error) {
+	values, err := d.store.Scan(ctx, shiftPrefix(r.team, start))
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]*firedRule)
+	for _, value := range values {
+		var f shiftFiring
+		if err := json.Unmarshal([]byte(value), &f); err != nil {
+			continue
+		}
+		key := f.Source + "|" + f.Name
+		rule, ok := rules[key]
+		if !ok {
+			rule = &firedRule{name: f.Name, severity: f.Severity}
+			rules[key] = rule
+		}
+		if f.Severity < rule.severity {
+			rule.severity = f.Severity
+		}
+		rule.count++
+	}
+
+	fired := make([]firedRule, 0, len(rules))
+	for _, rule := range rules {
+		fired = append(fired, *rule)
+	}
+	sort.Slice(fired, func(i, j int) bool {
+		if fired[i].count != fired[j].count {
+			return fired[i].count > fired[j].count
+		}
+		return fired[i].name < fired[j].name
+	})
+	return fired, nil
+}
+
+// teamSilences describes the AlertSilences and API silences in effect that can hold back
+// alerts in the team's channels: those limited to one of its channels, and those limited to
+// none
+func (d *Dispatcher) teamSilences(ctx context.Context, r *rotation, now time.Time) []string {
+	const until = "Mon 2 Jan 15:04"
+	d.reloadMu.RLock()
+	silences := d.silences
+	d.reloadMu.RUnlock()
+
+	var described []string
+	for _, s := range silences {
+		if !s.Active(now) || (len(s.Channels) > 0 && !slices.ContainsFunc(s.Channels, func(c string) bool { return r.channels[c] })) {
+			continue
+		}
+		text := s.Name
+		if !s.EndsAt.IsZero() {
+			text += " until " + s.EndsAt.In(r.location).Format(until)
+		}
+		described = append(described, text+silenceNote(s.CreatedBy, s.Comment))
+	}
+	for _, s := range d.apiSilences.current(ctx) {
+		if s.State(now) != SilenceActive || !s.coversChannels(r.channels) {
+			continue
+		}
+		matchers := make([]string, 0, len(s.Matchers))
+		for _, m := range s.Matchers {
+			matchers = append(matchers, m.String())
+		}
+		text := fmt.Sprintf("{%s} until %s", strings.Join(matchers, ", "), s.EndsAt.In(r.location).Format(until))
+		described = append(described, text+silenceNote(s.CreatedBy, s.Comment))
+	}
+	sort.Strings(described)
+	return described
+}
+
+// silenceNote is " by <who>: <comment>", leaving out what the silence doesn't say
+func silenceNote(createdBy, comment string) string {
+	var note string
+	if createdBy != "" {
+		note = " by " + createdBy
+	}
+	if comment != "" {
+		note += ": " + comment
+	}
+	return note
+}
+
+// escalates reports whether alerts of the priority are paged through Opsgenie or escalated
+// through Twilio
+func (d *Dispatcher) escalates(priority string) bool {
+	return (d.pager != nil && d.pager.priorities[priority]) || (d.escalator != nil && d.escalator.priorities[priority])
+}
+
+// RunHandoffs posts each team's on-call handoff summary when its rotation changes over, checking
+// every minute until ctx is cancelled
+func (d *Dispatcher) RunHandoffs(ctx context.Context) {
+	if len(d.rotations) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, r := range d.rotations {
+				if boundary := r.lastHandoff(now); boundary.After(r.shiftStart) {
+					r.shiftStart = boundary
+					d.postHandoff(ctx, r, boundary)
+				}
+			}
+		}
+	}
+}
+
+// postHandoff summarizes the ending shift in the team's handoff channel. Replicas share the
+// state store, so only the first to claim the boundary posts it.
+func (d *Dispatcher) postHandoff(ctx context.Context, r *rotation, boundary time.Time) {
+	key := fmt.Sprintf("handoff:%s|%s", r.team, boundary.UTC().Format(time.RFC3339))
+	if claimed, err := d.store.SetNX(ctx, key, "posted", shiftTTL); err != nil {
+		log.Printf("Failed to claim handoff for %s, posting anyway: %v", r.team, err)
+	} else if !claimed {
+		return
+	}
+
+	start := r.lastHandoff(boundary.Add(-time.Minute))
+	fired, err := d.shiftFired(ctx, r, start)
+	if err != nil {
+		log.Printf("Failed to read the %s shift's firings, summarizing without them: %v", r.team, err)
+	}
+	open := d.open.inChannels(ctx, r.channels)
+	var escalated []openAlert
+	for _, a := range open {
+		if d.escalates(a.Severity) {
+			escalated = append(escalated, a)
+		}
+	}
+
+	summary := handoffSummary(r, start, boundary, open, escalated, d.teamSilences(ctx, r, boundary), fired)
+	if err := d.slackNotifier(r.handoff).NotifyText(ctx, summary); err != nil {
+		log.Printf("Failed to post on-call handoff for %s: %v", r.team, err)
+		notifierErrors.Inc("handoff")
+		return
+	}
+	log.Printf("Posted on-call handoff for %s to %s", r.team, r.handoff)
+}
+
+func handoffSummary(r *rotation, start, end time.Time, open, escalated []openAlert, silences []string, fired []firedRule) string {
+	const day = "Mon 2 Jan 15:04"
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔄 *On-call handoff for %s*\n_%s → %s (%s)_\n", r.team,
+		start.In(r.location).Format(day), end.In(r.location).Format(day), r.location)
+
+	fmt.Fprintf(&sb, "\n*Open alerts (%d)*\n", len(open))
+	if len(open) == 0 {
+		sb.WriteString("_None_ 🎉\n")
+	}
+	for _, a := range open {
+		fmt.Fprintf(&sb, "• %s in %s\n", a.Name, a.Channel)
+	}
+
+	// Paged or phoned, and still nobody has acknowledged them
+	if len(escalated) > 0 {
+		fmt.Fprintf(&sb, "\n*Escalated and unacknowledged (%d)*\n", len(escalated))
+		for _, a := range escalated {
+			fmt.Fprintf(&sb, "• [%s] %s in %s\n", a.Severity, a.Name, a.Channel)
+		}
+	}
+
+	fmt.Fprintf(&sb, "\n*Silences in effect (%d)*\n", len(silences))
+	if len(silences) == 0 {
+		sb.WriteString("_None_\n")
+	}
+	for _, silence := range silences {
+		fmt.Fprintf(&sb, "• %s\n", silence)
+	}
+
+	total := 0
+	for _, rule := range fired {
+		total += rule.count
+	}
+	fmt.Fprintf(&sb, "\n*Fired this shift: %d alerts from %d rules*\n", total, len(fired))
+	for i, rule := range fired {
+		if i == handoffTopRules {
+			fmt.Fprintf(&sb, "_…and %d more rules_\n", len(fired)-handoffTopRules)
+			break
+		}
+		fmt.Fprintf(&sb, "• %d× [%s] %s\n", rule.count, rule.severity, rule.name)
+	}
+	return sb.String()
+}
@@ -0,0 +1,179 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// heartbeatAlertName names the alert raised when an input stops receiving alerts
+const heartbeatAlertName = "Input silent"
+
+const (
+	// heartbeatWrite is how often a replica records an input it keeps hearing from
+	heartbeatWrite = time.Minute
+	// heartbeatTTL keeps an input's last heartbeat well past any sensible window
+	heartbeatTTL = 30 * 24 * time.Hour
+)
+
+// heartbeats records when each input watched by the heartbeats config last received an alert,
+// in the state store so that every replica's inputs count. Writes are spaced heartbeatWrite
+// apart per replica.
+type heartbeats struct {
+	startedAt time.Time // inputs not heard from since count from here
+
+	mu      sync.Mutex
+	written map[string]time.Time
+}
+
+func newHeartbeats() *heartbeats {
+	return &heartbeats{startedAt: time.Now(), written: make(map[string]time.Time)}
+}
+
+// due reports whether this replica should write input's heartbeat now
+func (h *heartbeats) due(input string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now.Sub(h.written[input]) < heartbeatWrite {
+		return false
+	}
+	h.written[input] = now
+	return true
+}
+
+// heartbeatKey is "heartbeat:<input>", holding when input last received an alert
+func heartbeatKey(input string) string {
+	return "heartbeat:" + input
+}
+
+// silentKey is "heartbeat-silent:<input>", set while the input's silence alert fires
+func silentKey(input string) string {
+	return "heartbeat-silent:" + input
+}
+
+// Heartbeat records that input, such as "sqs", "grafana" or "webhook/<name>", received an
+// alert, resolving its silence alert if one fired. Inputs without a heartbeat are ignored.
+func (d *Dispatcher) Heartbeat(ctx context.Context, input string) {
+	if _, watched := d.config.Heartbeats[input]; !watched {
+		return
+	}
+	now := time.Now()
+	if !d.heartbeats.due(input, now) {
+		return
+	}
+
+	if err := d.store.Set(ctx, heartbeatKey(input), now.UTC().Format(time.RFC3339), heartbeatTTL); err != nil {
+		log.Printf("Failed to record heartbeat of %s: %v", input, err)
+		return
+	}
+	silentSince, silent, err := d.store.Get(ctx, silentKey(input))
+	if err != nil || !silent {
+		return
+	}
+	if err := d.store.Delete(ctx, silentKey(input)); err != nil {
+		log.Printf("Failed to clear silence alert of %s: %v", input, err)
+		return
+	}
+	var silentFor time.Duration
+	if since, err := time.Parse(time.RFC3339, silentSince); err == nil {
+		silentFor = now.Sub(since)
+	}
+	d.dispatchHeartbeat(ctx, input, false, silentFor)
+}
+
+// RunHeartbeats alerts once for each input silent for longer than its heartbeat allows,
+// checking every minute until ctx is cancelled. Inputs never heard from count from when the
+// dispatcher started.
+func (d *Dispatcher) RunHeartbeats(ctx context.Context) {
+	if len(d.config.Heartbeats) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for input, window := range d.config.Heartbeats {
+				d.checkHeartbeat(ctx, input, window, now)
+			}
+		}
+	}
+}
+
+// checkHeartbeat raises input's silence alert once it has gone window without an alert.
+// Replicas share the state store, so only the first to claim the silence alerts.
+func (d *Dispatcher) checkHeartbeat(ctx context.Context, input string, window time.Duration, now time.Time) {
+	lastSeen := d.heartbeats.startedAt
+	value, ok, err := d.store.Get(ctx, heartbeatKey(input))
+	if err != nil {
+		log.Printf("Failed to read heartbeat of %s: %v", input, err)
+		return
+	}
+	if seen, err := time.Parse(time.RFC3339, value); ok && err == nil && seen.After(lastSeen) {
+		lastSeen = seen
+	}
+	if now.Sub(lastSeen) <= window {
+		return
+	}
+
+	claimed, err := d.store.SetNX(ctx, silentKey(input), lastSeen.UTC().Format(time.RFC3339), heartbeatTTL)
+	if err != nil {
+		log.Printf("Failed to claim silence alert of %s: %v", input, err)
+		return
+	}
+	if claimed {
+		d.dispatchHeartbeat(ctx, input, true, now.Sub(lastSeen))
+	}
+}
+
+// dispatchHeartbeat raises or resolves input's silence alert, in the ops channel or else the
+// P1 channel
+func (d *Dispatcher) dispatchHeartbeat(ctx context.Context, input string, firing bool, silentFor time.Duration) {
+	channel := d.config.OpsChannel
+	if channel == "" {
+		channel = d.config.SlackChannels["P1"]
+	}
+	state, status := "ALARM", alert.StatusFiring
+	if !firing {
+		state, status = "OK", alert.StatusResolved
+	}
+	window := d.config.Heartbeats[input]
+	silentFor = silentFor.Round(time.Minute)
+
+	message := fmt.Sprintf("%s *Dispatcher Alert: %s*\n• *State:* `%s`\n• *Input:* `%s`", backlogEmoji(firing), heartbeatAlertName, state, input)
+	if firing {
+		message += fmt.Sprintf("\n• *No alerts for:* *%s* (expected within %s)", silentFor, window)
+		message += "\n• *Check:* the sender's subscription, contact point or webhook URL and secret"
+	} else {
+		message += fmt.Sprintf("\n• *Receiving alerts again* after %s", silentFor)
+	}
+	summary := fmt.Sprintf("%s *%s* `%s` %s", backlogEmoji(firing), heartbeatAlertName, state, input)
+	if firing {
+		summary += fmt.Sprintf(" silent for %s", silentFor)
+	}
+
+	heartbeatAlert := &alert.Alert{
+		Source:      alert.SourceDispatcher,
+		Name:        heartbeatAlertName,
+		Severity:    "P1",
+		Status:      status,
+		State:       state,
+		Channel:     channel,
+		Labels:      map[string]string{"input": input},
+		Annotations: map[string]string{alert.AnnotationValue: silentFor.String()},
+		ReceivedAt:  time.Now(),
+		Message:     message,
+		Summary:     summary,
+	}
+	if err := d.Dispatch(ctx, heartbeatAlert, ""); err != nil {
+		log.Printf("Failed to send silence alert of %s: %v", input, err)
+	}
+}
@@ -0,0 +1,101 @@
+package dispatch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/config"
+)
+
+// maxLintLines is how many findings a lint summary lists before "and N more"
+const maxLintLines = 20
+
+// lintClaimTTL is how long a posted change of lint summary keeps other replicas, which reload
+// the same config within moments of each other, from posting it again
+const lintClaimTTL = time.Hour
+
+// lintConfig logs the config's lint findings and, when they differ from the last ones, posts
+// a summary to the ops channel. A summary is also posted when earlier findings are all fixed.
+// Replicas share the state store, so only the first to claim a change of summary posts it.
+func (d *Dispatcher) lintConfig() {
+	findings := d.config.Lint()
+	for _, finding := range findings {
+		log.Printf("Config %s", finding)
+	}
+
+	summary := lintSummary(findings)
+	d.lintMu.Lock()
+	defer d.lintMu.Unlock()
+	if summary == d.lastLint {
+		return
+	}
+	previous := d.lastLint
+	fixed := summary == "" && previous != ""
+	d.lastLint = summary
+	if d.config.OpsChannel == "" {
+		return
+	}
+
+	ctx := context.Background()
+	if claimed, err := d.store.SetNX(ctx, lintClaimKey(previous, summary), "posted", lintClaimTTL); err != nil {
+		log.Printf("Failed to claim config lint summary, posting anyway: %v", err)
+	} else if !claimed {
+		return
+	}
+
+	text := summary
+	if fixed {
+		text = "✅ *Config lint:* the config problems posted earlier are fixed"
+	}
+	if err := d.slackNotifier(d.config.OpsChannel).NotifyText(ctx, text); err != nil {
+		log.Printf("Failed to post config lint summary to %s: %v", d.config.OpsChannel, err)
+	}
+}
+
+// lintClaimKey identifies a change from one lint summary to the next by their hash, so every
+// replica making the same change claims the same key
+func lintClaimKey(previous, summary string) string {
+	sum := sha256.Sum256([]byte(previous + "\x00" + summary))
+	return "lint:" + hex.EncodeToString(sum[:16])
+}
+
+// lintSummary is e.g. "⚠️ *Config lint: 1 error, 2 warnings*" followed by a line per finding,
+// or empty when there are none
+func lintSummary(findings []config.LintFinding) string {
+	if len(findings) == 0 {
+		return ""
+	}
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == config.LintError {
+			errors++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "⚠️ *Config lint: %s, %s*", plural(errors, "error"), plural(len(findings)-errors, "warning"))
+	for i, finding := range findings {
+		if i == maxLintLines {
+			fmt.Fprintf(&sb, "\n• _and %d more, see the logs_", len(findings)-maxLintLines)
+			break
+		}
+		badge := "🟡"
+		if finding.Severity == config.LintError {
+			badge = "🔴"
+		}
+		fmt.Fprintf(&sb, "\n%s %s", badge, finding.Message)
+	}
+	return sb.String()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
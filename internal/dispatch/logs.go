@@ -0,0 +1,144 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/render"
+)
+
+// LogsInsights runs CloudWatch Logs Insights queries; *cwlogs.Client implements it
+type LogsInsights interface {
+	Query(ctx context.Context, region string, logGroups []string, query string, start, end time.Time, limit int) ([]string, error)
+}
+
+// LokiQuerier runs LogQL queries; *loki.Client implements it
+type LokiQuerier interface {
+	QueryRange(ctx context.Context, baseURL, tenant, query string, start, end time.Time, limit int) ([]string, error)
+}
+
+// UseLogsInsights enables the cloudwatch log queries of routes
+func (d *Dispatcher) UseLogsInsights(insights LogsInsights) {
+	d.logsInsights = insights
+}
+
+// UseLoki enables the loki log queries of routes
+func (d *Dispatcher) UseLoki(loki LokiQuerier) {
+	d.loki = loki
+}
+
+// maxLogLine is how much of each log line is posted
+const maxLogLine = 300
+
+// fetchLogsEnabled reports whether the route's log query applies to the alert and its client is
+// set up, so the alert gets a Fetch logs button
+func (d *Dispatcher) fetchLogsEnabled(alertMsg *alert.Alert, route config.RouteConfig) bool {
+	logs := route.Logs
+	if logs == nil || !logs.Matches(alertMsg.Name) {
+		return false
+	}
+	switch logs.Type {
+	case config.LogQueryCloudWatch:
+		return d.logsInsights != nil
+	case config.LogQueryLoki:
+		return d.loki != nil
+	}
+	return false
+}
+
+// FetchLogs runs the log query of the alert's route, templated with the alert, and posts the
+// newest results in the thread. The query searches from its lookback before the alert started
+// until now.
+func (d *Dispatcher) FetchLogs(ctx context.Context, channelID, threadTS, alertID, userName string) error {
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return fmt.Errorf("failed to look up alert %s: %v", alertID, err)
+	}
+	if !ok || record.Alert == nil {
+		return fmt.Errorf("alert %s is no longer archived", alertID)
+	}
+	alertMsg := record.Alert
+	route := d.config.RouteFor(alertMsg.Channel)
+	if !d.fetchLogsEnabled(alertMsg, route) {
+		return fmt.Errorf("the route of %s no longer has a log query for %s", alertMsg.Channel, alertMsg.Name)
+	}
+	logs := *route.Logs
+
+	query, err := renderLogTemplate(logs.Query, alertMsg)
+	if err != nil {
+		return err
+	}
+	started := alertMsg.ReceivedAt
+	if alertMsg.StartsAt != nil {
+		started = *alertMsg.StartsAt
+	}
+	start, end := started.Add(-logs.LookbackWindow()), time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.LogQueryTimeout)
+	defer cancel()
+
+	var lines []string
+	switch logs.Type {
+	case config.LogQueryCloudWatch:
+		var groups []string
+		for _, group := range logs.LogGroups {
+			rendered, err := renderLogTemplate(group, alertMsg)
+			if err != nil {
+				return err
+			}
+			if !logs.LogGroupAllowed(group, rendered) {
+				return fmt.Errorf("log group %q isn't one the route allows searching", rendered)
+			}
+			groups = append(groups, rendered)
+		}
+		region := logs.Region
+		if region == "" {
+			region = alertRegion(alertMsg)
+		}
+		lines, err = d.logsInsights.Query(ctx, region, groups, query, start, end, logs.ResultLimit())
+	case config.LogQueryLoki:
+		lines, err = d.loki.QueryRange(ctx, logs.URL, logs.Tenant, query, start, end, logs.ResultLimit())
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Fetched %d log lines for %s by %s", len(lines), alertMsg.Name, userName)
+
+	text := fmt.Sprintf("📜 *Logs for %s* fetched by %s, %s to %s UTC", alertMsg.Name, userName, start.UTC().Format("15:04"), end.UTC().Format("15:04"))
+	if len(lines) == 0 {
+		text += "\n_No log lines matched_ `" + query + "`"
+	} else {
+		for i, line := range lines {
+			lines[i] = truncateLogLine(line)
+		}
+		text += "\n```\n" + strings.ReplaceAll(strings.Join(lines, "\n"), "```", "'''") + "\n```"
+	}
+	return d.slackNotifier(channelID).InThread(threadTS).NotifyText(ctx, text)
+}
+
+// truncateLogLine cuts a line longer than maxLogLine bytes at the start of the rune it ends in
+func truncateLogLine(line string) string {
+	if len(line) <= maxLogLine {
+		return line
+	}
+	end := maxLogLine
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	return line[:end] + "…"
+}
+
+// renderLogTemplate fills a log query or log group template with the alert
+func renderLogTemplate(source string, alertMsg *alert.Alert) (string, error) {
+	renderer, err := render.NewLogQueryRenderer("logs", source)
+	if err != nil {
+		return "", err
+	}
+	return renderer.Render(alertMsg)
+}
@@ -0,0 +1,52 @@
+package dispatch
+
+import (
+	"log"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+)
+
+var noDataAlerts = metrics.NewCounter("alert_dispatcher_nodata_alerts_total",
+	"NoData/INSUFFICIENT_DATA alerts by the route policy applied.", "policy")
+
+// applyNoDataPolicy handles a NoData alert per its route, returning false when it should be dropped.
+// Downgraded and rerouted alerts have their channel changed and are delivered using that channel's route.
+func (d *Dispatcher) applyNoDataPolicy(alertMsg *alert.Alert, route config.RouteConfig) bool {
+	switch route.NoDataPolicy {
+	case config.NoDataDrop:
+		log.Printf("Dropping NoData alert %s per route policy for %s", alertMsg.Name, alertMsg.Channel)
+		return false
+	case config.NoDataDowngrade:
+		alertMsg.Severity = downgradePriority(alertMsg.Severity)
+		channel := d.priorityChannel(alertMsg.Severity)
+		log.Printf("Downgrading NoData alert %s to %s (%s)", alertMsg.Name, alertMsg.Severity, channel)
+		alertMsg.Channel = channel
+	case config.NoDataReroute:
+		if route.NoDataChannel == "" {
+			log.Printf("NoData reroute policy for %s has no nodata_channel, delivering as-is", alertMsg.Channel)
+			return true
+		}
+		log.Printf("Rerouting NoData alert %s from %s to %s", alertMsg.Name, alertMsg.Channel, route.NoDataChannel)
+		alertMsg.Channel = route.NoDataChannel
+	}
+	return true
+}
+
+func downgradePriority(priority string) string {
+	switch priority {
+	case "P0":
+		return "P1"
+	default:
+		return "P2"
+	}
+}
+
+// priorityChannel returns the channel configured for a priority, or the default channel
+func (d *Dispatcher) priorityChannel(priority string) string {
+	if channel := d.config.SlackChannels[priority]; channel != "" {
+		return channel
+	}
+	return d.config.SlackChannels["default"]
+}
@@ -0,0 +1,116 @@
+package dispatch
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// Notifiers dropped by a reload are closed this long after, once alerts using them are done
+const notifierCloseDelay = time.Minute
+
+// notifierCache keeps one notifier per destination, so their clients and connections are reused
+// across alerts instead of being rebuilt for each
+type notifierCache struct {
+	mu        sync.Mutex
+	notifiers map[string]notifier.AlertNotifier
+}
+
+func newNotifierCache() *notifierCache {
+	return &notifierCache{notifiers: make(map[string]notifier.AlertNotifier)}
+}
+
+// get returns the notifier cached under key, building it on first use. Building can dial the
+// destination, so it happens outside the lock; of notifiers built at the same moment the first
+// cached is kept and the rest closed. Build errors aren't cached, so a destination fixed by a
+// config reload works on the next alert.
+func (c *notifierCache) get(key string, build func() (notifier.AlertNotifier, error)) (notifier.AlertNotifier, error) {
+	c.mu.Lock()
+	n, ok := c.notifiers[key]
+	c.mu.Unlock()
+
+	if !ok {
+		built, err := build()
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		if n, ok = c.notifiers[key]; !ok {
+			n = built
+			c.notifiers[key] = n
+		}
+		c.mu.Unlock()
+		if ok {
+			if err := notifier.Close(built); err != nil {
+				log.Printf("Failed to close notifier %s: %v", key, err)
+			}
+		}
+	}
+
+	// Slack notifiers track the thread of the message being posted, so each caller gets a copy
+	if slackNotifier, ok := n.(*notifier.SlackNotifier); ok {
+		return slackNotifier.Clone(), nil
+	}
+	return n, nil
+}
+
+// close releases the connections of every cached notifier and empties the cache
+func (c *notifierCache) close() {
+	closeNotifiers(c.take())
+}
+
+// reset empties the cache, so notifiers are built again from a reloaded config, and closes the
+// ones it held after notifierCloseDelay
+func (c *notifierCache) reset() {
+	if dropped := c.take(); len(dropped) > 0 {
+		time.AfterFunc(notifierCloseDelay, func() { closeNotifiers(dropped) })
+	}
+}
+
+// take empties the cache, returning what it held
+func (c *notifierCache) take() map[string]notifier.AlertNotifier {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	taken := c.notifiers
+	c.notifiers = make(map[string]notifier.AlertNotifier)
+	return taken
+}
+
+func closeNotifiers(notifiers map[string]notifier.AlertNotifier) {
+	for key, n := range notifiers {
+		if err := notifier.Close(n); err != nil {
+			log.Printf("Failed to close notifier %s: %v", key, err)
+		}
+	}
+}
+
+// targetKey identifies a target's destination and timeout; targets sharing it share a notifier
+func targetKey(target config.TargetConfig) string {
+	return strings.Join([]string{target.Type, target.Channel, target.URL, strings.Join(target.To, ","), target.Timeout.String()}, "|")
+}
+
+// Notifier returns the cached notifier for a route target, creating it on first use
+func (d *Dispatcher) Notifier(target config.TargetConfig) (notifier.AlertNotifier, error) {
+	return d.notifiers.get(targetKey(target), func() (notifier.AlertNotifier, error) {
+		return notifier.New(target, d.config)
+	})
+}
+
+// Close posts the summaries of alerts rate limits and sampling are holding back, then releases the
+// connections held by cached notifiers and streams. Call it on shutdown, once alerts are no
+// longer being dispatched.
+func (d *Dispatcher) Close() {
+	d.throttler.close()
+	d.sampler.close()
+	d.notifiers.close()
+	for _, s := range d.streams {
+		if err := notifier.Close(s.notifier); err != nil {
+			log.Printf("Failed to close the %s stream: %v", s.name, err)
+		}
+	}
+}
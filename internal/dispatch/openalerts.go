@@ -0,0 +1,202 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/audit"
+	"alert-dispatcher/internal/state"
+)
+
+// openAlert is a firing alert that nobody has acknowledged or dismissed yet
+type openAlert struct {
+	AlertID  string
+	Name     string
+	Severity string
+	Channel  string
+	ThreadTS string // the digest the alert was posted under, if any
+	Webhook  string // the configured webhook it was sent to, if any
+	Labels   map[string]string
+	// SilenceURL is the alert's Grafana or Alertmanager silence link, if it has one
+	SilenceURL string
+}
+
+// Firing alerts nobody closes are forgotten after a week, resolved or not
+const openAlertTTL = 7 * 24 * time.Hour
+
+// openPrefix starts the keys of open alerts, "open:<alert ID>"
+const openPrefix = "open:"
+
+// openAlerts tracks firing alerts in the state store, so they can be acknowledged in bulk from
+// any replica. State errors are logged and treated as no open alerts.
+type openAlerts struct {
+	store state.Store
+}
+
+func newOpenAlerts(store state.Store) *openAlerts {
+	return &openAlerts{store: store}
+}
+
+// track records a firing alert and forgets earlier ones with the same name in the channel once
+// it resolves
+func (o *openAlerts) track(ctx context.Context, alertMsg *alert.Alert, alertID, threadTS string) {
+	if alertMsg.IsResolved() {
+		o.take(ctx, func(open openAlert) bool {
+			return open.Name == alertMsg.Name && open.Channel == alertMsg.Channel
+		})
+		return
+	}
+	if alertMsg.Status != alert.StatusFiring {
+		return
+	}
+	data, err := json.Marshal(openAlert{
+		AlertID:  alertID,
+		Name:     alertMsg.Name,
+		Severity: alertMsg.Severity,
+		Channel:  alertMsg.Channel,
+		ThreadTS: threadTS,
+		Webhook:  alertWebhook(alertMsg),
+		Labels:   alertMsg.Labels,
+
+		SilenceURL: alertMsg.URLs[alert.URLSilence],
+	})
+	if err != nil {
+		log.Printf("Failed to encode open alert %s: %v", alertID, err)
+		return
+	}
+	if err := o.store.Set(ctx, openPrefix+alertID, string(data), openAlertTTL); err != nil {
+		log.Printf("Failed to save open alert %s: %v", alertID, err)
+	}
+}
+
+func (o *openAlerts) isOpen(ctx context.Context, alertID string) bool {
+	_, ok, err := o.store.Get(ctx, openPrefix+alertID)
+	if err != nil {
+		log.Printf("Failed to look up open alert %s: %v", alertID, err)
+	}
+	return ok
+}
+
+// inChannels returns the open alerts posted to any of the channels, sorted by name
+func (o *openAlerts) inChannels(ctx context.Context, channels map[string]bool) []openAlert {
+	return o.matching(ctx, func(open openAlert) bool { return channels[open.Channel] })
+}
+
+// matching returns the open alerts accepted by match, sorted by name, leaving them open
+func (o *openAlerts) matching(ctx context.Context, match func(openAlert) bool) []openAlert {
+	matched, _ := o.find(ctx, match)
+	return matched
+}
+
+// take removes and returns the open alerts accepted by match, sorted by name. Replicas taking
+// the same alert at the same moment may both return it.
+func (o *openAlerts) take(ctx context.Context, match func(openAlert) bool) []openAlert {
+	taken, keys := o.find(ctx, match)
+	if len(keys) == 0 {
+		return taken
+	}
+	if err := o.store.Delete(ctx, keys...); err != nil {
+		log.Printf("Failed to close %d open alerts: %v", len(keys), err)
+		return nil
+	}
+	return taken
+}
+
+// find returns the open alerts accepted by match, sorted by name, and their keys
+func (o *openAlerts) find(ctx context.Context, match func(openAlert) bool) ([]openAlert, []string) {
+	values, err := o.store.Scan(ctx, openPrefix)
+	if err != nil {
+		log.Printf("Failed to read open alerts: %v", err)
+		return nil, nil
+	}
+
+	var found []openAlert
+	var keys []string
+	for key, value := range values {
+		var open openAlert
+		if err := json.Unmarshal([]byte(value), &open); err != nil {
+			log.Printf("Failed to decode open alert %s: %v", strings.TrimPrefix(key, openPrefix), err)
+			continue
+		}
+		if match(open) {
+			found = append(found, open)
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found, keys
+}
+
+// CloseAlert records a person acknowledging or dismissing a single alert, calls back the
+// webhook it came from and, with silence sync, silences an acknowledged alert upstream
+func (d *Dispatcher) CloseAlert(ctx context.Context, action, alertID, user, via string) {
+	entry := audit.Entry{Action: action, AlertID: alertID, User: user, Via: via}
+	callback := Callback{Event: callbackAction(action), AlertID: alertID, User: user, Via: via}
+	var closed openAlert
+	if taken := d.open.take(ctx, func(open openAlert) bool { return open.AlertID == alertID }); len(taken) > 0 {
+		closed = taken[0]
+	} else if record, ok, err := d.archive.Get(ctx, alertID); err == nil && ok && record.Alert != nil {
+		closed = openAlert{
+			AlertID:    alertID,
+			Name:       record.Alert.Name,
+			Webhook:    alertWebhook(record.Alert),
+			Labels:     record.Alert.Labels,
+			SilenceURL: record.Alert.URLs[alert.URLSilence],
+		}
+	}
+	entry.AlertName = closed.Name
+	callback.Alert, callback.Labels, callback.Webhook = closed.Name, closed.Labels, closed.Webhook
+	audit.Record(entry)
+	d.callBack(callback)
+	d.acknowledgedUpstream(action, closed, user)
+}
+
+// CloseDigest acknowledges or dismisses every open alert posted under a digest message,
+// returning their names
+func (d *Dispatcher) CloseDigest(ctx context.Context, action, threadTS, user string) []string {
+	closed := d.open.take(ctx, func(open openAlert) bool { return open.ThreadTS == threadTS })
+	return d.recordClosed(closed, action, user, audit.ViaDigest)
+}
+
+// CloseMatching acknowledges or dismisses every open alert whose name matches a glob pattern
+// such as "payments-*", case-insensitively, returning their names
+func (d *Dispatcher) CloseMatching(ctx context.Context, action, pattern, user string) ([]string, error) {
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	closed := d.open.take(ctx, func(open openAlert) bool {
+		matched, _ := path.Match(pattern, strings.ToLower(open.Name))
+		return matched
+	})
+	return d.recordClosed(closed, action, user, audit.ViaCommand), nil
+}
+
+// recordClosed writes one audit entry per alert, calls back the webhooks they came from and
+// silences acknowledged alerts upstream
+func (d *Dispatcher) recordClosed(closed []openAlert, action, user, via string) []string {
+	names := make([]string, len(closed))
+	for i, open := range closed {
+		audit.Record(audit.Entry{Action: action, AlertID: open.AlertID, AlertName: open.Name, User: user, Via: via})
+		d.callBack(Callback{
+			Event:   callbackAction(action),
+			AlertID: open.AlertID,
+			Alert:   open.Name,
+			Labels:  open.Labels,
+			Webhook: open.Webhook,
+			User:    user,
+			Via:     via,
+		})
+		d.acknowledgedUpstream(action, open, user)
+		names[i] = open.Name
+	}
+	return names
+}
@@ -0,0 +1,49 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+	"alert-dispatcher/notifier"
+)
+
+var notifierErrors = metrics.NewCounter("alert_dispatcher_notifier_errors_total",
+	"Failed deliveries to notifiers other than Slack.", "notifier")
+
+// pager pages for alerts of the configured priorities in addition to posting them to Slack
+type pager struct {
+	opsgenie   *notifier.OpsgenieNotifier
+	priorities map[string]bool
+}
+
+func newPager(cfg config.OpsgenieConfig) *pager {
+	if cfg.APIKey == "" {
+		return nil
+	}
+
+	log.Printf("Paging %v alerts through Opsgenie", cfg.Priorities)
+	p := &pager{
+		opsgenie:   notifier.NewOpsgenieNotifier(cfg.APIURL, cfg.APIKey, cfg.Timeout),
+		priorities: make(map[string]bool),
+	}
+	for _, priority := range cfg.Priorities {
+		p.priorities[priority] = true
+	}
+	return p
+}
+
+// page creates, updates or closes the Opsgenie alert. Slack remains the primary destination,
+// so paging failures are logged and counted rather than failing the delivery.
+func (p *pager) page(ctx context.Context, alertMsg *alert.Alert, alertID string) {
+	if p == nil || !p.priorities[alertMsg.Severity] {
+		return
+	}
+
+	if err := p.opsgenie.NotifyAlert(ctx, alertMsg, alertID); err != nil {
+		log.Printf("Failed to page %s through Opsgenie: %v", alertMsg.Name, err)
+		notifierErrors.Inc("opsgenie")
+	}
+}
@@ -0,0 +1,79 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"alert-dispatcher/internal/archive"
+	"alert-dispatcher/notifier"
+)
+
+// permalinkPath prefixes alert permalinks, e.g. https://alerts.example.com/alerts/<alert ID>
+const permalinkPath = "/alerts/"
+
+// AlertRecord returns an archived alert by ID
+func (d *Dispatcher) AlertRecord(ctx context.Context, alertID string) (archive.Record, bool, error) {
+	return d.archive.Get(ctx, alertID)
+}
+
+// UnfurlLinks previews the alert permalinks among links shared in a message with a card showing
+// the alert's current status and its action buttons. Other links, and alerts no longer archived,
+// are left alone.
+func (d *Dispatcher) UnfurlLinks(ctx context.Context, channel, messageTS string, links []string) error {
+	cards := make(map[string]notifier.UnfurlCard)
+	for _, link := range links {
+		alertID, ok := d.permalinkAlertID(link)
+		if !ok {
+			continue
+		}
+		record, ok, err := d.archive.Get(ctx, alertID)
+		if err != nil {
+			log.Printf("Failed to look up alert %s to unfurl: %v", alertID, err)
+			continue
+		}
+		if !ok || record.Alert == nil {
+			continue
+		}
+
+		a := record.Alert
+		status := strings.ToUpper(a.Status)
+		if a.Status == "firing" {
+			if d.open.isOpen(ctx, alertID) {
+				status += ", open"
+			} else {
+				status += ", acknowledged or dismissed"
+			}
+		}
+		text := fmt.Sprintf("*[%s] %s*\n*Status:* %s · *Source:* %s · *Channel:* %s\n*Dispatched:* <!date^%d^{date_short_pretty} {time}|%s>",
+			a.Severity, a.Name, status, a.Source, a.Channel,
+			record.StoredAt.Unix(), record.StoredAt.UTC().Format("2006-01-02 15:04 MST"))
+		if summary := a.Annotations["summary"]; summary != "" {
+			text += "\n" + summary
+		}
+
+		card := notifier.UnfurlCard{Text: text, Color: notifier.SeverityColor(a.Severity, a.IsResolved())}
+		if !a.IsResolved() {
+			card.AlertID = alertID
+		}
+		cards[link] = card
+	}
+	if len(cards) == 0 {
+		return nil
+	}
+
+	return d.slackNotifier(channel).Unfurl(ctx, messageTS, cards)
+}
+
+// permalinkAlertID extracts the alert ID from one of our permalinks
+func (d *Dispatcher) permalinkAlertID(link string) (string, bool) {
+	if d.config.PublicURL == "" {
+		return "", false
+	}
+	alertID, ok := strings.CutPrefix(link, d.config.PublicURL+permalinkPath)
+	if !ok || alertID == "" || strings.Contains(alertID, "/") {
+		return "", false
+	}
+	return alertID, true
+}
@@ -0,0 +1,118 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/errs"
+)
+
+// Plan is where an alert would be delivered, worked out without sending anything
+type Plan struct {
+	Dropped  bool
+	DropRule string // drop rule that matched
+	Reason   string // why it would be dropped or held back
+	Severity string
+	Channel  string
+	Targets  []config.TargetConfig
+}
+
+// verdict is what the decision step made of an alert: the route it is delivered by, or why it
+// isn't delivered
+type verdict struct {
+	route    config.RouteConfig
+	held     bool
+	dropRule string
+	reason   string
+}
+
+// decide applies drop rules, the source's quota, priority overrides, silences, the route's
+// NoData policy, sampling and rate limit to the alert, in the order deliver relies on. Dispatch
+// runs it for real; plans run it with peek set, which only looks at quotas, samplers and rate
+// limits without spending or counting, and tracks and records nothing.
+func (d *Dispatcher) decide(ctx context.Context, alertMsg *alert.Alert, alertID string, peek bool) (verdict, error) {
+	if rule, drop := d.shouldDrop(alertMsg); drop {
+		if !peek {
+			log.Printf("Dropping alert %s (%s) matched by drop rule %s", alertMsg.Name, alertMsg.State, rule)
+			droppedAlerts.Inc(rule)
+		}
+		return verdict{held: true, dropRule: rule, reason: "matched drop rule " + rule}, nil
+	}
+	if peek {
+		if quota, key, _, ok := d.alertQuota(alertMsg); ok && !d.quotas.available(key, quota, time.Now()) {
+			return verdict{held: true, reason: "over its source's quota"}, nil
+		}
+	} else if err := d.checkQuota(alertMsg); err != nil {
+		return verdict{}, err
+	}
+
+	d.applyPriorityOverride(ctx, alertMsg)
+	if !peek {
+		d.recordAlertMetrics(alertMsg)
+	}
+
+	if alertMsg.Channel == "" {
+		return verdict{}, &errs.RouteNotFoundError{Alert: alertMsg.Name}
+	}
+	if !peek {
+		d.active.track(ctx, alertMsg, alertID)
+	}
+	if peek {
+		if silencedBy := d.silencedBy(ctx, alertMsg, true); len(silencedBy) > 0 {
+			return verdict{held: true, reason: "matched silence " + silencedBy[0]}, nil
+		}
+	} else if name, silenced := d.silenced(ctx, alertMsg); silenced {
+		log.Printf("Holding back alert %s (%s) matched by silence %s", alertMsg.Name, alertMsg.State, name)
+		return verdict{held: true, reason: "matched silence " + name}, nil
+	}
+
+	route := d.config.RouteFor(alertMsg.Channel)
+	if alertMsg.IsNoData() && route.NoDataPolicy != config.NoDataDeliver {
+		if !peek {
+			noDataAlerts.Inc(route.NoDataPolicy)
+		}
+		if !d.applyNoDataPolicy(alertMsg, route) {
+			return verdict{held: true, reason: "dropped by the channel's NoData policy"}, nil
+		}
+		route = d.config.RouteFor(alertMsg.Channel)
+	}
+
+	// Sampled out and throttled alerts reach no one, so both come before every delivery
+	const sampledOut, throttled = "sampled out, its rule is firing over the channel's sampling threshold",
+		"over the channel's rate limit, counted in its summary"
+	if peek {
+		if sampling, ok := samplingFor(route); ok && !d.sampler.wouldAllow(alertMsg, sampling) {
+			return verdict{held: true, reason: sampledOut}, nil
+		}
+		if limit, ok := rateLimitFor(route, alertMsg.Severity); ok && d.throttler.full(alertMsg, limit) {
+			return verdict{held: true, reason: throttled}, nil
+		}
+		return verdict{route: route}, nil
+	}
+	if d.sampled(ctx, alertMsg, route) {
+		return verdict{held: true, reason: sampledOut}, nil
+	}
+	if d.throttled(alertMsg, route) {
+		return verdict{held: true, reason: throttled}, nil
+	}
+	return verdict{route: route}, nil
+}
+
+// PlanRoute runs a copy of the alert through the decision step Dispatch uses, without spending
+// quota, counting it towards sampling or rate limits or sending anything, for previewing
+// routing changes. The plan reflects the silences, overrides and limits in effect now.
+func (d *Dispatcher) PlanRoute(ctx context.Context, alertMsg *alert.Alert) (Plan, error) {
+	planned := *alertMsg
+	v, err := d.decide(ctx, &planned, "", true)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan := Plan{Dropped: v.held, DropRule: v.dropRule, Reason: v.reason, Severity: planned.Severity, Channel: planned.Channel}
+	if !v.held {
+		plan.Targets = d.config.TargetsFor(planned.Name, planned.Severity, planned.Channel, v.route)
+	}
+	return plan, nil
+}
@@ -0,0 +1,35 @@
+package dispatch
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+	"alert-dispatcher/internal/state"
+)
+
+func TestPlanRouteDoesNotCountDrops(t *testing.T) {
+	cfg := &config.Config{
+		SlackChannels: config.PriorityChannels(),
+		DropRules:     []config.DropRule{{Name: "plan-noise", Source: alert.SourceGrafana}},
+	}
+	d := NewDispatcher(cfg, state.NewMemoryStore())
+
+	plan, err := d.PlanRoute(context.Background(), &alert.Alert{Source: alert.SourceGrafana, Name: "Noisy", Channel: "#alerts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Dropped || plan.DropRule != "plan-noise" {
+		t.Fatalf("plan = %+v, want dropped by plan-noise", plan)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if series := `alert_dispatcher_dropped_alerts_total{rule="plan-noise"}`; strings.Contains(rec.Body.String(), series) {
+		t.Fatalf("planning counted a drop: %s", series)
+	}
+}
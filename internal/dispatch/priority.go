@@ -0,0 +1,78 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/audit"
+)
+
+// Priority overrides outlive any single incident so recurring alerts stay reclassified
+const priorityOverrideTTL = 90 * 24 * time.Hour
+
+// priorityOverrideKey is "priority:<fingerprint>"
+func priorityOverrideKey(fingerprint string) string {
+	return "priority:" + fingerprint
+}
+
+// applyPriorityOverride reclassifies the alert if someone changed the priority of an earlier
+// firing, routing it to the channel of its new priority. State errors are logged and the alert
+// keeps its priority.
+func (d *Dispatcher) applyPriorityOverride(ctx context.Context, alertMsg *alert.Alert) {
+	priority, ok, err := d.store.Get(ctx, priorityOverrideKey(alertMsg.Fingerprint()))
+	if err != nil {
+		log.Printf("Failed to look up priority override for %s: %v", alertMsg.Name, err)
+		return
+	}
+	if !ok || priority == alertMsg.Severity {
+		return
+	}
+
+	channel := d.priorityChannel(priority)
+	log.Printf("Overriding priority of %s from %s to %s (%s)", alertMsg.Name, alertMsg.Severity, priority, channel)
+	alertMsg.Severity = priority
+	alertMsg.Channel = channel
+}
+
+// ChangePriority reclassifies a dispatched alert, remembering the new priority for future firings
+// of the same alert, and cross-posts it to the channel of its new priority. It returns that channel.
+func (d *Dispatcher) ChangePriority(ctx context.Context, alertID, priority, user string) (string, error) {
+	switch priority {
+	case "P0", "P1", "P2":
+	default:
+		return "", fmt.Errorf("unknown priority %s", priority)
+	}
+
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up alert %s: %v", alertID, err)
+	}
+	if !ok || record.Alert == nil {
+		return "", fmt.Errorf("alert %s is no longer available", alertID)
+	}
+
+	previous := record.Alert.Severity
+	if err := d.store.Set(ctx, priorityOverrideKey(record.Alert.Fingerprint()), priority, priorityOverrideTTL); err != nil {
+		return "", fmt.Errorf("failed to save priority override for %s: %v", record.Alert.Name, err)
+	}
+	audit.Record(audit.Entry{
+		Action:    "change_priority",
+		AlertID:   alertID,
+		AlertName: record.Alert.Name,
+		User:      user,
+		Via:       audit.ViaButton,
+		Detail:    previous + " -> " + priority,
+	})
+
+	reclassified := *record.Alert
+	reclassified.Message = fmt.Sprintf("🔀 _Reclassified from %s to %s by %s_\n%s", previous, priority, user, record.Message)
+	reclassified.Summary = fmt.Sprintf("🔀 *%s* reclassified from %s to %s by %s", record.Alert.Name, previous, priority, user)
+	reclassified.Raw = record.Payload
+	if err := d.Dispatch(ctx, &reclassified, fmt.Sprintf("%s_%s", alertID, priority)); err != nil {
+		return "", err
+	}
+	return reclassified.Channel, nil
+}
@@ -0,0 +1,195 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/audit"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// ErrQuickActionDenied means the user isn't one of the quick action's users
+var ErrQuickActionDenied = errors.New("not allowed to run this quick action")
+
+// ScalingGroups scales Auto Scaling groups; *autoscaling.Client implements it
+type ScalingGroups interface {
+	ScaleGroup(ctx context.Context, region, group string, step, max int) (int, int, error)
+}
+
+// Deployments scales and restarts Kubernetes deployments; *kube.Client implements it
+type Deployments interface {
+	ScaleDeployment(ctx context.Context, namespace, name string, step, max int) (int, int, error)
+	RestartDeployment(ctx context.Context, namespace, name string) error
+}
+
+// UseScalingGroups enables the scale_group quick actions of routes
+func (d *Dispatcher) UseScalingGroups(groups ScalingGroups) {
+	d.scalingGroups = groups
+}
+
+// UseDeployments enables the scale_deployment and restart_deployment quick actions of routes
+func (d *Dispatcher) UseDeployments(deployments Deployments) {
+	d.deployments = deployments
+}
+
+// Labels naming the Auto Scaling group an alert is about: the CloudWatch dimension, then the
+// usual Prometheus and Datadog ones
+var scalingGroupLabels = []string{"AutoScalingGroupName", "autoscaling_group", "autoscaling_group_name"}
+
+// quickActionTarget is the Auto Scaling group or Kubernetes deployment a quick action changes
+type quickActionTarget struct {
+	Region    string // groups only; empty for the default region
+	Namespace string // deployments only
+	Name      string
+}
+
+func (t quickActionTarget) String() string {
+	if t.Namespace != "" {
+		return fmt.Sprintf("deployment `%s/%s`", t.Namespace, t.Name)
+	}
+	return fmt.Sprintf("Auto Scaling group `%s`", t.Name)
+}
+
+// quickActionTargetOf finds what the action would change from the alert's labels, reporting
+// false when the alert isn't about a group or deployment, the group or deployment isn't one the
+// action allows, or its client isn't set up
+func (d *Dispatcher) quickActionTargetOf(alertMsg *alert.Alert, action config.QuickAction) (quickActionTarget, bool) {
+	switch action.Type {
+	case config.QuickActionScaleGroup:
+		if d.scalingGroups == nil {
+			return quickActionTarget{}, false
+		}
+		for _, label := range scalingGroupLabels {
+			if group := alertMsg.Labels[label]; group != "" {
+				if !action.AllowsGroup(group) {
+					return quickActionTarget{}, false
+				}
+				return quickActionTarget{Region: alertRegion(alertMsg), Name: group}, true
+			}
+		}
+	case config.QuickActionScaleDeployment, config.QuickActionRestartDeployment:
+		// CloudWatch alerts have a namespace label too, such as AWS/EC2
+		namespace, deployment := alertMsg.Labels["namespace"], alertMsg.Labels["deployment"]
+		if d.deployments != nil && deployment != "" && namespace != "" && !strings.Contains(namespace, "/") &&
+			action.AllowsDeployment(namespace, deployment) {
+			return quickActionTarget{Namespace: namespace, Name: deployment}, true
+		}
+	}
+	return quickActionTarget{}, false
+}
+
+// alertRegion is the AWS region of a CloudWatch alert's alarm, whose region label is the
+// region's display name, or else the alert's region label
+func alertRegion(alertMsg *alert.Alert) string {
+	arn, _ := alertMsg.Extensions["alarm_arn"].(string)
+	// arn:aws:cloudwatch:eu-west-1:123456789012:alarm:HighCPU
+	if parts := strings.SplitN(arn, ":", 7); len(parts) == 7 {
+		return parts[3]
+	}
+	if alertMsg.Source == alert.SourceCloudWatch {
+		return ""
+	}
+	return alertMsg.Labels["region"]
+}
+
+// quickActions returns the buttons of the route's quick actions that apply to a firing alert
+func (d *Dispatcher) quickActions(alertMsg *alert.Alert, route config.RouteConfig) []notifier.QuickAction {
+	if alertMsg.IsResolved() {
+		return nil
+	}
+	var buttons []notifier.QuickAction
+	for _, action := range route.Actions {
+		if !action.Matches(alertMsg.Name) {
+			continue
+		}
+		target, ok := d.quickActionTargetOf(alertMsg, action)
+		if !ok {
+			continue
+		}
+		buttons = append(buttons, notifier.QuickAction{
+			Name:    action.Name,
+			Label:   action.ButtonLabel(),
+			Confirm: quickActionConfirmation(action, target),
+		})
+	}
+	return buttons
+}
+
+// quickActionConfirmation says what the action will do, for the user to confirm
+func quickActionConfirmation(action config.QuickAction, target quickActionTarget) string {
+	if action.Type == config.QuickActionRestartDeployment {
+		return fmt.Sprintf("Restart every pod of %s?", target)
+	}
+	unit := "instances"
+	if action.Type == config.QuickActionScaleDeployment {
+		unit = "replicas"
+	}
+	text := fmt.Sprintf("Add %d %s to %s", action.ScaleStep(), unit, target)
+	if action.Max > 0 {
+		text += fmt.Sprintf(", up to %d", action.Max)
+	}
+	return text + "?"
+}
+
+// RunQuickAction runs the named quick action of the alert's route against what the alert is
+// about, if the user is one of its users, and returns what it did
+func (d *Dispatcher) RunQuickAction(ctx context.Context, alertID, name, userID, userName string) (string, error) {
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return "", err
+	}
+	if !ok || record.Alert == nil {
+		return "", fmt.Errorf("alert %s is no longer archived", alertID)
+	}
+	alertMsg := record.Alert
+
+	var action config.QuickAction
+	for _, a := range d.config.RouteFor(alertMsg.Channel).Actions {
+		if a.Name == name {
+			action = a
+		}
+	}
+	if action.Name == "" {
+		return "", fmt.Errorf("the route of %s no longer has quick action %s", alertMsg.Channel, name)
+	}
+	if !action.Allows(userID) {
+		return "", ErrQuickActionDenied
+	}
+	target, ok := d.quickActionTargetOf(alertMsg, action)
+	if !ok {
+		return "", fmt.Errorf("quick action %s doesn't apply to %s", name, alertMsg.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.QuickActionTimeout)
+	defer cancel()
+
+	var done string
+	switch action.Type {
+	case config.QuickActionScaleGroup:
+		from, to, err := d.scalingGroups.ScaleGroup(ctx, target.Region, target.Name, action.ScaleStep(), action.Max)
+		if err != nil {
+			return "", err
+		}
+		done = fmt.Sprintf("scaled %s from %d to %d instances", target, from, to)
+	case config.QuickActionScaleDeployment:
+		from, to, err := d.deployments.ScaleDeployment(ctx, target.Namespace, target.Name, action.ScaleStep(), action.Max)
+		if err != nil {
+			return "", err
+		}
+		done = fmt.Sprintf("scaled %s from %d to %d replicas", target, from, to)
+	case config.QuickActionRestartDeployment:
+		if err := d.deployments.RestartDeployment(ctx, target.Namespace, target.Name); err != nil {
+			return "", err
+		}
+		done = fmt.Sprintf("restarted %s", target)
+	}
+
+	log.Printf("Quick action %s on %s by %s: %s", name, alertMsg.Name, userName, done)
+	audit.Record(audit.Entry{Action: "quick_action", AlertID: alertID, AlertName: alertMsg.Name, User: userName, Via: audit.ViaButton, Detail: name + ": " + strings.ReplaceAll(done, "`", "")})
+	return done, nil
+}
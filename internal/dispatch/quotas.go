@@ -0,0 +1,132 @@
+package dispatch
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/cache"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/errs"
+	"alert-dispatcher/internal/metrics"
+)
+
+var quotaRejections = metrics.NewCounter("alert_dispatcher_quota_rejections_total",
+	"Alerts held back because their source was over its quota, by source.", "source")
+
+// Buckets kept at most; past it the least recently used is dropped, so a quota key with
+// unbounded values can't grow memory
+const maxQuotaBuckets = 10000
+
+// sourceQuotas keeps a token bucket per source, or per source and quota key value. Like the
+// route rate limits they are local to each replica, so each allows the full quota. A bucket
+// left idle for its quota's period is full again, so it expires then.
+type sourceQuotas struct {
+	mu      sync.Mutex // serializes spending tokens
+	buckets *cache.Cache[string, *tokenBucket]
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newSourceQuotas() *sourceQuotas {
+	return &sourceQuotas{buckets: cache.New[string, *tokenBucket]("quota_buckets", maxQuotaBuckets, time.Hour)}
+}
+
+// quotaFor returns the source's own quota, or the "*" quota, reporting false without either.
+// Alerts the dispatcher raises about itself have none.
+func quotaFor(quotas map[string]config.SourceQuota, source string) (config.SourceQuota, bool) {
+	if source == alert.SourceDispatcher {
+		return config.SourceQuota{}, false
+	}
+	if quota, ok := quotas[source]; ok {
+		return quota, true
+	}
+	quota, ok := quotas["*"]
+	return quota, ok
+}
+
+// take spends a token of the bucket for key, refilled at quota.Max per quota.Per, and otherwise
+// returns how long until one is available
+func (q *sourceQuotas) take(key string, quota config.SourceQuota, now time.Time) (bool, time.Duration) {
+	if quota.Per <= 0 {
+		quota.Per = time.Hour
+	}
+	if quota.Max <= 0 {
+		return false, quota.Per
+	}
+	perToken := quota.Per / time.Duration(quota.Max)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	bucket, ok := q.buckets.Get(key)
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(quota.Max), updated: now}
+	}
+	q.buckets.SetWithTTL(key, bucket, quota.Per)
+	bucket.tokens = bucket.refilled(quota, perToken, now)
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) * float64(perToken))
+}
+
+// available reports whether the bucket for key has a token to spend, without spending it
+func (q *sourceQuotas) available(key string, quota config.SourceQuota, now time.Time) bool {
+	if quota.Per <= 0 {
+		quota.Per = time.Hour
+	}
+	if quota.Max <= 0 {
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	bucket, ok := q.buckets.Get(key)
+	return !ok || bucket.refilled(quota, quota.Per/time.Duration(quota.Max), now) >= 1
+}
+
+// refilled returns the bucket's tokens refilled up to now, without updating it
+func (b *tokenBucket) refilled(quota config.SourceQuota, perToken time.Duration, now time.Time) float64 {
+	return min(b.tokens+float64(now.Sub(b.updated))/float64(perToken), float64(quota.Max))
+}
+
+// alertQuota returns the quota the alert falls under and its bucket's key and quota key value,
+// reporting false when the alert has none
+func (d *Dispatcher) alertQuota(alertMsg *alert.Alert) (config.SourceQuota, string, string, bool) {
+	quota, ok := quotaFor(d.config.SourceQuotas, alertMsg.Source)
+	if !ok || alertMsg.IsResolved() {
+		return config.SourceQuota{}, "", "", false
+	}
+	var value string
+	if quota.Key != "" {
+		value = alertMsg.Lookup(quota.Key)
+	}
+	return quota, alertMsg.Source + "\x00" + value, value, true
+}
+
+// checkQuota holds the alert back with a QuotaExceededError when its source, or the part of it
+// the quota key names, has used up its quota. Resolutions neither need nor spend quota, so a
+// posted alert's resolution is never held back.
+func (d *Dispatcher) checkQuota(alertMsg *alert.Alert) error {
+	quota, key, value, ok := d.alertQuota(alertMsg)
+	if !ok {
+		return nil
+	}
+
+	allowed, retryAfter := d.quotas.take(key, quota, time.Now())
+	if allowed {
+		return nil
+	}
+	quotaRejections.Inc(alertMsg.Source)
+	log.Printf("Holding back %s alert %s, over the quota of %d per %s", alertMsg.Source, alertMsg.Name, quota.Max, quota.Per)
+	return &errs.QuotaExceededError{Source: alertMsg.Source, Key: value, RetryAfter: retryAfter}
+}
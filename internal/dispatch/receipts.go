@@ -0,0 +1,85 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+)
+
+// Receipt records what became of a dispatched alert, so that whoever sent it can confirm it
+// reached a channel, or learn why it didn't
+type Receipt struct {
+	AlertID string `json:"alert_id"`
+	Alert   string `json:"alert"`
+	Webhook string `json:"webhook,omitempty"` // the configured webhook it was sent to, if any
+	// Outcome is delivered, dropped (held back on purpose, see Reason) or failed (see Error)
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+	// Channel is where the alert was routed; MessageTS is the Slack message posted there, a
+	// reply under ThreadTS when it was threaded
+	Channel      string    `json:"channel,omitempty"`
+	Severity     string    `json:"severity,omitempty"`
+	MessageTS    string    `json:"message_ts,omitempty"`
+	ThreadTS     string    `json:"thread_ts,omitempty"`
+	ReceivedAt   time.Time `json:"received_at"`
+	DispatchedAt time.Time `json:"dispatched_at"`
+}
+
+// receiptKey is "receipt:<alert ID>"
+func receiptKey(alertID string) string {
+	return "receipt:" + alertID
+}
+
+// newReceipt starts the receipt of an alert about to be delivered
+func newReceipt(alertMsg *alert.Alert, alertID string) *Receipt {
+	return &Receipt{AlertID: alertID, Alert: alertMsg.Name, Webhook: alertWebhook(alertMsg), ReceivedAt: alertMsg.ReceivedAt}
+}
+
+// posted notes the Slack message the alert was posted as
+func (r *Receipt) posted(messageTS, threadTS string) {
+	r.MessageTS, r.ThreadTS = messageTS, threadTS
+}
+
+// saveReceipt completes the receipt with the outcome and keeps it as long as the alert is
+// archived
+func (d *Dispatcher) saveReceipt(ctx context.Context, alertMsg *alert.Alert, receipt *Receipt, outcome string, err error) {
+	receipt.Outcome = outcome
+	receipt.Channel = alertMsg.Channel
+	receipt.Severity = alertMsg.Severity
+	receipt.DispatchedAt = time.Now().UTC()
+	if err != nil {
+		receipt.Error = err.Error()
+		receipt.Code = errs.Code(err)
+	}
+
+	data, jsonErr := json.Marshal(receipt)
+	if jsonErr != nil {
+		log.Printf("Failed to encode receipt of alert %s: %v", receipt.AlertID, jsonErr)
+		return
+	}
+	// The alert's own context may be out of time by now
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := d.store.Set(ctx, receiptKey(receipt.AlertID), string(data), archiveTTL); err != nil {
+		log.Printf("Failed to save receipt of alert %s: %v", receipt.AlertID, err)
+	}
+}
+
+// Receipt returns the delivery receipt of an alert dispatched within the archive's retention
+func (d *Dispatcher) Receipt(ctx context.Context, alertID string) (Receipt, bool, error) {
+	value, ok, err := d.store.Get(ctx, receiptKey(alertID))
+	if err != nil || !ok {
+		return Receipt{}, false, err
+	}
+	var receipt Receipt
+	if err := json.Unmarshal([]byte(value), &receipt); err != nil {
+		return Receipt{}, false, err
+	}
+	return receipt, true, nil
+}
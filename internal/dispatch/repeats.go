@@ -0,0 +1,184 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+	"alert-dispatcher/internal/render"
+	"alert-dispatcher/internal/state"
+)
+
+// An alert that fires again within a day of its last firing, without resolving in between,
+// is a repeat
+const repeatTTL = 24 * time.Hour
+
+var repeatedAlerts = metrics.NewCounter("alert_dispatcher_repeated_alerts_total",
+	"Repeated firings posted as updates in the first firing's thread.", "channel")
+
+// firing is the first posted firing of an alert in a channel, and how many times it has fired
+type firing struct {
+	Count    int       `json:"count"`
+	ThreadTS string    `json:"thread_ts"` // the thread the first firing is in, or started
+	FiredAt  time.Time `json:"fired_at"`
+}
+
+// repeatTracker counts the firings of each alert fingerprint per channel in the state store, so
+// every replica sees them. The first firing is claimed, so only one replica posts it in full,
+// but replicas update counts without locking, so concurrent repeats may be counted once.
+type repeatTracker struct {
+	store state.Store
+}
+
+func newRepeatTracker(store state.Store) *repeatTracker {
+	return &repeatTracker{store: store}
+}
+
+// firingKey is "firing:<channel>|<fingerprint>"
+func firingKey(alertMsg *alert.Alert) string {
+	return "firing:" + alertMsg.Channel + "|" + alertMsg.Fingerprint()
+}
+
+// Get returns the alert's firing in its channel. State errors are logged and treated as no
+// firing, so the alert is posted in full.
+func (r *repeatTracker) Get(ctx context.Context, alertMsg *alert.Alert) (firing, bool) {
+	value, ok, err := r.store.Get(ctx, firingKey(alertMsg))
+	if err != nil {
+		log.Printf("Failed to look up earlier firings of %s: %v", alertMsg.Name, err)
+		return firing{}, false
+	}
+	if !ok {
+		return firing{}, false
+	}
+
+	var f firing
+	if err := json.Unmarshal([]byte(value), &f); err != nil {
+		log.Printf("Failed to decode earlier firings of %s: %v", alertMsg.Name, err)
+		return firing{}, false
+	}
+	return f, true
+}
+
+// Claim records f as the alert's first firing in its channel unless one is already recorded,
+// which it returns. State errors are logged and treated as the first firing, so the alert is
+// posted in full.
+func (r *repeatTracker) Claim(ctx context.Context, alertMsg *alert.Alert, f firing) (firing, bool) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		log.Printf("Failed to encode firings of %s: %v", alertMsg.Name, err)
+		return f, true
+	}
+	first, err := r.store.SetNX(ctx, firingKey(alertMsg), string(data), repeatTTL)
+	if err != nil {
+		log.Printf("Failed to claim the first firing of %s: %v", alertMsg.Name, err)
+		return f, true
+	}
+	if first {
+		return f, true
+	}
+	if earlier, ok := r.Get(ctx, alertMsg); ok {
+		return earlier, false
+	}
+	return f, true
+}
+
+func (r *repeatTracker) Set(ctx context.Context, alertMsg *alert.Alert, f firing) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		log.Printf("Failed to encode firings of %s: %v", alertMsg.Name, err)
+		return
+	}
+	if err := r.store.Set(ctx, firingKey(alertMsg), string(data), repeatTTL); err != nil {
+		log.Printf("Failed to save firings of %s: %v", alertMsg.Name, err)
+	}
+}
+
+// Clear forgets the alert's firings once it resolves, so the next one is posted in full
+func (r *repeatTracker) Clear(ctx context.Context, alertMsg *alert.Alert) {
+	if err := r.store.Delete(ctx, firingKey(alertMsg)); err != nil {
+		log.Printf("Failed to clear firings of %s: %v", alertMsg.Name, err)
+	}
+}
+
+// tracksRepeats reports whether the route posts repeated firings as thread updates. Raw and
+// non-Slack routes get every firing in full, since bots read them.
+func tracksRepeats(route config.RouteConfig) bool {
+	return route.Repeats == config.RepeatsThread && route.Format != config.FormatRaw && route.Renderer == render.NameSlack
+}
+
+// repeatOf returns the alert's firing counting this one, or nil when the alert isn't a repeat.
+// It reports whether it claimed the alert's first firing, which is then posted in full.
+func (d *Dispatcher) repeatOf(ctx context.Context, alertMsg *alert.Alert, route config.RouteConfig) (*firing, bool) {
+	if alertMsg.Status != alert.StatusFiring || !tracksRepeats(route) {
+		return nil, false
+	}
+	f, first := d.repeats.Claim(ctx, alertMsg, firing{Count: 1, FiredAt: time.Now()})
+	if first {
+		return nil, true
+	}
+	f.Count++
+	return &f, false
+}
+
+// postRepeat posts a repeated firing as a one-line update in the first firing's thread, colored
+// by how long the alert has been firing. While another replica is still posting the first
+// firing, the update goes to the channel.
+func (d *Dispatcher) postRepeat(ctx context.Context, alertMsg *alert.Alert, f firing) error {
+	age := firingFor(alertMsg, f)
+	if err := d.slackNotifier(alertMsg.Channel).InThread(f.ThreadTS).NotifyColoredText(ctx, repeatUpdate(alertMsg, f.Count, age), agingOf(age).color); err != nil {
+		return fmt.Errorf("failed to post repeat of %s: %v", alertMsg.Name, err)
+	}
+	d.repeats.Set(ctx, alertMsg, f)
+	repeatedAlerts.Inc(alertMsg.Channel)
+	return nil
+}
+
+// firstFiring remembers the thread a firing alert was posted in, so its repeats go there
+func (d *Dispatcher) firstFiring(ctx context.Context, alertMsg *alert.Alert, route config.RouteConfig, threadTS string) {
+	if alertMsg.Status != alert.StatusFiring || !tracksRepeats(route) || threadTS == "" {
+		return
+	}
+	// Repeats may have been counted while the first firing was being posted
+	f, ok := d.repeats.Get(ctx, alertMsg)
+	if !ok {
+		f = firing{Count: 1, FiredAt: time.Now()}
+	}
+	f.ThreadTS = threadTS
+	d.repeats.Set(ctx, alertMsg, f)
+}
+
+// repeatUpdate is e.g. "🟠 *High CPU* still firing after 1h 5m, 4th notification, value now *93*",
+// or without the age when it isn't known
+func repeatUpdate(alertMsg *alert.Alert, count int, age time.Duration) string {
+	text := fmt.Sprintf("%s *%s* still firing", agingOf(age).emoji, alertMsg.Name)
+	if age > 0 {
+		text += " after " + formatAge(age)
+	}
+	text += fmt.Sprintf(", %s notification", ordinal(count))
+	if value := alertMsg.Annotations[alert.AnnotationValue]; value != "" {
+		text += fmt.Sprintf(", value now *%s*", value)
+	}
+	return text
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 100 {
+	case 11, 12, 13:
+	default:
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -0,0 +1,230 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+)
+
+var sampledAlerts = metrics.NewCounter("alert_dispatcher_sampled_alerts_total",
+	"Alerts of a rule firing over its route's sampling threshold that weren't posted, by channel.", "channel")
+
+const (
+	// defaultSampleEvery posts one in this many firings of a sampled rule
+	defaultSampleEvery = 10
+	// defaultSampleWindow is how often a sampled rule's summary is posted
+	defaultSampleWindow = 10 * time.Minute
+)
+
+// sampleRate counts a rule's firings in the current minute, and holds its sampling window
+// while it's over the threshold
+type sampleRate struct {
+	minute  time.Time
+	firings int
+	window  *sampleWindow
+}
+
+// sampleWindow counts the alerts of a sampled rule until its summary is posted
+type sampleWindow struct {
+	name     string
+	start    time.Time
+	sampling config.SamplingConfig
+	firings  int
+	resolved int
+	posted   int
+	values   map[string]struct{} // distinct count_by values, or fingerprints without it
+	timer    *time.Timer
+}
+
+// sampler samples the alerts of rules firing faster than their route's sampling threshold. While
+// a rule is sampled one in every few firings is posted, and when each window ends the channel
+// gets one message counting all of them.
+type sampler struct {
+	mu      sync.Mutex
+	rates   map[string]*sampleRate // by channel and alert name
+	summary func(channel string, w *sampleWindow)
+}
+
+func newSampler(summary func(channel string, w *sampleWindow)) *sampler {
+	return &sampler{rates: make(map[string]*sampleRate), summary: summary}
+}
+
+// allow reports whether the alert may be posted to its channel, counting it in its rule's
+// window if the rule is sampled, and returns the window when the alert started it
+func (s *sampler) allow(alertMsg *alert.Alert, sampling config.SamplingConfig, now time.Time) (bool, *sampleWindow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := alertMsg.Channel + "|" + alertMsg.Name
+	rate, ok := s.rates[key]
+	if !ok {
+		rate = &sampleRate{minute: now}
+		s.rates[key] = rate
+	}
+	if now.Sub(rate.minute) >= time.Minute {
+		rate.minute, rate.firings = now, 0
+	}
+	if !alertMsg.IsResolved() {
+		rate.firings++
+	}
+
+	var started *sampleWindow
+	w := rate.window
+	if w == nil {
+		if rate.firings <= sampling.Threshold {
+			return true, nil
+		}
+		w = &sampleWindow{name: alertMsg.Name, start: now, sampling: sampling, values: make(map[string]struct{})}
+		rate.window, started = w, w
+		channel := alertMsg.Channel
+		w.timer = time.AfterFunc(sampling.Window, func() {
+			s.flush(key, channel, w)
+		})
+	}
+
+	if alertMsg.IsResolved() {
+		w.resolved++
+		return false, nil
+	}
+	value := alertMsg.Fingerprint()
+	if sampling.CountBy != "" {
+		value = alertMsg.Lookup(sampling.CountBy)
+	}
+	w.values[value] = struct{}{}
+	w.firings++
+	if (w.firings-1)%sampling.Every != 0 {
+		return false, started
+	}
+	w.posted++
+	return true, started
+}
+
+// wouldAllow reports whether allow would let the alert through now, without counting it
+func (s *sampler) wouldAllow(alertMsg *alert.Alert, sampling config.SamplingConfig) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Outside a window alerts are posted, and a firing crossing the threshold is the first of
+	// the window it starts, which is posted too
+	rate, ok := s.rates[alertMsg.Channel+"|"+alertMsg.Name]
+	if !ok || rate.window == nil {
+		return true
+	}
+	return !alertMsg.IsResolved() && rate.window.firings%sampling.Every == 0
+}
+
+// flush summarizes the window and ends the rule's sampling, unless another flush already did
+func (s *sampler) flush(key, channel string, w *sampleWindow) {
+	s.mu.Lock()
+	if rate, ok := s.rates[key]; ok && rate.window == w {
+		rate.window = nil
+	}
+	if w.timer == nil {
+		s.mu.Unlock()
+		return
+	}
+	w.timer = nil
+	s.mu.Unlock()
+
+	s.summary(channel, w)
+}
+
+// close posts the summaries of windows that haven't ended yet
+func (s *sampler) close() {
+	s.mu.Lock()
+	pending := make(map[string]*sampleWindow)
+	for key, rate := range s.rates {
+		if w := rate.window; w != nil && w.timer != nil && w.timer.Stop() {
+			pending[key] = w
+		}
+	}
+	s.mu.Unlock()
+
+	for key, w := range pending {
+		channel, _, _ := strings.Cut(key, "|")
+		s.flush(key, channel, w)
+	}
+}
+
+// samplingFor returns the route's sampling with defaults filled in, reporting false without
+// one
+func samplingFor(route config.RouteConfig) (config.SamplingConfig, bool) {
+	if route.Sampling == nil || route.Sampling.Threshold <= 0 {
+		return config.SamplingConfig{}, false
+	}
+	sampling := *route.Sampling
+	if sampling.Every <= 0 {
+		sampling.Every = defaultSampleEvery
+	}
+	if sampling.Window <= 0 {
+		sampling.Window = defaultSampleWindow
+	}
+	return sampling, true
+}
+
+// sampled reports whether the route's sampling holds the alert back, telling the channel when
+// its rule starts being sampled
+func (d *Dispatcher) sampled(ctx context.Context, alertMsg *alert.Alert, route config.RouteConfig) bool {
+	sampling, ok := samplingFor(route)
+	if !ok {
+		return false
+	}
+
+	allowed, started := d.sampler.allow(alertMsg, sampling, time.Now())
+	if started != nil {
+		log.Printf("Sampling alert %s in %s, over %d firings a minute", alertMsg.Name, alertMsg.Channel, sampling.Threshold)
+		text := fmt.Sprintf("🎲 *%s* is firing over %d times a minute, posting 1 in %d of its alerts with a summary every %s",
+			alertMsg.Name, sampling.Threshold, sampling.Every, sampling.Window)
+		if err := d.slackNotifier(alertMsg.Channel).NotifyText(ctx, text); err != nil {
+			log.Printf("Failed to post sampling notice to %s: %v", alertMsg.Channel, err)
+		}
+	}
+	if !allowed {
+		sampledAlerts.Inc(alertMsg.Channel)
+	}
+	return !allowed
+}
+
+// postSampleSummary tells the channel how often a sampled rule fired in a window
+func (d *Dispatcher) postSampleSummary(channel string, w *sampleWindow) {
+	elapsed := time.Since(w.start).Round(time.Minute)
+	if elapsed < time.Minute {
+		elapsed = time.Minute
+	}
+	across := "alerts"
+	if w.sampling.CountBy != "" {
+		across = w.sampling.CountBy + "s"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🎲 *%s firings of %s in the last %s* across %s %s",
+		formatThousands(w.firings), w.name, strings.TrimSuffix(elapsed.String(), "0s"), formatThousands(len(w.values)), across)
+	fmt.Fprintf(&sb, "\n• %s posted (1 in %d)", formatThousands(w.posted), w.sampling.Every)
+	if w.resolved > 0 {
+		fmt.Fprintf(&sb, ", %s resolutions not posted", formatThousands(w.resolved))
+	}
+
+	if err := d.slackNotifier(channel).NotifyText(context.Background(), sb.String()); err != nil {
+		log.Printf("Failed to post sampling summary to %s: %v", channel, err)
+	}
+}
+
+// formatThousands renders n with comma separators, e.g. 1,243
+func formatThousands(n int) string {
+	digits := fmt.Sprint(n)
+	var sb strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(digit)
+	}
+	return sb.String()
+}
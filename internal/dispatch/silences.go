@@ -0,0 +1,103 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+)
+
+var silencedAlerts = metrics.NewCounter("alert_dispatcher_silenced_alerts_total",
+	"Alerts held back by silences.", "silence")
+
+// silence is a config.Silence with its name regex compiled
+type silence struct {
+	config.Silence
+	nameRe *regexp.Regexp
+}
+
+func compileSilences(silences []config.Silence) []silence {
+	var compiled []silence
+	for _, s := range silences {
+		c := silence{Silence: s}
+		if s.NameRegex != "" {
+			re, err := regexp.Compile(s.NameRegex)
+			if err != nil {
+				log.Printf("Skipping silence %s: invalid name regex: %v", s.Name, err)
+				continue
+			}
+			c.nameRe = re
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled
+}
+
+func (s silence) matches(alertMsg *alert.Alert, now time.Time) bool {
+	if !s.Active(now) {
+		return false
+	}
+	if s.Source != "" && !strings.EqualFold(s.Source, alertMsg.Source) {
+		return false
+	}
+	if len(s.Channels) > 0 && !slices.Contains(s.Channels, alertMsg.Channel) {
+		return false
+	}
+	if s.nameRe != nil && !s.nameRe.MatchString(alertMsg.Name) {
+		return false
+	}
+	for k, v := range s.Labels {
+		if alertMsg.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// silenced returns the first active silence matching the alert, AlertSilences before API
+// silences, counting it
+func (d *Dispatcher) silenced(ctx context.Context, alertMsg *alert.Alert) (string, bool) {
+	if silencedBy := d.silencedBy(ctx, alertMsg, true); len(silencedBy) > 0 {
+		silencedAlerts.Inc(silencedBy[0])
+		return silencedBy[0], true
+	}
+	return "", false
+}
+
+// silencedBy returns the names of the AlertSilences and IDs of the API silences that match the
+// alert now, or only the first when first is set
+func (d *Dispatcher) silencedBy(ctx context.Context, alertMsg *alert.Alert, first bool) []string {
+	d.reloadMu.RLock()
+	silences := d.silences
+	d.reloadMu.RUnlock()
+
+	var matched []string
+	now := time.Now()
+	for _, s := range silences {
+		if s.matches(alertMsg, now) {
+			if matched = append(matched, s.Name); first {
+				return matched
+			}
+		}
+	}
+
+	apiSilences := d.apiSilences.current(ctx)
+	if len(apiSilences) == 0 {
+		return matched
+	}
+	labels := AlertLabels(alertMsg)
+	for _, s := range apiSilences {
+		if s.matches(labels, now) {
+			if matched = append(matched, s.ID); first {
+				return matched
+			}
+		}
+	}
+	return matched
+}
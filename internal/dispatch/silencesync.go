@@ -0,0 +1,280 @@
+package dispatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/httpclient"
+)
+
+// silenceSync creates silences in Grafana and Alertmanager for alerts acknowledged or silenced
+// in Slack, through the silence links of their payloads
+type silenceSync struct {
+	acknowledge       time.Duration
+	grafanaURL        string // base URL silences of Grafana alerts may be created under
+	alertmanagerURL   string // base URL silences of Alertmanager alerts may be created under
+	grafanaToken      string
+	alertmanagerToken string
+	client            *http.Client
+}
+
+func newSilenceSync(cfg *config.SilenceSyncConfig) *silenceSync {
+	if cfg == nil {
+		return nil
+	}
+	log.Printf("Syncing silences to Grafana and Alertmanager")
+	return &silenceSync{
+		acknowledge:       cfg.Acknowledge,
+		grafanaURL:        strings.TrimRight(cfg.GrafanaURL, "/"),
+		alertmanagerURL:   strings.TrimRight(cfg.AlertmanagerURL, "/"),
+		grafanaToken:      cfg.GrafanaToken,
+		alertmanagerToken: cfg.AlertmanagerToken,
+		client:            httpclient.New(cfg.Timeout),
+	}
+}
+
+// upstreamSilence is a silence to create through a Grafana or Alertmanager silences API
+type upstreamSilence struct {
+	api      string // the silences endpoint
+	orgID    string // Grafana's organization, if the link names one
+	grafana  bool
+	matchers []Matcher
+}
+
+// parseSilenceLink reads the silences API and matchers from a silence link: Grafana's
+// .../alerting/silence/new?alertmanager=<name>&matcher=name=value, or Alertmanager's
+// .../#/silences/new?filter={name="value",...}
+func parseSilenceLink(link string) (upstreamSilence, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return upstreamSilence{}, err
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	if base, ok := strings.CutSuffix(u.Path, "/alerting/silence/new"); ok {
+		query := u.Query()
+		alertmanager := query.Get("alertmanager")
+		if alertmanager == "" {
+			alertmanager = "grafana"
+		}
+		silence := upstreamSilence{
+			api:     origin + base + "/api/alertmanager/" + url.PathEscape(alertmanager) + "/api/v2/silences",
+			orgID:   query.Get("orgId"),
+			grafana: true,
+		}
+		for _, text := range query["matcher"] {
+			m, err := ParseMatcher(text)
+			if err != nil {
+				return upstreamSilence{}, err
+			}
+			silence.matchers = append(silence.matchers, m)
+		}
+		return silence, checkUpstreamMatchers(silence)
+	}
+
+	if fragment, ok := strings.CutPrefix(u.Fragment, "/silences/new"); ok {
+		query, err := url.ParseQuery(strings.TrimPrefix(fragment, "?"))
+		if err != nil {
+			return upstreamSilence{}, err
+		}
+		silence := upstreamSilence{api: origin + strings.TrimSuffix(u.Path, "/") + "/api/v2/silences"}
+		filter := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(query.Get("filter")), "{"), "}")
+		for _, text := range splitMatchers(filter) {
+			m, err := ParseMatcher(text)
+			if err != nil {
+				return upstreamSilence{}, err
+			}
+			silence.matchers = append(silence.matchers, m)
+		}
+		return silence, checkUpstreamMatchers(silence)
+	}
+	return upstreamSilence{}, fmt.Errorf("%s is not a Grafana or Alertmanager silence link", link)
+}
+
+// checkUpstreamMatchers refuses silences without matchers, which would silence everything
+func checkUpstreamMatchers(silence upstreamSilence) error {
+	if len(silence.matchers) == 0 {
+		return fmt.Errorf("silence link has no matchers")
+	}
+	return nil
+}
+
+// splitMatchers splits an Alertmanager filter on the commas outside quoted values
+func splitMatchers(filter string) []string {
+	var matchers []string
+	var quoted, escaped bool
+	start := 0
+	for i, r := range filter {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			matchers = append(matchers, filter[start:i])
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(filter[start:]); rest != "" {
+		matchers = append(matchers, rest)
+	}
+	return matchers
+}
+
+// allowed reports whether the silences API is under the configured Grafana or Alertmanager URL
+// for its kind of link. Links come from alert payloads, which anyone may be able to post, so
+// the tokens are only ever sent to the configured hosts.
+func (s *silenceSync) allowed(silence upstreamSilence) bool {
+	base := s.alertmanagerURL
+	if silence.grafana {
+		base = s.grafanaURL
+	}
+	if base == "" {
+		return false
+	}
+	api, err := url.Parse(silence.api)
+	if err != nil {
+		return false
+	}
+	allowed, err := url.Parse(base)
+	if err != nil {
+		return false
+	}
+	return api.Scheme == allowed.Scheme && api.Host == allowed.Host &&
+		(api.Path == allowed.Path || strings.HasPrefix(api.Path, allowed.Path+"/"))
+}
+
+// create posts a silence for the alert behind the link, lasting duration
+func (s *silenceSync) create(ctx context.Context, link string, duration time.Duration, createdBy, comment string) error {
+	silence, err := parseSilenceLink(link)
+	if err != nil {
+		return err
+	}
+	if !s.allowed(silence) {
+		return fmt.Errorf("%s isn't under silence_sync's grafana_url or alertmanager_url", silence.api)
+	}
+	now := time.Now().UTC()
+	body, err := json.Marshal(struct {
+		Matchers  []Matcher `json:"matchers"`
+		StartsAt  time.Time `json:"startsAt"`
+		EndsAt    time.Time `json:"endsAt"`
+		CreatedBy string    `json:"createdBy"`
+		Comment   string    `json:"comment"`
+	}{silence.matchers, now, now.Add(duration), createdBy, comment})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, silence.api, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token := s.alertmanagerToken
+	if silence.grafana {
+		token = s.grafanaToken
+		if silence.orgID != "" {
+			req.Header.Set("X-Grafana-Org-Id", silence.orgID)
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", silence.api, resp.Status)
+	}
+	return nil
+}
+
+// silenceUpstream silences an alert with a silence link in Grafana or Alertmanager, in the
+// background so the Slack action isn't held up. Failures are logged and counted.
+func (d *Dispatcher) silenceUpstream(name, link string, duration time.Duration, user, comment string) bool {
+	if d.silenceSync == nil || link == "" || duration <= 0 {
+		return false
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), d.silenceSync.client.Timeout)
+		defer cancel()
+		if err := d.silenceSync.create(ctx, link, duration, user, comment); err != nil {
+			log.Printf("Failed to silence %s upstream: %v", name, err)
+			notifierErrors.Inc("silence_sync")
+			return
+		}
+		log.Printf("Silenced %s upstream for %s, for %s", name, user, duration)
+	}()
+	return true
+}
+
+// acknowledgedUpstream silences an acknowledged alert upstream for silence_sync's acknowledge
+// duration, if set
+func (d *Dispatcher) acknowledgedUpstream(action string, open openAlert, user string) {
+	if action != "acknowledge" || d.silenceSync == nil {
+		return
+	}
+	d.silenceUpstream(open.Name, open.SilenceURL, d.silenceSync.acknowledge, user, "Acknowledged in Slack by "+user)
+}
+
+// SilenceMatching silences every open alert whose name matches a glob pattern such as
+// "payments-*" for duration: here, by alertname and channel, and, with silence sync, in
+// Grafana or Alertmanager too. It returns the names silenced and how many were silenced
+// upstream.
+func (d *Dispatcher) SilenceMatching(ctx context.Context, pattern string, duration time.Duration, user string) ([]string, int, error) {
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, 0, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	if duration <= 0 {
+		return nil, 0, fmt.Errorf("the duration must be positive")
+	}
+
+	var names []string
+	upstream := 0
+	silenced := make(map[string]bool) // by name and channel
+	for _, open := range d.open.matching(ctx, func(open openAlert) bool {
+		matched, _ := path.Match(pattern, strings.ToLower(open.Name))
+		return matched
+	}) {
+		key := open.Name + "|" + open.Channel
+		if silenced[key] {
+			continue
+		}
+		silenced[key] = true
+
+		comment := "Silenced in Slack by " + user
+		isEqual := true
+		_, err := d.PutSilence(ctx, APISilence{
+			Matchers: []Matcher{
+				{Name: "alertname", Value: open.Name, IsEqual: &isEqual},
+				{Name: "channel", Value: open.Channel, IsEqual: &isEqual},
+			},
+			EndsAt:    time.Now().Add(duration),
+			CreatedBy: user,
+			Comment:   comment,
+		})
+		if err != nil {
+			return names, upstream, err
+		}
+		names = append(names, open.Name)
+		if d.silenceUpstream(open.Name, open.SilenceURL, duration, user, comment) {
+			upstream++
+		}
+	}
+	return names, upstream, nil
+}
@@ -0,0 +1,61 @@
+package dispatch
+
+import (
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/sink"
+)
+
+// Sinks receive events in batches of this size, or whatever has queued after the interval
+const (
+	sinkBatchSize     = 100
+	sinkFlushInterval = 5 * time.Second
+)
+
+// newSinks creates a batcher for every sink enabled in the config
+func newSinks(cfg *config.Config) []*sink.Batcher {
+	var sinks []*sink.Batcher
+	if cfg.OpenSearch.URL != "" {
+		log.Printf("Indexing alert events into OpenSearch at %s", cfg.OpenSearch.URL)
+		openSearch := sink.NewOpenSearchSink(cfg.OpenSearch.URL, cfg.OpenSearch.IndexPrefix,
+			cfg.OpenSearch.Username, cfg.OpenSearch.Password, cfg.OpenSearch.Timeout)
+		sinks = append(sinks, sink.NewBatcher(openSearch, sinkBatchSize, sinkFlushInterval))
+	}
+	if cfg.ClickHouse.URL != "" {
+		log.Printf("Inserting alert events into ClickHouse table %s at %s", cfg.ClickHouse.Table, cfg.ClickHouse.URL)
+		clickHouse := sink.NewClickHouseSink(cfg.ClickHouse.URL, cfg.ClickHouse.Table,
+			cfg.ClickHouse.Username, cfg.ClickHouse.Password, cfg.ClickHouse.Timeout)
+		sinks = append(sinks, sink.NewBatcher(clickHouse, sinkBatchSize, sinkFlushInterval))
+	}
+	return sinks
+}
+
+// AddSink hands every alert event to s as well as the sinks enabled in the config, in batches
+// like theirs
+func (d *Dispatcher) AddSink(s sink.Sink) {
+	log.Printf("Writing alert events to the %s sink", s.Name())
+	d.sinks = append(d.sinks, sink.NewBatcher(s, sinkBatchSize, sinkFlushInterval))
+}
+
+// recordOutcome hands the alert and what happened to it to every sink
+func (d *Dispatcher) recordOutcome(alertMsg *alert.Alert, alertID, outcome string, err error) {
+	if len(d.sinks) == 0 {
+		return
+	}
+
+	event := sink.Event{
+		AlertID:      alertID,
+		Alert:        alertMsg,
+		Outcome:      outcome,
+		DispatchedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	for _, s := range d.sinks {
+		s.Add(event)
+	}
+}
@@ -0,0 +1,241 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/audit"
+	"alert-dispatcher/internal/config"
+)
+
+// CloudWatch alarms whose actions are disabled by snoozes are kept under
+// snooze:<region>|<alarm>, one key each. Older versions kept them all in the legacy document,
+// which is moved to those keys when it is next read.
+const (
+	alarmSnoozePrefix     = "snooze:"
+	legacyAlarmSnoozesKey = "cloudwatch:snoozed"
+)
+
+// AlarmActions changes CloudWatch alarms; *cwalarms.Client implements it
+type AlarmActions interface {
+	DisableAlarmActions(ctx context.Context, region, alarmName string) error
+	EnableAlarmActions(ctx context.Context, region, alarmName string) error
+	SetAlarmState(ctx context.Context, region, alarmName, stateValue, reason string) error
+}
+
+// alarmSnooze is a CloudWatch alarm whose actions are disabled until a snooze ends
+type alarmSnooze struct {
+	Region string    `json:"region"`
+	Alarm  string    `json:"alarm"`
+	Until  time.Time `json:"until"`
+}
+
+// UseAlarmActions lets snoozing CloudWatch alerts change their alarms as cloudwatch_snooze says
+func (d *Dispatcher) UseAlarmActions(actions AlarmActions) {
+	log.Printf("Snoozing CloudWatch alerts with %s", d.config.CloudWatchSnooze.Action)
+	d.alarmActions = actions
+}
+
+// Snooze holds back an alert, by alertname and channel, for duration and, with
+// cloudwatch_snooze, pauses its CloudWatch alarm in the background. It returns the alert's name
+// and whether its alarm is being paused.
+func (d *Dispatcher) Snooze(ctx context.Context, alertID string, duration time.Duration, user string) (string, bool, error) {
+	record, ok, err := d.archive.Get(ctx, alertID)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok || record.Alert == nil {
+		return "", false, fmt.Errorf("alert %s is no longer archived", alertID)
+	}
+	alertMsg := record.Alert
+	until := time.Now().Add(duration)
+
+	isEqual := true
+	_, err = d.PutSilence(ctx, APISilence{
+		Matchers: []Matcher{
+			{Name: "alertname", Value: alertMsg.Name, IsEqual: &isEqual},
+			{Name: "channel", Value: alertMsg.Channel, IsEqual: &isEqual},
+		},
+		EndsAt:    until,
+		CreatedBy: user,
+		Comment:   "Snoozed in Slack by " + user,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	audit.Record(audit.Entry{Action: "snooze", AlertID: alertID, AlertName: alertMsg.Name, User: user, Via: audit.ViaButton, Detail: duration.String()})
+
+	return alertMsg.Name, d.pauseAlarm(alertMsg, until, user), nil
+}
+
+// pauseAlarm disables the actions of a CloudWatch alert's alarm until the snooze ends, or sets
+// it to OK, in the background so the Slack action isn't held up
+func (d *Dispatcher) pauseAlarm(alertMsg *alert.Alert, until time.Time, user string) bool {
+	arn, _ := alertMsg.Extensions["alarm_arn"].(string)
+	// arn:aws:cloudwatch:eu-west-1:123456789012:alarm:HighCPU
+	parts := strings.SplitN(arn, ":", 7)
+	if d.alarmActions == nil || alertMsg.Source != alert.SourceCloudWatch || len(parts) < 7 {
+		return false
+	}
+	region, name := parts[3], alertMsg.Name
+	action := d.config.CloudWatchSnooze.Action
+
+	go func() {
+		ctx := context.Background()
+		var err error
+		switch action {
+		case config.CloudWatchSetState:
+			reason := fmt.Sprintf("Snoozed in Slack by %s until %s", user, until.UTC().Format("15:04 UTC"))
+			err = d.alarmActions.SetAlarmState(ctx, region, name, "OK", reason)
+		default:
+			if err = d.alarmActions.DisableAlarmActions(ctx, region, name); err == nil {
+				err = d.addAlarmSnooze(ctx, alarmSnooze{Region: region, Alarm: name, Until: until})
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to %s CloudWatch alarm %s: %v", strings.ReplaceAll(action, "_", " "), name, err)
+			notifierErrors.Inc("cloudwatch")
+			return
+		}
+		log.Printf("Snoozed CloudWatch alarm %s with %s until %s", name, action, until.UTC().Format(time.RFC3339))
+	}()
+	return true
+}
+
+// alarmSnoozeKey is where the snooze of a region's alarm is kept
+func alarmSnoozeKey(region, alarm string) string {
+	return alarmSnoozePrefix + region + "|" + alarm
+}
+
+// addAlarmSnooze records an alarm to re-enable, keeping the later end when it is already snoozed
+func (d *Dispatcher) addAlarmSnooze(ctx context.Context, snooze alarmSnooze) error {
+	if existing, ok, err := d.alarmSnooze(ctx, snooze.Region, snooze.Alarm); err != nil {
+		return err
+	} else if ok && existing.Until.After(snooze.Until) {
+		return nil
+	}
+	return d.saveAlarmSnooze(ctx, snooze)
+}
+
+// alarmSnooze reads the snooze of one alarm
+func (d *Dispatcher) alarmSnooze(ctx context.Context, region, alarm string) (alarmSnooze, bool, error) {
+	value, ok, err := d.store.Get(ctx, alarmSnoozeKey(region, alarm))
+	if err != nil || !ok {
+		return alarmSnooze{}, false, err
+	}
+	var snooze alarmSnooze
+	if err := json.Unmarshal([]byte(value), &snooze); err != nil {
+		return alarmSnooze{}, false, fmt.Errorf("failed to decode snooze of %s: %v", alarm, err)
+	}
+	return snooze, true, nil
+}
+
+// saveAlarmSnooze writes a snooze under its own key, so replicas snoozing different alarms
+// don't overwrite each other. It is kept well past its end, so a replica restarting meanwhile
+// still re-enables the alarm.
+func (d *Dispatcher) saveAlarmSnooze(ctx context.Context, snooze alarmSnooze) error {
+	data, err := json.Marshal(snooze)
+	if err != nil {
+		return fmt.Errorf("failed to encode snooze of %s: %v", snooze.Alarm, err)
+	}
+	ttl := time.Until(snooze.Until) + archiveTTL
+	return d.store.Set(ctx, alarmSnoozeKey(snooze.Region, snooze.Alarm), string(data), ttl)
+}
+
+// alarmSnoozes reads every snoozed alarm, moving any left in the legacy document to their own
+// keys first
+func (d *Dispatcher) alarmSnoozes(ctx context.Context) ([]alarmSnooze, error) {
+	if err := d.migrateAlarmSnoozes(ctx); err != nil {
+		return nil, err
+	}
+	values, err := d.store.Scan(ctx, alarmSnoozePrefix)
+	if err != nil {
+		return nil, err
+	}
+	snoozes := make([]alarmSnooze, 0, len(values))
+	for key, value := range values {
+		var snooze alarmSnooze
+		if err := json.Unmarshal([]byte(value), &snooze); err != nil {
+			log.Printf("Skipping snooze %s: failed to decode: %v", strings.TrimPrefix(key, alarmSnoozePrefix), err)
+			continue
+		}
+		snoozes = append(snoozes, snooze)
+	}
+	return snoozes, nil
+}
+
+// migrateAlarmSnoozes moves the snoozes of the legacy document to their own keys
+func (d *Dispatcher) migrateAlarmSnoozes(ctx context.Context) error {
+	value, ok, err := d.store.Get(ctx, legacyAlarmSnoozesKey)
+	if err != nil || !ok {
+		return err
+	}
+	var snoozes []alarmSnooze
+	if err := json.Unmarshal([]byte(value), &snoozes); err != nil {
+		return fmt.Errorf("failed to decode snoozed alarms: %v", err)
+	}
+	for _, snooze := range snoozes {
+		if err := d.addAlarmSnooze(ctx, snooze); err != nil {
+			return err
+		}
+	}
+	return d.store.Delete(ctx, legacyAlarmSnoozesKey)
+}
+
+// RunAlarmSnoozes re-enables the actions of snoozed CloudWatch alarms once their snoozes end,
+// checking every minute until ctx is done
+func (d *Dispatcher) RunAlarmSnoozes(ctx context.Context) {
+	if d.alarmActions == nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.endAlarmSnoozes(ctx, now)
+		}
+	}
+}
+
+// endAlarmSnoozes re-enables the alarms whose snoozes have ended, keeping those that failed to
+// be retried a minute later
+func (d *Dispatcher) endAlarmSnoozes(ctx context.Context, now time.Time) {
+	snoozes, err := d.alarmSnoozes(ctx)
+	if err != nil {
+		log.Printf("Failed to read snoozed CloudWatch alarms: %v", err)
+		return
+	}
+	for _, s := range snoozes {
+		if now.Before(s.Until) {
+			continue
+		}
+		if err := d.alarmActions.EnableAlarmActions(ctx, s.Region, s.Alarm); err != nil {
+			log.Printf("Failed to re-enable actions of CloudWatch alarm %s: %v", s.Alarm, err)
+			notifierErrors.Inc("cloudwatch")
+			continue
+		}
+		log.Printf("Re-enabled actions of CloudWatch alarm %s after its snooze", s.Alarm)
+		d.clearAlarmSnooze(ctx, s)
+	}
+}
+
+// clearAlarmSnooze forgets an ended snooze, unless the alarm was snoozed again meanwhile
+func (d *Dispatcher) clearAlarmSnooze(ctx context.Context, ended alarmSnooze) {
+	current, ok, err := d.alarmSnooze(ctx, ended.Region, ended.Alarm)
+	if err == nil && ok && current.Until.After(ended.Until) {
+		return
+	}
+	if err := d.store.Delete(ctx, alarmSnoozeKey(ended.Region, ended.Alarm)); err != nil {
+		log.Printf("Failed to forget snooze of CloudWatch alarm %s: %v", ended.Alarm, err)
+	}
+}
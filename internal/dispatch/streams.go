@@ -0,0 +1,40 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/notifier"
+)
+
+// stream receives every alert the dispatcher delivers, for consumers outside Slack
+type stream struct {
+	name     string
+	notifier notifier.AlertNotifier
+}
+
+// AddStream republishes every delivered alert through n, e.g. to an SNS topic. Streams are
+// created by the caller since their backends need credentials; add them before dispatching.
+func (d *Dispatcher) AddStream(name string, n notifier.AlertNotifier) {
+	log.Printf("Republishing delivered alerts to the %s stream", name)
+	d.streams = append(d.streams, stream{name: name, notifier: n})
+}
+
+// publish hands the alert to every stream concurrently. Like targets, failures are logged and
+// counted rather than failing the Slack delivery.
+func (d *Dispatcher) publish(ctx context.Context, alertMsg *alert.Alert, alertID string) {
+	var wg sync.WaitGroup
+	for _, s := range d.streams {
+		wg.Add(1)
+		go func(s stream) {
+			defer wg.Done()
+			if err := s.notifier.NotifyAlert(ctx, alertMsg, alertID); err != nil {
+				log.Printf("Failed to publish %s to the %s stream: %v", alertMsg.Name, s.name, err)
+				notifierErrors.Inc(s.name)
+			}
+		}(s)
+	}
+	wg.Wait()
+}
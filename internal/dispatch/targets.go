@@ -0,0 +1,42 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+)
+
+// fanOut delivers the alert to its targets concurrently, each within its own timeout when it has
+// one. Slack remains the primary destination, so target failures are logged and counted rather
+// than failing the delivery.
+func (d *Dispatcher) fanOut(ctx context.Context, alertMsg *alert.Alert, alertID string, targets []config.TargetConfig) {
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		targetNotifier, err := d.Notifier(target)
+		if err != nil {
+			log.Printf("Skipping %s target for %s: %v", target.Type, alertMsg.Channel, err)
+			notifierErrors.Inc(target.Type)
+			continue
+		}
+
+		wg.Add(1)
+		go func(target config.TargetConfig) {
+			defer wg.Done()
+			// Email and SNS clients aren't built per target, so the deadline bounds them too
+			ctx := ctx
+			if target.Timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, target.Timeout)
+				defer cancel()
+			}
+			if err := targetNotifier.NotifyAlert(ctx, alertMsg, alertID); err != nil {
+				log.Printf("Failed to deliver %s to %s target: %v", alertMsg.Name, target.Type, err)
+				notifierErrors.Inc(target.Type)
+			}
+		}(target)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,59 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// telegram posts alerts to the Telegram chat configured for their priority
+type telegram struct {
+	config    *config.TelegramConfig
+	notifiers map[string]*notifier.TelegramNotifier // by chat ID
+}
+
+func newTelegram(cfg *config.TelegramConfig) *telegram {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.BotToken == "" {
+		log.Printf("Telegram notifier configured without TELEGRAM_BOT_TOKEN, disabling it")
+		return nil
+	}
+
+	log.Printf("Posting alerts to %d Telegram chats", len(cfg.Chats))
+	notifiers := make(map[string]*notifier.TelegramNotifier, len(cfg.Chats))
+	for _, chatID := range cfg.Chats {
+		notifiers[chatID] = notifier.NewTelegramNotifier(cfg.BotToken, chatID, cfg.Timeout)
+	}
+	return &telegram{config: cfg, notifiers: notifiers}
+}
+
+// TelegramNotifier returns the notifier of a chat alerts are posted to, or false for any other
+// chat, so button presses can only act on the configured chats
+func (d *Dispatcher) TelegramNotifier(chatID string) (*notifier.TelegramNotifier, bool) {
+	if d.telegram == nil {
+		return nil, false
+	}
+	n, ok := d.telegram.notifiers[chatID]
+	return n, ok
+}
+
+// send posts the alert with acknowledge and dismiss buttons, which call back to /telegram/webhook
+func (t *telegram) send(ctx context.Context, alertMsg *alert.Alert, alertID string) {
+	if t == nil {
+		return
+	}
+	chatID, ok := t.config.Chats[alertMsg.Severity]
+	if !ok {
+		return
+	}
+
+	if err := t.notifiers[chatID].NotifyAlert(ctx, alertMsg, alertID); err != nil {
+		log.Printf("Failed to post %s to Telegram chat %s: %v", alertMsg.Name, chatID, err)
+		notifierErrors.Inc("telegram")
+	}
+}
@@ -0,0 +1,56 @@
+package dispatch
+
+import (
+	"log"
+
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/render"
+)
+
+// routeStyle is a route's custom template and the legend posted under its alerts
+type routeStyle struct {
+	renderer *render.TemplateRenderer
+	legend   string
+}
+
+// newRouteStyles compiles the templates and emoji sets routes reference, keyed by channel.
+// A route naming a template or emoji set that doesn't exist, or whose template fails to parse,
+// is logged and keeps the default layout.
+func newRouteStyles(cfg *config.Config) map[string]routeStyle {
+	styles := make(map[string]routeStyle)
+	for _, channel := range cfg.RouteChannels() {
+		route := cfg.RouteFor(channel)
+		if route.Template == "" && route.EmojiSet == "" {
+			continue
+		}
+
+		var set config.EmojiSet
+		if route.EmojiSet != "" {
+			var ok bool
+			if set, ok = cfg.EmojiSets[route.EmojiSet]; !ok {
+				log.Printf("Route %s uses unknown emoji set %s, using the default emoji", channel, route.EmojiSet)
+			}
+		}
+
+		style := routeStyle{legend: set.Legend}
+		if route.Template != "" {
+			source, ok := cfg.Template(route.Template)
+			if !ok {
+				log.Printf("Route %s uses unknown template %s, using the default layout", channel, route.Template)
+			} else {
+				emoji := render.Emoji{Firing: set.Firing, Resolved: set.Resolved, NoData: set.NoData, Priorities: set.Priorities}
+				renderer, err := render.NewTemplateRenderer(route.Template, source, emoji)
+				if err != nil {
+					log.Printf("Route %s: %v, using the default layout", channel, err)
+				} else {
+					style.renderer = renderer
+				}
+			}
+		}
+
+		if style.renderer != nil || style.legend != "" {
+			styles[channel] = style
+		}
+	}
+	return styles
+}
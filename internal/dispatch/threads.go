@@ -0,0 +1,58 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/state"
+)
+
+// Thread parents are kept a little over a day so late alerts still find today's thread
+const threadTTL = 25 * time.Hour
+
+// threadTracker remembers the parent message of each thread key for the current day
+type threadTracker struct {
+	store state.Store
+}
+
+func newThreadTracker(store state.Store) *threadTracker {
+	return &threadTracker{
+		store: store,
+	}
+}
+
+// threadID is "thread:<day>|<channel>|<key>", so a new day starts new threads
+func threadID(channel, key string, now time.Time) string {
+	return fmt.Sprintf("thread:%s|%s|%s", now.UTC().Format("2006-01-02"), channel, key)
+}
+
+// Get returns today's parent for the key. State errors are logged and treated as no parent,
+// so the alert is still posted, just unthreaded.
+func (t *threadTracker) Get(ctx context.Context, channel, key string) (string, bool) {
+	ts, ok, err := t.store.Get(ctx, threadID(channel, key, time.Now()))
+	if err != nil {
+		log.Printf("Failed to look up thread %s in %s: %v", key, channel, err)
+		return "", false
+	}
+	return ts, ok
+}
+
+// Claim makes parentTS today's parent for the key unless another replica's message already
+// is, and returns the parent that won. State errors are logged and parentTS returned.
+func (t *threadTracker) Claim(ctx context.Context, channel, key, parentTS string) string {
+	id := threadID(channel, key, time.Now())
+	claimed, err := t.store.SetNX(ctx, id, parentTS, threadTTL)
+	if err != nil {
+		log.Printf("Failed to save thread %s in %s: %v", key, channel, err)
+		return parentTS
+	}
+	if claimed {
+		return parentTS
+	}
+	if winner, ok := t.Get(ctx, channel, key); ok {
+		return winner
+	}
+	return parentTS
+}
@@ -0,0 +1,173 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+)
+
+var throttledAlerts = metrics.NewCounter("alert_dispatcher_throttled_alerts_total",
+	"Alerts held back by a route's rate limit and summarized instead.", "channel", "priority")
+
+// maxSummaryNames is how many alert names a throttle summary lists before "and N more"
+const maxSummaryNames = 10
+
+// throttleWindow counts the alerts of one priority posted to one channel in the current window,
+// and the names of those held back
+type throttleWindow struct {
+	start      time.Time
+	limit      config.RateLimit
+	posted     int
+	suppressed map[string]int // held back alerts by name
+	timer      *time.Timer
+}
+
+// throttler enforces routes' rate limits. Alerts over a limit aren't posted; when the window
+// ends the channel gets one message summarizing them.
+type throttler struct {
+	mu      sync.Mutex
+	windows map[string]*throttleWindow // by channel and priority
+	summary func(channel, priority string, w *throttleWindow)
+}
+
+func newThrottler(summary func(channel, priority string, w *throttleWindow)) *throttler {
+	return &throttler{windows: make(map[string]*throttleWindow), summary: summary}
+}
+
+// allow reports whether the alert may be posted to its channel under the limit, counting it
+// either way
+func (t *throttler) allow(alertMsg *alert.Alert, limit config.RateLimit) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := alertMsg.Channel + "|" + alertMsg.Severity
+	now := time.Now()
+	w, ok := t.windows[key]
+	if !ok || now.Sub(w.start) >= w.limit.Per {
+		w = &throttleWindow{start: now, limit: limit, suppressed: make(map[string]int)}
+		t.windows[key] = w
+	}
+
+	if w.posted < limit.Max {
+		w.posted++
+		return true
+	}
+
+	w.suppressed[alertMsg.Name]++
+	if w.timer == nil {
+		channel, priority := alertMsg.Channel, alertMsg.Severity
+		w.timer = time.AfterFunc(time.Until(w.start.Add(w.limit.Per)), func() {
+			t.flush(key, channel, priority, w)
+		})
+	}
+	return false
+}
+
+// full reports whether the alert's channel has used up the limit of its priority in the current
+// window, without counting the alert
+func (t *throttler) full(alertMsg *alert.Alert, limit config.RateLimit) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	w, ok := t.windows[alertMsg.Channel+"|"+alertMsg.Severity]
+	return ok && time.Since(w.start) < w.limit.Per && w.posted >= limit.Max
+}
+
+// rateLimitFor returns the route's rate limit for the priority, reporting false without one
+func rateLimitFor(route config.RouteConfig, priority string) (config.RateLimit, bool) {
+	limit, ok := route.RateLimits[priority]
+	if !ok || limit.Max <= 0 {
+		return config.RateLimit{}, false
+	}
+	if limit.Per <= 0 {
+		limit.Per = time.Hour
+	}
+	return limit, true
+}
+
+// flush summarizes the window's held back alerts, unless another flush already did
+func (t *throttler) flush(key, channel, priority string, w *throttleWindow) {
+	t.mu.Lock()
+	if t.windows[key] == w {
+		delete(t.windows, key)
+	}
+	if w.timer == nil {
+		t.mu.Unlock()
+		return
+	}
+	w.timer = nil
+	t.mu.Unlock()
+
+	t.summary(channel, priority, w)
+}
+
+// close posts the summaries of windows that haven't ended yet, so nothing held back is lost
+func (t *throttler) close() {
+	t.mu.Lock()
+	pending := make(map[string]*throttleWindow)
+	for key, w := range t.windows {
+		if w.timer != nil && w.timer.Stop() {
+			pending[key] = w
+		}
+	}
+	t.mu.Unlock()
+
+	for key, w := range pending {
+		channel, priority, _ := strings.Cut(key, "|")
+		t.flush(key, channel, priority, w)
+	}
+}
+
+// throttled reports whether the route's rate limit for the alert's priority holds it back
+func (d *Dispatcher) throttled(alertMsg *alert.Alert, route config.RouteConfig) bool {
+	limit, ok := rateLimitFor(route, alertMsg.Severity)
+	if !ok {
+		return false
+	}
+	if d.throttler.allow(alertMsg, limit) {
+		return false
+	}
+
+	log.Printf("Holding back %s alert %s, over %s's limit of %d per %s", alertMsg.Severity, alertMsg.Name, alertMsg.Channel, limit.Max, limit.Per)
+	throttledAlerts.Inc(alertMsg.Channel, alertMsg.Severity)
+	return true
+}
+
+// postThrottleSummary tells the channel which alerts its rate limit held back in a window
+func (d *Dispatcher) postThrottleSummary(channel, priority string, w *throttleWindow) {
+	total := 0
+	names := make([]string, 0, len(w.suppressed))
+	for name, count := range w.suppressed {
+		total += count
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if w.suppressed[names[i]] != w.suppressed[names[j]] {
+			return w.suppressed[names[i]] > w.suppressed[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔇 *%d %s alerts were not posted* (limit %d per %s since %s UTC)",
+		total, priority, w.limit.Max, w.limit.Per, w.start.UTC().Format("15:04"))
+	for i, name := range names {
+		if i == maxSummaryNames {
+			fmt.Fprintf(&sb, "\n• _and %d more_", len(names)-maxSummaryNames)
+			break
+		}
+		fmt.Fprintf(&sb, "\n• `%s` ×%d", name, w.suppressed[name])
+	}
+
+	if err := d.slackNotifier(channel).NotifyText(context.Background(), sb.String()); err != nil {
+		log.Printf("Failed to post rate limit summary to %s: %v", channel, err)
+	}
+}
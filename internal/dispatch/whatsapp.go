@@ -0,0 +1,49 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// whatsApp messages a group of numbers about critical alerts, for stakeholders who only
+// reliably see WhatsApp
+type whatsApp struct {
+	notifier   *notifier.WhatsAppNotifier
+	priorities map[string]bool
+}
+
+func newWhatsApp(cfg *config.WhatsAppConfig) *whatsApp {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.AccessToken == "" || cfg.PhoneNumberID == "" || cfg.Template == "" {
+		log.Printf("WhatsApp notifier configured without WHATSAPP_ACCESS_TOKEN, phone_number_id or template, disabling it")
+		return nil
+	}
+
+	log.Printf("Sending %v alerts to %d WhatsApp numbers", cfg.Priorities, len(cfg.To))
+	w := &whatsApp{
+		notifier:   notifier.NewWhatsAppNotifier(cfg.APIURL, cfg.PhoneNumberID, cfg.AccessToken, cfg.Template, cfg.Language, cfg.To, cfg.Timeout),
+		priorities: make(map[string]bool),
+	}
+	for _, priority := range cfg.Priorities {
+		w.priorities[priority] = true
+	}
+	return w
+}
+
+// send messages about firing alerts; resolutions are left to Slack
+func (w *whatsApp) send(ctx context.Context, alertMsg *alert.Alert) {
+	if w == nil || !w.priorities[alertMsg.Severity] || alertMsg.Status != alert.StatusFiring {
+		return
+	}
+
+	if err := w.notifier.NotifyAlert(ctx, alertMsg, ""); err != nil {
+		log.Printf("Failed to send %s to WhatsApp: %v", alertMsg.Name, err)
+		notifierErrors.Inc("whatsapp")
+	}
+}
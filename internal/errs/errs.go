@@ -0,0 +1,184 @@
+// Package errs classifies pipeline failures, so retries, dead-lettering, metrics and API
+// responses are decided on the kind of error rather than by matching its message.
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+var pipelineErrors = metrics.NewCounter("alert_dispatcher_errors_total",
+	"Alerts that failed processing, by error code.", "code")
+
+// Machine-readable codes, reported in metrics and API error responses
+const (
+	CodeParse             = "parse_error"
+	CodeRouteNotFound     = "route_not_found"
+	CodeTransientDelivery = "transient_delivery_error"
+	CodePermanentDelivery = "permanent_delivery_error"
+	CodeConfig            = "config_error"
+	CodeTimeout           = "timeout"
+	CodeQuotaExceeded     = "quota_exceeded"
+	CodeInternal          = "internal_error"
+)
+
+// ParseError is a message that couldn't be read as an alert. Redelivering it won't help.
+type ParseError struct {
+	Source string // e.g. cloudwatch or grafana
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse %s message: %v", e.Source, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// RouteNotFoundError is an alert that no alarm mapping, priority channel or default channel
+// routes anywhere
+type RouteNotFoundError struct {
+	Alert string
+}
+
+func (e *RouteNotFoundError) Error() string {
+	return fmt.Sprintf("no channel routes alert %s", e.Alert)
+}
+
+// TransientDeliveryError is a delivery that may succeed if retried: a timeout, a rate limit or
+// a 5xx from the destination
+type TransientDeliveryError struct {
+	Destination string
+	Err         error
+}
+
+func (e *TransientDeliveryError) Error() string {
+	return fmt.Sprintf("failed to deliver to %s: %v", e.Destination, e.Err)
+}
+
+func (e *TransientDeliveryError) Unwrap() error { return e.Err }
+
+// PermanentDeliveryError is a delivery the destination rejected, such as an unknown channel or
+// revoked token, which fails the same way on every retry
+type PermanentDeliveryError struct {
+	Destination string
+	Err         error
+}
+
+func (e *PermanentDeliveryError) Error() string {
+	return fmt.Sprintf("%s rejected delivery: %v", e.Destination, e.Err)
+}
+
+func (e *PermanentDeliveryError) Unwrap() error { return e.Err }
+
+// ConfigError is a missing or invalid setting
+type ConfigError struct {
+	Setting string
+	Err     error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s config: %v", e.Setting, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// QuotaExceededError is an alert held back because its source, or the part of it Key names,
+// used up its quota. It fits once RetryAfter has passed.
+type QuotaExceededError struct {
+	Source     string
+	Key        string // the quota key's value, when the quota is split by one
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	source := e.Source
+	if e.Key != "" {
+		source += fmt.Sprintf(" %q", e.Key)
+	}
+	return fmt.Sprintf("%s is over its quota, retry in %s", source, e.RetryAfter.Round(time.Second))
+}
+
+// Delivery classifies a failed delivery by the HTTP status the destination responded with:
+// rate limits and server errors are transient, anything else is permanent
+func Delivery(destination string, status int, err error) error {
+	if status == http.StatusTooManyRequests || status >= 500 {
+		return &TransientDeliveryError{Destination: destination, Err: err}
+	}
+	return &PermanentDeliveryError{Destination: destination, Err: err}
+}
+
+// Code returns the code for the first classified error in err's chain, or CodeInternal
+func Code(err error) string {
+	var (
+		parseErr     *ParseError
+		routeErr     *RouteNotFoundError
+		transientErr *TransientDeliveryError
+		permanentErr *PermanentDeliveryError
+		configErr    *ConfigError
+		timeoutErr   *TimeoutError
+		quotaErr     *QuotaExceededError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &timeoutErr):
+		// Checked first, as the call the deadline cut short may have failed with any error
+		return CodeTimeout
+	case errors.As(err, &parseErr):
+		return CodeParse
+	case errors.As(err, &routeErr):
+		return CodeRouteNotFound
+	case errors.As(err, &transientErr):
+		return CodeTransientDelivery
+	case errors.As(err, &permanentErr):
+		return CodePermanentDelivery
+	case errors.As(err, &configErr):
+		return CodeConfig
+	case errors.As(err, &quotaErr):
+		return CodeQuotaExceeded
+	default:
+		return CodeInternal
+	}
+}
+
+// Count records a failed alert in alert_dispatcher_errors_total under its code
+func Count(err error) {
+	if err != nil {
+		pipelineErrors.Inc(Code(err))
+	}
+}
+
+// Retryable reports whether processing the same message again may succeed. Unclassified
+// errors, such as a state store being unreachable, are assumed to be.
+func Retryable(err error) bool {
+	switch Code(err) {
+	case CodeParse, CodeRouteNotFound, CodePermanentDelivery, CodeConfig:
+		return false
+	default:
+		return err != nil
+	}
+}
+
+// HTTPStatus is the status an API responds with for err
+func HTTPStatus(err error) int {
+	switch Code(err) {
+	case CodeParse:
+		return http.StatusBadRequest
+	case CodeRouteNotFound:
+		return http.StatusUnprocessableEntity
+	case CodeTransientDelivery:
+		return http.StatusServiceUnavailable
+	case CodePermanentDelivery:
+		return http.StatusBadGateway
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	case CodeQuotaExceeded:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
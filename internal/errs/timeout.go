@@ -0,0 +1,77 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+var messageTimeouts = metrics.NewCounter("alert_dispatcher_message_timeouts_total",
+	"Messages whose processing ran past its deadline, by the stage it was in.", "stage")
+
+// Stages of processing a message: parsing it into an alert, looking up the state that decides
+// where and whether it goes, and delivering it
+const (
+	StageParse   = "parse"
+	StageEnrich  = "enrich"
+	StageDeliver = "deliver"
+)
+
+// TimeoutError is a message whose processing ran past its deadline. Its outbound calls were
+// cancelled, and processing it again may succeed.
+type TimeoutError struct {
+	Stage  string
+	Budget time.Duration
+	Err    error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("processing exceeded %s during %s: %v", e.Budget, e.Stage, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+type stageKey struct{}
+
+// stageTracker is the stage processing under a context has reached
+type stageTracker struct {
+	mu    sync.Mutex
+	stage string
+}
+
+// EnterStage records that processing under ctx has reached stage. It does nothing unless ctx
+// comes from WithDeadline.
+func EnterStage(ctx context.Context, stage string) {
+	if tracker, ok := ctx.Value(stageKey{}).(*stageTracker); ok {
+		tracker.mu.Lock()
+		tracker.stage = stage
+		tracker.mu.Unlock()
+	}
+}
+
+// WithDeadline runs process with a context cancelled after budget, starting in StageParse. If
+// it fails once the deadline has passed, the error is a TimeoutError for the stage it had
+// reached, counted in alert_dispatcher_message_timeouts_total. A budget of 0 is no deadline.
+func WithDeadline(ctx context.Context, budget time.Duration, process func(context.Context) error) error {
+	if budget <= 0 {
+		return process(ctx)
+	}
+
+	tracker := &stageTracker{stage: StageParse}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, stageKey{}, tracker), budget)
+	defer cancel()
+
+	err := process(ctx)
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	tracker.mu.Lock()
+	stage := tracker.stage
+	tracker.mu.Unlock()
+	messageTimeouts.Inc(stage)
+	return &TimeoutError{Stage: stage, Budget: budget, Err: err}
+}
@@ -0,0 +1,114 @@
+package grafana
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/httpclient"
+)
+
+// Graph size in pixels, wide enough to read in a Slack thread
+const (
+	renderWidth  = 1000
+	renderHeight = 500
+)
+
+// Renderer renders Grafana panels as PNG images through the Grafana image renderer, for panels
+// of one Grafana only, so its token is never sent to a host named in an alert
+type Renderer struct {
+	baseURL *url.URL
+	token   string // service account token with the viewer role; empty for anonymous access
+	client  *http.Client
+}
+
+func NewRenderer(baseURL, token string, timeout time.Duration) (*Renderer, error) {
+	parsed, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid Grafana URL %q", baseURL)
+	}
+	return &Renderer{baseURL: parsed, token: token, client: httpclient.New(timeout)}, nil
+}
+
+// CanRender reports whether the panel URL is a panel on the renderer's Grafana
+func (r *Renderer) CanRender(panelURL string) bool {
+	_, err := r.renderURL(panelURL, time.Time{}, time.Time{})
+	return err == nil
+}
+
+// renderURL turns a panel URL such as <grafana>/d/<uid>/<slug>?orgId=1&viewPanel=2 into the
+// URL rendering it alone between from and to, keeping its organization and template variables
+func (r *Renderer) renderURL(panelURL string, from, to time.Time) (string, error) {
+	panel, err := url.Parse(panelURL)
+	if err != nil {
+		return "", err
+	}
+	if panel.Scheme != r.baseURL.Scheme || panel.Host != r.baseURL.Host {
+		return "", fmt.Errorf("panel %s isn't on %s", panelURL, r.baseURL)
+	}
+	dashboard, ok := strings.CutPrefix(panel.Path, r.baseURL.Path+"/d/")
+	if !ok || dashboard == "" {
+		return "", fmt.Errorf("%s isn't a dashboard panel", panelURL)
+	}
+
+	query := panel.Query()
+	panelID := query.Get("viewPanel")
+	for _, param := range []string{"panelId", "editPanel"} {
+		if panelID == "" {
+			panelID = query.Get(param)
+		}
+	}
+	if panelID == "" {
+		return "", fmt.Errorf("%s names no panel", panelURL)
+	}
+
+	params := url.Values{
+		"panelId": {panelID},
+		"from":    {strconv.FormatInt(from.UnixMilli(), 10)},
+		"to":      {strconv.FormatInt(to.UnixMilli(), 10)},
+		"width":   {strconv.Itoa(renderWidth)},
+		"height":  {strconv.Itoa(renderHeight)},
+		"tz":      {"UTC"},
+	}
+	for name, values := range query {
+		if name == "orgId" || strings.HasPrefix(name, "var-") {
+			params[name] = values
+		}
+	}
+	return fmt.Sprintf("%s/render/d-solo/%s?%s", r.baseURL, dashboard, params.Encode()), nil
+}
+
+// RenderPanel renders the panel between from and to as a PNG
+func (r *Renderer) RenderPanel(ctx context.Context, panelURL string, from, to time.Time) ([]byte, error) {
+	renderURL, err := r.renderURL(panelURL, from, to)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, renderURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("grafana render failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	// Grafana answers with its login page or an error page rather than a status in some setups
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("grafana render returned %s, not an image; is the image renderer installed?", contentType)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+}
@@ -0,0 +1,33 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// Keep-alive tuning for the shared transport. Go's default keeps only 2 idle connections per
+// host, so bursts of alerts to Slack or a pager would otherwise redo TCP and TLS handshakes.
+const (
+	maxIdleConns        = 256
+	maxIdleConnsPerHost = 64
+	idleConnTimeout     = 90 * time.Second
+)
+
+// Transport is shared by every outbound client, so connections and TLS sessions to each host
+// are pooled across alerts and destinations
+var Transport = newTransport()
+
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	transport.IdleConnTimeout = idleConnTimeout
+	transport.ForceAttemptHTTP2 = true
+	return transport
+}
+
+// New returns a client whose requests are bounded by timeout, zero for none. Clients are cheap
+// to create; their connections live in the shared Transport.
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport}
+}
@@ -0,0 +1,106 @@
+package kafkatopic
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// Producer writes normalized alerts as JSON to a Kafka topic. Messages are keyed by the alert's
+// fingerprint, so every notification of an alert lands on the same partition, in order.
+type Producer struct {
+	Writer *kafka.Writer
+}
+
+func NewProducer(cfg config.KafkaConfig) (*Producer, error) {
+	transport := &kafka.Transport{
+		ClientID:    "alert-dispatcher",
+		DialTimeout: cfg.Timeout,
+	}
+	if cfg.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	mechanism, err := saslMechanism(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport.SASL = mechanism
+
+	return &Producer{
+		Writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// Alerts are written one at a time, so don't hold them for the default 1s batch window
+			BatchTimeout: 10 * time.Millisecond,
+			WriteTimeout: cfg.Timeout,
+			Transport:    transport,
+		},
+	}, nil
+}
+
+func saslMechanism(cfg config.KafkaConfig) (sasl.Mechanism, error) {
+	switch strings.ToLower(cfg.SASLMechanism) {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("unknown Kafka SASL mechanism %q (plain, scram-sha-256 or scram-sha-512)", cfg.SASLMechanism)
+	}
+}
+
+// Notify writes {"text": message} without a key
+func (p *Producer) Notify(ctx context.Context, message string) error {
+	return p.write(ctx, "", notifier.AlertPayload{Text: message}, nil)
+}
+
+// NotifyAlert writes {"alert_id": ..., "fingerprint": ..., "alert": ...} keyed by fingerprint,
+// with the alert's source, severity and status as headers
+func (p *Producer) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	payload := notifier.NewAlertPayload(a, alertID)
+	headers := []kafka.Header{
+		{Key: "source", Value: []byte(a.Source)},
+		{Key: "severity", Value: []byte(a.Severity)},
+		{Key: "status", Value: []byte(a.Status)},
+	}
+	return p.write(ctx, payload.Fingerprint, payload, headers)
+}
+
+func (p *Producer) write(ctx context.Context, key string, payload notifier.AlertPayload, headers []kafka.Header) error {
+	value, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Kafka message: %v", err)
+	}
+
+	message := kafka.Message{Value: value, Headers: headers}
+	if key != "" {
+		message.Key = []byte(key)
+	}
+	if err := p.Writer.WriteMessages(ctx, message); err != nil {
+		return fmt.Errorf("failed to write to Kafka topic %s: %v", p.Writer.Topic, err)
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the producer's connections
+func (p *Producer) Close() error {
+	return p.Writer.Close()
+}
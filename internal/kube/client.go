@@ -0,0 +1,122 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"alert-dispatcher/internal/httpclient"
+)
+
+// serviceAccountDir is where Kubernetes mounts a pod's service account token and CA
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Client calls the Kubernetes API server with the pod's service account. Only the list and
+// watch calls the operator needs and the deployment changes of quick actions are supported.
+type Client struct {
+	apiURL    string
+	tokenFile string
+	namespace string // the pod's own namespace
+	client    *http.Client
+}
+
+// NewInClusterClient returns a client for the cluster the pod runs in
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes pod: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are unset")
+	}
+
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in service account CA")
+	}
+	namespace, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account namespace: %v", err)
+	}
+
+	// No client timeout, as watches stay open; requests are bounded by their context and the
+	// API server's timeoutSeconds
+	transport := httpclient.Transport.Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &Client{
+		apiURL:    "https://" + net.JoinHostPort(host, port),
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+		namespace: strings.TrimSpace(string(namespace)),
+		client:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// Namespace is the namespace the pod runs in
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// get requests path, returning the response when it is a 200
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, path+"?"+query.Encode(), "", nil)
+}
+
+// patch sends a patch of contentType, such as application/merge-patch+json, to path
+func (c *Client) patch(ctx context.Context, path, contentType string, body []byte) error {
+	resp, err := c.do(ctx, http.MethodPatch, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a request, returning the response when it is a 200. The token is read on every
+// request since the kubelet rotates it.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %v", strings.ToLower(method), strings.SplitN(path, "?", 2)[0], err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
+
+// StatusError is a response from the API server other than 200
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes API returned %d: %s", e.Code, e.Body)
+}
@@ -0,0 +1,66 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl rollout restart sets
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+func deploymentPath(namespace, name string) string {
+	return fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s", url.PathEscape(namespace), url.PathEscape(name))
+}
+
+// ScaleDeployment adds step replicas to the deployment, up to max unless it is 0, and returns
+// the replicas before and after. A deployment already at max is an error. The service account
+// needs get and patch on deployments/scale.
+func (c *Client) ScaleDeployment(ctx context.Context, namespace, name string, step, max int) (int, int, error) {
+	path := deploymentPath(namespace, name) + "/scale"
+	resp, err := c.get(ctx, path, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	var scale struct {
+		Spec struct {
+			Replicas int `json:"replicas"`
+		} `json:"spec"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&scale); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode scale of %s/%s: %v", namespace, name, err)
+	}
+
+	from := scale.Spec.Replicas
+	to := from + step
+	if max > 0 && to > max {
+		to = max
+	}
+	if to <= from {
+		return from, from, fmt.Errorf("%s/%s is already at its limit of %d replicas", namespace, name, max)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"spec": map[string]int{"replicas": to}})
+	if err := c.patch(ctx, path, "application/merge-patch+json", body); err != nil {
+		return from, from, err
+	}
+	return from, to, nil
+}
+
+// RestartDeployment rolls the deployment's pods the way kubectl rollout restart does. The
+// service account needs patch on deployments.
+func (c *Client) RestartDeployment(ctx context.Context, namespace, name string) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{restartedAtAnnotation: time.Now().Format(time.RFC3339)},
+				},
+			},
+		},
+	})
+	return c.patch(ctx, deploymentPath(namespace, name), "application/strategic-merge-patch+json", body)
+}
@@ -0,0 +1,191 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Watches are closed by the API server after this long and resumed from the last version seen
+const watchTimeoutSec = 300
+
+// relistDelay is the wait before listing again after a failed list or watch
+const relistDelay = 5 * time.Second
+
+// errExpired means the watched version is too old and the objects must be listed again
+var errExpired = errors.New("resource version expired")
+
+// Object is a custom resource: its metadata and its spec, left for the caller to decode
+type Object struct {
+	Metadata struct {
+		Name              string    `json:"name"`
+		Namespace         string    `json:"namespace"`
+		ResourceVersion   string    `json:"resourceVersion"`
+		CreationTimestamp time.Time `json:"creationTimestamp"`
+	} `json:"metadata"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+// Key is "namespace/name"
+func (o Object) Key() string {
+	return o.Metadata.Namespace + "/" + o.Metadata.Name
+}
+
+type objectList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []Object `json:"items"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK or ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+// informer keeps a cache of one resource's objects current by listing them, then watching for
+// changes, and calls changed after each change
+type informer struct {
+	client  *Client
+	path    string // e.g. /apis/<group>/<version>/alertroutes
+	changed func()
+
+	mu      sync.Mutex
+	objects map[string]Object // by namespace/name
+}
+
+func newInformer(client *Client, path string, changed func()) *informer {
+	return &informer{client: client, path: path, changed: changed, objects: make(map[string]Object)}
+}
+
+// run lists and watches until ctx is done
+func (i *informer) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		version, err := i.list(ctx)
+		for err == nil {
+			version, err = i.watch(ctx, version)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != errExpired {
+			log.Printf("Failed to watch %s, listing again in %s: %v", i.path, relistDelay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(relistDelay):
+			}
+		}
+	}
+}
+
+// list replaces the cache with every object, returning the version to watch from
+func (i *informer) list(ctx context.Context) (string, error) {
+	resp, err := i.client.get(ctx, i.path, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var list objectList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %v", i.path, err)
+	}
+	objects := make(map[string]Object, len(list.Items))
+	for _, obj := range list.Items {
+		objects[obj.Key()] = obj
+	}
+
+	i.mu.Lock()
+	i.objects = objects
+	i.mu.Unlock()
+	i.changed()
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watch applies changes after version to the cache until the API server ends the watch,
+// returning the last version seen
+func (i *informer) watch(ctx context.Context, version string) (string, error) {
+	resp, err := i.client.get(ctx, i.path, url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {version},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {fmt.Sprint(watchTimeoutSec)},
+	})
+	if err != nil {
+		var status *StatusError
+		if errors.As(err, &status) && status.Code == http.StatusGone {
+			return "", errExpired
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := dec.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			// The server closing the stream at timeoutSeconds ends the body
+			return version, nil
+		}
+
+		if event.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return "", errExpired
+			}
+			return "", fmt.Errorf("watch error %d: %s", status.Code, status.Message)
+		}
+
+		var obj Object
+		if err := json.Unmarshal(event.Object, &obj); err != nil {
+			return "", fmt.Errorf("failed to decode %s event: %v", i.path, err)
+		}
+		version = obj.Metadata.ResourceVersion
+		if event.Type == "BOOKMARK" {
+			continue
+		}
+
+		i.mu.Lock()
+		if event.Type == "DELETED" {
+			delete(i.objects, obj.Key())
+		} else {
+			i.objects[obj.Key()] = obj
+		}
+		i.mu.Unlock()
+		i.changed()
+	}
+}
+
+// snapshot returns the cached objects, oldest first so earlier claims win conflicts
+func (i *informer) snapshot() []Object {
+	i.mu.Lock()
+	objects := make([]Object, 0, len(i.objects))
+	for _, obj := range i.objects {
+		objects = append(objects, obj)
+	}
+	i.mu.Unlock()
+
+	sort.Slice(objects, func(a, b int) bool {
+		ta, tb := objects[a].Metadata.CreationTimestamp, objects[b].Metadata.CreationTimestamp
+		if !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		return objects[a].Key() < objects[b].Key()
+	})
+	return objects
+}
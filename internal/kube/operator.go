@@ -0,0 +1,189 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/config"
+)
+
+// API group and version of the AlertRoute and AlertSilence resources
+const (
+	Group   = "alerting.licious.in"
+	Version = "v1alpha1"
+)
+
+// AlertRouteSpec routes alerts to a channel and sets the channel's route options
+type AlertRouteSpec struct {
+	Channel  string          `json:"channel"`
+	Alarms   []string        `json:"alarms"`   // alert names routed to the channel, like alarm_mappings
+	Options  json.RawMessage `json:"options"`  // route options as in alarm-channels.yaml
+	Template string          `json:"template"` // a message template for the channel
+}
+
+// AlertSilenceSpec holds back matching alerts for a while; every set matcher must match
+type AlertSilenceSpec struct {
+	AlertName string            `json:"alertName"`
+	NameRegex string            `json:"nameRegex"`
+	Source    string            `json:"source"`
+	Channel   string            `json:"channel"`
+	Labels    map[string]string `json:"labels"`
+	StartsAt  time.Time         `json:"startsAt"`
+	EndsAt    time.Time         `json:"endsAt"`
+	Comment   string            `json:"comment"`
+	CreatedBy string            `json:"createdBy"`
+}
+
+// Operator watches AlertRoute and AlertSilence resources and layers them over the config file
+type Operator struct {
+	client   *Client
+	cfg      *config.Config
+	routes   *informer
+	silences *informer
+	applied  func()
+
+	mu sync.Mutex // serializes applying changes
+}
+
+// NewOperator watches namespace, or every namespace when empty, and calls applied after each
+// change has been applied to cfg
+func NewOperator(client *Client, cfg *config.Config, namespace string, applied func()) *Operator {
+	o := &Operator{client: client, cfg: cfg, applied: applied}
+	o.routes = newInformer(client, resourcePath(namespace, "alertroutes"), o.apply)
+	o.silences = newInformer(client, resourcePath(namespace, "alertsilences"), o.apply)
+	return o
+}
+
+func resourcePath(namespace, resource string) string {
+	if namespace == "" {
+		return "/apis/" + Group + "/" + Version + "/" + resource
+	}
+	return "/apis/" + Group + "/" + Version + "/namespaces/" + namespace + "/" + resource
+}
+
+// Run watches both resources until ctx is done
+func (o *Operator) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, i := range []*informer{o.routes, o.silences} {
+		wg.Add(1)
+		go func(i *informer) {
+			defer wg.Done()
+			i.run(ctx)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// apply rebuilds the overlay from every cached resource
+func (o *Operator) apply() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	overlay, owned := o.routeOverlay()
+	overlay.Silences = o.silenceOverlay(owned)
+	o.cfg.ApplyOverlay(overlay)
+	log.Printf("Applied %d AlertRoutes and %d AlertSilences", len(overlay.Routes), len(overlay.Silences))
+	o.applied()
+}
+
+// routeOverlay converts AlertRoutes, returning the channels each namespace owns. A channel or
+// alarm claimed by several AlertRoutes goes to the oldest.
+func (o *Operator) routeOverlay() (config.Overlay, map[string][]string) {
+	overlay := config.Overlay{
+		AlarmMappings: make(map[string]string),
+		Routes:        make(map[string]config.RouteConfig),
+		Templates:     make(map[string]string),
+	}
+	owners := make(map[string]string) // channel to owning AlertRoute
+	owned := make(map[string][]string)
+
+	for _, obj := range o.routes.snapshot() {
+		var spec AlertRouteSpec
+		if err := json.Unmarshal(obj.Spec, &spec); err != nil {
+			log.Printf("Skipping AlertRoute %s: invalid spec: %v", obj.Key(), err)
+			continue
+		}
+		if spec.Channel == "" {
+			log.Printf("Skipping AlertRoute %s: no channel", obj.Key())
+			continue
+		}
+		if owner, ok := owners[spec.Channel]; ok {
+			log.Printf("Skipping AlertRoute %s: channel %s belongs to %s", obj.Key(), spec.Channel, owner)
+			continue
+		}
+
+		var route config.RouteConfig
+		if len(spec.Options) > 0 {
+			var err error
+			if route, err = config.DecodeRoute(spec.Options); err != nil {
+				log.Printf("Skipping AlertRoute %s: invalid options: %v", obj.Key(), err)
+				continue
+			}
+		}
+		if spec.Template != "" {
+			overlay.Templates[obj.Key()] = spec.Template
+			route.Template = obj.Key()
+		}
+
+		owners[spec.Channel] = obj.Key()
+		owned[obj.Metadata.Namespace] = append(owned[obj.Metadata.Namespace], spec.Channel)
+		overlay.Routes[spec.Channel] = route
+		for _, alarm := range spec.Alarms {
+			if _, ok := overlay.AlarmMappings[alarm]; !ok {
+				overlay.AlarmMappings[alarm] = spec.Channel
+			}
+		}
+	}
+	return overlay, owned
+}
+
+// silenceOverlay converts AlertSilences. Silences outside the dispatcher's own namespace only
+// apply to channels owned by AlertRoutes in their namespace.
+func (o *Operator) silenceOverlay(owned map[string][]string) []config.Silence {
+	var silences []config.Silence
+	for _, obj := range o.silences.snapshot() {
+		var spec AlertSilenceSpec
+		if err := json.Unmarshal(obj.Spec, &spec); err != nil {
+			log.Printf("Skipping AlertSilence %s: invalid spec: %v", obj.Key(), err)
+			continue
+		}
+
+		silence := config.Silence{
+			Name:      obj.Key(),
+			Source:    spec.Source,
+			NameRegex: spec.NameRegex,
+			Labels:    spec.Labels,
+			StartsAt:  spec.StartsAt,
+			EndsAt:    spec.EndsAt,
+			Comment:   spec.Comment,
+			CreatedBy: spec.CreatedBy,
+		}
+		if spec.AlertName != "" {
+			silence.NameRegex = "^" + regexp.QuoteMeta(spec.AlertName) + "$"
+		}
+		if spec.Channel != "" {
+			silence.Channels = []string{spec.Channel}
+		}
+
+		if obj.Metadata.Namespace != o.client.Namespace() {
+			channels := owned[obj.Metadata.Namespace]
+			switch {
+			case spec.Channel == "":
+				silence.Channels = channels
+			case !slices.Contains(channels, spec.Channel):
+				channels = nil
+			}
+			if len(channels) == 0 {
+				log.Printf("Skipping AlertSilence %s: namespace %s owns no AlertRoute for its channels", obj.Key(), obj.Metadata.Namespace)
+				continue
+			}
+		}
+		silences = append(silences, silence)
+	}
+	return silences
+}
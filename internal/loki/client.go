@@ -0,0 +1,99 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/httpclient"
+)
+
+// Client runs LogQL queries against Loki's query_range API
+type Client struct {
+	token  string // bearer token, e.g. for Grafana Cloud; empty for none
+	client *http.Client
+}
+
+func NewClient(token string, timeout time.Duration) *Client {
+	return &Client{token: token, client: httpclient.New(timeout)}
+}
+
+// queryRangeResponse is the body of a log query; metric queries have other result types
+type queryRangeResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Values [][2]string `json:"values"` // [unix nanoseconds, line]
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// QueryRange returns the newest limit lines the query matches between start and end, newest
+// first, each prefixed with its time. The tenant, when set, is sent as X-Scope-OrgID.
+func (c *Client) QueryRange(ctx context.Context, baseURL, tenant, query string, start, end time.Time, limit int) ([]string, error) {
+	params := url.Values{
+		"query":     {query},
+		"start":     {strconv.FormatInt(start.UnixNano(), 10)},
+		"end":       {strconv.FormatInt(end.UnixNano(), 10)},
+		"limit":     {strconv.Itoa(limit)},
+		"direction": {"backward"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/loki/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if tenant != "" {
+		req.Header.Set("X-Scope-OrgID", tenant)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("loki query failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var result queryRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode loki response: %v", err)
+	}
+	if result.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("loki query returned %s, not log lines", result.Data.ResultType)
+	}
+
+	// Each stream is sorted on its own, so merge them newest first
+	type entry struct {
+		at   int64
+		line string
+	}
+	var entries []entry
+	for _, stream := range result.Data.Result {
+		for _, value := range stream.Values {
+			at, _ := strconv.ParseInt(value[0], 10, 64)
+			entries = append(entries, entry{at: at, line: value[1]})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at > entries[j].at })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = time.Unix(0, e.at).UTC().Format("15:04:05.000") + " " + strings.TrimRight(e.line, "\n")
+	}
+	return lines, nil
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A tiny Prometheus text-format registry; we only need counters and gauges with labels
+
+var (
+	registryMu sync.Mutex
+	registry   []*metric
+)
+
+type metric struct {
+	name       string
+	help       string
+	kind       string // "counter" or "gauge"
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64 // joined label values -> value
+}
+
+// Counter is a monotonically increasing metric
+type Counter struct{ m *metric }
+
+// Gauge is a metric that can go up and down
+type Gauge struct{ m *metric }
+
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{m: register(name, help, "counter", labelNames)}
+}
+
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{m: register(name, help, "gauge", labelNames)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.m.add(1, labelValues)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.m.add(v, labelValues)
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.m.set(v, labelValues)
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.m.add(v, labelValues)
+}
+
+// Delete removes the series for the label values so it is no longer exposed
+func (g *Gauge) Delete(labelValues ...string) {
+	g.m.delete(labelValues)
+}
+
+// DeleteWhere removes every series whose label values match reports true for
+func (g *Gauge) DeleteWhere(match func(labelValues []string) bool) {
+	g.m.deleteWhere(match)
+}
+
+func register(name, help, kind string, labelNames []string) *metric {
+	m := &metric{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+	return m
+}
+
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m *metric) add(v float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] += v
+	m.mu.Unlock()
+}
+
+func (m *metric) set(v float64, labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] = v
+	m.mu.Unlock()
+}
+
+func (m *metric) delete(labelValues []string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	delete(m.values, key)
+	m.mu.Unlock()
+}
+
+func (m *metric) deleteWhere(match func(labelValues []string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.values {
+		if match(strings.Split(key, "\xff")) {
+			delete(m.values, key)
+		}
+	}
+}
+
+func (m *metric) write(sb *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if len(m.labelNames) == 0 {
+			fmt.Fprintf(sb, "%s %g\n", m.name, m.values[k])
+			continue
+		}
+		values := strings.Split(k, "\xff")
+		pairs := make([]string, len(values))
+		for i, v := range values {
+			pairs[i] = fmt.Sprintf("%s=%q", m.labelNames[i], v)
+		}
+		fmt.Fprintf(sb, "%s{%s} %g\n", m.name, strings.Join(pairs, ","), m.values[k])
+	}
+}
+
+// Handler serves every registered metric in the Prometheus text exposition format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryMu.Lock()
+		metrics := append([]*metric(nil), registry...)
+		registryMu.Unlock()
+
+		var sb strings.Builder
+		for _, m := range metrics {
+			m.write(&sb)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(sb.String()))
+	})
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"html/template"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+)
+
+var htmlTemplate = template.Must(template.New("alert").Funcs(template.FuncMap{
+	"linkTitle": linkTitle,
+}).Parse(`<div style="font-family: sans-serif">
+<h2>{{.Title}}</h2>
+<table cellpadding="4">
+<tr><td><b>Source</b></td><td>{{.Alert.Source}}</td></tr>
+<tr><td><b>Severity</b></td><td>{{.Alert.Severity}}</td></tr>
+<tr><td><b>State</b></td><td><code>{{.Alert.State}}</code></td></tr>
+{{- if .Description}}
+<tr><td><b>Description</b></td><td>{{.Description}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Alert.Labels}}{{if $v}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}{{end}}
+</table>
+{{- range $k, $v := .Alert.URLs}}
+<p><a href="{{$v}}">{{linkTitle $k}}</a></p>
+{{- end}}
+</div>`))
+
+// HTMLRenderer emits an HTML fragment, for email targets
+type HTMLRenderer struct{}
+
+func (HTMLRenderer) Render(a *alert.Alert) (string, error) {
+	var sb strings.Builder
+	err := htmlTemplate.Execute(&sb, struct {
+		Alert       *alert.Alert
+		Title       string
+		Description string
+	}{
+		Alert:       a,
+		Title:       title(a),
+		Description: description(a),
+	})
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func (HTMLRenderer) ContentType() string {
+	return ContentTypeHTML
+}
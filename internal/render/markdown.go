@@ -0,0 +1,37 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// MarkdownRenderer emits CommonMark, for Teams, Mattermost, Google Chat and similar targets
+type MarkdownRenderer struct{}
+
+func (MarkdownRenderer) Render(a *alert.Alert) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**%s**\n\n", title(a))
+	fmt.Fprintf(&sb, "- **Source:** %s\n", a.Source)
+	fmt.Fprintf(&sb, "- **State:** `%s`\n", a.State)
+	if desc := description(a); desc != "" {
+		fmt.Fprintf(&sb, "- **Description:** %s\n", desc)
+	}
+	if len(a.Labels) > 0 {
+		sb.WriteString("- **Labels:**\n")
+		for _, k := range sortedKeys(a.Labels) {
+			if a.Labels[k] != "" {
+				fmt.Fprintf(&sb, "  - `%s`: %s\n", k, a.Labels[k])
+			}
+		}
+	}
+	for _, k := range sortedKeys(a.URLs) {
+		fmt.Fprintf(&sb, "- [%s](%s)\n", linkTitle(k), a.URLs[k])
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+func (MarkdownRenderer) ContentType() string {
+	return ContentTypeMarkdown
+}
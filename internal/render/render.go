@@ -0,0 +1,114 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// Renderer turns a canonical alert into the text format a destination understands
+type Renderer interface {
+	Render(a *alert.Alert) (string, error)
+	// ContentType is the MIME type of the rendered output
+	ContentType() string
+}
+
+// Renderer names used in config
+const (
+	NameSlack     = "slack"
+	NameMarkdown  = "markdown"
+	NamePlainText = "text"
+	NameHTML      = "html"
+	NameJSON      = "json"
+)
+
+// Content types of rendered output
+const (
+	ContentTypeSlack     = "text/x-slack-mrkdwn"
+	ContentTypeMarkdown  = "text/markdown"
+	ContentTypePlainText = "text/plain"
+	ContentTypeHTML      = "text/html"
+	ContentTypeJSON      = "application/json"
+)
+
+var renderers = map[string]Renderer{
+	NameSlack:     SlackRenderer{},
+	NameMarkdown:  MarkdownRenderer{},
+	NamePlainText: PlainTextRenderer{},
+	NameHTML:      HTMLRenderer{},
+	NameJSON:      JSONRenderer{},
+}
+
+// ForName returns the renderer registered under name
+func ForName(name string) (Renderer, error) {
+	r, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown renderer: %s", name)
+	}
+	return r, nil
+}
+
+// SlackRenderer emits Slack mrkdwn. The adapters already render source-specific layouts,
+// so this returns them as-is.
+type SlackRenderer struct {
+	Compact bool
+}
+
+func (r SlackRenderer) Render(a *alert.Alert) (string, error) {
+	if r.Compact {
+		return a.Summary, nil
+	}
+	return a.Message, nil
+}
+
+func (r SlackRenderer) ContentType() string {
+	return ContentTypeSlack
+}
+
+// JSONRenderer emits the normalized alert, for bots and webhook consumers
+type JSONRenderer struct{}
+
+func (JSONRenderer) Render(a *alert.Alert) (string, error) {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal alert: %v", err)
+	}
+	return string(data), nil
+}
+
+func (JSONRenderer) ContentType() string {
+	return ContentTypeJSON
+}
+
+// statusEmoji is shared by the generic renderers
+func statusEmoji(a *alert.Alert) string {
+	return DefaultEmoji.Status(a)
+}
+
+// description picks the most useful human text among the alert's annotations
+func description(a *alert.Alert) string {
+	for _, key := range []string{"description", "summary", "message", "reason"} {
+		if v := a.Annotations[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// sortedKeys returns map keys in a stable order for rendering
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// title is the one-line headline used by the generic renderers
+func title(a *alert.Alert) string {
+	return fmt.Sprintf("%s [%s] %s is %s", statusEmoji(a), a.Severity, a.Name, strings.ToUpper(a.Status))
+}
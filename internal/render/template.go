@@ -0,0 +1,112 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// Emoji are the symbols a template shows for an alert's status and priority
+type Emoji struct {
+	Firing     string
+	Resolved   string
+	NoData     string
+	Priorities map[string]string
+}
+
+// DefaultEmoji are the status emoji the generic renderers use
+var DefaultEmoji = Emoji{Firing: "🚨", Resolved: "✅", NoData: "⚠️"}
+
+// Status returns the emoji for the alert's status, falling back to the defaults for any the
+// set leaves out
+func (e Emoji) Status(a *alert.Alert) string {
+	var emoji, fallback string
+	switch a.Status {
+	case alert.StatusResolved:
+		emoji, fallback = e.Resolved, DefaultEmoji.Resolved
+	case alert.StatusNoData:
+		emoji, fallback = e.NoData, DefaultEmoji.NoData
+	default:
+		emoji, fallback = e.Firing, DefaultEmoji.Firing
+	}
+	if emoji == "" {
+		return fallback
+	}
+	return emoji
+}
+
+// Priority returns the emoji for the alert's priority, or nothing if the set has none
+func (e Emoji) Priority(a *alert.Alert) string {
+	return e.Priorities[a.Severity]
+}
+
+// TemplateRenderer renders alerts with a Go text/template. Templates see the canonical alert
+// as dot, plus these functions:
+//
+//	emoji            the status emoji
+//	priorityEmoji    the priority emoji, empty unless the emoji set defines one
+//	description      the most useful of the description, summary, message and reason annotations
+//	upper, lower     change case
+type TemplateRenderer struct {
+	name     string
+	template *template.Template
+}
+
+// NewTemplateRenderer parses source as the template called name
+func NewTemplateRenderer(name, source string, emoji Emoji) (*TemplateRenderer, error) {
+	return newTemplateRenderer(name, source, templateFuncs(emoji))
+}
+
+// NewLogQueryRenderer parses source as a log query or log group template. Besides the
+// functions of message templates, these quote alert values for the query they are put in,
+// since labels come from whoever sent the alert:
+//
+//	logql       escapes a value inside a LogQL "..." string
+//	insights    escapes a value inside a Logs Insights "..." or '...' string
+//	regex       escapes regular expression metacharacters, before logql or insights
+func NewLogQueryRenderer(name, source string) (*TemplateRenderer, error) {
+	funcs := templateFuncs(DefaultEmoji)
+	funcs["logql"] = logqlEscaper.Replace
+	funcs["insights"] = insightsEscaper.Replace
+	funcs["regex"] = regexp.QuoteMeta
+	return newTemplateRenderer(name, source, funcs)
+}
+
+var (
+	logqlEscaper    = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	insightsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `'`, `\'`, "\n", `\n`)
+)
+
+func templateFuncs(emoji Emoji) template.FuncMap {
+	return template.FuncMap{
+		"emoji":         emoji.Status,
+		"priorityEmoji": emoji.Priority,
+		"description":   description,
+		"upper":         strings.ToUpper,
+		"lower":         strings.ToLower,
+	}
+}
+
+func newTemplateRenderer(name, source string, funcs template.FuncMap) (*TemplateRenderer, error) {
+	t, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	return &TemplateRenderer{name: name, template: t}, nil
+}
+
+func (r *TemplateRenderer) Render(a *alert.Alert) (string, error) {
+	var out strings.Builder
+	if err := r.template.Execute(&out, a); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %v", r.name, err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// ContentType is Slack mrkdwn, since templates are written for the channels they post to
+func (r *TemplateRenderer) ContentType() string {
+	return ContentTypeSlack
+}
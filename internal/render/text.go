@@ -0,0 +1,43 @@
+package render
+
+import (
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// PlainTextRenderer emits unformatted text, for SMS, push and voice targets
+type PlainTextRenderer struct{}
+
+func (PlainTextRenderer) Render(a *alert.Alert) (string, error) {
+	lines := []string{title(a)}
+	if desc := description(a); desc != "" {
+		lines = append(lines, desc)
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		lines = append(lines, link)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (PlainTextRenderer) ContentType() string {
+	return ContentTypePlainText
+}
+
+// linkTitle turns a URL key into a human link label
+func linkTitle(key string) string {
+	switch key {
+	case alert.URLSource:
+		return "View alert"
+	case alert.URLDashboard:
+		return "View dashboard"
+	case alert.URLSilence:
+		return "Silence"
+	case alert.URLImage:
+		return "Graph"
+	case alert.URLPanel:
+		return "View panel"
+	default:
+		return key
+	}
+}
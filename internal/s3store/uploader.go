@@ -0,0 +1,36 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Uploader writes objects to an S3 bucket using the default AWS credential chain
+type Uploader struct {
+	Client *s3.Client
+	Bucket string
+}
+
+func NewUploader(bucket string) (*Uploader, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &Uploader{
+		Client: s3.NewFromConfig(cfg),
+		Bucket: bucket,
+	}, nil
+}
+
+func (u *Uploader) Upload(ctx context.Context, key string, data []byte) error {
+	_, err := u.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(u.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
@@ -0,0 +1,418 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/adapter"
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/dispatch"
+)
+
+// startedAt is reported as the uptime in the Alertmanager API status
+var startedAt = time.Now()
+
+// amAlert is an alert as the Alertmanager API v2 lists it
+type amAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+	Fingerprint  string            `json:"fingerprint"`
+	Receivers    []amReceiver      `json:"receivers"`
+	Status       amAlertStatus     `json:"status"`
+}
+
+type amReceiver struct {
+	Name string `json:"name"`
+}
+
+type amAlertStatus struct {
+	State       string   `json:"state"` // active or suppressed
+	SilencedBy  []string `json:"silencedBy"`
+	InhibitedBy []string `json:"inhibitedBy"`
+}
+
+// amSilence is a silence as the Alertmanager API v2 lists it
+type amSilence struct {
+	dispatch.APISilence
+	Status struct {
+		State string `json:"state"`
+	} `json:"status"`
+}
+
+// authorizeAlertmanager checks the bearer token the Alertmanager API requires, which amtool
+// sends from its --http.config.file. Without a token configured every request is refused.
+func (s *Server) authorizeAlertmanager(w http.ResponseWriter, r *http.Request) bool {
+	if !bearerAuthorized(r, s.config.AlertmanagerToken) {
+		log.Printf("Alertmanager API request verification failed")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// bearerAuthorized reports whether a request carries token as its bearer token. An empty token
+// authorizes nothing.
+func bearerAuthorized(r *http.Request, token string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token != "" && ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// parseFilter reads the filter query parameters, one matcher each
+func parseFilter(r *http.Request) ([]dispatch.Matcher, error) {
+	var matchers []dispatch.Matcher
+	for _, text := range r.URL.Query()["filter"] {
+		m, err := dispatch.ParseMatcher(text)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func matchesAll(matchers []dispatch.Matcher, labels map[string]string) bool {
+	for _, m := range matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryFlag reads a boolean query parameter, true unless it is "false"
+func queryFlag(r *http.Request, name string) bool {
+	return r.URL.Query().Get(name) != "false"
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleAMAlerts lists the active alerts for GET, filtered as Alertmanager filters them, and
+// dispatches the alerts Prometheus or amtool post
+func (s *Server) handleAMAlerts(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAlertmanager(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.listAMAlerts(w, r)
+	case http.MethodPost:
+		s.postAMAlerts(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) listAMAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts, err := s.amAlerts(r)
+	if err != nil {
+		writeAMAlertsError(w, err)
+		return
+	}
+	writeJSON(w, alerts)
+}
+
+// errReadAlerts is returned by amAlerts when the active alerts can't be read from the state
+// store; its other errors are the request's
+var errReadAlerts = errors.New("failed to read alerts")
+
+// writeAMAlertsError answers a request amAlerts failed
+func writeAMAlertsError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errReadAlerts) {
+		http.Error(w, "Failed to read alerts", http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// amAlerts returns the active alerts the request's filter, receiver, active and silenced
+// parameters select
+func (s *Server) amAlerts(r *http.Request) ([]amAlert, error) {
+	matchers, err := parseFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	var receiver *regexp.Regexp
+	if pattern := r.URL.Query().Get("receiver"); pattern != "" {
+		if receiver, err = regexp.Compile("^(?:" + pattern + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid receiver regex: %v", err)
+		}
+	}
+	showActive, showSilenced := queryFlag(r, "active"), queryFlag(r, "silenced")
+
+	active, err := s.dispatcher.ActiveAlerts(r.Context())
+	if err != nil {
+		log.Printf("Failed to read active alerts: %v", err)
+		return nil, errReadAlerts
+	}
+	alerts := []amAlert{}
+	for _, active := range active {
+		state := "active"
+		if len(active.SilencedBy) > 0 {
+			state = "suppressed"
+		}
+		if (state == "active" && !showActive) || (state == "suppressed" && !showSilenced) {
+			continue
+		}
+		if !matchesAll(matchers, active.Labels) {
+			continue
+		}
+		if receiver != nil && !receiver.MatchString(active.Alert.Channel) {
+			continue
+		}
+
+		endsAt := active.EndsAt
+		if endsAt.IsZero() {
+			endsAt = active.UpdatedAt.Add(dispatch.ResolveTimeout)
+		}
+		silencedBy := active.SilencedBy
+		if silencedBy == nil {
+			silencedBy = []string{}
+		}
+		annotations := active.Alert.Annotations
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		alerts = append(alerts, amAlert{
+			Labels:       active.Labels,
+			Annotations:  annotations,
+			StartsAt:     active.StartsAt,
+			EndsAt:       endsAt,
+			UpdatedAt:    active.UpdatedAt,
+			GeneratorURL: active.Alert.URLs[alert.URLSource],
+			Fingerprint:  active.Alert.Fingerprint(),
+			Receivers:    []amReceiver{{Name: active.Alert.Channel}},
+			Status:       amAlertStatus{State: state, SilencedBy: silencedBy, InhibitedBy: []string{}},
+		})
+	}
+	return alerts, nil
+}
+
+// amAlertGroup is a group of alerts as the Alertmanager API v2 lists them
+type amAlertGroup struct {
+	Labels   map[string]string `json:"labels"`
+	Receiver amReceiver        `json:"receiver"`
+	Alerts   []amAlert         `json:"alerts"`
+}
+
+// handleAMAlertGroups lists the active alerts grouped by channel and alertname, which is what
+// karma reads to draw its dashboard
+func (s *Server) handleAMAlertGroups(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAlertmanager(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	alerts, err := s.amAlerts(r)
+	if err != nil {
+		writeAMAlertsError(w, err)
+		return
+	}
+
+	groups := []amAlertGroup{}
+	index := make(map[[2]string]int)
+	for _, a := range alerts {
+		key := [2]string{a.Receivers[0].Name, a.Labels["alertname"]}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, amAlertGroup{
+				Labels:   map[string]string{"alertname": key[1]},
+				Receiver: amReceiver{Name: key[0]},
+			})
+		}
+		groups[i].Alerts = append(groups[i].Alerts, a)
+	}
+	writeJSON(w, groups)
+}
+
+func (s *Server) postAMAlerts(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	alerts, err := adapter.AdaptPostedAlerts(body, s.config.SlackChannels, s.config.AlarmMappings(), s.config)
+	if err != nil {
+		log.Printf("Failed to adapt posted alerts: %v", err)
+		writeError(w, "Failed to process alerts", err)
+		return
+	}
+
+	var firstErr error
+	for i, alertMsg := range alerts {
+		alertID := fmt.Sprintf("alertmanager_%d_%d", time.Now().UnixNano(), i)
+		if err := s.dispatcher.DispatchPosted(r.Context(), alertMsg, alertID); err != nil {
+			log.Printf("Failed to send posted alert %s to Slack: %v", alertMsg.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		writeError(w, "Failed to send to Slack", firstErr)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAMSilences lists the API silences for GET and creates or updates one for POST
+func (s *Server) handleAMSilences(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAlertmanager(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.listAMSilences(w, r)
+	case http.MethodPost:
+		s.postAMSilence(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) listAMSilences(w http.ResponseWriter, r *http.Request) {
+	matchers, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	silences, err := s.dispatcher.Silences(r.Context())
+	if err != nil {
+		log.Printf("Failed to read silences: %v", err)
+		http.Error(w, "Failed to read silences", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	listed := []amSilence{}
+	for _, silence := range silences {
+		// As in Alertmanager, filters match the labels the silence's equality matchers name
+		labels := make(map[string]string, len(silence.Matchers))
+		for _, m := range silence.Matchers {
+			if !m.IsRegex && (m.IsEqual == nil || *m.IsEqual) {
+				labels[m.Name] = m.Value
+			}
+		}
+		if !matchesAll(matchers, labels) {
+			continue
+		}
+		listed = append(listed, toAMSilence(silence, now))
+	}
+	writeJSON(w, listed)
+}
+
+func (s *Server) postAMSilence(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	var silence dispatch.APISilence
+	if err := json.Unmarshal([]byte(body), &silence); err != nil {
+		http.Error(w, fmt.Sprintf("invalid silence: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.dispatcher.PutSilence(r.Context(), silence)
+	if err != nil {
+		writeSilenceError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"silenceID": id})
+}
+
+// handleAMSilence gets or expires the silence /api/v2/silence/<id>
+func (s *Server) handleAMSilence(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAlertmanager(w, r) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/v2/silence/")
+
+	switch r.Method {
+	case http.MethodGet:
+		silences, err := s.dispatcher.Silences(r.Context())
+		if err != nil {
+			log.Printf("Failed to read silences: %v", err)
+			http.Error(w, "Failed to read silences", http.StatusInternalServerError)
+			return
+		}
+		for _, silence := range silences {
+			if silence.ID == id {
+				writeJSON(w, toAMSilence(silence, time.Now()))
+				return
+			}
+		}
+		writeSilenceError(w, dispatch.ErrSilenceNotFound)
+	case http.MethodDelete:
+		if err := s.dispatcher.ExpireSilence(r.Context(), id); err != nil {
+			writeSilenceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func toAMSilence(silence dispatch.APISilence, now time.Time) amSilence {
+	listed := amSilence{APISilence: silence}
+	listed.Status.State = silence.State(now)
+	return listed
+}
+
+// writeSilenceError responds as Alertmanager does: 404 for unknown silences, 400 for invalid
+// ones, with the reason as plain text
+func writeSilenceError(w http.ResponseWriter, err error) {
+	var invalid *dispatch.InvalidSilenceError
+	switch {
+	case errors.Is(err, dispatch.ErrSilenceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.As(err, &invalid):
+		http.Error(w, invalid.Reason, http.StatusBadRequest)
+	default:
+		log.Printf("Failed to save silence: %v", err)
+		http.Error(w, "Failed to save silence", http.StatusInternalServerError)
+	}
+}
+
+// handleAMStatus reports the dispatcher as a single Alertmanager without clustering, which
+// dashboards such as karma read before anything else
+func (s *Server) handleAMStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAlertmanager(w, r) {
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"cluster": map[string]interface{}{"name": "", "status": "disabled", "peers": []interface{}{}},
+		"versionInfo": map[string]string{
+			"version":   "alert-dispatcher",
+			"revision":  "",
+			"branch":    "",
+			"buildUser": "",
+			"buildDate": "",
+			"goVersion": runtime.Version(),
+		},
+		"config": map[string]string{"original": ""},
+		"uptime": startedAt,
+	})
+}
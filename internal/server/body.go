@@ -0,0 +1,28 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Alertmanager groups can be hundreds of kilobytes; reading them into pooled buffers saves
+// regrowing a new one for every webhook during a storm
+var bodyBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// readBody reads a request body into a pooled buffer and returns a copy as a string, which is
+// safe to keep after the buffer is reused
+func readBody(r io.Reader) (string, error) {
+	buf := bodyBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		bodyBuffers.Put(buf)
+	}()
+
+	if _, err := buf.ReadFrom(r); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const alertsCommandUsage = "Usage: `/alerts ack <pattern>`, `/alerts dismiss <pattern>` or `/alerts silence <pattern> <duration>`, e.g. `/alerts ack payments-*` or `/alerts silence payments-* 2h`"
+
+// handleCommand handles the /alerts slash command, which acknowledges, dismisses or silences
+// every open alert whose name matches a pattern
+func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if !s.verifySlackRequest(r, body) {
+		log.Printf("Slack request verification failed")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	formData, err := url.ParseQuery(string(body))
+	if err != nil {
+		log.Printf("Failed to parse form data: %v", err)
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user := formData.Get("user_name")
+	log.Printf("Command %s %q from %s", formData.Get("command"), formData.Get("text"), user)
+
+	var action string
+	subcommand, pattern, _ := strings.Cut(strings.TrimSpace(formData.Get("text")), " ")
+	if subcommand == "silence" {
+		s.silenceCommand(w, r, pattern, user)
+		return
+	}
+	switch subcommand {
+	case "ack", "acknowledge":
+		action = "acknowledge"
+	case "dismiss":
+		action = "dismiss"
+	}
+	pattern = strings.TrimSpace(pattern)
+	if action == "" || pattern == "" {
+		writeCommandResponse(w, "ephemeral", alertsCommandUsage)
+		return
+	}
+
+	names, err := s.dispatcher.CloseMatching(r.Context(), action, pattern, user)
+	if err != nil {
+		writeCommandResponse(w, "ephemeral", fmt.Sprintf("⚠️ %v", err))
+		return
+	}
+	if len(names) == 0 {
+		writeCommandResponse(w, "ephemeral", fmt.Sprintf("No open alerts match `%s`", pattern))
+		return
+	}
+	log.Printf("%d alerts matching %q closed with %s by %s", len(names), pattern, action, user)
+	writeCommandResponse(w, "in_channel", closedSummary(action, names, user))
+}
+
+// silenceCommand silences the open alerts matching a pattern for a duration such as 2h, here
+// and, with silence sync, in Grafana or Alertmanager
+func (s *Server) silenceCommand(w http.ResponseWriter, r *http.Request, args, user string) {
+	fields := strings.Fields(args)
+	if len(fields) != 2 {
+		writeCommandResponse(w, "ephemeral", alertsCommandUsage)
+		return
+	}
+	pattern := fields[0]
+	duration, err := time.ParseDuration(fields[1])
+	if err != nil {
+		writeCommandResponse(w, "ephemeral", fmt.Sprintf("⚠️ Invalid duration %q, use e.g. `30m` or `2h`", fields[1]))
+		return
+	}
+
+	names, upstream, err := s.dispatcher.SilenceMatching(r.Context(), pattern, duration, user)
+	if err != nil {
+		writeCommandResponse(w, "ephemeral", fmt.Sprintf("⚠️ %v", err))
+		return
+	}
+	if len(names) == 0 {
+		writeCommandResponse(w, "ephemeral", fmt.Sprintf("No open alerts match `%s`", pattern))
+		return
+	}
+	log.Printf("%d alerts matching %q silenced for %s by %s", len(names), pattern, duration, user)
+
+	text := fmt.Sprintf("🔕 *%d alerts silenced for %s by %s*", len(names), duration, user)
+	if upstream > 0 {
+		text += fmt.Sprintf(" (%d in Grafana or Alertmanager too)", upstream)
+	}
+	for _, name := range names {
+		text += "\n• " + name
+	}
+	writeCommandResponse(w, "in_channel", text)
+}
+
+// closedSummary describes alerts acknowledged or dismissed in bulk
+func closedSummary(action string, names []string, user string) string {
+	icon, verb := "✅", "acknowledged"
+	if action == "dismiss" {
+		icon, verb = "❌", "dismissed"
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%s _No open alerts left to be %s (%s)_", icon, verb, user)
+	}
+
+	text := fmt.Sprintf("%s *%d alerts %s by %s*", icon, len(names), verb, user)
+	for _, name := range names {
+		text += "\n• " + name
+	}
+	return text
+}
+
+func writeCommandResponse(w http.ResponseWriter, responseType, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"response_type": responseType,
+		"text":          text,
+	})
+}
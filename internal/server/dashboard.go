@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// dashboardRefresh is how often the dashboard page reloads itself
+const dashboardRefresh = 30 * time.Second
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": since,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{len .Alerts}} firing alerts</title>
+<style>
+body { font-family: sans-serif; background: #111; color: #eee; margin: 1em; }
+h1 { font-size: 1.4em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #333; }
+a { color: #8cf; }
+.P0 { background: #b71c1c; }
+.P1 { background: #e65100; }
+.P2 { background: #f9a825; color: #111; }
+.suppressed { opacity: 0.5; }
+.badge { padding: 2px 8px; border-radius: 4px; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{len .Alerts}} firing alerts <small>updated {{.Updated.Format "15:04:05 MST"}}</small></h1>
+{{- if .Alerts}}
+<table>
+<tr><th>Priority</th><th>Alert</th><th>Source</th><th>Channel</th><th>Firing for</th><th>Description</th></tr>
+{{- range .Alerts}}
+<tr{{if eq .Status.State "suppressed"}} class="suppressed"{{end}}>
+<td><span class="badge {{index .Labels "severity"}}">{{index .Labels "severity"}}</span></td>
+<td>{{if .GeneratorURL}}<a href="{{.GeneratorURL}}">{{index .Labels "alertname"}}</a>{{else}}{{index .Labels "alertname"}}{{end}}{{if .Status.SilencedBy}} (silenced){{end}}</td>
+<td>{{index .Labels "source"}}</td>
+<td>{{(index .Receivers 0).Name}}</td>
+<td>{{since .StartsAt}}</td>
+<td>{{with index .Annotations "description"}}{{.}}{{else}}{{index .Annotations "summary"}}{{end}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p>Nothing is firing.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// handleDashboard serves a page listing the firing alerts, most urgent first, that reloads
+// itself, for NOC screens without karma. It takes the Alertmanager API's filter, receiver and
+// silenced parameters, and its token as the token parameter, since a browser can't send one.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" || s.config.AlertmanagerToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AlertmanagerToken)) != 1 {
+		if !s.authorizeAlertmanager(w, r) {
+			return
+		}
+	}
+	alerts, err := s.amAlerts(r)
+	if err != nil {
+		writeAMAlertsError(w, err)
+		return
+	}
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if alerts[i].Labels["severity"] != alerts[j].Labels["severity"] {
+			return alerts[i].Labels["severity"] < alerts[j].Labels["severity"]
+		}
+		return alerts[i].StartsAt.Before(alerts[j].StartsAt)
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = dashboardTemplate.Execute(w, struct {
+		Alerts  []amAlert
+		Refresh int
+		Updated time.Time
+	}{alerts, int(dashboardRefresh.Seconds()), time.Now()})
+	if err != nil {
+		log.Printf("Failed to render dashboard: %v", err)
+	}
+}
+
+// since renders how long ago t was, to the minute
+func since(t time.Time) string {
+	d := time.Since(t).Round(time.Minute)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"alert-dispatcher/internal/errs"
+)
+
+// ErrorResponse is the body of a failed API request; Code is one of the errs codes, for
+// senders deciding whether to retry
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// writeError responds with the status matching err's kind, counting it as a failed alert.
+// Alerts over their source's quota are told when to retry.
+func writeError(w http.ResponseWriter, message string, err error) {
+	errs.Count(err)
+
+	var quotaErr *errs.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(quotaErr.RetryAfter.Seconds())+1))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errs.HTTPStatus(err))
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message, Code: errs.Code(err)})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// SlackEvent is the part of an Events API request we handle: the URL verification handshake and
+// link_shared events
+type SlackEvent struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Event     struct {
+		Type      string `json:"type"`
+		Channel   string `json:"channel"`
+		MessageTS string `json:"message_ts"`
+		Links     []struct {
+			URL string `json:"url"`
+		} `json:"links"`
+	} `json:"event"`
+}
+
+// handleSlackEvent unfurls alert permalinks shared in Slack. Slack expects a reply within three
+// seconds, so unfurling happens after the response.
+func (s *Server) handleSlackEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if !s.verifySlackRequest(r, body) {
+		log.Printf("Slack request verification failed")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var event SlackEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		log.Printf("Failed to unmarshal Slack event: %v", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case event.Type == "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(event.Challenge))
+		return
+	case event.Type == "event_callback" && event.Event.Type == "link_shared":
+		links := make([]string, len(event.Event.Links))
+		for i, link := range event.Event.Links {
+			links[i] = link.URL
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), s.config.SlackTimeout)
+			defer cancel()
+			if err := s.dispatcher.UnfurlLinks(ctx, event.Event.Channel, event.Event.MessageTS, links); err != nil {
+				log.Printf("Failed to unfurl links in %s: %v", event.Event.Channel, err)
+			}
+		}()
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAlertPermalink serves the archived alert behind a permalink as JSON to holders of the
+// permalink token, since alert IDs can be guessed. The source payload is left out since it may
+// carry account details.
+func (s *Server) handleAlertPermalink(w http.ResponseWriter, r *http.Request) {
+	alertID := strings.TrimPrefix(r.URL.Path, "/alerts/")
+	if alertID == "" || strings.Contains(alertID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if !bearerAuthorized(r, s.config.PermalinkToken) {
+		log.Printf("Permalink request for alert %s failed verification", alertID)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	record, ok, err := s.dispatcher.AlertRecord(r.Context(), alertID)
+	if err != nil {
+		log.Printf("Failed to look up alert %s: %v", alertID, err)
+		http.Error(w, "Failed to look up alert", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	record.Payload = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
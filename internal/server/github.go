@@ -0,0 +1,23 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// githubEvents are the webhook events mapped to alerts; GitHub sends the rest, including the
+// ping on creating a webhook, when the webhook subscribes to them
+var githubEvents = map[string]bool{"workflow_run": true, "deployment_status": true}
+
+// verifyGitHubSignature checks the "sha256=<hex HMAC-SHA256>" of the body GitHub signs with
+// the webhook's secret. Without a secret nothing verifies.
+func verifyGitHubSignature(secret, signature, body string) bool {
+	if secret == "" {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(body))
+	expected := "sha256=" + hex.EncodeToString(h.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
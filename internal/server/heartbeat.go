@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// statusRecorder keeps the status a handler responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// heartbeat records a heartbeat of input for every alert posted to the handler that it doesn't
+// reject as unauthorized or unparseable, so an input that goes silent, or whose requests are
+// all rejected, can be alerted on. Alerts that fail to deliver still count, since they arrived.
+// An empty input is the request path without its leading slash, as for webhook/<name>.
+func (s *Server) heartbeat(input string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r)
+		if r.Method != http.MethodPost || (recorder.status >= 400 && recorder.status < 500) {
+			return
+		}
+		name := input
+		if name == "" {
+			name = strings.TrimPrefix(r.URL.Path, "/")
+		}
+		s.dispatcher.Heartbeat(r.Context(), name)
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"alert-dispatcher/internal/adapter"
+)
+
+// ingestAuth reports whether a request to a built-in webhook, with its body, carries the
+// webhook's secret, which is never empty
+type ingestAuth func(r *http.Request, body, secret string) bool
+
+// ingestSource is a built-in webhook served at /<input>/webhook, parsed by the adapter of its
+// input: how its requests are authenticated and how their alerts are dispatched
+type ingestSource struct {
+	input   string // the path, heartbeat input and adapter name
+	title   string // the sender, in logs
+	secret  string
+	env     string // where the secret is set, in logs
+	auth    ingestAuth
+	event   func(r *http.Request) bool // whether the request is an event the adapter maps; nil for all
+	ignored error                      // adapter error for events accepted and ignored
+	// failuresOnly sources report successes all the time, so only recoveries from failures are
+	// posted
+	failuresOnly bool
+	// openWithoutSecret sources were served before they had a secret, so without one they keep
+	// accepting every request rather than disappearing on upgrade
+	openWithoutSecret bool
+}
+
+// authorized reports whether the request may be dispatched: it carries the secret, or the
+// source has none and is open without one
+func (source ingestSource) authorized(r *http.Request, body string) bool {
+	if source.secret == "" {
+		return source.openWithoutSecret
+	}
+	return source.auth(r, body, source.secret)
+}
+
+// ingestSources are the built-in webhooks. Each is only served when its secret is set, since
+// anyone who can post to one can page, except Grafana's, which was served before it had one.
+func (s *Server) ingestSources() []ingestSource {
+	return []ingestSource{
+		// Grafana, New Relic, Azure Monitor, Google Cloud Monitoring and Alertmanager alerts
+		{input: "grafana", title: "Grafana", secret: s.config.GrafanaSecret, env: "GRAFANA_WEBHOOK_SECRET", auth: secretBearerHeaderOrQuery,
+			openWithoutSecret: true,
+		},
+		// Monitor notifications from a Datadog webhook integration
+		{input: "datadog", title: "Datadog", secret: s.config.DatadogSecret, env: "DATADOG_WEBHOOK_SECRET", auth: secretHeader},
+		// Issue alerts from a Sentry internal integration or the legacy WebHooks plugin
+		{input: "sentry", title: "Sentry", secret: s.config.SentrySecret, env: "SENTRY_CLIENT_SECRET", auth: sentrySignature},
+		// Trigger events from the Zabbix webhook media type
+		{input: "zabbix", title: "Zabbix", secret: s.config.ZabbixSecret, env: "ZABBIX_WEBHOOK_SECRET", auth: secretHeader},
+		// Host and service notifications from Nagios or Icinga
+		{input: "nagios", title: "Nagios", secret: s.config.NagiosSecret, env: "NAGIOS_WEBHOOK_SECRET", auth: secretHeader},
+		// Problem notifications from a Dynatrace custom integration
+		{input: "dynatrace", title: "Dynatrace", secret: s.config.DynatraceSecret, env: "DYNATRACE_WEBHOOK_SECRET", auth: secretHeader},
+		// Saved search alerts from Splunk's webhook alert action, which can't add headers
+		{input: "splunk", title: "Splunk", secret: s.config.SplunkToken, env: "SPLUNK_WEBHOOK_TOKEN", auth: secretHeaderOrQuery},
+		// Rule actions from a Kibana webhook connector
+		{input: "kibana", title: "Kibana", secret: s.config.KibanaSecret, env: "KIBANA_WEBHOOK_SECRET", auth: secretHeader},
+		// Monitor notifications from an Uptime Kuma webhook notification
+		{input: "uptimekuma", title: "Uptime Kuma", secret: s.config.UptimeKumaSecret, env: "UPTIME_KUMA_WEBHOOK_SECRET", auth: secretHeader},
+		// Check state changes from a Pingdom webhook integration, which can't add headers
+		{input: "pingdom", title: "Pingdom", secret: s.config.PingdomToken, env: "PINGDOM_WEBHOOK_TOKEN", auth: secretHeaderOrQuery},
+		// Uptime test state changes from a StatusCake webhook contact, which can't add headers
+		{input: "statuscake", title: "StatusCake", secret: s.config.StatusCakeToken, env: "STATUSCAKE_WEBHOOK_TOKEN", auth: secretHeaderOrQuery},
+		// workflow_run and deployment_status events from a repository or organization webhook
+		{input: "github", title: "GitHub", secret: s.config.GitHubSecret, env: "GITHUB_WEBHOOK_SECRET", auth: githubSignature,
+			event:        func(r *http.Request) bool { return githubEvents[r.Header.Get("X-GitHub-Event")] },
+			ignored:      adapter.ErrGitHubEventIgnored,
+			failuresOnly: true,
+		},
+		// Pipeline events from a project or group webhook; other events it subscribes to, such as
+		// pushes, are ignored
+		{input: "gitlab", title: "GitLab", secret: s.config.GitLabToken, env: "GITLAB_WEBHOOK_TOKEN", auth: gitlabToken,
+			event:        func(r *http.Request) bool { return r.Header.Get("X-Gitlab-Event") == "Pipeline Hook" },
+			ignored:      adapter.ErrGitLabEventIgnored,
+			failuresOnly: true,
+		},
+		// Application notifications from the ArgoCD notifications webhook service
+		{input: "argocd", title: "ArgoCD", secret: s.config.ArgoCDSecret, env: "ARGOCD_WEBHOOK_SECRET", auth: secretHeader,
+			ignored:      adapter.ErrArgoCDEventIgnored,
+			failuresOnly: true,
+		},
+		// Events from a Flux notification-controller generic-hmac provider
+		{input: "flux", title: "Flux", secret: s.config.FluxSecret, env: "FLUX_WEBHOOK_SECRET", auth: fluxSignature,
+			ignored:      adapter.ErrFluxEventIgnored,
+			failuresOnly: true,
+		},
+	}
+}
+
+// registerIngestSources serves the built-in webhooks whose secrets are set, and those open
+// without one with a warning
+func (s *Server) registerIngestSources() {
+	for _, source := range s.ingestSources() {
+		switch {
+		case source.secret == "" && source.openWithoutSecret:
+			log.Printf("WARNING: %s is not set, so the %s webhook accepts alerts from anyone; set it to require a secret", source.env, source.title)
+		case source.secret == "":
+			log.Printf("%s is not set, not serving the %s webhook", source.env, source.title)
+			continue
+		}
+		http.HandleFunc("/"+source.input+"/webhook", s.heartbeat(source.input, s.handleIngest(source)))
+	}
+}
+
+// handleIngest authenticates requests to a built-in webhook, adapts them and dispatches their
+// alerts
+func (s *Server) handleIngest(source ingestSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := readBody(r.Body)
+		if err != nil {
+			log.Printf("Failed to read request body: %v", err)
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if !source.authorized(r, body) {
+			log.Printf("%s request verification failed", source.title)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if source.event != nil && !source.event(r) {
+			writeWebhookStatus(w, "ignored")
+			return
+		}
+
+		adapt := s.inputAdapter(source.input)
+		alertMsg, err := adapt(body, s.config.SlackChannels, s.config.AlarmMappings(), s.config)
+		if source.ignored != nil && errors.Is(err, source.ignored) {
+			writeWebhookStatus(w, "ignored")
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to adapt %s alert: %v", source.title, err)
+			writeError(w, "Failed to process alert", err)
+			return
+		}
+
+		// Alerts of a group arrive together, so IDs need more than seconds to stay apart
+		alertID := fmt.Sprintf("%s_%d", alertMsg.Source, time.Now().UnixNano())
+		if source.failuresOnly {
+			dispatched, err := s.dispatcher.DispatchFailures(r.Context(), alertMsg, alertID)
+			if err != nil {
+				log.Printf("Failed to send %s alert to Slack: %v", source.title, err)
+				writeError(w, "Failed to send to Slack", err)
+				return
+			}
+			if !dispatched {
+				writeWebhookStatus(w, "ignored")
+				return
+			}
+			log.Printf("Sent %s %s alert %s (%s) to %s", alertMsg.Severity, source.title, alertMsg.Name, alertMsg.State, alertMsg.Channel)
+		} else {
+			log.Printf("Sending %s %s alert to %s", alertMsg.Severity, alertMsg.Source, alertMsg.Channel)
+			if err := s.dispatcher.Dispatch(r.Context(), alertMsg, alertID); err != nil {
+				log.Printf("Failed to send %s alert to Slack: %v", source.title, err)
+				writeError(w, "Failed to send to Slack", err)
+				return
+			}
+		}
+		writeProcessed(w, alertID)
+	}
+}
+
+// secretHeader takes the secret from the X-Webhook-Secret header
+func secretHeader(r *http.Request, body, secret string) bool {
+	return hmac.Equal([]byte(r.Header.Get("X-Webhook-Secret")), []byte(secret))
+}
+
+// secretHeaderOrQuery also takes the secret from the token query parameter, for senders that
+// can't add headers
+func secretHeaderOrQuery(r *http.Request, body, secret string) bool {
+	return secretHeader(r, body, secret) || hmac.Equal([]byte(r.URL.Query().Get("token")), []byte(secret))
+}
+
+// secretBearerHeaderOrQuery also takes the secret as a bearer token, which Grafana and
+// Alertmanager send from their authorization settings
+func secretBearerHeaderOrQuery(r *http.Request, body, secret string) bool {
+	return bearerAuthorized(r, secret) || secretHeaderOrQuery(r, body, secret)
+}
+
+// gitlabToken takes the webhook's secret token from the X-Gitlab-Token header
+func gitlabToken(r *http.Request, body, secret string) bool {
+	return hmac.Equal([]byte(r.Header.Get("X-Gitlab-Token")), []byte(secret))
+}
+
+// githubSignature checks the signature GitHub sends in X-Hub-Signature-256
+func githubSignature(r *http.Request, body, secret string) bool {
+	return verifyGitHubSignature(secret, r.Header.Get("X-Hub-Signature-256"), body)
+}
+
+// fluxSignature checks the signature generic-hmac providers send in X-Signature, made the way
+// GitHub makes its own
+func fluxSignature(r *http.Request, body, secret string) bool {
+	return verifyGitHubSignature(secret, r.Header.Get("X-Signature"), body)
+}
+
+// sentrySignature checks the signature Sentry sends in Sentry-Hook-Signature
+func sentrySignature(r *http.Request, body, secret string) bool {
+	return verifySentrySignature(secret, r.Header.Get("Sentry-Hook-Signature"), body)
+}
+
+// writeWebhookStatus answers a webhook with 200 and whether its event was processed or ignored
+func writeWebhookStatus(w http.ResponseWriter, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
+
+// writeProcessed answers a request whose alert was dispatched with its ID and where to ask what
+// became of it
+func writeProcessed(w http.ResponseWriter, alertID string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":   "processed",
+		"alert_id": alertID,
+		"delivery": receiptPath(alertID),
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"alert-dispatcher/internal/audit"
+)
+
+// MattermostAction is the request Mattermost sends when an interactive message button is pressed
+type MattermostAction struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name"`
+	PostID   string `json:"post_id"`
+	Context  struct {
+		Action  string `json:"action"`
+		AlertID string `json:"alert_id"`
+		Secret  string `json:"secret"`
+	} `json:"context"`
+}
+
+// handleMattermostAction handles the Acknowledge and Dismiss buttons of Mattermost alerts with the
+// same responses as the Slack buttons
+func (s *Server) handleMattermostAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var action MattermostAction
+	if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+		log.Printf("Failed to decode Mattermost action: %v", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	secret := s.config.Mattermost.ActionSecret
+	if secret == "" || subtle.ConstantTimeCompare([]byte(action.Context.Secret), []byte(secret)) != 1 {
+		log.Printf("Mattermost action verification failed")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	actionType, alertID := action.Context.Action, action.Context.AlertID
+	if _, ok := actionPastTense[actionType]; !ok {
+		log.Printf("Unknown Mattermost action: %s", actionType)
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	user := action.UserName
+	if user == "" {
+		user = action.UserID
+	}
+
+	var alertInfo AlertInfo
+	if record, ok, err := s.dispatcher.Archive().Get(r.Context(), alertID); err == nil && ok && record.Alert != nil {
+		alertInfo.Name = record.Alert.Name
+	}
+
+	log.Printf("Alert %s (%s) %s by %s on Mattermost", alertID, alertInfo.Name, actionPastTense[actionType], user)
+	s.dispatcher.CloseAlert(r.Context(), actionType, alertID, user, audit.ViaMattermost)
+
+	// Replacing the post's attachments removes the buttons
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"update": map[string]interface{}{
+			"message": actionResponseText(actionType, alertInfo, alertID, user),
+			"props":   map[string]interface{}{"attachments": []interface{}{}},
+		},
+	})
+}
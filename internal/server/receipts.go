@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// receiptPath is where the delivery receipt of an alert is served
+func receiptPath(alertID string) string {
+	return "/api/alerts/" + alertID + "/delivery"
+}
+
+// handleReceipt serves GET /api/alerts/<alert ID>/delivery: whether, where and when the alert
+// was delivered, for senders confirming their alert reached someone. Receipts of alerts sent to
+// a configured webhook take its secret; others take the receipts token.
+func (s *Server) handleReceipt(w http.ResponseWriter, r *http.Request) {
+	alertID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/alerts/"), "/delivery")
+	if !ok || alertID == "" || strings.Contains(alertID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	receipt, ok, err := s.dispatcher.Receipt(r.Context(), alertID)
+	if err != nil {
+		log.Printf("Failed to look up receipt of alert %s: %v", alertID, err)
+		http.Error(w, "Failed to look up receipt", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.receiptAuthorized(r, receipt.Webhook) {
+		log.Printf("Receipt request for alert %s failed verification", alertID)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// receiptAuthorized reports whether a request may read the receipt of an alert from a webhook,
+// empty for alerts that didn't come through one. A webhook that is no longer configured, or an
+// unset receipts token, refuses every request.
+func (s *Server) receiptAuthorized(r *http.Request, webhookName string) bool {
+	if webhookName != "" {
+		webhook, configured := s.config.Webhooks[webhookName]
+		return configured && webhookAuthorized(r, webhook)
+	}
+	return bearerAuthorized(r, s.config.ReceiptsToken)
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// verifySentrySignature checks the hex HMAC-SHA256 of the body Sentry signs with the
+// integration's client secret. Without a secret nothing verifies.
+func verifySentrySignature(secret, signature, body string) bool {
+	if secret == "" {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(body))
+	expected := hex.EncodeToString(h.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
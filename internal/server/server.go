@@ -2,10 +2,12 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,8 +18,11 @@ import (
 	"strings"
 	"time"
 
-	"alert-dispatcher/internal/adapter"
+	"alert-dispatcher/internal/audit"
 	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/dispatch"
+	"alert-dispatcher/internal/httpclient"
+	"alert-dispatcher/internal/metrics"
 	"alert-dispatcher/notifier"
 )
 
@@ -25,20 +30,32 @@ type Server struct {
 	signingSecret string
 	port          string
 	config        *config.Config
+	dispatcher    *dispatch.Dispatcher
+	client        *http.Client // pooled client for Slack response_url callbacks
+	webhookLimits *webhookLimiter
 }
 
 type SlackPayload struct {
 	Type    string `json:"type"`
 	Actions []struct {
-		ActionID string `json:"action_id"`
-		Value    string `json:"value"`
+		ActionID       string `json:"action_id"`
+		Value          string `json:"value"`
+		SelectedOption struct {
+			Value string `json:"value"`
+		} `json:"selected_option"`
 	} `json:"actions"`
 	User struct {
+		ID   string `json:"id"`
 		Name string `json:"name"`
 	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
 	ResponseURL string `json:"response_url"`
 	Message     struct {
-		Text   string `json:"text"`
+		Ts       string `json:"ts"`
+		ThreadTs string `json:"thread_ts"`
+		Text     string `json:"text"`
 		Blocks []struct {
 			Type string `json:"type"`
 			Text struct {
@@ -48,18 +65,54 @@ type SlackPayload struct {
 	} `json:"message"`
 }
 
-func NewServer(signingSecret, port string, cfg *config.Config) *Server {
+func NewServer(signingSecret, port string, cfg *config.Config, dispatcher *dispatch.Dispatcher) *Server {
 	return &Server{
 		signingSecret: signingSecret,
 		port:          port,
 		config:        cfg,
+		dispatcher:    dispatcher,
+		client:        httpclient.New(0),
+		webhookLimits: &webhookLimiter{windows: make(map[string]*webhookWindow)},
 	}
 }
 
 func (s *Server) Start() error {
 	http.HandleFunc("/slack/events", s.handleInteractive)
-	http.HandleFunc("/grafana/webhook", s.handleGrafanaWebhook)
+	http.HandleFunc("/slack/commands", s.handleCommand)
+	http.HandleFunc("/slack/event-subscriptions", s.handleSlackEvent)
+	// Alert IDs can be guessed, so archived alerts are only served to holders of the token
+	if s.config.PermalinkToken != "" {
+		http.HandleFunc("/alerts/", s.handleAlertPermalink)
+	} else {
+		log.Printf("PERMALINK_TOKEN is not set, not serving alert permalinks")
+	}
+	http.HandleFunc("/api/alerts/", s.handleReceipt)
+	if s.config.Mattermost.URL != "" && s.config.Mattermost.ActionSecret != "" {
+		http.HandleFunc("/mattermost/actions", s.handleMattermostAction)
+	}
+	s.registerIngestSources()
+	http.HandleFunc("/webhook/", s.heartbeat("", s.handleConfiguredWebhook))
+	// The Alertmanager API pages and silences, so it is only served behind its token
+	if s.config.AlertmanagerToken != "" {
+		http.HandleFunc("/api/v2/alerts", s.heartbeat("alertmanager_api", s.handleAMAlerts))
+		http.HandleFunc("/api/v2/alerts/groups", s.handleAMAlertGroups)
+		http.HandleFunc("/api/v2/silences", s.handleAMSilences)
+		http.HandleFunc("/api/v2/silence/", s.handleAMSilence)
+		http.HandleFunc("/api/v2/status", s.handleAMStatus)
+		http.HandleFunc("/dashboard", s.handleDashboard)
+	} else {
+		log.Printf("ALERTMANAGER_API_TOKEN is not set, not serving the Alertmanager API or dashboard")
+	}
+	if telegram := s.config.Notifiers.Telegram; telegram != nil {
+		if telegram.WebhookSecret != "" {
+			http.HandleFunc("/telegram/webhook", s.handleTelegramCallback)
+		} else {
+			log.Printf("TELEGRAM_WEBHOOK_SECRET is not set, Telegram buttons won't work")
+		}
+	}
 	http.HandleFunc("/health", s.healthCheck)
+	http.HandleFunc("/reports/noise", s.handleNoiseReport)
+	http.Handle("/metrics", metrics.Handler())
 	log.Printf("Server starting on port %s", s.port)
 	return http.ListenAndServe(":"+s.port, nil)
 }
@@ -69,6 +122,12 @@ func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// handleNoiseReport returns the actionable/not actionable feedback per alert rule, noisiest first
+func (s *Server) handleNoiseReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.dispatcher.NoiseReport())
+}
+
 func (s *Server) handleInteractive(w http.ResponseWriter, r *http.Request) {
 	log.Printf("=== New Request ===")
 	log.Printf("Method: %s", r.Method)
@@ -146,6 +205,213 @@ func (s *Server) handleInteractive(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Action: %s, Value: %s, User: %s", actionType, alertID, user)
 
+	// Details and Raw payload reply in-thread and leave the original message untouched
+	if actionType == "details" || actionType == "raw_payload" {
+		threadTS := slackPayload.Message.ThreadTs
+		if threadTS == "" {
+			threadTS = slackPayload.Message.Ts
+		}
+
+		var err error
+		if actionType == "details" {
+			err = s.dispatcher.PostDetails(r.Context(), slackPayload.Channel.ID, threadTS, alertID)
+		} else {
+			err = s.dispatcher.PostRawPayload(r.Context(), slackPayload.Channel.ID, threadTS, alertID)
+		}
+		if err != nil {
+			log.Printf("Failed to handle %s for %s: %v", actionType, alertID, err)
+			http.Error(w, "Failed to post to thread", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// Digest buttons act on every open alert posted in the digest's thread
+	if actionType == "acknowledge_all" || actionType == "dismiss_all" {
+		action := strings.TrimSuffix(actionType, "_all")
+		names := s.dispatcher.CloseDigest(r.Context(), action, slackPayload.Message.Ts, user)
+		log.Printf("%d digest alerts closed with %s by %s", len(names), action, user)
+
+		response := map[string]interface{}{
+			"text":             slackPayload.Message.Text + "\n\n" + closedSummary(action, names, user),
+			"replace_original": true,
+			"response_type":    "in_channel",
+		}
+		if err := s.sendSlackResponse(r.Context(), slackPayload.ResponseURL, response); err != nil {
+			log.Printf("Failed to send response to Slack: %v", err)
+			http.Error(w, "Failed to send response to Slack", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// Feedback buttons are answered privately and leave the alert untouched
+	if actionType == "feedback_actionable" || actionType == "feedback_not_actionable" {
+		actionable := actionType == "feedback_actionable"
+
+		responseText := "🙏 Thanks, your feedback was recorded"
+		if recorded, err := s.dispatcher.RecordFeedback(r.Context(), alertID, slackPayload.User.ID, user, actionable); err != nil {
+			log.Printf("Failed to record feedback on %s: %v", alertID, err)
+			responseText = fmt.Sprintf("⚠️ Failed to record feedback: %v", err)
+		} else if !recorded {
+			responseText = "You have already given feedback on this alert"
+		}
+
+		response := map[string]interface{}{
+			"text":             responseText,
+			"replace_original": false,
+			"response_type":    "ephemeral",
+		}
+		if err := s.sendSlackResponse(r.Context(), slackPayload.ResponseURL, response); err != nil {
+			log.Printf("Failed to send response to Slack: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// The priority menu reclassifies the alert and cross-posts it to its new priority's channel
+	if actionType == "change_priority" {
+		priority, alertID, _ := strings.Cut(action.SelectedOption.Value, "|")
+
+		var responseText string
+		if !s.canChangePriority(slackPayload.User.ID) {
+			log.Printf("%s is not allowed to change the priority of %s", user, alertID)
+			responseText = "⛔ You are not allowed to change alert priorities"
+		} else if channel, err := s.dispatcher.ChangePriority(r.Context(), alertID, priority, user); err != nil {
+			log.Printf("Failed to change priority of %s to %s: %v", alertID, priority, err)
+			responseText = fmt.Sprintf("⚠️ Failed to change priority: %v", err)
+		} else {
+			log.Printf("Alert %s reclassified to %s by %s", alertID, priority, user)
+			responseText = fmt.Sprintf("🔀 *Priority changed to %s by %s* and reposted to %s. Future firings of this alert will be %s.", priority, user, channel, priority)
+		}
+
+		response := map[string]interface{}{
+			"text":             responseText,
+			"replace_original": false,
+			"response_type":    "ephemeral",
+		}
+		if err := s.sendSlackResponse(r.Context(), slackPayload.ResponseURL, response); err != nil {
+			log.Printf("Failed to send response to Slack: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// The snooze menu holds the alert back for a while and, with cloudwatch_snooze, pauses its
+	// CloudWatch alarm; the original message stays for whoever picks it up
+	if actionType == "snooze" {
+		value, alertID, _ := strings.Cut(action.SelectedOption.Value, "|")
+
+		var responseText string
+		if duration, err := time.ParseDuration(value); err != nil || duration <= 0 {
+			responseText = fmt.Sprintf("⚠️ Invalid snooze duration %q", value)
+		} else if name, pausing, err := s.dispatcher.Snooze(r.Context(), alertID, duration, user); err != nil {
+			log.Printf("Failed to snooze %s: %v", alertID, err)
+			responseText = fmt.Sprintf("⚠️ Failed to snooze: %v", err)
+		} else {
+			log.Printf("Alert %s (%s) snoozed for %s by %s", alertID, name, value, user)
+			responseText = fmt.Sprintf("💤 *'%s' snoozed for %s by %s*, until %s", name, value, user, time.Now().Add(duration).UTC().Format("15:04 UTC"))
+			switch {
+			case pausing && s.config.CloudWatchSnooze.Action == config.CloudWatchSetState:
+				responseText += ". Its CloudWatch alarm is being set to OK."
+			case pausing:
+				responseText += ". Its CloudWatch alarm's actions are being disabled until then."
+			}
+		}
+
+		response := map[string]interface{}{
+			"text":             responseText,
+			"replace_original": false,
+			"response_type":    "in_channel",
+		}
+		if err := s.sendSlackResponse(r.Context(), slackPayload.ResponseURL, response); err != nil {
+			log.Printf("Failed to send response to Slack: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// Quick actions change the alert's Auto Scaling group or deployment, which can take longer
+	// than Slack waits for an answer, so they run in the background and reply to response_url
+	if name, ok := strings.CutPrefix(actionType, notifier.QuickActionPrefix); ok {
+		userID, responseURL := slackPayload.User.ID, slackPayload.ResponseURL
+		go func() {
+			ctx := context.Background()
+			response := map[string]interface{}{"replace_original": false, "response_type": "ephemeral"}
+			done, err := s.dispatcher.RunQuickAction(ctx, alertID, name, userID, user)
+			switch {
+			case errors.Is(err, dispatch.ErrQuickActionDenied):
+				log.Printf("%s is not allowed to run quick action %s on %s", user, name, alertID)
+				response["text"] = "⛔ You are not allowed to run this action"
+			case err != nil:
+				log.Printf("Failed to run quick action %s on %s: %v", name, alertID, err)
+				response["text"] = fmt.Sprintf("⚠️ Failed to run %s: %v", name, err)
+			default:
+				response["text"] = fmt.Sprintf("🛠️ *%s %s*", user, done)
+				response["response_type"] = "in_channel"
+			}
+			if err := s.sendSlackResponse(ctx, responseURL, response); err != nil {
+				log.Printf("Failed to send response to Slack: %v", err)
+			}
+		}()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// Log queries and graph renders can take longer than Slack waits for an answer, so they run
+	// in the background and post their results in the thread; failures are told to the user alone
+	if actionType == notifier.FetchLogsAction || actionType == notifier.RefreshGraphAction {
+		channelID, responseURL := slackPayload.Channel.ID, slackPayload.ResponseURL
+		threadTS := slackPayload.Message.ThreadTs
+		if threadTS == "" {
+			threadTS = slackPayload.Message.Ts
+		}
+		run, what := s.dispatcher.FetchLogs, "fetch logs"
+		if actionType == notifier.RefreshGraphAction {
+			run, what = s.dispatcher.RefreshGraph, "refresh graph"
+		}
+		go func() {
+			ctx := context.Background()
+			if err := run(ctx, channelID, threadTS, alertID, user); err != nil {
+				log.Printf("Failed to %s for %s: %v", what, alertID, err)
+				response := map[string]interface{}{
+					"text":             fmt.Sprintf("⚠️ Failed to %s: %v", what, err),
+					"replace_original": false,
+					"response_type":    "ephemeral",
+				}
+				if err := s.sendSlackResponse(ctx, responseURL, response); err != nil {
+					log.Printf("Failed to send response to Slack: %v", err)
+				}
+			}
+		}()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
+	// Link buttons have already opened their page in the user's browser
+	if strings.HasPrefix(actionType, notifier.LinkActionPrefix) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		return
+	}
+
 	// Extract alert details from the original message
 	alertInfo := s.extractAlertInfo(slackPayload.Message.Text)
 	if alertInfo.Name == "" {
@@ -162,28 +428,10 @@ func (s *Server) handleInteractive(w http.ResponseWriter, r *http.Request) {
 
 	var responseText string
 	switch actionType {
-	case "acknowledge":
-		if alertInfo.Name != "" {
-			responseText = fmt.Sprintf("✅ **Alert '%s' acknowledged by %s**", alertInfo.Name, user)
-			if alertInfo.Description != "" {
-				responseText += fmt.Sprintf("\n• *Description:* %s", alertInfo.Description)
-			}
-			responseText += "\n\n_This alert is now being handled._"
-		} else {
-			responseText = fmt.Sprintf("✅ **Alert %s acknowledged by %s**\n\n_This alert is now being handled._", alertID, user)
-		}
-		log.Printf("Alert %s (%s) acknowledged by %s", alertID, alertInfo.Name, user)
-	case "dismiss":
-		if alertInfo.Name != "" {
-			responseText = fmt.Sprintf("❌ **Alert '%s' dismissed by %s**", alertInfo.Name, user)
-			if alertInfo.Description != "" {
-				responseText += fmt.Sprintf("\n• *Description:* %s", alertInfo.Description)
-			}
-			responseText += "\n\n_This alert has been dismissed and will not be actioned._"
-		} else {
-			responseText = fmt.Sprintf("❌ **Alert %s dismissed by %s**\n\n_This alert has been dismissed and will not be actioned._", alertID, user)
-		}
-		log.Printf("Alert %s (%s) dismissed by %s", alertID, alertInfo.Name, user)
+	case "acknowledge", "dismiss":
+		responseText = actionResponseText(actionType, alertInfo, alertID, user)
+		log.Printf("Alert %s (%s) %s by %s", alertID, alertInfo.Name, actionPastTense[actionType], user)
+		s.dispatcher.CloseAlert(r.Context(), actionType, alertID, user, audit.ViaButton)
 	default:
 		responseText = fmt.Sprintf("Unknown action: %s", actionType)
 		log.Printf("Unknown action: %s", actionType)
@@ -196,7 +444,7 @@ func (s *Server) handleInteractive(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send response to Slack via response_url
-	if err := s.sendSlackResponse(slackPayload.ResponseURL, response); err != nil {
+	if err := s.sendSlackResponse(r.Context(), slackPayload.ResponseURL, response); err != nil {
 		log.Printf("Failed to send response to Slack: %v", err)
 		http.Error(w, "Failed to send response to Slack", http.StatusInternalServerError)
 		return
@@ -243,6 +491,12 @@ func (s *Server) verifySlackRequest(r *http.Request, body []byte) bool {
 	log.Printf("Received signature: %s", signature)
 
 	isValid := hmac.Equal([]byte(signature), []byte(expectedSignature))
+	// Buttons and commands in the standby workspace are signed with its app's secret
+	if standby := s.config.SlackStandbySigningSecret; !isValid && standby != "" {
+		h := hmac.New(sha256.New, []byte(standby))
+		h.Write([]byte(baseString))
+		isValid = hmac.Equal([]byte(signature), []byte("v0="+hex.EncodeToString(h.Sum(nil))))
+	}
 	log.Printf("Signature valid: %t", isValid)
 	return isValid
 }
@@ -306,14 +560,59 @@ func (s *Server) extractAlertInfo(text string) AlertInfo {
 	return info
 }
 
+// canChangePriority reports whether the Slack user is one of the configured priority editors.
+// Without any, nobody is, since a new priority pages and sticks for 90 days. Only IDs count,
+// since users can change their names.
+func (s *Server) canChangePriority(userID string) bool {
+	for _, editor := range s.config.PriorityEditors {
+		if editor == userID {
+			return true
+		}
+	}
+	return false
+}
+
+var actionPastTense = map[string]string{
+	"acknowledge": "acknowledged",
+	"dismiss":     "dismissed",
+}
+
+// actionResponseText replaces an alert message once someone acknowledges or dismisses it
+func actionResponseText(actionType string, alertInfo AlertInfo, alertID, user string) string {
+	outcome := "_This alert is now being handled._"
+	icon := "✅"
+	if actionType == "dismiss" {
+		outcome = "_This alert has been dismissed and will not be actioned._"
+		icon = "❌"
+	}
+
+	if alertInfo.Name == "" {
+		return fmt.Sprintf("%s **Alert %s %s by %s**\n\n%s", icon, alertID, actionPastTense[actionType], user, outcome)
+	}
+	text := fmt.Sprintf("%s **Alert '%s' %s by %s**", icon, alertInfo.Name, actionPastTense[actionType], user)
+	if alertInfo.Description != "" {
+		text += fmt.Sprintf("\n• *Description:* %s", alertInfo.Description)
+	}
+	return text + "\n\n" + outcome
+}
+
 // Send response to Slack via response_url
-func (s *Server) sendSlackResponse(responseURL string, response map[string]interface{}) error {
+func (s *Server) sendSlackResponse(ctx context.Context, responseURL string, response map[string]interface{}) error {
 	payload, err := json.Marshal(response)
 	if err != nil {
 		return fmt.Errorf("failed to marshal response: %v", err)
 	}
 
-	resp, err := http.Post(responseURL, "application/json", bytes.NewBuffer(payload))
+	ctx, cancel := context.WithTimeout(ctx, s.config.WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create response_url request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to post to response_url: %v", err)
 	}
@@ -327,50 +626,3 @@ func (s *Server) sendSlackResponse(responseURL string, response map[string]inter
 	log.Printf("Successfully sent response to Slack via response_url")
 	return nil
 }
-
-func (s *Server) handleGrafanaWebhook(w http.ResponseWriter, r *http.Request) {
-	log.Printf("=== Grafana Webhook Request ===")
-	log.Printf("Method: %s", r.Method)
-	log.Printf("URL: %s", r.URL.Path)
-	log.Printf("Content-Type: %s", r.Header.Get("Content-Type"))
-
-	if r.Method != http.MethodPost {
-		log.Printf("Method not allowed: %s", r.Method)
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("Failed to read request body: %v", err)
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("Grafana webhook body: %s", string(body))
-
-	// Process the Grafana alert
-	alertMsg, err := adapter.AdaptGrafanaWebhook(string(body), s.config.SlackChannels, s.config.AlarmChannels)
-	if err != nil {
-		log.Printf("Failed to adapt Grafana webhook: %v", err)
-		http.Error(w, "Failed to process alert", http.StatusBadRequest)
-		return
-	}
-
-	// Create notifier for specific channel
-	channelNotifier := notifier.NewSlackNotifier(s.config.SlackBotToken, alertMsg.Channel)
-	log.Printf("Sending %s Grafana alert to %s", alertMsg.Priority, alertMsg.Channel)
-
-	// Send to Slack with interactive buttons
-	if err := channelNotifier.NotifyWithButtons(alertMsg.Message, fmt.Sprintf("grafana_%d", time.Now().Unix())); err != nil {
-		log.Printf("Failed to send Grafana alert to Slack: %v", err)
-		http.Error(w, "Failed to send to Slack", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "processed"})
-
-	log.Printf("Grafana webhook processed and sent to Slack successfully")
-}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"alert-dispatcher/internal/audit"
+)
+
+// TelegramUpdate is the part of a Bot API update sent when an inline button is pressed
+type TelegramUpdate struct {
+	CallbackQuery *struct {
+		ID   string `json:"id"`
+		Data string `json:"data"`
+		From struct {
+			Username  string `json:"username"`
+			FirstName string `json:"first_name"`
+		} `json:"from"`
+		Message struct {
+			MessageID int64  `json:"message_id"`
+			Text      string `json:"text"`
+			Chat      struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+		} `json:"message"`
+	} `json:"callback_query"`
+}
+
+// handleTelegramCallback handles the Acknowledge and Dismiss buttons of Telegram alerts, replacing
+// the message like the Slack buttons do
+func (s *Server) handleTelegramCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := s.config.Notifiers.Telegram
+	secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if cfg.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(cfg.WebhookSecret)) != 1 {
+		log.Printf("Telegram request verification failed")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update TelegramUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		log.Printf("Failed to decode Telegram update: %v", err)
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	// Other updates, such as messages sent to the bot, are ignored
+	query := update.CallbackQuery
+	if query == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Only the chats alerts are posted to have buttons; presses anywhere else are ignored
+	chatID := strconv.FormatInt(query.Message.Chat.ID, 10)
+	telegramNotifier, ok := s.dispatcher.TelegramNotifier(chatID)
+	if !ok {
+		log.Printf("Ignoring Telegram button press in unconfigured chat %s", chatID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	actionType, alertID, _ := strings.Cut(query.Data, ":")
+	user := query.From.Username
+	if user == "" {
+		user = query.From.FirstName
+	}
+
+	name := alertID
+	if record, ok, err := s.dispatcher.Archive().Get(r.Context(), alertID); err == nil && ok && record.Alert != nil {
+		name = record.Alert.Name
+	}
+
+	var status, responseText string
+	switch actionType {
+	case "acknowledge":
+		status = "Acknowledged"
+		responseText = fmt.Sprintf("✅ Alert '%s' acknowledged by %s\n\nThis alert is now being handled.", name, user)
+	case "dismiss":
+		status = "Dismissed"
+		responseText = fmt.Sprintf("❌ Alert '%s' dismissed by %s\n\nThis alert has been dismissed and will not be actioned.", name, user)
+	default:
+		log.Printf("Unknown Telegram action: %s", query.Data)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	log.Printf("Alert %s (%s) %s by %s on Telegram", alertID, name, strings.ToLower(status), user)
+	s.dispatcher.CloseAlert(r.Context(), actionType, alertID, user, audit.ViaTelegram)
+
+	if err := telegramNotifier.EditMessage(r.Context(), query.Message.MessageID, responseText); err != nil {
+		log.Printf("Failed to update Telegram message: %v", err)
+		http.Error(w, "Failed to update Telegram message", http.StatusInternalServerError)
+		return
+	}
+	if err := telegramNotifier.AnswerCallback(r.Context(), query.ID, status); err != nil {
+		log.Printf("Failed to answer Telegram callback: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
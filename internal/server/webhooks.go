@@ -0,0 +1,190 @@
+package server
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alert-dispatcher/internal/adapter"
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/metrics"
+)
+
+var rejectedWebhooks = metrics.NewCounter("alert_dispatcher_webhook_rate_limited_total",
+	"Requests to configured webhooks rejected by their rate limit.", "webhook")
+
+// webhookAdapter parses a request body into a routed alert
+type webhookAdapter func(body string, channels, alarmChannels map[string]string, fields adapter.FieldFilter) (*alert.Alert, error)
+
+// webhookAdapters are the adapters configured webhooks can use, by name
+func (s *Server) webhookAdapters() map[string]webhookAdapter {
+	adapters := make(map[string]webhookAdapter)
+	for _, input := range adapter.Inputs {
+		if input.Configurable {
+			adapters[input.Name] = s.inputAdapter(input.Name)
+		}
+	}
+	return adapters
+}
+
+// inputAdapter is the adapter of a webhook input, routing by the input's own objects from the
+// config. It is nil for inputs that aren't webhooks.
+func (s *Server) inputAdapter(name string) webhookAdapter {
+	input, ok := adapter.LookupInput(name)
+	if !ok || input.Webhook == nil {
+		return nil
+	}
+	objectChannels := map[string]map[string]string{
+		"sentry":    s.config.SentryProjects,
+		"dynatrace": s.config.DynatraceZones,
+		"github":    s.config.GitHubRepos,
+		"gitlab":    s.config.GitLabProjects,
+		"argocd":    s.config.ArgoCDApps,
+		"flux":      s.config.FluxObjects,
+	}[name]
+	return func(body string, channels, alarmChannels map[string]string, fields adapter.FieldFilter) (*alert.Alert, error) {
+		return input.Webhook(body, channels, alarmChannels, objectChannels, fields)
+	}
+}
+
+// webhookLimiter counts the requests to each configured webhook in its current window
+type webhookLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*webhookWindow
+}
+
+type webhookWindow struct {
+	start    time.Time
+	accepted int
+}
+
+// allow reports whether a request to the webhook fits its limit, and otherwise when the window
+// ends
+func (l *webhookLimiter) allow(name string, limit config.RateLimit) (bool, time.Duration) {
+	if limit.Per <= 0 {
+		limit.Per = time.Hour
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	w, ok := l.windows[name]
+	if !ok || now.Sub(w.start) >= limit.Per {
+		w = &webhookWindow{start: now}
+		l.windows[name] = w
+	}
+	if w.accepted < limit.Max {
+		w.accepted++
+		return true, 0
+	}
+	return false, w.start.Add(limit.Per).Sub(now)
+}
+
+// handleConfiguredWebhook serves /webhook/<name> for the webhooks in alarm-channels.yaml, each
+// with its own secret, adapter, default channels, labels and rate limit
+func (s *Server) handleConfiguredWebhook(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/webhook/")
+	webhook, ok := s.config.Webhooks[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !webhookAuthorized(r, webhook) {
+		log.Printf("Webhook %s request verification failed", name)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if webhook.RateLimit != nil {
+		if ok, retryAfter := s.webhookLimits.allow(name, *webhook.RateLimit); !ok {
+			rejectedWebhooks.Inc(name)
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	adapt, ok := s.webhookAdapters()[webhook.Adapter]
+	if !ok {
+		log.Printf("Webhook %s has unknown adapter %q", name, webhook.Adapter)
+		http.Error(w, "Webhook misconfigured", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := readBody(r.Body)
+	if err != nil {
+		log.Printf("Failed to read request body: %v", err)
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	alertMsg, err := adapt(body, webhookChannels(s.config.SlackChannels, webhook), s.config.AlarmMappings(), s.config)
+	if err != nil {
+		log.Printf("Failed to adapt webhook %s alert: %v", name, err)
+		writeError(w, "Failed to process alert", err)
+		return
+	}
+	if len(webhook.Labels) > 0 && alertMsg.Labels == nil {
+		alertMsg.Labels = make(map[string]string, len(webhook.Labels))
+	}
+	for k, v := range webhook.Labels {
+		alertMsg.Labels[k] = v
+	}
+	if alertMsg.Extensions == nil {
+		alertMsg.Extensions = make(map[string]interface{})
+	}
+	alertMsg.Extensions["webhook"] = name
+
+	log.Printf("Sending %s %s alert from webhook %s to %s", alertMsg.Severity, alertMsg.Source, name, alertMsg.Channel)
+
+	alertID := fmt.Sprintf("%s_%d", name, time.Now().UnixNano())
+	if err := s.dispatcher.Dispatch(r.Context(), alertMsg, alertID); err != nil {
+		log.Printf("Failed to send webhook %s alert to Slack: %v", name, err)
+		writeError(w, "Failed to send to Slack", err)
+		return
+	}
+
+	writeProcessed(w, alertID)
+}
+
+// webhookAuthorized reports whether the request carries the webhook's secret in the
+// X-Webhook-Secret header. Webhooks without a secret reject every request. The secret isn't
+// taken from the query string, which ends up in access and proxy logs.
+func webhookAuthorized(r *http.Request, webhook config.WebhookConfig) bool {
+	if webhook.Secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(r.Header.Get("X-Webhook-Secret")), []byte(webhook.Secret))
+}
+
+// webhookChannels are the priority channels alerts from the webhook are routed by: its channel
+// in place of every priority channel, then its own priority channels
+func webhookChannels(channels map[string]string, webhook config.WebhookConfig) map[string]string {
+	if webhook.Channel == "" && len(webhook.Priorities) == 0 {
+		return channels
+	}
+	merged := make(map[string]string, len(channels)+1)
+	for priority, channel := range channels {
+		merged[priority] = channel
+	}
+	if webhook.Channel != "" {
+		for _, priority := range []string{"P0", "P1", "P2", "default"} {
+			merged[priority] = webhook.Channel
+		}
+	}
+	for priority, channel := range webhook.Priorities {
+		merged[priority] = channel
+	}
+	return merged
+}
@@ -0,0 +1,46 @@
+package ses
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+
+	"alert-dispatcher/notifier"
+)
+
+// Sender sends email through the SES API using the default AWS credential chain
+type Sender struct {
+	Client *sesv2.Client
+}
+
+func NewSender() (*Sender, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{
+		Client: sesv2.NewFromConfig(cfg),
+	}, nil
+}
+
+func (s *Sender) Send(ctx context.Context, msg notifier.EmailMessage) error {
+	_, err := s.Client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(msg.From),
+		Destination: &types.Destination{
+			ToAddresses: msg.To,
+		},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(msg.Subject), Charset: aws.String("UTF-8")},
+				Body: &types.Body{
+					Html: &types.Content{Data: aws.String(msg.HTML), Charset: aws.String("UTF-8")},
+					Text: &types.Content{Data: aws.String(msg.Text), Charset: aws.String("UTF-8")},
+				},
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,101 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/httpclient"
+)
+
+// ClickHouseSink inserts one row per event through the ClickHouse HTTP interface. Batches are
+// sent as async inserts, so the server buffers rows from every replica into larger parts.
+type ClickHouseSink struct {
+	url      string
+	table    string
+	username string
+	password string
+	client   *http.Client
+}
+
+func NewClickHouseSink(url, table, username, password string, timeout time.Duration) *ClickHouseSink {
+	return &ClickHouseSink{
+		url:      strings.TrimRight(url, "/"),
+		table:    table,
+		username: username,
+		password: password,
+		client:   httpclient.New(timeout),
+	}
+}
+
+func (s *ClickHouseSink) Name() string {
+	return "clickhouse"
+}
+
+// clickHouseRow is an event flattened into the columns of the alert events table
+type clickHouseRow struct {
+	EventTime string            `json:"event_time"`
+	AlertID   string            `json:"alert_id"`
+	Source    string            `json:"source"`
+	Name      string            `json:"name"`
+	Severity  string            `json:"severity"`
+	Status    string            `json:"status"`
+	State     string            `json:"state"`
+	Channel   string            `json:"channel"`
+	Outcome   string            `json:"outcome"`
+	Error     string            `json:"error"`
+	Labels    map[string]string `json:"labels"`
+}
+
+func (s *ClickHouseSink) Write(ctx context.Context, events []Event) error {
+	var body bytes.Buffer
+	for _, event := range events {
+		row := clickHouseRow{
+			EventTime: event.DispatchedAt.UTC().Format("2006-01-02 15:04:05.000"),
+			AlertID:   event.AlertID,
+			Outcome:   event.Outcome,
+			Error:     event.Error,
+			Labels:    map[string]string{},
+		}
+		if a := event.Alert; a != nil {
+			row.Source, row.Name, row.Severity = a.Source, a.Name, a.Severity
+			row.Status, row.State, row.Channel = a.Status, a.State, a.Channel
+			if a.Labels != nil {
+				row.Labels = a.Labels
+			}
+		}
+		if err := writeNDJSON(&body, row); err != nil {
+			return err
+		}
+	}
+
+	params := url.Values{}
+	params.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", s.table))
+	params.Set("async_insert", "1")
+	params.Set("wait_for_async_insert", "1")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/?"+params.Encode(), &body)
+	if err != nil {
+		return fmt.Errorf("failed to create insert request: %v", err)
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post insert: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("clickhouse responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/httpclient"
+)
+
+// OpenSearchSink indexes events into daily indices named "<prefix>-YYYY.MM.DD" via the bulk API.
+// Daily indices match an index template on "<prefix>-*", so ILM/ISM policies can roll them over
+// and delete them by age. Works with Elasticsearch as well as OpenSearch.
+type OpenSearchSink struct {
+	url         string
+	indexPrefix string
+	username    string
+	password    string
+	client      *http.Client
+}
+
+func NewOpenSearchSink(url, indexPrefix, username, password string, timeout time.Duration) *OpenSearchSink {
+	return &OpenSearchSink{
+		url:         strings.TrimRight(url, "/"),
+		indexPrefix: indexPrefix,
+		username:    username,
+		password:    password,
+		client:      httpclient.New(timeout),
+	}
+}
+
+func (s *OpenSearchSink) Name() string {
+	return "opensearch"
+}
+
+// bulkResponse is the part of the bulk API response we check for per-document failures
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+func (s *OpenSearchSink) Write(ctx context.Context, events []Event) error {
+	var body bytes.Buffer
+	for _, event := range events {
+		action := map[string]map[string]string{
+			"index": {"_index": s.index(event.DispatchedAt)},
+		}
+		if err := writeNDJSON(&body, action, event); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/_bulk", &body)
+	if err != nil {
+		return fmt.Errorf("failed to create bulk request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post bulk request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("opensearch responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result bulkResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("failed to parse bulk response: %v", err)
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, op := range item {
+				if op.Status >= 300 {
+					return fmt.Errorf("bulk indexing failed with status %d: %s", op.Status, op.Error.Reason)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *OpenSearchSink) index(t time.Time) string {
+	return fmt.Sprintf("%s-%s", s.indexPrefix, t.UTC().Format("2006.01.02"))
+}
+
+// writeNDJSON appends each value to buf as one line of JSON
+func writeNDJSON(buf *bytes.Buffer, values ...interface{}) error {
+	for _, v := range values {
+		line, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %v", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return nil
+}
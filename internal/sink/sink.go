@@ -0,0 +1,96 @@
+package sink
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/metrics"
+)
+
+// Delivery outcomes recorded for each alert
+const (
+	OutcomeDelivered = "delivered"
+	OutcomeFailed    = "failed"
+	OutcomeDropped   = "dropped"
+)
+
+// Event is one alert transition and what the dispatcher did with it
+type Event struct {
+	AlertID      string       `json:"alert_id"`
+	Alert        *alert.Alert `json:"alert"`
+	Outcome      string       `json:"outcome"`
+	Error        string       `json:"error,omitempty"`
+	DispatchedAt time.Time    `json:"dispatched_at"`
+}
+
+// Sink stores alert events for analytics outside Slack
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, events []Event) error
+}
+
+var sinkEvents = metrics.NewCounter("alert_dispatcher_sink_events_total",
+	"Alert events handed to sinks, by sink and result.", "sink", "result")
+
+// Batcher buffers events and writes them to a sink in batches from a background goroutine,
+// so a slow sink never delays delivery. Events are discarded when the buffer is full.
+type Batcher struct {
+	sink     Sink
+	events   chan Event
+	size     int
+	interval time.Duration
+}
+
+func NewBatcher(s Sink, size int, interval time.Duration) *Batcher {
+	b := &Batcher{
+		sink:     s,
+		events:   make(chan Event, size*10),
+		size:     size,
+		interval: interval,
+	}
+	go b.run()
+	return b
+}
+
+// Add queues an event for the next batch
+func (b *Batcher) Add(event Event) {
+	select {
+	case b.events <- event:
+	default:
+		sinkEvents.Inc(b.sink.Name(), "discarded")
+	}
+}
+
+func (b *Batcher) run() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, b.size)
+	for {
+		select {
+		case event := <-b.events:
+			batch = append(batch, event)
+			if len(batch) < b.size {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		b.flush(batch)
+		batch = make([]Event, 0, b.size)
+	}
+}
+
+func (b *Batcher) flush(batch []Event) {
+	if err := b.sink.Write(context.Background(), batch); err != nil {
+		log.Printf("Failed to write %d events to %s sink: %v", len(batch), b.sink.Name(), err)
+		sinkEvents.Add(float64(len(batch)), b.sink.Name(), "failed")
+		return
+	}
+	sinkEvents.Add(float64(len(batch)), b.sink.Name(), "written")
+}
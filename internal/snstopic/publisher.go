@@ -0,0 +1,99 @@
+package snstopic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/notifier"
+)
+
+// Publisher republishes normalized alerts as JSON to an SNS topic using the default AWS
+// credential chain. Alerts carry source, severity, status and name message attributes so
+// subscribers can filter them with subscription filter policies.
+type Publisher struct {
+	Client   *sns.Client
+	TopicARN string
+}
+
+func NewPublisher(topicARN string) (*Publisher, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &Publisher{
+		Client:   sns.NewFromConfig(cfg),
+		TopicARN: topicARN,
+	}, nil
+}
+
+// Notify publishes {"text": message}
+func (p *Publisher) Notify(ctx context.Context, message string) error {
+	return p.publish(ctx, notifier.AlertPayload{Text: message}, nil, "", "")
+}
+
+// NotifyAlert publishes {"alert_id": ..., "fingerprint": ..., "alert": ...}. On FIFO topics
+// notifications of the same alert share a message group, so they are delivered in order.
+func (p *Publisher) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	payload := notifier.NewAlertPayload(a, alertID)
+	attributes := map[string]types.MessageAttributeValue{
+		"source":   stringAttribute(a.Source),
+		"severity": stringAttribute(a.Severity),
+		"status":   stringAttribute(a.Status),
+		"name":     stringAttribute(a.Name),
+	}
+	return p.publish(ctx, payload, attributes, payload.Fingerprint, alertID)
+}
+
+// Factory creates sns targets publishing to the topic ARN given as the target's channel,
+// sharing this publisher's client
+func (p *Publisher) Factory() notifier.Factory {
+	return func(target config.TargetConfig, _ *config.Config) (notifier.AlertNotifier, error) {
+		if target.Channel == "" {
+			return nil, fmt.Errorf("sns target needs a topic ARN as channel")
+		}
+		return &Publisher{Client: p.Client, TopicARN: target.Channel}, nil
+	}
+}
+
+func (p *Publisher) publish(ctx context.Context, payload notifier.AlertPayload, attributes map[string]types.MessageAttributeValue, groupID, dedupID string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SNS message: %v", err)
+	}
+
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(p.TopicARN),
+		Message:           aws.String(string(body)),
+		MessageAttributes: attributes,
+	}
+	if strings.HasSuffix(p.TopicARN, ".fifo") {
+		if groupID == "" {
+			groupID = "alert-dispatcher"
+		}
+		input.MessageGroupId = aws.String(groupID)
+		if dedupID != "" {
+			input.MessageDeduplicationId = aws.String(dedupID)
+		}
+	}
+
+	if _, err := p.Client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish to %s: %v", p.TopicARN, err)
+	}
+	return nil
+}
+
+func stringAttribute(value string) types.MessageAttributeValue {
+	if value == "" {
+		value = "unknown"
+	}
+	return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
@@ -0,0 +1,107 @@
+package sqs
+
+import (
+	"context"
+	"time"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+const (
+	// scaleInterval is how often Poll starts receive loops the backlog calls for
+	scaleInterval = 5 * time.Second
+	// backlogPerReceiver is the backlog that calls for each receive loop after the first
+	backlogPerReceiver = 50
+
+	maxBatch       = 10 // the most messages SQS returns per receive
+	defaultBatch   = 5
+	maxWaitSeconds = 20 // the longest long poll SQS allows
+	busyWait       = 10
+)
+
+var receiversRunning = metrics.NewGauge("alert_dispatcher_sqs_receivers",
+	"Receive loops polling the SQS queue.")
+
+// wantReceivers is how many receive loops the last depth check's backlog calls for
+func (p *Poller) wantReceivers() int {
+	want := 1 + int(p.backlog.Load()/backlogPerReceiver)
+	if want > p.MaxReceivers {
+		want = p.MaxReceivers
+	}
+	if want < 1 {
+		want = 1
+	}
+	return want
+}
+
+// startReceiver reserves a receive loop if fewer run than the backlog calls for
+func (p *Poller) startReceiver() bool {
+	p.scaleMu.Lock()
+	defer p.scaleMu.Unlock()
+	if p.receivers >= p.wantReceivers() {
+		return false
+	}
+	p.receivers++
+	receiversRunning.Set(float64(p.receivers))
+	return true
+}
+
+// stopReceiver releases a receive loop if more run than the backlog calls for. The last one
+// always keeps running.
+func (p *Poller) stopReceiver() bool {
+	p.scaleMu.Lock()
+	defer p.scaleMu.Unlock()
+	if p.receivers <= p.wantReceivers() {
+		return false
+	}
+	p.receivers--
+	receiversRunning.Set(float64(p.receivers))
+	return true
+}
+
+// receiveSize picks the batch size and long poll wait of the next receive: full batches while
+// messages are waiting, and the longest long poll once the queue has been empty, so quiet
+// periods cost fewer calls
+func (p *Poller) receiveSize(idle int) (batch, waitSeconds int32) {
+	switch {
+	case p.backlog.Load() >= maxBatch:
+		return maxBatch, busyWait
+	case idle > 0:
+		return defaultBatch, maxWaitSeconds
+	default:
+		return defaultBatch, busyWait
+	}
+}
+
+// idleWait pauses after the idle-th empty receive in a row, doubling from a second up to
+// MaxIdleWait. A depth check that finds messages waiting ends the pause early.
+func (p *Poller) idleWait(ctx context.Context, idle int) {
+	if p.MaxIdleWait <= 0 || idle < 2 {
+		return
+	}
+	wait := time.Second << min(idle-2, 16)
+	if wait > p.MaxIdleWait {
+		wait = p.MaxIdleWait
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	case <-p.wake:
+	}
+}
+
+// setBacklog records the visible messages found by a depth check, waking an idle receive loop
+// when there are any
+func (p *Poller) setBacklog(visible int) {
+	p.backlog.Store(int64(visible))
+	if visible == 0 {
+		return
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,104 @@
+package sqs
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+var (
+	queueMessages = metrics.NewGauge("alert_dispatcher_sqs_messages",
+		"Approximate messages in the SQS queue, by state: visible, in_flight or delayed.", "state")
+	oldestMessageAge = metrics.NewGauge("alert_dispatcher_sqs_oldest_message_age_seconds",
+		"Age of the oldest message received since the last depth check, or the time since the last receive while messages wait.")
+)
+
+// Depth is the backlog of the queue at one check
+type Depth struct {
+	Visible  int // waiting to be received
+	InFlight int // received but not yet deleted
+	Delayed  int
+	// OldestAge is how long the oldest message received since the last check had been in the
+	// queue. When messages wait but none were received, it's the time since the last receive.
+	OldestAge time.Duration
+}
+
+// recordLag remembers the age of the oldest message in a received batch
+func (p *Poller) recordLag(messages []types.Message) {
+	now := time.Now()
+	p.lagMu.Lock()
+	defer p.lagMu.Unlock()
+	p.lastReceive = now
+	for _, msg := range messages {
+		ms, err := strconv.ParseInt(msg.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)], 10, 64)
+		if err != nil {
+			continue
+		}
+		if age := now.Sub(time.UnixMilli(ms)); age > p.maxLag {
+			p.maxLag = age
+		}
+	}
+}
+
+// Depth reads the queue's approximate message counts and the lag since the last call
+func (p *Poller) Depth(ctx context.Context) (Depth, error) {
+	out, err := p.Client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: &p.QueueURL,
+		AttributeNames: []types.QueueAttributeName{
+			types.QueueAttributeNameApproximateNumberOfMessages,
+			types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+			types.QueueAttributeNameApproximateNumberOfMessagesDelayed,
+		},
+	})
+	if err != nil {
+		return Depth{}, err
+	}
+
+	var depth Depth
+	depth.Visible, _ = strconv.Atoi(out.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)])
+	depth.InFlight, _ = strconv.Atoi(out.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessagesNotVisible)])
+	depth.Delayed, _ = strconv.Atoi(out.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessagesDelayed)])
+	p.setBacklog(depth.Visible)
+
+	p.lagMu.Lock()
+	depth.OldestAge = p.maxLag
+	if depth.OldestAge == 0 && depth.Visible > 0 && !p.lastReceive.IsZero() {
+		depth.OldestAge = time.Since(p.lastReceive)
+	}
+	p.maxLag = 0
+	p.lagMu.Unlock()
+	return depth, nil
+}
+
+// MonitorDepth checks the queue's depth every interval until ctx is cancelled, exporting it as
+// metrics and passing it to check
+func (p *Poller) MonitorDepth(ctx context.Context, interval time.Duration, check func(context.Context, Depth)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		depth, err := p.Depth(ctx)
+		if err != nil {
+			log.Printf("Failed to read SQS queue depth: %v", err)
+			continue
+		}
+		queueMessages.Set(float64(depth.Visible), "visible")
+		queueMessages.Set(float64(depth.InFlight), "in_flight")
+		queueMessages.Set(float64(depth.Delayed), "delayed")
+		oldestMessageAge.Set(depth.OldestAge.Seconds())
+		if check != nil {
+			check(ctx, depth)
+		}
+	}
+}
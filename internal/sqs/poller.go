@@ -4,15 +4,44 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"alert-dispatcher/internal/errs"
 )
 
+// releaseTimeout bounds releasing unhandled messages at shutdown
+const releaseTimeout = 5 * time.Second
+
 type Poller struct {
 	Client   *sqs.Client
 	QueueURL string
+
+	// DeadLetterURL receives messages whose handler error isn't retryable, such as unparseable
+	// bodies. Without it they stay in the queue until its redrive policy moves them.
+	DeadLetterURL string
+
+	// MaxReceivers bounds the receive loops run at once while the queue is backed up. Values
+	// below 1 mean 1.
+	MaxReceivers int
+	// MaxIdleWait bounds the pause between empty receives while the queue is idle
+	MaxIdleWait time.Duration
+
+	scaleMu   sync.Mutex
+	receivers int          // receive loops running
+	backlog   atomic.Int64 // visible messages at the last depth check
+	wake      chan struct{}
+
+	lagMu       sync.Mutex
+	maxLag      time.Duration // age of the oldest message received since the last Depth
+	lastReceive time.Time
 }
 
 func NewPoller(queueURL string) (*Poller, error) {
@@ -22,39 +51,153 @@ func NewPoller(queueURL string) (*Poller, error) {
 	}
 	client := sqs.NewFromConfig(cfg)
 	return &Poller{
-		Client:   client,
-		QueueURL: queueURL,
+		Client:       client,
+		QueueURL:     queueURL,
+		MaxReceivers: 1,
+		wake:         make(chan struct{}, 1),
 	}, nil
 }
 
-func (p *Poller) Poll(handler func(string) error) {
+// Poll receives messages until ctx is cancelled. One receive loop runs while the queue keeps up
+// and up to MaxReceivers while it is backed up. Once ctx is cancelled, the messages being
+// handled are finished and the rest of their batches released, and Poll returns.
+func (p *Poller) Poll(ctx context.Context, handler func(context.Context, string) error) {
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(scaleInterval)
+	defer ticker.Stop()
 	for {
-		out, err := p.Client.ReceiveMessage(context.TODO(), &sqs.ReceiveMessageInput{
+		for p.startReceiver() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.receive(ctx, handler)
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// receive runs one receive loop until ctx is cancelled or the loop is no longer needed
+func (p *Poller) receive(ctx context.Context, handler func(context.Context, string) error) {
+	idle := 0 // empty receives in a row
+	for ctx.Err() == nil {
+		batch, wait := p.receiveSize(idle)
+		out, err := p.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
 			QueueUrl:            &p.QueueURL,
-			MaxNumberOfMessages: 5,
-			WaitTimeSeconds:     10,
+			MaxNumberOfMessages: batch,
+			WaitTimeSeconds:     wait,
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+				types.MessageSystemAttributeNameSentTimestamp,
+			},
 		})
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
 			log.Printf("Receive error: %v", err)
 			time.Sleep(5 * time.Second)
 			continue
 		}
+		if len(out.Messages) > 0 {
+			p.recordLag(out.Messages)
+		}
 
-		for _, msg := range out.Messages {
+		// A message being handled at shutdown is finished, so it isn't posted twice
+		msgCtx := context.WithoutCancel(ctx)
+		for i, msg := range out.Messages {
+			if ctx.Err() != nil {
+				p.release(out.Messages[i:])
+				return
+			}
 			fmt.Println("Processing message:", *msg.Body)
 
-			if err := handler(*msg.Body); err == nil {
-				// Delete message on success
-				_, err := p.Client.DeleteMessage(context.TODO(), &sqs.DeleteMessageInput{
-					QueueUrl:      &p.QueueURL,
-					ReceiptHandle: msg.ReceiptHandle,
-				})
-				if err != nil {
-					log.Printf("Delete error: %v", err)
+			err := handler(msgCtx, *msg.Body)
+			if err != nil {
+				log.Printf("Handler error (%s): %v", errs.Code(err), err)
+				if errs.Retryable(err) || !p.deadLetter(msgCtx, *msg.Body, err) {
+					continue
 				}
-			} else {
-				log.Printf("Handler error: %v", err)
+			}
+
+			// Delete message on success, or once it has been dead-lettered
+			_, err = p.Client.DeleteMessage(msgCtx, &sqs.DeleteMessageInput{
+				QueueUrl:      &p.QueueURL,
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				log.Printf("Delete error: %v", err)
 			}
 		}
+
+		if len(out.Messages) < int(batch) && p.stopReceiver() {
+			return
+		}
+		if len(out.Messages) > 0 {
+			idle = 0
+			continue
+		}
+		idle++
+		p.idleWait(ctx, idle)
+	}
+}
+
+// release makes received messages that won't be handled visible again right away, so another
+// replica receives them instead of waiting out the visibility timeout
+func (p *Poller) release(messages []types.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	for len(messages) > 0 {
+		batch := messages[:min(len(messages), maxBatch)]
+		messages = messages[len(batch):]
+
+		entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, len(batch))
+		for i, msg := range batch {
+			entries[i] = types.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     msg.ReceiptHandle,
+				VisibilityTimeout: 0,
+			}
+		}
+		out, err := p.Client.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: &p.QueueURL,
+			Entries:  entries,
+		})
+		if err != nil {
+			log.Printf("Failed to release %d unhandled messages: %v", len(batch), err)
+			continue
+		}
+		for _, failed := range out.Failed {
+			log.Printf("Failed to release unhandled message %s: %s", aws.ToString(failed.Id), aws.ToString(failed.Message))
+		}
+		log.Printf("Released %d unhandled messages", len(batch)-len(out.Failed))
+	}
+}
+
+// deadLetter moves a message that will never succeed to the dead-letter queue, tagged with the
+// error, and reports whether it can be deleted from the source queue
+func (p *Poller) deadLetter(ctx context.Context, body string, handlerErr error) bool {
+	if p.DeadLetterURL == "" {
+		return false
+	}
+
+	_, err := p.Client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    &p.DeadLetterURL,
+		MessageBody: &body,
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"error_code": {DataType: aws.String("String"), StringValue: aws.String(errs.Code(handlerErr))},
+			"error":      {DataType: aws.String("String"), StringValue: aws.String(handlerErr.Error())},
+		},
+	})
+	if err != nil {
+		log.Printf("Dead-letter error: %v", err)
+		return false
 	}
+	return true
 }
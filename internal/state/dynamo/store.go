@@ -0,0 +1,151 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Item attributes. expires_at should be the table's TTL attribute so DynamoDB deletes expired
+// items; since that can lag by hours, reads check it too.
+const (
+	attrKey       = "key"
+	attrValue     = "value"
+	attrExpiresAt = "expires_at"
+)
+
+// Store keeps dispatcher state in a DynamoDB table with a string partition key named "key",
+// one item per state key; alerts, silences and acknowledgements have no tables of their own.
+// SetNX uses a conditional write, so concurrent replicas can't both claim a key.
+type Store struct {
+	Client *dynamodb.Client
+	Table  string
+}
+
+func NewStore(table string) (*Store, error) {
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		Client: dynamodb.NewFromConfig(cfg),
+		Table:  table,
+	}, nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (string, bool, error) {
+	out, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.Table),
+		Key:            itemKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := out.Item[attrValue].(*types.AttributeValueMemberS)
+	if !ok || expired(out.Item) {
+		return "", false, nil
+	}
+	return value.Value, true, nil
+}
+
+func (s *Store) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.Table),
+		Item:      item(key, value, ttl),
+	})
+	return err
+}
+
+func (s *Store) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	_, err := s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.Table),
+		Item:      item(key, value, ttl),
+		// Expired items that DynamoDB hasn't deleted yet count as missing
+		ConditionExpression:      aws.String("attribute_not_exists(#key) OR #expires_at < :now"),
+		ExpressionAttributeNames: map[string]string{"#key": attrKey, "#expires_at": attrExpiresAt},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if _, err := s.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(s.Table),
+			Key:       itemKey(key),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scan reads the whole table, filtering by prefix, since keys are spread across partitions.
+// Prefixes are scanned rarely, and by few kinds of state.
+func (s *Store) Scan(ctx context.Context, prefix string) (map[string]string, error) {
+	found := make(map[string]string)
+	paginator := dynamodb.NewScanPaginator(s.Client, &dynamodb.ScanInput{
+		TableName:                aws.String(s.Table),
+		FilterExpression:         aws.String("begins_with(#key, :prefix)"),
+		ExpressionAttributeNames: map[string]string{"#key": attrKey},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":prefix": &types.AttributeValueMemberS{Value: prefix},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			key, keyOK := item[attrKey].(*types.AttributeValueMemberS)
+			value, valueOK := item[attrValue].(*types.AttributeValueMemberS)
+			if keyOK && valueOK && !expired(item) {
+				found[key.Value] = value.Value
+			}
+		}
+	}
+	return found, nil
+}
+
+func itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrKey: &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func item(key, value string, ttl time.Duration) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrKey:       &types.AttributeValueMemberS{Value: key},
+		attrValue:     &types.AttributeValueMemberS{Value: value},
+		attrExpiresAt: &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)},
+	}
+}
+
+func expired(item map[string]types.AttributeValue) bool {
+	expiresAt, ok := item[attrExpiresAt].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expiresAt.Value, 10, 64)
+	return err == nil && time.Now().Unix() > unix
+}
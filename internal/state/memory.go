@@ -0,0 +1,50 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/cache"
+)
+
+// MemoryStore keeps state in process, for single-replica deployments
+type MemoryStore struct {
+	entries *cache.Cache[string, string]
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		// Entries always carry their own TTL, and nothing may be evicted early
+		entries: cache.New[string, string]("state", 0, 0),
+	}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (string, bool, error) {
+	value, ok := m.entries.Get(key)
+	return value, ok, nil
+}
+
+func (m *MemoryStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.entries.SetWithTTL(key, value, ttl)
+	return nil
+}
+
+func (m *MemoryStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return m.entries.Add(key, value, ttl), nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, keys ...string) error {
+	m.entries.Delete(keys...)
+	return nil
+}
+
+func (m *MemoryStore) Scan(ctx context.Context, prefix string) (map[string]string, error) {
+	found := make(map[string]string)
+	for key, value := range m.entries.Entries() {
+		if strings.HasPrefix(key, prefix) {
+			found[key] = value
+		}
+	}
+	return found, nil
+}
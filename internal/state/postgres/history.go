@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"alert-dispatcher/internal/audit"
+	"alert-dispatcher/internal/sink"
+)
+
+// Name identifies the alerts table as a sink
+func (s *Store) Name() string {
+	return "postgres"
+}
+
+// Write records dispatched alerts and what became of them in the alerts table, so reports can
+// query alert history alongside the state
+func (s *Store) Write(ctx context.Context, events []sink.Event) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, event := range events {
+		var source, name, severity, status, state, channel string
+		labels := map[string]string{}
+		if a := event.Alert; a != nil {
+			source, name, severity = a.Source, a.Name, a.Severity
+			status, state, channel = a.Status, a.State, a.Channel
+			if a.Labels != nil {
+				labels = a.Labels
+			}
+		}
+		labelsJSON, err := json.Marshal(labels)
+		if err != nil {
+			return err
+		}
+		// A redelivered alert keeps its ID, and its last outcome wins
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO alerts (alert_id, source, name, severity, status, state, channel, labels, outcome, error, dispatched_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			 ON CONFLICT (alert_id) DO UPDATE SET outcome = EXCLUDED.outcome, error = EXCLUDED.error, dispatched_at = EXCLUDED.dispatched_at`,
+			event.AlertID, source, name, severity, status, state, channel, string(labelsJSON), event.Outcome, event.Error, event.DispatchedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// WriteAudit appends an action someone took on an alert to the audit_log table
+func (s *Store) WriteAudit(ctx context.Context, entry audit.Entry) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO audit_log (time, action, alert_id, alert_name, user_name, via, detail)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.Time, entry.Action, entry.AlertID, entry.AlertName, entry.User, entry.Via, entry.Detail)
+	return err
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS state_key_pattern_idx;
@@ -0,0 +1,2 @@
+-- Prefix scans match keys with LIKE 'prefix%', which needs pattern ops outside the C collation
+CREATE INDEX IF NOT EXISTS state_key_pattern_idx ON state (key text_pattern_ops);
@@ -0,0 +1,17 @@
+-- One row per dispatched alert and what became of it, for reporting
+CREATE TABLE IF NOT EXISTS alerts (
+    alert_id      TEXT PRIMARY KEY,
+    source        TEXT NOT NULL,
+    name          TEXT NOT NULL,
+    severity      TEXT NOT NULL,
+    status        TEXT NOT NULL,
+    state         TEXT NOT NULL,
+    channel       TEXT NOT NULL,
+    labels        JSONB NOT NULL,
+    outcome       TEXT NOT NULL,
+    error         TEXT NOT NULL,
+    dispatched_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS alerts_dispatched_at_idx ON alerts (dispatched_at);
+CREATE INDEX IF NOT EXISTS alerts_source_name_idx ON alerts (source, name, dispatched_at);
@@ -0,0 +1,14 @@
+-- Actions people took on alerts, as also written to the logs as audit: lines
+CREATE TABLE IF NOT EXISTS audit_log (
+    id         BIGSERIAL PRIMARY KEY,
+    time       TIMESTAMPTZ NOT NULL,
+    action     TEXT NOT NULL,
+    alert_id   TEXT NOT NULL,
+    alert_name TEXT NOT NULL,
+    user_name  TEXT NOT NULL,
+    via        TEXT NOT NULL,
+    detail     TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_time_idx ON audit_log (time);
+CREATE INDEX IF NOT EXISTS audit_log_alert_id_idx ON audit_log (alert_id);
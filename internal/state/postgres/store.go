@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// How often expired rows are deleted; reads already skip them
+const purgeInterval = 10 * time.Minute
+
+// Store keeps dispatcher state in Postgres, in the key-value state table. The schema is migrated
+// to the latest version on startup.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore connects to the database at dsn and applies any pending migrations
+func NewStore(dsn string) (*Store, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %v", err)
+	}
+
+	if err := migrateUp(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate postgres schema: %v", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func migrateUp(db *sql.DB) error {
+	source, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		return err
+	}
+	driver, err := pgxmigrate.WithInstance(db, &pgxmigrate.Config{})
+	if err != nil {
+		return err
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT value FROM state WHERE key = $1 AND expires_at > now()`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *Store) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO state (key, value, expires_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+		key, value, time.Now().Add(ttl))
+	return err
+}
+
+func (s *Store) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	// An expired row that hasn't been purged yet is replaced as if it were missing
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO state (key, value, expires_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at
+		 WHERE state.expires_at <= now()`,
+		key, value, time.Now().Add(ttl))
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (s *Store) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM state WHERE key = ANY($1)`, keys)
+	return err
+}
+
+// likeEscaper escapes the characters LIKE treats specially, with the default escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *Store) Scan(ctx context.Context, prefix string) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, value FROM state WHERE key LIKE $1 AND expires_at > now()`, likeEscaper.Replace(prefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		found[key] = value
+	}
+	return found, rows.Err()
+}
+
+// Run deletes expired rows periodically until ctx is cancelled
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.db.ExecContext(ctx, `DELETE FROM state WHERE expires_at <= now()`); err != nil {
+				log.Printf("Failed to purge expired postgres state: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisOptions configures the Redis backend
+type RedisOptions struct {
+	Addr      string
+	Password  string
+	DB        int
+	KeyPrefix string // namespaces keys when the Redis instance is shared
+}
+
+// RedisStore keeps state in Redis so every replica sees the same threads and alerts
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(opts RedisOptions) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     opts.Addr,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis at %s: %v", opts.Addr, err)
+	}
+
+	return &RedisStore{
+		client: client,
+		prefix: opts.KeyPrefix,
+	}, nil
+}
+
+func (r *RedisStore) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (r *RedisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+func (r *RedisStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, r.prefix+key, value, ttl).Result()
+}
+
+func (r *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefix + key
+	}
+	return r.client.Del(ctx, prefixed...).Err()
+}
+
+// globEscaper escapes the characters SCAN's MATCH pattern treats specially
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func (r *RedisStore) Scan(ctx context.Context, prefix string) (map[string]string, error) {
+	var keys []string
+	iter := r.client.Scan(ctx, 0, globEscaper.Replace(r.prefix+prefix)+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]string, len(keys))
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), 500)]
+		keys = keys[len(batch):]
+		values, err := r.client.MGet(ctx, batch...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			// Keys that expired since the scan come back nil
+			if value, ok := value.(string); ok {
+				found[strings.TrimPrefix(batch[i], r.prefix)] = value
+			}
+		}
+	}
+	return found, nil
+}
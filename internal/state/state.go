@@ -0,0 +1,20 @@
+package state
+
+import (
+	"context"
+	"time"
+)
+
+// Store holds dispatcher state that replicas need to share, such as thread parents and archived
+// alerts. Keys expire after their TTL.
+type Store interface {
+	// Get returns the value for key, or false when it is missing or expired
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX sets key only when it is missing, reporting whether it did
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+	// Scan returns the live keys starting with prefix and their values. Keys set or deleted
+	// while it runs may or may not be included.
+	Scan(ctx context.Context, prefix string) (map[string]string, error)
+}
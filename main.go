@@ -1,14 +1,36 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"path"
 	"sync"
+	"syscall"
 	"time"
 
 	"alert-dispatcher/internal/adapter"
+	"alert-dispatcher/internal/archive"
+	"alert-dispatcher/internal/audit"
+	"alert-dispatcher/internal/autoscaling"
 	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/cwalarms"
+	"alert-dispatcher/internal/cwlogs"
+	"alert-dispatcher/internal/dispatch"
+	"alert-dispatcher/internal/errs"
+	"alert-dispatcher/internal/grafana"
+	"alert-dispatcher/internal/kafkatopic"
+	"alert-dispatcher/internal/kube"
+	"alert-dispatcher/internal/loki"
+	"alert-dispatcher/internal/s3store"
+	"alert-dispatcher/internal/ses"
 	"alert-dispatcher/internal/server"
+	"alert-dispatcher/internal/snstopic"
 	"alert-dispatcher/internal/sqs"
+	"alert-dispatcher/internal/state"
+	"alert-dispatcher/internal/state/dynamo"
+	"alert-dispatcher/internal/state/postgres"
 	"alert-dispatcher/notifier"
 )
 
@@ -19,28 +41,182 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create poller: %v", err)
 	}
+	poller.DeadLetterURL = cfg.SQSDeadLetterURL
+	poller.MaxReceivers = cfg.SQSMaxReceivers
+	poller.MaxIdleWait = cfg.SQSMaxIdleWait
 
+	var store state.Store
+	var pgStore *postgres.Store
+	switch cfg.State.Backend {
+	case config.StateRedis:
+		store, err = state.NewRedisStore(state.RedisOptions{
+			Addr:      cfg.State.RedisAddr,
+			Password:  cfg.State.RedisPassword,
+			DB:        cfg.State.RedisDB,
+			KeyPrefix: cfg.State.RedisKeyPrefix,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create Redis state store: %v", err)
+		}
+	case config.StateDynamoDB:
+		store, err = dynamo.NewStore(cfg.State.DynamoDBTable)
+		if err != nil {
+			log.Fatalf("Failed to create DynamoDB state store: %v", err)
+		}
+	case config.StatePostgres:
+		pgStore, err = postgres.NewStore(cfg.State.PostgresDSN)
+		if err != nil {
+			log.Fatalf("Failed to create Postgres state store: %v", err)
+		}
+		go pgStore.Run(context.Background())
+		store = pgStore
+	default:
+		store = state.NewMemoryStore()
+	}
 
-	handler := func(body string) error {
-		alertMsg, err := adapter.AdaptSQSMessageWithRouting(body, cfg.SlackChannels, cfg.AlarmChannels)
+	dispatcher := dispatch.NewDispatcher(cfg, store)
+	defer dispatcher.Close()
+	if pgStore != nil {
+		// Postgres also keeps alert history and the audit log for reporting
+		dispatcher.AddSink(pgStore)
+		audit.Use(pgStore)
+	}
+
+	switch cfg.Email.Backend {
+	case config.EmailSMTP:
+		sender := notifier.NewSMTPSender(cfg.Email.SMTPHost, cfg.Email.SMTPPort, cfg.Email.SMTPUsername, cfg.Email.SMTPPassword)
+		dispatcher.UseEmailSender(sender)
+		notifier.Register(notifier.TargetEmail, notifier.EmailFactory(sender))
+	case config.EmailSES:
+		sender, err := ses.NewSender()
+		if err != nil {
+			log.Fatalf("Failed to create SES sender: %v", err)
+		}
+		dispatcher.UseEmailSender(sender)
+		notifier.Register(notifier.TargetEmail, notifier.EmailFactory(sender))
+	}
+
+	publisher, err := snstopic.NewPublisher(cfg.SNSTopicARN)
+	if err != nil {
+		log.Fatalf("Failed to create SNS publisher: %v", err)
+	}
+	notifier.Register(notifier.TargetSNS, publisher.Factory())
+	if cfg.SNSTopicARN != "" {
+		dispatcher.AddStream("sns", publisher)
+	}
+
+	if len(cfg.Kafka.Brokers) > 0 {
+		producer, err := kafkatopic.NewProducer(cfg.Kafka)
+		if err != nil {
+			log.Fatalf("Failed to create Kafka producer: %v", err)
+		}
+		dispatcher.AddStream("kafka", producer)
+	}
+
+	if cfg.S3Archive.Bucket != "" {
+		uploader, err := s3store.NewUploader(cfg.S3Archive.Bucket)
+		if err != nil {
+			log.Fatalf("Failed to create S3 uploader: %v", err)
+		}
+		archiver := archive.NewArchiver(dispatcher.Archive(), uploader, cfg.S3Archive.Prefix, cfg.S3Archive.After, cfg.S3Archive.Interval)
+		log.Printf("Archiving alert history to s3://%s/%s", cfg.S3Archive.Bucket, cfg.S3Archive.Prefix)
+		go archiver.Run(context.Background())
+	}
+
+	if cfg.CloudWatchSnooze != nil {
+		client, err := cwalarms.NewClient(cfg.CloudWatchSnooze.Timeout)
+		if err != nil {
+			log.Fatalf("Failed to create CloudWatch client: %v", err)
+		}
+		dispatcher.UseAlarmActions(client)
+		go dispatcher.RunAlarmSnoozes(context.Background())
+	}
+
+	go dispatcher.RunHandoffs(context.Background())
+	go dispatcher.RunHeartbeats(context.Background())
+
+	quickActions := cfg.QuickActionTypes()
+	if quickActions[config.QuickActionScaleGroup] {
+		client, err := autoscaling.NewClient(cfg.QuickActionTimeout)
+		if err != nil {
+			log.Fatalf("Failed to create Auto Scaling client: %v", err)
+		}
+		dispatcher.UseScalingGroups(client)
+	}
+
+	logQueries := cfg.LogQueryTypes()
+	if logQueries[config.LogQueryCloudWatch] {
+		client, err := cwlogs.NewClient(cfg.LogQueryTimeout)
+		if err != nil {
+			log.Fatalf("Failed to create CloudWatch Logs client: %v", err)
+		}
+		dispatcher.UseLogsInsights(client)
+	}
+	if logQueries[config.LogQueryLoki] {
+		dispatcher.UseLoki(loki.NewClient(cfg.LokiToken, cfg.LogQueryTimeout))
+	}
+
+	if cfg.GrafanaURL != "" {
+		renderer, err := grafana.NewRenderer(cfg.GrafanaURL, cfg.GrafanaRenderToken, cfg.GraphRenderTimeout)
+		if err != nil {
+			log.Fatalf("Failed to create Grafana renderer: %v", err)
+		}
+		dispatcher.UseGraphRenderer(renderer)
+	}
+
+	// Routes from custom resources may add deployment quick actions later, so the operator's
+	// client runs them too
+	if cfg.Kubernetes.CRDs || quickActions[config.QuickActionScaleDeployment] || quickActions[config.QuickActionRestartDeployment] {
+		client, err := kube.NewInClusterClient()
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		dispatcher.UseDeployments(client)
+		if cfg.Kubernetes.CRDs {
+			operator := kube.NewOperator(client, cfg, cfg.Kubernetes.Namespace, dispatcher.Reload)
+			log.Printf("Reading AlertRoutes and AlertSilences from Kubernetes")
+			go operator.Run(context.Background())
+		}
+	}
+
+	process := func(ctx context.Context, body string) error {
+		alertMsg, err := adapter.AdaptSQSMessageWithRouting(body, cfg.SlackChannels, cfg.AlarmMappings(), cfg)
 		if err != nil {
 			return err
 		}
+		dispatcher.Heartbeat(ctx, "sqs")
 		
-		// Create notifier for specific channel
-		channelNotifier := notifier.NewSlackNotifier(cfg.SlackBotToken, alertMsg.Channel)
-		log.Printf("Sending %s alert to %s", alertMsg.Priority, alertMsg.Channel)
+		log.Printf("Sending %s alert to %s", alertMsg.Severity, alertMsg.Channel)
 		
-		return channelNotifier.Notify(alertMsg.Message)
+		return dispatcher.Dispatch(ctx, alertMsg, "")
+	}
+
+	// A message that runs past MESSAGE_TIMEOUT_SEC has its outbound calls cancelled and is left
+	// in the queue for redelivery
+	handler := func(ctx context.Context, body string) error {
+		err := errs.WithDeadline(ctx, cfg.MessageTimeout, func(ctx context.Context) error {
+			return process(ctx, body)
+		})
+		errs.Count(err)
+		return err
 	}
 
-	srv := server.NewServer(cfg.SlackSigningSecret, cfg.ServerPort, cfg)
+	// SIGTERM stops polling: the messages being handled are finished and the rest of their
+	// batches released for other replicas
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	queueName := path.Base(cfg.SQSQueueURL)
+	go poller.MonitorDepth(ctx, cfg.SQSBacklog.Interval, func(ctx context.Context, depth sqs.Depth) {
+		dispatcher.CheckBacklog(ctx, queueName, depth.Visible, depth.OldestAge)
+	})
+
+	srv := server.NewServer(cfg.SlackSigningSecret, cfg.ServerPort, cfg, dispatcher)
 
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(1)
 
 	go func() {
-		defer wg.Done()
 		log.Println("Starting HTTP server...")
 		if err := srv.Start(); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
@@ -51,7 +227,11 @@ func main() {
 		defer wg.Done()
 		log.Println("Starting SQS polling...")
 		for {
-			poller.Poll(handler)
+			poller.Poll(ctx, handler)
+			if ctx.Err() != nil {
+				log.Println("SQS polling stopped")
+				return
+			}
 			time.Sleep(time.Duration(cfg.PollIntervalSec) * time.Second)
 		}
 	}()
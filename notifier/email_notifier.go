@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// EmailMessage is a rendered email ready for a backend to send
+type EmailMessage struct {
+	From    string
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// EmailSender delivers email through a backend such as SMTP or SES
+type EmailSender interface {
+	Send(ctx context.Context, msg EmailMessage) error
+}
+
+// EmailNotifier emails alerts rendered with the HTML template for their source
+type EmailNotifier struct {
+	sender EmailSender
+	from   string
+	to     []string
+}
+
+func NewEmailNotifier(sender EmailSender, from string, to []string) *EmailNotifier {
+	return &EmailNotifier{
+		sender: sender,
+		from:   from,
+		to:     to,
+	}
+}
+
+func (e *EmailNotifier) Notify(ctx context.Context, message string) error {
+	return e.sender.Send(ctx, EmailMessage{
+		From:    e.from,
+		To:      e.to,
+		Subject: "Alert",
+		HTML:    "<pre>" + template.HTMLEscapeString(message) + "</pre>",
+		Text:    message,
+	})
+}
+
+// NotifyAlert emails the alert to the notifier's recipients
+func (e *EmailNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, _ string) error {
+	return e.NotifyAlertTo(ctx, a, nil)
+}
+
+// NotifyAlertTo emails the alert to the given recipients, or the notifier's default recipients when empty
+func (e *EmailNotifier) NotifyAlertTo(ctx context.Context, a *alert.Alert, to []string) error {
+	if len(to) == 0 {
+		to = e.to
+	}
+
+	html, err := renderEmailHTML(a)
+	if err != nil {
+		return fmt.Errorf("failed to render email for %s: %v", a.Name, err)
+	}
+
+	return e.sender.Send(ctx, EmailMessage{
+		From:    e.from,
+		To:      to,
+		Subject: emailSubject(a),
+		HTML:    html,
+		Text:    emailText(a),
+	})
+}
+
+func emailSubject(a *alert.Alert) string {
+	return fmt.Sprintf("[%s] %s: %s", a.Severity, strings.ToUpper(a.Status), a.Name)
+}
+
+// emailText is the plain-text alternative for mail clients that don't render HTML
+func emailText(a *alert.Alert) string {
+	lines := []string{
+		emailSubject(a),
+		"",
+		"Source: " + a.Source,
+		"State: " + a.State,
+	}
+	for _, key := range []string{"description", "summary", "reason"} {
+		if v := a.Annotations[key]; v != "" {
+			lines = append(lines, "", v)
+			break
+		}
+	}
+	if len(a.Labels) > 0 {
+		lines = append(lines, "")
+		keys := make([]string, 0, len(a.Labels))
+		for k := range a.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if a.Labels[k] != "" {
+				lines = append(lines, fmt.Sprintf("%s: %s", k, a.Labels[k]))
+			}
+		}
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		lines = append(lines, "", link)
+	}
+	return strings.Join(lines, "\n")
+}
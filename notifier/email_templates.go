@@ -0,0 +1,505 @@
+package notifier
+
+import (
+	"html/template"
+	"strings"
+
+	"alert-dispatcher/internal/alert"
+)
+
+// Every source template defines "details"; the shared layout renders the header and links around it
+const emailLayout = `<div style="font-family: Arial, sans-serif; max-width: 640px">
+<div style="border-left: 6px solid {{color .}}; padding: 8px 16px">
+<h2 style="margin: 0">{{.Name}}</h2>
+<p style="margin: 4px 0; color: #555">{{.Severity}} &middot; {{upper .Status}} &middot; {{.Source}}</p>
+</div>
+{{template "details" .}}
+<p>
+{{- with index .URLs "source"}}<a href="{{.}}">View alert</a> {{end}}
+{{- with index .URLs "dashboard"}}<a href="{{.}}">View dashboard</a> {{end}}
+{{- with index .URLs "silence"}}<a href="{{.}}">Silence</a>{{end}}
+</p>
+{{- with index .URLs "image"}}
+<img src="{{.}}" alt="Graph" style="max-width: 100%">
+{{- end}}
+</div>`
+
+var emailTemplates = map[string]string{
+	alert.SourceCloudWatch: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{with index .Extensions "old_state"}}{{.}} &rarr; {{end}}{{.State}}</td></tr>
+<tr><td><b>Metric</b></td><td>{{index .Labels "namespace"}}/{{index .Labels "metric_name"}}</td></tr>
+<tr><td><b>Region</b></td><td>{{index .Labels "region"}}</td></tr>
+<tr><td><b>Account</b></td><td>{{index .Labels "account_id"}}</td></tr>
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+<tr><td><b>Reason</b></td><td>{{index .Annotations "reason"}}</td></tr>
+</table>
+{{end}}`,
+
+	alert.SourceGrafana: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- range $k, $v := .Annotations}}
+<tr><td><b>{{$k}}</b></td><td>{{$v}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceAlertmanager: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "summary"}}
+<tr><td><b>Summary</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Extensions "alert_count"}}
+<tr><td><b>Alerts in group</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceDatadog: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "query"}}
+<tr><td><b>Query</b></td><td><code>{{.}}</code></td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceNewRelic: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "severity"}}
+<tr><td><b>Severity</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceSentry: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "culprit"}}
+<tr><td><b>Culprit</b></td><td><code>{{.}}</code></td></tr>
+{{- end}}
+{{- with index .Annotations "release"}}
+<tr><td><b>Release</b></td><td><code>{{.}}</code></td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceAzure: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "azure_severity"}}
+<tr><td><b>Severity</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceGCP: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "gcp_severity"}}
+<tr><td><b>Severity</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "value"}}
+<tr><td><b>Value</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "summary"}}
+<tr><td><b>Summary</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Documentation</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceNagios: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "notification_type"}}
+<tr><td><b>Notification</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Output</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceDynatrace: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "dynatrace_severity"}}
+<tr><td><b>Severity</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Details</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceSplunk: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "value"}}
+<tr><td><b>Results</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceKibana: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "description"}}
+<tr><td><b>Reason</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "value"}}
+<tr><td><b>Value</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceUptimeKuma: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Status</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "description"}}
+<tr><td><b>Message</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "value"}}
+<tr><td><b>Response time</b></td><td>{{.}} ms</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourcePingdom: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "description"}}
+<tr><td><b>Error</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceStatusCake: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "status_code"}}
+<tr><td><b>Status code</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "value"}}
+<tr><td><b>Response time</b></td><td>{{.}} ms</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceAWSCost: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Alert</b></td><td>{{.State}}</td></tr>
+{{- with index .Annotations "value"}}
+<tr><td><b>Amount</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "threshold"}}
+<tr><td><b>Threshold</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceCloudTrail: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Outcome</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "actor_arn"}}
+<tr><td><b>Actor</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Extensions "source_ip_address"}}
+<tr><td><b>Source IP</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Error</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceECS: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "stop_code"}}
+<tr><td><b>Stop code</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "value"}}
+<tr><td><b>Exit code</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Reason</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceRDS: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Category</b></td><td>{{.State}}</td></tr>
+{{- with index .Labels "source_type"}}
+<tr><td><b>Source</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Event</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceAWSBackup: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Job state</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "resource_arn"}}
+<tr><td><b>Resource</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Message</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceLambda: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Condition</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "request_id"}}
+<tr><td><b>Request ID</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Error</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceCodeStar: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "stage"}}
+<tr><td><b>Stage</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Extensions "action"}}
+<tr><td><b>Action</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Error</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceGitHub: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Conclusion</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "sha"}}
+<tr><td><b>Commit</b></td><td><code>{{.}}</code></td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceGitLab: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Status</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "sha"}}
+<tr><td><b>Commit</b></td><td><code>{{.}}</code></td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Failure</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceArgoCD: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "sync_status"}}
+<tr><td><b>Sync</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Extensions "health_status"}}
+<tr><td><b>Health</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Extensions "revision"}}
+<tr><td><b>Revision</b></td><td><code>{{.}}</code></td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Message</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceFlux: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>Reason</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "revision"}}
+<tr><td><b>Revision</b></td><td><code>{{.}}</code></td></tr>
+{{- end}}
+{{- with index .Annotations "summary"}}
+<tr><td><b>Summary</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Message</b></td><td><pre>{{.}}</pre></td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+
+	alert.SourceZabbix: `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- with index .Extensions "zabbix_severity"}}
+<tr><td><b>Severity</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "value"}}
+<tr><td><b>Value</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- with index .Annotations "description"}}
+<tr><td><b>Description</b></td><td>{{.}}</td></tr>
+{{- end}}
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`,
+}
+
+// defaultEmailDetails is used for sources without their own template
+const defaultEmailDetails = `{{define "details"}}
+<table cellpadding="4">
+<tr><td><b>State</b></td><td>{{.State}}</td></tr>
+{{- range $k, $v := .Labels}}
+<tr><td><code>{{$k}}</code></td><td>{{$v}}</td></tr>
+{{- end}}
+</table>
+{{end}}`
+
+var emailFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"color": func(a *alert.Alert) string {
+		if a.IsResolved() {
+			return "#2eb886"
+		}
+		switch a.Severity {
+		case "P0":
+			return "#d00000"
+		case "P1":
+			return "#daa038"
+		default:
+			return "#439fe0"
+		}
+	},
+}
+
+// parsedEmailTemplates holds the layout combined with each source's details, keyed by source
+var parsedEmailTemplates = func() map[string]*template.Template {
+	parsed := map[string]*template.Template{
+		"": template.Must(template.New("email").Funcs(emailFuncs).Parse(emailLayout + defaultEmailDetails)),
+	}
+	for source, details := range emailTemplates {
+		parsed[source] = template.Must(template.New("email").Funcs(emailFuncs).Parse(emailLayout + details))
+	}
+	return parsed
+}()
+
+func renderEmailHTML(a *alert.Alert) (string, error) {
+	tmpl, ok := parsedEmailTemplates[a.Source]
+	if !ok {
+		tmpl = parsedEmailTemplates[""]
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, a); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
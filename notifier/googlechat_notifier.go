@@ -0,0 +1,166 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+)
+
+// GoogleChatNotifier posts to a Google Chat space through an incoming webhook
+type GoogleChatNotifier struct {
+	webhookURL string
+	client     *http.Client
+}
+
+func NewGoogleChatNotifier(webhookURL string, timeout time.Duration) *GoogleChatNotifier {
+	return &GoogleChatNotifier{
+		webhookURL: webhookURL,
+		client:     httpclient.New(timeout),
+	}
+}
+
+// Card v2 message structure; only the widgets we use are modelled
+type googleChatMessage struct {
+	Text    string           `json:"text,omitempty"`
+	CardsV2 []googleChatCard `json:"cardsV2,omitempty"`
+}
+
+type googleChatCard struct {
+	CardID string `json:"cardId"`
+	Card   struct {
+		Header   googleChatHeader    `json:"header"`
+		Sections []googleChatSection `json:"sections"`
+	} `json:"card"`
+}
+
+type googleChatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type googleChatSection struct {
+	Header  string             `json:"header,omitempty"`
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget struct {
+	DecoratedText *googleChatDecoratedText `json:"decoratedText,omitempty"`
+	TextParagraph *googleChatTextParagraph `json:"textParagraph,omitempty"`
+	ButtonList    *googleChatButtonList    `json:"buttonList,omitempty"`
+}
+
+type googleChatDecoratedText struct {
+	TopLabel string `json:"topLabel"`
+	Text     string `json:"text"`
+}
+
+type googleChatTextParagraph struct {
+	Text string `json:"text"`
+}
+
+type googleChatButtonList struct {
+	Buttons []googleChatButton `json:"buttons"`
+}
+
+type googleChatButton struct {
+	Text    string `json:"text"`
+	OnClick struct {
+		OpenLink struct {
+			URL string `json:"url"`
+		} `json:"openLink"`
+	} `json:"onClick"`
+}
+
+// Notify posts a plain-text message to the space
+func (g *GoogleChatNotifier) Notify(ctx context.Context, message string) error {
+	return g.post(ctx, googleChatMessage{Text: message})
+}
+
+// NotifyAlert posts the alert as a card with its status, labels, summary and links
+func (g *GoogleChatNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, _ string) error {
+	icon := "🔥"
+	if a.IsResolved() {
+		icon = "✅"
+	}
+
+	card := googleChatCard{CardID: "alert"}
+	card.Card.Header = googleChatHeader{
+		Title:    fmt.Sprintf("%s [%s] %s", icon, a.Severity, a.Name),
+		Subtitle: fmt.Sprintf("%s · %s", strings.ToUpper(a.Status), a.Source),
+	}
+
+	details := googleChatSection{}
+	if summary := a.Annotations["summary"]; summary != "" {
+		details.Widgets = append(details.Widgets, googleChatWidget{TextParagraph: &googleChatTextParagraph{Text: summary}})
+	}
+	labels := make([]string, 0, len(a.Labels))
+	for name := range a.Labels {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	for _, name := range labels {
+		details.Widgets = append(details.Widgets, googleChatWidget{DecoratedText: &googleChatDecoratedText{TopLabel: name, Text: a.Labels[name]}})
+	}
+	if len(details.Widgets) > 0 {
+		card.Card.Sections = append(card.Card.Sections, details)
+	}
+
+	var buttons []googleChatButton
+	for _, link := range []struct{ key, text string }{
+		{alert.URLSource, "View in " + a.Source},
+		{alert.URLDashboard, "Dashboard"},
+		{alert.URLSilence, "Silence"},
+	} {
+		if target := a.URLs[link.key]; target != "" {
+			button := googleChatButton{Text: link.text}
+			button.OnClick.OpenLink.URL = target
+			buttons = append(buttons, button)
+		}
+	}
+	if len(buttons) > 0 {
+		card.Card.Sections = append(card.Card.Sections, googleChatSection{
+			Widgets: []googleChatWidget{{ButtonList: &googleChatButtonList{Buttons: buttons}}},
+		})
+	}
+
+	return g.post(ctx, googleChatMessage{
+		Text:    fmt.Sprintf("[%s] %s is %s", a.Severity, a.Name, strings.ToUpper(a.Status)),
+		CardsV2: []googleChatCard{card},
+	})
+}
+
+func (g *GoogleChatNotifier) post(ctx context.Context, message googleChatMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Google Chat message: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Google Chat request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		// The webhook URL carries the space's key and token, so keep it out of the error
+		return fmt.Errorf("failed to post to Google Chat: %v", errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("google chat responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+	"alert-dispatcher/internal/render"
+)
+
+// MattermostNotifier posts to a Mattermost channel as a bot through the REST API
+type MattermostNotifier struct {
+	serverURL    string
+	botToken     string
+	channelID    string
+	actionURL    string
+	actionSecret string
+	client       *http.Client
+}
+
+func NewMattermostNotifier(serverURL, botToken, channelID string, timeout time.Duration) *MattermostNotifier {
+	return &MattermostNotifier{
+		serverURL: strings.TrimRight(serverURL, "/"),
+		botToken:  botToken,
+		channelID: channelID,
+		client:    httpclient.New(timeout),
+	}
+}
+
+// MattermostAction is an interactive message button. Mattermost posts Context back to URL when
+// it is pressed.
+type MattermostAction struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Style       string                `json:"style,omitempty"`
+	Integration MattermostIntegration `json:"integration"`
+}
+
+type MattermostIntegration struct {
+	URL     string                 `json:"url"`
+	Context map[string]interface{} `json:"context"`
+}
+
+type mattermostAttachment struct {
+	Color   string             `json:"color,omitempty"`
+	Text    string             `json:"text"`
+	Actions []MattermostAction `json:"actions,omitempty"`
+}
+
+type mattermostPost struct {
+	ChannelID string `json:"channel_id"`
+	Message   string `json:"message"`
+	Props     struct {
+		Attachments []mattermostAttachment `json:"attachments,omitempty"`
+	} `json:"props"`
+}
+
+// WithActions adds Acknowledge and Dismiss buttons to alerts, calling back to actionURL with secret
+func (m *MattermostNotifier) WithActions(actionURL, secret string) *MattermostNotifier {
+	m.actionURL = actionURL
+	m.actionSecret = secret
+	return m
+}
+
+// Attachment colors matching the Slack severity bar, which uses Slack color names
+var mattermostColors = map[string]string{
+	"danger":  "#D00000",
+	"warning": "#DAA038",
+	"good":    "#2EB886",
+}
+
+// NotifyAlert posts the alert in Markdown with a severity color bar, and with Acknowledge and
+// Dismiss buttons when actions are enabled and it is still firing
+func (m *MattermostNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	renderer, err := render.ForName(render.NameMarkdown)
+	if err != nil {
+		return err
+	}
+	message, err := renderer.Render(a)
+	if err != nil {
+		return fmt.Errorf("failed to render alert for Mattermost: %v", err)
+	}
+
+	color := SeverityColor(a.Severity, a.IsResolved())
+	if hex, ok := mattermostColors[color]; ok {
+		color = hex
+	}
+
+	var actions []MattermostAction
+	// Presses can't be verified without the secret, so the buttons need both
+	if m.actionURL != "" && m.actionSecret != "" && !a.IsResolved() {
+		actions = MattermostAlertActions(m.actionURL, alertID, m.actionSecret)
+	}
+	return m.NotifyWithActions(ctx, message, color, actions)
+}
+
+// Notify posts a markdown message to the channel
+func (m *MattermostNotifier) Notify(ctx context.Context, message string) error {
+	return m.post(ctx, mattermostPost{ChannelID: m.channelID, Message: message})
+}
+
+// NotifyWithActions posts the message as an attachment with a color bar and buttons.
+// Mattermost has no Slack-style color names, so color is a hex value.
+func (m *MattermostNotifier) NotifyWithActions(ctx context.Context, message, color string, actions []MattermostAction) error {
+	post := mattermostPost{ChannelID: m.channelID}
+	post.Props.Attachments = []mattermostAttachment{{Color: color, Text: message, Actions: actions}}
+	return m.post(ctx, post)
+}
+
+// MattermostAlertActions builds Acknowledge and Dismiss buttons for an alert that call back to
+// actionURL. The secret is echoed back so the callback can be verified.
+func MattermostAlertActions(actionURL, alertID, secret string) []MattermostAction {
+	action := func(id, name, style string) MattermostAction {
+		return MattermostAction{
+			ID:    id,
+			Name:  name,
+			Style: style,
+			Integration: MattermostIntegration{
+				URL: actionURL,
+				Context: map[string]interface{}{
+					"action":   id,
+					"alert_id": alertID,
+					"secret":   secret,
+				},
+			},
+		}
+	}
+	return []MattermostAction{
+		action("acknowledge", "✅ Acknowledge", "primary"),
+		action("dismiss", "✖️ Dismiss", "danger"),
+	}
+}
+
+func (m *MattermostNotifier) post(ctx context.Context, post mattermostPost) error {
+	body, err := json.Marshal(post)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Mattermost post: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.serverURL+"/api/v4/posts", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Mattermost request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.botToken)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to Mattermost: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mattermost responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
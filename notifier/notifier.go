@@ -1,5 +1,41 @@
 package notifier
 
+import (
+	"context"
+	"io"
+
+	"alert-dispatcher/internal/alert"
+)
+
 type Notifier interface {
-	Notify(message string) error
+	Notify(ctx context.Context, message string) error
+}
+
+// AlertNotifier is a destination that formats canonical alerts itself. alertID identifies the
+// dispatched alert, for destinations with action buttons.
+type AlertNotifier interface {
+	Notifier
+	NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error
+}
+
+// Close releases the connections held by n. Notifiers that keep long-lived connections, such as
+// a Kafka producer, implement io.Closer; for the rest it does nothing.
+func Close(n Notifier) error {
+	if closer, ok := n.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// AlertPayload is the JSON body alerts are republished with to webhooks and message streams
+type AlertPayload struct {
+	AlertID     string       `json:"alert_id,omitempty"`
+	Fingerprint string       `json:"fingerprint,omitempty"` // same for every notification of an alert
+	Alert       *alert.Alert `json:"alert,omitempty"`
+	Text        string       `json:"text,omitempty"`
+}
+
+// NewAlertPayload wraps a dispatched alert for republishing
+func NewAlertPayload(a *alert.Alert, alertID string) AlertPayload {
+	return AlertPayload{AlertID: alertID, Fingerprint: a.Fingerprint(), Alert: a}
 }
@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+)
+
+// NtfyNotifier publishes push notifications to an ntfy topic, on ntfy.sh or a self-hosted server
+type NtfyNotifier struct {
+	serverURL string
+	topic     string
+	token     string
+	client    *http.Client
+}
+
+// NewNtfyNotifier publishes to topic on serverURL; token is an optional access token for
+// protected topics
+func NewNtfyNotifier(serverURL, topic, token string, timeout time.Duration) *NtfyNotifier {
+	return &NtfyNotifier{
+		serverURL: strings.TrimRight(serverURL, "/"),
+		topic:     topic,
+		token:     token,
+		client:    httpclient.New(timeout),
+	}
+}
+
+// Notify publishes a message at default priority
+func (n *NtfyNotifier) Notify(ctx context.Context, message string) error {
+	return n.publish(ctx, message, nil)
+}
+
+// NotifyAlert publishes the alert at a priority matching its own, with a button opening its source
+func (n *NtfyNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, _ string) error {
+	message := a.Annotations["description"]
+	if message == "" {
+		message = a.Annotations["reason"]
+	}
+	if message == "" {
+		message = fmt.Sprintf("%s is %s", a.Name, a.Status)
+	}
+
+	tags := "rotating_light"
+	if a.IsResolved() {
+		tags = "white_check_mark"
+	}
+	headers := map[string]string{
+		"Title":    fmt.Sprintf("[%s] %s is %s", a.Severity, a.Name, strings.ToUpper(a.Status)),
+		"Priority": NtfyPriority(a.Severity, a.IsResolved()),
+		"Tags":     tags + "," + strings.ToLower(a.Severity),
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		headers["Click"] = link
+		headers["Actions"] = fmt.Sprintf("view, Open in %s, %s", a.Source, link)
+	}
+	return n.publish(ctx, message, headers)
+}
+
+// NtfyPriority maps our priorities onto ntfy's 1-5 scale, where 5 vibrates and pops up
+func NtfyPriority(priority string, resolved bool) string {
+	if resolved {
+		return "2"
+	}
+	switch priority {
+	case "P0":
+		return "5"
+	case "P1":
+		return "4"
+	default:
+		return "3"
+	}
+}
+
+func (n *NtfyNotifier) publish(ctx context.Context, message string, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.serverURL+"/"+n.topic, strings.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("failed to create ntfy request: %v", err)
+	}
+	// Header values must be ASCII, so anything else is sent as an RFC 2047 word, which ntfy decodes
+	for key, value := range headers {
+		req.Header.Set(key, mime.QEncoding.Encode("utf-8", value))
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to ntfy: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ntfy responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+)
+
+// Opsgenie limits on alert fields
+const (
+	opsgenieMaxMessage = 130
+	opsgenieMaxAlias   = 512
+	opsgenieMaxTag     = 50
+)
+
+// OpsgenieNotifier pages through the Opsgenie Alert API. The alarm name is used as the alert
+// alias, so repeated notifications for the same alarm update one Opsgenie alert and a resolved
+// notification closes it.
+type OpsgenieNotifier struct {
+	apiURL string
+	apiKey string
+	client *http.Client
+}
+
+func NewOpsgenieNotifier(apiURL, apiKey string, timeout time.Duration) *OpsgenieNotifier {
+	return &OpsgenieNotifier{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		apiKey: apiKey,
+		client: httpclient.New(timeout),
+	}
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Priority    string            `json:"priority"`
+}
+
+// Notify creates a bare alert from a message, for callers without a canonical alert
+func (o *OpsgenieNotifier) Notify(ctx context.Context, message string) error {
+	return o.post(ctx, "/v2/alerts", opsgenieAlert{
+		Message:  truncate(message, opsgenieMaxMessage),
+		Alias:    truncate(message, opsgenieMaxAlias),
+		Priority: "P3",
+	})
+}
+
+// NotifyAlert creates or updates the Opsgenie alert for a firing alert and closes it once resolved.
+// alertID is added to the alert's details so responders can find the Slack message.
+func (o *OpsgenieNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	alias := truncate(a.Name, opsgenieMaxAlias)
+	if a.IsResolved() {
+		path := fmt.Sprintf("/v2/alerts/%s/close?identifierType=alias", url.PathEscape(alias))
+		return o.post(ctx, path, map[string]string{
+			"source": "alert-dispatcher",
+			"note":   fmt.Sprintf("Resolved in %s (%s)", a.Source, a.State),
+		})
+	}
+
+	description := a.Annotations["description"]
+	if description == "" {
+		description = a.Annotations["reason"]
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		description = strings.TrimSpace(description + "\n\n" + link)
+	}
+
+	return o.post(ctx, "/v2/alerts", opsgenieAlert{
+		Message:     truncate(a.Name, opsgenieMaxMessage),
+		Alias:       alias,
+		Description: description,
+		Tags:        opsgenieTags(a),
+		Details:     opsgenieDetails(a, alertID),
+		Source:      a.Source,
+		Priority:    OpsgeniePriority(a.Severity),
+	})
+}
+
+// OpsgeniePriority maps our priorities onto Opsgenie's, where P1 is the most urgent
+func OpsgeniePriority(priority string) string {
+	switch priority {
+	case "P0":
+		return "P1"
+	case "P1":
+		return "P2"
+	default:
+		return "P3"
+	}
+}
+
+// opsgenieDetails is the alert's labels plus the dispatcher's alert ID
+func opsgenieDetails(a *alert.Alert, alertID string) map[string]string {
+	details := make(map[string]string, len(a.Labels)+1)
+	for k, v := range a.Labels {
+		details[k] = v
+	}
+	if alertID != "" {
+		details["dispatcher_alert_id"] = alertID
+	}
+	return details
+}
+
+// opsgenieTags turns alarm dimensions and labels into "key:value" tags
+func opsgenieTags(a *alert.Alert) []string {
+	tags := []string{a.Source, a.Severity}
+	for k, v := range a.Labels {
+		if v != "" {
+			tags = append(tags, truncate(k+":"+v, opsgenieMaxTag))
+		}
+	}
+	sort.Strings(tags[2:])
+	return tags
+}
+
+func (o *OpsgenieNotifier) post(ctx context.Context, path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Opsgenie request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.apiURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create Opsgenie request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+o.apiKey)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to Opsgenie: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Alert requests are processed asynchronously and answered with 202 Accepted
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("opsgenie responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// truncate shortens s to at most max characters
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,129 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+)
+
+// PagerDutyNotifier triggers incidents through the PagerDuty Events API v2. The alert's
+// fingerprint is the dedup key, so repeated firings update one incident and a resolved
+// notification resolves it.
+type PagerDutyNotifier struct {
+	eventsURL  string
+	routingKey string
+	client     *http.Client
+}
+
+func NewPagerDutyNotifier(eventsURL, routingKey string, timeout time.Duration) *PagerDutyNotifier {
+	return &PagerDutyNotifier{
+		eventsURL:  eventsURL,
+		routingKey: routingKey,
+		client:     httpclient.New(timeout),
+	}
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// Notify triggers a bare incident from a message, for callers without a canonical alert
+func (p *PagerDutyNotifier) Notify(ctx context.Context, message string) error {
+	return p.send(ctx, pagerDutyEvent{
+		RoutingKey:  p.routingKey,
+		EventAction: "trigger",
+		Payload:     &pagerDutyPayload{Summary: truncate(message, 1024), Source: "alert-dispatcher", Severity: "warning"},
+	})
+}
+
+// NotifyAlert triggers the incident for a firing alert and resolves it once the alert resolves
+func (p *PagerDutyNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	event := pagerDutyEvent{
+		RoutingKey: p.routingKey,
+		DedupKey:   a.Fingerprint(),
+	}
+	if a.IsResolved() {
+		event.EventAction = "resolve"
+		return p.send(ctx, event)
+	}
+
+	summary := a.Name
+	if description := a.Annotations["description"]; description != "" {
+		summary += ": " + description
+	}
+
+	event.EventAction = "trigger"
+	event.Payload = &pagerDutyPayload{
+		Summary:       truncate(summary, 1024),
+		Source:        a.Source,
+		Severity:      PagerDutySeverity(a.Severity),
+		Component:     a.Labels["service"],
+		CustomDetails: opsgenieDetails(a, alertID),
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		event.Links = []pagerDutyLink{{Href: link, Text: "Source"}}
+	}
+	return p.send(ctx, event)
+}
+
+// PagerDutySeverity maps our priorities onto PagerDuty event severities
+func PagerDutySeverity(priority string) string {
+	switch priority {
+	case "P0":
+		return "critical"
+	case "P1":
+		return "error"
+	default:
+		return "warning"
+	}
+}
+
+func (p *PagerDutyNotifier) send(ctx context.Context, event pagerDutyEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty event: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.eventsURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create PagerDuty request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to PagerDuty: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Events are queued and answered with 202 Accepted
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("pagerduty responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+)
+
+const (
+	pushoverAPIURL     = "https://api.pushover.net/1/messages.json"
+	pushoverMaxTitle   = 250
+	pushoverMaxMessage = 1024
+)
+
+// PushoverNotifier sends mobile push notifications through the Pushover Message API to a user
+// or delivery group key
+type PushoverNotifier struct {
+	appToken string
+	userKey  string
+	client   *http.Client
+}
+
+func NewPushoverNotifier(appToken, userKey string, timeout time.Duration) *PushoverNotifier {
+	return &PushoverNotifier{
+		appToken: appToken,
+		userKey:  userKey,
+		client:   httpclient.New(timeout),
+	}
+}
+
+// Notify pushes a message at normal priority
+func (p *PushoverNotifier) Notify(ctx context.Context, message string) error {
+	return p.post(ctx, url.Values{
+		"message": {truncate(message, pushoverMaxMessage)},
+	})
+}
+
+// NotifyAlert pushes the alert at a priority matching its own, linking to its source
+func (p *PushoverNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, _ string) error {
+	message := a.Annotations["description"]
+	if message == "" {
+		message = a.Annotations["reason"]
+	}
+	if message == "" {
+		message = fmt.Sprintf("%s is %s", a.Name, a.Status)
+	}
+
+	form := url.Values{
+		"title":    {truncate(fmt.Sprintf("[%s] %s is %s", a.Severity, a.Name, strings.ToUpper(a.Status)), pushoverMaxTitle)},
+		"message":  {truncate(message, pushoverMaxMessage)},
+		"priority": {strconv.Itoa(PushoverPriority(a.Severity, a.IsResolved()))},
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		form.Set("url", link)
+		form.Set("url_title", "Open in "+a.Source)
+	}
+	return p.post(ctx, form)
+}
+
+// PushoverPriority maps our priorities onto Pushover's: P0 bypasses quiet hours, P2 is silent,
+// and resolutions never make a sound
+func PushoverPriority(priority string, resolved bool) int {
+	if resolved {
+		return -1
+	}
+	switch priority {
+	case "P0":
+		return 1
+	case "P1":
+		return 0
+	default:
+		return -1
+	}
+}
+
+func (p *PushoverNotifier) post(ctx context.Context, form url.Values) error {
+	form.Set("token", p.appToken)
+	form.Set("user", p.userKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pushoverAPIURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create Pushover request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to Pushover: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("pushover responded with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package notifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"alert-dispatcher/internal/config"
+	"alert-dispatcher/internal/errs"
+)
+
+// Target types built in to the registry; email and sns are registered by main since they need
+// AWS credentials or a configured sender
+const (
+	TargetSlack      = "slack"
+	TargetWebhook    = "webhook"
+	TargetEmail      = "email"
+	TargetGoogleChat = "googlechat"
+	TargetMattermost = "mattermost"
+	TargetTelegram   = "telegram"
+	TargetOpsgenie   = "opsgenie"
+	TargetPagerDuty  = "pagerduty"
+	TargetTwilio     = "twilio"
+	TargetWhatsApp   = "whatsapp"
+	TargetPushover   = "pushover"
+	TargetNtfy       = "ntfy"
+	TargetSNS        = "sns"
+)
+
+// Factory creates the notifier for a route target, taking credentials from cfg, and timeouts
+// from the target or else cfg
+type Factory func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error)
+
+var (
+	registryMu sync.RWMutex
+	factories  = map[string]Factory{
+		TargetSlack: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			if target.Channel == "" {
+				return nil, fmt.Errorf("slack target needs a channel")
+			}
+			return NewSlackNotifier(cfg.SlackBotToken, target.Channel, target.TimeoutOr(cfg.SlackTimeout)).WithAPIURL(cfg.SlackAPIURL).
+				WithStandby(cfg.SlackStandbyBotToken, cfg.SlackStandbyAPIURL, cfg.SlackFailoverAfter), nil
+		},
+		TargetWebhook: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			if target.URL == "" {
+				return nil, fmt.Errorf("webhook target needs a url")
+			}
+			return NewWebhookNotifier(target.URL, target.TimeoutOr(cfg.WebhookTimeout)), nil
+		},
+		TargetGoogleChat: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			if target.URL == "" {
+				return nil, fmt.Errorf("googlechat target needs a url")
+			}
+			return NewGoogleChatNotifier(target.URL, target.TimeoutOr(cfg.WebhookTimeout)), nil
+		},
+		TargetMattermost: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			mm := cfg.Mattermost
+			if mm.URL == "" || target.Channel == "" {
+				return nil, fmt.Errorf("mattermost target needs MATTERMOST_URL and a channel")
+			}
+			return NewMattermostNotifier(mm.URL, mm.BotToken, target.Channel, target.TimeoutOr(mm.Timeout)).WithActions(mm.ActionURL, mm.ActionSecret), nil
+		},
+		TargetTelegram: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			tg := cfg.Notifiers.Telegram
+			if tg == nil || tg.BotToken == "" || target.Channel == "" {
+				return nil, fmt.Errorf("telegram target needs notifiers.telegram with TELEGRAM_BOT_TOKEN and a chat ID as channel")
+			}
+			return NewTelegramNotifier(tg.BotToken, target.Channel, target.TimeoutOr(tg.Timeout)), nil
+		},
+		TargetOpsgenie: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			og := cfg.Opsgenie
+			if og.APIKey == "" {
+				return nil, fmt.Errorf("opsgenie target needs OPSGENIE_API_KEY")
+			}
+			return NewOpsgenieNotifier(og.APIURL, og.APIKey, target.TimeoutOr(og.Timeout)), nil
+		},
+		TargetPagerDuty: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			pd := cfg.PagerDuty
+			if pd.RoutingKey == "" {
+				return nil, fmt.Errorf("pagerduty target needs PAGERDUTY_ROUTING_KEY")
+			}
+			return NewPagerDutyNotifier(pd.EventsURL, pd.RoutingKey, target.TimeoutOr(pd.Timeout)), nil
+		},
+		TargetTwilio: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			tw := cfg.Notifiers.Twilio
+			if tw == nil || tw.AccountSID == "" || tw.AuthToken == "" {
+				return nil, fmt.Errorf("twilio target needs notifiers.twilio with TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN")
+			}
+			to := target.To
+			if len(to) == 0 {
+				to = tw.To
+			}
+			return NewTwilioNotifier(tw.AccountSID, tw.AuthToken, tw.From, to, tw.Voice, target.TimeoutOr(tw.Timeout)), nil
+		},
+		TargetWhatsApp: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			wa := cfg.Notifiers.WhatsApp
+			if wa == nil || wa.AccessToken == "" || wa.PhoneNumberID == "" || wa.Template == "" {
+				return nil, fmt.Errorf("whatsapp target needs notifiers.whatsapp with WHATSAPP_ACCESS_TOKEN, a phone_number_id and a template")
+			}
+			to := target.To
+			if len(to) == 0 {
+				to = wa.To
+			}
+			return NewWhatsAppNotifier(wa.APIURL, wa.PhoneNumberID, wa.AccessToken, wa.Template, wa.Language, to, target.TimeoutOr(wa.Timeout)), nil
+		},
+		TargetPushover: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			po := cfg.Pushover
+			userKey := target.Channel
+			if userKey == "" {
+				userKey = po.UserKey
+			}
+			if po.AppToken == "" || userKey == "" {
+				return nil, fmt.Errorf("pushover target needs PUSHOVER_APP_TOKEN and a user key as channel or PUSHOVER_USER_KEY")
+			}
+			return NewPushoverNotifier(po.AppToken, userKey, target.TimeoutOr(po.Timeout)), nil
+		},
+		TargetNtfy: func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+			if target.Channel == "" {
+				return nil, fmt.Errorf("ntfy target needs a topic as channel")
+			}
+			serverURL := cfg.Ntfy.URL
+			if target.URL != "" {
+				serverURL = target.URL
+			}
+			return NewNtfyNotifier(serverURL, target.Channel, cfg.Ntfy.Token, target.TimeoutOr(cfg.Ntfy.Timeout)), nil
+		},
+	}
+)
+
+// Register makes a target type available to routes, replacing any existing factory for it
+func Register(targetType string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	factories[targetType] = factory
+}
+
+// New creates the notifier for a route target
+func New(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+	registryMu.RLock()
+	factory, ok := factories[target.Type]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, &errs.ConfigError{Setting: "target", Err: fmt.Errorf("unknown target type %q (registered: %s)", target.Type, strings.Join(registeredTypes(), ", "))}
+	}
+
+	n, err := factory(target, cfg)
+	if err != nil {
+		return nil, &errs.ConfigError{Setting: target.Type + " target", Err: err}
+	}
+	return n, nil
+}
+
+func registeredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for targetType := range factories {
+		types = append(types, targetType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// EmailFactory creates email targets sending through sender to the target's addresses
+func EmailFactory(sender EmailSender) Factory {
+	return func(target config.TargetConfig, cfg *config.Config) (AlertNotifier, error) {
+		to := target.To
+		if len(to) == 0 {
+			to = cfg.Email.To
+		}
+		if len(to) == 0 {
+			return nil, fmt.Errorf("email target needs to addresses")
+		}
+		return NewEmailNotifier(sender, cfg.Email.From, to), nil
+	}
+}
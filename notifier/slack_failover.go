@@ -0,0 +1,141 @@
+package notifier
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"alert-dispatcher/internal/metrics"
+)
+
+var slackFailoverActive = metrics.NewGauge("alert_dispatcher_slack_failover_active",
+	"1 while alerts are posted to the standby Slack workspace because the primary keeps failing")
+
+// Slack API errors meaning the primary workspace can't take messages at all, rather than that
+// one message is wrong
+var slackWorkspaceErrors = map[string]bool{
+	"ratelimited":             true,
+	"invalid_auth":            true,
+	"not_authed":              true,
+	"account_inactive":        true,
+	"token_revoked":           true,
+	"token_expired":           true,
+	"team_access_not_granted": true,
+	"service_unavailable":     true,
+}
+
+// isSlackWorkspaceError reports whether err is an auth or rate limit error from the workspace
+func isSlackWorkspaceError(err error) bool {
+	var apiErr slack.SlackErrorResponse
+	var rateLimited *slack.RateLimitedError
+	switch {
+	case errors.As(err, &rateLimited):
+		return true
+	case errors.As(err, &apiErr):
+		return slackWorkspaceErrors[apiErr.Err]
+	}
+	return false
+}
+
+// slackFailover moves posting to a standby workspace once the primary has returned auth or rate
+// limit errors for a sustained period, and back once it accepts a message again. While on the
+// standby, a message is tried on the primary first once per period to find out.
+type slackFailover struct {
+	standby *slack.Client
+	after   time.Duration // how long the primary must keep failing before switching
+
+	mu           sync.Mutex
+	failingSince time.Time // first of the primary's current run of workspace errors
+	active       bool      // posting to the standby
+	lastProbe    time.Time
+	standbyFrom  time.Time // when posting last moved to the standby
+	standbyUntil time.Time // when it last moved back, zero while it's active
+}
+
+// slackFailovers holds one failover per primary and standby workspace, shared by notifiers
+var slackFailovers sync.Map
+
+func slackFailoverFor(primaryKey, botToken, apiURL string, after time.Duration) *slackFailover {
+	key := primaryKey + "|" + botToken + "|" + apiURL
+	if failover, ok := slackFailovers.Load(key); ok {
+		return failover.(*slackFailover)
+	}
+	failover, _ := slackFailovers.LoadOrStore(key, &slackFailover{standby: slackClient(botToken, apiURL), after: after})
+	return failover.(*slackFailover)
+}
+
+// useStandby reports whether the next message goes straight to the standby, which is while
+// failover is active and no probe of the primary is due
+func (f *slackFailover) useStandby() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.active {
+		return false
+	}
+	if time.Since(f.lastProbe) >= f.after {
+		f.lastProbe = time.Now()
+		return false
+	}
+	return true
+}
+
+// observe records the outcome of posting to the primary and reports whether the message should
+// go to the standby instead
+func (f *slackFailover) observe(err error) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+
+	if err == nil || !isSlackWorkspaceError(err) {
+		// Any answer other than a workspace error means the primary is back
+		f.failingSince = time.Time{}
+		if f.active {
+			f.active = false
+			f.standbyUntil = now
+			slackFailoverActive.Set(0)
+			log.Printf("Primary Slack workspace is accepting messages again, leaving the standby workspace")
+		}
+		return false
+	}
+
+	if f.failingSince.IsZero() {
+		f.failingSince = now
+	}
+	if !f.active && now.Sub(f.failingSince) >= f.after {
+		f.active = true
+		f.lastProbe = now
+		f.standbyFrom, f.standbyUntil = now, time.Time{}
+		slackFailoverActive.Set(1)
+		log.Printf("Primary Slack workspace has been failing for %s (%v), posting to the standby workspace", now.Sub(f.failingSince).Round(time.Second), err)
+	}
+	return f.active
+}
+
+// threadIn returns the parent timestamp to use in the workspace, or none when the parent was
+// posted in the other one. Timestamps are when the parent was posted, so parents posted while
+// the standby was active are the standby's.
+func (f *slackFailover) threadIn(standby bool, threadTS string) string {
+	if threadTS == "" {
+		return ""
+	}
+	seconds, err := strconv.ParseFloat(threadTS, 64)
+	if err != nil {
+		return threadTS
+	}
+	posted := time.Unix(0, int64(seconds*float64(time.Second)))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.standbyFrom.IsZero() {
+		return threadTS
+	}
+	onStandby := !posted.Before(f.standbyFrom) && (f.standbyUntil.IsZero() || posted.Before(f.standbyUntil))
+	if onStandby != standby {
+		return ""
+	}
+	return threadTS
+}
@@ -1,40 +1,143 @@
 package notifier
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/slack-go/slack"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+	"alert-dispatcher/internal/httpclient"
 )
 
 type SlackNotifier struct {
-	client  *slack.Client
-	channel string
+	client   *slack.Client
+	botToken string
+	apiURL   string
+	channel  string
+	threadTS string // when set, messages are posted as replies in this thread
+	postedTS string // timestamp of the last message posted by this notifier
+	timeout  time.Duration
+	failover *slackFailover // nil without a standby workspace
+
+	quickActions []QuickAction // buttons added below the alert's actions
+	links        []LinkButton  // link buttons added below the alert's actions
+	fetchLogs    bool          // add a Fetch logs button beside the links
+	refreshGraph bool          // add a Refresh graph button beside the links
 }
 
-func NewSlackNotifier(botToken, channel string) *SlackNotifier {
+// NewSlackNotifier creates a notifier for the channel; every Slack API call is bounded by timeout
+func NewSlackNotifier(botToken, channel string, timeout time.Duration) *SlackNotifier {
 	return &SlackNotifier{
-		client:  slack.New(botToken),
-		channel: channel,
+		client:   slackClient(botToken, ""),
+		botToken: botToken,
+		channel:  channel,
+		timeout:  timeout,
 	}
 }
 
-func (s *SlackNotifier) Notify(message string) error {
-	return s.NotifyWithButtons(message, "")
+// WithAPIURL sends Slack API calls to apiURL instead of slack.com, e.g. a proxy or a stub.
+// An empty URL keeps the default.
+func (s *SlackNotifier) WithAPIURL(apiURL string) *SlackNotifier {
+	if apiURL != "" {
+		s.client = slackClient(s.botToken, apiURL)
+		s.apiURL = apiURL
+	}
+	return s
 }
 
-func (s *SlackNotifier) NotifyWithButtons(message, alertID string) error {
-	if alertID == "" {
-		alertID = fmt.Sprintf("alert_%d", len(message))
+// WithStandby posts messages to the standby workspace of botToken, and apiURL unless empty,
+// once the primary has returned auth or rate limit errors for after, until it recovers. The
+// standby needs channels of the same names. An empty token keeps the primary only.
+func (s *SlackNotifier) WithStandby(botToken, apiURL string, after time.Duration) *SlackNotifier {
+	if botToken != "" {
+		s.failover = slackFailoverFor(s.botToken+"|"+s.apiURL, botToken, apiURL, after)
 	}
+	return s
+}
 
-	acknowledgeBtn := slack.NewButtonBlockElement("acknowledge", alertID, slack.NewTextBlockObject("plain_text", "✅ Acknowledge", false, false))
-	acknowledgeBtn.Style = slack.StylePrimary
+// slackClients holds one client per workspace (bot token and API URL). Notifiers are created per
+// alert, and a shared client keeps the pooled connection to Slack warm between them.
+var slackClients sync.Map
 
-	dismissBtn := slack.NewButtonBlockElement("dismiss", alertID, slack.NewTextBlockObject("plain_text", "✖️ Dismiss", false, false))
-	dismissBtn.Style = slack.StyleDanger
+func slackClient(botToken, apiURL string) *slack.Client {
+	key := botToken + "|" + apiURL
+	if client, ok := slackClients.Load(key); ok {
+		return client.(*slack.Client)
+	}
+
+	options := []slack.Option{slack.OptionHTTPClient(httpclient.New(0))}
+	if apiURL != "" {
+		options = append(options, slack.OptionAPIURL(strings.TrimRight(apiURL, "/")+"/"))
+	}
+	client, _ := slackClients.LoadOrStore(key, slack.New(botToken, options...))
+	return client.(*slack.Client)
+}
+
+// Clone returns a notifier for the same channel and client with no thread or posted message, so
+// a cached notifier can be shared by alerts posted concurrently
+func (s *SlackNotifier) Clone() *SlackNotifier {
+	return &SlackNotifier{
+		client:   s.client,
+		botToken: s.botToken,
+		apiURL:   s.apiURL,
+		channel:  s.channel,
+		timeout:  s.timeout,
+		failover: s.failover,
+	}
+}
 
-	actionBlock := slack.NewActionBlock("alert_actions", acknowledgeBtn, dismissBtn)
+// InThread makes subsequent messages replies under the given parent timestamp
+func (s *SlackNotifier) InThread(threadTS string) *SlackNotifier {
+	s.threadTS = threadTS
+	return s
+}
+
+// WithQuickActions adds buttons for the actions below the action buttons of alerts posted in
+// full, which Slack asks the user to confirm before sending
+func (s *SlackNotifier) WithQuickActions(actions []QuickAction) *SlackNotifier {
+	s.quickActions = actions
+	return s
+}
+
+// WithLinks adds buttons opening the links below the action buttons of alerts posted in full
+func (s *SlackNotifier) WithLinks(links []LinkButton) *SlackNotifier {
+	s.links = links
+	return s
+}
+
+// WithFetchLogs adds a Fetch logs button beside the links of alerts posted in full
+func (s *SlackNotifier) WithFetchLogs(enabled bool) *SlackNotifier {
+	s.fetchLogs = enabled
+	return s
+}
+
+// WithRefreshGraph adds a Refresh graph button beside the links of alerts posted in full
+func (s *SlackNotifier) WithRefreshGraph(enabled bool) *SlackNotifier {
+	s.refreshGraph = enabled
+	return s
+}
+
+// PostedTimestamp returns the timestamp of the last message posted, usable as a thread parent
+func (s *SlackNotifier) PostedTimestamp() string {
+	return s.postedTS
+}
+
+func (s *SlackNotifier) Notify(ctx context.Context, message string) error {
+	return s.NotifyWithButtons(ctx, message, "")
+}
+
+func (s *SlackNotifier) NotifyWithButtons(ctx context.Context, message, alertID string) error {
+	if alertID == "" {
+		alertID = fmt.Sprintf("alert_%d", len(message))
+	}
 
 	headerSection := slack.NewSectionBlock(
 		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("🚨 *Alert*\n%s", message), false, false),
@@ -43,10 +146,16 @@ func (s *SlackNotifier) NotifyWithButtons(message, alertID string) error {
 
 	blocks := []slack.Block{
 		headerSection,
-		actionBlock,
+		alertActionBlock(alertID),
+	}
+	if len(s.quickActions) > 0 {
+		blocks = append(blocks, quickActionBlock(alertID, s.quickActions))
+	}
+	if s.fetchLogs || s.refreshGraph || len(s.links) > 0 {
+		blocks = append(blocks, s.contextActionBlock(alertID))
 	}
 
-	_, _, err := s.client.PostMessage(s.channel,
+	err := s.post(ctx,
 		slack.MsgOptionBlocks(blocks...),
 		slack.MsgOptionText(message, false),
 	)
@@ -58,3 +167,390 @@ func (s *SlackNotifier) NotifyWithButtons(message, alertID string) error {
 
 	return nil
 }
+
+// NotifyAlert posts the adapter's rendering of the alert with its action buttons
+func (s *SlackNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	return s.NotifyWithButtons(ctx, a.Message, alertID)
+}
+
+// NotifyAttachment sends the alert as a legacy attachment so Slack draws a color bar beside it
+func (s *SlackNotifier) NotifyAttachment(ctx context.Context, message, alertID, color string) error {
+	if alertID == "" {
+		alertID = fmt.Sprintf("alert_%d", len(message))
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", message, false, false), nil, nil),
+		alertActionBlock(alertID),
+	}
+	if len(s.quickActions) > 0 {
+		blocks = append(blocks, quickActionBlock(alertID, s.quickActions))
+	}
+	if s.fetchLogs || s.refreshGraph || len(s.links) > 0 {
+		blocks = append(blocks, s.contextActionBlock(alertID))
+	}
+	attachment := slack.Attachment{
+		Color:    color,
+		Fallback: message,
+		Blocks:   slack.Blocks{BlockSet: blocks},
+	}
+
+	err := s.post(ctx,
+		slack.MsgOptionAttachments(attachment),
+		slack.MsgOptionText(message, false),
+	)
+
+	if err != nil {
+		log.Printf("Failed to send Slack attachment: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// NotifyCompact posts a one-line alert with a Details button that expands the full message in-thread.
+// A non-empty color wraps the line in an attachment so compact routes keep their severity bar.
+func (s *SlackNotifier) NotifyCompact(ctx context.Context, summary, alertID, color string) error {
+	detailsBtn := slack.NewButtonBlockElement("details", alertID, slack.NewTextBlockObject("plain_text", "Details", false, false))
+	line := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", summary, false, false), nil, slack.NewAccessory(detailsBtn))
+
+	option := slack.MsgOptionBlocks(line)
+	if color != "" {
+		option = slack.MsgOptionAttachments(slack.Attachment{
+			Color:    color,
+			Fallback: summary,
+			Blocks:   slack.Blocks{BlockSet: []slack.Block{line}},
+		})
+	}
+
+	err := s.post(ctx, option, slack.MsgOptionText(summary, false))
+	if err != nil {
+		log.Printf("Failed to send compact Slack message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// NotifyThreadReply posts the full alert with its action buttons as a reply under threadTS
+func (s *SlackNotifier) NotifyThreadReply(ctx context.Context, message, alertID, threadTS string) error {
+	section := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", message, false, false), nil, nil)
+
+	err := s.InThread(threadTS).post(ctx,
+		slack.MsgOptionBlocks(section, alertActionBlock(alertID)),
+		slack.MsgOptionText(message, false),
+	)
+	if err != nil {
+		log.Printf("Failed to send Slack thread reply: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// NotifyThreadSnippet uploads content as a file snippet in the thread under threadTS.
+// Uploads need a channel ID rather than a name and the files:write scope.
+func (s *SlackNotifier) NotifyThreadSnippet(ctx context.Context, content, filename, threadTS string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.client.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+		Channel:         s.channel,
+		ThreadTimestamp: threadTS,
+		Content:         content,
+		FileSize:        len(content),
+		Filename:        filename,
+		Title:           filename,
+	})
+	if err != nil {
+		log.Printf("Failed to upload Slack snippet: %v", err)
+		return slackDeliveryError(err)
+	}
+
+	return nil
+}
+
+// NotifyThreadImage uploads a PNG image with a comment in the thread under threadTS. Like
+// snippets, uploads need a channel ID and the files:write scope.
+func (s *SlackNotifier) NotifyThreadImage(ctx context.Context, image []byte, filename, comment, threadTS string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.client.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+		Channel:         s.channel,
+		ThreadTimestamp: threadTS,
+		Reader:          bytes.NewReader(image),
+		FileSize:        len(image),
+		Filename:        filename,
+		Title:           filename,
+		InitialComment:  comment,
+	})
+	if err != nil {
+		log.Printf("Failed to upload Slack image: %v", err)
+		return slackDeliveryError(err)
+	}
+
+	return nil
+}
+
+// NotifyText posts plain text without blocks or buttons
+func (s *SlackNotifier) NotifyText(ctx context.Context, text string) error {
+	err := s.post(ctx, slack.MsgOptionText(text, false))
+	if err != nil {
+		log.Printf("Failed to send Slack text message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// NotifyColoredText posts text in an attachment so Slack draws a color bar beside it
+func (s *SlackNotifier) NotifyColoredText(ctx context.Context, text, color string) error {
+	section := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", text, false, false), nil, nil)
+	err := s.post(ctx, slack.MsgOptionAttachments(slack.Attachment{
+		Color:    color,
+		Fallback: text,
+		Blocks:   slack.Blocks{BlockSet: []slack.Block{section}},
+	}), slack.MsgOptionText(text, false))
+	if err != nil {
+		log.Printf("Failed to send colored Slack text message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// NotifyDigest posts the parent message of a group of alerts, with buttons that acknowledge or
+// dismiss every alert posted under it
+func (s *SlackNotifier) NotifyDigest(ctx context.Context, text string) error {
+	section := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", text, false, false), nil, nil)
+
+	acknowledgeBtn := slack.NewButtonBlockElement("acknowledge_all", "digest", slack.NewTextBlockObject("plain_text", "✅ Acknowledge all", false, false))
+	acknowledgeBtn.Style = slack.StylePrimary
+	dismissBtn := slack.NewButtonBlockElement("dismiss_all", "digest", slack.NewTextBlockObject("plain_text", "✖️ Dismiss all", false, false))
+	dismissBtn.Style = slack.StyleDanger
+
+	err := s.post(ctx,
+		slack.MsgOptionBlocks(section, slack.NewActionBlock("digest_actions", acknowledgeBtn, dismissBtn)),
+		slack.MsgOptionText(text, false),
+	)
+	if err != nil {
+		log.Printf("Failed to send Slack digest message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// UnfurlCard is the preview shown in place of a shared alert link
+type UnfurlCard struct {
+	Text    string
+	Color   string
+	AlertID string // adds the alert's action buttons when set
+}
+
+// Unfurl replaces the previews of links in the message at messageTS with cards, keyed by URL.
+// It needs the links:write scope.
+func (s *SlackNotifier) Unfurl(ctx context.Context, messageTS string, cards map[string]UnfurlCard) error {
+	unfurls := make(map[string]slack.Attachment, len(cards))
+	for url, card := range cards {
+		blocks := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", card.Text, false, false), nil, nil)}
+		if card.AlertID != "" {
+			blocks = append(blocks, alertActionBlock(card.AlertID))
+		}
+		unfurls[url] = slack.Attachment{Color: card.Color, Blocks: slack.Blocks{BlockSet: blocks}}
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, _, _, err := s.client.UnfurlMessageContext(ctx, s.channel, messageTS, unfurls); err != nil {
+		log.Printf("Failed to unfurl Slack links: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// post sends a message to the notifier's channel, threading it when a parent is set, on the
+// standby workspace instead while failover is active
+func (s *SlackNotifier) post(ctx context.Context, options ...slack.MsgOption) error {
+	if s.failover == nil {
+		return s.postWith(ctx, s.client, s.threadTS, options)
+	}
+	if s.failover.useStandby() {
+		return s.postWith(ctx, s.failover.standby, s.failover.threadIn(true, s.threadTS), options)
+	}
+	err := s.postWith(ctx, s.client, s.failover.threadIn(false, s.threadTS), options)
+	if s.failover.observe(err) {
+		return s.postWith(ctx, s.failover.standby, s.failover.threadIn(true, s.threadTS), options)
+	}
+	return err
+}
+
+func (s *SlackNotifier) postWith(ctx context.Context, client *slack.Client, threadTS string, options []slack.MsgOption) error {
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, ts, err := client.PostMessageContext(ctx, s.channel, options...)
+	if err != nil {
+		return slackDeliveryError(err)
+	}
+	s.postedTS = ts
+	return nil
+}
+
+// Slack API errors worth retrying; the rest, like channel_not_found or invalid_auth, fail the
+// same way every time
+var transientSlackErrors = map[string]bool{
+	"ratelimited":         true,
+	"internal_error":      true,
+	"fatal_error":         true,
+	"service_unavailable": true,
+	"request_timeout":     true,
+}
+
+// slackDeliveryError classifies a failed Slack API call. Errors without a response, such as
+// timeouts and connection resets, are transient.
+func slackDeliveryError(err error) error {
+	var apiErr slack.SlackErrorResponse
+	var retryable interface{ Retryable() bool }
+	switch {
+	case errors.As(err, &apiErr):
+		if transientSlackErrors[apiErr.Err] {
+			return &errs.TransientDeliveryError{Destination: "slack", Err: err}
+		}
+		return &errs.PermanentDeliveryError{Destination: "slack", Err: err}
+	case errors.As(err, &retryable) && !retryable.Retryable():
+		return &errs.PermanentDeliveryError{Destination: "slack", Err: err}
+	default:
+		return &errs.TransientDeliveryError{Destination: "slack", Err: err}
+	}
+}
+
+// withTimeout bounds a Slack API call so a hung request can't stall delivery
+func (s *SlackNotifier) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// SeverityColor maps an alert priority to a Slack attachment color
+func SeverityColor(priority string, resolved bool) string {
+	if resolved {
+		return "good"
+	}
+	switch priority {
+	case "P0":
+		return "danger"
+	case "P1":
+		return "warning"
+	default:
+		return "#439FE0"
+	}
+}
+
+// SnoozeDurations are the choices of the Snooze menu on alerts
+var SnoozeDurations = []string{"30m", "1h", "4h", "24h"}
+
+func alertActionBlock(alertID string) *slack.ActionBlock {
+	acknowledgeBtn := slack.NewButtonBlockElement("acknowledge", alertID, slack.NewTextBlockObject("plain_text", "✅ Acknowledge", false, false))
+	acknowledgeBtn.Style = slack.StylePrimary
+
+	dismissBtn := slack.NewButtonBlockElement("dismiss", alertID, slack.NewTextBlockObject("plain_text", "✖️ Dismiss", false, false))
+	dismissBtn.Style = slack.StyleDanger
+
+	rawPayloadBtn := slack.NewButtonBlockElement("raw_payload", alertID, slack.NewTextBlockObject("plain_text", "📄 Raw payload", false, false))
+
+	// Was this alert actionable? Answers feed the noise report
+	actionableBtn := slack.NewButtonBlockElement("feedback_actionable", alertID, slack.NewTextBlockObject("plain_text", "👍", true, false))
+	notActionableBtn := slack.NewButtonBlockElement("feedback_not_actionable", alertID, slack.NewTextBlockObject("plain_text", "👎", true, false))
+
+	// Option values are "<priority>|<alert ID>" since overflow menus have no per-menu value
+	var priorities []*slack.OptionBlockObject
+	for _, priority := range []string{"P0", "P1", "P2"} {
+		priorities = append(priorities, slack.NewOptionBlockObject(priority+"|"+alertID,
+			slack.NewTextBlockObject("plain_text", "Change priority to "+priority, false, false), nil))
+	}
+	priorityMenu := slack.NewOverflowBlockElement("change_priority", priorities...)
+
+	// Option values are "<duration>|<alert ID>" like the priority menu's
+	var snoozes []*slack.OptionBlockObject
+	for _, duration := range SnoozeDurations {
+		snoozes = append(snoozes, slack.NewOptionBlockObject(duration+"|"+alertID,
+			slack.NewTextBlockObject("plain_text", "For "+duration, false, false), nil))
+	}
+	snoozeMenu := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic,
+		slack.NewTextBlockObject("plain_text", "💤 Snooze", true, false), "snooze", snoozes...)
+
+	return slack.NewActionBlock("alert_actions", acknowledgeBtn, dismissBtn, snoozeMenu, rawPayloadBtn, actionableBtn, notActionableBtn, priorityMenu)
+}
+
+// QuickActionPrefix starts the action IDs of quick action buttons, followed by the action's name
+const QuickActionPrefix = "quick_action:"
+
+// QuickAction is a button that remediates what an alert is about
+type QuickAction struct {
+	Name    string
+	Label   string
+	Confirm string // what the action will do, asked before it is sent
+}
+
+func quickActionBlock(alertID string, actions []QuickAction) *slack.ActionBlock {
+	var buttons []slack.BlockElement
+	for _, action := range actions {
+		confirm := slack.NewConfirmationBlockObject(
+			slack.NewTextBlockObject("plain_text", action.Label+"?", false, false),
+			slack.NewTextBlockObject("mrkdwn", action.Confirm, false, false),
+			slack.NewTextBlockObject("plain_text", "Run it", false, false),
+			slack.NewTextBlockObject("plain_text", "Cancel", false, false),
+		)
+		button := slack.NewButtonBlockElement(QuickActionPrefix+action.Name, alertID,
+			slack.NewTextBlockObject("plain_text", "🛠️ "+action.Label, true, false)).WithConfirm(confirm)
+		buttons = append(buttons, button)
+	}
+	return slack.NewActionBlock("quick_actions", buttons...)
+}
+
+// LinkActionPrefix starts the action IDs of link buttons. Slack opens their URL in the browser
+// and still sends the click, which only needs acknowledging.
+const LinkActionPrefix = "open_link:"
+
+// LinkButton is a button opening a page about what an alert is about, e.g. in the AWS console
+type LinkButton struct {
+	Name  string // unique among the alert's links
+	Label string
+	URL   string
+}
+
+// Action IDs of the Fetch logs and Refresh graph buttons
+const (
+	FetchLogsAction    = "fetch_logs"
+	RefreshGraphAction = "refresh_graph"
+)
+
+// contextActionBlock holds the buttons that bring up context on an alert: its logs, a current
+// graph and links
+func (s *SlackNotifier) contextActionBlock(alertID string) *slack.ActionBlock {
+	var buttons []slack.BlockElement
+	if s.fetchLogs {
+		buttons = append(buttons, slack.NewButtonBlockElement(FetchLogsAction, alertID,
+			slack.NewTextBlockObject("plain_text", "📜 Fetch logs", true, false)))
+	}
+	if s.refreshGraph {
+		buttons = append(buttons, slack.NewButtonBlockElement(RefreshGraphAction, alertID,
+			slack.NewTextBlockObject("plain_text", "📈 Refresh graph", true, false)))
+	}
+	for _, link := range s.links {
+		button := slack.NewButtonBlockElement(LinkActionPrefix+link.Name, alertID,
+			slack.NewTextBlockObject("plain_text", link.Label, true, false))
+		button.URL = link.URL
+		buttons = append(buttons, button)
+	}
+	return slack.NewActionBlock("context_actions", buttons...)
+}
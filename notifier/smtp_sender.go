@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends email through an SMTP relay, upgrading to TLS when the server offers STARTTLS.
+// The whole session is bounded by the context deadline.
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+}
+
+func NewSMTPSender(host, port, username, password string) *SMTPSender {
+	return &SMTPSender{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+	}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg EmailMessage) error {
+	body, err := buildMIMEMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.host, s.port))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP session: %v", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %v", err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %v", err)
+		}
+	}
+
+	if err := client.Mail(msg.From); err != nil {
+		return fmt.Errorf("failed to set sender: %v", err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %v", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %v", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+	return client.Quit()
+}
+
+// buildMIMEMessage encodes the email as multipart/alternative with text and HTML parts
+func buildMIMEMessage(msg EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", msg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %v", err)
+		}
+		// Quoted-printable keeps long HTML lines within SMTP's line length limit
+		qw := quotedprintable.NewWriter(w)
+		qw.Write([]byte(part.content))
+		qw.Close()
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %v", err)
+	}
+	return buf.Bytes(), nil
+}
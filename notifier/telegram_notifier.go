@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+)
+
+const telegramAPIURL = "https://api.telegram.org"
+
+// Telegram rejects messages longer than this
+const telegramMaxMessage = 4096
+
+// TelegramNotifier posts to a Telegram chat through the Bot API
+type TelegramNotifier struct {
+	botToken string
+	chatID   string
+	client   *http.Client
+}
+
+func NewTelegramNotifier(botToken, chatID string, timeout time.Duration) *TelegramNotifier {
+	return &TelegramNotifier{
+		botToken: botToken,
+		chatID:   chatID,
+		client:   httpclient.New(timeout),
+	}
+}
+
+type telegramButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+type telegramKeyboard struct {
+	InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+}
+
+type telegramMessage struct {
+	ChatID      string            `json:"chat_id"`
+	MessageID   int64             `json:"message_id,omitempty"`
+	Text        string            `json:"text"`
+	ParseMode   string            `json:"parse_mode,omitempty"`
+	ReplyMarkup *telegramKeyboard `json:"reply_markup,omitempty"`
+}
+
+// Notify posts a plain-text message to the chat
+func (t *TelegramNotifier) Notify(ctx context.Context, message string) error {
+	return t.call(ctx, "sendMessage", telegramMessage{
+		ChatID: t.chatID,
+		Text:   truncate(message, telegramMaxMessage),
+	})
+}
+
+// NotifyAlert posts the alert with Acknowledge and Dismiss buttons. Their callback data is
+// "<action>:<alertID>", matching the Slack action IDs.
+func (t *TelegramNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	icon := "🔥"
+	if a.IsResolved() {
+		icon = "✅"
+	}
+	text := fmt.Sprintf("%s <b>[%s] %s</b> is %s", icon, html.EscapeString(a.Severity), html.EscapeString(a.Name), strings.ToUpper(a.Status))
+	if summary := a.Annotations["summary"]; summary != "" {
+		text += "\n" + html.EscapeString(summary)
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		text += fmt.Sprintf("\n<a href=\"%s\">View in %s</a>", html.EscapeString(link), html.EscapeString(a.Source))
+	}
+
+	message := telegramMessage{
+		ChatID:    t.chatID,
+		Text:      text,
+		ParseMode: "HTML",
+	}
+	if !a.IsResolved() {
+		message.ReplyMarkup = &telegramKeyboard{InlineKeyboard: [][]telegramButton{{
+			{Text: "✅ Acknowledge", CallbackData: "acknowledge:" + alertID},
+			{Text: "❌ Dismiss", CallbackData: "dismiss:" + alertID},
+		}}}
+	}
+	return t.call(ctx, "sendMessage", message)
+}
+
+// EditMessage replaces the text of a message in the chat, removing its buttons
+func (t *TelegramNotifier) EditMessage(ctx context.Context, messageID int64, text string) error {
+	return t.call(ctx, "editMessageText", telegramMessage{
+		ChatID:    t.chatID,
+		MessageID: messageID,
+		Text:      truncate(text, telegramMaxMessage),
+	})
+}
+
+// AnswerCallback stops the client's loading indicator on a pressed button and shows text briefly
+func (t *TelegramNotifier) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	return t.call(ctx, "answerCallbackQuery", map[string]string{
+		"callback_query_id": callbackID,
+		"text":              text,
+	})
+}
+
+func (t *TelegramNotifier) call(ctx context.Context, method string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Telegram %s request: %v", method, err)
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/%s", telegramAPIURL, t.botToken, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Telegram request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// The URL embeds the bot token, so don't let it leak into logs through the error
+		return fmt.Errorf("failed to call Telegram %s: %v", method, strings.ReplaceAll(err.Error(), t.botToken, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(respBody, &result); err != nil || !result.OK {
+		return fmt.Errorf("telegram %s responded with status %d: %s", method, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
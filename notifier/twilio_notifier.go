@@ -0,0 +1,152 @@
+package notifier
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/httpclient"
+)
+
+const twilioAPIURL = "https://api.twilio.com/2010-04-01"
+
+// SMS bodies longer than this are split into several billed segments, so we keep alerts to one
+const twilioMaxSMS = 160
+
+// TwilioNotifier texts, and optionally calls, a list of phone numbers through the Twilio REST API
+type TwilioNotifier struct {
+	accountSID string
+	authToken  string
+	from       string
+	to         []string
+	voice      bool
+	client     *http.Client
+}
+
+func NewTwilioNotifier(accountSID, authToken, from string, to []string, voice bool, timeout time.Duration) *TwilioNotifier {
+	return &TwilioNotifier{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		to:         to,
+		voice:      voice,
+		client:     httpclient.New(timeout),
+	}
+}
+
+// Notify texts the message to every number
+func (t *TwilioNotifier) Notify(ctx context.Context, message string) error {
+	var failed []string
+	for _, to := range t.to {
+		if err := t.post(ctx, "Messages.json", url.Values{
+			"From": {t.from},
+			"To":   {to},
+			"Body": {truncate(message, twilioMaxSMS)},
+		}); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", to, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to text %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// NotifyAlert texts every number about the alert and, when voice is enabled, calls them to read it out
+func (t *TwilioNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, _ string) error {
+	text := fmt.Sprintf("[%s] %s is %s", a.Severity, a.Name, strings.ToUpper(a.Status))
+	spoken := fmt.Sprintf("%s alert. %s is %s.", a.Severity, a.Name, a.Status)
+	return t.escalate(ctx, a, text, spoken)
+}
+
+// NotifyReminder texts (and calls) about an alert that is still firing, saying how long it has
+// been firing for when age is set and how many times it has been escalated, e.g.
+// "[P0] High CPU STILL FIRING for 2h 15m, escalation 3"
+func (t *TwilioNotifier) NotifyReminder(ctx context.Context, a *alert.Alert, age string, level int) error {
+	text := fmt.Sprintf("[%s] %s STILL FIRING", a.Severity, a.Name)
+	spoken := fmt.Sprintf("%s alert. %s is still firing", a.Severity, a.Name)
+	if age != "" {
+		text += " for " + age
+		spoken += " after " + age
+	}
+	text += fmt.Sprintf(", escalation %d", level)
+	spoken += fmt.Sprintf(". This is escalation %d.", level)
+	return t.escalate(ctx, a, text, spoken)
+}
+
+// escalate texts every number, adding the alert's link when it fits in one SMS, then calls them
+// to read out spoken when voice is enabled
+func (t *TwilioNotifier) escalate(ctx context.Context, a *alert.Alert, text, spoken string) error {
+	if link := a.URLs[alert.URLSource]; link != "" && len(text)+1+len(link) <= twilioMaxSMS {
+		text += " " + link
+	}
+	if err := t.Notify(ctx, text); err != nil {
+		return err
+	}
+	if !t.voice {
+		return nil
+	}
+
+	twiml, err := twilioSay(spoken)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, to := range t.to {
+		if err := t.post(ctx, "Calls.json", url.Values{
+			"From":  {t.from},
+			"To":    {to},
+			"Twiml": {twiml},
+		}); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", to, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to call %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// twilioSay builds TwiML that reads the text out twice
+func twilioSay(text string) (string, error) {
+	type say struct {
+		Loop int    `xml:"loop,attr"`
+		Text string `xml:",chardata"`
+	}
+	twiml, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"Response"`
+		Say     say      `xml:"Say"`
+	}{Say: say{Loop: 2, Text: text}})
+	if err != nil {
+		return "", fmt.Errorf("failed to build TwiML: %v", err)
+	}
+	return string(twiml), nil
+}
+
+func (t *TwilioNotifier) post(ctx context.Context, resource string, form url.Values) error {
+	endpoint := fmt.Sprintf("%s/Accounts/%s/%s", twilioAPIURL, t.accountSID, resource)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create Twilio request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.accountSID, t.authToken)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to Twilio: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("twilio responded with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+	"alert-dispatcher/internal/httpclient"
+)
+
+// WebhookNotifier posts alerts as JSON to an HTTP endpoint, for bots and in-house tools
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: httpclient.New(timeout),
+	}
+}
+
+// Notify posts {"text": message}
+func (w *WebhookNotifier) Notify(ctx context.Context, message string) error {
+	return w.post(ctx, AlertPayload{Text: message})
+}
+
+// NotifyAlert posts {"alert_id": ..., "fingerprint": ..., "alert": ...} with the normalized alert
+func (w *WebhookNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, alertID string) error {
+	return w.post(ctx, NewAlertPayload(a, alertID))
+}
+
+func (w *WebhookNotifier) post(ctx context.Context, payload AlertPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return &errs.TransientDeliveryError{Destination: "webhook", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return errs.Delivery("webhook", resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, string(respBody)))
+	}
+	return nil
+}
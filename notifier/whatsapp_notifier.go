@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert-dispatcher/internal/alert"
+	"alert-dispatcher/internal/errs"
+	"alert-dispatcher/internal/httpclient"
+)
+
+const whatsAppAPIURL = "https://graph.facebook.com/v21.0"
+
+// Template parameters are limited in length and can't contain newlines or tabs
+const whatsAppMaxParameter = 1024
+
+// WhatsAppNotifier sends messages to a list of numbers through the WhatsApp Business Cloud API.
+// Alerts use an approved message template, since free-form text is only delivered to numbers
+// that messaged the business within the last 24 hours.
+type WhatsAppNotifier struct {
+	apiURL        string
+	phoneNumberID string
+	accessToken   string
+	template      string
+	language      string
+	to            []string
+	client        *http.Client
+}
+
+// NewWhatsAppNotifier sends from the business number phoneNumberID. The template's body takes
+// four parameters: priority, alert name, status and description.
+func NewWhatsAppNotifier(apiURL, phoneNumberID, accessToken, template, language string, to []string, timeout time.Duration) *WhatsAppNotifier {
+	if apiURL == "" {
+		apiURL = whatsAppAPIURL
+	}
+	return &WhatsAppNotifier{
+		apiURL:        strings.TrimRight(apiURL, "/"),
+		phoneNumberID: phoneNumberID,
+		accessToken:   accessToken,
+		template:      template,
+		language:      language,
+		to:            to,
+		client:        httpclient.New(timeout),
+	}
+}
+
+type whatsAppParameter struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type whatsAppComponent struct {
+	Type       string              `json:"type"`
+	Parameters []whatsAppParameter `json:"parameters"`
+}
+
+type whatsAppLanguage struct {
+	Code string `json:"code"`
+}
+
+type whatsAppTemplate struct {
+	Name       string              `json:"name"`
+	Language   whatsAppLanguage    `json:"language"`
+	Components []whatsAppComponent `json:"components"`
+}
+
+type whatsAppBody struct {
+	Body string `json:"body"`
+}
+
+type whatsAppMessage struct {
+	MessagingProduct string            `json:"messaging_product"`
+	To               string            `json:"to"`
+	Type             string            `json:"type"`
+	Text             *whatsAppBody     `json:"text,omitempty"`
+	Template         *whatsAppTemplate `json:"template,omitempty"`
+}
+
+// Notify sends the message as free-form text, which only reaches numbers inside the 24-hour
+// customer service window
+func (w *WhatsAppNotifier) Notify(ctx context.Context, message string) error {
+	return w.sendAll(ctx, func(to string) whatsAppMessage {
+		return whatsAppMessage{MessagingProduct: "whatsapp", To: to, Type: "text", Text: &whatsAppBody{Body: message}}
+	})
+}
+
+// NotifyAlert sends the alert template to every number
+func (w *WhatsAppNotifier) NotifyAlert(ctx context.Context, a *alert.Alert, _ string) error {
+	description := a.Annotations["description"]
+	if description == "" {
+		description = a.Annotations["reason"]
+	}
+	if link := a.URLs[alert.URLSource]; link != "" {
+		description = strings.TrimSpace(description + " " + link)
+	}
+	if description == "" {
+		description = "-" // parameters can't be empty
+	}
+
+	template := &whatsAppTemplate{
+		Name:     w.template,
+		Language: whatsAppLanguage{Code: w.language},
+		Components: []whatsAppComponent{{Type: "body", Parameters: []whatsAppParameter{
+			whatsAppText(a.Severity),
+			whatsAppText(a.Name),
+			whatsAppText(strings.ToUpper(a.Status)),
+			whatsAppText(description),
+		}}},
+	}
+
+	return w.sendAll(ctx, func(to string) whatsAppMessage {
+		return whatsAppMessage{MessagingProduct: "whatsapp", To: to, Type: "template", Template: template}
+	})
+}
+
+// whatsAppText collapses whitespace, which template parameters reject, and truncates
+func whatsAppText(text string) whatsAppParameter {
+	return whatsAppParameter{Type: "text", Text: truncate(strings.Join(strings.Fields(text), " "), whatsAppMaxParameter)}
+}
+
+func (w *WhatsAppNotifier) sendAll(ctx context.Context, message func(to string) whatsAppMessage) error {
+	var failed []string
+	for _, to := range w.to {
+		if err := w.send(ctx, message(to)); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", to, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to message %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (w *WhatsAppNotifier) send(ctx context.Context, message whatsAppMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal WhatsApp message: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/messages", w.apiURL, w.phoneNumberID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create WhatsApp request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+w.accessToken)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return &errs.TransientDeliveryError{Destination: "whatsapp", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return errs.Delivery("whatsapp", resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, string(respBody)))
+	}
+	return nil
+}